@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	rpmdb "github.com/chennqqi/go-rpmdb/pkg"
+)
+
+// carveCommand runs "rpmdb carve <file>", listing the rpm headers found in a raw disk image or a
+// memory dump.
+func carveCommand() *command {
+	c := &command{
+		path:    []string{"carve"},
+		args:    "<file>",
+		summary: "find the rpm headers of a raw image",
+		description: "Searches a raw disk image, a memory dump or any file for rpm headers, printing the offset, " +
+			"the size, the kind (rpm for the headers with the magic of rpm files, db for the ones of a database) " +
+			"and the NEVRA of each one decoding to a package. A header cut off or damaged is marked partial with " +
+			"the reason. Extract one with dd and print it with \"rpmdb header dump\".",
+		flags:    newFlagSet([]string{"carve"}),
+		complete: completion{files: true},
+	}
+
+	c.run = func(args []string) error {
+		if len(args) != 1 {
+			return c.usage()
+		}
+		if err := checkInput(args[0]); err != nil {
+			return err
+		}
+		f, err := os.Open(args[0])
+		if err != nil {
+			return &exitError{exitNotFound, err}
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		err = rpmdb.CarveHeaders(f, info.Size(), func(h *rpmdb.CarvedHeader) error {
+			kind := "db"
+			if h.Magic {
+				kind = "rpm"
+			}
+			status := ""
+			if h.Partial {
+				status = fmt.Sprintf("partial: %v", h.Err)
+			}
+			_, err := fmt.Fprintf(tw, "%d\t%d\t%s\t%s\t%s\n", h.Offset, h.Size, kind, h.Package.NEVRA(), status)
+			return err
+		})
+		if flushErr := tw.Flush(); err == nil {
+			err = flushErr
+		}
+		return err
+	}
+	return c
+}
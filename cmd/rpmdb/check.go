@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+)
+
+// checkCommand runs "rpmdb check", cross-checking the headers of a database with its indexes.
+// Inconsistencies exit with exitCorrupt.
+func checkCommand() *command {
+	c := &command{
+		path:    []string{"check"},
+		summary: "check the database against its indexes",
+		description: "Cross-checks the headers of the database with the index databases next to it, or the index " +
+			"tables of an rpmdb.sqlite, for the symptoms of an interrupted rpm transaction: an instance recorded " +
+			"twice, index entries referencing a missing instance and headers missing from the Name index. Every " +
+			"issue is printed with the way to fix it, and the tool exits with the code of a corrupt database when " +
+			"there is any.",
+		flags: newFlagSet([]string{"check"}),
+	}
+	dbPath := c.flags.String("db", "./Packages", "`path` of the rpm database")
+	c.values = map[string]completion{
+		"db": {files: true},
+	}
+
+	c.run = func(args []string) error {
+		if len(args) != 0 {
+			return c.usage()
+		}
+		db, err := openDB(*dbPath)
+		if err != nil {
+			return err
+		}
+		report, err := db.CheckConsistency()
+		if err != nil {
+			return corrupt(fmt.Errorf("%s: %w", *dbPath, err))
+		}
+		for _, issue := range report.Issues {
+			fmt.Printf("%s: %s\n\tfix: %s\n", issue.Problem, issue, issue.Fix)
+		}
+		if len(report.Issues) > 0 {
+			return corrupt(fmt.Errorf("%d issues in %d headers and %d indexes", len(report.Issues), report.Headers, len(report.Indexes)))
+		}
+		return nil
+	}
+	return c
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// command is a command of the tool. The shell completions and the man page are generated from
+// them: a new command or flag is completed and documented once added here.
+type command struct {
+	// path is the words naming the command after "rpmdb", none for the package listing.
+	path []string
+	// args is the synopsis of the arguments, "<file>".
+	args    string
+	summary string
+	// description is the paragraph of the man page, the summary when empty.
+	description string
+	flags       *flag.FlagSet
+	// aliases are the shorthands of flags, by shorthand: they are documented with their flag.
+	aliases map[string]string
+	// values completes the flag values, by flag name, and complete the arguments.
+	values   map[string]completion
+	complete completion
+	// hidden commands are the ones of the completion scripts, neither completed nor documented.
+	hidden bool
+	run    func(args []string) error
+}
+
+// completion is what a flag value or an argument is completed with: file names, the names of the
+// installed packages or a list of words. The zero value completes nothing.
+type completion struct {
+	files    bool
+	packages bool
+	words    []string
+}
+
+func newFlagSet(path []string) *flag.FlagSet {
+	flags := flag.NewFlagSet(strings.Join(append([]string{"rpmdb"}, path...), " "), flag.ContinueOnError)
+	// the usage is printed by the caller, with the synopsis of the command
+	flags.SetOutput(ioutil.Discard)
+	flags.BoolVar(&quiet, "quiet", false, "write the errors as a JSON object")
+	return flags
+}
+
+// name returns "rpmdb header dump".
+func (c *command) name() string {
+	return strings.Join(append([]string{"rpmdb"}, c.path...), " ")
+}
+
+// synopsis returns the name, the flags and the arguments of the command,
+// "rpmdb header dump [-tz zone] <file>".
+func (c *command) synopsis() string {
+	words := []string{c.name()}
+	for _, f := range c.flagList() {
+		words = append(words, "["+flagSynopsis(f)+"]")
+	}
+	if c.args != "" {
+		words = append(words, c.args)
+	}
+	return strings.Join(words, " ")
+}
+
+// flagList returns the flags of the command in name order, the aliases left out.
+func (c *command) flagList() []*flag.Flag {
+	var flags []*flag.Flag
+	c.flags.VisitAll(func(f *flag.Flag) {
+		if _, ok := c.aliases[f.Name]; !ok {
+			flags = append(flags, f)
+		}
+	})
+	return flags
+}
+
+// flagNames returns the names of a flag of the command, its aliases included.
+func (c *command) flagNames(f *flag.Flag) []string {
+	names := []string{f.Name}
+	for alias, name := range c.aliases {
+		if name == f.Name {
+			names = append(names, alias)
+		}
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// flagSynopsis returns "-tz zone", the value name being the back-quoted word of the usage.
+func flagSynopsis(f *flag.Flag) string {
+	value, _ := flag.UnquoteUsage(f)
+	if value == "" {
+		return "-" + f.Name
+	}
+	return "-" + f.Name + " " + value
+}
+
+// takesValue tells whether a flag is followed by a value, the boolean ones not being.
+func takesValue(f *flag.Flag) bool {
+	value, _ := flag.UnquoteUsage(f)
+	return value != ""
+}
+
+// usage returns the usage error of the command.
+func (c *command) usage() error {
+	return &exitError{exitUsage, errors.New("usage: " + c.synopsis())}
+}
+
+// findCommand returns the command the first words of args name and the arguments following its
+// name, the package listing when they don't name one.
+func findCommand(commands []*command, args []string) (*command, []string, error) {
+	var found, root *command
+	for _, c := range commands {
+		if len(c.path) == 0 {
+			root = c
+			continue
+		}
+		if len(c.path) <= len(args) && strings.Join(c.path, " ") == strings.Join(args[:len(c.path)], " ") &&
+			(found == nil || len(c.path) > len(found.path)) {
+			found = c
+		}
+	}
+	if found != nil {
+		return found, args[len(found.path):], nil
+	}
+
+	// "rpmdb header" names a group of commands
+	if len(args) > 0 {
+		var usages []string
+		for _, c := range commands {
+			if len(c.path) > 0 && c.path[0] == args[0] && !c.hidden {
+				usages = append(usages, c.synopsis())
+			}
+		}
+		if len(usages) > 0 {
+			return nil, nil, &exitError{exitUsage, errors.New("usage: " + strings.Join(usages, "\n       "))}
+		}
+	}
+	return root, args, nil
+}
+
+// subcommands returns the sorted words that may follow the command path, the ones of the visible
+// commands it prefixes, with their summary.
+func subcommands(commands []*command, path []string) ([]string, map[string]string) {
+	summaries := make(map[string]string)
+	prefix := strings.Join(path, " ")
+	for _, c := range commands {
+		if c.hidden || len(c.path) <= len(path) || strings.Join(c.path[:len(path)], " ") != prefix {
+			continue
+		}
+		word := c.path[len(path)]
+		// a group, "header", is summed up by one of its commands
+		if _, ok := summaries[word]; !ok || len(c.path) == len(path)+1 {
+			summaries[word] = c.summary
+		}
+	}
+	words := make([]string, 0, len(summaries))
+	for word := range summaries {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words, summaries
+}
+
+// commandPaths returns the sorted paths of the visible commands and of their groups, "header" and
+// "header dump".
+func commandPaths(commands []*command) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, c := range commands {
+		if c.hidden {
+			continue
+		}
+		for i := 1; i <= len(c.path); i++ {
+			path := strings.Join(c.path[:i], " ")
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+var completionShells = map[string]func(w io.Writer, states []*completionState){
+	"bash": writeBashCompletion,
+	"zsh":  writeZshCompletion,
+	"fish": writeFishCompletion,
+}
+
+// completionCommand runs "rpmdb completion <shell>", printing the completion script of the shell.
+func completionCommand() *command {
+	c := &command{
+		path:    []string{"completion"},
+		args:    "bash|zsh|fish",
+		summary: "print a shell completion script",
+		description: "Prints the completion script of bash, zsh or fish, the package names being completed from " +
+			"the database -db names: source <(rpmdb completion bash), " +
+			"rpmdb completion zsh > \"${fpath[1]}/_rpmdb\" or " +
+			"rpmdb completion fish > ~/.config/fish/completions/rpmdb.fish.",
+		flags:    newFlagSet([]string{"completion"}),
+		complete: completion{words: []string{"bash", "fish", "zsh"}},
+	}
+	c.run = func(args []string) error {
+		if len(args) != 1 || completionShells[args[0]] == nil {
+			return c.usage()
+		}
+		w := bufio.NewWriter(os.Stdout)
+		completionShells[args[0]](w, completionStates(commands))
+		return w.Flush()
+	}
+	return c
+}
+
+// completePackagesCommand runs "rpmdb __complete [prefix]", the command the completion scripts
+// run to complete package names: it prints the names of the installed packages starting with
+// prefix, read from the Name index when there is one.
+func completePackagesCommand() *command {
+	c := &command{
+		path:    []string{"__complete"},
+		args:    "[prefix]",
+		summary: "print the package names starting with prefix",
+		flags:   newFlagSet([]string{"__complete"}),
+		hidden:  true,
+	}
+	dbPath := c.flags.String("db", "./Packages", "`path` of the rpm database")
+	c.run = func(args []string) error {
+		if len(args) > 1 {
+			return c.usage()
+		}
+		prefix := ""
+		if len(args) == 1 {
+			prefix = args[0]
+		}
+		db, err := openDB(*dbPath)
+		if err != nil {
+			return err
+		}
+		names, err := db.PackageNames()
+		if err != nil {
+			return corrupt(err)
+		}
+		w := bufio.NewWriter(os.Stdout)
+		for _, name := range names {
+			if strings.HasPrefix(name, prefix) {
+				fmt.Fprintln(w, name)
+			}
+		}
+		return w.Flush()
+	}
+	return c
+}
+
+// completionState is what is completed after the words of a command path, "" for the package
+// listing.
+type completionState struct {
+	path string
+	// flags are the ones of the command, none for a group like "header".
+	flags []*completionFlag
+	// words are the subcommands, with their summary.
+	words     []string
+	summaries map[string]string
+	args      completion
+}
+
+type completionFlag struct {
+	// names are the flag and its aliases
+	names  []string
+	usage  string
+	value  bool
+	values completion
+}
+
+// completionStates returns the state of the package listing and of every command path.
+func completionStates(commands []*command) []*completionState {
+	var states []*completionState
+	for _, path := range append([]string{""}, commandPaths(commands)...) {
+		state := &completionState{path: path}
+		var words []string
+		if path != "" {
+			words = strings.Split(path, " ")
+		}
+		state.words, state.summaries = subcommands(commands, words)
+		for _, c := range commands {
+			if strings.Join(c.path, " ") != path {
+				continue
+			}
+			state.args = c.complete
+			for _, f := range c.flagList() {
+				_, usage := flag.UnquoteUsage(f)
+				state.flags = append(state.flags, &completionFlag{
+					names:  c.flagNames(f),
+					usage:  usage,
+					value:  takesValue(f),
+					values: c.values[f.Name],
+				})
+			}
+		}
+		states = append(states, state)
+	}
+	return states
+}
+
+// valueFlags returns the names of the flags taking a value but -db, the words that skip the next
+// one when looking for the command path.
+func valueFlags(states []*completionState) []string {
+	seen := map[string]bool{"db": true}
+	var names []string
+	for _, state := range states {
+		for _, f := range state.flags {
+			for _, name := range f.names {
+				if f.value && !seen[name] {
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// flagPatterns returns the case patterns of flags, "-db|--db", the flag package accepting both.
+func flagPatterns(names []string, sep string) string {
+	var patterns []string
+	for _, name := range names {
+		patterns = append(patterns, "-"+name, "--"+name)
+	}
+	return strings.Join(patterns, sep)
+}
+
+// commandPatterns returns the case patterns of the command paths, quoted.
+func commandPatterns(states []*completionState, sep string) string {
+	var patterns []string
+	for _, state := range states {
+		if state.path != "" {
+			patterns = append(patterns, shellQuote(state.path))
+		}
+	}
+	return strings.Join(patterns, sep)
+}
+
+func stateFlagNames(state *completionState) []string {
+	var names []string
+	for _, f := range state.flags {
+		for _, name := range f.names {
+			names = append(names, "-"+name)
+		}
+	}
+	return names
+}
+
+// shellQuote single-quotes s for bash and zsh.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+func writeBashCompletion(w io.Writer, states []*completionState) {
+	fmt.Fprint(w, `# bash completion for rpmdb, generated by "rpmdb completion bash"
+_rpmdb() {
+	local cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]}
+	local cmd= db= i word
+	for ((i = 1; i < COMP_CWORD; i++)); do
+		word=${COMP_WORDS[i]}
+		case $word in
+		-db | --db) db=${COMP_WORDS[i+1]}; ((i++)) ;;
+`)
+	fmt.Fprintf(w, "\t\t%s) ((i++)) ;;\n", flagPatterns(valueFlags(states), " | "))
+	fmt.Fprintf(w, `		-*) ;;
+		*)
+			case "${cmd:+$cmd }$word" in
+			%s) cmd="${cmd:+$cmd }$word" ;;
+			esac
+			;;
+		esac
+	done
+	case $cmd in
+`, commandPatterns(states, " | "))
+
+	reply := func(c completion, indent string) {
+		if len(c.words) > 0 {
+			fmt.Fprintf(w, "%sCOMPREPLY+=($(compgen -W %s -- \"$cur\"))\n", indent, shellQuote(strings.Join(c.words, " ")))
+		}
+		if c.packages {
+			fmt.Fprintf(w, "%sCOMPREPLY+=($(\"${COMP_WORDS[0]}\" __complete ${db:+-db \"$db\"} -- \"$cur\" 2>/dev/null))\n", indent)
+		}
+		if c.files {
+			fmt.Fprintf(w, "%scompopt -o filenames 2>/dev/null\n", indent)
+			fmt.Fprintf(w, "%sCOMPREPLY+=($(compgen -f -- \"$cur\"))\n", indent)
+		}
+	}
+	for _, state := range states {
+		fmt.Fprintf(w, "\t%s)\n", shellQuote(state.path))
+		if len(state.flags) > 0 {
+			fmt.Fprint(w, "\t\tcase $prev in\n")
+			for _, f := range state.flags {
+				if !f.value {
+					continue
+				}
+				fmt.Fprintf(w, "\t\t%s)\n", flagPatterns(f.names, " | "))
+				reply(f.values, "\t\t\t")
+				fmt.Fprint(w, "\t\t\treturn\n\t\t\t;;\n")
+			}
+			fmt.Fprint(w, "\t\tesac\n")
+			fmt.Fprintf(w, "\t\tif [[ $cur == -* ]]; then\n\t\t\tCOMPREPLY=($(compgen -W %s -- \"$cur\"))\n\t\t\treturn\n\t\tfi\n",
+				shellQuote(strings.Join(stateFlagNames(state), " ")))
+		}
+		reply(completion{words: state.words}, "\t\t")
+		reply(state.args, "\t\t")
+		fmt.Fprint(w, "\t\t;;\n")
+	}
+	fmt.Fprint(w, `	esac
+}
+complete -F _rpmdb rpmdb
+`)
+}
+
+func writeZshCompletion(w io.Writer, states []*completionState) {
+	fmt.Fprint(w, `#compdef rpmdb
+# zsh completion for rpmdb, generated by "rpmdb completion zsh"
+_rpmdb() {
+	local cur=${words[CURRENT]} prev=${words[CURRENT-1]}
+	local cmd= db= i word
+	local -a dbflag descriptions
+	for ((i = 2; i < CURRENT; i++)); do
+		word=${words[i]}
+		case $word in
+		(-db|--db) db=${words[i+1]}; ((i++)) ;;
+`)
+	fmt.Fprintf(w, "\t\t(%s) ((i++)) ;;\n", flagPatterns(valueFlags(states), "|"))
+	fmt.Fprintf(w, `		(-*) ;;
+		(*)
+			case "${cmd:+$cmd }$word" in
+			(%s) cmd="${cmd:+$cmd }$word" ;;
+			esac
+			;;
+		esac
+	done
+	[[ -n $db ]] && dbflag=(-db "$db")
+	case $cmd in
+`, commandPatterns(states, "|"))
+
+	add := func(c completion, indent string) {
+		if len(c.words) > 0 {
+			fmt.Fprintf(w, "%scompadd -- %s\n", indent, strings.Join(c.words, " "))
+		}
+		if c.packages {
+			fmt.Fprintf(w, "%scompadd -- ${(f)\"$(${words[1]} __complete $dbflag -- \"$cur\" 2>/dev/null)\"}\n", indent)
+		}
+		if c.files {
+			fmt.Fprintf(w, "%s_files\n", indent)
+		}
+	}
+	for _, state := range states {
+		fmt.Fprintf(w, "\t(%s)\n", shellQuote(state.path))
+		if len(state.flags) > 0 {
+			fmt.Fprint(w, "\t\tcase $prev in\n")
+			for _, f := range state.flags {
+				if !f.value {
+					continue
+				}
+				fmt.Fprintf(w, "\t\t(%s)\n", flagPatterns(f.names, "|"))
+				add(f.values, "\t\t\t")
+				fmt.Fprint(w, "\t\t\treturn\n\t\t\t;;\n")
+			}
+			fmt.Fprint(w, "\t\tesac\n")
+			fmt.Fprintf(w, "\t\tif [[ $cur == -* ]]; then\n\t\t\tcompadd -- %s\n\t\t\treturn\n\t\tfi\n",
+				strings.Join(stateFlagNames(state), " "))
+		}
+		if len(state.words) > 0 {
+			var descriptions []string
+			for _, word := range state.words {
+				descriptions = append(descriptions, shellQuote(word+":"+state.summaries[word]))
+			}
+			fmt.Fprintf(w, "\t\tdescriptions=(%s)\n\t\t_describe command descriptions\n", strings.Join(descriptions, " "))
+		}
+		add(state.args, "\t\t")
+		fmt.Fprint(w, "\t\t;;\n")
+	}
+	fmt.Fprint(w, `	esac
+}
+
+if [[ $zsh_eval_context[-1] == loadautofunc ]]; then
+	_rpmdb "$@"
+else
+	compdef _rpmdb rpmdb
+fi
+`)
+}
+
+// fishQuote single-quotes s for fish, which escapes backslashes and quotes inside the quotes.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+func writeFishCompletion(w io.Writer, states []*completionState) {
+	patterns := []string{"-db", "--db"}
+	for _, name := range valueFlags(states) {
+		patterns = append(patterns, "-"+name, "--"+name)
+	}
+	var paths []string
+	for _, state := range states {
+		if state.path != "" {
+			paths = append(paths, fishQuote(state.path))
+		}
+	}
+	fmt.Fprintf(w, `# fish completion for rpmdb, generated by "rpmdb completion fish"
+function __rpmdb_command
+	set -l words (commandline -opc)
+	set -l cmd ''
+	set -e words[1]
+	while set -q words[1]
+		switch $words[1]
+		case %s
+			set -e words[1]
+		case '-*'
+		case '*'
+			set -l next (string trim -- "$cmd $words[1]")
+			if contains -- $next %s
+				set cmd $next
+			end
+		end
+		set -e words[1]
+	end
+	echo $cmd
+end
+
+function __rpmdb_using
+	set -l cmd (__rpmdb_command)
+	test "$cmd" = "$argv[1]"
+end
+
+function __rpmdb_packages
+	set -l words (commandline -opc)
+	set -l db
+	for i in (seq 2 (count $words))
+		if contains -- $words[$i] -db --db
+			set db $words[(math $i + 1)]
+		end
+	end
+	if test -n "$db"
+		$words[1] __complete -db $db -- (commandline -ct) 2>/dev/null
+	else
+		$words[1] __complete -- (commandline -ct) 2>/dev/null
+	end
+end
+
+complete -c rpmdb -f
+`, strings.Join(patterns, " "), strings.Join(paths, " "))
+
+	for _, state := range states {
+		cond := "complete -c rpmdb -n " + fishQuote("__rpmdb_using "+fishQuote(state.path))
+		for _, f := range state.flags {
+			line := cond
+			for _, name := range f.names {
+				line += " -o " + name
+			}
+			switch {
+			case !f.value:
+			case f.values.files:
+				line += " -r -F"
+			case len(f.values.words) > 0:
+				line += " -x -a " + fishQuote(strings.Join(f.values.words, " "))
+			case f.values.packages:
+				line += " -x -a '(__rpmdb_packages)'"
+			default:
+				line += " -x"
+			}
+			fmt.Fprintf(w, "%s -d %s\n", line, fishQuote(f.usage))
+		}
+		for _, word := range state.words {
+			fmt.Fprintf(w, "%s -a %s -d %s\n", cond, fishQuote(word), fishQuote(state.summaries[word]))
+		}
+		if len(state.args.words) > 0 {
+			fmt.Fprintf(w, "%s -a %s\n", cond, fishQuote(strings.Join(state.args.words, " ")))
+		}
+		if state.args.packages {
+			fmt.Fprintf(w, "%s -a '(__rpmdb_packages)' -d package\n", cond)
+		}
+		if state.args.files {
+			fmt.Fprintf(w, "%s -F\n", cond)
+		}
+	}
+}
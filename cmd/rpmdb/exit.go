@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	rpmdb "github.com/chennqqi/go-rpmdb/pkg"
+)
+
+// The exit codes of the tool. They are stable: scripts and CI gates tell the failures apart with
+// them.
+const (
+	exitOK = 0
+	// exitUsage is an invalid command line, and the failures having no code of their own, writing
+	// the output for instance.
+	exitUsage = 1
+	// exitNotFound is a database, or an input file, that doesn't exist.
+	exitNotFound = 2
+	// exitCorrupt is a database, or a header, that can't be read.
+	exitCorrupt = 3
+	// exitVerification is a database read fine whose packages fail the checks of rpmdb verify.
+	exitVerification = 4
+)
+
+// the kinds of the JSON errors of -quiet and their man page description, by exit code
+var exitKinds = map[int]struct{ kind, description string }{
+	exitUsage:        {"usage", "invalid command line, or a failure having no code of its own"},
+	exitNotFound:     {"db-not-found", "no database, or no input file, at the path given"},
+	exitCorrupt:      {"corrupt-db", "the database, or the header, can't be read"},
+	exitVerification: {"verification-failed", "packages failed the checks of rpmdb verify"},
+}
+
+// quiet is set by the -quiet flag of every command: the errors are written as a JSON object.
+var quiet bool
+
+// exitError is an error and the exit code it ends the tool with.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// openDB opens the database at path, failing with exitNotFound when there is none and with
+// exitCorrupt when it can't be read.
+func openDB(path string, opts ...rpmdb.Option) (*rpmdb.RpmDB, error) {
+	if err := checkInput(path); err != nil {
+		return nil, err
+	}
+	db, err := rpmdb.Open(path, opts...)
+	if err != nil {
+		return nil, &exitError{exitCorrupt, fmt.Errorf("%s: %w", path, err)}
+	}
+	return db, nil
+}
+
+// checkInput fails with exitNotFound when path isn't a file.
+func checkInput(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return &exitError{exitNotFound, err}
+	}
+	if info.IsDir() {
+		return &exitError{exitNotFound, fmt.Errorf("%s: is a directory", path)}
+	}
+	return nil
+}
+
+// corrupt fails with exitCorrupt, err coming from reading a database.
+func corrupt(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{exitCorrupt, err}
+}
+
+// errorReport is the JSON error of -quiet, {"code":2,"kind":"db-not-found","message":"..."}.
+type errorReport struct {
+	Code    int    `json:"code"`
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// exit ends the tool with the exit code of err, reporting it on the standard error.
+func exit(err error) {
+	if err == nil {
+		os.Exit(exitOK)
+	}
+	code := exitUsage
+	var e *exitError
+	if errors.As(err, &e) {
+		code = e.code
+	}
+	if quiet {
+		encoder := json.NewEncoder(os.Stderr)
+		encoder.SetEscapeHTML(false)
+		encoder.Encode(errorReport{Code: code, Kind: exitKinds[code].kind, Message: err.Error()})
+	} else {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	os.Exit(code)
+}
+
+// exitCodes returns the exit codes of failures, sorted.
+func exitCodes() []int {
+	codes := make([]int, 0, len(exitKinds))
+	for code := range exitKinds {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	return codes
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"time"
+
+	rpmdb "github.com/chennqqi/go-rpmdb/pkg"
+)
+
+// headerDumpCommand runs "rpmdb header dump <file>", printing a header blob extracted from a
+// database, a memory image or a .rpm file. "-" reads the blob from the standard input. The times
+// are printed in UTC, or in the -tz zone: "Local" or a name of the tz database.
+func headerDumpCommand() *command {
+	c := &command{
+		path:    []string{"header", "dump"},
+		args:    "<file>",
+		summary: "print a header blob",
+		description: "Prints the tags of a header blob: an rpmdb value, a header with its magic, a memory image " +
+			"or a .rpm file, \"-\" reading the standard input.",
+		flags:    newFlagSet([]string{"header", "dump"}),
+		complete: completion{files: true},
+	}
+	zone := c.flags.String("tz", "UTC", "time `zone` of the times, Local or a name of the tz database")
+
+	c.run = func(args []string) error {
+		if len(args) != 1 {
+			return c.usage()
+		}
+		loc, err := time.LoadLocation(*zone)
+		if err != nil {
+			return &exitError{exitUsage, err}
+		}
+
+		var blob []byte
+		if args[0] == "-" {
+			blob, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			blob, err = ioutil.ReadFile(args[0])
+		}
+		if err != nil {
+			return &exitError{exitNotFound, err}
+		}
+		return corrupt(rpmdb.DumpHeaderIn(blob, os.Stdout, loc))
+	}
+	return c
+}
@@ -1,25 +1,152 @@
 package main
 
 import (
+	"bufio"
+	"flag"
 	"fmt"
-	"log"
+	"os"
+	"strings"
 
 	rpmdb "github.com/chennqqi/go-rpmdb/pkg"
 )
 
+// commands are the commands of the tool, set by main: the completions and the man page are
+// generated from them.
+var commands []*command
+
 func main() {
-	db, err := rpmdb.Open("./Packages")
+	commands = []*command{
+		listCommand(),
+		headerDumpCommand(),
+		verifyCommand(),
+		checkCommand(),
+		salvageCommand(),
+		carveCommand(),
+		completionCommand(),
+		completePackagesCommand(),
+		manCommand(),
+	}
+
+	cmd, args, err := findCommand(commands, os.Args[1:])
 	if err != nil {
-		log.Fatal(err)
+		// the command, and its -quiet flag, are unknown
+		for _, arg := range os.Args[1:] {
+			quiet = quiet || arg == "-quiet" || arg == "--quiet"
+		}
+		exit(err)
+	}
+	if err := cmd.flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			fmt.Fprintln(os.Stderr, cmd.usage())
+			cmd.flags.SetOutput(os.Stderr)
+			cmd.flags.PrintDefaults()
+			exit(nil)
+		}
+		exit(&exitError{exitUsage, fmt.Errorf("%v\n%v", err, cmd.usage())})
+	}
+	exit(cmd.run(cmd.flags.Args()))
+}
+
+// listCommand runs "rpmdb [name...]", listing the packages of the database, the ones of the given
+// names only if any, in the format -output selects.
+func listCommand() *command {
+	c := &command{
+		args:    "[name...]",
+		summary: "list the packages of an rpm database",
+		description: "Lists the packages of the database, Packages, rpmdb.sqlite or Packages.db, or the ones of the " +
+			"given names, as a table or in one of the output formats. The formats registered in the library with " +
+			"rpmdb.RegisterFormat are available as well. With -cache, the packages are read from a snapshot while " +
+			"the database is unchanged, and saved to it otherwise. With -watch, a line is printed for every package rpm " +
+			"installs, erases or replaces next: \"<time> upgraded bash.x86_64 4.2.46-31.el7 4.2.46-34.el7\".",
+		flags:    newFlagSet(nil),
+		aliases:  map[string]string{"o": "output"},
+		complete: completion{packages: true},
+	}
+	dbPath := c.flags.String("db", "./Packages", "`path` of the rpm database")
+	output := c.flags.String("output", "table", "output `format`: "+strings.Join(outputNames(), ", "))
+	c.flags.StringVar(output, "o", "table", "shorthand for -output")
+	text := c.flags.String("template", "", "text/template of each package, `text` like '{{.Name}} {{.EVR}}', implies -output template")
+	cache := c.flags.String("cache", "", "`path` of a snapshot of the packages, reused while the database is unchanged")
+	watch := c.flags.Bool("watch", false, "list the packages, then the changes rpm makes to the database until interrupted")
+	c.values = map[string]completion{
+		"db":     {files: true},
+		"output": {words: outputNames()},
+		"cache":  {files: true},
+	}
+
+	c.run = func(args []string) error {
+		if *text != "" && *output == "table" {
+			*output = "template"
+		}
+		driver, err := newOutputDriver(*output, *text)
+		if err != nil {
+			return err
+		}
+		if *watch {
+			if err := checkInput(*dbPath); err != nil {
+				return err
+			}
+			return watchPackages(*dbPath, driver, args)
+		}
+		db, err := openDB(*dbPath)
+		if err != nil {
+			return err
+		}
+		pkgList, err := listPackages(db, *cache)
+		if err != nil {
+			return err
+		}
+		return writeOutput(driver, selectPackages(pkgList, args))
+	}
+	return c
+}
+
+// listPackages lists the packages of db, from the snapshot at cache when given and the database
+// didn't change since it was saved. A missing, stale or unreadable snapshot is saved again.
+func listPackages(db *rpmdb.RpmDB, cache string) ([]*rpmdb.PackageInfo, error) {
+	if cache == "" {
+		pkgList, err := db.ListPackages()
+		return pkgList, corrupt(err)
+	}
+	if pkgList, err := db.LoadSnapshot(cache); err == nil {
+		return pkgList, nil
 	}
 	pkgList, err := db.ListPackages()
 	if err != nil {
-		log.Fatal(err)
+		return nil, corrupt(err)
 	}
+	if err := db.SaveSnapshot(cache); err != nil {
+		return nil, fmt.Errorf("saving the snapshot: %w", err)
+	}
+	return pkgList, nil
+}
 
-	fmt.Println("Packages:")
-	for _, pkg := range pkgList {
-		fmt.Printf("\t%+v\n", *pkg)
+// selectPackages returns the packages of the given names, every package when there are none.
+func selectPackages(pkgs []*rpmdb.PackageInfo, names []string) []*rpmdb.PackageInfo {
+	if len(names) == 0 {
+		return pkgs
+	}
+	selected := make(map[string]bool, len(names))
+	for _, name := range names {
+		selected[name] = true
+	}
+	var pkgList []*rpmdb.PackageInfo
+	for _, pkg := range pkgs {
+		if selected[pkg.Name] {
+			pkgList = append(pkgList, pkg)
+		}
+	}
+	return pkgList
+}
+
+// writeOutput writes the packages to the standard output with driver.
+func writeOutput(driver outputDriver, pkgs []*rpmdb.PackageInfo) error {
+	w := bufio.NewWriter(os.Stdout)
+	if err := driver.write(w, pkgs); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("writing the output: %w", err)
 	}
-	fmt.Printf("[Total Packages: %d]\n", len(pkgList))
+	return nil
 }
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testDB is the database the tests list, the one of the tests of the package.
+const testDB = "../../pkg/testdata/centos7-plain/Packages"
+
+// TestMain runs the tool instead of the tests when runTool starts the test binary again, so that
+// its exit codes and standard error can be checked.
+func TestMain(m *testing.M) {
+	if os.Getenv("RPMDB_TEST_RUN_TOOL") == "1" {
+		os.Args = append([]string{"rpmdb"}, os.Args[1:]...)
+		main()
+	}
+	os.Exit(m.Run())
+}
+
+// runTool runs "rpmdb args...", returning its exit code and standard error.
+func runTool(t *testing.T, args ...string) (int, string) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "RPMDB_TEST_RUN_TOOL=1")
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if e, ok := err.(*exec.ExitError); ok {
+		return e.ExitCode(), stderr.String()
+	}
+	if err != nil {
+		t.Fatalf("running rpmdb %v: %v", args, err)
+	}
+	return exitOK, stderr.String()
+}
+
+func TestFindCommand(t *testing.T) {
+	commands := []*command{
+		listCommand(),
+		headerDumpCommand(),
+		verifyCommand(),
+		checkCommand(),
+	}
+	tests := []struct {
+		args     []string
+		wantPath []string
+		wantArgs []string
+		wantErr  bool
+	}{
+		{args: nil, wantPath: nil, wantArgs: nil},
+		{args: []string{"-db", "Packages", "bash"}, wantPath: nil, wantArgs: []string{"-db", "Packages", "bash"}},
+		{args: []string{"verify", "-db", "Packages"}, wantPath: []string{"verify"}, wantArgs: []string{"-db", "Packages"}},
+		{args: []string{"header", "dump", "bash.hdr"}, wantPath: []string{"header", "dump"}, wantArgs: []string{"bash.hdr"}},
+		// a group of commands, and a package named like one
+		{args: []string{"header"}, wantErr: true},
+		{args: []string{"dump"}, wantPath: nil, wantArgs: []string{"dump"}},
+	}
+	for _, tt := range tests {
+		cmd, args, err := findCommand(commands, tt.args)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("findCommand(%q): got %v, want an error", tt.args, cmd.path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("findCommand(%q) error: %v", tt.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(cmd.path, tt.wantPath) || !reflect.DeepEqual(args, tt.wantArgs) {
+			t.Errorf("findCommand(%q): got %q %q, want %q %q", tt.args, cmd.path, args, tt.wantPath, tt.wantArgs)
+		}
+	}
+}
+
+func TestFlags(t *testing.T) {
+	tests := []struct {
+		args       []string
+		wantOutput string
+		wantArgs   []string
+		wantErr    bool
+	}{
+		{args: nil, wantOutput: "table"},
+		{args: []string{"-output", "json", "bash"}, wantOutput: "json", wantArgs: []string{"bash"}},
+		{args: []string{"-o", "csv"}, wantOutput: "csv"},
+		{args: []string{"--o=jsonl", "-quiet", "bash", "zlib"}, wantOutput: "jsonl", wantArgs: []string{"bash", "zlib"}},
+		{args: []string{"-unknown"}, wantErr: true},
+		{args: []string{"-cache"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		c := listCommand()
+		err := c.flags.Parse(tt.args)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Parse(%q): got no error", tt.args)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q) error: %v", tt.args, err)
+			continue
+		}
+		if output := c.flags.Lookup("output").Value.String(); output != tt.wantOutput {
+			t.Errorf("Parse(%q): got -output %q, want %q", tt.args, output, tt.wantOutput)
+		}
+		if args := c.flags.Args(); len(args) != len(tt.wantArgs) || (len(args) > 0 && !reflect.DeepEqual(args, tt.wantArgs)) {
+			t.Errorf("Parse(%q): got arguments %q, want %q", tt.args, args, tt.wantArgs)
+		}
+	}
+	quiet = false
+}
+
+func TestQuietErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmdb")
+	if err != nil {
+		t.Fatalf("TempDir() error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	garbage := filepath.Join(dir, "Packages")
+	if err := ioutil.WriteFile(garbage, []byte(strings.Repeat("garbage ", 1024)), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	tests := []struct {
+		args     []string
+		wantCode int
+		wantKind string
+	}{
+		{args: []string{"-quiet", "-db", filepath.Join(dir, "missing")}, wantCode: exitNotFound, wantKind: "db-not-found"},
+		{args: []string{"-quiet", "-db", dir}, wantCode: exitNotFound, wantKind: "db-not-found"},
+		{args: []string{"-quiet", "-db", garbage}, wantCode: exitCorrupt, wantKind: "corrupt-db"},
+		{args: []string{"-quiet", "-unknown"}, wantCode: exitUsage, wantKind: "usage"},
+		// the command is unknown, its -quiet flag is honored regardless
+		{args: []string{"header", "-quiet"}, wantCode: exitUsage, wantKind: "usage"},
+		{args: []string{"verify", "--quiet", "-db", filepath.Join(dir, "missing")}, wantCode: exitNotFound, wantKind: "db-not-found"},
+		{args: []string{"verify", "-quiet", "-db", testDB, "-keyring", filepath.Join(dir, "missing")}, wantCode: exitNotFound, wantKind: "db-not-found"},
+		{args: []string{"verify", "-quiet", "-db", testDB, "-keyring", garbage}, wantCode: exitUsage, wantKind: "usage"},
+	}
+	for _, tt := range tests {
+		code, stderr := runTool(t, tt.args...)
+		var report errorReport
+		if err := json.Unmarshal([]byte(stderr), &report); err != nil {
+			t.Errorf("rpmdb %q: got %q, want a JSON error: %v", tt.args, stderr, err)
+			continue
+		}
+		if code != tt.wantCode || report.Code != tt.wantCode || report.Kind != tt.wantKind || report.Message == "" {
+			t.Errorf("rpmdb %q: got exit code %d, %+v, want %d %q", tt.args, code, report, tt.wantCode, tt.wantKind)
+		}
+		if strings.Count(stderr, "\n") != 1 {
+			t.Errorf("rpmdb %q: got %q, want a single line", tt.args, stderr)
+		}
+	}
+
+	// without -quiet, the message is written as is
+	code, stderr := runTool(t, "-db", filepath.Join(dir, "missing"))
+	if code != exitNotFound || strings.HasPrefix(stderr, "{") || !strings.Contains(stderr, "missing") {
+		t.Errorf("rpmdb -db missing: got exit code %d, %q", code, stderr)
+	}
+	if code, stderr := runTool(t, "-quiet", "-db", testDB, "-o", "json", "bash"); code != exitOK || stderr != "" {
+		t.Errorf("rpmdb -quiet -db %s: got exit code %d, %q", testDB, code, stderr)
+	}
+}
+
+func TestListPackagesCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmdb")
+	if err != nil {
+		t.Fatalf("TempDir() error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	cache := filepath.Join(dir, "snapshot")
+
+	db, err := openDB(testDB)
+	if err != nil {
+		t.Fatalf("openDB() error: %v", err)
+	}
+	defer db.Close()
+	want, err := listPackages(db, "")
+	if err != nil {
+		t.Fatalf("listPackages() error: %v", err)
+	}
+
+	// the snapshot is missing, then saved, then stale
+	for i := 0; i < 3; i++ {
+		if i == 2 {
+			if err := ioutil.WriteFile(cache, []byte("stale"), 0644); err != nil {
+				t.Fatalf("WriteFile() error: %v", err)
+			}
+		}
+		pkgList, err := listPackages(db, cache)
+		if err != nil {
+			t.Fatalf("listPackages() with a cache, run %d, error: %v", i, err)
+		}
+		if len(pkgList) != len(want) {
+			t.Fatalf("listPackages() with a cache, run %d: got %d packages, want %d", i, len(pkgList), len(want))
+		}
+		for j := range want {
+			if pkgList[j].NEVRA() != want[j].NEVRA() {
+				t.Errorf("listPackages() with a cache, run %d: got %s, want %s", i, pkgList[j].NEVRA(), want[j].NEVRA())
+			}
+		}
+		if _, err := db.LoadSnapshot(cache); err != nil {
+			t.Errorf("LoadSnapshot() after run %d error: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// manCommand runs "rpmdb man", printing the rpmdb(1) man page, "rpmdb man > rpmdb.1".
+func manCommand() *command {
+	c := &command{
+		path:        []string{"man"},
+		summary:     "print the man page",
+		description: "Prints the rpmdb(1) man page in roff: rpmdb man > /usr/local/share/man/man1/rpmdb.1.",
+		flags:       newFlagSet([]string{"man"}),
+	}
+	c.run = func(args []string) error {
+		if len(args) != 0 {
+			return c.usage()
+		}
+		w := bufio.NewWriter(os.Stdout)
+		writeManPage(w, commands)
+		return w.Flush()
+	}
+	return c
+}
+
+// writeManPage writes the man page of the commands, documenting their flags. It has no date so
+// that it doesn't change from a build to the next.
+func writeManPage(w io.Writer, commands []*command) {
+	var visible []*command
+	var root *command
+	for _, c := range commands {
+		if c.hidden {
+			continue
+		}
+		visible = append(visible, c)
+		if len(c.path) == 0 {
+			root = c
+		}
+	}
+
+	fmt.Fprint(w, ".TH RPMDB 1 \"\" \"go-rpmdb\" \"User Commands\"\n")
+	fmt.Fprint(w, ".SH NAME\n")
+	fmt.Fprintf(w, "rpmdb \\- %s\n", roffEscape(root.summary))
+	fmt.Fprint(w, ".SH SYNOPSIS\n")
+	for i, c := range visible {
+		if i > 0 {
+			fmt.Fprint(w, ".br\n")
+		}
+		writeManSynopsis(w, c)
+	}
+
+	fmt.Fprint(w, ".SH DESCRIPTION\n")
+	fmt.Fprintf(w, "%s\n", roffEscape(manDescription(root)))
+	writeManFlags(w, root)
+
+	fmt.Fprint(w, ".SH COMMANDS\n")
+	for _, c := range visible {
+		if c == root {
+			continue
+		}
+		fmt.Fprintf(w, ".SS %s\n", roffEscape(c.name()))
+		fmt.Fprintf(w, "%s\n", roffEscape(manDescription(c)))
+		writeManFlags(w, c)
+	}
+
+	fmt.Fprint(w, ".SH EXIT STATUS\n")
+	fmt.Fprintf(w, ".TP\n%d\nsuccess\n", exitOK)
+	for _, code := range exitCodes() {
+		fmt.Fprintf(w, ".TP\n%d\n%s: %s\n", code, roffEscape(exitKinds[code].kind), roffEscape(exitKinds[code].description))
+	}
+	fmt.Fprint(w, ".PP\nWith \\fB\\-quiet\\fR, the error is written on the standard error as a JSON object: ")
+	fmt.Fprint(w, `{"code":2,"kind":"db\-not\-found","message":"..."}.`+"\n")
+	fmt.Fprint(w, ".SH SEE ALSO\n")
+	fmt.Fprint(w, ".BR rpm (8)\n")
+}
+
+func manDescription(c *command) string {
+	if c.description != "" {
+		return c.description
+	}
+	return strings.ToUpper(c.summary[:1]) + c.summary[1:] + "."
+}
+
+// writeManSynopsis writes "\fBrpmdb header dump\fR [\fB\-tz\fR \fIzone\fR] \fI<file>\fR".
+func writeManSynopsis(w io.Writer, c *command) {
+	words := []string{`\fB` + roffEscape(c.name()) + `\fR`}
+	for _, f := range c.flagList() {
+		words = append(words, "["+manFlag(f, f.Name)+"]")
+	}
+	if c.args != "" {
+		words = append(words, `\fI`+roffEscape(c.args)+`\fR`)
+	}
+	fmt.Fprintf(w, "%s\n", strings.Join(words, " "))
+}
+
+func writeManFlags(w io.Writer, c *command) {
+	for _, f := range c.flagList() {
+		var names []string
+		for _, name := range c.flagNames(f) {
+			names = append(names, manFlag(f, name))
+		}
+		_, usage := flag.UnquoteUsage(f)
+		if f.DefValue != "" && takesValue(f) {
+			usage += ", " + f.DefValue + " by default"
+		}
+		fmt.Fprintf(w, ".TP\n%s\n%s\n", strings.Join(names, ", "), roffEscape(usage))
+	}
+}
+
+// manFlag returns "\fB\-tz\fR \fIzone\fR".
+func manFlag(f *flag.Flag, name string) string {
+	s := `\fB\-` + roffEscape(name) + `\fR`
+	if value, _ := flag.UnquoteUsage(f); value != "" {
+		s += ` \fI` + roffEscape(value) + `\fR`
+	}
+	return s
+}
+
+// roffEscape escapes the backslashes and hyphens of text, and the dots and quotes starting its
+// lines, which roff takes for requests.
+func roffEscape(text string) string {
+	text = strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(text)
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
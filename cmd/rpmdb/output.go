@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	rpmdb "github.com/chennqqi/go-rpmdb/pkg"
+)
+
+// outputDriver writes the package listing in the format -output selects.
+type outputDriver interface {
+	write(w io.Writer, pkgs []*rpmdb.PackageInfo) error
+}
+
+// formatDriver writes a format registered in the library with rpmdb.RegisterFormat.
+type formatDriver rpmdb.FormatWriter
+
+func (f formatDriver) write(w io.Writer, pkgs []*rpmdb.PackageInfo) error {
+	return f(w, pkgs)
+}
+
+// tableDriver writes a column per NEVRA field and the vendor, for people to read.
+type tableDriver struct{}
+
+func (tableDriver) write(w io.Writer, pkgs []*rpmdb.PackageInfo) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tEPOCH\tVERSION\tRELEASE\tARCH\tVENDOR")
+	for _, pkg := range pkgs {
+		epoch := ""
+		if pkg.Epoch != 0 {
+			epoch = strconv.Itoa(pkg.Epoch)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", pkg.Name, epoch, pkg.Version, pkg.Release, pkg.Arch, pkg.Vendor)
+	}
+	return tw.Flush()
+}
+
+// templateDriver executes a text/template per package, like the --queryformat of rpm: its dot is
+// the *rpmdb.PackageInfo, "{{.Name}} {{.EVR}}". A newline ends each package.
+type templateDriver struct {
+	tmpl *template.Template
+}
+
+func (d templateDriver) write(w io.Writer, pkgs []*rpmdb.PackageInfo) error {
+	for _, pkg := range pkgs {
+		if err := d.tmpl.Execute(w, pkg); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// outputNames returns the sorted names -output accepts: the drivers of the command and the
+// formats of the library.
+func outputNames() []string {
+	names := append([]string{"table", "template"}, rpmdb.Formats()...)
+	sort.Strings(names)
+	return names
+}
+
+// newOutputDriver returns the driver of the output named name, text being the template of the
+// template output.
+func newOutputDriver(name, text string) (outputDriver, error) {
+	switch name {
+	case "table":
+		return tableDriver{}, nil
+	case "template":
+		if text == "" {
+			return nil, errors.New("the template output needs -template")
+		}
+		tmpl, err := template.New("package").Parse(text)
+		if err != nil {
+			return nil, err
+		}
+		return templateDriver{tmpl: tmpl}, nil
+	}
+	if write, ok := rpmdb.LookupFormat(name); ok {
+		return formatDriver(write), nil
+	}
+	return nil, fmt.Errorf("unknown output %q, one of %s", name, strings.Join(outputNames(), ", "))
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+// salvageCommand runs "rpmdb salvage", listing the packages of a damaged database with what could
+// be recovered of the headers that don't decode. Damaged headers exit with exitCorrupt.
+func salvageCommand() *command {
+	c := &command{
+		path:    []string{"salvage"},
+		summary: "recover the packages of a damaged database",
+		description: "Lists the instance number and NEVRA of every header of the database, the damaged ones " +
+			"included: a header truncated or corrupt gives the fields of the entries it still holds, with " +
+			"the count of entries recovered and the reason. The tool exits with the code of a corrupt database " +
+			"when any header is damaged.",
+		flags: newFlagSet([]string{"salvage"}),
+	}
+	dbPath := c.flags.String("db", "./Packages", "`path` of the rpm database")
+	c.values = map[string]completion{
+		"db": {files: true},
+	}
+
+	c.run = func(args []string) error {
+		if len(args) != 0 {
+			return c.usage()
+		}
+		db, err := openDB(*dbPath)
+		if err != nil {
+			return err
+		}
+		salvaged, readErr := db.SalvagePackages()
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		damaged := 0
+		for _, s := range salvaged {
+			nevra := "?"
+			if s.Package.Name != "" {
+				nevra = s.Package.NEVRA()
+			}
+			status := "ok"
+			if s.Partial {
+				damaged++
+				status = fmt.Sprintf("partial, %d of %d entries: %v", s.Recovered, s.Entries, s.Err)
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\n", s.HNum, nevra, status)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		switch {
+		case readErr != nil:
+			return corrupt(fmt.Errorf("%s: %w", *dbPath, readErr))
+		case damaged > 0:
+			return corrupt(fmt.Errorf("%d of %d headers damaged", damaged, len(salvaged)))
+		}
+		return nil
+	}
+	return c
+}
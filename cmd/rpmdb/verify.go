@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	rpmdb "github.com/chennqqi/go-rpmdb/pkg"
+)
+
+var trustLevels = []rpmdb.TrustLevel{rpmdb.TrustNone, rpmdb.TrustRegion, rpmdb.TrustDigest, rpmdb.TrustSignature}
+
+// verifyCommand runs "rpmdb verify [name...]", checking the headers of the packages, the ones of
+// the given names only if any, reach the -min trust level. The packages below it are printed with
+// the reason, and the tool exits with exitVerification.
+func verifyCommand() *command {
+	var levels []string
+	for _, level := range trustLevels {
+		levels = append(levels, level.String())
+	}
+	c := &command{
+		path:    []string{"verify"},
+		args:    "[name...]",
+		summary: "check the integrity of the package headers",
+		description: "Checks the headers of the packages, or of the ones of the given names, reach the trust level " +
+			"-min names: none, region for an immutable region, digest for a region matching its digests, " +
+			"signature for a header whose signature verifies with a key imported in the database, or with the " +
+			"keys of -keyring or -distro-keys when given. The packages below it are printed with the reason.",
+		flags:    newFlagSet([]string{"verify"}),
+		complete: completion{packages: true},
+	}
+	dbPath := c.flags.String("db", "./Packages", "`path` of the rpm database")
+	min := c.flags.String("min", rpmdb.TrustSignature.String(), "trust `level` required: "+strings.Join(levels, ", "))
+	keyring := c.flags.String("keyring", "", "`path` of a key file, or of a directory of them like /etc/pki/rpm-gpg, checking the signatures")
+	distroKeys := c.flags.Bool("distro-keys", false, "check the signatures with the bundled signing keys of the distributions")
+	c.values = map[string]completion{
+		"db":      {files: true},
+		"min":     {words: levels},
+		"keyring": {files: true},
+	}
+
+	c.run = func(args []string) error {
+		required := -1
+		for _, level := range trustLevels {
+			if level.String() == *min {
+				required = int(level)
+			}
+		}
+		if required < 0 {
+			return &exitError{exitUsage, fmt.Errorf("unknown trust level %q, one of %s", *min, strings.Join(levels, ", "))}
+		}
+
+		var opts []rpmdb.Option
+		switch {
+		case *keyring != "" && *distroKeys:
+			return &exitError{exitUsage, errors.New("-keyring and -distro-keys are exclusive")}
+		case *keyring != "":
+			// not checkInput: a keyring may be a directory of keys
+			if _, err := os.Stat(*keyring); err != nil {
+				return &exitError{exitNotFound, err}
+			}
+			k, err := rpmdb.LoadKeyring(*keyring)
+			if err != nil {
+				return &exitError{exitUsage, err}
+			}
+			opts = append(opts, rpmdb.WithKeyring(k))
+		case *distroKeys:
+			k, err := rpmdb.DistroKeyring()
+			if err != nil {
+				return err
+			}
+			opts = append(opts, rpmdb.WithKeyring(k))
+		}
+
+		db, err := openDB(*dbPath, opts...)
+		if err != nil {
+			return err
+		}
+		trusts, err := db.Trust()
+		if err != nil {
+			return corrupt(err)
+		}
+		names := make(map[string]bool, len(args))
+		for _, name := range args {
+			names[name] = true
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		checked, failed := 0, 0
+		for _, trust := range trusts {
+			if len(names) > 0 && !names[trust.Package.Name] {
+				continue
+			}
+			checked++
+			if int(trust.Level) >= required {
+				continue
+			}
+			failed++
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", trust.Package.NEVRA(), trust.Level, trust.Reason)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		if failed > 0 {
+			return &exitError{exitVerification, fmt.Errorf("%d of %d packages below %s", failed, checked, *min)}
+		}
+		return nil
+	}
+	return c
+}
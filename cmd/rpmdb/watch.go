@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	rpmdb "github.com/chennqqi/go-rpmdb/pkg"
+)
+
+// watchPackages lists the packages of the given names of the database at path with driver, then
+// prints a line per event of the changes rpm makes to them until interrupted, with the EVR before
+// and after:
+//
+//	2023-03-01T10:00:00Z installed nano.x86_64 2.3.1-10.el7
+//	2023-03-01T10:05:00Z upgraded bash.x86_64 4.2.46-31.el7 4.2.46-34.el7
+//	2023-03-01T10:07:00Z removed nano.x86_64 2.3.1-10.el7
+func watchPackages(path string, driver outputDriver, names []string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// a SIGTERM, out of reach without syscall, ends the tool with its default action
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	go func() {
+		<-signals
+		cancel()
+	}()
+
+	first := true
+	opts := &rpmdb.WatchOptions{
+		OnError: func(err error) {
+			if !quiet {
+				fmt.Fprintf(os.Stderr, "rescan failed: %v\n", err)
+			}
+		},
+	}
+	err := rpmdb.WatchFunc(ctx, path, opts, func(delta *rpmdb.PackageDelta) error {
+		if first {
+			first = false
+			return writeOutput(driver, selectPackages(delta.Added, names))
+		}
+		selected := make(map[string]bool, len(names))
+		for _, name := range names {
+			selected[name] = true
+		}
+		for _, event := range delta.Events() {
+			if len(selected) > 0 && !selected[event.Name] {
+				continue
+			}
+			line := fmt.Sprintf("%s %s %s", event.Time.Format(time.RFC3339), event.Type, event.Name)
+			if event.Arch != "" {
+				line += "." + event.Arch
+			}
+			for _, evr := range []string{event.Before, event.After} {
+				if evr != "" {
+					line += " " + evr
+				}
+			}
+			fmt.Println(line)
+		}
+		return nil
+	})
+	switch {
+	case first:
+		return corrupt(err)
+	case err == context.Canceled:
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,196 @@
+// Command agent is an example host inventory agent: it snapshots the rpm database on a timer as a
+// package manifest and POSTs what changed since the last snapshot the endpoint acknowledged, as
+// newline delimited JSON, retrying with exponential backoff.
+//
+//	agent -endpoint https://inventory.example.com/v1/deltas -interval 15m -state /var/lib/agent/manifest
+//
+// The first snapshot reports every package as added. A failed upload is not lost, the next
+// snapshot is diffed against the same acknowledged manifest.
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	rpmdb "github.com/chennqqi/go-rpmdb/pkg"
+)
+
+// Delta is a line of the upload.
+type Delta struct {
+	Host   string    `json:"host"`
+	Time   time.Time `json:"time"`
+	Change string    `json:"change"`
+	NEVRA  string    `json:"nevra"`
+	Digest string    `json:"digest"`
+	// Previous is the NEVRA a changed package replaced.
+	Previous string `json:"previous,omitempty"`
+}
+
+type agent struct {
+	dbPath     string
+	endpoint   string
+	host       string
+	statePath  string
+	maxRetries int
+	client     *http.Client
+	// the last manifest the endpoint acknowledged
+	acked *rpmdb.PackageManifest
+}
+
+func main() {
+	a := &agent{client: &http.Client{Timeout: time.Minute}}
+	hostname, _ := os.Hostname()
+	flag.StringVar(&a.dbPath, "db", "/var/lib/rpm/Packages", "rpm database")
+	flag.StringVar(&a.endpoint, "endpoint", "", "URL the deltas are POSTed to")
+	flag.StringVar(&a.host, "host", hostname, "host name reported")
+	flag.StringVar(&a.statePath, "state", "", "file keeping the acknowledged manifest across restarts")
+	flag.IntVar(&a.maxRetries, "retries", 5, "upload attempts per snapshot")
+	interval := flag.Duration("interval", time.Hour, "time between snapshots")
+	flag.Parse()
+	if a.endpoint == "" {
+		log.Fatal("-endpoint is required")
+	}
+
+	if err := a.loadState(); err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	go func() {
+		<-signals
+		cancel()
+	}()
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		if err := a.snapshot(ctx); err != nil {
+			log.Printf("snapshot: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *agent) loadState() error {
+	a.acked = &rpmdb.PackageManifest{Algorithm: "sha256"}
+	if a.statePath == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(a.statePath)
+	if os.IsNotExist(err) || len(data) == 0 {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	acked, err := rpmdb.ParseManifest(data)
+	if err != nil {
+		return fmt.Errorf("invalid state %s: %w", a.statePath, err)
+	}
+	a.acked = acked
+	return nil
+}
+
+// snapshot uploads the delta of the database against the acknowledged manifest.
+func (a *agent) snapshot(ctx context.Context) error {
+	db, err := rpmdb.Open(a.dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	manifest, err := rpmdb.Manifest(db, crypto.SHA256)
+	if err != nil {
+		return err
+	}
+	diff := rpmdb.DiffManifests(a.acked, manifest)
+	if diff.Empty() {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, entry := range diff.Added {
+		encoder.Encode(Delta{Host: a.host, Time: now, Change: "added", NEVRA: entry.NEVRA, Digest: entry.Digest})
+	}
+	for _, entry := range diff.Removed {
+		encoder.Encode(Delta{Host: a.host, Time: now, Change: "removed", NEVRA: entry.NEVRA, Digest: entry.Digest})
+	}
+	for _, drift := range diff.Drifted {
+		encoder.Encode(Delta{Host: a.host, Time: now, Change: "changed", NEVRA: drift.Actual.NEVRA,
+			Digest: drift.Actual.Digest, Previous: drift.Expected.NEVRA})
+	}
+
+	if err := a.upload(ctx, body.Bytes()); err != nil {
+		return err
+	}
+	log.Printf("uploaded %d added, %d removed, %d changed", len(diff.Added), len(diff.Removed), len(diff.Drifted))
+
+	a.acked = manifest
+	if a.statePath != "" {
+		if err := ioutil.WriteFile(a.statePath, manifest.Bytes(), 0600); err != nil {
+			return fmt.Errorf("failed to save state: %w", err)
+		}
+	}
+	return nil
+}
+
+// upload POSTs the deltas, waiting 1s, 2s, 4s... up to a minute between the attempts.
+func (a *agent) upload(ctx context.Context, body []byte) error {
+	backoff := time.Second
+	var err error
+	for attempt := 0; attempt < a.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > time.Minute {
+				backoff = time.Minute
+			}
+		}
+		if err = a.post(ctx, body); err == nil {
+			return nil
+		}
+		log.Printf("upload attempt %d: %v", attempt+1, err)
+	}
+	return err
+}
+
+func (a *agent) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, a.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	ioutil.ReadAll(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
@@ -2,7 +2,4 @@ module github.com/chennqqi/go-rpmdb
 
 go 1.14
 
-require (
-	github.com/go-restruct/restruct v0.0.0-20191227155143-5734170a48a1
-	golang.org/x/xerrors v0.0.0-20191204190536-9bdfabe68543
-)
+require github.com/go-restruct/restruct v0.0.0-20191227155143-5734170a48a1
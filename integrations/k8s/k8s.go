@@ -0,0 +1,94 @@
+// Package k8s attributes the rpm packages of container images to the layers that installed them,
+// for admission controllers and cluster scanners. The images are fetched through a Puller, which
+// wraps containerd, the CRI image service or a registry client, so that this package depends on
+// none of them.
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	rpmdb "github.com/chennqqi/go-rpmdb/pkg"
+	"github.com/chennqqi/go-rpmdb/pkg/image"
+)
+
+// Layer is a filesystem layer of an image.
+type Layer = image.Layer
+
+// Puller fetches the layers of an image reference such as "registry.example.com/app:1.0" or a
+// containerd image name, the base layer first.
+type Puller interface {
+	Pull(ctx context.Context, ref string) ([]Layer, error)
+}
+
+// LayerScan is what a layer changed in the rpm database, by NEVRA: a package update is the removal
+// of the old NEVRA and the addition of the new one. image.LayerDiff tells updates apart.
+type LayerScan struct {
+	Index  int
+	Digest string
+	// DBChanged is set when the layer writes or deletes the database, Added and Removed are then
+	// the packages it installed and erased, by NEVRA.
+	DBChanged bool
+	Added     []*rpmdb.PackageInfo
+	Removed   []*rpmdb.PackageInfo
+}
+
+// PackageAttribution is a package of the image and the layer that installed it in this version.
+type PackageAttribution struct {
+	Package     *rpmdb.PackageInfo
+	Layer       int
+	LayerDigest string
+}
+
+// ImageScan is the rpm database of an image, layer by layer.
+type ImageScan struct {
+	Ref    string
+	Layers []*LayerScan
+	// Packages are the packages of the resulting image, sorted by NEVRA.
+	Packages []*PackageAttribution
+}
+
+// ScanImage pulls the layers of ref and reads the rpm database each layer writes, at any of
+// rpmdb.DefaultDBPaths, attributing the packages of the image to layers with image.Attribute.
+// Images without rpm database have no packages.
+func ScanImage(ctx context.Context, puller Puller, ref string) (*ImageScan, error) {
+	layers, err := puller.Pull(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull %s: %w", ref, err)
+	}
+	attribution, err := image.Attribute(ctx, layers)
+	if err != nil {
+		return nil, err
+	}
+
+	scan := &ImageScan{Ref: ref}
+	for _, diff := range attribution.Layers {
+		layerScan := &LayerScan{Index: diff.Index, Digest: diff.Digest, DBChanged: diff.DBChanged}
+		for _, change := range diff.Changes {
+			switch change.Kind {
+			case image.Introduced:
+				layerScan.Added = append(layerScan.Added, change.Package)
+			case image.Modified:
+				layerScan.Added = append(layerScan.Added, change.Package)
+				layerScan.Removed = append(layerScan.Removed, change.Previous)
+			case image.Deleted:
+				layerScan.Removed = append(layerScan.Removed, change.Package)
+			}
+		}
+		sortPackages(layerScan.Added)
+		sortPackages(layerScan.Removed)
+		scan.Layers = append(scan.Layers, layerScan)
+	}
+	for _, a := range attribution.Packages {
+		layer := a.Layer()
+		scan.Packages = append(scan.Packages, &PackageAttribution{Package: a.Package, Layer: layer.Layer, LayerDigest: layer.LayerDigest})
+	}
+	return scan, nil
+}
+
+func sortPackages(pkgs []*rpmdb.PackageInfo) {
+	sort.Slice(pkgs, func(i, j int) bool {
+		return pkgs[i].NEVRA() < pkgs[j].NEVRA()
+	})
+}
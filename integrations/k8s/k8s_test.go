@@ -0,0 +1,128 @@
+package k8s
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+type testFile struct {
+	name string
+	data []byte
+}
+
+func testLayer(t *testing.T, digest string, compress bool, files ...testFile) Layer {
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var zw *gzip.Writer
+	if compress {
+		zw = gzip.NewWriter(&buf)
+		w = zw
+	}
+	tw := tar.NewWriter(w)
+	for _, f := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data := buf.Bytes()
+	return Layer{Digest: digest, Open: func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}}
+}
+
+type testPuller map[string][]Layer
+
+func (p testPuller) Pull(ctx context.Context, ref string) ([]Layer, error) {
+	layers, ok := p[ref]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return layers, nil
+}
+
+func TestScanImage(t *testing.T) {
+	plain, err := ioutil.ReadFile("../../pkg/testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpd24, err := ioutil.ReadFile("../../pkg/testdata/centos7-httpd24/Packages")
+	if err != nil {
+		t.Fatal(err)
+	}
+	puller := testPuller{
+		"app": {
+			testLayer(t, "sha256:base", true, testFile{"./var/lib/rpm/Packages", plain}),
+			testLayer(t, "sha256:config", false, testFile{"etc/httpd.conf", []byte("Listen 80\n")}),
+			testLayer(t, "sha256:httpd", true, testFile{"var/lib/rpm/Packages", httpd24}),
+		},
+		"distroless": {
+			testLayer(t, "sha256:base", true, testFile{"var/lib/rpm/Packages", plain}),
+			testLayer(t, "sha256:strip", false, testFile{"var/lib/rpm/.wh..wh..opq", nil}),
+		},
+	}
+
+	scan, err := ScanImage(context.Background(), puller, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scan.Layers) != 3 {
+		t.Fatalf("unexpected layers: %d", len(scan.Layers))
+	}
+	for i, want := range []struct {
+		changed        bool
+		added, removed int
+	}{
+		{true, 144, 0},
+		{false, 0, 0},
+		{true, 146, 65},
+	} {
+		layer := scan.Layers[i]
+		if layer.DBChanged != want.changed || len(layer.Added) != want.added || len(layer.Removed) != want.removed {
+			t.Errorf("layer %d: changed=%v added=%d removed=%d, want %+v",
+				i, layer.DBChanged, len(layer.Added), len(layer.Removed), want)
+		}
+	}
+	if len(scan.Packages) != 225 {
+		t.Fatalf("unexpected packages: %d", len(scan.Packages))
+	}
+	layers := make(map[string]int)
+	for _, attribution := range scan.Packages {
+		layers[attribution.LayerDigest]++
+		if attribution.Package.Name == "bash" && attribution.LayerDigest != "sha256:httpd" {
+			t.Errorf("bash upgraded by sha256:httpd, got %s", attribution.LayerDigest)
+		}
+	}
+	if layers["sha256:base"] != 144-65 || layers["sha256:httpd"] != 146 {
+		t.Errorf("unexpected attributions: %v", layers)
+	}
+
+	scan, err = ScanImage(context.Background(), puller, "distroless")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !scan.Layers[1].DBChanged || len(scan.Layers[1].Removed) != 144 || len(scan.Packages) != 0 {
+		t.Errorf("whiteout: changed=%v removed=%d packages=%d",
+			scan.Layers[1].DBChanged, len(scan.Layers[1].Removed), len(scan.Packages))
+	}
+
+	if _, err := ScanImage(context.Background(), puller, "missing"); err == nil {
+		t.Error("expected a pull error")
+	}
+}
@@ -0,0 +1,121 @@
+package rpmdb
+
+import (
+	"crypto"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// AggregatedPackage is a distinct package header of an Aggregator along with the sources holding it.
+type AggregatedPackage struct {
+	// Digest is the hex digest of the immutable header region, the SHA1HEADER or SHA256HEADER of the
+	// package for sha1 and sha256.
+	Digest string
+	// Package is decoded once for all the sources, it must not be modified.
+	Package *PackageInfo
+	// Sources are sorted.
+	Sources []string
+}
+
+// Aggregator groups the packages of many databases, the images of a registry for instance, by
+// header digest: a package installed from the same rpm in hundreds of images is decoded and kept
+// once, with the list of the images that have it. The headers already seen aren't decoded again.
+// It is safe for concurrent use.
+type Aggregator struct {
+	algo crypto.Hash
+
+	mu       sync.Mutex
+	packages map[string]*AggregatedPackage
+}
+
+// NewAggregator returns an aggregator keying the headers by their digest with algo, crypto.SHA256
+// usually.
+func NewAggregator(algo crypto.Hash) (*Aggregator, error) {
+	if _, ok := hashNames[algo]; !ok || !algo.Available() {
+		return nil, fmt.Errorf("unsupported hash algorithm: %v", algo)
+	}
+	return &Aggregator{algo: algo, packages: make(map[string]*AggregatedPackage)}, nil
+}
+
+// Add records the packages of db as held by source. Nothing is recorded when db can't be read.
+func (a *Aggregator) Add(source string, db *RpmDB) error {
+	digests := make(map[string]*PackageInfo)
+	err := db.forEachHeader(func(hnum uint32, blob []byte) error {
+		digest, err := headerDigest(a.algo, blob)
+		if err != nil {
+			return fmt.Errorf("invalid header %d: %w", hnum, err)
+		}
+		if _, ok := digests[digest]; ok {
+			return nil
+		}
+		if a.known(digest) {
+			digests[digest] = nil
+			return nil
+		}
+
+		indexEntries, err := headerImport(blob)
+		if err != nil {
+			return fmt.Errorf("error during importing header: %w", err)
+		}
+		pkg, err := getNEVRA(indexEntries)
+		if err != nil {
+			return fmt.Errorf("invalid package info: %w", err)
+		}
+		if db.interner != nil {
+			db.interner.internPackage(pkg)
+		}
+		digests[digest] = pkg
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for digest, pkg := range digests {
+		aggregated, ok := a.packages[digest]
+		if !ok {
+			// pkg is only nil for the headers seen before
+			aggregated = &AggregatedPackage{Digest: digest, Package: pkg}
+			a.packages[digest] = aggregated
+		}
+		aggregated.Sources = append(aggregated.Sources, source)
+	}
+	return nil
+}
+
+func (a *Aggregator) known(digest string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, ok := a.packages[digest]
+	return ok
+}
+
+// Len returns the number of distinct headers recorded.
+func (a *Aggregator) Len() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.packages)
+}
+
+// Packages returns the distinct headers recorded, sorted by NEVRA then digest.
+func (a *Aggregator) Packages() []*AggregatedPackage {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	pkgs := make([]*AggregatedPackage, 0, len(a.packages))
+	for _, aggregated := range a.packages {
+		sources := append([]string(nil), aggregated.Sources...)
+		sort.Strings(sources)
+		pkgs = append(pkgs, &AggregatedPackage{Digest: aggregated.Digest, Package: aggregated.Package, Sources: sources})
+	}
+	sort.Slice(pkgs, func(i, j int) bool {
+		if x, y := pkgs[i].Package.NEVRA(), pkgs[j].Package.NEVRA(); x != y {
+			return x < y
+		}
+		return pkgs[i].Digest < pkgs[j].Digest
+	})
+	return pkgs
+}
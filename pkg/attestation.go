@@ -0,0 +1,125 @@
+package rpmdb
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// the in-toto types of the statements of NewStatement
+// ref. https://github.com/in-toto/attestation/blob/v1.0/spec/v1/statement.md
+const (
+	StatementType          = "https://in-toto.io/Statement/v1"
+	StatementPayloadType   = "application/vnd.in-toto+json"
+	InventoryPredicateType = "https://github.com/chennqqi/go-rpmdb/inventory/v1"
+)
+
+// ResourceDescriptor is a subject of a Statement, an image by its manifest digest for instance.
+// Digest maps algorithm names to hex digests, {"sha256": "..."}.
+type ResourceDescriptor struct {
+	Name   string            `json:"name,omitempty"`
+	Digest map[string]string `json:"digest"`
+}
+
+// Statement is an in-toto statement of the package inventory of its subjects. Its Bytes are the
+// payload of a DSSE envelope of StatementPayloadType, SignEnvelope signing one.
+type Statement struct {
+	Type          string               `json:"_type"`
+	Subject       []ResourceDescriptor `json:"subject"`
+	PredicateType string               `json:"predicateType"`
+	Predicate     InventoryPredicate   `json:"predicate"`
+}
+
+// InventoryPredicate is the predicate of InventoryPredicateType.
+type InventoryPredicate struct {
+	// Packages are sorted by NEVRA.
+	Packages []InventoryPackage `json:"packages"`
+	// ManifestDigest is the digest of the PackageManifest of the packages.
+	ManifestDigest map[string]string `json:"manifestDigest"`
+}
+
+// InventoryPackage is a package of an InventoryPredicate. Digest is the SHA-256 of its header, its
+// SHA256HEADER, and PayloadDigest the digest of the payload of its rpm when recorded.
+type InventoryPackage struct {
+	NEVRA         string            `json:"nevra"`
+	Name          string            `json:"name"`
+	Epoch         int               `json:"epoch,omitempty"`
+	Version       string            `json:"version"`
+	Release       string            `json:"release"`
+	Arch          string            `json:"arch,omitempty"`
+	SourceRpm     string            `json:"sourceRpm,omitempty"`
+	License       string            `json:"license,omitempty"`
+	Vendor        string            `json:"vendor,omitempty"`
+	Digest        map[string]string `json:"digest"`
+	PayloadDigest map[string]string `json:"payloadDigest,omitempty"`
+}
+
+// NewStatement returns the in-toto statement of the packages of db about subjects, the image or
+// host scanned. A subject without digest, a host having none, gets the manifest digest of the
+// packages.
+func NewStatement(db *RpmDB, subjects ...ResourceDescriptor) (*Statement, error) {
+	if len(subjects) == 0 {
+		return nil, errors.New("a statement needs a subject")
+	}
+
+	predicate := InventoryPredicate{Packages: []InventoryPackage{}}
+	err := db.forEachHeader(func(hnum uint32, blob []byte) error {
+		indexEntries, err := headerImport(blob)
+		if err != nil {
+			return fmt.Errorf("error during importing header: %w", err)
+		}
+		pkg, err := getNEVRA(indexEntries)
+		if err != nil {
+			return fmt.Errorf("invalid package info: %w", err)
+		}
+		digest, err := headerDigest(crypto.SHA256, blob)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pkg.NEVRA(), err)
+		}
+
+		p := InventoryPackage{
+			NEVRA:     pkg.NEVRA(),
+			Name:      pkg.Name,
+			Epoch:     pkg.Epoch,
+			Version:   pkg.Version,
+			Release:   pkg.Release,
+			Arch:      pkg.Arch,
+			SourceRpm: pkg.SourceRpm,
+			License:   pkg.License,
+			Vendor:    pkg.Vendor,
+			Digest:    map[string]string{"sha256": digest},
+		}
+		if pkg.PayloadDigest != "" {
+			p.PayloadDigest = map[string]string{pkg.PayloadDigestAlgo.String(): pkg.PayloadDigest}
+		}
+		predicate.Packages = append(predicate.Packages, p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(predicate.Packages, func(i, j int) bool {
+		return predicate.Packages[i].NEVRA < predicate.Packages[j].NEVRA
+	})
+
+	manifest, err := Manifest(db, crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	predicate.ManifestDigest = map[string]string{manifest.Algorithm: manifest.Digest}
+
+	statement := &Statement{Type: StatementType, PredicateType: InventoryPredicateType, Predicate: predicate}
+	for _, subject := range subjects {
+		if len(subject.Digest) == 0 {
+			subject.Digest = predicate.ManifestDigest
+		}
+		statement.Subject = append(statement.Subject, subject)
+	}
+	return statement, nil
+}
+
+// Bytes returns the canonical JSON of the statement.
+func (s *Statement) Bytes() ([]byte, error) {
+	return CanonicalJSON(s)
+}
@@ -1,3 +1,10 @@
+// Package bdb is a read-only reader of the Berkeley DB hash databases, the format of rpm's
+// Packages file and of its index databases, without cgo or libdb. A BerkeleyDB is opened from a
+// file with Open or from any io.ReaderAt with NewReader, then read a key-value pair at a time with
+// a Cursor, a page at a time with Pages, or looked up by key with Get; Read and ReadAll send the
+// pairs on a channel. Writer creates databases of the same format, and Dump reads the text db_dump
+// prints.
+// ref. https://github.com/berkeleydb/libdb/blob/5b7b02ae052442626af54c176335b67ecc613a30/src/dbinc/db_page.h
 package bdb
 
 import (
@@ -18,13 +25,17 @@ var validPageSizes = map[uint32]struct{}{
 }
 
 type BerkeleyDB struct {
-	file         *os.File
+	file         io.ReaderAt
+	closer       io.Closer
 	HashMetadata *HashMetadataPage
 }
 
 type Entry struct {
+	Key   []byte
 	Value []byte
-	Err   error
+	// PageNo is the first overflow page of off-page values, 0 for values stored on the hash page.
+	PageNo uint32
+	Err    error
 }
 
 func Open(path string) (*BerkeleyDB, error) {
@@ -32,17 +43,30 @@ func Open(path string) (*BerkeleyDB, error) {
 	if err != nil {
 		return nil, err
 	}
-
-	// read just a bit in to parse at least the metadata...
-	metadataBuff := make([]byte, 512)
-	_, err = file.Read(metadataBuff)
+	info, err := file.Stat()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read metadata: %w", err)
+		file.Close()
+		return nil, err
 	}
 
-	_, err = file.Seek(0, io.SeekStart)
+	db, err := NewReader(file, info.Size())
 	if err != nil {
-		return nil, fmt.Errorf("failed to seek db file: %w", err)
+		file.Close()
+		return nil, err
+	}
+	db.closer = file
+	return db, nil
+}
+
+// NewReader reads a database from r, size is the length of the database: a file, a memory map or
+// a byte slice with bytes.NewReader, an HTTP range reader. The pages past size don't read.
+func NewReader(r io.ReaderAt, size int64) (*BerkeleyDB, error) {
+	file := io.NewSectionReader(r, 0, size)
+
+	// read just a bit in to parse at least the metadata...
+	metadataBuff := make([]byte, 512)
+	if _, err := file.ReadAt(metadataBuff, 0); err != nil {
+		return nil, fmt.Errorf("failed to read metadata: %w", err)
 	}
 
 	hashMetadata, err := ParseHashMetadataPage(metadataBuff)
@@ -61,89 +85,67 @@ func Open(path string) (*BerkeleyDB, error) {
 
 }
 
-func (db *BerkeleyDB) Read() <-chan Entry {
-	entries := make(chan Entry)
+func (db *BerkeleyDB) Close() error {
+	if db.closer == nil {
+		return nil
+	}
+	return db.closer.Close()
+}
 
-	go func() {
-		defer close(entries)
+// Read returns the values stored on overflow pages, which is where rpm keeps package headers. The
+// channel is not closed before every pair has been received, stop a reading early with ReadUntil or
+// a Cursor.
+func (db *BerkeleyDB) Read() <-chan Entry {
+	return db.read(false, nil)
+}
 
-		// the first content entry (idx=0) is the db metadata, skip to the first real entry and keep reading content values
-		for pageNum := uint32(1); pageNum <= db.HashMetadata.LastPageNo; pageNum++ {
-			pageData, err := slice(db.file, int(db.HashMetadata.PageSize))
-			if err != nil {
-				entries <- Entry{
-					Err: err,
-				}
-				return
-			}
+// ReadUntil is Read stopping once done is closed: the channel is then closed, no pair being sent
+// anymore, and the reading goroutine exits.
+func (db *BerkeleyDB) ReadUntil(done <-chan struct{}) <-chan Entry {
+	return db.read(false, done)
+}
 
-			// keep track of the start of the next page for the next iteration...
-			endOfPageOffset, err := db.file.Seek(0, io.SeekCurrent)
-			if err != nil {
-				entries <- Entry{
-					Err: err,
-				}
-				return
-			}
+// ReadAll returns every key-value pair, including small values stored on the hash pages themselves
+// such as the records of the rpm index databases (Name, Providename, Basenames, ...).
+func (db *BerkeleyDB) ReadAll() <-chan Entry {
+	return db.read(true, nil)
+}
 
-			hashPageHeader, err := ParseHashPage(pageData)
-			if err != nil {
-				entries <- Entry{
-					Err: err,
-				}
-				return
-			}
+// ReadAllUntil is ReadAll stopping once done is closed, like ReadUntil.
+func (db *BerkeleyDB) ReadAllUntil(done <-chan struct{}) <-chan Entry {
+	return db.read(true, done)
+}
 
-			if hashPageHeader.PageType != HashPageType {
-				// skip over pages that do not have hash values
-				continue
-			}
+// ReadOverflow reads the off-page value starting at pageNo, the Entry.PageNo of a previous Read.
+func (db *BerkeleyDB) ReadOverflow(pageNo uint32) ([]byte, error) {
+	if pageNo == 0 || pageNo > db.HashMetadata.LastPageNo {
+		return nil, fmt.Errorf("invalid overflow page: %d", pageNo)
+	}
+	return OverflowPageContent(db.file, pageNo, db.HashMetadata.PageSize)
+}
 
-			hashPageIndexes, err := HashPageValueIndexes(pageData, hashPageHeader.NumEntries)
-			if err != nil {
-				entries <- Entry{
-					Err: err,
-				}
-				return
-			}
+// read sends the pairs of a Cursor, the error stopping it last, until done is closed; a nil done
+// is never.
+func (db *BerkeleyDB) read(includeOnPage bool, done <-chan struct{}) <-chan Entry {
+	entries := make(chan Entry)
 
-			for _, hashPageIndex := range hashPageIndexes {
-				// the first byte is the page type, so we can peek at it first before parsing further...
-				valuePageType := pageData[hashPageIndex]
-
-				// Only Overflow pages contain package data, skip anything else.
-				if valuePageType != HashOffIndexPageType {
-					continue
-				}
-
-				// Traverse the page to concatenate the data that may span multiple pages.
-				valueContent, err := HashPageValueContent(
-					db.file,
-					pageData,
-					hashPageIndex,
-					db.HashMetadata.PageSize,
-				)
-
-				entries <- Entry{
-					Value: valueContent,
-					Err:   err,
-				}
-
-				if err != nil {
-					return
-				}
-			}
+	go func() {
+		defer close(entries)
 
-			// go back to the start of the next page for reading...
-			_, err = db.file.Seek(endOfPageOffset, io.SeekStart)
-			if err != nil {
-				entries <- Entry{
-					Err: err,
-				}
+		c := db.Cursor(includeOnPage)
+		for c.Next() {
+			select {
+			case entries <- c.Entry():
+			case <-done:
 				return
 			}
 		}
-
+		if err := c.Err(); err != nil {
+			select {
+			case entries <- Entry{Err: err}:
+			case <-done:
+			}
+		}
 	}()
 
 	return entries
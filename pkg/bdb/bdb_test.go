@@ -0,0 +1,258 @@
+package bdb
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestDB creates a database of n pairs, every third value large enough for overflow pages.
+func writeTestDB(t *testing.T, n int) (string, map[string][]byte) {
+	dir, err := ioutil.TempDir("", "bdb")
+	if err != nil {
+		t.Fatalf("TempDir() error: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "test.db")
+	w, err := Create(path, 1024)
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	pairs := make(map[string][]byte)
+	for i := 0; i < n; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		value := []byte(fmt.Sprintf("value-%d", i))
+		if i%3 == 0 {
+			value = bytes.Repeat(value, 300)
+		}
+		if err := w.Put([]byte(key), value); err != nil {
+			t.Fatalf("Put() error: %v", err)
+		}
+		pairs[key] = value
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+	return path, pairs
+}
+
+func TestCursor(t *testing.T) {
+	path, pairs := writeTestDB(t, 200)
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	if db.PageSize() != 1024 || db.NumKeys() != 200 || db.Version() != HashMetadataVersion {
+		t.Errorf("metadata: got page size %d, %d keys, version %d", db.PageSize(), db.NumKeys(), db.Version())
+	}
+
+	for _, includeOnPage := range []bool{false, true} {
+		seen := make(map[string]bool)
+		c := db.Cursor(includeOnPage)
+		// a cursor running along doesn't disturb the other
+		other := db.Cursor(includeOnPage)
+		for c.Next() {
+			if !other.Next() {
+				t.Fatalf("concurrent cursor error: %v", other.Err())
+			}
+			entry := c.Entry()
+			if !bytes.Equal(entry.Key, other.Entry().Key) {
+				t.Errorf("concurrent cursor: got %q, want %q", other.Entry().Key, entry.Key)
+			}
+			if !bytes.Equal(entry.Value, pairs[string(entry.Key)]) {
+				t.Errorf("%s: unexpected value of %d bytes", entry.Key, len(entry.Value))
+			}
+			if (entry.PageNo != 0) != (len(entry.Value) > int(db.PageSize()/4)) {
+				t.Errorf("%s: unexpected page %d for %d bytes", entry.Key, entry.PageNo, len(entry.Value))
+			}
+			seen[string(entry.Key)] = true
+		}
+		if err := c.Err(); err != nil {
+			t.Fatalf("Cursor(%v) error: %v", includeOnPage, err)
+		}
+
+		want := 0
+		for _, value := range pairs {
+			if includeOnPage || len(value) > int(db.PageSize()/4) {
+				want++
+			}
+		}
+		if len(seen) != want {
+			t.Errorf("Cursor(%v): got %d pairs, want %d", includeOnPage, len(seen), want)
+		}
+
+		// the channel API yields the same pairs
+		n := 0
+		read := db.Read
+		if includeOnPage {
+			read = db.ReadAll
+		}
+		for entry := range read() {
+			if entry.Err != nil {
+				t.Fatalf("read error: %v", entry.Err)
+			}
+			n++
+		}
+		if n != want {
+			t.Errorf("read(%v): got %d pairs, want %d", includeOnPage, n, want)
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	path, pairs := writeTestDB(t, 50)
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	for key, want := range pairs {
+		value, err := db.Get([]byte(key))
+		if err != nil {
+			t.Fatalf("Get(%s) error: %v", key, err)
+		}
+		if !bytes.Equal(value, want) {
+			t.Errorf("Get(%s): got %d bytes, want %d", key, len(value), len(want))
+		}
+	}
+	if _, err := db.Get([]byte("missing")); err != ErrNotFound {
+		t.Errorf("Get(missing): got %v, want ErrNotFound", err)
+	}
+
+	// a database of rpm, having several buckets
+	rpmdb, err := Open("../testdata/centos7-many/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer rpmdb.Close()
+	if rpmdb.HashMetadata.MaxBucket == 0 {
+		t.Fatalf("single bucket database")
+	}
+	c := rpmdb.Cursor(true)
+	n := 0
+	for c.Next() {
+		entry := c.Entry()
+		value, err := rpmdb.Get(entry.Key)
+		if err != nil {
+			t.Fatalf("Get(%x) error: %v", entry.Key, err)
+		}
+		if !bytes.Equal(value, entry.Value) {
+			t.Errorf("Get(%x): got %d bytes, want %d", entry.Key, len(value), len(entry.Value))
+		}
+		n++
+	}
+	if err := c.Err(); err != nil {
+		t.Fatalf("Cursor() error: %v", err)
+	}
+	if n == 0 {
+		t.Errorf("no pairs read")
+	}
+}
+
+func TestPages(t *testing.T) {
+	path, _ := writeTestDB(t, 100)
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	stats, err := db.Stats()
+	if err != nil {
+		t.Fatalf("Stats() error: %v", err)
+	}
+	var hashPages, overflowPages int
+	want := uint32(1)
+	it := db.Pages()
+	for it.Next() {
+		page := it.Page()
+		if page.No != want || page.Header.PageNo != page.No || len(page.Data) != int(db.PageSize()) {
+			t.Errorf("page %d: got page %d (header %d) of %d bytes", want, page.No, page.Header.PageNo, len(page.Data))
+		}
+		switch page.Header.PageType {
+		case HashPageType:
+			hashPages++
+		case OverflowPageType:
+			overflowPages++
+		}
+		want++
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("Pages() error: %v", err)
+	}
+	if want != db.LastPageNo()+1 || hashPages != stats.HashPages || overflowPages != stats.OverflowPages {
+		t.Errorf("got %d pages, %d hash and %d overflow ones, want %+v", want-1, hashPages, overflowPages, stats)
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	path, pairs := writeTestDB(t, 100)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("NewReader() error: %v", err)
+	}
+	defer db.Close()
+	n := 0
+	for entry := range db.ReadAll() {
+		if entry.Err != nil {
+			t.Fatalf("ReadAll() error: %v", entry.Err)
+		}
+		if !bytes.Equal(entry.Value, pairs[string(entry.Key)]) {
+			t.Errorf("%s: got %d bytes, want %d", entry.Key, len(entry.Value), len(pairs[string(entry.Key)]))
+		}
+		n++
+	}
+	if n != len(pairs) {
+		t.Errorf("ReadAll(): got %d pairs, want %d", n, len(pairs))
+	}
+
+	// the pages past the size given don't read, whatever r holds
+	truncated, err := NewReader(bytes.NewReader(data), int64(len(data)/2))
+	if err != nil {
+		t.Fatalf("NewReader() truncated error: %v", err)
+	}
+	c := truncated.Cursor(true)
+	for c.Next() {
+	}
+	if c.Err() == nil {
+		t.Errorf("Cursor() of a truncated database: expected an error")
+	}
+	if _, err := NewReader(bytes.NewReader(data), 100); err == nil {
+		t.Errorf("NewReader() of 100 bytes: expected an error")
+	}
+}
+
+func TestReadUntil(t *testing.T) {
+	path, _ := writeTestDB(t, 100)
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	done := make(chan struct{})
+	entries := db.ReadUntil(done)
+	if entry := <-entries; entry.Err != nil || entry.Key == nil {
+		t.Fatalf("ReadUntil() got %+v", entry)
+	}
+	close(done)
+	// the channel is closed without the 33 other overflow values
+	n := 0
+	for range entries {
+		n++
+	}
+	if n >= 33 {
+		t.Errorf("ReadUntil(): got %d pairs after done", n)
+	}
+}
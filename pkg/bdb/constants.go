@@ -13,9 +13,17 @@ const (
 	// all page types supported
 	HashMetadataPageType PageType = 8
 	HashPageType         PageType = 13
+	OverflowPageType     PageType = 7 // a.k.a P_OVERFLOW
+	HashKeyDataPageType  PageType = 1 // a.k.a H_KEYDATA
 	HashOffIndexPageType PageType = 3 // a.k.a HOFFPAGE
 
 	HashOffPageSize = 12 // (in bytes)
+
+	// the hash access method version written by libdb >= 4.6
+	HashMetadataVersion = 9
 )
 
+// charKey is hashed into the metadata page, telling the hash function the database was created with.
+var charKey = []byte("%$sniglet^&")
+
 type PageType = uint8
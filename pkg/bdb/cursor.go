@@ -0,0 +1,280 @@
+package bdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound is returned by Get when no pair of the database has the key.
+var ErrNotFound = errors.New("key not found")
+
+// PageSize is the size of every page of the database, in bytes.
+func (db *BerkeleyDB) PageSize() uint32 {
+	return db.HashMetadata.PageSize
+}
+
+// LastPageNo is the number of the last page of the database, the metadata page being page 0.
+func (db *BerkeleyDB) LastPageNo() uint32 {
+	return db.HashMetadata.LastPageNo
+}
+
+// NumKeys is the number of keys the metadata page records. libdb only updates it when the
+// database is closed cleanly, iterate with a Cursor for the actual count.
+func (db *BerkeleyDB) NumKeys() uint32 {
+	return db.HashMetadata.NumKeys
+}
+
+// Version is the version of the hash access method the database was written with.
+func (db *BerkeleyDB) Version() uint32 {
+	return db.HashMetadata.Version
+}
+
+// Page is a page of the database read by a PageIterator.
+type Page struct {
+	No uint32
+	// Header is the page header, whose PageType tells what the page holds.
+	Header *HashPage
+	// Data is the whole page, the header included.
+	Data []byte
+}
+
+// PageIterator reads the pages following the metadata page in page order:
+//
+//	it := db.Pages()
+//	for it.Next() {
+//		page := it.Page()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+//
+// It reads at explicit offsets, several iterators can run at the same time.
+type PageIterator struct {
+	db   *BerkeleyDB
+	next uint32
+	// follow the NextPageNo of the pages from next, the chain of a bucket, rather than every page
+	chain bool
+	// the pages read, a chain longer than the database being a cycle
+	read uint32
+	page Page
+	err  error
+}
+
+// Pages returns an iterator over the pages of the database.
+func (db *BerkeleyDB) Pages() *PageIterator {
+	return &PageIterator{db: db, next: 1}
+}
+
+// Next reads the next page, false once every page has been read or on error.
+func (it *PageIterator) Next() bool {
+	if it.err != nil || it.next == 0 || (!it.chain && it.next > it.db.HashMetadata.LastPageNo) {
+		return false
+	}
+	pageNo := it.next
+	if it.chain && (pageNo > it.db.HashMetadata.LastPageNo || it.read > it.db.HashMetadata.LastPageNo) {
+		it.err = fmt.Errorf("invalid bucket page: %d", pageNo)
+		return false
+	}
+	data, err := it.db.readPage(pageNo)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	header, err := ParseHashPage(data)
+	if err != nil {
+		it.err = fmt.Errorf("failed to parse page=%d: %w", pageNo, err)
+		return false
+	}
+	it.page = Page{No: pageNo, Header: header, Data: data}
+	it.read++
+	if it.chain {
+		it.next = header.NextPageNo
+	} else {
+		it.next++
+	}
+	return true
+}
+
+// Page returns the page read by the last Next.
+func (it *PageIterator) Page() *Page {
+	return &it.page
+}
+
+// Err returns the error that stopped the iteration, nil when every page was read.
+func (it *PageIterator) Err() error {
+	return it.err
+}
+
+// Cursor reads the key-value pairs of the hash pages in page order, the values stored on overflow
+// pages being reassembled. Like PageIterator, several cursors can run at the same time.
+type Cursor struct {
+	db    *BerkeleyDB
+	pages *PageIterator
+	// whether the small values stored on the hash pages are returned, not only the off-page ones
+	includeOnPage bool
+	// only the pairs having key are read when match is set, for Get
+	match bool
+	key   []byte
+
+	// the pairs of the current hash page and the next one to return
+	keys, values []uint16
+	i            int
+
+	entry Entry
+	err   error
+}
+
+// Cursor returns a cursor over the pairs of the database, only the ones having their value on
+// overflow pages unless includeOnPage is set: Read and ReadAll respectively.
+func (db *BerkeleyDB) Cursor(includeOnPage bool) *Cursor {
+	return &Cursor{db: db, pages: db.Pages(), includeOnPage: includeOnPage}
+}
+
+// Next reads the next pair, false once every pair has been read or on error.
+func (c *Cursor) Next() bool {
+	for c.err == nil {
+		for c.i < len(c.values) {
+			i := c.i
+			c.i++
+			ok, err := c.read(i)
+			if err != nil {
+				c.err = err
+				return false
+			}
+			if ok {
+				return true
+			}
+		}
+
+		if !c.pages.Next() {
+			c.err = c.pages.Err()
+			return false
+		}
+		page := c.pages.Page()
+		if page.Header.PageType != HashPageType {
+			// skip over pages that do not have hash values
+			c.keys, c.values, c.i = nil, nil, 0
+			continue
+		}
+		values, err := HashPageValueIndexes(page.Data, page.Header.NumEntries)
+		if err != nil {
+			c.err = fmt.Errorf("page=%d: %w", page.No, err)
+			return false
+		}
+		keys, err := HashPageKeyIndexes(page.Data, page.Header.NumEntries)
+		if err != nil {
+			c.err = fmt.Errorf("page=%d: %w", page.No, err)
+			return false
+		}
+		c.keys, c.values, c.i = keys, values, 0
+	}
+	return false
+}
+
+// read sets the entry to the i-th pair of the current page, false for the pairs skipped.
+func (c *Cursor) read(i int) (bool, error) {
+	page := c.pages.Page()
+	index := c.values[i]
+	if int(index) >= len(page.Data) {
+		return false, fmt.Errorf("invalid item offset: %d (page=%d)", index, page.No)
+	}
+
+	valuePageType := page.Data[index]
+	if valuePageType != HashOffIndexPageType && (valuePageType != HashKeyDataPageType || !c.includeOnPage) {
+		return false, nil
+	}
+
+	// the key item ends where the value item of the previous pair begins
+	keyEnd := c.db.HashMetadata.PageSize
+	if i > 0 {
+		keyEnd = uint32(c.values[i-1])
+	}
+	key, err := HashPageKeyDataContent(page.Data, c.keys[i], keyEnd)
+	if err != nil {
+		return false, err
+	}
+	if c.match && !bytes.Equal(key, c.key) {
+		return false, nil
+	}
+
+	var value []byte
+	var valuePageNo uint32
+	switch valuePageType {
+	case HashOffIndexPageType:
+		if int(index)+HashOffPageSize > len(page.Data) {
+			return false, fmt.Errorf("invalid item offset: %d (page=%d)", index, page.No)
+		}
+		offPageEntry, err := ParseHashOffPageEntry(page.Data[index : index+HashOffPageSize])
+		if err != nil {
+			return false, err
+		}
+		valuePageNo = offPageEntry.PageNo
+		// Traverse the page to concatenate the data that may span multiple pages.
+		value, err = c.db.ReadOverflow(valuePageNo)
+		if err != nil {
+			return false, err
+		}
+	default:
+		// the value item ends where the key item of the same pair begins
+		value, err = HashPageKeyDataContent(page.Data, index, uint32(c.keys[i]))
+		if err != nil {
+			return false, err
+		}
+	}
+
+	c.entry = Entry{Key: key, Value: value, PageNo: valuePageNo}
+	return true, nil
+}
+
+// Entry returns the pair read by the last Next. Its Key and Value aren't modified by the following
+// calls, they can be kept.
+func (c *Cursor) Entry() Entry {
+	return c.entry
+}
+
+// Err returns the error that stopped the cursor, nil when every pair was read.
+func (c *Cursor) Err() error {
+	return c.err
+}
+
+// Get returns the value of the first pair having key, ErrNotFound when there is none. Only the
+// pages of the bucket of key are read for the databases using the default hash function of libdb,
+// the one of rpm; the hash pages are walked for the others, whose hash function isn't known.
+func (db *BerkeleyDB) Get(key []byte) ([]byte, error) {
+	c := db.Cursor(true)
+	if pageNo, ok := db.bucketPage(key); ok {
+		c.pages = &PageIterator{db: db, next: pageNo, chain: true}
+	}
+	// the values of the other pairs aren't read
+	c.match, c.key = true, key
+	if c.Next() {
+		return c.Entry().Value, nil
+	}
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
+	return nil, ErrNotFound
+}
+
+// bucketPage returns the first page of the bucket key hashes to, false when the database wasn't
+// created with the default hash function.
+// ref. https://github.com/berkeleydb/libdb/blob/5b7b02ae052442626af54c176335b67ecc613a30/src/hash/hash_page.c
+func (db *BerkeleyDB) bucketPage(key []byte) (uint32, bool) {
+	meta := db.HashMetadata
+	if meta.CharKeyHash != hashFunc(charKey) {
+		return 0, false
+	}
+	h := hashFunc(key)
+	bucket := h & meta.HighMask
+	if bucket > meta.MaxBucket {
+		bucket = h & meta.LowMask
+	}
+	// BUCKET_TO_PAGE: the buckets of a doubling follow the spare pages of the ones before
+	spare := 0
+	for limit := uint32(1); limit < bucket+1; limit <<= 1 {
+		spare++
+	}
+	return bucket + meta.Spares[spare], true
+}
@@ -0,0 +1,221 @@
+package bdb
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// the page size of the databases whose db_dump header doesn't tell it
+const defaultDumpPageSize = 4096
+
+// Dump is a database read from the text db_dump prints and db_load reads back, in either the
+// bytevalue format or the printable one of db_dump -p. The records keep the order of the dump.
+// ref. https://github.com/berkeleydb/libdb/blob/5b7b02ae052442626af54c176335b67ecc613a30/src/db/db_pr.c
+type Dump struct {
+	// Header holds the key=value lines before HEADER=END: type, db_pagesize, h_ffactor, ...
+	Header   map[string]string
+	PageSize uint32
+	records  []Entry
+}
+
+// ReadDump parses the output of db_dump (db_dump -k included) for a single database.
+func ReadDump(r io.Reader) (*Dump, error) {
+	scanner := bufio.NewScanner(r)
+	// header blobs of several megabytes end up on a single line
+	scanner.Buffer(make([]byte, 64*1024), 1<<30)
+
+	dump := &Dump{Header: make(map[string]string), PageSize: defaultDumpPageSize}
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if line == "HEADER=END" {
+			break
+		}
+		i := strings.IndexByte(line, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: invalid dump header: %q", lineNo, line)
+		}
+		dump.Header[line[:i]] = line[i+1:]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dump: %w", err)
+	}
+
+	switch dump.Header["VERSION"] {
+	case "2", "3":
+	default:
+		return nil, fmt.Errorf("unsupported dump version: %q", dump.Header["VERSION"])
+	}
+	if t := dump.Header["type"]; t != "" && t != "hash" && t != "btree" {
+		return nil, fmt.Errorf("unsupported database type: %q", t)
+	}
+	printable := dump.Header["format"] == "print"
+	if s, ok := dump.Header["db_pagesize"]; ok {
+		pageSize, err := strconv.ParseUint(s, 10, 32)
+		if _, valid := validPageSizes[uint32(pageSize)]; err != nil || !valid {
+			return nil, fmt.Errorf("unexpected page size: %q", s)
+		}
+		dump.PageSize = uint32(pageSize)
+	}
+
+	var key []byte
+	ended := false
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if line == "DATA=END" {
+			ended = true
+			break
+		}
+		// every data line is indented by a space
+		if !strings.HasPrefix(line, " ") {
+			return nil, fmt.Errorf("line %d: invalid data line", lineNo)
+		}
+		var data []byte
+		var err error
+		if printable {
+			data, err = unescapeDumpLine(line[1:])
+		} else {
+			data, err = hex.DecodeString(line[1:])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+
+		if key == nil {
+			key = data
+			continue
+		}
+		dump.records = append(dump.records, Entry{Key: key, Value: data, PageNo: uint32(len(dump.records) + 1)})
+		key = nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dump: %w", err)
+	}
+	if !ended || key != nil {
+		return nil, fmt.Errorf("truncated dump: %d records", len(dump.records))
+	}
+	return dump, nil
+}
+
+// unescapeDumpLine decodes a line of db_dump -p: printable characters as is, a backslash escaped
+// by another one and every other byte written as \xx in hex.
+func unescapeDumpLine(line string) ([]byte, error) {
+	data := make([]byte, 0, len(line))
+	for i := 0; i < len(line); i++ {
+		if line[i] != '\\' {
+			data = append(data, line[i])
+			continue
+		}
+		if i+1 < len(line) && line[i+1] == '\\' {
+			data = append(data, '\\')
+			i++
+			continue
+		}
+		if i+2 >= len(line) {
+			return nil, fmt.Errorf("truncated escape at %d", i)
+		}
+		b, err := hex.DecodeString(line[i+1 : i+3])
+		if err != nil {
+			return nil, fmt.Errorf("invalid escape at %d: %w", i, err)
+		}
+		data = append(data, b...)
+		i += 2
+	}
+	return data, nil
+}
+
+// isBig tells whether the hash access method would store a value of this size on overflow pages.
+// ref. https://github.com/berkeleydb/libdb/blob/5b7b02ae052442626af54c176335b67ecc613a30/src/dbinc/hash.h
+func (d *Dump) isBig(size int) bool {
+	return size > int(d.PageSize/4)
+}
+
+// Read returns the values that would be stored on overflow pages, like BerkeleyDB.Read: the package
+// headers of an rpm Packages dump.
+func (d *Dump) Read() <-chan Entry {
+	return d.read(false, nil)
+}
+
+// ReadUntil is Read stopping once done is closed, like BerkeleyDB.ReadUntil.
+func (d *Dump) ReadUntil(done <-chan struct{}) <-chan Entry {
+	return d.read(false, done)
+}
+
+// ReadAll returns every record of the dump.
+func (d *Dump) ReadAll() <-chan Entry {
+	return d.read(true, nil)
+}
+
+func (d *Dump) read(includeOnPage bool, done <-chan struct{}) <-chan Entry {
+	entries := make(chan Entry)
+	go func() {
+		defer close(entries)
+		for _, record := range d.records {
+			if !includeOnPage && !d.isBig(len(record.Value)) {
+				continue
+			}
+			select {
+			case entries <- record:
+			case <-done:
+				return
+			}
+		}
+	}()
+	return entries
+}
+
+// ReadOverflow returns the value of the record whose Entry.PageNo is pageNo, the position of the
+// record in the dump: a dump has no pages.
+func (d *Dump) ReadOverflow(pageNo uint32) ([]byte, error) {
+	if pageNo == 0 || int(pageNo) > len(d.records) {
+		return nil, fmt.Errorf("invalid record: %d", pageNo)
+	}
+	return d.records[pageNo-1].Value, nil
+}
+
+// Close releases nothing, the dump is held in memory.
+func (d *Dump) Close() error {
+	return nil
+}
+
+// DumpWriter writes the text db_dump prints in the bytevalue format, which db_load turns back into
+// a hash database with the standard Berkeley DB tools.
+type DumpWriter struct {
+	w *bufio.Writer
+}
+
+func NewDumpWriter(w io.Writer, pageSize uint32) (*DumpWriter, error) {
+	if _, ok := validPageSizes[pageSize]; !ok {
+		return nil, fmt.Errorf("unexpected page size: %+v", pageSize)
+	}
+	dw := &DumpWriter{w: bufio.NewWriter(w)}
+	fmt.Fprintf(dw.w, "VERSION=3\nformat=bytevalue\ntype=hash\ndb_pagesize=%d\nHEADER=END\n", pageSize)
+	return dw, nil
+}
+
+// Put writes a key-value pair.
+func (w *DumpWriter) Put(key, value []byte) error {
+	for _, data := range [][]byte{key, value} {
+		w.w.WriteByte(' ')
+		w.w.WriteString(hex.EncodeToString(data))
+		if err := w.w.WriteByte('\n'); err != nil {
+			return fmt.Errorf("failed to write dump: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close ends the dump and flushes it, the underlying writer is left open.
+func (w *DumpWriter) Close() error {
+	w.w.WriteString("DATA=END\n")
+	if err := w.w.Flush(); err != nil {
+		return fmt.Errorf("failed to write dump: %w", err)
+	}
+	return nil
+}
@@ -23,7 +23,7 @@ type GenericMetadataPage struct {
 	KeyCount      uint32   `struct:"uint32"`   /* 40-43: Cached key count. */
 	RecordCount   uint32   `struct:"uint32"`   /* 44-47: Cached record count. */
 	Flags         uint32   `struct:"uint32"`   /* 48-51: Flags: unique to each AM. */
-	UniqueFileID  [19]byte `struct:"[19]byte"` /* 52-71: Unique file ID. */
+	UniqueFileID  [20]byte `struct:"[20]byte"` /* 52-71: Unique file ID. */
 }
 
 func ParseGenericMetadataPage(data []byte) (*GenericMetadataPage, error) {
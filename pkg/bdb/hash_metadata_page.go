@@ -15,6 +15,8 @@ type HashMetadataPage struct {
 	FillFactor  uint32 `struct:"uint32"` /* 84-87: Fill factor */
 	NumKeys     uint32 `struct:"uint32"` /* 88-91: Number of keys in hash table */
 	CharKeyHash uint32 `struct:"uint32"` /* 92-95: Value of hash(CHARKEY) */
+	// Spares tells where the pages of the buckets start, the buckets being allocated in doublings.
+	Spares [32]uint32 `struct:"[32]uint32"` /* 96-223: Spare pages for overflow */
 	// don't care about the rest...
 }
 
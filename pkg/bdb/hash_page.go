@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"github.com/go-restruct/restruct"
 	"io"
-	"os"
 )
 
 // source: https://github.com/berkeleydb/libdb/blob/5b7b02ae052442626af54c176335b67ecc613a30/src/dbinc/db_page.h#L259
@@ -31,7 +30,7 @@ func ParseHashPage(data []byte) (*HashPage, error) {
 	return &hashPage, nil
 }
 
-func HashPageValueContent(db *os.File, pageData []byte, hashPageIndex uint16, pageSize uint32) ([]byte, error) {
+func HashPageValueContent(db io.ReaderAt, pageData []byte, hashPageIndex uint16, pageSize uint32) ([]byte, error) {
 	// the first byte is the page type, so we can peek at it first before parsing further...
 	valuePageType := pageData[hashPageIndex]
 
@@ -47,17 +46,16 @@ func HashPageValueContent(db *os.File, pageData []byte, hashPageIndex uint16, pa
 		return nil, err
 	}
 
-	var hashValue []byte
-
-	for currentPageNo := entry.PageNo; currentPageNo != 0; {
-		pageStart := pageSize * currentPageNo
+	return OverflowPageContent(db, entry.PageNo, pageSize)
+}
 
-		_, err := db.Seek(int64(pageStart), io.SeekStart)
-		if err != nil {
-			return nil, fmt.Errorf("failed to seek to HashPageValueContent (page=%d): %w", currentPageNo, err)
-		}
+// OverflowPageContent concatenates the data of the chain of overflow pages starting at pageNo.
+func OverflowPageContent(db io.ReaderAt, pageNo uint32, pageSize uint32) ([]byte, error) {
+	var hashValue []byte
 
-		currentPageBuff, err := slice(db, int(pageSize))
+	currentPageBuff := make([]byte, pageSize)
+	for currentPageNo := pageNo; currentPageNo != 0; {
+		_, err := db.ReadAt(currentPageBuff, int64(pageSize)*int64(currentPageNo))
 		if err != nil {
 			return nil, fmt.Errorf("failed to read page=%d: %w", currentPageNo, err)
 		}
@@ -66,10 +64,16 @@ func HashPageValueContent(db *os.File, pageData []byte, hashPageIndex uint16, pa
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse page=%d: %w", currentPageNo, err)
 		}
+		if currentPage.PageType != OverflowPageType {
+			return nil, fmt.Errorf("unexpected page type: %+v (page=%d)", currentPage.PageType, currentPageNo)
+		}
 
 		var hashValueBytes []byte
 		if currentPage.NextPageNo == 0 {
 			// this is the last page, the whole page contains content
+			if int(PageHeaderSize)+int(currentPage.FreeAreaOffset) > len(currentPageBuff) {
+				return nil, fmt.Errorf("invalid overflow length: %d (page=%d)", currentPage.FreeAreaOffset, currentPageNo)
+			}
 			hashValueBytes = currentPageBuff[PageHeaderSize : PageHeaderSize+currentPage.FreeAreaOffset]
 		} else {
 			hashValueBytes = currentPageBuff[PageHeaderSize:]
@@ -106,14 +110,38 @@ func HashPageValueIndexes(data []byte, entries uint16) ([]uint16, error) {
 	return hashIndexValues, nil
 }
 
-func slice(reader io.Reader, n int) ([]byte, error) {
-	newBuff := make([]byte, n)
-	numRead, err := reader.Read(newBuff)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read page: %w", err)
+// HashPageKeyIndexes returns the in-page offsets of the keys of every key-value pair on the page.
+func HashPageKeyIndexes(data []byte, entries uint16) ([]uint16, error) {
+	var hashIndexKeys = make([]uint16, 0)
+	if entries%2 != 0 {
+		return nil, fmt.Errorf("invalid hash index: entries should only come in pairs (%+v)", entries)
+	}
+
+	hashIndexSize := entries * HashIndexEntrySize
+	hashIndexData := data[PageHeaderSize : PageHeaderSize+hashIndexSize]
+
+	const keyValuePairSize = 2 * HashIndexEntrySize
+	for idx := 0; idx < len(hashIndexData); idx += keyValuePairSize {
+		key := binary.LittleEndian.Uint16(hashIndexData[idx : idx+2])
+		hashIndexKeys = append(hashIndexKeys, key)
 	}
-	if numRead != n {
-		return nil, fmt.Errorf("short page size: %d!=%d", n, numRead)
+
+	return hashIndexKeys, nil
+}
+
+// HashPageKeyDataContent returns the H_KEYDATA item (a key or a small value) stored at the given in-page
+// offset. Items are laid out from the end of the page backwards, so the length of an item is bounded by
+// the item stored before it
+// (https://github.com/berkeleydb/libdb/blob/5b7b02ae052442626af54c176335b67ecc613a30/src/dbinc/hash.h#L168).
+func HashPageKeyDataContent(pageData []byte, hashPageIndex uint16, itemEnd uint32) ([]byte, error) {
+	if int(itemEnd) > len(pageData) || uint32(hashPageIndex) >= itemEnd {
+		return nil, fmt.Errorf("invalid item offset: %d (end=%d)", hashPageIndex, itemEnd)
 	}
-	return newBuff, nil
+
+	itemPageType := pageData[hashPageIndex]
+	if itemPageType != HashKeyDataPageType {
+		return nil, fmt.Errorf("only H_KEYDATA items supported (%+v)", itemPageType)
+	}
+
+	return pageData[hashPageIndex+1 : itemEnd], nil
 }
@@ -0,0 +1,132 @@
+package bdb
+
+import "fmt"
+
+// Stats describes how the pages of a hash database are used, to tell a Packages file bloated by
+// churn (free or leaked pages, long bucket chains) from one that is merely large.
+type Stats struct {
+	PageSize uint32
+	// Pages counts every page of the file, the metadata page included.
+	Pages uint32
+	// Buckets is the number of hash buckets and FillFactor the h_ffactor they were sized with.
+	Buckets    uint32
+	FillFactor uint32
+	// Records counts the key-value pairs of the hash pages.
+	Records int
+
+	HashPages int
+	// BucketOverflowPages are the hash pages chained to a full bucket.
+	BucketOverflowPages int
+	// HashPageFill is the part of the hash pages in use, between 0 and 1.
+	HashPageFill float64
+
+	// OverflowPages hold the values too large for the hash pages, OverflowChains is the number of
+	// such values and LongestChain the pages the largest one takes.
+	OverflowPages  int
+	OverflowChains int
+	LongestChain   int
+	// OrphanedOverflowPages are the overflow pages no value references, left by an interrupted
+	// write.
+	OrphanedOverflowPages int
+	// LargestEntry is the size of the largest value.
+	LargestEntry uint32
+
+	// FreePages are the pages on the free list, reused before the file grows.
+	FreePages int
+}
+
+// Stats walks every page of the database. It reads at explicit offsets and can run along a Read.
+func (db *BerkeleyDB) Stats() (*Stats, error) {
+	meta := db.HashMetadata
+	stats := &Stats{
+		PageSize:   meta.PageSize,
+		Pages:      meta.LastPageNo + 1,
+		Buckets:    meta.MaxBucket + 1,
+		FillFactor: meta.FillFactor,
+	}
+
+	// the data an overflow page holds
+	overflowCapacity := meta.PageSize - PageHeaderSize
+	var chainPages, hashBytes, hashUsed int
+	for pageNo := uint32(1); pageNo <= meta.LastPageNo; pageNo++ {
+		pageData, err := db.readPage(pageNo)
+		if err != nil {
+			return nil, err
+		}
+		page, err := ParseHashPage(pageData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse page=%d: %w", pageNo, err)
+		}
+
+		switch page.PageType {
+		case OverflowPageType:
+			stats.OverflowPages++
+		case HashPageType:
+			stats.HashPages++
+			if page.PreviousPageNo != 0 {
+				stats.BucketOverflowPages++
+			}
+			indexEnd := PageHeaderSize + int(page.NumEntries)*HashIndexEntrySize
+			if int(page.FreeAreaOffset) < indexEnd || int(page.FreeAreaOffset) > len(pageData) {
+				return nil, fmt.Errorf("invalid free area offset: %d (page=%d)", page.FreeAreaOffset, pageNo)
+			}
+			hashBytes += len(pageData) - PageHeaderSize
+			hashUsed += len(pageData) - int(page.FreeAreaOffset) + int(page.NumEntries)*HashIndexEntrySize
+
+			valueIndexes, err := HashPageValueIndexes(pageData, page.NumEntries)
+			if err != nil {
+				return nil, fmt.Errorf("page=%d: %w", pageNo, err)
+			}
+			stats.Records += len(valueIndexes)
+			for _, index := range valueIndexes {
+				if int(index)+HashOffPageSize > len(pageData) || pageData[index] != HashOffIndexPageType {
+					continue
+				}
+				entry, err := ParseHashOffPageEntry(pageData[index : index+HashOffPageSize])
+				if err != nil {
+					return nil, err
+				}
+				stats.OverflowChains++
+				pages := int((entry.Length + overflowCapacity - 1) / overflowCapacity)
+				chainPages += pages
+				if pages > stats.LongestChain {
+					stats.LongestChain = pages
+				}
+				if entry.Length > stats.LargestEntry {
+					stats.LargestEntry = entry.Length
+				}
+			}
+		}
+	}
+	if hashBytes > 0 {
+		stats.HashPageFill = float64(hashUsed) / float64(hashBytes)
+	}
+	if stats.OverflowPages > chainPages {
+		stats.OrphanedOverflowPages = stats.OverflowPages - chainPages
+	}
+
+	// the free list is chained through the next page numbers, a cycle would be a corrupt file
+	for pageNo := meta.Free; pageNo != 0; stats.FreePages++ {
+		if pageNo > meta.LastPageNo || stats.FreePages > int(meta.LastPageNo) {
+			return nil, fmt.Errorf("invalid free list page: %d", pageNo)
+		}
+		pageData, err := db.readPage(pageNo)
+		if err != nil {
+			return nil, err
+		}
+		page, err := ParseHashPage(pageData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse page=%d: %w", pageNo, err)
+		}
+		pageNo = page.NextPageNo
+	}
+	return stats, nil
+}
+
+func (db *BerkeleyDB) readPage(pageNo uint32) ([]byte, error) {
+	pageData := make([]byte, db.HashMetadata.PageSize)
+	if _, err := db.file.ReadAt(pageData, int64(pageNo)*int64(db.HashMetadata.PageSize)); err != nil {
+		return nil, fmt.Errorf("failed to read page=%d: %w", pageNo, err)
+	}
+	return pageData, nil
+}
@@ -0,0 +1,221 @@
+package bdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// the offset of the spares array in the hash metadata page
+// source: https://github.com/berkeleydb/libdb/blob/5b7b02ae052442626af54c176335b67ecc613a30/src/dbinc/db_page.h#L138
+const hashMetadataSparesOffset = 96
+
+// Writer creates a hash database readable by BerkeleyDB and libdb. All records are stored in a single
+// bucket (max_bucket=0) whose pages are chained together, which keeps the layout trivial while
+// remaining a valid hash database.
+type Writer struct {
+	file     *os.File
+	pageSize uint32
+
+	lastPageNo uint32
+	numKeys    uint32
+
+	// the hash page currently being filled (bucket 0 chain)
+	page       []byte
+	pageNo     uint32
+	numEntries uint16
+	freeOffset uint16
+}
+
+func Create(path string, pageSize uint32) (*Writer, error) {
+	if _, ok := validPageSizes[pageSize]; !ok {
+		return nil, fmt.Errorf("unexpected page size: %+v", pageSize)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Writer{
+		file:     file,
+		pageSize: pageSize,
+	}
+	// page 0 is the metadata page, page 1 is the first page of bucket 0
+	w.lastPageNo = 1
+	w.resetHashPage(1)
+
+	return w, nil
+}
+
+func (w *Writer) resetHashPage(pageNo uint32) {
+	w.page = make([]byte, w.pageSize)
+	w.pageNo = pageNo
+	w.numEntries = 0
+	w.freeOffset = uint16(w.pageSize)
+}
+
+func (w *Writer) allocPage() uint32 {
+	w.lastPageNo++
+	return w.lastPageNo
+}
+
+// Put appends a key-value pair to the database. Keys are not checked for uniqueness. Small values are
+// stored on the hash page itself, anything bigger than a quarter page goes to overflow pages like libdb does.
+func (w *Writer) Put(key, value []byte) error {
+	keyItemSize := 1 + len(key)
+	onPage := keyItemSize+1+len(value) <= int(w.pageSize)/4
+
+	valueItemSize := HashOffPageSize
+	if onPage {
+		valueItemSize = 1 + len(value)
+	}
+
+	pairSize := keyItemSize + valueItemSize + 2*HashIndexEntrySize
+	if pairSize > int(w.pageSize)-PageHeaderSize {
+		return fmt.Errorf("key too large: %d", len(key))
+	}
+
+	var firstPageNo uint32
+	if !onPage {
+		var err error
+		firstPageNo, err = w.writeOverflow(value)
+		if err != nil {
+			return err
+		}
+	}
+
+	indexEnd := PageHeaderSize + int(w.numEntries)*HashIndexEntrySize
+	if int(w.freeOffset)-indexEnd < pairSize {
+		nextPageNo := w.allocPage()
+		if err := w.flushHashPage(nextPageNo); err != nil {
+			return err
+		}
+		prevPageNo := w.pageNo
+		w.resetHashPage(nextPageNo)
+		binary.LittleEndian.PutUint32(w.page[12:16], prevPageNo)
+	}
+
+	// items are laid out from the end of the page backwards: the key first, then its value
+	keyOffset := w.freeOffset - uint16(keyItemSize)
+	w.page[keyOffset] = HashKeyDataPageType
+	copy(w.page[keyOffset+1:], key)
+
+	valueOffset := keyOffset - uint16(valueItemSize)
+	if onPage {
+		w.page[valueOffset] = HashKeyDataPageType
+		copy(w.page[valueOffset+1:], value)
+	} else {
+		w.page[valueOffset] = HashOffIndexPageType
+		binary.LittleEndian.PutUint32(w.page[valueOffset+4:], firstPageNo)
+		binary.LittleEndian.PutUint32(w.page[valueOffset+8:], uint32(len(value)))
+	}
+
+	indexStart := PageHeaderSize + int(w.numEntries)*HashIndexEntrySize
+	binary.LittleEndian.PutUint16(w.page[indexStart:], keyOffset)
+	binary.LittleEndian.PutUint16(w.page[indexStart+HashIndexEntrySize:], valueOffset)
+
+	w.numEntries += 2
+	w.freeOffset = valueOffset
+	w.numKeys++
+
+	return nil
+}
+
+// writeOverflow stores the value on a chain of overflow pages and returns the first page number.
+func (w *Writer) writeOverflow(value []byte) (uint32, error) {
+	capacity := int(w.pageSize) - PageHeaderSize
+	numPages := (len(value) + capacity - 1) / capacity
+	if numPages == 0 {
+		numPages = 1
+	}
+
+	firstPageNo := w.lastPageNo + 1
+	for i := 0; i < numPages; i++ {
+		pageNo := w.allocPage()
+		chunk := value[i*capacity:]
+		if len(chunk) > capacity {
+			chunk = chunk[:capacity]
+		}
+
+		var prevPageNo, nextPageNo uint32
+		if i > 0 {
+			prevPageNo = pageNo - 1
+		}
+		if i < numPages-1 {
+			nextPageNo = pageNo + 1
+		}
+
+		page := make([]byte, w.pageSize)
+		putPageHeader(page, pageNo, prevPageNo, nextPageNo, 1, uint16(len(chunk)), OverflowPageType)
+		copy(page[PageHeaderSize:], chunk)
+
+		if err := w.writePage(pageNo, page); err != nil {
+			return 0, err
+		}
+	}
+
+	return firstPageNo, nil
+}
+
+func (w *Writer) flushHashPage(nextPageNo uint32) error {
+	prevPageNo := binary.LittleEndian.Uint32(w.page[12:16])
+	putPageHeader(w.page, w.pageNo, prevPageNo, nextPageNo, w.numEntries, w.freeOffset, HashPageType)
+	return w.writePage(w.pageNo, w.page)
+}
+
+func (w *Writer) writePage(pageNo uint32, page []byte) error {
+	_, err := w.file.WriteAt(page, int64(pageNo)*int64(w.pageSize))
+	if err != nil {
+		return fmt.Errorf("failed to write page=%d: %w", pageNo, err)
+	}
+	return nil
+}
+
+// Close flushes the pending hash page, writes the metadata page and closes the file.
+func (w *Writer) Close() error {
+	if err := w.flushHashPage(0); err != nil {
+		w.file.Close()
+		return err
+	}
+
+	meta := make([]byte, w.pageSize)
+	binary.LittleEndian.PutUint32(meta[12:], HashMagicNumber)
+	binary.LittleEndian.PutUint32(meta[16:], HashMetadataVersion)
+	binary.LittleEndian.PutUint32(meta[20:], w.pageSize)
+	meta[25] = HashMetadataPageType
+	binary.LittleEndian.PutUint32(meta[32:], w.lastPageNo)
+	// max_bucket, high_mask and low_mask are all zero: a single bucket
+	binary.LittleEndian.PutUint32(meta[88:], w.numKeys)
+	binary.LittleEndian.PutUint32(meta[92:], hashFunc(charKey))
+	// bucket 0 lives on page 1
+	binary.LittleEndian.PutUint32(meta[hashMetadataSparesOffset:], 1)
+
+	if err := w.writePage(0, meta); err != nil {
+		w.file.Close()
+		return err
+	}
+
+	return w.file.Close()
+}
+
+func putPageHeader(page []byte, pageNo, prevPageNo, nextPageNo uint32, numEntries, freeAreaOffset uint16, pageType PageType) {
+	binary.LittleEndian.PutUint32(page[8:], pageNo)
+	binary.LittleEndian.PutUint32(page[12:], prevPageNo)
+	binary.LittleEndian.PutUint32(page[16:], nextPageNo)
+	binary.LittleEndian.PutUint16(page[20:], numEntries)
+	binary.LittleEndian.PutUint16(page[22:], freeAreaOffset)
+	page[24] = 0
+	page[25] = pageType
+}
+
+// the default libdb hash function (__ham_func5, FNV-1)
+// source: https://github.com/berkeleydb/libdb/blob/5b7b02ae052442626af54c176335b67ecc613a30/src/hash/hash_func.c#L207
+func hashFunc(key []byte) uint32 {
+	var h uint32
+	for _, b := range key {
+		h *= 16777619
+		h ^= uint32(b)
+	}
+	return h
+}
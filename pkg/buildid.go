@@ -0,0 +1,217 @@
+package rpmdb
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// buildIDDir is where rpmbuild >= 4.14 packages a symlink named after the build-id of every ELF
+// file, /usr/lib/.build-id/ab/cdef... -> ../../../../usr/bin/foo.
+const buildIDDir = "/usr/lib/.build-id/"
+
+// BuildIDFile is an ELF file of a package having a GNU build-id.
+type BuildIDFile struct {
+	Path    string
+	Package *PackageInfo
+}
+
+// BuildIDOptions configures BuildIDs.
+type BuildIDOptions struct {
+	// Root is the root filesystem the database belongs to, the ELF files of the packages being read
+	// there. When empty only the build-id links packaged with the files are used.
+	Root string
+	// VerifyDigests skips the files of Root whose digest isn't the one recorded, rebuilt or
+	// replaced since they were installed: their build-id isn't the one of the package.
+	VerifyDigests bool
+}
+
+// BuildIDs maps the hex GNU build-ids of the ELF files of the packages to the files, so that crash
+// dumps and profiles can be symbolized with the right debuginfo packages offline. A build-id has
+// several files when packages ship the same binary.
+func (d *RpmDB) BuildIDs(opts *BuildIDOptions) (map[string][]BuildIDFile, error) {
+	if opts == nil {
+		opts = &BuildIDOptions{}
+	}
+	ids := make(map[string][]BuildIDFile)
+	add := func(id string, file BuildIDFile) {
+		for _, f := range ids[id] {
+			if f == file {
+				return
+			}
+		}
+		ids[id] = append(ids[id], file)
+	}
+
+	err := d.forEachPackage(func(hnum uint32, indexEntries []indexEntry, pkg *PackageInfo) error {
+		files, err := fileInfos(indexEntries)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pkg.NEVRA(), err)
+		}
+		algo, err := digestAlgoTag(indexEntries, RPMTAG_FILEDIGESTALGO, PGPHASHALGO_MD5)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pkg.NEVRA(), err)
+		}
+
+		// the packages built since file colors exist only have ELF files among the colored ones
+		colored := false
+		for _, file := range files {
+			if id, target, ok := buildIDLink(file); ok {
+				add(id, BuildIDFile{Path: target, Package: pkg})
+			}
+			colored = colored || file.Color != RPMFC_BLACK
+		}
+		if opts.Root == "" {
+			return nil
+		}
+
+		for _, file := range files {
+			if uint32(file.Mode)&modeTypeMask != modeRegular || file.Flags&RPMFILE_GHOST != 0 ||
+				(colored && file.Color == RPMFC_BLACK) || file.State != RPMFILE_STATE_NORMAL {
+				continue
+			}
+			id, err := readBuildID(opts.Root, file, algo, opts.VerifyDigests)
+			if err != nil {
+				return fmt.Errorf("%s: %w", file.Path, err)
+			}
+			if id != "" {
+				add(id, BuildIDFile{Path: file.Path, Package: pkg})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// buildIDLink returns the build-id a packaged build-id link names and the file it links to. The
+// links to the .debug files of debuginfo packages are left out.
+func buildIDLink(file FileInfo) (id, target string, ok bool) {
+	if uint32(file.Mode)&modeTypeMask != modeSymlink || !strings.HasPrefix(file.Path, buildIDDir) ||
+		strings.HasSuffix(file.Path, ".debug") {
+		return "", "", false
+	}
+	dir, name := path.Split(strings.TrimPrefix(file.Path, buildIDDir))
+	// the links of files sharing a build-id are numbered, "cdef....1"
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		name = name[:i]
+	}
+	id = strings.TrimSuffix(dir, "/") + name
+	if len(dir) != 3 || !isHex(id) {
+		return "", "", false
+	}
+	target = file.LinkTo
+	if !strings.HasPrefix(target, "/") {
+		target = path.Join(path.Dir(file.Path), target)
+	}
+	return id, target, true
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil && s != ""
+}
+
+// readBuildID returns the build-id of the file in root, "" for the files that aren't ELF or have
+// none, the missing ones and the ones whose digest doesn't match when verify is set.
+func readBuildID(root string, file FileInfo, algo DigestAlgo, verify bool) (string, error) {
+	host, err := resolveInRoot(root, file.Path, true)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(host)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	magic := make([]byte, len(elf.ELFMAG))
+	if _, err := f.ReadAt(magic, 0); err != nil || string(magic) != elf.ELFMAG {
+		return "", nil
+	}
+	if verify && file.Digest != "" {
+		h, err := NewDigest(algo)
+		if err != nil {
+			return "", err
+		}
+		if _, err := io.Copy(h, f); err != nil {
+			return "", err
+		}
+		if hex.EncodeToString(h.Sum(nil)) != file.Digest {
+			return "", nil
+		}
+	}
+
+	ef, err := elf.NewFile(f)
+	if err != nil {
+		// a truncated or foreign ELF file
+		return "", nil
+	}
+	return elfBuildID(ef), nil
+}
+
+// elfBuildID returns the hex NT_GNU_BUILD_ID note of the program headers, the ones of the
+// sections when the program headers have none.
+// ref. https://refspecs.linuxbase.org/elf/gabi4+/ch5.pheader.html#note_section
+func elfBuildID(ef *elf.File) string {
+	for _, prog := range ef.Progs {
+		if prog.Type != elf.PT_NOTE || prog.Filesz > maxNotesSize {
+			continue
+		}
+		notes := make([]byte, prog.Filesz)
+		if _, err := prog.ReadAt(notes, 0); err != nil {
+			continue
+		}
+		if id := noteBuildID(notes, ef.ByteOrder); id != "" {
+			return id
+		}
+	}
+	for _, section := range ef.Sections {
+		if section.Type != elf.SHT_NOTE || section.Size > maxNotesSize {
+			continue
+		}
+		notes, err := section.Data()
+		if err != nil {
+			continue
+		}
+		if id := noteBuildID(notes, ef.ByteOrder); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+const (
+	ntGNUBuildID = 3
+	// the notes of a binary are a few dozens of bytes, the larger ones aren't read
+	maxNotesSize = 1 << 20
+)
+
+func noteBuildID(notes []byte, order binary.ByteOrder) string {
+	align := func(n uint32) uint64 { return (uint64(n) + 3) &^ 3 }
+	for len(notes) >= 12 {
+		nameSize, descSize, typ := order.Uint32(notes), order.Uint32(notes[4:]), order.Uint32(notes[8:])
+		notes = notes[12:]
+		if align(nameSize)+align(descSize) > uint64(len(notes)) {
+			return ""
+		}
+		name := notes[:nameSize]
+		desc := notes[align(nameSize) : align(nameSize)+uint64(descSize)]
+		notes = notes[align(nameSize)+align(descSize):]
+		if typ == ntGNUBuildID && bytes.Equal(name, []byte("GNU\x00")) && len(desc) > 0 {
+			return hex.EncodeToString(desc)
+		}
+	}
+	return ""
+}
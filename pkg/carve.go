@@ -0,0 +1,167 @@
+package rpmdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// CarvedHeader is a header found by CarveHeaders.
+type CarvedHeader struct {
+	// Offset is where the header starts in the input, at its magic when it has one, Size its
+	// length.
+	Offset int64
+	Size   int64
+	// Magic is set for the headers starting with the magic of rpm files, the headers of the
+	// databases have none.
+	Magic bool
+	// Package has the fields of the header. Partial is set for a header that doesn't decode as a
+	// whole, cut off by the end of the input or damaged, Package having the fields of the entries
+	// recovered and Err telling why.
+	Package *PackageInfo
+	Partial bool
+	Err     error
+}
+
+// the first index entry of the headers of a database, the HEADER_IMMUTABLE region entry of type
+// RPM_BIN_TYPE, whose count of 16 follows its offset
+var regionEntryPrefix = []byte{0, 0, 0, 0x3f, 0, 0, 0, 0x07}
+
+const (
+	carveChunkSize = 1 << 20
+	// the length of the longest pattern: the region entry, count included
+	carveOverlap = 16
+)
+
+// CarveHeaders searches the size bytes of r, a raw disk image, a memory dump or any binary blob,
+// for rpm headers, calling fn with each one that decodes to a package with a name in the order of
+// their offsets. The candidates are the headers magic of rpm files (skipping the signature headers,
+// which have no name) and the immutable region entry starting the headers rpm writes in its
+// databases; their lengths and index are checked against rpm's limits before the data is read.
+// Only the headers stored contiguously are found: the ones of rpm files, of ndb Packages.db blobs,
+// of the memory of a process, not the ones a Berkeley DB or SQLite database splits over overflow
+// pages past the first. The headers are decoded like SalvagePackages does for a damaged one.
+func CarveHeaders(r io.ReaderAt, size int64, fn func(header *CarvedHeader) error) error {
+	buf := make([]byte, carveChunkSize+carveOverlap)
+	// the matches inside a header found are part of it
+	next := int64(0)
+	for base := int64(0); base < size; base += carveChunkSize {
+		length := int64(len(buf))
+		if base+length > size {
+			length = size - base
+		}
+		n, err := r.ReadAt(buf[:length], base)
+		if err != nil && !(err == io.EOF && int64(n) == length) {
+			return err
+		}
+		chunk := buf[:n]
+
+		magic := bytes.Index(chunk, headerMagic)
+		region := bytes.Index(chunk, regionEntryPrefix)
+		for {
+			var start int64
+			var hasMagic bool
+			var i int
+			switch {
+			case magic >= 0 && (region < 0 || magic <= region):
+				i, start, hasMagic = magic, base+int64(magic)+int64(len(headerMagic)), true
+			case region >= 0:
+				i, start = region, base+int64(region)-8
+			default:
+				i = len(chunk)
+			}
+			if i >= carveChunkSize || i >= len(chunk) {
+				break
+			}
+
+			if start >= next && start >= 0 && (hasMagic || regionEntry(chunk[i:])) {
+				header, end := carveHeader(r, size, start, hasMagic)
+				if header != nil {
+					if err := fn(header); err != nil {
+						return err
+					}
+					next = end
+				}
+			}
+			if hasMagic {
+				magic = nextIndex(chunk, magic, headerMagic)
+			} else {
+				region = nextIndex(chunk, region, regionEntryPrefix)
+			}
+		}
+	}
+	return nil
+}
+
+// nextIndex returns the index of the next occurrence of pattern in chunk after the one at i.
+func nextIndex(chunk []byte, i int, pattern []byte) int {
+	j := bytes.Index(chunk[i+1:], pattern)
+	if j < 0 {
+		return -1
+	}
+	return i + 1 + j
+}
+
+// regionEntry tells the region entries from the bytes merely starting like one, by their count.
+func regionEntry(entry []byte) bool {
+	return len(entry) >= 16 && binary.BigEndian.Uint32(entry[12:]) == regionTagCount
+}
+
+// carveHeader decodes the header whose index starts at start, returning it and where it ends, nil
+// for lengths or index entries out of rpm's limits and for headers without name.
+func carveHeader(r io.ReaderAt, size, start int64, hasMagic bool) (*CarvedHeader, int64) {
+	lengths := make([]byte, 8)
+	if _, err := r.ReadAt(lengths, start); err != nil {
+		return nil, 0
+	}
+	il := binary.BigEndian.Uint32(lengths)
+	dl := binary.BigEndian.Uint32(lengths[4:])
+	if il < 1 || il > maxHeaderTags || dl > maxHeaderData {
+		return nil, 0
+	}
+	end := start + 8 + int64(il)*16 + int64(dl)
+	if end > size {
+		end = size
+	}
+	// the index is checked before reading data lengths of up to 256MB for a random match
+	index := make([]byte, int64(il)*16)
+	if start+8+int64(len(index)) > size {
+		index = index[:(size-start-8)/16*16]
+	}
+	if n, err := r.ReadAt(index, start+8); err != nil && !(err == io.EOF && n == len(index)) {
+		return nil, 0
+	}
+	for i := 0; i < len(index); i += 16 {
+		typ := TAG_TYPE(binary.BigEndian.Uint32(index[i+4:]))
+		offset := int32(binary.BigEndian.Uint32(index[i+8:]))
+		if typ > RPM_I18NSTRING_TYPE || offset < 0 || int64(offset) > int64(dl) {
+			return nil, 0
+		}
+	}
+	blob := make([]byte, end-start)
+	if n, err := r.ReadAt(blob, start); err != nil && !(err == io.EOF && n == len(blob)) {
+		return nil, 0
+	}
+
+	header := &CarvedHeader{Offset: start, Size: end - start, Magic: hasMagic}
+	if hasMagic {
+		header.Offset -= int64(len(headerMagic))
+		header.Size += int64(len(headerMagic))
+	}
+	indexEntries, err := headerImport(blob)
+	if err != nil {
+		header.Partial, header.Err = true, err
+		if blob, _, _ = salvageHeader(blob); blob == nil {
+			return nil, 0
+		}
+		if indexEntries, err = headerImport(blob); err != nil {
+			return nil, 0
+		}
+	}
+	pkg, err := getNEVRA(indexEntries)
+	if err != nil || pkg.Name == "" {
+		return nil, 0
+	}
+	header.Package = pkg
+	return header, end
+}
@@ -0,0 +1,135 @@
+package rpmdb
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// the changelog tags, an entry per change since the package was first built: the text of CentOS
+// kernels alone is megabytes
+var changelogTags = map[TAG_ID]bool{
+	RPMTAG_CHANGELOGTIME: true,
+	RPMTAG_CHANGELOGNAME: true,
+	RPMTAG_CHANGELOGTEXT: true,
+}
+
+// WithoutChangelogs leaves the changelogs out of the headers decoded by the listings, like
+// WithoutFiles does with the file lists: the changelogs are most of the headers of the
+// distributions, see ProfileHeaders. ListPackagesWithTags then has no changelog tags.
+func WithoutChangelogs() Option {
+	return func(d *RpmDB) {
+		d.skip(changelogTags)
+	}
+}
+
+// ChangelogPolicy is how the distribution trims the changelogs at build time, rpmbuild dropping
+// the entries older than %_changelog_trimtime, or older than %_changelog_trimage before the build
+// since rpm 4.17.
+type ChangelogPolicy struct {
+	// TrimTime is the %_changelog_trimtime of the builds, zero when unset.
+	TrimTime time.Time
+	// TrimAge is the %_changelog_trimage of the builds, zero when unset.
+	TrimAge time.Duration
+}
+
+// ChangelogSpan is the period the changelog of a package covers.
+type ChangelogSpan struct {
+	Package *PackageInfo
+	Entries int
+	// Earliest and Latest are the dates of the oldest and newest entries, zero without changelog.
+	// rpm keeps the day of an entry, at noon UTC.
+	Earliest time.Time
+	Latest   time.Time
+	// Initial tells that the oldest entry is the first build or import of the package.
+	Initial bool
+	// Trimmed tells that entries older than Earliest were dropped: the oldest entry says so, as
+	// the RHEL kernels do, or it isn't initial and follows the cutoff of the policy.
+	Trimmed bool
+}
+
+// Covers tells whether the changelog records the changes made at t: a trimmed changelog only
+// covers the time since its oldest entry, and a change missing from it before is no evidence
+// that a fix wasn't backported.
+func (s *ChangelogSpan) Covers(t time.Time) bool {
+	return s.Entries > 0 && (!s.Trimmed || !t.Before(s.Earliest))
+}
+
+var (
+	initialChangelogRe = regexp.MustCompile(`(?i)\b(initial (package|packaging|build|import|release|version|rpm|spec)|first (build|package|release)|new package)`)
+	trimmedChangelogRe = regexp.MustCompile(`(?i)\b(trimmed|truncated|earlier (history|changelog|changes|entries)|older (history|changelog|changes|entries))\b`)
+)
+
+// ChangelogSpans lists the period the changelog of every package covers in database order, the
+// gpg-pubkey entries of the imported keys, which have none, being left out. policy tells the
+// trimming of the builds, only the oldest entries telling it when nil.
+func (d *RpmDB) ChangelogSpans(policy *ChangelogPolicy) ([]*ChangelogSpan, error) {
+	if policy == nil {
+		policy = &ChangelogPolicy{}
+	}
+	var spans []*ChangelogSpan
+	err := d.forEachPackage(func(hnum uint32, indexEntries []indexEntry, pkg *PackageInfo) error {
+		if pkg.Name == "gpg-pubkey" {
+			return nil
+		}
+		span, err := changelogSpan(indexEntries, pkg, policy)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pkg.NEVRA(), err)
+		}
+		spans = append(spans, span)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return spans, nil
+}
+
+func changelogSpan(indexEntries []indexEntry, pkg *PackageInfo, policy *ChangelogPolicy) (*ChangelogSpan, error) {
+	span := &ChangelogSpan{Package: pkg}
+	times, err := tagUint32s(indexEntries, RPMTAG_CHANGELOGTIME)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag %v: %w", RPMTAG_CHANGELOGTIME, err)
+	}
+	if len(times) == 0 {
+		return span, nil
+	}
+	texts, err := tagStrings(indexEntries, RPMTAG_CHANGELOGTEXT)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag %v: %w", RPMTAG_CHANGELOGTEXT, err)
+	}
+
+	// newest first, but the order isn't enforced by rpmbuild: the last of the oldest entries is the
+	// first of the package
+	oldest := 0
+	span.Entries = len(times)
+	span.Earliest, span.Latest = unixTime(uint64(times[0])), unixTime(uint64(times[0]))
+	for i, t := range times {
+		switch tm := unixTime(uint64(t)); {
+		case !tm.After(span.Earliest):
+			span.Earliest, oldest = tm, i
+		case tm.After(span.Latest):
+			span.Latest = tm
+		}
+	}
+
+	var text string
+	if oldest < len(texts) {
+		text = texts[oldest]
+	}
+	span.Initial = initialChangelogRe.MatchString(text)
+	if trimmedChangelogRe.MatchString(text) {
+		span.Trimmed, span.Initial = true, false
+		return span, nil
+	}
+
+	cutoff := policy.TrimTime
+	if policy.TrimAge > 0 && !pkg.BuildTime.IsZero() {
+		if age := pkg.BuildTime.Add(-policy.TrimAge); age.After(cutoff) {
+			cutoff = age
+		}
+	}
+	// the entries are dated at noon, the ones of the day of the cutoff may have been kept
+	span.Trimmed = !span.Initial && !cutoff.IsZero() && span.Earliest.After(cutoff.Add(-24*time.Hour))
+	return span, nil
+}
@@ -0,0 +1,102 @@
+package rpmdb
+
+import (
+	"fmt"
+	"sort"
+)
+
+// preferredColor is rpm's %_prefer_color: the 64-bit file wins the conflicts of multilib twins.
+const preferredColor = RPMFC_ELF64
+
+// ColoredFile is the file a package installs at a path shared with packages of other colors.
+type ColoredFile struct {
+	Package *PackageInfo
+	Color   FileColor
+	State   FileState
+}
+
+// MultilibFile is a path where packages install ELF files of different classes, the /usr/bin
+// binaries of the i686 and x86_64 builds of a package for instance. rpm doesn't report these
+// conflicts: only one of the files is on disk, the others being in the RPMFILE_STATE_WRONGCOLOR
+// state.
+type MultilibFile struct {
+	Path string
+	// Files are sorted by NEVRA.
+	Files []ColoredFile
+	// Owner is the package whose file is installed: the one not recorded with the wrong color, the
+	// preferred color breaking ties as in rpm.
+	Owner *PackageInfo
+}
+
+// MultilibFiles lists the paths the packages install files of different colors at, sorted. The
+// files without color, the documentation and data the twins share, are left out: rpm keeps them
+// only when they are identical.
+// ref. https://github.com/rpm-software-management/rpm/blob/rpm-4.11.3-release/lib/transaction.c#L256
+func (d *RpmDB) MultilibFiles() ([]*MultilibFile, error) {
+	byPath := make(map[string]*MultilibFile)
+	err := d.forEachPackage(func(hnum uint32, indexEntries []indexEntry, pkg *PackageInfo) error {
+		files, err := fileInfos(indexEntries)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pkg.NEVRA(), err)
+		}
+		for _, file := range files {
+			if file.Color == RPMFC_BLACK {
+				continue
+			}
+			m, ok := byPath[file.Path]
+			if !ok {
+				m = &MultilibFile{Path: file.Path}
+				byPath[file.Path] = m
+			}
+			m.Files = append(m.Files, ColoredFile{Package: pkg, Color: file.Color, State: file.State})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var multilib []*MultilibFile
+	for _, m := range byPath {
+		if !mixedColors(m.Files) {
+			continue
+		}
+		sort.SliceStable(m.Files, func(i, j int) bool {
+			return m.Files[i].Package.NEVRA() < m.Files[j].Package.NEVRA()
+		})
+		m.Owner = colorOwner(m.Files)
+		multilib = append(multilib, m)
+	}
+	sort.Slice(multilib, func(i, j int) bool {
+		return multilib[i].Path < multilib[j].Path
+	})
+	return multilib, nil
+}
+
+func mixedColors(files []ColoredFile) bool {
+	for _, file := range files[1:] {
+		if file.Color != files[0].Color {
+			return true
+		}
+	}
+	return false
+}
+
+// colorOwner returns the package of the file installed at the path, see MultilibFile.Owner.
+func colorOwner(files []ColoredFile) *PackageInfo {
+	var candidates []ColoredFile
+	for _, file := range files {
+		if file.State != RPMFILE_STATE_WRONGCOLOR {
+			candidates = append(candidates, file)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = files
+	}
+	for _, file := range candidates {
+		if file.Color&preferredColor != 0 {
+			return file.Package
+		}
+	}
+	return candidates[0].Package
+}
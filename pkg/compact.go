@@ -0,0 +1,309 @@
+package rpmdb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// compactField is a field of the compact encodings of a package, numbered as in inventory.proto
+// and named as the msgpack tags of PackageInfo.
+type compactField struct {
+	number uint64
+	name   string
+	str    func(p *PackageInfo) string
+	num    func(p *PackageInfo) uint64
+	// setStr and setNum set the field of a decoded package
+	setStr func(p *PackageInfo, s string)
+	setNum func(p *PackageInfo, v uint64)
+}
+
+var compactFields = []compactField{
+	{number: 1, name: "name", str: func(p *PackageInfo) string { return p.Name },
+		setStr: func(p *PackageInfo, s string) { p.Name = s }},
+	{number: 2, name: "epoch", num: func(p *PackageInfo) uint64 { return uint64(p.Epoch) },
+		setNum: func(p *PackageInfo, v uint64) { p.Epoch = int(v) }},
+	{number: 3, name: "version", str: func(p *PackageInfo) string { return p.Version },
+		setStr: func(p *PackageInfo, s string) { p.Version = s }},
+	{number: 4, name: "release", str: func(p *PackageInfo) string { return p.Release },
+		setStr: func(p *PackageInfo, s string) { p.Release = s }},
+	{number: 5, name: "arch", str: func(p *PackageInfo) string { return p.Arch },
+		setStr: func(p *PackageInfo, s string) { p.Arch = s }},
+	{number: 6, name: "source_rpm", str: func(p *PackageInfo) string { return p.SourceRpm },
+		setStr: func(p *PackageInfo, s string) { p.SourceRpm = s }},
+	{number: 7, name: "size", num: func(p *PackageInfo) uint64 { return uint64(p.Size) },
+		setNum: func(p *PackageInfo, v uint64) { p.Size = int(v) }},
+	{number: 8, name: "license", str: func(p *PackageInfo) string { return p.License },
+		setStr: func(p *PackageInfo, s string) { p.License = s }},
+	{number: 9, name: "vendor", str: func(p *PackageInfo) string { return p.Vendor },
+		setStr: func(p *PackageInfo, s string) { p.Vendor = s }},
+	{number: 10, name: "url", str: func(p *PackageInfo) string { return p.URL },
+		setStr: func(p *PackageInfo, s string) { p.URL = s }},
+	{number: 11, name: "packager", str: func(p *PackageInfo) string { return p.Packager },
+		setStr: func(p *PackageInfo, s string) { p.Packager = s }},
+	{number: 12, name: "modularity_label", str: func(p *PackageInfo) string { return p.ModularityLabel },
+		setStr: func(p *PackageInfo, s string) { p.ModularityLabel = s }},
+	{number: 13, name: "signed_by", str: func(p *PackageInfo) string {
+		if p.SignedBy == nil {
+			return ""
+		}
+		return p.SignedBy.KeyID
+	}, setStr: func(p *PackageInfo, s string) { p.SignedBy = &PubKeyRef{KeyID: s} }},
+	{number: 14, name: "install_reason", num: func(p *PackageInfo) uint64 { return uint64(p.InstallReason) },
+		setNum: func(p *PackageInfo, v uint64) { p.InstallReason = InstallReason(v) }},
+	{number: 15, name: "payload_digest", str: func(p *PackageInfo) string { return p.PayloadDigest },
+		setStr: func(p *PackageInfo, s string) { p.PayloadDigest = s }},
+	{number: 16, name: "payload_digest_algo", num: func(p *PackageInfo) uint64 { return uint64(p.PayloadDigestAlgo) },
+		setNum: func(p *PackageInfo, v uint64) { p.PayloadDigestAlgo = DigestAlgo(v) }},
+	{number: 17, name: "install_time", num: func(p *PackageInfo) uint64 { return unixSeconds(p.InstallTime) },
+		setNum: func(p *PackageInfo, v uint64) { p.InstallTime = unixTime(v) }},
+	{number: 18, name: "build_time", num: func(p *PackageInfo) uint64 { return unixSeconds(p.BuildTime) },
+		setNum: func(p *PackageInfo, v uint64) { p.BuildTime = unixTime(v) }},
+}
+
+// unixSeconds returns the seconds since the epoch of the time of a time_t tag, 0 for the zero time.
+func unixSeconds(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.Unix())
+}
+
+// WriteInventoryMsgpack encodes the packages as a MessagePack array of maps keyed by the msgpack
+// tags of PackageInfo, the empty fields left out. signed_by is the key ID of the signature, a
+// string, and the times are seconds since the epoch.
+// ref. https://github.com/msgpack/msgpack/blob/master/spec.md
+func WriteInventoryMsgpack(w io.Writer, pkgs []*PackageInfo) error {
+	e := &msgpackEncoder{w: bufio.NewWriter(w)}
+	e.writeHeader(len(pkgs), 0x90, 0xdc, 0xdd)
+	for _, pkg := range pkgs {
+		n := 0
+		for _, f := range compactFields {
+			if !f.empty(pkg) {
+				n++
+			}
+		}
+		e.writeHeader(n, 0x80, 0xde, 0xdf)
+		for _, f := range compactFields {
+			if f.empty(pkg) {
+				continue
+			}
+			e.writeString(f.name)
+			if f.str != nil {
+				e.writeString(f.str(pkg))
+			} else {
+				e.writeUint(f.num(pkg))
+			}
+		}
+	}
+	return e.w.Flush()
+}
+
+func (f *compactField) empty(p *PackageInfo) bool {
+	if f.str != nil {
+		return f.str(p) == ""
+	}
+	return f.num(p) == 0
+}
+
+// msgpackEncoder writes MessagePack values, the errors being reported by the Flush of w.
+type msgpackEncoder struct {
+	w   *bufio.Writer
+	buf [9]byte
+}
+
+// writeHeader writes the header of an array or a map of n elements, in its fix, 16 or 32 bits
+// form.
+func (e *msgpackEncoder) writeHeader(n int, fix, b16, b32 byte) {
+	switch {
+	case n < 16:
+		e.w.WriteByte(fix | byte(n))
+	case n <= math.MaxUint16:
+		e.buf[0] = b16
+		binary.BigEndian.PutUint16(e.buf[1:], uint16(n))
+		e.w.Write(e.buf[:3])
+	default:
+		e.buf[0] = b32
+		binary.BigEndian.PutUint32(e.buf[1:], uint32(n))
+		e.w.Write(e.buf[:5])
+	}
+}
+
+func (e *msgpackEncoder) writeString(s string) {
+	switch n := len(s); {
+	case n < 32:
+		e.w.WriteByte(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		e.w.Write([]byte{0xd9, byte(n)})
+	case n <= math.MaxUint16:
+		e.buf[0] = 0xda
+		binary.BigEndian.PutUint16(e.buf[1:], uint16(n))
+		e.w.Write(e.buf[:3])
+	default:
+		e.buf[0] = 0xdb
+		binary.BigEndian.PutUint32(e.buf[1:], uint32(n))
+		e.w.Write(e.buf[:5])
+	}
+	e.w.WriteString(s)
+}
+
+func (e *msgpackEncoder) writeUint(v uint64) {
+	switch {
+	case v < 128:
+		e.w.WriteByte(byte(v))
+	case v <= math.MaxUint8:
+		e.w.Write([]byte{0xcc, byte(v)})
+	case v <= math.MaxUint16:
+		e.buf[0] = 0xcd
+		binary.BigEndian.PutUint16(e.buf[1:], uint16(v))
+		e.w.Write(e.buf[:3])
+	case v <= math.MaxUint32:
+		e.buf[0] = 0xce
+		binary.BigEndian.PutUint32(e.buf[1:], uint32(v))
+		e.w.Write(e.buf[:5])
+	default:
+		e.buf[0] = 0xcf
+		binary.BigEndian.PutUint64(e.buf[1:], v)
+		e.w.Write(e.buf[:9])
+	}
+}
+
+// protobuf wire types
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+// MarshalInventoryProto encodes the packages as the Inventory message of inventory.proto, in the
+// protobuf wire format: the code generated from the definition decodes it, without this package
+// depending on the protobuf runtime. UnmarshalInventoryProto decodes it back.
+// ref. https://protobuf.dev/programming-guides/encoding/
+func MarshalInventoryProto(pkgs []*PackageInfo) []byte {
+	var inventory, pkgBuf []byte
+	for _, pkg := range pkgs {
+		pkgBuf = pkgBuf[:0]
+		for _, f := range compactFields {
+			// proto3 leaves the default values out
+			if f.empty(pkg) {
+				continue
+			}
+			if f.str != nil {
+				pkgBuf = appendProtoBytes(pkgBuf, f.number, f.str(pkg))
+			} else {
+				pkgBuf = appendVarint(pkgBuf, f.number<<3|protoVarint)
+				pkgBuf = appendVarint(pkgBuf, f.num(pkg))
+			}
+		}
+		// Inventory.packages is field 1
+		inventory = appendProtoBytes(inventory, 1, string(pkgBuf))
+	}
+	return inventory
+}
+
+// WriteInventoryProto writes MarshalInventoryProto(pkgs) to w.
+func WriteInventoryProto(w io.Writer, pkgs []*PackageInfo) error {
+	_, err := w.Write(MarshalInventoryProto(pkgs))
+	return err
+}
+
+// UnmarshalInventoryProto decodes the Inventory message of inventory.proto into packages, the
+// fields of later versions of the definition being skipped. SignedBy only has its KeyID, and the
+// fields inventory.proto doesn't have are left empty.
+func UnmarshalInventoryProto(data []byte) ([]*PackageInfo, error) {
+	fields := make(map[uint64]*compactField, len(compactFields))
+	for i := range compactFields {
+		fields[compactFields[i].number] = &compactFields[i]
+	}
+
+	var pkgs []*PackageInfo
+	err := forEachProtoField(data, func(number, wireType, value uint64, payload []byte) error {
+		if number != 1 || wireType != protoBytes {
+			return nil
+		}
+		pkg := &PackageInfo{}
+		err := forEachProtoField(payload, func(number, wireType, value uint64, payload []byte) error {
+			f, ok := fields[number]
+			switch {
+			case !ok:
+			case f.str != nil && wireType == protoBytes:
+				f.setStr(pkg, string(payload))
+			case f.num != nil && wireType == protoVarint:
+				f.setNum(pkg, value)
+			default:
+				return fmt.Errorf("field %s: unexpected wire type %d", f.name, wireType)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("package %d: %w", len(pkgs), err)
+		}
+		pkgs = append(pkgs, pkg)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pkgs, nil
+}
+
+// forEachProtoField calls fn with the number, the wire type and the value of every field of a
+// message, value holding the varints, payload the length-delimited fields.
+func forEachProtoField(data []byte, fn func(number, wireType, value uint64, payload []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errors.New("invalid protobuf field key")
+		}
+		data = data[n:]
+		number, wireType := key>>3, key&7
+		var value uint64
+		var payload []byte
+		switch wireType {
+		case protoVarint:
+			value, n = binary.Uvarint(data)
+			if n <= 0 {
+				return fmt.Errorf("field %d: invalid varint", number)
+			}
+			data = data[n:]
+		case protoFixed64, protoFixed32:
+			size := 8
+			if wireType == protoFixed32 {
+				size = 4
+			}
+			if len(data) < size {
+				return fmt.Errorf("field %d: truncated", number)
+			}
+			data = data[size:]
+		case protoBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || size > uint64(len(data)-n) {
+				return fmt.Errorf("field %d: truncated", number)
+			}
+			payload, data = data[n:n+int(size)], data[n+int(size):]
+		default:
+			return fmt.Errorf("field %d: unsupported wire type %d", number, wireType)
+		}
+		if err := fn(number, wireType, value, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendProtoBytes(b []byte, number uint64, s string) []byte {
+	b = appendVarint(b, number<<3|protoBytes)
+	b = appendVarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
@@ -0,0 +1,229 @@
+package rpmdb
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// types of the packages of a comps group
+const (
+	CompsMandatory   = "mandatory"
+	CompsDefault     = "default"
+	CompsOptional    = "optional"
+	CompsConditional = "conditional"
+)
+
+// Comps is the group definitions of a repository, comps.xml.
+// ref. https://github.com/rpm-software-management/libcomps/blob/master/libcomps/src/comps_doc.h
+type Comps struct {
+	Groups       []*CompsGroup
+	Environments []*CompsEnvironment
+
+	groups map[string]*CompsGroup
+}
+
+// CompsGroup is a package group, "Core" or "GNOME" for instance.
+type CompsGroup struct {
+	ID   string
+	Name string
+	// Packages are the members of the group in the comps.xml order.
+	Packages []CompsPackage
+}
+
+// CompsPackage is a member of a group, Type is one of CompsMandatory, CompsDefault, CompsOptional
+// or CompsConditional.
+type CompsPackage struct {
+	Name string
+	Type string
+}
+
+// CompsEnvironment is an environment group, the "Minimal Install" or "Server with GUI" choices of
+// the installer.
+type CompsEnvironment struct {
+	ID   string
+	Name string
+	// Groups are the ids of the groups the environment is made of.
+	Groups []string
+	// Options are the ids of the groups offered as add-ons.
+	Options []string
+}
+
+// GroupStatus tells how much of a group is installed.
+type GroupStatus struct {
+	Group *CompsGroup
+	// Installed are the installed members of the group of any type.
+	Installed []*PackageInfo
+	// Missing are the mandatory members not installed.
+	Missing []string
+}
+
+// Complete reports whether every mandatory member of the group is installed, which is how dnf
+// sees a group installed from a kickstart with default packages excluded.
+func (s *GroupStatus) Complete() bool {
+	return len(s.Missing) == 0
+}
+
+// EnvironmentStatus tells how much of an environment group is installed.
+type EnvironmentStatus struct {
+	Environment *CompsEnvironment
+	// Groups are the statuses of the groups of the environment, options excluded.
+	Groups []*GroupStatus
+	// Unknown are the group ids the environment lists without comps.xml defining them.
+	Unknown []string
+}
+
+// Complete reports whether every group of the environment is complete.
+func (s *EnvironmentStatus) Complete() bool {
+	if len(s.Unknown) > 0 {
+		return false
+	}
+	for _, group := range s.Groups {
+		if !group.Complete() {
+			return false
+		}
+	}
+	return true
+}
+
+type compsXML struct {
+	Groups []struct {
+		ID       string         `xml:"id"`
+		Names    []compsNameXML `xml:"name"`
+		Packages []struct {
+			Type string `xml:"type,attr"`
+			Name string `xml:",chardata"`
+		} `xml:"packagelist>packagereq"`
+	} `xml:"group"`
+	Environments []struct {
+		ID      string         `xml:"id"`
+		Names   []compsNameXML `xml:"name"`
+		Groups  []string       `xml:"grouplist>groupid"`
+		Options []string       `xml:"optionlist>groupid"`
+	} `xml:"environment"`
+}
+
+type compsNameXML struct {
+	Lang string `xml:"lang,attr"`
+	Name string `xml:",chardata"`
+}
+
+// ReadComps loads a comps.xml file, optionally compressed with gzip or bzip2.
+func ReadComps(path string) (*Comps, error) {
+	data, err := readRepoFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read comps: %w", err)
+	}
+	return ParseComps(bytes.NewReader(data))
+}
+
+// ParseComps parses comps.xml, the group names are the untranslated ones.
+func ParseComps(r io.Reader) (*Comps, error) {
+	var doc compsXML
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid comps: %w", err)
+	}
+
+	c := &Comps{groups: make(map[string]*CompsGroup)}
+	for _, g := range doc.Groups {
+		group := &CompsGroup{ID: g.ID, Name: compsName(g.Names)}
+		for _, p := range g.Packages {
+			typ := p.Type
+			if typ == "" {
+				typ = CompsMandatory
+			}
+			group.Packages = append(group.Packages, CompsPackage{Name: p.Name, Type: typ})
+		}
+		c.Groups = append(c.Groups, group)
+		c.groups[group.ID] = group
+	}
+	for _, e := range doc.Environments {
+		c.Environments = append(c.Environments, &CompsEnvironment{
+			ID:      e.ID,
+			Name:    compsName(e.Names),
+			Groups:  e.Groups,
+			Options: e.Options,
+		})
+	}
+	return c, nil
+}
+
+func compsName(names []compsNameXML) string {
+	for _, name := range names {
+		if name.Lang == "" {
+			return name.Name
+		}
+	}
+	return ""
+}
+
+// Group returns the group of the given id, nil when there is none.
+func (c *Comps) Group(id string) *CompsGroup {
+	return c.groups[id]
+}
+
+// PackageGroups maps the names of the installed packages to the ids of the groups listing them,
+// packages of no group are left out.
+func (c *Comps) PackageGroups(pkgList []*PackageInfo) map[string][]string {
+	installed := installedNames(pkgList)
+	groups := make(map[string][]string)
+	for _, group := range c.Groups {
+		for _, p := range group.Packages {
+			if _, ok := installed[p.Name]; ok {
+				groups[p.Name] = append(groups[p.Name], group.ID)
+			}
+		}
+	}
+	for _, ids := range groups {
+		sort.Strings(ids)
+	}
+	return groups
+}
+
+// ResolveGroup resolves how much of a group the installed packages cover.
+func (c *Comps) ResolveGroup(group *CompsGroup, pkgList []*PackageInfo) *GroupStatus {
+	return groupStatus(group, installedNames(pkgList))
+}
+
+// ResolveEnvironments resolves every environment group against the installed packages, the complete
+// ones are the candidates for the environment the system was installed with.
+func (c *Comps) ResolveEnvironments(pkgList []*PackageInfo) []*EnvironmentStatus {
+	installed := installedNames(pkgList)
+	var statuses []*EnvironmentStatus
+	for _, env := range c.Environments {
+		status := &EnvironmentStatus{Environment: env}
+		for _, id := range env.Groups {
+			group := c.Group(id)
+			if group == nil {
+				status.Unknown = append(status.Unknown, id)
+				continue
+			}
+			status.Groups = append(status.Groups, groupStatus(group, installed))
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+func groupStatus(group *CompsGroup, installed map[string][]*PackageInfo) *GroupStatus {
+	status := &GroupStatus{Group: group}
+	for _, p := range group.Packages {
+		pkgs, ok := installed[p.Name]
+		if ok {
+			status.Installed = append(status.Installed, pkgs...)
+		} else if p.Type == CompsMandatory {
+			status.Missing = append(status.Missing, p.Name)
+		}
+	}
+	return status
+}
+
+func installedNames(pkgList []*PackageInfo) map[string][]*PackageInfo {
+	installed := make(map[string][]*PackageInfo)
+	for _, pkg := range pkgList {
+		installed[pkg.Name] = append(installed[pkg.Name], pkg)
+	}
+	return installed
+}
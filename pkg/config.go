@@ -0,0 +1,79 @@
+package rpmdb
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ConfigFile is a %config file of a package.
+type ConfigFile struct {
+	Package *PackageInfo
+	File    FileInfo
+	// Algo is the algorithm of File.Digest.
+	Algo DigestAlgo
+	// NoReplace is set for %config(noreplace): an upgrade keeps a modified file and installs the
+	// new one as .rpmnew instead of saving the modified one as .rpmsave.
+	NoReplace bool
+}
+
+// ConfigFiles lists the %config files of every package with their recorded digests, sorted by
+// path then NEVRA.
+func (d *RpmDB) ConfigFiles() ([]*ConfigFile, error) {
+	var configs []*ConfigFile
+	err := d.forEachPackage(func(hnum uint32, indexEntries []indexEntry, pkg *PackageInfo) error {
+		files, err := fileInfos(indexEntries)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pkg.NEVRA(), err)
+		}
+		algo, err := digestAlgoTag(indexEntries, RPMTAG_FILEDIGESTALGO, PGPHASHALGO_MD5)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pkg.NEVRA(), err)
+		}
+		for _, file := range files {
+			if file.Flags&RPMFILE_CONFIG == 0 {
+				continue
+			}
+			configs = append(configs, &ConfigFile{
+				Package:   pkg,
+				File:      file,
+				Algo:      algo,
+				NoReplace: file.Flags&RPMFILE_NOREPLACE != 0,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(configs, func(i, j int) bool {
+		if configs[i].File.Path != configs[j].File.Path {
+			return configs[i].File.Path < configs[j].File.Path
+		}
+		return configs[i].Package.NEVRA() < configs[j].Package.NEVRA()
+	})
+	return configs, nil
+}
+
+// ModifiedConfigFiles verifies the %config files only and returns the ones missing or differing
+// from their headers, leaving out those whose only result is an attribute that couldn't be
+// checked. The policy is used as in Verify, a nil one checks everything.
+func (d *RpmDB) ModifiedConfigFiles(policy *VerifyPolicy, names ...string) ([]*VerifyResult, error) {
+	configPolicy := VerifyPolicy{}
+	if policy != nil {
+		configPolicy = *policy
+	}
+	configPolicy.OnlyFlags = RPMFILE_CONFIG
+
+	results, err := d.Verify(&configPolicy, names...)
+	if err != nil {
+		return nil, err
+	}
+	var modified []*VerifyResult
+	for _, result := range results {
+		if result.Missing || result.Failed != 0 {
+			modified = append(modified, result)
+		}
+	}
+	return modified, nil
+}
@@ -0,0 +1,174 @@
+package rpmdb
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/chennqqi/go-rpmdb/pkg/ndb"
+)
+
+// conformanceBackends returns the databases of the headers of testdata/centos7-plain in every
+// format RpmDB reads, the Packages file itself along with the others: a new backend is added here to
+// be checked against the same contract.
+func conformanceBackends(t *testing.T) map[string]*RpmDB {
+	dir, err := ioutil.TempDir("", "conformance")
+	if err != nil {
+		t.Fatalf("TempDir() error: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	open := func(path string) *RpmDB {
+		db, err := Open(path)
+		if err != nil {
+			t.Fatalf("Open(%s) error: %v", path, err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+	source := open("testdata/centos7-plain/Packages")
+
+	// the ndb database has the same package indexes as the instance numbers
+	ndbPath := filepath.Join(dir, "Packages.db")
+	w, err := ndb.Create(ndbPath)
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	var dump bytes.Buffer
+	err = source.forEachHeader(func(hnum uint32, blob []byte) error {
+		return w.Put(hnum, blob)
+	})
+	if err != nil {
+		t.Fatalf("Put() error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+	if err := source.ExportDump(&dump); err != nil {
+		t.Fatalf("ExportDump() error: %v", err)
+	}
+	fromDump, err := OpenDump(&dump)
+	if err != nil {
+		t.Fatalf("OpenDump() error: %v", err)
+	}
+
+	return map[string]*RpmDB{
+		"bdb":    source,
+		"sqlite": open("testdata/sqlite-centos7/rpmdb.sqlite"),
+		"ndb":    open(ndbPath),
+		"dump":   fromDump,
+	}
+}
+
+func TestBackendConformance(t *testing.T) {
+	backends := conformanceBackends(t)
+	if fileBackend("testdata/sqlite-centos7/rpmdb.sqlite") != BackendSQLite {
+		t.Errorf("rpmdb.sqlite not told an sqlite database")
+	}
+
+	nevras := func(pkgs []*PackageInfo) []string {
+		list := make([]string, 0, len(pkgs))
+		for _, pkg := range pkgs {
+			list = append(list, pkg.NEVRA())
+		}
+		sort.Strings(list)
+		return list
+	}
+	files := []string{"/usr/bin/bash", "/etc/passwd", "/usr/share/doc", "/missing"}
+	capabilities := []string{"bash", "/bin/sh", "libc.so.6()(64bit)", "missing"}
+
+	// the results of every backend, compared with the ones of the Packages file
+	type results struct {
+		headers   map[uint32][sha256.Size]byte
+		packages  []*PackageInfo
+		names     []string
+		owners    map[string][]string
+		providers map[string][]string
+		requirers map[string][]string
+		withTags  []*PackageInfoEx
+	}
+	collect := func(name string, db *RpmDB) *results {
+		r := &results{
+			headers:   make(map[uint32][sha256.Size]byte),
+			owners:    make(map[string][]string),
+			providers: make(map[string][]string),
+			requirers: make(map[string][]string),
+		}
+		err := db.forEachHeader(func(hnum uint32, blob []byte) error {
+			r.headers[hnum] = sha256.Sum256(blob)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: headers error: %v", name, err)
+		}
+
+		db.sorted = true
+		if r.packages, err = db.ListPackages(); err != nil {
+			t.Fatalf("%s: ListPackages() error: %v", name, err)
+		}
+		if r.withTags, err = db.ListPackagesWithTags(RPMTAG_BASENAMES, RPMTAG_REQUIRENAME); err != nil {
+			t.Fatalf("%s: ListPackagesWithTags() error: %v", name, err)
+		}
+		db.sorted = false
+
+		if r.names, err = db.PackageNames(); err != nil {
+			t.Fatalf("%s: PackageNames() error: %v", name, err)
+		}
+		for _, file := range files {
+			pkgs, err := db.WhoOwns(file, nil)
+			if err != nil {
+				t.Fatalf("%s: WhoOwns(%s) error: %v", name, file, err)
+			}
+			r.owners[file] = nevras(pkgs)
+		}
+		for _, capability := range capabilities {
+			pkgs, err := db.WhatProvides(capability)
+			if err != nil {
+				t.Fatalf("%s: WhatProvides(%s) error: %v", name, capability, err)
+			}
+			r.providers[capability] = nevras(pkgs)
+			if pkgs, err = db.WhatRequires(capability); err != nil {
+				t.Fatalf("%s: WhatRequires(%s) error: %v", name, capability, err)
+			}
+			r.requirers[capability] = nevras(pkgs)
+		}
+		return r
+	}
+
+	want := collect("bdb", backends["bdb"])
+	if len(want.headers) != len(CentOS7Plain) || len(want.owners["/usr/bin/bash"]) != 1 {
+		t.Fatalf("unexpected fixture: %d headers", len(want.headers))
+	}
+	for name, db := range backends {
+		if name == "bdb" {
+			continue
+		}
+		got := collect(name, db)
+		if !reflect.DeepEqual(got.headers, want.headers) {
+			t.Errorf("%s: got %d headers, want %d", name, len(got.headers), len(want.headers))
+		}
+		if !reflect.DeepEqual(got.packages, want.packages) || !reflect.DeepEqual(got.withTags, want.withTags) {
+			t.Errorf("%s: got %d packages, want %d", name, len(got.packages), len(want.packages))
+		}
+		if !reflect.DeepEqual(got.names, want.names) {
+			t.Errorf("%s: PackageNames(): got %v, want %v", name, got.names, want.names)
+		}
+		for _, lookup := range []struct {
+			name      string
+			got, want map[string][]string
+		}{
+			{"WhoOwns", got.owners, want.owners},
+			{"WhatProvides", got.providers, want.providers},
+			{"WhatRequires", got.requirers, want.requirers},
+		} {
+			if !reflect.DeepEqual(lookup.got, lookup.want) {
+				t.Errorf("%s: %s: got %v, want %v", name, lookup.name, lookup.got, lookup.want)
+			}
+		}
+	}
+}
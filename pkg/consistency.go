@@ -0,0 +1,212 @@
+package rpmdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// ConsistencyProblem is a kind of inconsistency between the headers of a database and its indexes.
+type ConsistencyProblem int
+
+const (
+	// DuplicateKey is an instance number recorded for several headers of Packages, which a hash
+	// database never holds unless a write was interrupted: rpm reads one of them, the other
+	// staying on disk.
+	DuplicateKey ConsistencyProblem = iota
+	// DanglingIndexEntry is an index entry referencing an instance no header of Packages has, the
+	// remains of a package erased while the indexes weren't updated: rpm -q finds a package it
+	// then fails to read.
+	DanglingIndexEntry
+	// UnindexedHeader is a header of Packages whose instance the Name index doesn't reference, a
+	// package installed while the indexes weren't updated: rpm -qa lists it, rpm -q <name>
+	// doesn't, and an upgrade installs it a second time.
+	UnindexedHeader
+)
+
+var consistencyProblemNames = map[ConsistencyProblem]string{
+	DuplicateKey:       "duplicate-key",
+	DanglingIndexEntry: "dangling-index-entry",
+	UnindexedHeader:    "unindexed-header",
+}
+
+// String returns the name of the problem.
+func (p ConsistencyProblem) String() string {
+	if name, ok := consistencyProblemNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("ConsistencyProblem(%d)", int(p))
+}
+
+// MarshalText encodes the problem as its name.
+func (p ConsistencyProblem) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// ConsistencyIssue is an inconsistency found by CheckConsistency.
+type ConsistencyIssue struct {
+	Problem ConsistencyProblem `json:"problem"`
+	// HNum is the instance number of the headers, or the one the index entries reference.
+	HNum uint32 `json:"hnum"`
+	// Package is the package of the header, the first decoding of a duplicate key, nil for a
+	// dangling entry or a header that doesn't decode.
+	Package *PackageInfo `json:"-"`
+	// Count is the number of headers of a duplicate key.
+	Count int `json:"count,omitempty"`
+	// Index and Keys are the index of dangling entries and the sorted keys recording them.
+	Index string   `json:"index,omitempty"`
+	Keys  []string `json:"keys,omitempty"`
+	// Fix tells how to repair the database.
+	Fix string `json:"fix"`
+}
+
+// String describes the issue on a line.
+func (i *ConsistencyIssue) String() string {
+	nevra := "?"
+	if i.Package != nil && i.Package.Name != "" {
+		nevra = i.Package.NEVRA()
+	}
+	switch i.Problem {
+	case DuplicateKey:
+		return fmt.Sprintf("instance %d (%s) is recorded %d times in Packages", i.HNum, nevra, i.Count)
+	case DanglingIndexEntry:
+		return fmt.Sprintf("%s references missing instance %d for %d keys, %q first", i.Index, i.HNum, len(i.Keys), i.Keys[0])
+	case UnindexedHeader:
+		return fmt.Sprintf("instance %d (%s) is missing from the Name index", i.HNum, nevra)
+	}
+	return i.Problem.String()
+}
+
+// ConsistencyReport is the result of CheckConsistency.
+type ConsistencyReport struct {
+	// Headers is the number of headers of Packages, the ones of duplicate keys included.
+	Headers int `json:"headers"`
+	// Indexes are the index databases found and checked, in the order of consistencyIndexes.
+	Indexes []string            `json:"indexes"`
+	Issues  []*ConsistencyIssue `json:"issues"`
+}
+
+// consistencyIndexes are the index databases rpm keeps next to Packages, the tables of an
+// rpmdb.sqlite.
+// ref. https://github.com/rpm-software-management/rpm/blob/rpm-4.16.0-release/lib/rpmdb.c
+var consistencyIndexes = []string{
+	"Name", "Basenames", "Group", "Requirename", "Providename", "Conflictname", "Obsoletename",
+	"Triggername", "Dirnames", "Installtid", "Sigmd5", "Sha1header", "Filetriggername",
+	"Transfiletriggername", "Recommendname", "Suggestname", "Supplementname", "Enhancename",
+}
+
+const (
+	rebuildFix   = "back up the database directory, then run rpm --rebuilddb to regenerate the indexes from Packages"
+	duplicateFix = "back up the database directory, then run rpm --rebuilddb; if the package is then listed twice, " +
+		"remove the extra copy with package-cleanup --cleandupes or rpm -e --justdb --nodeps on one of them"
+)
+
+// CheckConsistency cross-checks the headers of Packages with the index databases next to it, or
+// the index tables of an rpmdb.sqlite, for the usual symptoms of an interrupted rpm transaction:
+// instances recorded twice, index entries referencing missing instances and headers missing from
+// the Name index. Every issue comes with the way to fix it. The headers that don't decode are
+// checked as well, SalvagePackages telling what they hold; a database without indexes, an ndb
+// one or a dump, only has its keys checked.
+func (d *RpmDB) CheckConsistency() (*ConsistencyReport, error) {
+	report := &ConsistencyReport{}
+	counts := make(map[uint32]int)
+	pkgs := make(map[uint32]*PackageInfo)
+	var hnums []uint32
+	entries, stop := d.readHeaders()
+	defer stop()
+	for entry := range entries {
+		if entry.Err != nil {
+			return nil, entry.Err
+		}
+		if isPlaceholder(entry) || len(entry.Key) != 4 {
+			continue
+		}
+		report.Headers++
+		hnum := binary.LittleEndian.Uint32(entry.Key)
+		if counts[hnum] == 0 {
+			hnums = append(hnums, hnum)
+		}
+		counts[hnum]++
+		if pkgs[hnum] != nil {
+			continue
+		}
+		if indexEntries, err := headerImport(entry.Value); err == nil {
+			if pkg, err := getNEVRA(indexEntries); err == nil {
+				pkgs[hnum] = pkg
+			}
+		}
+	}
+	sort.Slice(hnums, func(i, j int) bool { return hnums[i] < hnums[j] })
+
+	for _, hnum := range hnums {
+		if counts[hnum] > 1 {
+			report.Issues = append(report.Issues, &ConsistencyIssue{
+				Problem: DuplicateKey,
+				HNum:    hnum,
+				Package: pkgs[hnum],
+				Count:   counts[hnum],
+				Fix:     duplicateFix,
+			})
+		}
+	}
+
+	var named map[uint32]bool
+	for _, name := range consistencyIndexes {
+		indexed := make(map[uint32]bool)
+		dangling := make(map[uint32]map[string]bool)
+		ok, err := d.forEachIndexItem(name, func(key string, item indexItem) error {
+			indexed[item.hdrNum] = true
+			if counts[item.hdrNum] == 0 {
+				if dangling[item.hdrNum] == nil {
+					dangling[item.hdrNum] = make(map[string]bool)
+				}
+				dangling[item.hdrNum][key] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		report.Indexes = append(report.Indexes, name)
+		if name == "Name" {
+			named = indexed
+		}
+
+		var missing []uint32
+		for hnum := range dangling {
+			missing = append(missing, hnum)
+		}
+		sort.Slice(missing, func(i, j int) bool { return missing[i] < missing[j] })
+		for _, hnum := range missing {
+			keys := make([]string, 0, len(dangling[hnum]))
+			for key := range dangling[hnum] {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			report.Issues = append(report.Issues, &ConsistencyIssue{
+				Problem: DanglingIndexEntry,
+				HNum:    hnum,
+				Index:   name,
+				Keys:    keys,
+				Fix:     rebuildFix,
+			})
+		}
+	}
+
+	if named != nil {
+		for _, hnum := range hnums {
+			if !named[hnum] {
+				report.Issues = append(report.Issues, &ConsistencyIssue{
+					Problem: UnindexedHeader,
+					HNum:    hnum,
+					Package: pkgs[hnum],
+					Fix:     rebuildFix,
+				})
+			}
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,123 @@
+package rpmdb
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"hash"
+	"sync"
+)
+
+// DigestAlgo is a hash algorithm of the OpenPGP numbering, the one FILEDIGESTALGO and
+// PAYLOADDIGESTALGO use.
+type DigestAlgo uint32
+
+// ref. https://github.com/rpm-software-management/rpm/blob/rpm-4.20.0-release/include/rpm/rpmpgp.h#L240
+const (
+	PGPHASHALGO_MD5         DigestAlgo = 1
+	PGPHASHALGO_SHA1        DigestAlgo = 2
+	PGPHASHALGO_RIPEMD160   DigestAlgo = 3
+	PGPHASHALGO_MD2         DigestAlgo = 5
+	PGPHASHALGO_TIGER192    DigestAlgo = 6
+	PGPHASHALGO_HAVAL_5_160 DigestAlgo = 7
+	PGPHASHALGO_SHA256      DigestAlgo = 8
+	PGPHASHALGO_SHA384      DigestAlgo = 9
+	PGPHASHALGO_SHA512      DigestAlgo = 10
+	PGPHASHALGO_SHA224      DigestAlgo = 11
+	PGPHASHALGO_SHA3_256    DigestAlgo = 12
+	PGPHASHALGO_SHA3_512    DigestAlgo = 14
+)
+
+// names of the algorithms rpm knows of, registered or not
+var digestAlgoNames = map[DigestAlgo]string{
+	PGPHASHALGO_MD5:         "md5",
+	PGPHASHALGO_SHA1:        "sha1",
+	PGPHASHALGO_RIPEMD160:   "ripemd160",
+	PGPHASHALGO_MD2:         "md2",
+	PGPHASHALGO_TIGER192:    "tiger192",
+	PGPHASHALGO_HAVAL_5_160: "haval-5-160",
+	PGPHASHALGO_SHA256:      "sha256",
+	PGPHASHALGO_SHA384:      "sha384",
+	PGPHASHALGO_SHA512:      "sha512",
+	PGPHASHALGO_SHA224:      "sha224",
+	PGPHASHALGO_SHA3_256:    "sha3-256",
+	PGPHASHALGO_SHA3_512:    "sha3-512",
+}
+
+// ErrUnsupportedDigestAlgo is returned for digests of an algorithm no one registered.
+var ErrUnsupportedDigestAlgo = errors.New("unsupported digest algorithm")
+
+type digestAlgorithm struct {
+	name    string
+	newHash func() hash.Hash
+}
+
+var (
+	digestAlgosMu sync.RWMutex
+	digestAlgos   = make(map[DigestAlgo]digestAlgorithm)
+)
+
+func init() {
+	RegisterDigestAlgo(PGPHASHALGO_MD5, "md5", md5.New)
+	RegisterDigestAlgo(PGPHASHALGO_SHA1, "sha1", sha1.New)
+	RegisterDigestAlgo(PGPHASHALGO_SHA224, "sha224", sha256.New224)
+	RegisterDigestAlgo(PGPHASHALGO_SHA256, "sha256", sha256.New)
+	RegisterDigestAlgo(PGPHASHALGO_SHA384, "sha384", sha512.New384)
+	RegisterDigestAlgo(PGPHASHALGO_SHA512, "sha512", sha512.New)
+}
+
+// RegisterDigestAlgo makes an algorithm available to the digest checks, replacing the one
+// registered with the same number. SHA-3, for instance, can be added with golang.org/x/crypto/sha3.
+func RegisterDigestAlgo(algo DigestAlgo, name string, newHash func() hash.Hash) {
+	digestAlgosMu.Lock()
+	defer digestAlgosMu.Unlock()
+	digestAlgos[algo] = digestAlgorithm{name: name, newHash: newHash}
+}
+
+// UnregisterDigestAlgo removes an algorithm, the digests using it are reported as not checked
+// rather than trusted: FIPS builds refuse MD5 this way.
+func UnregisterDigestAlgo(algo DigestAlgo) {
+	digestAlgosMu.Lock()
+	defer digestAlgosMu.Unlock()
+	delete(digestAlgos, algo)
+}
+
+// NewDigest returns a new hash of the algorithm.
+func NewDigest(algo DigestAlgo) (hash.Hash, error) {
+	digestAlgosMu.RLock()
+	a, ok := digestAlgos[algo]
+	digestAlgosMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedDigestAlgo, algo)
+	}
+	return a.newHash(), nil
+}
+
+// String returns the name the algorithm is registered with, or rpm's name for it.
+func (a DigestAlgo) String() string {
+	digestAlgosMu.RLock()
+	registered, ok := digestAlgos[a]
+	digestAlgosMu.RUnlock()
+	if ok {
+		return registered.name
+	}
+	if name, ok := digestAlgoNames[a]; ok {
+		return name
+	}
+	return fmt.Sprintf("pgphashalgo(%d)", uint32(a))
+}
+
+// digestAlgoTag returns the algorithm of an algorithm tag, def when the header doesn't have it.
+func digestAlgoTag(indexEntries []indexEntry, tag TAG_ID, def DigestAlgo) (DigestAlgo, error) {
+	values, err := tagUint32s(indexEntries, tag)
+	if err != nil {
+		return 0, fmt.Errorf("invalid tag %v: %w", tag, err)
+	}
+	if len(values) == 0 {
+		return def, nil
+	}
+	return DigestAlgo(values[0]), nil
+}
@@ -0,0 +1,166 @@
+package rpmdb
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// Distro is the subset of os-release(5) that can be inferred from the rpm database.
+type Distro struct {
+	ID        string // e.g. "centos", the os-release ID
+	VersionID string // e.g. "7", the os-release VERSION_ID
+	Name      string // e.g. "CentOS Linux"
+	// ReleasePackage is the NEVRA of the package the answer comes from, empty when guessed from
+	// vendors and dist tags.
+	ReleasePackage string
+}
+
+type releasePackage struct {
+	id   string
+	name string
+}
+
+// the packages shipping /etc/os-release (or /etc/system-release) of each distro
+var releasePackages = map[string]releasePackage{
+	"centos-release":             {"centos", "CentOS Linux"},
+	"centos-stream-release":      {"centos", "CentOS Stream"},
+	"redhat-release":             {"rhel", "Red Hat Enterprise Linux"},
+	"redhat-release-server":      {"rhel", "Red Hat Enterprise Linux Server"},
+	"redhat-release-workstation": {"rhel", "Red Hat Enterprise Linux Workstation"},
+	"fedora-release":             {"fedora", "Fedora Linux"},
+	"fedora-release-common":      {"fedora", "Fedora Linux"},
+	"rocky-release":              {"rocky", "Rocky Linux"},
+	"almalinux-release":          {"almalinux", "AlmaLinux"},
+	"oraclelinux-release":        {"ol", "Oracle Linux Server"},
+	"enterprise-release":         {"ol", "Oracle Linux Server"},
+	"system-release":             {"amzn", "Amazon Linux"},
+	"amazon-linux-release":       {"amzn", "Amazon Linux"},
+	"sles-release":               {"sles", "SLES"},
+	"openSUSE-release":           {"opensuse-leap", "openSUSE Leap"},
+	"photon-release":             {"photon", "VMware Photon OS"},
+	"mariner-release":            {"mariner", "CBL-Mariner"},
+	"azurelinux-release":         {"azurelinux", "Microsoft Azure Linux"},
+	"anolis-release":             {"anolis", "Anolis OS"},
+	"openEuler-release":          {"openEuler", "openEuler"},
+}
+
+// the dist tags found in release strings, used when there is no release package
+var distTagPatterns = []struct {
+	pattern *regexp.Regexp
+	id      string
+	name    string
+}{
+	{regexp.MustCompile(`\.el(\d+)`), "rhel", "Red Hat Enterprise Linux"},
+	{regexp.MustCompile(`\.fc(\d+)`), "fedora", "Fedora Linux"},
+	{regexp.MustCompile(`\.amzn(\d+)`), "amzn", "Amazon Linux"},
+	{regexp.MustCompile(`\.ph(\d+)`), "photon", "VMware Photon OS"},
+	{regexp.MustCompile(`\.cm(\d+)`), "mariner", "CBL-Mariner"},
+	{regexp.MustCompile(`\.azl(\d+)`), "azurelinux", "Microsoft Azure Linux"},
+}
+
+// the vendors of the rebuilds of Red Hat Enterprise Linux, all sharing the el dist tag
+var elVendors = map[string]releasePackage{
+	"CentOS":                               {"centos", "CentOS Linux"},
+	"Rocky Enterprise Software Foundation": {"rocky", "Rocky Linux"},
+	"AlmaLinux":                            {"almalinux", "AlmaLinux"},
+	"Oracle America":                       {"ol", "Oracle Linux Server"},
+}
+
+// ErrUnknownDistro is returned by DetectDistro when no package tells the distribution.
+var ErrUnknownDistro = errors.New("unable to detect the distribution")
+
+// DetectDistro infers the distribution a database belongs to, so that a copied /var/lib/rpm is
+// enough to pick the right advisory feed. The release package (centos-release, redhat-release, ...)
+// is preferred, then the most common dist tag (DISTTAG or the el7, fc39, amzn2, ... suffix of the
+// package releases), the vendor telling the Enterprise Linux rebuilds apart.
+func DetectDistro(db *RpmDB) (*Distro, error) {
+	var distro *Distro
+	distTags := make(map[Distro]int)
+	vendors := make(map[string]int)
+
+	err := db.forEachPackage(func(hnum uint32, indexEntries []indexEntry, pkg *PackageInfo) error {
+		if known, ok := releasePackages[pkg.Name]; ok && distro == nil {
+			distro = &Distro{
+				ID:             known.id,
+				VersionID:      pkg.Version,
+				Name:           known.name,
+				ReleasePackage: pkg.NEVRA(),
+			}
+
+			// system-release(releasever) carries the exact version when the package has one
+			provideNames, err := tagStrings(indexEntries, RPMTAG_PROVIDENAME)
+			if err != nil {
+				return fmt.Errorf("invalid tag providename: %w", err)
+			}
+			provideVersions, err := tagStrings(indexEntries, RPMTAG_PROVIDEVERSION)
+			if err != nil {
+				return fmt.Errorf("invalid tag provideversion: %w", err)
+			}
+			for i, name := range provideNames {
+				if name == "system-release(releasever)" && i < len(provideVersions) && provideVersions[i] != "" {
+					distro.VersionID = provideVersions[i]
+				}
+			}
+		}
+
+		vendors[pkg.Vendor]++
+
+		release := pkg.Release
+		if distTag, err := tagStrings(indexEntries, RPMTAG_DISTTAG); err == nil && len(distTag) == 1 {
+			release = "." + distTag[0]
+		}
+		for _, p := range distTagPatterns {
+			if m := p.pattern.FindStringSubmatch(release); m != nil {
+				distTags[Distro{ID: p.id, VersionID: m[1], Name: p.name}]++
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if distro != nil {
+		return distro, nil
+	}
+
+	var candidates []Distro
+	for candidate := range distTags {
+		candidates = append(candidates, candidate)
+	}
+	if len(candidates) == 0 {
+		return nil, ErrUnknownDistro
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if distTags[candidates[i]] != distTags[candidates[j]] {
+			return distTags[candidates[i]] > distTags[candidates[j]]
+		}
+		return candidates[i].ID+candidates[i].VersionID < candidates[j].ID+candidates[j].VersionID
+	})
+	guess := candidates[0]
+
+	if guess.ID == "rhel" {
+		if rebuild, ok := elVendors[topVendor(vendors)]; ok {
+			guess.ID, guess.Name = rebuild.id, rebuild.name
+		}
+	}
+	return &guess, nil
+}
+
+// topVendor returns the vendor of the most packages, the first by name of the ones of as many, ""
+// when no package has one.
+func topVendor(vendors map[string]int) string {
+	var top string
+	for vendor, count := range vendors {
+		if vendor == "" {
+			continue
+		}
+		if top == "" || count > vendors[top] || (count == vendors[top] && vendor < top) {
+			top = vendor
+		}
+	}
+	return top
+}
@@ -0,0 +1,252 @@
+package rpmdb
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/chennqqi/go-rpmdb/pkg/sqlite"
+)
+
+// the transaction item actions and states of the dnf history database
+// ref. https://github.com/rpm-software-management/libdnf/blob/0.48.0/libdnf/transaction/Types.hpp
+const (
+	dnfActionInstall      = 1
+	dnfActionDowngrade    = 2
+	dnfActionDowngraded   = 3
+	dnfActionObsolete     = 4
+	dnfActionObsoleted    = 5
+	dnfActionUpgrade      = 6
+	dnfActionUpgraded     = 7
+	dnfActionRemove       = 8
+	dnfActionReinstall    = 9
+	dnfActionReasonChange = 11
+
+	dnfStateDone = 1
+)
+
+var dnfActionNames = map[int64]string{
+	dnfActionInstall:   "Install",
+	dnfActionDowngrade: "Downgrade",
+	dnfActionObsolete:  "Obsolete",
+	dnfActionUpgrade:   "Upgrade",
+	dnfActionReinstall: "Reinstall",
+}
+
+// libdnf numbers reasons differently from yumdb names
+var dnfReasons = map[int64]InstallReason{
+	1: InstallReasonDependency,
+	2: InstallReasonUser,
+	4: InstallReasonWeakDependency,
+	5: InstallReasonGroup,
+}
+
+// DNFTransaction is a completed transaction of the dnf history.
+type DNFTransaction struct {
+	ID         int64
+	Begin      time.Time
+	End        time.Time
+	UserID     int
+	CmdLine    string
+	ReleaseVer string
+}
+
+// PackageHistory is an installed package along with what dnf recorded about it.
+type PackageHistory struct {
+	Package *PackageInfo
+	// Transaction brought the installed version in, nil when dnf has no record of it (installed
+	// by anaconda, rpm or another tool).
+	Transaction *DNFTransaction
+	// Action is how the transaction did it: Install, Upgrade, Downgrade, Reinstall or Obsolete.
+	Action string
+	// Repo is the id of the repository the package came from.
+	Repo   string
+	Reason InstallReason
+}
+
+// DNFHistory is the dnf history database (/var/lib/dnf/history.sqlite) boiled down to the latest
+// record of every package. It is an InstallReasonOverlay.
+type DNFHistory struct {
+	// Transactions are the completed transactions ordered by id.
+	Transactions []*DNFTransaction
+
+	packages map[string]*PackageHistory
+}
+
+// ReadDNFHistory loads a dnf history database, failed transactions are ignored.
+func ReadDNFHistory(path string) (*DNFHistory, error) {
+	db, err := sqlite.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dnf history: %w", err)
+	}
+	defer db.Close()
+
+	h := &DNFHistory{packages: make(map[string]*PackageHistory)}
+
+	transactions := make(map[int64]*DNFTransaction)
+	err = readDNFTable(db, "trans", []string{"id", "dt_begin", "dt_end", "user_id", "cmdline", "releasever", "state"}, func(v []interface{}) {
+		if dnfInt(v[6]) != dnfStateDone {
+			return
+		}
+		tx := &DNFTransaction{
+			ID:         dnfInt(v[0]),
+			Begin:      time.Unix(dnfInt(v[1]), 0).UTC(),
+			UserID:     int(dnfInt(v[3])),
+			CmdLine:    dnfString(v[4]),
+			ReleaseVer: dnfString(v[5]),
+		}
+		if v[2] != nil {
+			tx.End = time.Unix(dnfInt(v[2]), 0).UTC()
+		}
+		transactions[tx.ID] = tx
+		h.Transactions = append(h.Transactions, tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	repos := make(map[int64]string)
+	err = readDNFTable(db, "repo", []string{"id", "repoid"}, func(v []interface{}) {
+		repos[dnfInt(v[0])] = dnfString(v[1])
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	nevras := make(map[int64]string)
+	err = readDNFTable(db, "rpm", []string{"item_id", "name", "epoch", "version", "release", "arch"}, func(v []interface{}) {
+		pkg := &PackageInfo{
+			Name:    dnfString(v[1]),
+			Epoch:   int(dnfInt(v[2])),
+			Version: dnfString(v[3]),
+			Release: dnfString(v[4]),
+			Arch:    dnfString(v[5]),
+		}
+		nevras[dnfInt(v[0])] = pkg.NEVRA()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	type transItem struct {
+		id, transID, itemID, repoID, action, reason int64
+	}
+	var items []transItem
+	err = readDNFTable(db, "trans_item", []string{"id", "trans_id", "item_id", "repo_id", "action", "reason", "state"}, func(v []interface{}) {
+		if _, ok := transactions[dnfInt(v[1])]; !ok || dnfInt(v[6]) != dnfStateDone {
+			return
+		}
+		items = append(items, transItem{dnfInt(v[0]), dnfInt(v[1]), dnfInt(v[2]), dnfInt(v[3]), dnfInt(v[4]), dnfInt(v[5])})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// replay the transactions in order, the last record of a package wins
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].transID != items[j].transID {
+			return items[i].transID < items[j].transID
+		}
+		return items[i].id < items[j].id
+	})
+	for _, item := range items {
+		nevra, ok := nevras[item.itemID]
+		if !ok {
+			continue
+		}
+		switch item.action {
+		case dnfActionRemove, dnfActionDowngraded, dnfActionObsoleted, dnfActionUpgraded:
+			delete(h.packages, nevra)
+		case dnfActionReasonChange:
+			if record, ok := h.packages[nevra]; ok {
+				record.Reason = dnfReasons[item.reason]
+			}
+		default:
+			action, ok := dnfActionNames[item.action]
+			if !ok {
+				continue
+			}
+			h.packages[nevra] = &PackageHistory{
+				Transaction: transactions[item.transID],
+				Action:      action,
+				Repo:        repos[item.repoID],
+				Reason:      dnfReasons[item.reason],
+			}
+		}
+	}
+
+	sort.Slice(h.Transactions, func(i, j int) bool {
+		return h.Transactions[i].ID < h.Transactions[j].ID
+	})
+	return h, nil
+}
+
+// Lookup returns what dnf recorded about the package, with Transaction nil when nothing.
+func (h *DNFHistory) Lookup(pkg *PackageInfo) *PackageHistory {
+	history := &PackageHistory{Package: pkg}
+	if record, ok := h.packages[pkg.NEVRA()]; ok {
+		*history = *record
+		history.Package = pkg
+	}
+	return history
+}
+
+func (h *DNFHistory) InstallReason(pkg *PackageInfo) InstallReason {
+	return h.Lookup(pkg).Reason
+}
+
+// EnrichWithDNFHistory lists the installed packages along with the dnf history records. The
+// InstallReason of the packages is set from the history as well.
+func (d *RpmDB) EnrichWithDNFHistory(path string) ([]*PackageHistory, error) {
+	pkgList, err := d.ListPackages()
+	if err != nil {
+		return nil, err
+	}
+	history, err := ReadDNFHistory(path)
+	if err != nil {
+		return nil, err
+	}
+	ApplyInstallReasons(pkgList, history)
+
+	var histories []*PackageHistory
+	for _, pkg := range pkgList {
+		histories = append(histories, history.Lookup(pkg))
+	}
+	return histories, nil
+}
+
+func readDNFTable(db *sqlite.DB, name string, columns []string, fn func(values []interface{})) error {
+	table := db.Table(name)
+	if table == nil {
+		return fmt.Errorf("invalid dnf history: missing table %s", name)
+	}
+	indexes := make([]int, len(columns))
+	for i, column := range columns {
+		if indexes[i] = table.ColumnIndex(column); indexes[i] < 0 {
+			return fmt.Errorf("invalid dnf history: missing column %s.%s", name, column)
+		}
+	}
+
+	values := make([]interface{}, len(columns))
+	err := db.Rows(name, func(row sqlite.Row) error {
+		for i, index := range indexes {
+			values[i] = row.Values[index]
+		}
+		fn(values)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read dnf history: %w", err)
+	}
+	return nil
+}
+
+func dnfInt(v interface{}) int64 {
+	i, _ := v.(int64)
+	return i
+}
+
+func dnfString(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
@@ -0,0 +1,224 @@
+package rpmdb
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// ErrNotDeltaRPM is returned by ReadDeltaRPM for files that are not delta rpms, plain rpms included.
+var ErrNotDeltaRPM = errors.New("not a delta rpm")
+
+var (
+	// magic of the deltas of rpm-only deltarpm, made without the old rpm file
+	drpmMagic = []byte("drpm")
+	// magic of the delta itself, followed by the version digit
+	deltaMagic = []byte("DLT")
+)
+
+// DeltaRPM is the metadata of a delta rpm made by makedeltarpm, which rebuilds a new package from an
+// old one installed or at hand. Applying deltas is out of scope.
+// ref. https://github.com/rpm-software-management/deltarpm/blob/master/readdeltarpm.c
+type DeltaRPM struct {
+	// RPMOnly is set for the deltas made with makedeltarpm -r, which don't carry the header of the
+	// new package.
+	RPMOnly bool
+	// Version is the version of the delta format, 1 to 3.
+	Version int
+	// SourceNEVR is the "name-[epoch:]version-release" of the old package.
+	SourceNEVR string
+	// TargetNEVR is the "name-[epoch:]version-release" of the new package.
+	TargetNEVR string
+	// Sequence identifies the old package the delta applies to, as applydeltarpm -s takes it and
+	// prestodelta.xml lists it: the source NEVR followed by the hex of the file order digest.
+	Sequence string
+	// TargetMD5 is the hex md5 of the new rpm file, TargetSize its size for format 2 onward.
+	TargetMD5  string
+	TargetSize uint32
+	// Target is the package info of the new package, nil for rpm-only deltas.
+	Target *PackageInfo
+}
+
+// ReadDeltaRPM reads the metadata of a .drpm file: the header of the new package for regular deltas
+// and the head of the delta, which names the old package.
+func ReadDeltaRPM(r io.Reader) (*DeltaRPM, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read delta rpm: %w", err)
+	}
+
+	delta := &DeltaRPM{}
+	var payload io.Reader
+	switch {
+	case bytes.Equal(magic, drpmMagic):
+		delta.RPMOnly = true
+		if payload, err = delta.readRPMOnlyHead(br); err != nil {
+			return nil, err
+		}
+	case bytes.Equal(magic, leadMagic):
+		indexEntries, _, err := readPackageHeader(br)
+		if err != nil {
+			return nil, err
+		}
+		formats, err := tagStrings(indexEntries, RPMTAG_PAYLOADFORMAT)
+		if err != nil || len(formats) == 0 || formats[0] != "drpm" {
+			return nil, ErrNotDeltaRPM
+		}
+		if delta.Target, err = getNEVRA(indexEntries); err != nil {
+			return nil, fmt.Errorf("invalid package info: %w", err)
+		}
+		delta.TargetNEVR = delta.Target.Name + "-" + delta.Target.EVR()
+
+		compressors, err := tagStrings(indexEntries, RPMTAG_PAYLOADCOMPRESSOR)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag payloadcompressor: %w", err)
+		}
+		compressor := "gzip"
+		if len(compressors) > 0 {
+			compressor = compressors[0]
+		}
+		if payload, err = decompressPayload(br, compressor); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrNotDeltaRPM
+	}
+
+	if err := delta.readDelta(payload); err != nil {
+		return nil, fmt.Errorf("invalid delta: %w", err)
+	}
+	return delta, nil
+}
+
+// readRPMOnlyHead reads the uncompressed head of rpm-only deltas and returns the compressed delta
+// following it: "drpm", the delta magic, the target NEVR and the additional data.
+func (d *DeltaRPM) readRPMOnlyHead(br *bufio.Reader) (io.Reader, error) {
+	head := make([]byte, 8)
+	if _, err := io.ReadFull(br, head); err != nil {
+		return nil, fmt.Errorf("failed to read delta rpm: %w", err)
+	}
+	if !bytes.Equal(head[4:7], deltaMagic) {
+		return nil, ErrNotDeltaRPM
+	}
+	nevr, err := readDeltaString(br)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target nevr: %w", err)
+	}
+	d.TargetNEVR = nevr
+	addLen, err := readDeltaUint32(br)
+	if err != nil {
+		return nil, fmt.Errorf("invalid delta: %w", err)
+	}
+	if _, err := io.CopyN(ioutil.Discard, br, int64(addLen)); err != nil {
+		return nil, fmt.Errorf("invalid delta: %w", err)
+	}
+
+	// the delta is compressed with the payload compressor of the new package, told by its magic
+	magic, err := br.Peek(6)
+	if err != nil {
+		return nil, fmt.Errorf("invalid delta: %w", err)
+	}
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return decompressPayload(br, "gzip")
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		return decompressPayload(br, "bzip2")
+	case bytes.HasPrefix(magic, deltaMagic):
+		return br, nil
+	case bytes.HasPrefix(magic, []byte{0xfd, '7', 'z', 'X', 'Z', 0}):
+		return decompressPayload(br, "xz")
+	case bytes.HasPrefix(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return decompressPayload(br, "zstd")
+	}
+	return decompressPayload(br, "lzma")
+}
+
+func decompressPayload(r io.Reader, compressor string) (io.Reader, error) {
+	switch compressor {
+	case "gzip":
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip payload: %w", err)
+		}
+		return gz, nil
+	case "bzip2":
+		return bzip2.NewReader(r), nil
+	}
+	return nil, fmt.Errorf("unsupported payload compressor: %s", compressor)
+}
+
+// readDelta reads the head of the delta, up to the target size.
+func (d *DeltaRPM) readDelta(r io.Reader) error {
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return err
+	}
+	if !bytes.Equal(magic[:3], deltaMagic) || magic[3] < '1' || magic[3] > '3' {
+		return fmt.Errorf("unsupported delta magic: %q", magic)
+	}
+	d.Version = int(magic[3] - '0')
+
+	nevr, err := readDeltaString(r)
+	if err != nil {
+		return fmt.Errorf("invalid source nevr: %w", err)
+	}
+	d.SourceNEVR = nevr
+
+	seqLen, err := readDeltaUint32(r)
+	if err != nil {
+		return err
+	}
+	// the sequence starts with the md5 of the old header
+	if seqLen < 16 || seqLen > 1<<20 {
+		return fmt.Errorf("invalid sequence length: %d", seqLen)
+	}
+	seq := make([]byte, seqLen)
+	if _, err := io.ReadFull(r, seq); err != nil {
+		return err
+	}
+	d.Sequence = d.SourceNEVR + "-" + hex.EncodeToString(seq)
+
+	md5sum := make([]byte, 16)
+	if _, err := io.ReadFull(r, md5sum); err != nil {
+		return err
+	}
+	d.TargetMD5 = hex.EncodeToString(md5sum)
+
+	if d.Version >= 2 {
+		if d.TargetSize, err = readDeltaUint32(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readDeltaUint32(r io.Reader) (uint32, error) {
+	b := make([]byte, 4)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(b), nil
+}
+
+func readDeltaString(r io.Reader) (string, error) {
+	n, err := readDeltaUint32(r)
+	if err != nil {
+		return "", err
+	}
+	if n > 1<<16 {
+		return "", fmt.Errorf("string too long: %d", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", err
+	}
+	return string(bytes.TrimRight(b, "\x00")), nil
+}
@@ -0,0 +1,135 @@
+package rpmdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DumpHeader pretty-prints a header blob with the name, type, count and decoded values of each of
+// its entries in index order, duplicates included. The blob is an rpmdb value, a header with its
+// magic, the dump of a signature header or a whole .rpm file, whose package header is printed.
+// Entries too corrupt to decode are printed with the error instead of failing the dump. The time_t
+// tags are followed by their time in UTC.
+func DumpHeader(blob []byte, w io.Writer) error {
+	return DumpHeaderIn(blob, w, time.UTC)
+}
+
+// DumpHeaderIn is DumpHeader printing the times in loc, time.Local for the zone of the host.
+func DumpHeaderIn(blob []byte, w io.Writer, loc *time.Location) error {
+	var indexEntries []indexEntry
+	var err error
+	switch {
+	case bytes.HasPrefix(blob, leadMagic):
+		indexEntries, _, err = readPackageHeader(bytes.NewReader(blob))
+	case bytes.HasPrefix(blob, headerMagic[:3]):
+		if len(blob) < len(headerMagic) {
+			return fmt.Errorf("truncated header magic: %d bytes", len(blob))
+		}
+		indexEntries, err = headerImport(blob[len(headerMagic):])
+	default:
+		indexEntries, err = headerImport(blob)
+	}
+	if err != nil {
+		return fmt.Errorf("error during importing header: %w", err)
+	}
+
+	bw := bufio.NewWriter(w)
+	for i := range indexEntries {
+		entry := &indexEntries[i]
+		typ := strings.TrimSuffix(strings.TrimPrefix(entry.Info.Type.String(), "RPM_"), "_TYPE")
+		fmt.Fprintf(bw, "%-28s %5d %-12s %6d", dumpTagName(entry.Info.Tag), int32(entry.Info.Tag), typ, entry.Info.Count)
+
+		values, err := dumpValues(entry, loc)
+		switch {
+		case err != nil:
+			fmt.Fprintf(bw, "  <%v>\n", err)
+		case len(values) == 1:
+			fmt.Fprintf(bw, "  %s\n", values[0])
+		default:
+			bw.WriteByte('\n')
+			for j, v := range values {
+				fmt.Fprintf(bw, "    [%d] %s\n", j, v)
+			}
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write header dump: %w", err)
+	}
+	return nil
+}
+
+// dumpTagName returns the name of a tag, RPMTAG_NAME sharing its number with HEADER_TAGBASE.
+func dumpTagName(tag TAG_ID) string {
+	if tag == RPMTAG_NAME {
+		return "RPMTAG_NAME"
+	}
+	return tag.String()
+}
+
+// dumpValues formats the values of an entry: strings quoted, integers in decimal and binary data in
+// hex, as a single value. The seconds of time_t tags are followed by their time in loc.
+func dumpValues(entry *indexEntry, loc *time.Location) ([]string, error) {
+	var values []string
+	switch entry.Info.Type {
+	case RPM_NULL_TYPE:
+	case RPM_STRING_TYPE, RPM_STRING_ARRAY_TYPE, RPM_I18NSTRING_TYPE:
+		strs, err := entryStrings(entry)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range strs {
+			values = append(values, strconv.Quote(s))
+		}
+	case RPM_CHAR_TYPE, RPM_INT8_TYPE:
+		data, err := entryBytes(entry)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range data {
+			values = append(values, strconv.Itoa(int(v)))
+		}
+	case RPM_INT16_TYPE:
+		ints, err := entryUint16s(entry)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range ints {
+			values = append(values, strconv.FormatUint(uint64(v), 10))
+		}
+	case RPM_INT32_TYPE:
+		ints, err := entryUint32s(entry)
+		if err != nil {
+			return nil, err
+		}
+		_, isTime := timeTags[entry.Info.Tag]
+		for _, v := range ints {
+			value := strconv.FormatUint(uint64(v), 10)
+			if isTime {
+				value += " (" + unixTime(uint64(v)).In(loc).Format(time.RFC3339) + ")"
+			}
+			values = append(values, value)
+		}
+	case RPM_INT64_TYPE:
+		ints, err := entryUint64s(entry)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range ints {
+			values = append(values, strconv.FormatUint(v, 10))
+		}
+	case RPM_BIN_TYPE:
+		if err := checkEntry(entry); err != nil {
+			return nil, err
+		}
+		values = append(values, hex.EncodeToString(entry.Data[:entry.Info.Count]))
+	default:
+		return nil, checkEntry(entry)
+	}
+	return values, nil
+}
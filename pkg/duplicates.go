@@ -0,0 +1,118 @@
+package rpmdb
+
+// DuplicatePackage is a package whose name and arch are recorded more than once, which only happens
+// when the database lost track of a transaction: an upgrade interrupted before erasing the old
+// version, or an install recorded twice (corrupted indexes). The installonly packages, kernels
+// installed side by side, aren't duplicates unless the very same NEVRA is recorded twice.
+type DuplicatePackage struct {
+	Name string
+	Arch string
+	// Packages are the packages of the name and arch, of the NEVRA for an installonly package, in
+	// the order of the input.
+	Packages []*PackageInfo
+	// Identical is set when the packages have the same NEVRA, the same install recorded
+	// several times.
+	Identical bool
+}
+
+// MultilibPackage is a package installed with the same NEVR for several architectures, e.g. the i686
+// and x86_64 builds of a library. This is legitimate and expected on multilib hosts.
+type MultilibPackage struct {
+	NEVR     string
+	Packages []*PackageInfo
+}
+
+// DuplicateReport is the result of FindDuplicates.
+type DuplicateReport struct {
+	Duplicates []DuplicatePackage
+	Multilib   []MultilibPackage
+}
+
+// FindDuplicates flags the packages installed several times, telling database corruption (the same
+// name and arch, the packages of InstallOnlyNames excepted) apart from multilib installs (identical
+// NEVR, different arch), the same analysis as `package-cleanup --dupes`. The order of the input is
+// kept.
+func FindDuplicates(pkgList []*PackageInfo) *DuplicateReport {
+	installOnly := make(map[string]bool)
+	for _, name := range InstallOnlyNames {
+		installOnly[name] = true
+	}
+	return findDuplicates(pkgList, installOnly)
+}
+
+// findDuplicates is FindDuplicates with the names of the installonly packages.
+func findDuplicates(pkgList []*PackageInfo, installOnly map[string]bool) *DuplicateReport {
+	// the packages of a name and arch, of a NEVRA for the installonly ones whose versions side by
+	// side are expected
+	var keys []string
+	byKey := make(map[string][]*PackageInfo)
+	var nevrs []string
+	arches := make(map[string][]*PackageInfo)
+	seen := make(map[string]bool)
+
+	for _, pkg := range pkgList {
+		key := pkg.Name + "." + pkg.Arch
+		if installOnly[pkg.Name] {
+			key = pkg.NEVRA()
+		}
+		if _, ok := byKey[key]; !ok {
+			keys = append(keys, key)
+		}
+		byKey[key] = append(byKey[key], pkg)
+
+		nevra := pkg.NEVRA()
+		if !seen[nevra] {
+			seen[nevra] = true
+			nevr := pkg.Name + "-" + pkg.EVR()
+			if _, ok := arches[nevr]; !ok {
+				nevrs = append(nevrs, nevr)
+			}
+			arches[nevr] = append(arches[nevr], pkg)
+		}
+	}
+
+	report := &DuplicateReport{}
+	for _, key := range keys {
+		pkgs := byKey[key]
+		if len(pkgs) < 2 {
+			continue
+		}
+		identical := true
+		for _, pkg := range pkgs[1:] {
+			identical = identical && pkg.NEVRA() == pkgs[0].NEVRA()
+		}
+		report.Duplicates = append(report.Duplicates, DuplicatePackage{
+			Name:      pkgs[0].Name,
+			Arch:      pkgs[0].Arch,
+			Packages:  pkgs,
+			Identical: identical,
+		})
+	}
+	for _, nevr := range nevrs {
+		if pkgs := arches[nevr]; len(pkgs) > 1 {
+			report.Multilib = append(report.Multilib, MultilibPackage{
+				NEVR:     nevr,
+				Packages: pkgs,
+			})
+		}
+	}
+	return report
+}
+
+// FindDuplicates runs FindDuplicates over every package of the database, the packages providing
+// installonlypkg() being installonly as well.
+func (d *RpmDB) FindDuplicates() (*DuplicateReport, error) {
+	pkgList, err := d.ListPackages()
+	if err != nil {
+		return nil, err
+	}
+	groups, err := d.InstallOnlyPackages()
+	if err != nil {
+		return nil, err
+	}
+	installOnly := make(map[string]bool)
+	for _, group := range groups {
+		installOnly[group.Name] = true
+	}
+	return findDuplicates(pkgList, installOnly), nil
+}
@@ -4,10 +4,9 @@ package rpmdb
 import (
 	"bytes"
 	"encoding/binary"
-	"io"
+	"errors"
+	"fmt"
 	"unsafe"
-
-	"golang.org/x/xerrors"
 )
 
 // ref. https://github.com/rpm-software-management/rpm/blob/rpm-4.11.3-release/lib/header_internal.h#L13-L19
@@ -26,41 +25,130 @@ type indexEntry struct {
 	Data   []byte
 }
 
+// the type and count of region tag entries
+// ref. https://github.com/rpm-software-management/rpm/blob/rpm-4.11.3-release/lib/header.c#L120
+const (
+	regionTagType  = RPM_BIN_TYPE
+	regionTagCount = 16
+)
+
+// headerImport decodes a header blob the way librpm does. Headers built by rpm >= 4 start with a
+// region entry whose trailer tells how many entries the region covers, the entries past these are
+// dribbles added later (install time tags in rpmdb) and replace the region entries of the same tag.
+// Legacy rpm 3 headers have no region: every entry is data.
 // ref. https://github.com/rpm-software-management/rpm/blob/rpm-4.11.3-release/lib/header.c#L789
 func headerImport(data []byte) ([]indexEntry, error) {
-	var il, dl int32
-	var err error
-	reader := bytes.NewReader(data)
+	return importHeader(data, nil, nil)
+}
 
-	if err = binary.Read(reader, binary.BigEndian, &il); err != nil {
-		return nil, xerrors.Errorf("invalid index length: %w", err)
+// importHeader is headerImport decoding into the tables of arena, allocated when it is nil, and
+// leaving out the entries of the tags of skip: their data isn't even checked.
+func importHeader(data []byte, arena *entryArena, skip map[TAG_ID]bool) ([]indexEntry, error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("header too short: %d bytes", len(data))
 	}
-	if err = binary.Read(reader, binary.BigEndian, &dl); err != nil {
-		return nil, xerrors.Errorf("invalid data length: %w", err)
+	il := int32(binary.BigEndian.Uint32(data[0:]))
+	dl := int32(binary.BigEndian.Uint32(data[4:]))
+
+	if il < 1 {
+		return nil, errors.New("empty header")
+	}
+	// checked before allocating anything from the lengths of a corrupt blob
+	entrySize := int(unsafe.Sizeof(entryInfo{}))
+	if dl < 0 || int(il) > (len(data)-8)/entrySize || 8+int(il)*entrySize+int(dl) > len(data) {
+		return nil, fmt.Errorf("invalid header lengths: il=%d dl=%d for %d bytes", il, dl, len(data))
 	}
 
 	dataStart := int32(unsafe.Sizeof(il)) + int32(unsafe.Sizeof(dl)) + il*int32(unsafe.Sizeof(entryInfo{}))
 
-	peList := make([]entryInfo, il)
-	for i := 0; i < int(il); i++ {
-		var pe entryInfo
-		err = binary.Read(reader, binary.LittleEndian, &pe)
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			return nil, xerrors.Errorf("failed to read entry info: %w", err)
+	// read as is, the fields are swapped along with the checks
+	peList := arena.entryInfos(int(il))
+	for i := range peList {
+		pe := data[8+i*entrySize:]
+		peList[i] = entryInfo{
+			Tag:    TAG_ID(binary.LittleEndian.Uint32(pe)),
+			Type:   TAG_TYPE(binary.LittleEndian.Uint32(pe[4:])),
+			Offset: int32(binary.LittleEndian.Uint32(pe[8:])),
+			Count:  binary.LittleEndian.Uint32(pe[12:]),
+		}
+	}
+
+	region := entryInfo{
+		Tag:    TAG_ID(Htonl(int32(peList[0].Tag))),
+		Type:   TAG_TYPE(HtonlU(uint32(peList[0].Type))),
+		Offset: Htonl(peList[0].Offset),
+		Count:  HtonlU(peList[0].Count),
+	}
+	isRegion := region.Tag == HEADER_IMAGE || region.Tag == HEADER_SIGNATURES || region.Tag == HEADER_IMMUTABLE
+	if !isRegion || region.Type != regionTagType || region.Count != regionTagCount {
+		return regionSwab(arena.indexEntries(len(peList)), data, peList, dataStart, int(dl), skip)
+	}
+
+	// a region without trailer covers the whole header
+	ril, rdl := int(il), int(dl)
+	if region.Offset != 0 {
+		trailerStart := int(dataStart) + int(region.Offset)
+		if region.Offset < 0 || region.Offset+regionTagCount > dl || trailerStart+regionTagCount > len(data) {
+			return nil, fmt.Errorf("invalid region trailer offset: %d", region.Offset)
+		}
+		ril = -int(int32(binary.BigEndian.Uint32(data[trailerStart+8:]))) / regionTagCount
+		rdl = int(region.Offset)
+		if ril < 1 || ril > int(il) {
+			return nil, fmt.Errorf("invalid region index length: %d", ril)
 		}
-		peList[i] = pe
 	}
 
-	// Ignore negative offset
-	indexEntries := regionSwab(data, peList[1:], dataStart, int(dl))
+	// signature headers use their own tag namespace
+	if region.Tag == HEADER_SIGNATURES {
+		skip = nil
+	}
+
+	// the region entry itself holds no data, the dribbles follow the region entries in the table
+	entries := arena.indexEntries(int(il) - 1)
+	indexEntries, err := regionSwab(entries[:ril-1], data, peList[1:ril], dataStart, rdl, skip)
+	if err != nil {
+		return nil, err
+	}
+	if ril < int(il) {
+		dribbles, err := regionSwab(entries[ril-1:], data, peList[ril:], dataStart, int(dl), skip)
+		if err != nil {
+			return nil, err
+		}
+		indexEntries = append(removeReplaced(indexEntries, dribbles), dribbles...)
+	}
+
+	if region.Tag == HEADER_SIGNATURES {
+		indexEntries = sigToHeaderEntries(indexEntries)
+	}
 	return indexEntries, nil
 }
 
+// removeReplaced drops the region entries a dribble entry replaces, rpm deletes the old file list
+// along when new basenames are dribbled. The entries kept are moved to the front of indexEntries.
+func removeReplaced(indexEntries, dribbles []indexEntry) []indexEntry {
+	replaced := make(map[TAG_ID]bool, len(dribbles))
+	for _, dribble := range dribbles {
+		replaced[dribble.Info.Tag] = true
+		if dribble.Info.Tag == RPMTAG_BASENAMES {
+			replaced[RPMTAG_OLDFILENAMES] = true
+		}
+	}
+
+	entries := indexEntries[:0]
+	for _, entry := range indexEntries {
+		if !replaced[entry.Info.Tag] {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// regionSwab decodes the entries of a region into indexEntries, as long as peList, checking that
+// their data lies within it and is large enough for their count so that the accessors can't read
+// past it. The entries of the tags of skip are left out, the ones decoded returned.
 // ref. https://github.com/rpm-software-management/rpm/blob/7a2f891d25d78cf797c789ac6859b5f2c589d296/lib/header.c#L498
-func regionSwab(data []byte, peList []entryInfo, dataStart int32, dl int) []indexEntry {
-	indexEntries := make([]indexEntry, len(peList))
+func regionSwab(indexEntries []indexEntry, data []byte, peList []entryInfo, dataStart int32, dl int, skip map[TAG_ID]bool) ([]indexEntry, error) {
+	n := 0
 	for i := 0; i < len(peList); i++ {
 		pe := peList[i]
 		indexEntry := indexEntry{
@@ -72,16 +160,277 @@ func regionSwab(data []byte, peList []entryInfo, dataStart int32, dl int) []inde
 			},
 		}
 		if i < len(peList)-1 {
-			indexEntry.Length = int(Htonl(peList[i+1].Offset) - indexEntry.Info.Offset)
+			indexEntry.Length = int(Htonl(peList[i+1].Offset)) - int(indexEntry.Info.Offset)
 		} else {
 			indexEntry.Length = dl - int(indexEntry.Info.Offset)
 		}
 
-		start := dataStart + indexEntry.Info.Offset
-		end := int(start) + indexEntry.Length
+		if indexEntry.Info.Offset < 0 || indexEntry.Length < 0 || int(indexEntry.Info.Offset)+indexEntry.Length > dl {
+			return nil, fmt.Errorf("invalid data offset %d (tag %v)", indexEntry.Info.Offset, indexEntry.Info.Tag)
+		}
+		start := int(dataStart) + int(indexEntry.Info.Offset)
+		end := start + indexEntry.Length
+		if end > len(data) {
+			return nil, fmt.Errorf("truncated data: %d > %d (tag %v)", end, len(data), indexEntry.Info.Tag)
+		}
+		if skip[indexEntry.Info.Tag] {
+			continue
+		}
 		indexEntry.Data = data[start:end]
+		if err := checkEntry(&indexEntry); err != nil {
+			return nil, err
+		}
+
+		indexEntries[n] = indexEntry
+		n++
+	}
+	return indexEntries[:n], nil
+}
+
+// the size of an element of the fixed size types
+var typeSizes = map[TAG_TYPE]uint64{
+	RPM_CHAR_TYPE:  1,
+	RPM_INT8_TYPE:  1,
+	RPM_INT16_TYPE: 2,
+	RPM_INT32_TYPE: 4,
+	RPM_INT64_TYPE: 8,
+	RPM_BIN_TYPE:   1,
+}
+
+// checkEntry verifies that the data of an entry holds its count of elements, the way rpm's
+// dataLength does: every accessor calls it before reading, entries of a corrupt header included.
+func checkEntry(entry *indexEntry) error {
+	switch entry.Info.Type {
+	case RPM_NULL_TYPE:
+		return nil
+	case RPM_STRING_TYPE, RPM_STRING_ARRAY_TYPE, RPM_I18NSTRING_TYPE:
+		if n := bytes.Count(entry.Data, []byte{0}); n < int(entry.Info.Count) {
+			return fmt.Errorf("short string data: %d < %d strings (tag %v)", n, entry.Info.Count, entry.Info.Tag)
+		}
+		return nil
+	}
+
+	size, ok := typeSizes[entry.Info.Type]
+	if !ok {
+		return fmt.Errorf("invalid type: %v (tag %v)", entry.Info.Type, entry.Info.Tag)
+	}
+	if uint64(len(entry.Data)) < size*uint64(entry.Info.Count) {
+		return fmt.Errorf("short %v data: %d < %d (tag %v)", entry.Info.Type, len(entry.Data), size*uint64(entry.Info.Count), entry.Info.Tag)
+	}
+	return nil
+}
+
+func findEntry(indexEntries []indexEntry, tag TAG_ID) *indexEntry {
+	for i := range indexEntries {
+		if indexEntries[i].Info.Tag == tag {
+			return &indexEntries[i]
+		}
+	}
+	return nil
+}
+
+// entryStrings decodes string, string array and i18n string entries.
+func entryStrings(entry *indexEntry) ([]string, error) {
+	switch entry.Info.Type {
+	case RPM_STRING_TYPE, RPM_STRING_ARRAY_TYPE, RPM_I18NSTRING_TYPE:
+	default:
+		return nil, fmt.Errorf("invalid string type: %v (tag %v)", entry.Info.Type, entry.Info.Tag)
+	}
+	if err := checkEntry(entry); err != nil {
+		return nil, err
+	}
+
+	var values = make([]string, 0, entry.Info.Count)
+	subStrings := bytes.SplitN(entry.Data, []byte("\x00"), int(entry.Info.Count)+1)
+	for i := 0; i < int(entry.Info.Count) && i < len(subStrings); i++ {
+		values = append(values, string(subStrings[i]))
+	}
+	return values, nil
+}
+
+// The integers of headers are decoded unsigned whatever the name of their type, the way rpm's
+// rpmtdGetNumber and queryformat read them: CHAR and INT8 as uint8, INT16 as uint16, INT32 as uint32
+// and INT64 as uint64, never sign-extended. The accessors, entryNumber and entryValue follow it, so
+// TagsMap holds the unsigned types too. A value becomes signed only through the type of the field
+// it is stored in where rpm gives it a sign: FileState, whose 0xff is RPMFILE_STATE_MISSING. The
+// Epoch and Size of PackageInfo and the Size of FileInfo are signed for compatibility, holding the
+// unsigned value.
 
-		indexEntries[i] = indexEntry
+func entryUint32s(entry *indexEntry) ([]uint32, error) {
+	if entry.Info.Type != RPM_INT32_TYPE {
+		return nil, fmt.Errorf("invalid int32 type: %v (tag %v)", entry.Info.Type, entry.Info.Tag)
 	}
-	return indexEntries
+	if err := checkEntry(entry); err != nil {
+		return nil, err
+	}
+
+	var values = make([]uint32, entry.Info.Count)
+	for i := range values {
+		values[i] = binary.BigEndian.Uint32(entry.Data[4*i:])
+	}
+	return values, nil
+}
+
+func entryUint16s(entry *indexEntry) ([]uint16, error) {
+	if entry.Info.Type != RPM_INT16_TYPE {
+		return nil, fmt.Errorf("invalid int16 type: %v (tag %v)", entry.Info.Type, entry.Info.Tag)
+	}
+	if err := checkEntry(entry); err != nil {
+		return nil, err
+	}
+
+	var values = make([]uint16, entry.Info.Count)
+	for i := range values {
+		values[i] = binary.BigEndian.Uint16(entry.Data[2*i:])
+	}
+	return values, nil
+}
+
+func entryUint64s(entry *indexEntry) ([]uint64, error) {
+	if entry.Info.Type != RPM_INT64_TYPE {
+		return nil, fmt.Errorf("invalid int64 type: %v (tag %v)", entry.Info.Type, entry.Info.Tag)
+	}
+	if err := checkEntry(entry); err != nil {
+		return nil, err
+	}
+
+	var values = make([]uint64, entry.Info.Count)
+	for i := range values {
+		values[i] = binary.BigEndian.Uint64(entry.Data[8*i:])
+	}
+	return values, nil
+}
+
+// entryNumber decodes an entry holding one integer of any width, as rpm's headerGetNumber: packages
+// built by other tools than rpmbuild store some of the integers rpm writes as int32 in another type.
+func entryNumber(entry *indexEntry) (uint64, error) {
+	if entry.Info.Count != 1 {
+		return 0, fmt.Errorf("invalid count: %d (tag %v)", entry.Info.Count, entry.Info.Tag)
+	}
+	switch entry.Info.Type {
+	case RPM_CHAR_TYPE, RPM_INT8_TYPE:
+		values, err := entryBytes(entry)
+		if err != nil {
+			return 0, err
+		}
+		return uint64(values[0]), nil
+	case RPM_INT16_TYPE:
+		values, err := entryUint16s(entry)
+		if err != nil {
+			return 0, err
+		}
+		return uint64(values[0]), nil
+	case RPM_INT32_TYPE:
+		values, err := entryUint32s(entry)
+		if err != nil {
+			return 0, err
+		}
+		return uint64(values[0]), nil
+	case RPM_INT64_TYPE:
+		values, err := entryUint64s(entry)
+		if err != nil {
+			return 0, err
+		}
+		return values[0], nil
+	}
+	return 0, fmt.Errorf("invalid integer type: %v (tag %v)", entry.Info.Type, entry.Info.Tag)
+}
+
+// entryBytes decodes char and int8 entries.
+func entryBytes(entry *indexEntry) ([]byte, error) {
+	if entry.Info.Type != RPM_CHAR_TYPE && entry.Info.Type != RPM_INT8_TYPE {
+		return nil, fmt.Errorf("invalid int8 type: %v (tag %v)", entry.Info.Type, entry.Info.Tag)
+	}
+	if err := checkEntry(entry); err != nil {
+		return nil, err
+	}
+	return entry.Data[:entry.Info.Count], nil
+}
+
+// tagStrings returns the strings of the given tag, or nil when the header doesn't have it.
+func tagStrings(indexEntries []indexEntry, tag TAG_ID) ([]string, error) {
+	entry := findEntry(indexEntries, tag)
+	if entry == nil {
+		return nil, nil
+	}
+	return entryStrings(entry)
+}
+
+// tagUint32s returns the integers of the given tag, or nil when the header doesn't have it.
+func tagUint32s(indexEntries []indexEntry, tag TAG_ID) ([]uint32, error) {
+	entry := findEntry(indexEntries, tag)
+	if entry == nil {
+		return nil, nil
+	}
+	return entryUint32s(entry)
+}
+
+// tagUint16s returns the integers of the given tag, or nil when the header doesn't have it.
+func tagUint16s(indexEntries []indexEntry, tag TAG_ID) ([]uint16, error) {
+	entry := findEntry(indexEntries, tag)
+	if entry == nil {
+		return nil, nil
+	}
+	return entryUint16s(entry)
+}
+
+// tagUint64s returns the integers of the given tag, or nil when the header doesn't have it.
+func tagUint64s(indexEntries []indexEntry, tag TAG_ID) ([]uint64, error) {
+	entry := findEntry(indexEntries, tag)
+	if entry == nil {
+		return nil, nil
+	}
+	return entryUint64s(entry)
+}
+
+// tagBytes returns the bytes of the given char or int8 tag, or nil when the header doesn't have it.
+func tagBytes(indexEntries []indexEntry, tag TAG_ID) ([]byte, error) {
+	entry := findEntry(indexEntries, tag)
+	if entry == nil {
+		return nil, nil
+	}
+	return entryBytes(entry)
+}
+
+// immutableRegion returns the part of a header blob covered by its immutable region: the header as
+// it was built and signed, without the tags rpm added at install time.
+// ref. https://github.com/rpm-software-management/rpm/blob/rpm-4.11.3-release/lib/header.c#L878
+func immutableRegion(data []byte) ([]byte, error) {
+	if len(data) < 8+16 {
+		return nil, errors.New("header too short")
+	}
+	il := int32(binary.BigEndian.Uint32(data[0:]))
+	dl := int32(binary.BigEndian.Uint32(data[4:]))
+	dataStart := 8 + int(il)*16
+	if il < 1 || dl < 0 || dataStart+int(dl) > len(data) {
+		return nil, fmt.Errorf("invalid header lengths: il=%d dl=%d", il, dl)
+	}
+
+	// legacy headers, without region or with a region lacking trailer, are immutable as a whole
+	tag := TAG_ID(binary.BigEndian.Uint32(data[8:]))
+	if tag != HEADER_IMMUTABLE && tag != HEADER_SIGNATURES && tag != HEADER_IMAGE {
+		return data[:dataStart+int(dl)], nil
+	}
+	trailerOffset := int(int32(binary.BigEndian.Uint32(data[16:])))
+	if trailerOffset == 0 {
+		return data[:dataStart+int(dl)], nil
+	}
+	if trailerOffset < 0 || trailerOffset+16 > int(dl) {
+		return nil, fmt.Errorf("invalid region trailer offset: %d", trailerOffset)
+	}
+
+	trailer := data[dataStart+trailerOffset:]
+	ril := -int(int32(binary.BigEndian.Uint32(trailer[8:]))) / 16
+	rdl := trailerOffset + 16
+	if ril < 1 || ril > int(il) {
+		return nil, fmt.Errorf("invalid region index length: %d", ril)
+	}
+
+	region := make([]byte, 0, 8+ril*16+rdl)
+	region = append(region, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(region[0:], uint32(ril))
+	binary.BigEndian.PutUint32(region[4:], uint32(rdl))
+	region = append(region, data[8:8+ril*16]...)
+	region = append(region, data[dataStart:dataStart+rdl]...)
+	return region, nil
 }
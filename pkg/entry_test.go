@@ -0,0 +1,622 @@
+package rpmdb
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+// legacyHeader strips the region from a header built by HeaderBuilder, the layout of rpm 3 headers.
+func legacyHeader(t *testing.T, b *HeaderBuilder) []byte {
+	blob, err := b.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes() error: %v", err)
+	}
+	il := binary.BigEndian.Uint32(blob[0:])
+	dl := binary.BigEndian.Uint32(blob[4:])
+
+	// the region entry comes first and its trailer last, the other offsets stay valid
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header[0:], il-1)
+	binary.BigEndian.PutUint32(header[4:], dl-16)
+	header = append(header, blob[8+16:8+il*16]...)
+	return append(header, blob[8+il*16:8+il*16+dl-16]...)
+}
+
+// untrailedHeader prepends a region entry without trailer to a legacy header, like rpm 4 did when
+// converting rpm 3 headers to HEADER_IMAGE.
+func untrailedHeader(legacy []byte) []byte {
+	il := binary.BigEndian.Uint32(legacy[0:])
+	header := make([]byte, 8+16)
+	binary.BigEndian.PutUint32(header[0:], il+1)
+	copy(header[4:], legacy[4:8])
+	binary.BigEndian.PutUint32(header[8:], uint32(HEADER_IMAGE))
+	binary.BigEndian.PutUint32(header[12:], uint32(RPM_BIN_TYPE))
+	binary.BigEndian.PutUint32(header[20:], 16)
+	return append(header, legacy[8:]...)
+}
+
+// dribble appends entries past the region, the way rpm adds install time tags to rpmdb headers.
+func dribble(t *testing.T, blob []byte, b *HeaderBuilder) []byte {
+	extra, err := b.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes() error: %v", err)
+	}
+	il := binary.BigEndian.Uint32(blob[0:])
+	dl := binary.BigEndian.Uint32(blob[4:])
+	eil := binary.BigEndian.Uint32(extra[0:])
+	edl := binary.BigEndian.Uint32(extra[4:])
+
+	// keep the natural alignment of the dribbled data
+	pad := (8 - dl%8) % 8
+
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header[0:], il+eil-1)
+	binary.BigEndian.PutUint32(header[4:], dl+pad+edl-16)
+	header = append(header, blob[8:8+il*16]...)
+	for i := uint32(1); i < eil; i++ {
+		pe := append([]byte{}, extra[8+i*16:8+(i+1)*16]...)
+		binary.BigEndian.PutUint32(pe[8:], binary.BigEndian.Uint32(pe[8:])+dl+pad)
+		header = append(header, pe...)
+	}
+	header = append(header, blob[8+il*16:8+il*16+dl]...)
+	header = append(header, make([]byte, pad)...)
+	return append(header, extra[8+eil*16:8+eil*16+edl-16]...)
+}
+
+func TestHeaderImport(t *testing.T) {
+	pkg := func() *HeaderBuilder {
+		b := NewHeaderBuilder()
+		b.AddString(RPMTAG_NAME, "foo")
+		b.AddString(RPMTAG_VERSION, "1.0")
+		b.AddString(RPMTAG_RELEASE, "1")
+		b.AddString(RPMTAG_ARCH, "noarch")
+		b.AddInt32(RPMTAG_SIZE, 42)
+		b.AddStringArray(RPMTAG_OLDFILENAMES, []string{"/usr/bin/foo"})
+		return b
+	}
+	blob := func(b *HeaderBuilder) []byte {
+		data, err := b.Bytes()
+		if err != nil {
+			t.Fatalf("Bytes() error: %v", err)
+		}
+		return data
+	}
+
+	install := NewHeaderBuilder()
+	install.AddInt32(RPMTAG_INSTALLTIME, 1500000000)
+	install.AddInt32(RPMTAG_INSTALLTID, 1500000000)
+
+	// rpm 4.19 rewrites relocated file lists and sizes as dribbles
+	relocated := NewHeaderBuilder()
+	relocated.AddInt32(RPMTAG_SIZE, 43)
+	relocated.AddStringArray(RPMTAG_BASENAMES, []string{"foo"})
+	relocated.AddStringArray(RPMTAG_DIRNAMES, []string{"/opt/bin/"})
+	relocated.AddInt32(RPMTAG_DIRINDEXES, 0)
+
+	vectors := []struct {
+		name   string
+		header []byte
+		size   uint32
+		files  []string
+		tags   []TAG_ID
+		// tags the dribbles replaced
+		deleted []TAG_ID
+	}{
+		{
+			name:   "rpm 3 legacy",
+			header: legacyHeader(t, pkg()),
+			size:   42,
+			files:  []string{"/usr/bin/foo"},
+		},
+		{
+			name:   "rpm 4.8 converted legacy",
+			header: untrailedHeader(legacyHeader(t, pkg())),
+			size:   42,
+			files:  []string{"/usr/bin/foo"},
+		},
+		{
+			name:   "rpm 4.14 installed",
+			header: dribble(t, blob(pkg()), install),
+			size:   42,
+			files:  []string{"/usr/bin/foo"},
+			tags:   []TAG_ID{RPMTAG_INSTALLTIME, RPMTAG_INSTALLTID},
+		},
+		{
+			name:    "rpm 4.19 installed with replaced tags",
+			header:  dribble(t, dribble(t, blob(pkg()), install), relocated),
+			size:    43,
+			files:   []string{"/opt/bin/foo"},
+			tags:    []TAG_ID{RPMTAG_INSTALLTIME, RPMTAG_BASENAMES},
+			deleted: []TAG_ID{RPMTAG_OLDFILENAMES},
+		},
+	}
+
+	for _, v := range vectors {
+		t.Run(v.name, func(t *testing.T) {
+			indexEntries, err := headerImport(v.header)
+			if err != nil {
+				t.Fatalf("headerImport() error: %v", err)
+			}
+
+			seen := make(map[TAG_ID]bool)
+			for _, entry := range indexEntries {
+				if seen[entry.Info.Tag] {
+					t.Errorf("duplicate tag %v", entry.Info.Tag)
+				}
+				seen[entry.Info.Tag] = true
+				if entry.Info.Tag < HEADER_I18NTABLE {
+					t.Errorf("region tag %v imported", entry.Info.Tag)
+				}
+			}
+
+			pkg, err := getNEVRA(indexEntries)
+			if err != nil {
+				t.Fatalf("getNEVRA() error: %v", err)
+			}
+			if pkg.NEVRA() != "foo-1.0-1.noarch" {
+				t.Errorf("NEVRA: got %s, want foo-1.0-1.noarch", pkg.NEVRA())
+			}
+
+			sizes, err := tagUint32s(indexEntries, RPMTAG_SIZE)
+			if err != nil {
+				t.Fatalf("invalid tag size: %v", err)
+			}
+			if !reflect.DeepEqual(sizes, []uint32{v.size}) {
+				t.Errorf("size: got %v, want %d", sizes, v.size)
+			}
+
+			files, err := fileNames(indexEntries)
+			if err != nil {
+				t.Fatalf("fileNames() error: %v", err)
+			}
+			if !reflect.DeepEqual(files, v.files) {
+				t.Errorf("files: got %v, want %v", files, v.files)
+			}
+
+			for _, tag := range v.tags {
+				if !seen[tag] {
+					t.Errorf("missing %v", tag)
+				}
+			}
+			for _, tag := range v.deleted {
+				if seen[tag] {
+					t.Errorf("%v not deleted", tag)
+				}
+			}
+		})
+	}
+}
+
+func TestMalformedHeader(t *testing.T) {
+	types := []struct {
+		name  string
+		typ   TAG_TYPE
+		count uint32
+		data  []byte
+	}{
+		{name: "char", typ: RPM_CHAR_TYPE, count: 5, data: []byte("abcd")},
+		{name: "int8", typ: RPM_INT8_TYPE, count: 5, data: []byte{1, 2, 3, 4}},
+		{name: "int16", typ: RPM_INT16_TYPE, count: 3, data: []byte{0, 1, 0, 2}},
+		{name: "int32", typ: RPM_INT32_TYPE, count: 2, data: []byte{0, 0, 0, 1}},
+		{name: "int64", typ: RPM_INT64_TYPE, count: 1, data: []byte{0, 0, 0, 1}},
+		{name: "string", typ: RPM_STRING_TYPE, count: 1, data: []byte("foo")},
+		{name: "string array", typ: RPM_STRING_ARRAY_TYPE, count: 3, data: []byte("foo\x00bar\x00")},
+		{name: "i18n string", typ: RPM_I18NSTRING_TYPE, count: 2, data: []byte("foo\x00")},
+		{name: "bin", typ: RPM_BIN_TYPE, count: 1 << 31, data: []byte{0xde, 0xad, 0xbe, 0xef}},
+		{name: "unknown type", typ: TAG_TYPE(42), count: 1, data: []byte{0, 0, 0, 0}},
+	}
+	for _, v := range types {
+		t.Run(v.name, func(t *testing.T) {
+			entry := indexEntry{
+				Info:   entryInfo{Tag: RPMTAG_DESCRIPTION, Type: v.typ, Count: v.count},
+				Length: len(v.data),
+				Data:   v.data,
+			}
+			if _, err := entryValue(&entry); err == nil {
+				t.Errorf("entryValue() succeeded")
+			}
+
+			b := NewHeaderBuilder()
+			b.add(RPMTAG_DESCRIPTION, v.typ, v.count, v.data)
+			blob, err := b.Bytes()
+			if err != nil {
+				t.Fatalf("Bytes() error: %v", err)
+			}
+			if _, err := headerImport(blob); err == nil {
+				t.Errorf("headerImport() succeeded")
+			}
+			if _, err := headerImport(legacyHeader(t, b)); err == nil {
+				t.Errorf("headerImport() of the legacy header succeeded")
+			}
+		})
+	}
+
+	// each accessor rejects the entries it could read past
+	accessors := []struct {
+		name  string
+		typ   TAG_TYPE
+		check func(*indexEntry) error
+	}{
+		{"entryStrings", RPM_STRING_ARRAY_TYPE, func(e *indexEntry) error { _, err := entryStrings(e); return err }},
+		{"entryUint16s", RPM_INT16_TYPE, func(e *indexEntry) error { _, err := entryUint16s(e); return err }},
+		{"entryUint32s", RPM_INT32_TYPE, func(e *indexEntry) error { _, err := entryUint32s(e); return err }},
+		{"entryUint64s", RPM_INT64_TYPE, func(e *indexEntry) error { _, err := entryUint64s(e); return err }},
+		{"entryBytes", RPM_INT8_TYPE, func(e *indexEntry) error { _, err := entryBytes(e); return err }},
+		{"dumpEntry", RPM_BIN_TYPE, dumpEntry},
+	}
+	for _, a := range accessors {
+		entry := indexEntry{Info: entryInfo{Tag: RPMTAG_DESCRIPTION, Type: a.typ, Count: 1 << 20}, Data: []byte{1, 0}}
+		if err := a.check(&entry); err == nil {
+			t.Errorf("%s() accepted a count of %d for %d bytes", a.name, entry.Info.Count, len(entry.Data))
+		}
+	}
+
+	b := NewHeaderBuilder()
+	b.AddString(RPMTAG_NAME, "foo")
+	b.AddInt32(RPMTAG_SIZE, 42)
+	valid, err := b.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes() error: %v", err)
+	}
+	if _, err := headerImport(valid); err != nil {
+		t.Fatalf("headerImport() error: %v", err)
+	}
+	patch := func(offset int, value uint32) []byte {
+		blob := append([]byte{}, valid...)
+		binary.BigEndian.PutUint32(blob[offset:], value)
+		return blob
+	}
+
+	// the entry of RPMTAG_NAME is the second one, after the region entry
+	layouts := []struct {
+		name   string
+		header []byte
+	}{
+		{name: "truncated index", header: valid[:20]},
+		{name: "truncated data", header: valid[:len(valid)-8]},
+		{name: "huge index length", header: patch(0, 0x7fffffff)},
+		{name: "negative index length", header: patch(0, 0xffffffff)},
+		{name: "negative data length", header: patch(4, 0xfffffff0)},
+		{name: "data offset past the region", header: patch(8+16+8, 0x1000)},
+		{name: "negative data offset", header: patch(8+16+8, 0xfffffffc)},
+		{name: "region trailer past the data", header: patch(8+8, 0x1000)},
+	}
+	for _, v := range layouts {
+		if _, err := headerImport(v.header); err == nil {
+			t.Errorf("%s: headerImport() succeeded", v.name)
+		}
+	}
+}
+
+func TestDuplicateTags(t *testing.T) {
+	b := NewHeaderBuilder()
+	b.AddString(RPMTAG_NAME, "foo")
+	b.AddString(RPMTAG_VERSION, "1.0")
+	b.AddString(RPMTAG_RELEASE, "1")
+	b.AddString(RPMTAG_SUMMARY, "first summary")
+	b.AddString(RPMTAG_VENDOR, "bar")
+	b.AddString(RPMTAG_LICENSE, "second summary")
+	b.AddString(RPMTAG_ARCH, "noarch")
+	blob, err := b.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes() error: %v", err)
+	}
+
+	// retag the vendor and license entries, the way a buggy tool would write a tag twice
+	il := binary.BigEndian.Uint32(blob[0:])
+	for i := uint32(0); i < il; i++ {
+		pe := blob[8+16*i:]
+		switch TAG_ID(binary.BigEndian.Uint32(pe)) {
+		case RPMTAG_VENDOR:
+			binary.BigEndian.PutUint32(pe, uint32(RPMTAG_NAME))
+		case RPMTAG_LICENSE:
+			binary.BigEndian.PutUint32(pe, uint32(RPMTAG_SUMMARY))
+		}
+	}
+
+	indexEntries, err := headerImport(blob)
+	if err != nil {
+		t.Fatalf("headerImport() error: %v", err)
+	}
+	pkg, err := getNEVRA(indexEntries)
+	if err != nil {
+		t.Fatalf("getNEVRA() error: %v", err)
+	}
+	if pkg.NEVRA() != "foo-1.0-1.noarch" {
+		t.Errorf("NEVRA: got %s, want foo-1.0-1.noarch", pkg.NEVRA())
+	}
+	pkgEx, err := getPackageWithTags(indexEntries, map[TAG_ID]bool{RPMTAG_SUMMARY: true}, false)
+	if err != nil {
+		t.Fatalf("getPackageWithTags() error: %v", err)
+	}
+	if pkgEx.Name != "foo" || pkgEx.TagsMap[RPMTAG_SUMMARY] != "first summary" {
+		t.Errorf("getPackageWithTags(): got %s and %v, want foo and first summary", pkgEx.Name, pkgEx.TagsMap[RPMTAG_SUMMARY])
+	}
+
+	header, err := ParseHeader(blob)
+	if err != nil {
+		t.Fatalf("ParseHeader() error: %v", err)
+	}
+	name, err := header.Get(RPMTAG_NAME)
+	if err != nil || name != "foo" {
+		t.Errorf("Get(): got %v, %v, want foo", name, err)
+	}
+	names, err := header.All(RPMTAG_NAME)
+	if err != nil {
+		t.Fatalf("All() error: %v", err)
+	}
+	if !reflect.DeepEqual(names, []interface{}{"foo", "bar"}) {
+		t.Errorf("All(): got %v, want [foo bar]", names)
+	}
+	tags := header.Tags()
+	want := []TAG_ID{RPMTAG_NAME, RPMTAG_VERSION, RPMTAG_RELEASE, RPMTAG_SUMMARY, RPMTAG_ARCH}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("Tags(): got %v, want %v", tags, want)
+	}
+	if v, err := header.Get(RPMTAG_VENDOR); v != nil || err != nil {
+		t.Errorf("Get() of a missing tag: got %v, %v", v, err)
+	}
+}
+
+func TestSentinelValues(t *testing.T) {
+	b := NewHeaderBuilder()
+	b.AddString(RPMTAG_NAME, "foo")
+	b.AddString(RPMTAG_VERSION, "1.0")
+	b.AddString(RPMTAG_RELEASE, "1")
+	b.AddString(RPMTAG_ARCH, "noarch")
+	b.AddString(RPMTAG_VENDOR, "(none)")
+	b.AddString(RPMTAG_LICENSE, " ")
+	b.AddString(RPMTAG_SOURCERPM, "(none)")
+	b.AddString(RPMTAG_URL, "(none)")
+	b.AddString(RPMTAG_PACKAGER, "Jane Doe")
+	b.AddString(RPMTAG_BUILDHOST, "(none)")
+	b.AddString(RPMTAG_DISTRIBUTION, "(none)")
+	blob, err := b.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes() error: %v", err)
+	}
+	indexEntries, err := headerImport(blob)
+	if err != nil {
+		t.Fatalf("headerImport() error: %v", err)
+	}
+
+	want := &PackageInfo{Name: "foo", Version: "1.0", Release: "1", Arch: "noarch", Packager: "Jane Doe"}
+	pkg, err := getNEVRA(indexEntries)
+	if err != nil {
+		t.Fatalf("getNEVRA() error: %v", err)
+	}
+	if !reflect.DeepEqual(pkg, want) {
+		t.Errorf("getNEVRA() got %+v, want %+v", pkg, want)
+	}
+	if got := pkg.NEVRA(); got != "foo-1.0-1.noarch" {
+		t.Errorf("NEVRA() got %s", got)
+	}
+
+	ex, err := getPackageWithTags(indexEntries, map[TAG_ID]bool{RPMTAG_URL: true, RPMTAG_PACKAGER: true, RPMTAG_DISTRIBUTION: true}, false)
+	if err != nil {
+		t.Fatalf("getPackageWithTags() error: %v", err)
+	}
+	if !reflect.DeepEqual(&ex.PackageInfo, want) {
+		t.Errorf("getPackageWithTags() got %+v, want %+v", ex.PackageInfo, want)
+	}
+	wantTags := map[TAG_ID]interface{}{RPMTAG_URL: "", RPMTAG_PACKAGER: "Jane Doe", RPMTAG_DISTRIBUTION: ""}
+	if !reflect.DeepEqual(ex.TagsMap, wantTags) {
+		t.Errorf("got tags %v, want %v", ex.TagsMap, wantTags)
+	}
+
+	p, err := packageProvenance(indexEntries, pkg)
+	if err != nil {
+		t.Fatalf("packageProvenance() error: %v", err)
+	}
+	if p.BuildHost != "" {
+		t.Errorf("got build host %q", p.BuildHost)
+	}
+}
+
+func TestRawValues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmdb-raw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	b := verifyTestHeader(nil, 0)
+	b.AddString(RPMTAG_SUMMARY, "foo")
+	b.add(RPMTAG_DISTTAG, RPM_NULL_TYPE, 0, nil)
+	dbPath := createTestDB(t, dir, b)
+
+	tags := []TAG_ID{RPMTAG_SUMMARY, RPMTAG_DISTTAG}
+	for _, raw := range []bool{false, true} {
+		var opts []Option
+		if raw {
+			opts = append(opts, WithRawValues())
+		}
+		db, err := Open(dbPath, opts...)
+		if err != nil {
+			t.Fatalf("Open() error: %v", err)
+		}
+		pkgs, err := db.ListPackagesWithTags(tags...)
+		db.Close()
+		if err != nil {
+			t.Fatalf("ListPackagesWithTags() error: %v", err)
+		}
+
+		if got := pkgs[0].TagsMap[RPMTAG_SUMMARY]; got != "foo" {
+			t.Errorf("summary: got %v, want foo", got)
+		}
+		value, ok := pkgs[0].TagsMap[RPMTAG_DISTTAG]
+		if !raw {
+			if ok {
+				t.Errorf("unsupported value without WithRawValues: %v", value)
+			}
+			continue
+		}
+		if rawValue, ok := value.(RawValue); !ok || rawValue.Type != RPM_NULL_TYPE || len(rawValue.Data) != 0 {
+			t.Errorf("raw value: got %#v", value)
+		}
+	}
+}
+
+// randomValues are the values of the file tags of a random header, one per file for each of the
+// numeric types rpm has.
+type randomValues struct {
+	names  []string
+	states []uint8
+	modes  []uint16
+	sizes  []uint32
+	longs  []uint64
+}
+
+// Generate implements quick.Generator, with at least a file and names without NUL.
+func (randomValues) Generate(rnd *rand.Rand, size int) reflect.Value {
+	n := 1 + rnd.Intn(size+1)
+	v := randomValues{}
+	for i := 0; i < n; i++ {
+		name := make([]byte, rnd.Intn(16))
+		for j := range name {
+			name[j] = byte(1 + rnd.Intn(255))
+		}
+		v.names = append(v.names, string(name))
+		v.states = append(v.states, uint8(rnd.Uint32()))
+		v.modes = append(v.modes, uint16(rnd.Uint32()))
+		v.sizes = append(v.sizes, rnd.Uint32())
+		v.longs = append(v.longs, rnd.Uint64())
+	}
+	return reflect.ValueOf(v)
+}
+
+func (v randomValues) header(name string) *HeaderBuilder {
+	b := NewHeaderBuilder()
+	b.AddString(RPMTAG_NAME, name)
+	b.AddString(RPMTAG_VERSION, "1.0")
+	b.AddString(RPMTAG_RELEASE, "1")
+	b.AddString(RPMTAG_ARCH, "x86_64")
+	b.AddStringArray(RPMTAG_FILEUSERNAME, v.names)
+	b.AddInt8(RPMTAG_FILESTATES, v.states...)
+	b.AddInt16(RPMTAG_FILEMODES, v.modes...)
+	b.AddInt32(RPMTAG_FILESIZES, v.sizes...)
+	b.AddInt64(RPMTAG_LONGFILESIZES, v.longs...)
+	return b
+}
+
+func TestRandomHeaderValues(t *testing.T) {
+	decode := func(v randomValues) bool {
+		blob, err := v.header("foo").Bytes()
+		if err != nil {
+			t.Fatalf("Bytes() error: %v", err)
+		}
+		indexEntries, err := headerImport(blob)
+		if err != nil {
+			t.Logf("headerImport() error: %v", err)
+			return false
+		}
+		names, err1 := tagStrings(indexEntries, RPMTAG_FILEUSERNAME)
+		states, err2 := tagBytes(indexEntries, RPMTAG_FILESTATES)
+		modes, err3 := tagUint16s(indexEntries, RPMTAG_FILEMODES)
+		sizes, err4 := tagUint32s(indexEntries, RPMTAG_FILESIZES)
+		longs, err5 := tagUint64s(indexEntries, RPMTAG_LONGFILESIZES)
+		for _, err := range []error{err1, err2, err3, err4, err5} {
+			if err != nil {
+				t.Logf("accessor error: %v", err)
+				return false
+			}
+		}
+		if !reflect.DeepEqual(randomValues{names, states, modes, sizes, longs}, v) {
+			return false
+		}
+
+		// entryValue decodes the first element
+		firsts := map[TAG_ID]interface{}{
+			RPMTAG_FILESTATES:    v.states[0],
+			RPMTAG_FILEMODES:     v.modes[0],
+			RPMTAG_FILESIZES:     v.sizes[0],
+			RPMTAG_LONGFILESIZES: v.longs[0],
+		}
+		for tag, want := range firsts {
+			if got, err := entryValue(findEntry(indexEntries, tag)); err != nil || got != want {
+				t.Logf("entryValue(%v) got %v, %v, want %v", tag, got, err, want)
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(decode, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+
+	// a single byte entry was read twice
+	entry := indexEntry{Info: entryInfo{Tag: RPMTAG_FILESTATES, Type: RPM_CHAR_TYPE, Count: 1}, Data: []byte{7}}
+	if got, err := entryValue(&entry); err != nil || got != uint8(7) {
+		t.Errorf("entryValue() of a char got %v, %v", got, err)
+	}
+	entry.Info.Count = 0
+	if _, err := entryValue(&entry); err == nil || !strings.Contains(err.Error(), "empty") {
+		t.Errorf("entryValue() of an empty entry got error %v", err)
+	}
+}
+
+func TestSignedness(t *testing.T) {
+	b := NewHeaderBuilder()
+	b.AddString(RPMTAG_NAME, "foo")
+	b.AddInt32(RPMTAG_EPOCH, 0xffffffff)
+	b.AddInt8(RPMTAG_FILESTATES, 0xff)
+	b.AddInt16(RPMTAG_FILEMODES, 0xffff)
+	b.AddInt32(RPMTAG_FILESIZES, 0xffffffff)
+	b.AddInt64(RPMTAG_LONGFILESIZES, 0xffffffffffffffff)
+	b.AddStringArray(RPMTAG_BASENAMES, []string{"foo"})
+	b.AddStringArray(RPMTAG_DIRNAMES, []string{"/"})
+	b.AddInt32(RPMTAG_DIRINDEXES, 0)
+	blob, err := b.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes() error: %v", err)
+	}
+	indexEntries, err := headerImport(blob)
+	if err != nil {
+		t.Fatalf("headerImport() error: %v", err)
+	}
+
+	tags := []TAG_ID{RPMTAG_EPOCH, RPMTAG_FILESTATES, RPMTAG_FILEMODES, RPMTAG_FILESIZES, RPMTAG_LONGFILESIZES}
+	mask := make(map[TAG_ID]bool)
+	for _, tag := range tags {
+		mask[tag] = true
+	}
+	pkg, err := getPackageWithTags(indexEntries, mask, false)
+	if err != nil {
+		t.Fatalf("getPackageWithTags() error: %v", err)
+	}
+	wants := map[TAG_ID]interface{}{
+		RPMTAG_FILESTATES:    uint8(0xff),
+		RPMTAG_FILEMODES:     uint16(0xffff),
+		RPMTAG_FILESIZES:     uint32(0xffffffff),
+		RPMTAG_LONGFILESIZES: uint64(0xffffffffffffffff),
+	}
+	for tag, want := range wants {
+		if got := pkg.TagsMap[tag]; got != want {
+			t.Errorf("%v: got %#v, want %#v", tag, got, want)
+		}
+		if n, ok := pkg.Number(tag); !ok || n != reflect.ValueOf(want).Uint() {
+			t.Errorf("Number(%v) got %d, %v", tag, n, ok)
+		}
+	}
+	if pkg.Epoch != 0xffffffff {
+		t.Errorf("got epoch %d, want %d", pkg.Epoch, uint32(0xffffffff))
+	}
+	if _, ok := pkg.Number(RPMTAG_NAME); ok {
+		t.Error("Number() of a string succeeded")
+	}
+
+	files, err := fileInfos(indexEntries)
+	if err != nil {
+		t.Fatalf("fileInfos() error: %v", err)
+	}
+	// the state is the one value rpm reads signed
+	if files[0].State != RPMFILE_STATE_MISSING || files[0].Mode != 0xffff {
+		t.Errorf("got file %+v", files[0])
+	}
+}
@@ -0,0 +1,81 @@
+package rpmdb
+
+import (
+	"fmt"
+	"sync"
+)
+
+// WithEntryPool makes the listings decode every header into index tables reused from a package to
+// the next, and from a listing to the next through a sync.Pool, instead of allocating them per
+// package: an agent scanning many roots at a high rate then mostly allocates the packages it gets.
+//
+// Only the tables are reused. The data of the entries are slices of the header blob, read anew for
+// every package, and nothing a listing returns refers to the tables: the values of
+// ListPackagesWithTags are copies.
+func WithEntryPool() Option {
+	return func(d *RpmDB) {
+		d.entryPool = true
+	}
+}
+
+// entryArena holds the tables importHeader decodes a header into. The entries returned alias them
+// until the next import: they must not be retained past the header, which is why importHeader gets
+// no arena wherever the entries outlive it (ParseHeader, the headers of package files).
+type entryArena struct {
+	infos   []entryInfo
+	entries []indexEntry
+}
+
+var entryArenas = sync.Pool{
+	New: func() interface{} { return new(entryArena) },
+}
+
+// entryInfos returns a table of n entry infos, a new one for a nil arena.
+func (a *entryArena) entryInfos(n int) []entryInfo {
+	if a == nil {
+		return make([]entryInfo, n)
+	}
+	if cap(a.infos) < n {
+		a.infos = make([]entryInfo, n)
+	}
+	return a.infos[:n]
+}
+
+// indexEntries returns a table of n index entries, a new one for a nil arena.
+func (a *entryArena) indexEntries(n int) []indexEntry {
+	if a == nil {
+		return make([]indexEntry, n)
+	}
+	if cap(a.entries) < n {
+		a.entries = make([]indexEntry, n)
+	}
+	a.entries = a.entries[:n]
+	return a.entries
+}
+
+// release returns the arena to the pool, cleared so that it doesn't keep the last blobs alive.
+func (a *entryArena) release() {
+	a.entries = a.entries[:cap(a.entries)]
+	for i := range a.entries {
+		a.entries[i] = indexEntry{}
+	}
+	entryArenas.Put(a)
+}
+
+// forEachIndex calls fn with the decoded entries of every header, but the tags WithoutFiles skips,
+// from the tables of a pooled arena with WithEntryPool: fn must not retain indexEntries once it
+// returns.
+func (d *RpmDB) forEachIndex(fn func(hnum uint32, indexEntries []indexEntry) error) error {
+	var arena *entryArena
+	if d.entryPool {
+		arena = entryArenas.Get().(*entryArena)
+		defer arena.release()
+	}
+	return d.forEachHeader(func(hnum uint32, blob []byte) error {
+		indexEntries, err := importHeader(blob, arena, d.skipTags)
+		if err != nil {
+			return fmt.Errorf("error during importing header: %w", err)
+		}
+		return fn(hnum, indexEntries)
+	})
+}
@@ -0,0 +1,211 @@
+package rpmdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// PackageEventType is what happened to a package.
+type PackageEventType int
+
+const (
+	// PackageInstalled is a package installed, no other version of it being installed before.
+	PackageInstalled PackageEventType = iota
+	// PackageRemoved is a package erased, no other version of it replacing it.
+	PackageRemoved
+	// PackageUpgraded is a package replaced by a newer version.
+	PackageUpgraded
+	// PackageDowngraded is a package replaced by an older version.
+	PackageDowngraded
+	// PackageReinstalled is a package replaced by another build of the same version, a rpm
+	// --reinstall or a rebuilt package.
+	PackageReinstalled
+)
+
+var packageEventTypeNames = map[PackageEventType]string{
+	PackageInstalled:   "installed",
+	PackageRemoved:     "removed",
+	PackageUpgraded:    "upgraded",
+	PackageDowngraded:  "downgraded",
+	PackageReinstalled: "reinstalled",
+}
+
+// String returns the name of the type.
+func (t PackageEventType) String() string {
+	if name, ok := packageEventTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("PackageEventType(%d)", int(t))
+}
+
+// MarshalText encodes the type as its name, "upgraded" in the JSON of an event.
+func (t PackageEventType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// PackageEvent is a change to a package, one record of an audit log.
+type PackageEvent struct {
+	Type PackageEventType `json:"type"`
+	// Time is the INSTALLTIME of the package installed, when rpm ran the transaction; it is when
+	// the change was noticed for a removal, rpm not recording it.
+	Time time.Time `json:"time"`
+	Name string    `json:"name"`
+	Arch string    `json:"arch,omitempty"`
+	// Before is the EVR of the package replaced or removed, After the one of the package
+	// installed.
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+	// TID is the INSTALLTID of the package installed, the transaction installing it: the events of
+	// a dnf update share it.
+	TID uint32 `json:"tid,omitempty"`
+	// Backfilled is set on the events of the changes made before the watch started, while the
+	// agent was down.
+	Backfilled bool `json:"backfilled,omitempty"`
+
+	// Package is the package installed, the one removed for PackageRemoved.
+	Package *PackageInfo `json:"-"`
+}
+
+// Events returns the events of the delta, sorted by time and transaction.
+func (d *PackageDelta) Events() []*PackageEvent {
+	var events []*PackageEvent
+	installed := func(typ PackageEventType, pkg *PackageInfo) *PackageEvent {
+		event := &PackageEvent{
+			Type:    typ,
+			Time:    pkg.InstallTime,
+			Name:    pkg.Name,
+			Arch:    pkg.Arch,
+			After:   pkg.EVR(),
+			TID:     d.installTIDs[pkg],
+			Package: pkg,
+		}
+		if event.Time.IsZero() {
+			event.Time = d.Time
+		}
+		events = append(events, event)
+		return event
+	}
+	for _, pkg := range d.Added {
+		installed(PackageInstalled, pkg)
+	}
+	for _, change := range d.Changed {
+		typ := PackageReinstalled
+		switch cmp := CompareEVR(change.After, change.Before); {
+		case cmp > 0:
+			typ = PackageUpgraded
+		case cmp < 0:
+			typ = PackageDowngraded
+		}
+		installed(typ, change.After).Before = change.Before.EVR()
+	}
+	for _, pkg := range d.Removed {
+		events = append(events, &PackageEvent{
+			Type:    PackageRemoved,
+			Time:    d.Time,
+			Name:    pkg.Name,
+			Arch:    pkg.Arch,
+			Before:  pkg.EVR(),
+			Package: pkg,
+		})
+	}
+	sortEvents(events)
+	return events
+}
+
+func sortEvents(events []*PackageEvent) {
+	sort.SliceStable(events, func(i, j int) bool {
+		a, b := events[i], events[j]
+		if !a.Time.Equal(b.Time) {
+			return a.Time.Before(b.Time)
+		}
+		if a.TID != b.TID {
+			return a.TID < b.TID
+		}
+		return a.Name < b.Name
+	})
+}
+
+// EventSink receives the events of WatchEvents, a batch per scan with the manifest of the
+// database as scanned. Saving the manifest once the events are written, and passing it as
+// WatchOptions.Previous at the next start, backfills the changes made while the agent was down
+// without writing an event twice.
+type EventSink interface {
+	WriteEvents(events []*PackageEvent, manifest *PackageManifest) error
+}
+
+// EventSinkFunc is a function used as an EventSink.
+type EventSinkFunc func(events []*PackageEvent, manifest *PackageManifest) error
+
+// WriteEvents calls f.
+func (f EventSinkFunc) WriteEvents(events []*PackageEvent, manifest *PackageManifest) error {
+	return f(events, manifest)
+}
+
+type jsonEventSink struct {
+	encoder *json.Encoder
+}
+
+// NewJSONEventSink returns a sink writing the events to w as JSON lines:
+//
+//	{"type":"upgraded","time":"2023-03-01T10:05:00Z","name":"bash","arch":"x86_64","before":"4.2.46-31.el7","after":"4.2.46-34.el7","tid":1677665100}
+func NewJSONEventSink(w io.Writer) EventSink {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return &jsonEventSink{encoder: encoder}
+}
+
+func (s *jsonEventSink) WriteEvents(events []*PackageEvent, manifest *PackageManifest) error {
+	for _, event := range events {
+		if err := s.encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EventOptions configures WatchEvents.
+type EventOptions struct {
+	WatchOptions
+	// Since backfills, when Previous is nil, the packages whose INSTALLTIME is after it as
+	// PackageInstalled events: the packages upgraded since can't be told from the ones installed,
+	// nor the ones removed seen, without the manifest of the previous scan. When both are zero,
+	// the packages installed at the start have no events.
+	Since time.Time
+}
+
+// WatchEvents watches the database at path like WatchFunc, writing the events of every change to
+// sink. The first batch has the backfilled events, possibly none: it is written anyway for the
+// sink to save the manifest. It returns the error of the first scan or of sink, or ctx.Err() once
+// ctx is canceled.
+func WatchEvents(ctx context.Context, path string, opts *EventOptions, sink EventSink) error {
+	if opts == nil {
+		opts = &EventOptions{}
+	}
+	first := true
+	return WatchFunc(ctx, path, &opts.WatchOptions, func(delta *PackageDelta) error {
+		events := delta.Events()
+		if first {
+			first = false
+			events = backfillEvents(events, opts)
+		}
+		return sink.WriteEvents(events, delta.Manifest)
+	})
+}
+
+// backfillEvents marks the events of the first delta as backfilled, keeping the installs after
+// opts.Since only when there is no previous manifest to tell what changed.
+func backfillEvents(events []*PackageEvent, opts *EventOptions) []*PackageEvent {
+	var backfilled []*PackageEvent
+	for _, event := range events {
+		if opts.Previous == nil && (opts.Since.IsZero() || !event.Package.InstallTime.After(opts.Since)) {
+			continue
+		}
+		event.Backfilled = true
+		backfilled = append(backfilled, event)
+	}
+	return backfilled
+}
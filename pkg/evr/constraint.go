@@ -0,0 +1,127 @@
+package evr
+
+import (
+	"fmt"
+	"strings"
+
+	rpmdb "github.com/chennqqi/go-rpmdb/pkg"
+)
+
+// Operator is a comparison operator of a constraint.
+type Operator string
+
+const (
+	Less         Operator = "<"
+	LessEqual    Operator = "<="
+	Equal        Operator = "="
+	GreaterEqual Operator = ">="
+	Greater      Operator = ">"
+)
+
+// the operators in parsing order: two character operators first
+var operators = []Operator{LessEqual, GreaterEqual, Less, Greater, Equal}
+
+// Constraint is a single "operator evr" condition, e.g. "< 0:2.4.37-43.module+el8".
+type Constraint struct {
+	Op  Operator
+	EVR EVR
+	// Module restricts the constraint to packages built for the "name:stream" module stream. When empty
+	// and the EVR is a modular release, only modular packages can match, which prevents a fix released
+	// in a module stream from being evaluated against the non-modular build of the same package.
+	Module string
+}
+
+// ParseConstraint parses "op evr", the space being optional. "==" is accepted for "=" and a missing
+// operator means "=".
+func ParseConstraint(s string) (Constraint, error) {
+	s = strings.TrimSpace(s)
+	c := Constraint{Op: Equal}
+
+	if strings.HasPrefix(s, "==") {
+		s = s[2:]
+	} else {
+		for _, op := range operators {
+			if strings.HasPrefix(s, string(op)) {
+				c.Op = op
+				s = s[len(op):]
+				break
+			}
+		}
+	}
+
+	evr, err := Parse(s)
+	if err != nil {
+		return c, fmt.Errorf("invalid constraint: %w", err)
+	}
+	c.EVR = evr
+	return c, nil
+}
+
+func (c Constraint) String() string {
+	return string(c.Op) + " " + c.EVR.String()
+}
+
+// Eval evaluates the constraint against an EVR, ignoring module streams.
+func (c Constraint) Eval(evr EVR) bool {
+	cmp := evr.Compare(c.EVR)
+	switch c.Op {
+	case Less:
+		return cmp < 0
+	case LessEqual:
+		return cmp <= 0
+	case Equal:
+		return cmp == 0
+	case GreaterEqual:
+		return cmp >= 0
+	case Greater:
+		return cmp > 0
+	}
+	return false
+}
+
+// Match evaluates the constraint against an installed package, taking its module stream into account.
+func (c Constraint) Match(pkg *rpmdb.PackageInfo) bool {
+	if c.Module != "" {
+		if moduleStream(pkg.ModularityLabel) != c.Module {
+			return false
+		}
+	} else if c.EVR.IsModular() && pkg.ModularityLabel == "" {
+		return false
+	}
+	return c.Eval(FromPackage(pkg))
+}
+
+// Constraints is a conjunction of constraints, e.g. ">= 2.4.6, < 2.4.37-43".
+type Constraints []Constraint
+
+// ParseConstraints parses comma separated constraints which must all hold.
+func ParseConstraints(s string) (Constraints, error) {
+	var constraints Constraints
+	for _, part := range strings.Split(s, ",") {
+		c, err := ParseConstraint(part)
+		if err != nil {
+			return nil, err
+		}
+		constraints = append(constraints, c)
+	}
+	return constraints, nil
+}
+
+// Match reports whether every constraint matches the package.
+func (cs Constraints) Match(pkg *rpmdb.PackageInfo) bool {
+	for _, c := range cs {
+		if !c.Match(pkg) {
+			return false
+		}
+	}
+	return len(cs) > 0
+}
+
+// moduleStream returns "name:stream" of a "name:stream:version:context" modularity label.
+func moduleStream(label string) string {
+	parts := strings.SplitN(label, ":", 3)
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[0] + ":" + parts[1]
+}
@@ -0,0 +1,88 @@
+// Package evr parses and evaluates the epoch:version-release constraints found in OVAL definitions
+// and CSAF product trees against installed packages.
+package evr
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	rpmdb "github.com/chennqqi/go-rpmdb/pkg"
+)
+
+// EVR is an epoch, version and release triplet.
+type EVR struct {
+	Epoch   int
+	Version string
+	Release string
+}
+
+// Parse parses "[epoch:]version[-release]". The release is split at the last dash, versions can't
+// contain dashes.
+func Parse(s string) (EVR, error) {
+	var evr EVR
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return evr, errors.New("empty evr")
+	}
+
+	if i := strings.Index(s, ":"); i >= 0 {
+		epoch, err := strconv.Atoi(s[:i])
+		if err != nil || epoch < 0 {
+			return evr, fmt.Errorf("invalid epoch: %q", s[:i])
+		}
+		evr.Epoch = epoch
+		s = s[i+1:]
+	}
+
+	if i := strings.LastIndex(s, "-"); i >= 0 {
+		evr.Version, evr.Release = s[:i], s[i+1:]
+	} else {
+		evr.Version = s
+	}
+	if evr.Version == "" {
+		return evr, fmt.Errorf("missing version: %q", s)
+	}
+	return evr, nil
+}
+
+// FromPackage returns the EVR of an installed package.
+func FromPackage(pkg *rpmdb.PackageInfo) EVR {
+	return EVR{
+		Epoch:   pkg.Epoch,
+		Version: pkg.Version,
+		Release: pkg.Release,
+	}
+}
+
+func (e EVR) String() string {
+	s := fmt.Sprintf("%d:%s", e.Epoch, e.Version)
+	if e.Release != "" {
+		s += "-" + e.Release
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1. An empty release on either side matches any release, as when rpm
+// compares a versioned dependency without release.
+func (e EVR) Compare(other EVR) int {
+	if e.Epoch != other.Epoch {
+		if e.Epoch < other.Epoch {
+			return -1
+		}
+		return 1
+	}
+	if cmp := rpmdb.Vercmp(e.Version, other.Version); cmp != 0 {
+		return cmp
+	}
+	if e.Release == "" || other.Release == "" {
+		return 0
+	}
+	return rpmdb.Vercmp(e.Release, other.Release)
+}
+
+// IsModular reports whether the release is the one of a module stream build (".module+el8...").
+func (e EVR) IsModular() bool {
+	return strings.Contains(e.Release, ".module+") || strings.Contains(e.Release, ".module_")
+}
@@ -0,0 +1,53 @@
+package evr
+
+import (
+	"testing"
+
+	rpmdb "github.com/chennqqi/go-rpmdb/pkg"
+)
+
+func TestConstraintMatch(t *testing.T) {
+	httpd := &rpmdb.PackageInfo{
+		Name:            "httpd",
+		Version:         "2.4.37",
+		Release:         "41.module+el8.5.0+11772+c8e0c271",
+		ModularityLabel: "httpd:2.4:8050020211105102018:b4937e53",
+	}
+	bash := &rpmdb.PackageInfo{
+		Name:    "bash",
+		Epoch:   0,
+		Version: "4.4.20",
+		Release: "4.el8_6",
+	}
+
+	vectors := []struct {
+		constraint string
+		module     string
+		pkg        *rpmdb.PackageInfo
+		want       bool
+	}{
+		{constraint: "< 0:2.4.37-43.module+el8.5.0+13806+b30d9eec", pkg: httpd, want: true},
+		{constraint: "<0:2.4.37-43.module+el8.5.0+13806+b30d9eec", module: "httpd:2.4", pkg: httpd, want: true},
+		{constraint: "< 0:2.4.37-43.module+el8.5.0+13806+b30d9eec", module: "httpd:2.6", pkg: httpd, want: false},
+		{constraint: ">= 2.4.37", pkg: httpd, want: true},
+		{constraint: "= 0:2.4.37-41.module+el8.5.0+11772+c8e0c271", pkg: httpd, want: true},
+		{constraint: "> 1:2.4.0-1.module+el8", pkg: httpd, want: false},
+		{constraint: "< 0:4.4.20-5.el8", pkg: bash, want: true},
+		{constraint: "<= 4.4.20-4.el8_6", pkg: bash, want: true},
+		{constraint: "< 4.4.20-5.module+el8", pkg: bash, want: false},
+		{constraint: ">= 4.4.19, < 4.4.21", pkg: bash, want: true},
+	}
+
+	for _, v := range vectors {
+		constraints, err := ParseConstraints(v.constraint)
+		if err != nil {
+			t.Fatalf("ParseConstraints(%q) error: %v", v.constraint, err)
+		}
+		for i := range constraints {
+			constraints[i].Module = v.module
+		}
+		if got := constraints.Match(v.pkg); got != v.want {
+			t.Errorf("%q (module %q) on %s: got %v, want %v", v.constraint, v.module, v.pkg.NEVRA(), got, v.want)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package rpmdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// fileOwnersMagic starts a file owners index, the last byte is the format version
+var fileOwnersMagic = []byte("RPMFOWN\x01")
+
+// FileOwner is a file of a package, a line of ExportFileOwners.
+type FileOwner struct {
+	Path  string `json:"path"`
+	NEVRA string `json:"nevra"`
+	// Digest is the hex digest of regular files, Algo its algorithm.
+	Digest string `json:"digest,omitempty"`
+	Algo   string `json:"algo,omitempty"`
+}
+
+// fileOwners lists the files of every package sorted by path then NEVRA, directories shared by
+// several packages appearing once per owner. The set is spilled to disk past WithMemoryBudget and must
+// be closed.
+func (d *RpmDB) fileOwners() (*ownerSet, error) {
+	owners := &ownerSet{budget: d.memoryBudget}
+	err := d.forEachPackage(func(hnum uint32, indexEntries []indexEntry, pkg *PackageInfo) error {
+		files, err := fileInfos(indexEntries)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pkg.NEVRA(), err)
+		}
+		algo, err := digestAlgoTag(indexEntries, RPMTAG_FILEDIGESTALGO, PGPHASHALGO_MD5)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pkg.NEVRA(), err)
+		}
+
+		nevra := pkg.NEVRA()
+		for _, file := range files {
+			owner := FileOwner{Path: file.Path, NEVRA: nevra, Digest: file.Digest}
+			if file.Digest != "" {
+				owner.Algo = algo.String()
+			}
+			if err := owners.add(owner); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		err = owners.finish()
+	}
+	if err != nil {
+		owners.Close()
+		return nil, err
+	}
+	return owners, nil
+}
+
+// ExportFileOwners writes the files of every package as newline delimited JSON FileOwner objects
+// sorted by path, the "known files" set of host intrusion detection tools.
+func (d *RpmDB) ExportFileOwners(w io.Writer) error {
+	owners, err := d.fileOwners()
+	if err != nil {
+		return err
+	}
+	defer owners.Close()
+	bw := bufio.NewWriter(w)
+	encoder := json.NewEncoder(bw)
+	encoder.SetEscapeHTML(false)
+	err = owners.each(func(owner *FileOwner) error {
+		if err := encoder.Encode(owner); err != nil {
+			return fmt.Errorf("failed to write file owner: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// ExportFileOwnersIndex writes the files of every package as a compact binary index, read back with
+// ReadFileOwnersIndex. Digests are left out.
+//
+// The index is the magic followed by the NEVRAs and the sorted paths, front coded:
+//
+//	magic  [8]byte
+//	nevras uvarint count, then uvarint length and bytes per NEVRA
+//	paths  uvarint count, then per path the uvarint length of the prefix shared with the previous
+//	       path, the uvarint length and bytes of the rest, and the uvarint index of the NEVRA
+func (d *RpmDB) ExportFileOwnersIndex(w io.Writer) error {
+	owners, err := d.fileOwners()
+	if err != nil {
+		return err
+	}
+	defer owners.Close()
+
+	nevraIndexes := make(map[string]uint64)
+	var nevras []string
+	err = owners.each(func(owner *FileOwner) error {
+		if _, ok := nevraIndexes[owner.NEVRA]; !ok {
+			nevraIndexes[owner.NEVRA] = uint64(len(nevras))
+			nevras = append(nevras, owner.NEVRA)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	buf := make([]byte, binary.MaxVarintLen64)
+	putUvarint := func(v uint64) {
+		n := binary.PutUvarint(buf, v)
+		bw.Write(buf[:n])
+	}
+
+	bw.Write(fileOwnersMagic)
+	putUvarint(uint64(len(nevras)))
+	for _, nevra := range nevras {
+		putUvarint(uint64(len(nevra)))
+		bw.WriteString(nevra)
+	}
+	putUvarint(uint64(owners.count))
+	previous := ""
+	err = owners.each(func(owner *FileOwner) error {
+		shared := commonPrefixLength(previous, owner.Path)
+		putUvarint(uint64(shared))
+		putUvarint(uint64(len(owner.Path) - shared))
+		bw.WriteString(owner.Path[shared:])
+		putUvarint(nevraIndexes[owner.NEVRA])
+		previous = owner.Path
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write file owners index: %w", err)
+	}
+	return nil
+}
+
+func commonPrefixLength(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// FileOwnersIndex is a file owners index loaded in memory.
+type FileOwnersIndex struct {
+	nevras []string
+	paths  []string
+	owners []uint32
+}
+
+// ReadFileOwnersIndex loads an index written by ExportFileOwnersIndex.
+func ReadFileOwnersIndex(r io.Reader) (*FileOwnersIndex, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(fileOwnersMagic))
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return nil, fmt.Errorf("failed to read file owners index: %w", err)
+	}
+	if !bytes.Equal(magic, fileOwnersMagic) {
+		return nil, errors.New("not a file owners index")
+	}
+
+	readString := func(prefix []byte) (string, error) {
+		n, err := binary.ReadUvarint(br)
+		if err != nil {
+			return "", err
+		}
+		if n > 1<<16 {
+			return "", fmt.Errorf("invalid string length: %d", n)
+		}
+		s := make([]byte, len(prefix)+int(n))
+		copy(s, prefix)
+		if _, err := io.ReadFull(br, s[len(prefix):]); err != nil {
+			return "", err
+		}
+		return string(s), nil
+	}
+
+	index := &FileOwnersIndex{}
+	numNEVRAs, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file owners index: %w", err)
+	}
+	for i := uint64(0); i < numNEVRAs; i++ {
+		nevra, err := readString(nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file owners index: %w", err)
+		}
+		index.nevras = append(index.nevras, nevra)
+	}
+
+	numPaths, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file owners index: %w", err)
+	}
+	previous := ""
+	for i := uint64(0); i < numPaths; i++ {
+		shared, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file owners index: %w", err)
+		}
+		if shared > uint64(len(previous)) {
+			return nil, fmt.Errorf("invalid file owners index: shared prefix %d > %d", shared, len(previous))
+		}
+		path, err := readString([]byte(previous[:shared]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid file owners index: %w", err)
+		}
+		owner, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file owners index: %w", err)
+		}
+		if owner >= uint64(len(index.nevras)) {
+			return nil, fmt.Errorf("invalid file owners index: nevra index %d", owner)
+		}
+		index.paths = append(index.paths, path)
+		index.owners = append(index.owners, uint32(owner))
+		previous = path
+	}
+	return index, nil
+}
+
+// Len returns the number of path and owner pairs of the index.
+func (x *FileOwnersIndex) Len() int {
+	return len(x.paths)
+}
+
+// Lookup returns the NEVRAs of the packages owning path, nil for unknown files.
+func (x *FileOwnersIndex) Lookup(path string) []string {
+	i := sort.SearchStrings(x.paths, path)
+	var nevras []string
+	for ; i < len(x.paths) && x.paths[i] == path; i++ {
+		nevras = append(nevras, x.nevras[x.owners[i]])
+	}
+	return nevras
+}
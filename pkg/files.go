@@ -0,0 +1,383 @@
+package rpmdb
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// the tags holding an element per file or directory of a package, the bulk of most headers, but
+// FILECOLORS of which PackageInfo.Color is made
+var fileTags = map[TAG_ID]bool{
+	RPMTAG_OLDFILENAMES:    true,
+	RPMTAG_BASENAMES:       true,
+	RPMTAG_DIRNAMES:        true,
+	RPMTAG_DIRINDEXES:      true,
+	RPMTAG_ORIGBASENAMES:   true,
+	RPMTAG_ORIGDIRNAMES:    true,
+	RPMTAG_ORIGDIRINDEXES:  true,
+	RPMTAG_FILESIZES:       true,
+	RPMTAG_LONGFILESIZES:   true,
+	RPMTAG_FILESTATES:      true,
+	RPMTAG_FILEMODES:       true,
+	RPMTAG_FILEUIDS:        true,
+	RPMTAG_FILEGIDS:        true,
+	RPMTAG_FILERDEVS:       true,
+	RPMTAG_FILEMTIMES:      true,
+	RPMTAG_FILEDIGESTS:     true,
+	RPMTAG_FILELINKTOS:     true,
+	RPMTAG_FILEFLAGS:       true,
+	RPMTAG_FILEUSERNAME:    true,
+	RPMTAG_FILEGROUPNAME:   true,
+	RPMTAG_FILEVERIFYFLAGS: true,
+	RPMTAG_FILEDEVICES:     true,
+	RPMTAG_FILEINODES:      true,
+	RPMTAG_FILELANGS:       true,
+	RPMTAG_FILECLASS:       true,
+	RPMTAG_CLASSDICT:       true,
+	RPMTAG_FILEDEPENDSX:    true,
+	RPMTAG_FILEDEPENDSN:    true,
+	RPMTAG_DEPENDSDICT:     true,
+	RPMTAG_FILECAPS:        true,
+	RPMTAG_FILESIGNATURES:  true,
+	RPMTAG_FILECONTEXTS:    true,
+	RPMTAG_FILEDIGESTALGOS: true,
+	RPMTAG_FILEXATTRSX:     true,
+}
+
+// WithoutFiles leaves the file lists out of the headers decoded by the listings, the data of these
+// tags being neither checked nor decoded: an inventory of names and versions doesn't pay for the
+// thousands of paths of every package. The headers are still read whole from the database. The
+// packages then have no files, for ExportFileOwners, WriteMtree or the file tags of
+// ListPackagesWithTags alike.
+func WithoutFiles() Option {
+	return func(d *RpmDB) {
+		d.skip(fileTags)
+	}
+}
+
+// skip adds tags to the ones the listings leave out of the headers.
+func (d *RpmDB) skip(tags map[TAG_ID]bool) {
+	skipTags := make(map[TAG_ID]bool, len(d.skipTags)+len(tags))
+	for tag := range d.skipTags {
+		skipTags[tag] = true
+	}
+	for tag := range tags {
+		skipTags[tag] = true
+	}
+	d.skipTags = skipTags
+}
+
+// fileNames returns the full paths of the files of a package, built from the compressed
+// BASENAMES/DIRNAMES/DIRINDEXES triplet or the legacy OLDFILENAMES tag.
+// ref. https://github.com/rpm-software-management/rpm/blob/rpm-4.11.3-release/lib/tagexts.c#L68
+func fileNames(indexEntries []indexEntry) ([]string, error) {
+	files, err := compressedFileNames(indexEntries, RPMTAG_BASENAMES, RPMTAG_DIRNAMES, RPMTAG_DIRINDEXES)
+	if err != nil || files != nil {
+		return files, err
+	}
+	oldFileNames, err := tagStrings(indexEntries, RPMTAG_OLDFILENAMES)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag oldfilenames: %w", err)
+	}
+	return oldFileNames, nil
+}
+
+// origFileNames returns the paths of the files as built in the package before relocation, nil when
+// the package wasn't relocated.
+func origFileNames(indexEntries []indexEntry) ([]string, error) {
+	return compressedFileNames(indexEntries, RPMTAG_ORIGBASENAMES, RPMTAG_ORIGDIRNAMES, RPMTAG_ORIGDIRINDEXES)
+}
+
+func compressedFileNames(indexEntries []indexEntry, baseNamesTag, dirNamesTag, dirIndexesTag TAG_ID) ([]string, error) {
+	baseNames, err := tagStrings(indexEntries, baseNamesTag)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag %v: %w", baseNamesTag, err)
+	}
+	if baseNames == nil {
+		return nil, nil
+	}
+
+	dirNames, err := tagStrings(indexEntries, dirNamesTag)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag %v: %w", dirNamesTag, err)
+	}
+	dirIndexes, err := tagUint32s(indexEntries, dirIndexesTag)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag %v: %w", dirIndexesTag, err)
+	}
+	if len(dirIndexes) != len(baseNames) {
+		return nil, fmt.Errorf("%v/%v mismatch: %d != %d", dirIndexesTag, baseNamesTag, len(dirIndexes), len(baseNames))
+	}
+
+	var files = make([]string, len(baseNames))
+	for i, baseName := range baseNames {
+		if int(dirIndexes[i]) >= len(dirNames) {
+			return nil, fmt.Errorf("invalid dir index: %d", dirIndexes[i])
+		}
+		files[i] = dirNames[dirIndexes[i]] + baseName
+	}
+	return files, nil
+}
+
+// installPrefixes returns the relocatable prefixes of a package along with where they were
+// installed, from PREFIXES/INSTPREFIXES or the single prefix tags of rpm 3.
+func installPrefixes(indexEntries []indexEntry) (prefixes, instPrefixes []string, err error) {
+	if prefixes, err = tagStrings(indexEntries, RPMTAG_PREFIXES); err != nil {
+		return nil, nil, fmt.Errorf("invalid tag prefixes: %w", err)
+	}
+	if instPrefixes, err = tagStrings(indexEntries, RPMTAG_INSTPREFIXES); err != nil {
+		return nil, nil, fmt.Errorf("invalid tag instprefixes: %w", err)
+	}
+	if prefixes == nil {
+		if prefixes, err = tagStrings(indexEntries, RPMTAG_DEFAULTPREFIX); err != nil {
+			return nil, nil, fmt.Errorf("invalid tag defaultprefix: %w", err)
+		}
+	}
+	if instPrefixes == nil {
+		if instPrefixes, err = tagStrings(indexEntries, RPMTAG_INSTALLPREFIX); err != nil {
+			return nil, nil, fmt.Errorf("invalid tag installprefix: %w", err)
+		}
+	}
+	return prefixes, instPrefixes, nil
+}
+
+// relocate moves file under the first prefix it is in, the way rpm relocates a file list.
+func relocate(file string, prefixes, instPrefixes []string) string {
+	for i, prefix := range prefixes {
+		if i >= len(instPrefixes) {
+			break
+		}
+		prefix = strings.TrimRight(prefix, "/")
+		if file != prefix && !strings.HasPrefix(file, prefix+"/") {
+			continue
+		}
+		return strings.TrimRight(instPrefixes[i], "/") + file[len(prefix):]
+	}
+	return file
+}
+
+// FileFlags are the rpmfileAttrs_e flags of a file, set by the %config, %doc... directives of the spec.
+type FileFlags uint32
+
+// ref. https://github.com/rpm-software-management/rpm/blob/rpm-4.20.0-release/include/rpm/rpmfiles.h#L60
+const (
+	RPMFILE_NONE      FileFlags = 0
+	RPMFILE_CONFIG    FileFlags = 1 << 0
+	RPMFILE_DOC       FileFlags = 1 << 1
+	RPMFILE_ICON      FileFlags = 1 << 2
+	RPMFILE_MISSINGOK FileFlags = 1 << 3
+	RPMFILE_NOREPLACE FileFlags = 1 << 4
+	RPMFILE_SPECFILE  FileFlags = 1 << 5
+	RPMFILE_GHOST     FileFlags = 1 << 6
+	RPMFILE_LICENSE   FileFlags = 1 << 7
+	RPMFILE_README    FileFlags = 1 << 8
+	RPMFILE_PUBKEY    FileFlags = 1 << 11
+	RPMFILE_ARTIFACT  FileFlags = 1 << 12
+)
+
+// FileColor is the rpmfileColor of a file, the ELF class of the binaries rpmbuild found, by which
+// rpm settles the conflicts of the files the packages of a multilib host share.
+type FileColor uint32
+
+// ref. https://github.com/rpm-software-management/rpm/blob/rpm-4.11.3-release/lib/rpmfc.h#L27
+const (
+	RPMFC_BLACK      FileColor = 0
+	RPMFC_ELF32      FileColor = 1 << 0
+	RPMFC_ELF64      FileColor = 1 << 1
+	RPMFC_ELFMIPSN32 FileColor = 1 << 2
+)
+
+// FileState is what rpm did with a file at install time.
+type FileState int8
+
+// ref. https://github.com/rpm-software-management/rpm/blob/rpm-4.20.0-release/include/rpm/rpmfiles.h#L40
+const (
+	RPMFILE_STATE_MISSING      FileState = -1
+	RPMFILE_STATE_NORMAL       FileState = 0
+	RPMFILE_STATE_REPLACED     FileState = 1
+	RPMFILE_STATE_NOTINSTALLED FileState = 2
+	RPMFILE_STATE_NETSHARED    FileState = 3
+	RPMFILE_STATE_WRONGCOLOR   FileState = 4
+)
+
+var fileStateNames = map[FileState]string{
+	RPMFILE_STATE_MISSING:      "(no state)",
+	RPMFILE_STATE_NORMAL:       "normal",
+	RPMFILE_STATE_REPLACED:     "replaced",
+	RPMFILE_STATE_NOTINSTALLED: "not installed",
+	RPMFILE_STATE_NETSHARED:    "net shared",
+	RPMFILE_STATE_WRONGCOLOR:   "wrong color",
+}
+
+// String returns the name rpm -qs prints for the state.
+func (s FileState) String() string {
+	if name, ok := fileStateNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("(unknown %3d)", int(s))
+}
+
+// FileInfo is the metadata a header records about one of its files.
+type FileInfo struct {
+	// Path is where the file is installed, OrigPath where the package put it before relocation.
+	Path     string `json:"path" yaml:"path" msgpack:"path"`
+	OrigPath string `json:"orig_path,omitempty" yaml:"orig_path,omitempty" msgpack:"orig_path,omitempty"`
+	Size     int64  `json:"size" yaml:"size" msgpack:"size"`
+	// Mode is the st_mode of the file, type bits included.
+	Mode uint16 `json:"mode" yaml:"mode" msgpack:"mode"`
+	Rdev uint16 `json:"rdev,omitempty" yaml:"rdev,omitempty" msgpack:"rdev,omitempty"`
+	// MTime is the modification time in seconds since the epoch, see ModTime.
+	MTime uint32 `json:"mtime" yaml:"mtime" msgpack:"mtime"`
+	// Digest is the hex digest of regular files, empty for other types. The algorithm is the
+	// FILEDIGESTALGO of the package.
+	Digest    string    `json:"digest,omitempty" yaml:"digest,omitempty" msgpack:"digest,omitempty"`
+	LinkTo    string    `json:"link_to,omitempty" yaml:"link_to,omitempty" msgpack:"link_to,omitempty"`
+	Flags     FileFlags `json:"flags" yaml:"flags" msgpack:"flags"`
+	Username  string    `json:"username" yaml:"username" msgpack:"username"`
+	Groupname string    `json:"groupname" yaml:"groupname" msgpack:"groupname"`
+	// VerifyFlags are the attributes rpm -V checks, narrowed by the %verify directive.
+	VerifyFlags VerifyAttr `json:"verify_flags" yaml:"verify_flags" msgpack:"verify_flags"`
+	State       FileState  `json:"state" yaml:"state" msgpack:"state"`
+	// Caps is the text form of the file capabilities, as cap_to_text(3) prints them.
+	Caps string `json:"caps,omitempty" yaml:"caps,omitempty" msgpack:"caps,omitempty"`
+	// Lang is the language of the file marked with %lang, empty for files of every language.
+	Lang string `json:"lang,omitempty" yaml:"lang,omitempty" msgpack:"lang,omitempty"`
+	// Color is RPMFC_BLACK for the files ELF binaries aren't.
+	Color FileColor `json:"color,omitempty" yaml:"color,omitempty" msgpack:"color,omitempty"`
+}
+
+// ModTime returns MTime as a time in UTC.
+func (f *FileInfo) ModTime() time.Time {
+	return unixTime(uint64(f.MTime))
+}
+
+// fileInfos decodes the per-file tags of a header. Tags missing from the header leave the zero
+// value, except the verify flags defaulting to every attribute.
+func fileInfos(indexEntries []indexEntry) ([]FileInfo, error) {
+	names, err := fileNames(indexEntries)
+	if err != nil {
+		return nil, err
+	}
+	// the FILECOLORS WithoutFiles keeps belong to no file
+	if names == nil {
+		return nil, nil
+	}
+	files := make([]FileInfo, len(names))
+	for i, name := range names {
+		files[i] = FileInfo{Path: name, OrigPath: name, VerifyFlags: RPMVERIFY_ALL}
+	}
+
+	// rpm rewrites the file list of relocated packages at install time and keeps the original one
+	// in ORIGBASENAMES, rpm 3 only recorded the prefixes
+	origNames, err := origFileNames(indexEntries)
+	if err != nil {
+		return nil, err
+	}
+	if origNames != nil && len(origNames) != len(files) {
+		return nil, fmt.Errorf("origbasenames/files mismatch: %d != %d", len(origNames), len(files))
+	}
+	for i, name := range origNames {
+		files[i].OrigPath = name
+	}
+	if origNames == nil {
+		prefixes, instPrefixes, err := installPrefixes(indexEntries)
+		if err != nil {
+			return nil, err
+		}
+		for i := range files {
+			files[i].Path = relocate(files[i].OrigPath, prefixes, instPrefixes)
+		}
+	}
+
+	stringTags := []struct {
+		tag TAG_ID
+		set func(f *FileInfo, v string)
+	}{
+		{RPMTAG_FILEDIGESTS, func(f *FileInfo, v string) { f.Digest = v }},
+		{RPMTAG_FILELINKTOS, func(f *FileInfo, v string) { f.LinkTo = v }},
+		{RPMTAG_FILEUSERNAME, func(f *FileInfo, v string) { f.Username = v }},
+		{RPMTAG_FILEGROUPNAME, func(f *FileInfo, v string) { f.Groupname = v }},
+		{RPMTAG_FILECAPS, func(f *FileInfo, v string) { f.Caps = v }},
+		{RPMTAG_FILELANGS, func(f *FileInfo, v string) { f.Lang = v }},
+	}
+	for _, t := range stringTags {
+		values, err := tagStrings(indexEntries, t.tag)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag %v: %w", t.tag, err)
+		}
+		if values != nil && len(values) != len(files) {
+			return nil, fmt.Errorf("%v/files mismatch: %d != %d", t.tag, len(values), len(files))
+		}
+		for i, v := range values {
+			t.set(&files[i], v)
+		}
+	}
+
+	uint32Tags := []struct {
+		tag TAG_ID
+		set func(f *FileInfo, v uint32)
+	}{
+		{RPMTAG_FILESIZES, func(f *FileInfo, v uint32) { f.Size = int64(v) }},
+		{RPMTAG_FILEMTIMES, func(f *FileInfo, v uint32) { f.MTime = v }},
+		{RPMTAG_FILEFLAGS, func(f *FileInfo, v uint32) { f.Flags = FileFlags(v) }},
+		{RPMTAG_FILEVERIFYFLAGS, func(f *FileInfo, v uint32) { f.VerifyFlags = VerifyAttr(v) }},
+		{RPMTAG_FILECOLORS, func(f *FileInfo, v uint32) { f.Color = FileColor(v) }},
+	}
+	for _, t := range uint32Tags {
+		values, err := tagUint32s(indexEntries, t.tag)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag %v: %w", t.tag, err)
+		}
+		if values != nil && len(values) != len(files) {
+			return nil, fmt.Errorf("%v/files mismatch: %d != %d", t.tag, len(values), len(files))
+		}
+		for i, v := range values {
+			t.set(&files[i], v)
+		}
+	}
+
+	uint16Tags := []struct {
+		tag TAG_ID
+		set func(f *FileInfo, v uint16)
+	}{
+		{RPMTAG_FILEMODES, func(f *FileInfo, v uint16) { f.Mode = v }},
+		{RPMTAG_FILERDEVS, func(f *FileInfo, v uint16) { f.Rdev = v }},
+	}
+	for _, t := range uint16Tags {
+		values, err := tagUint16s(indexEntries, t.tag)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag %v: %w", t.tag, err)
+		}
+		if values != nil && len(values) != len(files) {
+			return nil, fmt.Errorf("%v/files mismatch: %d != %d", t.tag, len(values), len(files))
+		}
+		for i, v := range values {
+			t.set(&files[i], v)
+		}
+	}
+
+	// packages with files over 4GB carry LONGFILESIZES instead of FILESIZES
+	longSizes, err := tagUint64s(indexEntries, RPMTAG_LONGFILESIZES)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag longfilesizes: %w", err)
+	}
+	if longSizes != nil && len(longSizes) != len(files) {
+		return nil, fmt.Errorf("longfilesizes/files mismatch: %d != %d", len(longSizes), len(files))
+	}
+	for i, size := range longSizes {
+		files[i].Size = int64(size)
+	}
+
+	states, err := tagBytes(indexEntries, RPMTAG_FILESTATES)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag filestates: %w", err)
+	}
+	if states != nil && len(states) != len(files) {
+		return nil, fmt.Errorf("filestates/files mismatch: %d != %d", len(states), len(files))
+	}
+	for i, state := range states {
+		files[i].State = FileState(int8(state))
+	}
+	return files, nil
+}
@@ -0,0 +1,239 @@
+package rpmdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FormatWriter encodes a package listing to w.
+type FormatWriter func(w io.Writer, pkgs []*PackageInfo) error
+
+var (
+	formatsMu sync.RWMutex
+	formats   = make(map[string]FormatWriter)
+)
+
+func init() {
+	RegisterFormat("json", WriteInventoryJSON)
+	RegisterFormat("yaml", WriteInventoryYAML)
+	RegisterFormat("csv", WriteInventoryCSV)
+	RegisterFormat("msgpack", WriteInventoryMsgpack)
+	RegisterFormat("proto", WriteInventoryProto)
+	RegisterFormat("cyclonedx", WriteCycloneDX)
+	RegisterFormat("spdx", WriteSPDX)
+}
+
+// RegisterFormat makes a listing format available by name, to the command line tool among others,
+// replacing the one registered with the same name.
+func RegisterFormat(name string, write FormatWriter) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	formats[name] = write
+}
+
+// Formats returns the sorted names of the registered formats.
+func Formats() []string {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupFormat returns the writer of the format registered as name, false when there is none.
+func LookupFormat(name string) (FormatWriter, bool) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	write, ok := formats[name]
+	return write, ok
+}
+
+// WriteInventoryJSON encodes the packages as an indented JSON array with the json tags of
+// PackageInfo.
+func WriteInventoryJSON(w io.Writer, pkgs []*PackageInfo) error {
+	if pkgs == nil {
+		pkgs = []*PackageInfo{}
+	}
+	return writeIndentedJSON(w, pkgs)
+}
+
+// WriteInventoryCSV encodes the packages as CSV with a header line, a column per field of the
+// compact encodings. The times are in RFC 3339, empty when the header doesn't record them.
+func WriteInventoryCSV(w io.Writer, pkgs []*PackageInfo) error {
+	cw := csv.NewWriter(w)
+	record := make([]string, len(compactFields))
+	for i, f := range compactFields {
+		record[i] = f.name
+	}
+	if err := cw.Write(record); err != nil {
+		return err
+	}
+	for _, pkg := range pkgs {
+		for i, f := range compactFields {
+			switch {
+			case f.name == "install_time":
+				record[i] = formatTime(pkg.InstallTime)
+			case f.name == "build_time":
+				record[i] = formatTime(pkg.BuildTime)
+			case f.str != nil:
+				record[i] = f.str(pkg)
+			default:
+				record[i] = strconv.FormatUint(f.num(pkg), 10)
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// WriteInventoryYAML encodes the packages as a YAML sequence with the fields of the JSON encoding,
+// in the same order. The strings are double-quoted, JSON strings being valid YAML ones.
+func WriteInventoryYAML(w io.Writer, pkgs []*PackageInfo) error {
+	if pkgs == nil {
+		pkgs = []*PackageInfo{}
+	}
+	data, err := json.Marshal(pkgs)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := writeYAML(bw, decoder, 0, yamlLine); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// where a YAML value is written: at the start of a line, after the dash of a sequence entry or
+// after the key of a mapping entry
+const (
+	yamlLine = iota
+	yamlDash
+	yamlKey
+)
+
+// writeYAML converts the next JSON value of the decoder to YAML in block style, the collections
+// starting on a new line being indented by indent levels.
+func writeYAML(w *bufio.Writer, decoder *json.Decoder, indent int, at int) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	pad := strings.Repeat("  ", indent)
+
+	switch token {
+	case json.Delim('['), json.Delim('{'):
+		end := json.Delim(']')
+		if token == json.Delim('{') {
+			end = json.Delim('}')
+		}
+		if !decoder.More() {
+			if _, err := decoder.Token(); err != nil {
+				return err
+			}
+			return writeYAMLScalar(w, string(token.(json.Delim))+string(end), at)
+		}
+		// a mapping in a sequence entry starts on the line of the dash, "- key: value"
+		sameLine := at == yamlDash && token == json.Delim('{')
+		if at != yamlLine && !sameLine {
+			w.WriteString("\n")
+		}
+		for first := true; decoder.More(); first = false {
+			switch {
+			case first && sameLine:
+				w.WriteString(" ")
+			default:
+				w.WriteString(pad)
+			}
+			if token == json.Delim('[') {
+				w.WriteString("-")
+				if err := writeYAML(w, decoder, indent+1, yamlDash); err != nil {
+					return err
+				}
+				continue
+			}
+			key, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+			w.WriteString(yamlKeyString(key.(string)))
+			w.WriteString(":")
+			if err := writeYAML(w, decoder, indent+1, yamlKey); err != nil {
+				return err
+			}
+		}
+		_, err = decoder.Token()
+		return err
+	}
+
+	var scalar string
+	switch v := token.(type) {
+	case nil:
+		scalar = "null"
+	case bool:
+		scalar = strconv.FormatBool(v)
+	case json.Number:
+		scalar = v.String()
+	case string:
+		scalar = yamlQuote(v)
+	default:
+		return fmt.Errorf("unexpected JSON token: %v", token)
+	}
+	return writeYAMLScalar(w, scalar, at)
+}
+
+// yamlKeyString leaves the keys made of letters, digits and underscores unquoted.
+func yamlKeyString(key string) string {
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_') {
+			return yamlQuote(key)
+		}
+	}
+	if key == "" {
+		return `""`
+	}
+	return key
+}
+
+// yamlQuote double-quotes s, the escapes of JSON strings being the ones of YAML but for the HTML
+// ones, which are left out.
+func yamlQuote(s string) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func writeYAMLScalar(w *bufio.Writer, scalar string, at int) error {
+	if at != yamlLine {
+		w.WriteString(" ")
+	}
+	w.WriteString(scalar)
+	w.WriteString("\n")
+	return nil
+}
@@ -0,0 +1,146 @@
+//go:build ignore
+// +build ignore
+
+// gen_tags.go generates tags.go, the TAG_ID constants, from rpm's rpmtag.h.
+//
+//	go run gen_tags.go [-rpmtag path/to/rpmtag.h] [-release rpm-4.20.0-release]
+//
+// Without -rpmtag the header of the given rpm release is downloaded from GitHub.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const sourceURL = "https://github.com/rpm-software-management/rpm/blob/%s/include/rpm/rpmtag.h"
+
+var (
+	defineRe = regexp.MustCompile(`^#define\s+(HEADER_\w+)\s+(\d+)`)
+	tagRe    = regexp.MustCompile(`^(RPMTAG_\w+)\s*=\s*([^,/]+?)\s*,?\s*(/\*.*\*/)?$`)
+	numberRe = regexp.MustCompile(`^-?\d+$`)
+)
+
+// tags rpm uses as markers rather than header tags
+var skipTags = map[string]bool{
+	"RPMTAG_NOT_FOUND": true,
+}
+
+func main() {
+	rpmtag := flag.String("rpmtag", "", "path to rpmtag.h, downloaded when empty")
+	release := flag.String("release", "rpm-4.20.0-release", "rpm release the header is taken from")
+	output := flag.String("o", "tags.go", "output file")
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("gen_tags: ")
+
+	header, err := readHeader(*rpmtag, *release)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	src, err := generate(header, fmt.Sprintf(sourceURL, *release))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(*output, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func readHeader(path, release string) ([]byte, error) {
+	if path != "" {
+		return ioutil.ReadFile(path)
+	}
+
+	url := fmt.Sprintf("https://raw.githubusercontent.com/rpm-software-management/rpm/%s/include/rpm/rpmtag.h", release)
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+func generate(header []byte, source string) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by \"go run gen_tags.go\"; DO NOT EDIT.\n")
+	fmt.Fprintf(&buf, "// source: %s\n\n", source)
+	fmt.Fprintf(&buf, "package rpmdb\n\nconst (\n")
+
+	scanner := bufio.NewScanner(bytes.NewReader(header))
+	inEnum, inComment, blank := false, false, false
+	numTags := 0
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if inComment {
+			inComment = !strings.Contains(line, "*/")
+			continue
+		}
+
+		if !inEnum {
+			if m := defineRe.FindStringSubmatch(line); m != nil {
+				fmt.Fprintf(&buf, "%s TAG_ID = %s\n", m[1], m[2])
+				continue
+			}
+			if strings.HasPrefix(line, "typedef enum rpmTag_e") {
+				inEnum, blank = true, true
+				fmt.Fprintf(&buf, "\n// rpmTag_e\n")
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "}"):
+			if numTags == 0 {
+				return nil, fmt.Errorf("no tag found")
+			}
+			fmt.Fprintf(&buf, ")\n")
+			return format.Source(buf.Bytes())
+		case line == "":
+			if !blank {
+				buf.WriteString("\n")
+			}
+			blank = true
+			continue
+		case strings.HasPrefix(line, "/*") && strings.HasSuffix(line, "*/"):
+			fmt.Fprintf(&buf, "%s\n", collapse(line))
+		case strings.HasPrefix(line, "/*"):
+			// doxygen blocks spanning several lines are not worth keeping
+			inComment = true
+		default:
+			m := tagRe.FindStringSubmatch(line)
+			if m == nil || skipTags[m[1]] {
+				continue
+			}
+			name, value, comment := m[1], m[2], collapse(m[3])
+			if numberRe.MatchString(value) {
+				fmt.Fprintf(&buf, "%s TAG_ID = %s %s\n", name, value, comment)
+			} else {
+				fmt.Fprintf(&buf, "%s = %s %s\n", name, value, comment)
+			}
+			numTags++
+		}
+		blank = false
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("unterminated rpmTag_e enum")
+}
+
+func collapse(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
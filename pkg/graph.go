@@ -0,0 +1,298 @@
+package rpmdb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DependencyNode is a package of the dependency graph.
+type DependencyNode struct {
+	Package *PackageInfo
+	// Requires lists the packages providing the requirements of this package.
+	Requires []*DependencyNode
+	// RequiredBy lists the packages whose requirements this package provides.
+	RequiredBy []*DependencyNode
+	// Unresolved lists the requirements no installed package provides.
+	Unresolved []string
+
+	index int
+}
+
+// DependencyGraph is the directed graph of installed packages, edges go from a package to the
+// packages satisfying its requirements. Only capability names are matched, versions are not
+// compared, and rpmlib() requirements are ignored since rpm itself provides them.
+type DependencyGraph struct {
+	Nodes []*DependencyNode
+}
+
+// ErrDependencyCycle matches a *DependencyCycleError with errors.Is.
+var ErrDependencyCycle = errors.New("dependency cycle")
+
+// DependencyCycleError is returned by TopologicalSort when the graph has dependency loops.
+type DependencyCycleError struct {
+	Cycles [][]*DependencyNode
+}
+
+func (e *DependencyCycleError) Error() string {
+	var cycles []string
+	for _, cycle := range e.Cycles {
+		var names []string
+		for _, node := range cycle {
+			names = append(names, node.Package.Name)
+		}
+		cycles = append(cycles, strings.Join(names, " -> "))
+	}
+	return fmt.Sprintf("%d dependency cycle(s): %s", len(e.Cycles), strings.Join(cycles, ", "))
+}
+
+// Is makes the error match ErrDependencyCycle.
+func (e *DependencyCycleError) Is(target error) bool {
+	return target == ErrDependencyCycle
+}
+
+// DependencyGraph resolves the requirements of every package against the provides and files of the
+// installed packages.
+func (d *RpmDB) DependencyGraph() (*DependencyGraph, error) {
+	graph := &DependencyGraph{}
+	providers := make(map[string][]*DependencyNode)
+	var requires [][]string
+
+	err := d.forEachPackage(func(hnum uint32, indexEntries []indexEntry, pkg *PackageInfo) error {
+		node := &DependencyNode{
+			Package: pkg,
+			index:   len(graph.Nodes),
+		}
+		graph.Nodes = append(graph.Nodes, node)
+
+		provideNames, err := tagStrings(indexEntries, RPMTAG_PROVIDENAME)
+		if err != nil {
+			return fmt.Errorf("invalid tag providename: %w", err)
+		}
+		files, err := fileNames(indexEntries)
+		if err != nil {
+			return err
+		}
+		for _, capability := range append(provideNames, files...) {
+			providers[capability] = append(providers[capability], node)
+		}
+
+		requireNames, err := tagStrings(indexEntries, RPMTAG_REQUIRENAME)
+		if err != nil {
+			return fmt.Errorf("invalid tag requirename: %w", err)
+		}
+		requires = append(requires, requireNames)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, node := range graph.Nodes {
+		seen := make(map[*DependencyNode]struct{})
+		for _, capability := range requires[i] {
+			if strings.HasPrefix(capability, "rpmlib(") {
+				continue
+			}
+
+			nodes, ok := providers[capability]
+			if !ok {
+				if !containsString(node.Unresolved, capability) {
+					node.Unresolved = append(node.Unresolved, capability)
+				}
+				continue
+			}
+			for _, provider := range nodes {
+				if _, ok := seen[provider]; ok || provider == node {
+					continue
+				}
+				seen[provider] = struct{}{}
+				node.Requires = append(node.Requires, provider)
+				provider.RequiredBy = append(provider.RequiredBy, node)
+			}
+		}
+	}
+
+	return graph, nil
+}
+
+// Lookup returns the nodes of the packages named name.
+func (g *DependencyGraph) Lookup(name string) []*DependencyNode {
+	var nodes []*DependencyNode
+	for _, node := range g.Nodes {
+		if node.Package.Name == name {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// Dependents returns every package that directly or transitively requires node, i.e. the packages
+// that would break if node was removed.
+func (g *DependencyGraph) Dependents(node *DependencyNode) []*DependencyNode {
+	var dependents []*DependencyNode
+	visited := make([]bool, len(g.Nodes))
+	visited[node.index] = true
+
+	queue := []*DependencyNode{node}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, dependent := range current.RequiredBy {
+			if visited[dependent.index] {
+				continue
+			}
+			visited[dependent.index] = true
+			dependents = append(dependents, dependent)
+			queue = append(queue, dependent)
+		}
+	}
+	return dependents
+}
+
+// Leaves returns the packages no other package requires, like `package-cleanup --leaves --all`.
+func (g *DependencyGraph) Leaves() []*DependencyNode {
+	var leaves []*DependencyNode
+	for _, node := range g.Nodes {
+		if len(node.RequiredBy) == 0 {
+			leaves = append(leaves, node)
+		}
+	}
+	return leaves
+}
+
+// Orphans returns the packages nothing outside their own dependency loop requires. Unlike Leaves it
+// also reports groups of packages that only require each other (e.g. a library and its plugin), which
+// can only be removed together; leaves are orphans of a loop of one.
+func (g *DependencyGraph) Orphans() []*DependencyNode {
+	var orphans []*DependencyNode
+	for _, component := range g.components() {
+		inComponent := make(map[*DependencyNode]struct{}, len(component))
+		for _, node := range component {
+			inComponent[node] = struct{}{}
+		}
+
+		required := false
+		for _, node := range component {
+			for _, dependent := range node.RequiredBy {
+				if _, ok := inComponent[dependent]; !ok {
+					required = true
+				}
+			}
+		}
+		if !required {
+			orphans = append(orphans, component...)
+		}
+	}
+	return orphans
+}
+
+// Cycles returns the dependency loops of the graph: the strongly connected components having more
+// than one package.
+// ref. https://en.wikipedia.org/wiki/Tarjan%27s_strongly_connected_components_algorithm
+func (g *DependencyGraph) Cycles() [][]*DependencyNode {
+	var cycles [][]*DependencyNode
+	for _, component := range g.components() {
+		if len(component) > 1 {
+			cycles = append(cycles, component)
+		}
+	}
+	return cycles
+}
+
+// TopologicalSort orders the packages so that every package comes after the packages it requires.
+// When the graph has loops the packages of each loop are kept next to each other, the complete order
+// is still returned along with a *DependencyCycleError.
+func (g *DependencyGraph) TopologicalSort() ([]*DependencyNode, error) {
+	// Tarjan emits the components in reverse topological order of the condensed graph, which given
+	// edges pointing to requirements is exactly dependencies first.
+	var sorted []*DependencyNode
+	var cycles [][]*DependencyNode
+	for _, component := range g.components() {
+		if len(component) > 1 {
+			cycles = append(cycles, component)
+		}
+		sorted = append(sorted, component...)
+	}
+
+	if len(cycles) > 0 {
+		return sorted, &DependencyCycleError{Cycles: cycles}
+	}
+	return sorted, nil
+}
+
+// components returns the strongly connected components in reverse topological order. It is
+// iterative since dependency chains of big hosts easily exceed comfortable recursion depths.
+func (g *DependencyGraph) components() [][]*DependencyNode {
+	type frame struct {
+		node *DependencyNode
+		next int
+	}
+
+	counter := 0
+	indexes := make([]int, len(g.Nodes))
+	lowLinks := make([]int, len(g.Nodes))
+	onStack := make([]bool, len(g.Nodes))
+	for i := range indexes {
+		indexes[i] = -1
+	}
+
+	var stack []*DependencyNode
+	var components [][]*DependencyNode
+
+	for _, root := range g.Nodes {
+		if indexes[root.index] != -1 {
+			continue
+		}
+
+		callStack := []frame{{node: root}}
+		indexes[root.index], lowLinks[root.index] = counter, counter
+		counter++
+		stack = append(stack, root)
+		onStack[root.index] = true
+
+		for len(callStack) > 0 {
+			top := &callStack[len(callStack)-1]
+			v := top.node
+
+			if top.next < len(v.Requires) {
+				w := v.Requires[top.next]
+				top.next++
+				if indexes[w.index] == -1 {
+					indexes[w.index], lowLinks[w.index] = counter, counter
+					counter++
+					stack = append(stack, w)
+					onStack[w.index] = true
+					callStack = append(callStack, frame{node: w})
+				} else if onStack[w.index] && indexes[w.index] < lowLinks[v.index] {
+					lowLinks[v.index] = indexes[w.index]
+				}
+				continue
+			}
+
+			if lowLinks[v.index] == indexes[v.index] {
+				var component []*DependencyNode
+				for {
+					w := stack[len(stack)-1]
+					stack = stack[:len(stack)-1]
+					onStack[w.index] = false
+					component = append(component, w)
+					if w == v {
+						break
+					}
+				}
+				components = append(components, component)
+			}
+
+			callStack = callStack[:len(callStack)-1]
+			if len(callStack) > 0 {
+				parent := callStack[len(callStack)-1].node
+				if lowLinks[v.index] < lowLinks[parent.index] {
+					lowLinks[parent.index] = lowLinks[v.index]
+				}
+			}
+		}
+	}
+
+	return components
+}
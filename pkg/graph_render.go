@@ -0,0 +1,106 @@
+package rpmdb
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// GraphDirection is the edges a rendered dependency tree follows from its root.
+type GraphDirection int
+
+const (
+	// GraphRequires follows the requirements: what the root needs installed.
+	GraphRequires GraphDirection = iota
+	// GraphRequiredBy follows the dependents: what removing the root would break.
+	GraphRequiredBy
+)
+
+// dependencyEdge is a requirement of a rendered tree, from the package requiring to the provider,
+// whatever the direction of the tree.
+type dependencyEdge struct {
+	from, to *DependencyNode
+}
+
+// dependencyTree returns the nodes reached from root in direction within depth edges, depth < 1
+// meaning no limit, in breadth first order, and the edges followed.
+func (g *DependencyGraph) dependencyTree(root *DependencyNode, direction GraphDirection, depth int) ([]*DependencyNode, []dependencyEdge) {
+	nodes := []*DependencyNode{root}
+	var edges []dependencyEdge
+	visited := map[*DependencyNode]bool{root: true}
+
+	level := []*DependencyNode{root}
+	for distance := 0; len(level) > 0 && (depth < 1 || distance < depth); distance++ {
+		var next []*DependencyNode
+		for _, node := range level {
+			neighbors := node.Requires
+			if direction == GraphRequiredBy {
+				neighbors = node.RequiredBy
+			}
+			for _, neighbor := range neighbors {
+				if direction == GraphRequiredBy {
+					edges = append(edges, dependencyEdge{from: neighbor, to: node})
+				} else {
+					edges = append(edges, dependencyEdge{from: node, to: neighbor})
+				}
+				if !visited[neighbor] {
+					visited[neighbor] = true
+					nodes = append(nodes, neighbor)
+					next = append(next, neighbor)
+				}
+			}
+		}
+		level = next
+	}
+	return nodes, edges
+}
+
+// WriteDOT writes the dependency tree of root in Graphviz DOT, up to depth edges away (no limit
+// when depth < 1), for `dot -Tsvg`. Edges point from a package to the packages it requires in both
+// directions, the root being drawn bold.
+// ref. https://graphviz.org/doc/info/lang.html
+func (g *DependencyGraph) WriteDOT(w io.Writer, root *DependencyNode, direction GraphDirection, depth int) error {
+	nodes, edges := g.dependencyTree(root, direction, depth)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "digraph %s {\n", dotID(root.Package.Name))
+	fmt.Fprintln(bw, "\trankdir=LR;")
+	for _, node := range nodes {
+		if node == root {
+			fmt.Fprintf(bw, "\t%s [style=bold];\n", dotID(node.Package.NEVRA()))
+		} else {
+			fmt.Fprintf(bw, "\t%s;\n", dotID(node.Package.NEVRA()))
+		}
+	}
+	for _, edge := range edges {
+		fmt.Fprintf(bw, "\t%s -> %s;\n", dotID(edge.from.Package.NEVRA()), dotID(edge.to.Package.NEVRA()))
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// dotID quotes s as a DOT identifier.
+func dotID(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// WriteMermaid writes the dependency tree of root as a Mermaid flowchart, for the documents
+// rendering them, like WriteDOT does. Nodes are named n0, n1... in breadth first order, the root
+// being n0, and labeled with their NEVRA.
+// ref. https://mermaid.js.org/syntax/flowchart.html
+func (g *DependencyGraph) WriteMermaid(w io.Writer, root *DependencyNode, direction GraphDirection, depth int) error {
+	nodes, edges := g.dependencyTree(root, direction, depth)
+	ids := make(map[*DependencyNode]string, len(nodes))
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "flowchart LR")
+	for i, node := range nodes {
+		ids[node] = fmt.Sprintf("n%d", i)
+		fmt.Fprintf(bw, "    %s[\"%s\"]\n", ids[node], strings.Replace(node.Package.NEVRA(), `"`, "#quot;", -1))
+	}
+	for _, edge := range edges {
+		fmt.Fprintf(bw, "    %s --> %s\n", ids[edge.from], ids[edge.to])
+	}
+	return bw.Flush()
+}
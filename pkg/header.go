@@ -0,0 +1,65 @@
+package rpmdb
+
+import "fmt"
+
+// Header is a decoded header blob, as stored in rpmdb or read from a package file.
+//
+// Headers written by buggy tools can hold a tag more than once. Like librpm, which returns the
+// first entry of a tag in index order, every lookup of this package uses the first occurrence;
+// All gives access to the others.
+type Header struct {
+	entries []indexEntry
+}
+
+// ParseHeader decodes a header blob without its magic, the format stored in rpmdb.
+func ParseHeader(blob []byte) (*Header, error) {
+	indexEntries, err := headerImport(blob)
+	if err != nil {
+		return nil, fmt.Errorf("error during importing header: %w", err)
+	}
+	return &Header{entries: indexEntries}, nil
+}
+
+// Tags lists the tags of the header in index order, each one once.
+func (h *Header) Tags() []TAG_ID {
+	var tags []TAG_ID
+	seen := make(map[TAG_ID]bool)
+	for _, entry := range h.entries {
+		if !seen[entry.Info.Tag] {
+			seen[entry.Info.Tag] = true
+			tags = append(tags, entry.Info.Tag)
+		}
+	}
+	return tags
+}
+
+// Get returns the value of the first occurrence of a tag, decoded as in PackageInfoEx.TagsMap,
+// or nil when the header doesn't have it.
+func (h *Header) Get(tag TAG_ID) (interface{}, error) {
+	entry := findEntry(h.entries, tag)
+	if entry == nil {
+		return nil, nil
+	}
+	v, err := entryValue(entry)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag %v: %w", tag, err)
+	}
+	return v, nil
+}
+
+// All returns the values of every occurrence of a tag in index order, the first one being the one
+// Get returns.
+func (h *Header) All(tag TAG_ID) ([]interface{}, error) {
+	var values []interface{}
+	for i := range h.entries {
+		if h.entries[i].Info.Tag != tag {
+			continue
+		}
+		v, err := entryValue(&h.entries[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag %v: %w", tag, err)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
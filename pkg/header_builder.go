@@ -0,0 +1,163 @@
+package rpmdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// HeaderBuilder assembles a header blob in the on-disk format stored by rpmdb (no magic), with an
+// immutable region covering every tag, the same layout headerExport produces.
+// ref. https://github.com/rpm-software-management/rpm/blob/rpm-4.11.3-release/lib/header.c#L347
+type HeaderBuilder struct {
+	regionTag TAG_ID
+	entries   map[TAG_ID]indexEntry
+}
+
+func NewHeaderBuilder() *HeaderBuilder {
+	return &HeaderBuilder{
+		regionTag: HEADER_IMMUTABLE,
+		entries:   make(map[TAG_ID]indexEntry),
+	}
+}
+
+func (b *HeaderBuilder) add(tag TAG_ID, typ TAG_TYPE, count uint32, data []byte) {
+	b.entries[tag] = indexEntry{
+		Info: entryInfo{
+			Tag:   tag,
+			Type:  typ,
+			Count: count,
+		},
+		Length: len(data),
+		Data:   data,
+	}
+}
+
+func (b *HeaderBuilder) AddString(tag TAG_ID, value string) {
+	b.add(tag, RPM_STRING_TYPE, 1, append([]byte(value), 0))
+}
+
+func (b *HeaderBuilder) AddI18NString(tag TAG_ID, value string) {
+	b.add(tag, RPM_I18NSTRING_TYPE, 1, append([]byte(value), 0))
+}
+
+func (b *HeaderBuilder) AddStringArray(tag TAG_ID, values []string) {
+	var data []byte
+	for _, v := range values {
+		data = append(data, v...)
+		data = append(data, 0)
+	}
+	b.add(tag, RPM_STRING_ARRAY_TYPE, uint32(len(values)), data)
+}
+
+func (b *HeaderBuilder) AddInt8(tag TAG_ID, values ...uint8) {
+	b.add(tag, RPM_INT8_TYPE, uint32(len(values)), append([]byte(nil), values...))
+}
+
+func (b *HeaderBuilder) AddInt16(tag TAG_ID, values ...uint16) {
+	data := make([]byte, 2*len(values))
+	for i, v := range values {
+		binary.BigEndian.PutUint16(data[2*i:], v)
+	}
+	b.add(tag, RPM_INT16_TYPE, uint32(len(values)), data)
+}
+
+func (b *HeaderBuilder) AddInt32(tag TAG_ID, values ...uint32) {
+	data := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.BigEndian.PutUint32(data[4*i:], v)
+	}
+	b.add(tag, RPM_INT32_TYPE, uint32(len(values)), data)
+}
+
+func (b *HeaderBuilder) AddInt64(tag TAG_ID, values ...uint64) {
+	data := make([]byte, 8*len(values))
+	for i, v := range values {
+		binary.BigEndian.PutUint64(data[8*i:], v)
+	}
+	b.add(tag, RPM_INT64_TYPE, uint32(len(values)), data)
+}
+
+func (b *HeaderBuilder) AddBinary(tag TAG_ID, value []byte) {
+	b.add(tag, RPM_BIN_TYPE, uint32(len(value)), value)
+}
+
+// Bytes returns the encoded header blob.
+func (b *HeaderBuilder) Bytes() ([]byte, error) {
+	if len(b.entries) == 0 {
+		return nil, errors.New("empty header")
+	}
+
+	var entries []indexEntry
+	for _, entry := range b.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Info.Tag < entries[j].Info.Tag
+	})
+
+	var data bytes.Buffer
+	for i := range entries {
+		// numeric values are aligned to their natural size
+		if align := typeAlignment(entries[i].Info.Type); align > 1 {
+			for data.Len()%align != 0 {
+				data.WriteByte(0)
+			}
+		}
+		entries[i].Info.Offset = int32(data.Len())
+		data.Write(entries[i].Data)
+	}
+
+	// the region tag comes first, its trailer at the end of the data points back to the whole index
+	il := int32(len(entries) + 1)
+	trailerOffset := int32(data.Len())
+	trailer := entryInfo{
+		Tag:    b.regionTag,
+		Type:   RPM_BIN_TYPE,
+		Offset: -il * 16,
+		Count:  16,
+	}
+	if err := binary.Write(&data, binary.BigEndian, trailer); err != nil {
+		return nil, fmt.Errorf("failed to write region trailer: %w", err)
+	}
+
+	var blob bytes.Buffer
+	if err := binary.Write(&blob, binary.BigEndian, il); err != nil {
+		return nil, fmt.Errorf("failed to write index length: %w", err)
+	}
+	if err := binary.Write(&blob, binary.BigEndian, int32(data.Len())); err != nil {
+		return nil, fmt.Errorf("failed to write data length: %w", err)
+	}
+
+	region := entryInfo{
+		Tag:    b.regionTag,
+		Type:   RPM_BIN_TYPE,
+		Offset: trailerOffset,
+		Count:  16,
+	}
+	if err := binary.Write(&blob, binary.BigEndian, region); err != nil {
+		return nil, fmt.Errorf("failed to write region entry: %w", err)
+	}
+	for _, entry := range entries {
+		if err := binary.Write(&blob, binary.BigEndian, entry.Info); err != nil {
+			return nil, fmt.Errorf("failed to write entry info: %w", err)
+		}
+	}
+	blob.Write(data.Bytes())
+
+	return blob.Bytes(), nil
+}
+
+func typeAlignment(typ TAG_TYPE) int {
+	switch typ {
+	case RPM_INT16_TYPE:
+		return 2
+	case RPM_INT32_TYPE:
+		return 4
+	case RPM_INT64_TYPE:
+		return 8
+	}
+	return 1
+}
@@ -0,0 +1,276 @@
+package image
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+
+	rpmdb "github.com/chennqqi/go-rpmdb/pkg"
+)
+
+// ChangeKind is how a layer changed the database entry of a package.
+type ChangeKind int
+
+const (
+	// Introduced is the first install of a package.
+	Introduced ChangeKind = iota + 1
+	// Modified replaces the entry of a package by another version of the same name and arch, an
+	// update or a downgrade, or by a rebuild of the same version with another payload.
+	Modified
+	// Deleted erases a package.
+	Deleted
+)
+
+var changeKindNames = map[ChangeKind]string{
+	Introduced: "introduced",
+	Modified:   "modified",
+	Deleted:    "deleted",
+}
+
+func (k ChangeKind) String() string {
+	if name, ok := changeKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("ChangeKind(%d)", int(k))
+}
+
+// Change is a change of the database entry of a package by a layer.
+type Change struct {
+	Kind        ChangeKind
+	Layer       int
+	LayerDigest string
+	// Package is the entry the layer wrote, the one it erased for a deletion.
+	Package *rpmdb.PackageInfo
+	// Previous is the entry a modification replaced.
+	Previous *rpmdb.PackageInfo
+}
+
+// LayerDiff is what a layer changed in the rpm database.
+type LayerDiff struct {
+	Index  int
+	Digest string
+	// DBChanged is set when the layer writes or deletes the database.
+	DBChanged bool
+	// Changes are sorted by NEVRA of their package.
+	Changes []*Change
+}
+
+// PackageAttribution is the history of a package across the layers of an image.
+type PackageAttribution struct {
+	// Package is the entry of the package in the image, the last one for a deleted package.
+	Package    *rpmdb.PackageInfo
+	Introduced *Change
+	// Modified is the last modification of the package, nil when none.
+	Modified *Change
+	// Deleted is the deletion of a package of Attribution.Deleted.
+	Deleted *Change
+}
+
+// Layer returns the change that wrote the entry of the package, its last modification or its
+// introduction.
+func (a *PackageAttribution) Layer() *Change {
+	if a.Modified != nil {
+		return a.Modified
+	}
+	return a.Introduced
+}
+
+// Attribution is the rpm database of an image, layer by layer.
+type Attribution struct {
+	Layers []*LayerDiff
+	// Packages are the packages of the image, Deleted the ones a layer installed and a later one
+	// erased, both sorted by NEVRA.
+	Packages []*PackageAttribution
+	Deleted  []*PackageAttribution
+}
+
+// Attribute reads the rpm database each layer writes, at any of rpmdb.DefaultDBPaths, and diffs
+// it with the one of the layers below. Whiteouts of the database or of its directories delete it.
+// Images without rpm database have no packages.
+func Attribute(ctx context.Context, layers []Layer) (*Attribution, error) {
+	attribution := &Attribution{}
+	installed := make(map[string]*PackageAttribution)
+	for i, layer := range layers {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		diff := &LayerDiff{Index: i, Digest: layer.Digest}
+		attribution.Layers = append(attribution.Layers, diff)
+
+		pkgs, changed, err := layerPackages(layer)
+		if err != nil {
+			return nil, fmt.Errorf("layer %d (%s): %w", i, layer.Digest, err)
+		}
+		if !changed {
+			continue
+		}
+		diff.DBChanged = true
+
+		change := func(kind ChangeKind, pkg, previous *rpmdb.PackageInfo) *Change {
+			c := &Change{Kind: kind, Layer: i, LayerDigest: layer.Digest, Package: pkg, Previous: previous}
+			diff.Changes = append(diff.Changes, c)
+			return c
+		}
+
+		// the same NEVRA is left alone unless rebuilt, the other entries are paired by name and arch
+		current := make(map[string]*PackageAttribution, len(pkgs))
+		var added []*rpmdb.PackageInfo
+		for _, pkg := range pkgs {
+			nevra := pkg.NEVRA()
+			a := installed[nevra]
+			if a == nil {
+				added = append(added, pkg)
+				continue
+			}
+			delete(installed, nevra)
+			if a.Package.PayloadDigest != pkg.PayloadDigest {
+				a.Modified = change(Modified, pkg, a.Package)
+			}
+			a.Package = pkg
+			current[nevra] = a
+		}
+		removed := make(map[string][]*PackageAttribution)
+		for _, a := range installed {
+			key := a.Package.Name + "." + a.Package.Arch
+			removed[key] = append(removed[key], a)
+		}
+		for _, as := range removed {
+			sort.Slice(as, func(i, j int) bool {
+				return as[i].Package.NEVRA() < as[j].Package.NEVRA()
+			})
+		}
+		sortPackages(added)
+		for _, pkg := range added {
+			key := pkg.Name + "." + pkg.Arch
+			if as := removed[key]; len(as) > 0 {
+				a := as[0]
+				removed[key] = as[1:]
+				a.Modified = change(Modified, pkg, a.Package)
+				a.Package = pkg
+				current[pkg.NEVRA()] = a
+				continue
+			}
+			current[pkg.NEVRA()] = &PackageAttribution{Package: pkg, Introduced: change(Introduced, pkg, nil)}
+		}
+		for _, as := range removed {
+			for _, a := range as {
+				a.Deleted = change(Deleted, a.Package, nil)
+				attribution.Deleted = append(attribution.Deleted, a)
+			}
+		}
+		installed = current
+
+		sort.Slice(diff.Changes, func(i, j int) bool {
+			return diff.Changes[i].Package.NEVRA() < diff.Changes[j].Package.NEVRA()
+		})
+	}
+
+	for _, a := range installed {
+		attribution.Packages = append(attribution.Packages, a)
+	}
+	sortAttributions(attribution.Packages)
+	sortAttributions(attribution.Deleted)
+	return attribution, nil
+}
+
+func sortPackages(pkgs []*rpmdb.PackageInfo) {
+	sort.Slice(pkgs, func(i, j int) bool {
+		return pkgs[i].NEVRA() < pkgs[j].NEVRA()
+	})
+}
+
+func sortAttributions(as []*PackageAttribution) {
+	sort.SliceStable(as, func(i, j int) bool {
+		return as[i].Package.NEVRA() < as[j].Package.NEVRA()
+	})
+}
+
+// layerPackages lists the packages of the database a layer writes. changed is false when the layer
+// leaves the database alone, and true with no packages when it deletes the database.
+func layerPackages(layer Layer) (pkgs []*rpmdb.PackageInfo, changed bool, err error) {
+	rc, err := layer.Open()
+	if err != nil {
+		return nil, false, err
+	}
+	defer rc.Close()
+
+	r, err := decompress(rc)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var db []byte
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid layer: %w", err)
+		}
+
+		name := path.Clean("/" + hdr.Name)
+		dir, base := path.Split(name)
+		for _, dbPath := range rpmdb.DefaultDBPaths {
+			dbDir, dbBase := path.Split(dbPath)
+			switch {
+			case name == dbPath && hdr.Typeflag == tar.TypeReg:
+				if db, err = ioutil.ReadAll(tr); err != nil {
+					return nil, false, fmt.Errorf("failed to read %s: %w", name, err)
+				}
+				changed = true
+			// whiteouts of the database or of a directory holding it
+			// ref. https://github.com/opencontainers/image-spec/blob/v1.0.2/layer.md#whiteouts
+			case dir == dbDir && base == ".wh."+dbBase,
+				base == ".wh..wh..opq" && strings.HasPrefix(dbPath, dir),
+				strings.HasPrefix(base, ".wh.") && strings.HasPrefix(dbPath, dir+strings.TrimPrefix(base, ".wh.")+"/"):
+				// a whiteout only hides the lower layers, the database of this layer stays
+				if db == nil {
+					changed = true
+				}
+			}
+		}
+	}
+	// the rest of the stream, for the blob digest to be checked
+	if _, err := io.Copy(ioutil.Discard, r); err != nil {
+		return nil, false, err
+	}
+	if db == nil {
+		return nil, changed, nil
+	}
+
+	pkgs, err = listPackages(db)
+	if err != nil {
+		return nil, false, err
+	}
+	return pkgs, true, nil
+}
+
+// listPackages reads a database held in memory.
+func listPackages(data []byte) ([]*rpmdb.PackageInfo, error) {
+	db, err := rpmdb.OpenReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return db.ListPackages()
+}
+
+// decompress returns r as is unless it starts with the gzip magic.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		return gzip.NewReader(br)
+	}
+	return br, nil
+}
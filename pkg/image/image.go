@@ -0,0 +1,228 @@
+// Package image reads the rpm database of container images layer by layer, to tell which layer
+// introduced, modified or deleted each package:
+//
+//	img, err := image.OpenLayout("/tmp/app-oci", "1.0")
+//	attribution, err := image.Attribute(ctx, img.Layers)
+//
+// Images are read from an OCI image layout, or from any Layer source such as a registry client.
+package image
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// ErrDigestMismatch is returned when reading a blob whose content doesn't match its digest.
+var ErrDigestMismatch = errors.New("digest mismatch")
+
+// the media types of the image indexes, OCI and Docker manifest lists
+var indexMediaTypes = map[string]struct{}{
+	"application/vnd.oci.image.index.v1+json":                   {},
+	"application/vnd.docker.distribution.manifest.list.v2+json": {},
+}
+
+// refNameAnnotation names the manifests of an index.json.
+// ref. https://github.com/opencontainers/image-spec/blob/v1.0.2/annotations.md#pre-defined-annotation-keys
+const refNameAnnotation = "org.opencontainers.image.ref.name"
+
+// Layer is a filesystem layer of an image.
+type Layer struct {
+	// Digest is the digest of the layer, "sha256:..." as in the image manifest.
+	Digest string
+	// Open returns the tar stream of the layer, gzip compressed or not.
+	Open func() (io.ReadCloser, error)
+}
+
+// Image is an image manifest with its layers, the base layer first.
+type Image struct {
+	// Digest is the digest of the manifest.
+	Digest string
+	// Annotations are the annotations of the manifest, org.opencontainers.image.base.name naming
+	// the base image for instance.
+	Annotations map[string]string
+	Layers      []Layer
+}
+
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Annotations map[string]string `json:"annotations"`
+	Platform    *struct {
+		OS           string `json:"os"`
+		Architecture string `json:"architecture"`
+	} `json:"platform"`
+}
+
+type index struct {
+	Manifests []descriptor `json:"manifests"`
+}
+
+type manifest struct {
+	Layers      []descriptor      `json:"layers"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// OpenLayout opens the image ref of the OCI image layout in dir, as written by skopeo copy
+// oci:<dir>:<ref> or buildah push. ref is matched against the org.opencontainers.image.ref.name
+// annotation of index.json, an empty ref selecting the only image of the layout. A multi-platform
+// image resolves to its linux manifest for runtime.GOARCH.
+// ref. https://github.com/opencontainers/image-spec/blob/v1.0.2/image-layout.md
+func OpenLayout(dir, ref string) (*Image, error) {
+	var idx index
+	if err := readJSON(filepath.Join(dir, "index.json"), &idx); err != nil {
+		return nil, err
+	}
+	var desc *descriptor
+	for i := range idx.Manifests {
+		m := &idx.Manifests[i]
+		if ref != "" && m.Annotations[refNameAnnotation] != ref {
+			continue
+		}
+		if desc != nil {
+			return nil, fmt.Errorf("several images in %s, select one by ref", dir)
+		}
+		desc = m
+	}
+	if desc == nil {
+		return nil, fmt.Errorf("image not found: %q", ref)
+	}
+
+	for {
+		if _, ok := indexMediaTypes[desc.MediaType]; !ok {
+			break
+		}
+		var platforms index
+		if err := readBlobJSON(dir, desc.Digest, &platforms); err != nil {
+			return nil, err
+		}
+		desc = nil
+		for i := range platforms.Manifests {
+			m := &platforms.Manifests[i]
+			if m.Platform != nil && m.Platform.OS == "linux" && m.Platform.Architecture == runtime.GOARCH {
+				desc = m
+				break
+			}
+		}
+		if desc == nil {
+			return nil, fmt.Errorf("no linux/%s image for %q", runtime.GOARCH, ref)
+		}
+	}
+
+	var m manifest
+	if err := readBlobJSON(dir, desc.Digest, &m); err != nil {
+		return nil, err
+	}
+	img := &Image{Digest: desc.Digest, Annotations: m.Annotations}
+	for _, layer := range m.Layers {
+		if strings.HasSuffix(layer.MediaType, "+zstd") {
+			return nil, fmt.Errorf("unsupported layer media type: %s", layer.MediaType)
+		}
+		blob, err := blobPath(dir, layer.Digest)
+		if err != nil {
+			return nil, err
+		}
+		digest := layer.Digest
+		img.Layers = append(img.Layers, Layer{Digest: digest, Open: func() (io.ReadCloser, error) {
+			return openBlob(blob, digest)
+		}})
+	}
+	return img, nil
+}
+
+// BaseLayers returns the number of layers of layers coming from the base image base, their common
+// prefix: a package whose Introduced.Layer is below it comes with the base image.
+func BaseLayers(layers, base []Layer) int {
+	n := 0
+	for n < len(layers) && n < len(base) && layers[n].Digest == base[n].Digest {
+		n++
+	}
+	return n
+}
+
+func readJSON(path string, v interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("invalid %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+func readBlobJSON(dir, digest string, v interface{}) error {
+	path, err := blobPath(dir, digest)
+	if err != nil {
+		return err
+	}
+	rc, err := openBlob(path, digest)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("invalid blob %s: %w", digest, err)
+	}
+	return nil
+}
+
+// blobPath returns the path of the blob of a digest, checked not to leave the layout.
+func blobPath(dir, digest string) (string, error) {
+	i := strings.IndexByte(digest, ':')
+	if i < 0 || newDigestHash(digest[:i]) == nil {
+		return "", fmt.Errorf("unsupported digest: %q", digest)
+	}
+	if _, err := hex.DecodeString(digest[i+1:]); err != nil || len(digest[i+1:]) != 2*newDigestHash(digest[:i]).Size() {
+		return "", fmt.Errorf("invalid digest: %q", digest)
+	}
+	return filepath.Join(dir, "blobs", digest[:i], digest[i+1:]), nil
+}
+
+func newDigestHash(algorithm string) hash.Hash {
+	switch algorithm {
+	case "sha256":
+		return sha256.New()
+	case "sha512":
+		return sha512.New()
+	}
+	return nil
+}
+
+// verifiedBlob checks the digest of a blob once read to the end.
+type verifiedBlob struct {
+	*os.File
+	digest string
+	h      hash.Hash
+}
+
+func openBlob(path, digest string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &verifiedBlob{File: f, digest: digest, h: newDigestHash(digest[:strings.IndexByte(digest, ':')])}, nil
+}
+
+func (b *verifiedBlob) Read(p []byte) (int, error) {
+	n, err := b.File.Read(p)
+	b.h.Write(p[:n])
+	if err == io.EOF && hex.EncodeToString(b.h.Sum(nil)) != b.digest[strings.IndexByte(b.digest, ':')+1:] {
+		return n, fmt.Errorf("%w: %s", ErrDigestMismatch, b.digest)
+	}
+	return n, err
+}
@@ -0,0 +1,185 @@
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+type testFile struct {
+	name string
+	data []byte
+}
+
+func testTar(t *testing.T, files ...testFile) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	for _, f := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// writeBlob stores data in the layout and returns its descriptor.
+func writeBlob(t *testing.T, dir, mediaType string, data []byte) map[string]interface{} {
+	sum := sha256.Sum256(data)
+	if err := os.MkdirAll(filepath.Join(dir, "blobs", "sha256"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "blobs", "sha256", hex.EncodeToString(sum[:])), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return map[string]interface{}{"mediaType": mediaType, "digest": "sha256:" + hex.EncodeToString(sum[:]), "size": len(data)}
+}
+
+func writeJSONBlob(t *testing.T, dir, mediaType string, v interface{}) map[string]interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writeBlob(t, dir, mediaType, data)
+}
+
+func TestAttribute(t *testing.T) {
+	plain, err := ioutil.ReadFile("../testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpd24, err := ioutil.ReadFile("../testdata/centos7-httpd24/Packages")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	layerType := "application/vnd.oci.image.layer.v1.tar+gzip"
+	var layers []interface{}
+	for _, data := range [][]byte{
+		testTar(t, testFile{"var/lib/rpm/Packages", plain}),
+		testTar(t, testFile{"etc/httpd.conf", []byte("Listen 80\n")}),
+		testTar(t, testFile{"var/lib/rpm/Packages", httpd24}),
+		testTar(t, testFile{"var/lib/.wh.rpm", nil}),
+	} {
+		layers = append(layers, writeBlob(t, dir, layerType, data))
+	}
+	config := writeBlob(t, dir, "application/vnd.oci.image.config.v1+json", []byte("{}"))
+	app := writeJSONBlob(t, dir, "application/vnd.oci.image.manifest.v1+json", map[string]interface{}{
+		"schemaVersion": 2, "config": config, "layers": layers[:3],
+		"annotations": map[string]string{"org.opencontainers.image.base.name": "centos:7"},
+	})
+	app["annotations"] = map[string]string{refNameAnnotation: "app"}
+	stripped := writeJSONBlob(t, dir, "application/vnd.oci.image.manifest.v1+json", map[string]interface{}{
+		"schemaVersion": 2, "config": config, "layers": layers,
+	})
+	stripped["platform"] = map[string]string{"os": "linux", "architecture": runtime.GOARCH}
+	platforms := writeJSONBlob(t, dir, "application/vnd.oci.image.index.v1+json", map[string]interface{}{
+		"schemaVersion": 2, "manifests": []interface{}{stripped},
+	})
+	platforms["annotations"] = map[string]string{refNameAnnotation: "stripped"}
+	data, err := json.Marshal(map[string]interface{}{"schemaVersion": 2, "manifests": []interface{}{app, platforms}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "index.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenLayout(dir, ""); err == nil {
+		t.Error("expected an error for a layout of several images")
+	}
+
+	img, err := OpenLayout(dir, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Digest != app["digest"] || img.Annotations["org.opencontainers.image.base.name"] != "centos:7" || len(img.Layers) != 3 {
+		t.Fatalf("unexpected image: %s %v %d layers", img.Digest, img.Annotations, len(img.Layers))
+	}
+	attribution, err := Attribute(context.Background(), img.Layers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kinds := make([]map[ChangeKind]int, len(attribution.Layers))
+	for i, diff := range attribution.Layers {
+		kinds[i] = make(map[ChangeKind]int)
+		for _, change := range diff.Changes {
+			kinds[i][change.Kind]++
+		}
+	}
+	if !attribution.Layers[0].DBChanged || kinds[0][Introduced] != 144 || len(attribution.Layers[0].Changes) != 144 {
+		t.Errorf("layer 0: %v", kinds[0])
+	}
+	if attribution.Layers[1].DBChanged || len(attribution.Layers[1].Changes) != 0 {
+		t.Errorf("layer 1: %v", kinds[1])
+	}
+	// 65 packages updated, 81 installed
+	if kinds[2][Modified] != 65 || kinds[2][Introduced] != 81 || kinds[2][Deleted] != 0 {
+		t.Errorf("layer 2: %v", kinds[2])
+	}
+	if len(attribution.Packages) != 225 || len(attribution.Deleted) != 0 {
+		t.Fatalf("unexpected packages: %d, deleted %d", len(attribution.Packages), len(attribution.Deleted))
+	}
+	for _, a := range attribution.Packages {
+		if a.Package.Name != "bash" {
+			continue
+		}
+		if a.Introduced.Layer != 0 || a.Modified == nil || a.Modified.Layer != 2 || a.Layer() != a.Modified ||
+			a.Modified.Previous.NEVRA() != "bash-4.2.46-30.el7.x86_64" {
+			t.Errorf("unexpected bash attribution: %+v", a)
+		}
+	}
+
+	base, err := OpenLayout(dir, "stripped")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := BaseLayers(img.Layers, base.Layers); n != 3 {
+		t.Errorf("unexpected base layers: %d", n)
+	}
+	attribution, err = Attribute(context.Background(), base.Layers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attribution.Packages) != 0 || len(attribution.Deleted) != 225 || attribution.Deleted[0].Deleted.Layer != 3 {
+		t.Errorf("unexpected whiteout: %d packages, %d deleted", len(attribution.Packages), len(attribution.Deleted))
+	}
+
+	// a corrupted layer
+	blob, err := blobPath(dir, layers[1].(map[string]interface{})["digest"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(blob, testTar(t, testFile{"etc/httpd.conf", []byte("Listen 8080\n")}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Attribute(context.Background(), img.Layers); !errors.Is(err, ErrDigestMismatch) {
+		t.Errorf("expected ErrDigestMismatch, got %v", err)
+	}
+}
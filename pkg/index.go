@@ -0,0 +1,158 @@
+package rpmdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/chennqqi/go-rpmdb/pkg/bdb"
+)
+
+// indexItem references the tag entry tagNum of the header instance hdrNum.
+// ref. https://github.com/rpm-software-management/rpm/blob/rpm-4.11.3-release/lib/backend/dbi.h#L31
+type indexItem struct {
+	hdrNum uint32
+	tagNum uint32
+}
+
+// lookupIndex returns the items recorded for key in the index database name (Name, Providename,
+// Basenames, ...) next to Packages, or in the table name of an rpmdb.sqlite. ok is false when the
+// database doesn't exist. Only the bucket of key is read, the key being tried with and without the
+// trailing NUL some rpm versions store.
+func (d *RpmDB) lookupIndex(name, key string) (items []indexItem, ok bool, err error) {
+	if s, ok := d.db.(*sqliteStorage); ok {
+		return s.lookupIndex(name, key)
+	}
+	db, ok, err := d.openIndex(name)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	defer db.Close()
+
+	for _, k := range [][]byte{[]byte(key), append([]byte(key), 0)} {
+		value, err := db.Get(k)
+		if err == bdb.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read index %s: %w", name, err)
+		}
+		items = append(items, decodeIndexItems(value)...)
+	}
+
+	return items, true, nil
+}
+
+// forEachIndexItem calls fn with every item of the index database name next to Packages, or of
+// the table name of an rpmdb.sqlite, and the key recording it. ok is false when the database
+// doesn't exist.
+func (d *RpmDB) forEachIndexItem(name string, fn func(key string, item indexItem) error) (ok bool, err error) {
+	if s, ok := d.db.(*sqliteStorage); ok {
+		return s.forEachIndexItem(name, fn)
+	}
+	db, ok, err := d.openIndex(name)
+	if err != nil || !ok {
+		return false, err
+	}
+	defer db.Close()
+
+	for entry := range db.ReadAll() {
+		if entry.Err != nil {
+			return false, fmt.Errorf("failed to read index %s: %w", name, entry.Err)
+		}
+		key := string(bytes.TrimRight(entry.Key, "\x00"))
+		for _, item := range decodeIndexItems(entry.Value) {
+			if err := fn(key, item); err != nil {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// openIndex opens the index database name next to Packages. ok is false when it doesn't exist.
+func (d *RpmDB) openIndex(name string) (db *bdb.BerkeleyDB, ok bool, err error) {
+	// databases opened from a dump have no directory, the index databases left next to an
+	// rpmdb.sqlite by a conversion are stale
+	if _, ok := d.db.(*sqliteStorage); ok || d.dir == "" {
+		return nil, false, nil
+	}
+	path := filepath.Join(d.dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return nil, false, nil
+	}
+
+	db, err = bdb.Open(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to open index %s: %w", name, err)
+	}
+	return db, true, nil
+}
+
+// PackageNames returns the sorted names of the installed packages, once whatever the number of
+// instances. With the Name index database next to Packages they are the keys of the index, no
+// header being read: telling whether a package is installed in hundreds of images then takes a
+// small file per image. The Name table of an rpmdb.sqlite is read the same way. Without them every
+// header is read.
+func (d *RpmDB) PackageNames() ([]string, error) {
+	names := make(map[string]bool)
+	db, ok, err := d.openIndex("Name")
+	if err != nil {
+		return nil, err
+	}
+	if s, isSQLite := d.db.(*sqliteStorage); isSQLite {
+		var keys []string
+		keys, ok, err = s.indexKeys("Name")
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			names[key] = true
+		}
+	} else if ok {
+		defer db.Close()
+		for entry := range db.ReadAll() {
+			if entry.Err != nil {
+				return nil, fmt.Errorf("failed to read index Name: %w", entry.Err)
+			}
+			// rpm drops the key along with its last item, an empty one is no package
+			if len(decodeIndexItems(entry.Value)) > 0 {
+				names[string(bytes.TrimRight(entry.Key, "\x00"))] = true
+			}
+		}
+	}
+	if !ok {
+		err := d.forEachIndex(func(hnum uint32, indexEntries []indexEntry) error {
+			pkg, err := getNEVRA(indexEntries)
+			if err != nil {
+				return fmt.Errorf("invalid package info: %w", err)
+			}
+			names[pkg.Name] = true
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, d.intern(name))
+	}
+	sort.Strings(sorted)
+	return sorted, nil
+}
+
+func decodeIndexItems(data []byte) []indexItem {
+	var items []indexItem
+	for i := 0; i+8 <= len(data); i += 8 {
+		items = append(items, indexItem{
+			hdrNum: binary.LittleEndian.Uint32(data[i:]),
+			tagNum: binary.LittleEndian.Uint32(data[i+4:]),
+		})
+	}
+	return items
+}
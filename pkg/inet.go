@@ -1,35 +1,13 @@
 package rpmdb
 
-import (
-	"bytes"
-	"encoding/binary"
-	"log"
-)
+import "math/bits"
 
+// Htonl swaps the bytes of val, the index entries of headers being big endian.
 func Htonl(val int32) int32 {
-	buf := new(bytes.Buffer)
-	if err := binary.Write(buf, binary.LittleEndian, val); err != nil {
-		log.Println(err)
-		return 0
-	}
-
-	if err := binary.Read(buf, binary.BigEndian, &val); err != nil {
-		log.Println(err)
-		return 0
-	}
-	return val
+	return int32(bits.ReverseBytes32(uint32(val)))
 }
 
+// HtonlU is Htonl for unsigned values.
 func HtonlU(val uint32) uint32 {
-	buf := new(bytes.Buffer)
-	if err := binary.Write(buf, binary.LittleEndian, val); err != nil {
-		log.Println(err)
-		return 0
-	}
-
-	if err := binary.Read(buf, binary.BigEndian, &val); err != nil {
-		log.Println(err)
-		return 0
-	}
-	return val
+	return bits.ReverseBytes32(val)
 }
@@ -0,0 +1,113 @@
+package rpmdb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// InstallOnlyNames lists the packages allowed to be installed in several versions side by side, the
+// defaults of dnf's installonlypkgs plus gpg-pubkey which rpm itself treats that way.
+var InstallOnlyNames = []string{
+	"kernel",
+	"kernel-PAE",
+	"kernel-core",
+	"kernel-modules",
+	"kernel-modules-extra",
+	"kernel-devel",
+	"kernel-debug",
+	"kernel-debug-core",
+	"kernel-debug-modules",
+	"kernel-debug-devel",
+	"kernel-uek",
+	"kernel-uek-devel",
+	"kernel-rt",
+	"kernel-rt-core",
+	"kernel-rt-modules",
+	"gpg-pubkey",
+}
+
+// the provides marking a package as installonly
+// ref. https://github.com/rpm-software-management/dnf/blob/4.2.23/dnf/const.py.in#L30
+var installOnlyProvides = []string{
+	"installonlypkg(",
+	"multiversion(kernel)",
+}
+
+// InstallOnlyPackage groups the installed versions of an installonly package.
+type InstallOnlyPackage struct {
+	Name string
+	// Installed is sorted by EVR, the newest last.
+	Installed []*PackageInfo
+}
+
+// Latest returns the newest installed version.
+func (p *InstallOnlyPackage) Latest() *PackageInfo {
+	return p.Installed[len(p.Installed)-1]
+}
+
+// Lookup returns the installed version matching a kernel release as printed by `uname -r`
+// ("version-release.arch" or "version-release"), or nil.
+func (p *InstallOnlyPackage) Lookup(release string) *PackageInfo {
+	for _, pkg := range p.Installed {
+		vr := pkg.Version + "-" + pkg.Release
+		if release == vr || release == vr+"."+pkg.Arch {
+			return pkg
+		}
+	}
+	return nil
+}
+
+// InstallOnlyPackages returns the installonly packages (kernels, kernel modules, gpg-pubkey and
+// anything providing installonlypkg()) with their installed versions sorted by EVR.
+func (d *RpmDB) InstallOnlyPackages() ([]*InstallOnlyPackage, error) {
+	var names []string
+	byName := make(map[string]*InstallOnlyPackage)
+
+	err := d.forEachPackage(func(hnum uint32, indexEntries []indexEntry, pkg *PackageInfo) error {
+		provideNames, err := tagStrings(indexEntries, RPMTAG_PROVIDENAME)
+		if err != nil {
+			return fmt.Errorf("invalid tag providename: %w", err)
+		}
+		if !isInstallOnly(pkg.Name, provideNames) {
+			return nil
+		}
+
+		group, ok := byName[pkg.Name]
+		if !ok {
+			group = &InstallOnlyPackage{Name: pkg.Name}
+			byName[pkg.Name] = group
+			names = append(names, pkg.Name)
+		}
+		group.Installed = append(group.Installed, pkg)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(names)
+	var groups []*InstallOnlyPackage
+	for _, name := range names {
+		group := byName[name]
+		sort.SliceStable(group.Installed, func(i, j int) bool {
+			return CompareEVR(group.Installed[i], group.Installed[j]) < 0
+		})
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+func isInstallOnly(name string, provideNames []string) bool {
+	if containsString(InstallOnlyNames, name) {
+		return true
+	}
+	for _, provide := range provideNames {
+		for _, prefix := range installOnlyProvides {
+			if strings.HasPrefix(provide, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package rpmdb
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// InstallReason tells why a package was installed.
+type InstallReason int
+
+const (
+	InstallReasonUnknown InstallReason = iota
+	// InstallReasonUser is a package explicitly requested by the user.
+	InstallReasonUser
+	// InstallReasonDependency is a package pulled in by the requirements of another one.
+	InstallReasonDependency
+	// InstallReasonWeakDependency is a package pulled in by weak dependencies (Recommends).
+	InstallReasonWeakDependency
+	// InstallReasonGroup is a package installed as member of a comps group.
+	InstallReasonGroup
+)
+
+var installReasonNames = map[InstallReason]string{
+	InstallReasonUnknown:        "unknown",
+	InstallReasonUser:           "user",
+	InstallReasonDependency:     "dep",
+	InstallReasonWeakDependency: "weak",
+	InstallReasonGroup:          "group",
+}
+
+// String returns the name yumdb and dnf use for the reason.
+func (r InstallReason) String() string {
+	if name, ok := installReasonNames[r]; ok {
+		return name
+	}
+	return installReasonNames[InstallReasonUnknown]
+}
+
+// ParseInstallReason converts a reason name as written by yum, dnf ("user", "dep", "weak", "group",
+// case insensitive, long forms like "dependency" accepted) to an InstallReason.
+func ParseInstallReason(s string) InstallReason {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "user":
+		return InstallReasonUser
+	case "dep", "dependency":
+		return InstallReasonDependency
+	case "weak", "weak_dependency", "weakdependency":
+		return InstallReasonWeakDependency
+	case "group":
+		return InstallReasonGroup
+	}
+	return InstallReasonUnknown
+}
+
+// InstallReasonOverlay provides install reasons recorded outside of rpmdb: rpm reserves
+// RPMTAG_AUTOINSTALLED but never writes it, the package managers keep track themselves.
+type InstallReasonOverlay interface {
+	// InstallReason returns InstallReasonUnknown for packages the overlay knows nothing about.
+	InstallReason(pkg *PackageInfo) InstallReason
+}
+
+// InstallReasons is an overlay of reasons keyed by "name-version-release.arch", the epoch is left out
+// since yumdb does the same.
+type InstallReasons map[string]InstallReason
+
+func (r InstallReasons) InstallReason(pkg *PackageInfo) InstallReason {
+	return r[installReasonKey(pkg.Name, pkg.Version, pkg.Release, pkg.Arch)]
+}
+
+func installReasonKey(name, version, release, arch string) string {
+	return name + "-" + version + "-" + release + "." + arch
+}
+
+// ReadYumDB loads the install reasons from a yumdb directory (/var/lib/yum/yumdb), where yum and
+// dnf < 2 keep a "reason" file per package in <letter>/<pkgid>-<name>-<version>-<release>-<arch>/.
+func ReadYumDB(dir string) (InstallReasons, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*", "*", "reason"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid yumdb path: %w", err)
+	}
+
+	reasons := make(InstallReasons)
+	for _, path := range paths {
+		// name may contain dashes, the pkgid, version, release and arch can't
+		nevra := filepath.Base(filepath.Dir(path))
+		parts := strings.Split(nevra, "-")
+		if len(parts) < 5 {
+			continue
+		}
+		n := len(parts)
+		name := strings.Join(parts[1:n-3], "-")
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		reasons[installReasonKey(name, parts[n-3], parts[n-2], parts[n-1])] = ParseInstallReason(string(data))
+	}
+	return reasons, nil
+}
+
+// ReadInstallReasons loads a sidecar file of "name-version-release.arch reason" lines, blank lines
+// and lines starting with # are skipped.
+func ReadInstallReasons(r io.Reader) (InstallReasons, error) {
+	reasons := make(InstallReasons)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"nvra reason\": %q", line, text)
+		}
+		reasons[fields[0]] = ParseInstallReason(fields[1])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read install reasons: %w", err)
+	}
+	return reasons, nil
+}
+
+// ReadInstallReasonsFile is ReadInstallReasons on a file.
+func ReadInstallReasonsFile(path string) (InstallReasons, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadInstallReasons(f)
+}
+
+// ApplyInstallReasons sets PackageInfo.InstallReason from the overlays, the first overlay knowing a
+// package wins over the next ones and over RPMTAG_AUTOINSTALLED.
+func ApplyInstallReasons(pkgList []*PackageInfo, overlays ...InstallReasonOverlay) {
+	for _, pkg := range pkgList {
+		for _, overlay := range overlays {
+			if reason := overlay.InstallReason(pkg); reason != InstallReasonUnknown {
+				pkg.InstallReason = reason
+				break
+			}
+		}
+	}
+}
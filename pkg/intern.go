@@ -0,0 +1,71 @@
+package rpmdb
+
+import "sync"
+
+// Interner keeps a single copy of equal strings. Vendors, licenses, archs or the DIRNAMES of
+// ListPackagesWithTags repeat across thousands of packages: an agent holding the inventory of a
+// large host, or of many roots sharing the interner, then retains each of them once. It is safe for
+// concurrent use.
+type Interner struct {
+	mu      sync.Mutex
+	strings map[string]string
+}
+
+func NewInterner() *Interner {
+	return &Interner{strings: make(map[string]string)}
+}
+
+// WithInterner interns the strings of the packages listed, the digests unique to a package apart.
+func WithInterner(in *Interner) Option {
+	return func(d *RpmDB) {
+		d.interner = in
+	}
+}
+
+// Intern returns the copy of s kept by the interner, s itself the first time.
+func (in *Interner) Intern(s string) string {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if interned, ok := in.strings[s]; ok {
+		return interned
+	}
+	in.strings[s] = s
+	return s
+}
+
+// Len returns the number of distinct strings kept.
+func (in *Interner) Len() int {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return len(in.strings)
+}
+
+// intern interns s when the database has an interner.
+func (d *RpmDB) intern(s string) string {
+	if d.interner == nil {
+		return s
+	}
+	return d.interner.Intern(s)
+}
+
+func (in *Interner) internPackage(pkg *PackageInfo) {
+	for _, f := range stringFields {
+		if v := f.value(pkg); *v != "" {
+			*v = in.Intern(*v)
+		}
+	}
+	pkg.Arch = in.Intern(pkg.Arch)
+}
+
+func (in *Interner) internTags(tags map[TAG_ID]interface{}) {
+	for tag, v := range tags {
+		switch value := v.(type) {
+		case string:
+			tags[tag] = in.Intern(value)
+		case []string:
+			for i := range value {
+				value[i] = in.Intern(value[i])
+			}
+		}
+	}
+}
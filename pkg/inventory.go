@@ -0,0 +1,233 @@
+package rpmdb
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+)
+
+// InventoryPayloadType is the payload type of the envelopes of SignInventory.
+const InventoryPayloadType = "application/vnd.go-rpmdb.inventory+json"
+
+// ErrInvalidSignature is returned by VerifyEnvelope when no signature of the envelope verifies.
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// CanonicalJSON encodes v as JSON deterministically, so that equal values hash and sign the same
+// whatever encoded them: object keys sorted bytewise, no insignificant whitespace, integers in
+// plain decimal and strings escaping only the quote, the backslash and the control characters.
+// Non-integer numbers have no fixed format and are rejected.
+// ref. http://wiki.laptop.org/go/Canonical_JSON
+func CanonicalJSON(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := writeCanonical(&buf, value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeCanonical(buf *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			buf.WriteString(strconv.FormatInt(i, 10))
+		} else if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			buf.WriteString(strconv.FormatUint(u, 10))
+		} else {
+			return fmt.Errorf("non-integer number in canonical JSON: %s", v)
+		}
+	case string:
+		writeCanonicalString(buf, v)
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, elem := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonical(buf, elem); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		buf.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeCanonicalString(buf, key)
+			buf.WriteByte(':')
+			if err := writeCanonical(buf, v[key]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("unexpected JSON value: %T", value)
+	}
+	return nil
+}
+
+func writeCanonicalString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case c < 0x20:
+			fmt.Fprintf(buf, "\\u%04x", c)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// Envelope is a signed payload in the DSSE format, the envelope of in-toto attestations, its JSON
+// encoding being the one of the specification.
+// ref. https://github.com/secure-systems-lab/dsse/blob/v1.0.0/envelope.md
+type Envelope struct {
+	PayloadType string              `json:"payloadType"`
+	Payload     []byte              `json:"payload"`
+	Signatures  []EnvelopeSignature `json:"signatures"`
+}
+
+// EnvelopeSignature is a signature of an Envelope. KeyID is the hex SHA-256 of the PKIX encoding
+// of the public key.
+type EnvelopeSignature struct {
+	KeyID string `json:"keyid,omitempty"`
+	Sig   []byte `json:"sig"`
+}
+
+// SignInventory signs the canonical JSON of the packages sorted by NEVRA in an envelope of
+// InventoryPayloadType, with SignEnvelope.
+func SignInventory(pkgs []*PackageInfo, signer crypto.Signer) (*Envelope, error) {
+	sorted := append([]*PackageInfo(nil), pkgs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].NEVRA() < sorted[j].NEVRA()
+	})
+	payload, err := CanonicalJSON(sorted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode inventory: %w", err)
+	}
+	return SignEnvelope(InventoryPayloadType, payload, signer)
+}
+
+// VerifyInventory checks the envelope of SignInventory with pub and returns the packages it holds.
+func VerifyInventory(envelope *Envelope, pub crypto.PublicKey) ([]*PackageInfo, error) {
+	if envelope.PayloadType != InventoryPayloadType {
+		return nil, fmt.Errorf("unexpected payload type: %q", envelope.PayloadType)
+	}
+	if err := VerifyEnvelope(envelope, pub); err != nil {
+		return nil, err
+	}
+	var pkgs []*PackageInfo
+	if err := json.Unmarshal(envelope.Payload, &pkgs); err != nil {
+		return nil, fmt.Errorf("invalid inventory: %w", err)
+	}
+	return pkgs, nil
+}
+
+// SignEnvelope signs a payload with signer, an ed25519, ECDSA or RSA (PKCS #1 v1.5) key, in a DSSE
+// envelope. ECDSA and RSA sign the SHA-256 of the pre-authentication encoding.
+func SignEnvelope(payloadType string, payload []byte, signer crypto.Signer) (*Envelope, error) {
+	keyID, err := publicKeyID(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	message := preAuthEncoding(payloadType, payload)
+	var sig []byte
+	if _, ok := signer.Public().(ed25519.PublicKey); ok {
+		sig, err = signer.Sign(rand.Reader, message, crypto.Hash(0))
+	} else {
+		digest := sha256.Sum256(message)
+		sig, err = signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign envelope: %w", err)
+	}
+	return &Envelope{
+		PayloadType: payloadType,
+		Payload:     payload,
+		Signatures:  []EnvelopeSignature{{KeyID: keyID, Sig: sig}},
+	}, nil
+}
+
+// VerifyEnvelope checks that a signature of the envelope verifies with pub, ErrInvalidSignature
+// being returned when none does.
+func VerifyEnvelope(envelope *Envelope, pub crypto.PublicKey) error {
+	message := preAuthEncoding(envelope.PayloadType, envelope.Payload)
+	digest := sha256.Sum256(message)
+	for _, signature := range envelope.Signatures {
+		verified := false
+		switch key := pub.(type) {
+		case ed25519.PublicKey:
+			verified = ed25519.Verify(key, message, signature.Sig)
+		case *ecdsa.PublicKey:
+			var rs struct{ R, S *big.Int }
+			if rest, err := asn1.Unmarshal(signature.Sig, &rs); err == nil && len(rest) == 0 {
+				verified = ecdsa.Verify(key, digest[:], rs.R, rs.S)
+			}
+		case *rsa.PublicKey:
+			verified = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature.Sig) == nil
+		default:
+			return fmt.Errorf("unsupported public key: %T", pub)
+		}
+		if verified {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// preAuthEncoding is the DSSE PAE of a payload, what is actually signed.
+// ref. https://github.com/secure-systems-lab/dsse/blob/v1.0.0/protocol.md
+func preAuthEncoding(payloadType string, payload []byte) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "DSSEv1 %d %s %d ", len(payloadType), payloadType, len(payload))
+	buf.Write(payload)
+	return buf.Bytes()
+}
+
+func publicKeyID(pub crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", fmt.Errorf("unsupported public key: %w", err)
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,98 @@
+package rpmdb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// LangFilter selects files by their %lang language, as the %_install_langs macro of rpm does.
+// Files of no language always match.
+type LangFilter struct {
+	// Include are the languages kept, every one when empty or when it holds "all". A file
+	// language matches a language it is a prefix of: "de" files are kept for "de_DE".
+	Include []string
+	// Exclude are the languages left out, matched like Include.
+	Exclude []string
+}
+
+// Match reports whether the filter keeps the file, a nil filter keeps everything.
+func (f *LangFilter) Match(file FileInfo) bool {
+	if f == nil || file.Lang == "" {
+		return true
+	}
+	if matchLangs(f.Exclude, file.Lang) {
+		return false
+	}
+	if len(f.Include) == 0 || containsString(f.Include, "all") {
+		return true
+	}
+	return matchLangs(f.Include, file.Lang)
+}
+
+// matchLangs reports whether one of the "|" separated file languages matches one of langs.
+// ref. https://github.com/rpm-software-management/rpm/blob/rpm-4.20.0-release/lib/transaction.c#L772
+func matchLangs(langs []string, fileLangs string) bool {
+	for _, lang := range langs {
+		for _, fileLang := range strings.Split(fileLangs, "|") {
+			if fileLang != "" && strings.HasPrefix(lang, fileLang) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// LocaleUsage is the space the language files of a package take.
+type LocaleUsage struct {
+	Package *PackageInfo
+	// Sizes are the sizes of the regular files by FILELANGS value.
+	Sizes map[string]int64
+	// Total is the size of every language file of the package.
+	Total int64
+	// Removable is the size of the language files the filter leaves out.
+	Removable int64
+}
+
+// LocaleUsage sums up the size of the language files of every package having some, the largest
+// first. keep tells which languages an image would be slimmed down to.
+func (d *RpmDB) LocaleUsage(keep *LangFilter) ([]*LocaleUsage, error) {
+	var usages []*LocaleUsage
+	err := d.forEachPackage(func(hnum uint32, indexEntries []indexEntry, pkg *PackageInfo) error {
+		files, err := fileInfos(indexEntries)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pkg.NEVRA(), err)
+		}
+
+		usage := &LocaleUsage{Package: pkg, Sizes: make(map[string]int64)}
+		for _, file := range files {
+			if file.Lang == "" {
+				continue
+			}
+			size := int64(0)
+			if file.Mode&modeTypeMask == modeRegular {
+				size = file.Size
+			}
+			usage.Sizes[file.Lang] += size
+			usage.Total += size
+			if !keep.Match(file) {
+				usage.Removable += size
+			}
+		}
+		if len(usage.Sizes) > 0 {
+			usages = append(usages, usage)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(usages, func(i, j int) bool {
+		if usages[i].Total != usages[j].Total {
+			return usages[i].Total > usages[j].Total
+		}
+		return usages[i].Package.NEVRA() < usages[j].Package.NEVRA()
+	})
+	return usages, nil
+}
@@ -0,0 +1,105 @@
+package rpmdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	// ErrNotRPM is returned for files not starting with the lead magic.
+	ErrNotRPM = errors.New("not an rpm package")
+	// ErrTruncatedLead is returned for files shorter than a lead.
+	ErrTruncatedLead = errors.New("truncated lead")
+	// ErrInvalidLead is returned for leads with an unsupported version, type or signature type, or
+	// a name overflowing its field.
+	ErrInvalidLead = errors.New("invalid lead")
+)
+
+// Lead is the 96 byte lead starting .rpm files. rpm reads little of it nowadays, the header tells
+// the same and more, but it is the first thing to look at in a damaged file.
+// ref. https://github.com/rpm-software-management/rpm/blob/rpm-4.11.3-release/lib/rpmlead.c#L28-L37
+type Lead struct {
+	Magic [4]byte
+	Major uint8
+	Minor uint8
+	// Type is RPMLEAD_BINARY or RPMLEAD_SOURCE.
+	Type uint16
+	// ArchNum is the arch number of rpmrc, 1 for every x86 flavour.
+	ArchNum uint16
+	// Name is "name-version-release", cut at 65 bytes.
+	Name  string
+	OSNum uint16
+	// SignatureType is RPMSIGTYPE_HEADERSIG for every package since rpm 3.
+	SignatureType uint16
+	Reserved      [16]byte
+}
+
+// ReadLead reads the lead of a .rpm file. The lead is returned with the validation error, if any,
+// as long as the 96 bytes could be read.
+func ReadLead(r io.Reader) (*Lead, error) {
+	data := make([]byte, leadSize)
+	n, err := io.ReadFull(r, data)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("%d bytes: %w", n, ErrTruncatedLead)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lead: %w", err)
+	}
+	return ParseLead(data)
+}
+
+// ParseLead decodes and validates a lead, see ReadLead.
+func ParseLead(data []byte) (*Lead, error) {
+	if len(data) < leadSize {
+		return nil, fmt.Errorf("%d bytes: %w", len(data), ErrTruncatedLead)
+	}
+	lead := &Lead{
+		Major:         data[4],
+		Minor:         data[5],
+		Type:          binary.BigEndian.Uint16(data[6:]),
+		ArchNum:       binary.BigEndian.Uint16(data[8:]),
+		OSNum:         binary.BigEndian.Uint16(data[76:]),
+		SignatureType: binary.BigEndian.Uint16(data[78:]),
+	}
+	copy(lead.Magic[:], data)
+	copy(lead.Reserved[:], data[80:leadSize])
+	name := data[10:76]
+	if i := bytes.IndexByte(name, 0); i >= 0 {
+		name = name[:i]
+	}
+	lead.Name = string(name)
+	return lead, lead.Validate()
+}
+
+// Validate checks the lead the way rpmLeadCheck does, accepting the rpm 2 and 3 era leads
+// ReadPackageFile reads.
+// ref. https://github.com/rpm-software-management/rpm/blob/rpm-4.11.3-release/lib/rpmlead.c#L80
+func (l *Lead) Validate() error {
+	if !bytes.Equal(l.Magic[:], leadMagic) {
+		return ErrNotRPM
+	}
+	if l.Major < 2 || l.Major > 4 {
+		return fmt.Errorf("unsupported version %d.%d: %w", l.Major, l.Minor, ErrInvalidLead)
+	}
+	if l.Type != RPMLEAD_BINARY && l.Type != RPMLEAD_SOURCE {
+		return fmt.Errorf("unsupported type %d: %w", l.Type, ErrInvalidLead)
+	}
+	switch l.SignatureType {
+	case RPMSIGTYPE_NONE, RPMSIGTYPE_PGP262_1024, RPMSIGTYPE_HEADERSIG:
+	default:
+		return fmt.Errorf("unsupported signature type %d: %w", l.SignatureType, ErrInvalidLead)
+	}
+	// the name field is NUL terminated
+	if len(l.Name) > 65 {
+		return fmt.Errorf("unterminated name: %w", ErrInvalidLead)
+	}
+	return nil
+}
+
+// IsSource reports whether the lead is the one of a source package.
+func (l *Lead) IsSource() bool {
+	return l.Type == RPMLEAD_SOURCE
+}
@@ -0,0 +1,279 @@
+//go:build librpm
+// +build librpm
+
+package rpmdb
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Cross-validation against librpm, run with `go test -tags librpm ./pkg/` on a host having rpm.
+// Besides the fixtures, the database directory given in RPMDB_CROSSCHECK_DBPATH (e.g. /var/lib/rpm) is
+// checked too, which is the way to catch decoder drift against new rpm releases.
+
+type rpmQueryPackage struct {
+	nevra      string
+	sha1Header string
+	files      []string
+	digests    []string
+}
+
+func TestLibrpm(t *testing.T) {
+	if _, err := exec.LookPath("rpm"); err != nil {
+		t.Skip("rpm not found")
+	}
+
+	dirs, err := filepath.Glob("testdata/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir := os.Getenv("RPMDB_CROSSCHECK_DBPATH"); dir != "" {
+		dirs = append(dirs, dir)
+	}
+
+	for _, dir := range dirs {
+		if _, err := os.Stat(filepath.Join(dir, "Packages")); err != nil {
+			continue
+		}
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			want := queryRpm(t, dir)
+
+			db, err := Open(filepath.Join(dir, "Packages"))
+			if err != nil {
+				t.Fatalf("Open() error: %v", err)
+			}
+			defer db.Close()
+
+			got := make(map[uint32]*rpmQueryPackage)
+			err = db.forEachHeader(func(hnum uint32, blob []byte) error {
+				indexEntries, err := headerImport(blob)
+				if err != nil {
+					return err
+				}
+				pkg, err := getNEVRA(indexEntries)
+				if err != nil {
+					return err
+				}
+				files, err := fileNames(indexEntries)
+				if err != nil {
+					return err
+				}
+				digests, err := tagStrings(indexEntries, RPMTAG_FILEDIGESTS)
+				if err != nil {
+					return err
+				}
+				got[hnum] = &rpmQueryPackage{
+					nevra:   pkg.NEVRA(),
+					files:   files,
+					digests: digests,
+				}
+				// legacy headers without region are not compared, rpm digests them differently
+				if region, err := immutableRegion(blob); err == nil {
+					digest := sha1.Sum(append(append([]byte{}, headerMagic...), region...))
+					got[hnum].sha1Header = hex.EncodeToString(digest[:])
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("failed to read packages: %v", err)
+			}
+
+			if len(got) != len(want) {
+				t.Errorf("pkg length: got %d, want %d", len(got), len(want))
+			}
+			for hnum, w := range want {
+				g, ok := got[hnum]
+				if !ok {
+					t.Errorf("%d: %s: missing", hnum, w.nevra)
+					continue
+				}
+				if g.nevra != w.nevra {
+					t.Errorf("%d: NEVRA: got %s, want %s", hnum, g.nevra, w.nevra)
+				}
+				// headers imported by old rpm releases may lack SHA1HEADER
+				if w.sha1Header != "" && g.sha1Header != "" && g.sha1Header != w.sha1Header {
+					t.Errorf("%s: header digest: got %s, want %s", w.nevra, g.sha1Header, w.sha1Header)
+				}
+				if !reflect.DeepEqual(g.files, w.files) {
+					t.Errorf("%s: files: got %d files, want %d files", w.nevra, len(g.files), len(w.files))
+				}
+				if !reflect.DeepEqual(g.digests, w.digests) {
+					t.Errorf("%s: file digests differ", w.nevra)
+				}
+			}
+		})
+	}
+}
+
+// queryRpm runs rpm against a copy of the database, rpm wants to take locks and may create the
+// index databases.
+func queryRpm(t *testing.T, dir string) map[uint32]*rpmQueryPackage {
+	dbPath, err := ioutil.TempDir("", "rpmdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dbPath)
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "Packages"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dbPath, "Packages"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pkgs := make(map[uint32]*rpmQueryPackage)
+	lines := rpmQuery(t, dbPath, `%{DBINSTANCE}\t%{NAME}\t%{EPOCHNUM}\t%{VERSION}\t%{RELEASE}\t%{ARCH}\t%{SHA1HEADER}\n`)
+	for _, fields := range lines {
+		if len(fields) != 7 {
+			t.Fatalf("unexpected rpm output: %q", fields)
+		}
+		pkg := &PackageInfo{
+			Name:    fields[1],
+			Version: fields[3],
+			Release: fields[4],
+			Arch:    rpmNone(fields[5]),
+		}
+		fmt.Sscan(fields[2], &pkg.Epoch)
+		pkgs[rpmInstance(t, fields[0])] = &rpmQueryPackage{
+			nevra:      pkg.NEVRA(),
+			sha1Header: rpmNone(fields[6]),
+		}
+	}
+
+	for _, fields := range rpmQuery(t, dbPath, `[%{=DBINSTANCE}\t%{FILENAMES}\t%{FILEDIGESTS}\n]`) {
+		if len(fields) != 3 {
+			t.Fatalf("unexpected rpm output: %q", fields)
+		}
+		pkg, ok := pkgs[rpmInstance(t, fields[0])]
+		if !ok {
+			t.Fatalf("unknown instance: %s", fields[0])
+		}
+		pkg.files = append(pkg.files, fields[1])
+		pkg.digests = append(pkg.digests, fields[2])
+	}
+
+	return pkgs
+}
+
+func rpmQuery(t *testing.T, dbPath, queryFormat string) [][]string {
+	// rpm >= 4.16 only reads Berkeley DB through the read-only backend, older releases ignore the macro
+	cmd := exec.Command("rpm", "--dbpath", dbPath, "--define", "_db_backend bdb_ro", "-qa", "--qf", queryFormat)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("rpm error: %v: %s", err, stderr.String())
+	}
+
+	var lines [][]string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, strings.Split(scanner.Text(), "\t"))
+	}
+	return lines
+}
+
+func rpmInstance(t *testing.T, s string) uint32 {
+	var hnum uint32
+	if _, err := fmt.Sscan(s, &hnum); err != nil {
+		t.Fatalf("invalid instance %q: %v", s, err)
+	}
+	return hnum
+}
+
+// rpmNone maps the "(none)" rpm prints for missing tags to an empty value.
+func rpmNone(s string) string {
+	if s == "(none)" {
+		return ""
+	}
+	return s
+}
+
+// TestLibrpmRandomHeaders checks that the integers of random headers decode as rpm reads them, the
+// numeric types printed unsigned by the queryformat.
+func TestLibrpmRandomHeaders(t *testing.T) {
+	if _, err := exec.LookPath("rpm"); err != nil {
+		t.Skip("rpm not found")
+	}
+	seed := time.Now().UnixNano()
+	t.Logf("seed %d", seed)
+	rnd := rand.New(rand.NewSource(seed))
+
+	dir, err := ioutil.TempDir("", "rpmdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var headers []*HeaderBuilder
+	for i := 0; i < 100; i++ {
+		v := randomValues{}.Generate(rnd, 16).Interface().(randomValues)
+		headers = append(headers, v.header(fmt.Sprintf("random%d", i)))
+	}
+	db, err := Open(createTestDB(t, dir, headers...))
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	var got []string
+	err = db.forEachHeader(func(hnum uint32, blob []byte) error {
+		indexEntries, err := headerImport(blob)
+		if err != nil {
+			return err
+		}
+		pkg, err := getNEVRA(indexEntries)
+		if err != nil {
+			return err
+		}
+		states, err := tagBytes(indexEntries, RPMTAG_FILESTATES)
+		if err != nil {
+			return err
+		}
+		modes, err := tagUint16s(indexEntries, RPMTAG_FILEMODES)
+		if err != nil {
+			return err
+		}
+		sizes, err := tagUint32s(indexEntries, RPMTAG_FILESIZES)
+		if err != nil {
+			return err
+		}
+		longs, err := tagUint64s(indexEntries, RPMTAG_LONGFILESIZES)
+		if err != nil {
+			return err
+		}
+		for i := range states {
+			got = append(got, fmt.Sprintf("%s\t%d\t%d\t%d\t%d", pkg.Name, states[i], modes[i], sizes[i], longs[i]))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to read packages: %v", err)
+	}
+
+	var want []string
+	for _, fields := range rpmQuery(t, dir, `[%{=NAME}\t%{FILESTATES}\t%{FILEMODES}\t%{FILESIZES}\t%{LONGFILESIZES}\n]`) {
+		want = append(want, strings.Join(fields, "\t"))
+	}
+	sort.Strings(got)
+	sort.Strings(want)
+	if onlyGot, onlyWant := diffSorted(got, want); len(onlyGot) > 0 || len(onlyWant) > 0 {
+		t.Errorf("values differ from rpm: got %q, want %q", onlyGot, onlyWant)
+	}
+}
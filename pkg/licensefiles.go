@@ -0,0 +1,54 @@
+package rpmdb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+)
+
+// LicenseFile is a %license file of a package, the license texts legal reviews export along with
+// the License tag.
+type LicenseFile struct {
+	Package *PackageInfo
+	File    FileInfo
+}
+
+// LicenseFiles lists the %license files of every package, sorted by NEVRA then path. Unlike %doc
+// files, rpm installs them even with --excludedocs: a file missing from the root was removed since.
+func (d *RpmDB) LicenseFiles() ([]*LicenseFile, error) {
+	var licenses []*LicenseFile
+	err := d.forEachPackage(func(hnum uint32, indexEntries []indexEntry, pkg *PackageInfo) error {
+		files, err := fileInfos(indexEntries)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pkg.NEVRA(), err)
+		}
+		for _, file := range files {
+			if file.Flags&RPMFILE_LICENSE == 0 {
+				continue
+			}
+			licenses = append(licenses, &LicenseFile{Package: pkg, File: file})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(licenses, func(i, j int) bool {
+		if a, b := licenses[i].Package.NEVRA(), licenses[j].Package.NEVRA(); a != b {
+			return a < b
+		}
+		return licenses[i].File.Path < licenses[j].File.Path
+	})
+	return licenses, nil
+}
+
+// Read returns the text of the license file in root, the root filesystem the database belongs to,
+// resolving symlinks within it.
+func (l *LicenseFile) Read(root string) ([]byte, error) {
+	path, err := resolveInRoot(root, l.File.Path, true)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(path)
+}
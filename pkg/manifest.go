@@ -0,0 +1,271 @@
+package rpmdb
+
+import (
+	"bytes"
+	"crypto"
+	_ "crypto/md5"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var hashNames = map[crypto.Hash]string{
+	crypto.MD5:    "md5",
+	crypto.SHA1:   "sha1",
+	crypto.SHA224: "sha224",
+	crypto.SHA256: "sha256",
+	crypto.SHA384: "sha384",
+	crypto.SHA512: "sha512",
+}
+
+// ManifestEntry is a package of a PackageManifest.
+type ManifestEntry struct {
+	NEVRA string
+	// Digest is the hex digest of the immutable header region, equal to the SHA1HEADER and
+	// SHA256HEADER tags for sha1 and sha256.
+	Digest string
+}
+
+// PackageManifest is a deterministic description of an inventory: two hosts or images with the same
+// set of packages, built identically, have the same manifest digest regardless of install times and
+// database layout.
+type PackageManifest struct {
+	Algorithm string
+	// Entries are sorted by NEVRA then digest.
+	Entries []ManifestEntry
+	// Digest is the hex digest of the text form of the manifest.
+	Digest string
+}
+
+// Manifest computes the manifest of the database with the given hash algorithm.
+func Manifest(db *RpmDB, algo crypto.Hash) (*PackageManifest, error) {
+	name, ok := hashNames[algo]
+	if !ok || !algo.Available() {
+		return nil, fmt.Errorf("unsupported hash algorithm: %v", algo)
+	}
+
+	manifest := &PackageManifest{Algorithm: name}
+	err := db.forEachHeader(func(hnum uint32, blob []byte) error {
+		indexEntries, err := headerImport(blob)
+		if err != nil {
+			return fmt.Errorf("error during importing header: %w", err)
+		}
+		pkg, err := getNEVRA(indexEntries)
+		if err != nil {
+			return fmt.Errorf("invalid package info: %w", err)
+		}
+		digest, err := headerDigest(algo, blob)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pkg.NEVRA(), err)
+		}
+		manifest.Entries = append(manifest.Entries, ManifestEntry{
+			NEVRA:  pkg.NEVRA(),
+			Digest: digest,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	manifest.seal(algo)
+	return manifest, nil
+}
+
+// seal sorts the entries and computes the digest of the manifest.
+func (m *PackageManifest) seal(algo crypto.Hash) {
+	sort.Slice(m.Entries, func(i, j int) bool {
+		a, b := m.Entries[i], m.Entries[j]
+		if a.NEVRA != b.NEVRA {
+			return a.NEVRA < b.NEVRA
+		}
+		return a.Digest < b.Digest
+	})
+
+	h := algo.New()
+	h.Write(m.Bytes())
+	m.Digest = hex.EncodeToString(h.Sum(nil))
+}
+
+// headerDigest returns the hex digest of the immutable region of a header blob with its magic, the
+// data the SHA1HEADER and SHA256HEADER tags are computed on.
+func headerDigest(algo crypto.Hash, blob []byte) (string, error) {
+	region, err := immutableRegion(blob)
+	if err != nil {
+		return "", err
+	}
+	h := algo.New()
+	h.Write(headerMagic)
+	h.Write(region)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Bytes returns the text form of the manifest the digest is computed on: a "nevra algo:digest" line
+// per package.
+func (m *PackageManifest) Bytes() []byte {
+	var buf bytes.Buffer
+	for _, entry := range m.Entries {
+		fmt.Fprintf(&buf, "%s %s:%s\n", entry.NEVRA, m.Algorithm, entry.Digest)
+	}
+	return buf.Bytes()
+}
+
+// ParseManifest parses the text form of a manifest as returned by Bytes, a golden manifest whose
+// signature the caller verified for instance.
+func ParseManifest(data []byte) (*PackageManifest, error) {
+	manifest := &PackageManifest{}
+	for i, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid manifest line %d: %q", i+1, line)
+		}
+		algo, digest := splitDigest(fields[1])
+		if _, err := hex.DecodeString(digest); err != nil || digest == "" {
+			return nil, fmt.Errorf("invalid manifest line %d: invalid digest: %q", i+1, fields[1])
+		}
+		if manifest.Algorithm == "" {
+			manifest.Algorithm = algo
+		} else if algo != manifest.Algorithm {
+			return nil, fmt.Errorf("invalid manifest line %d: mixed hash algorithms: %s, %s", i+1, manifest.Algorithm, algo)
+		}
+		manifest.Entries = append(manifest.Entries, ManifestEntry{NEVRA: fields[0], Digest: digest})
+	}
+
+	hash, ok := manifestHash(manifest.Algorithm)
+	if !ok {
+		return nil, fmt.Errorf("unsupported hash algorithm: %q", manifest.Algorithm)
+	}
+	h := hash.New()
+	h.Write(data)
+	manifest.Digest = hex.EncodeToString(h.Sum(nil))
+	return manifest, nil
+}
+
+func splitDigest(s string) (string, string) {
+	i := strings.IndexByte(s, ':')
+	if i < 0 {
+		return "", s
+	}
+	return s[:i], s[i+1:]
+}
+
+func manifestHash(name string) (crypto.Hash, bool) {
+	for hash, n := range hashNames {
+		if n == name && hash.Available() {
+			return hash, true
+		}
+	}
+	return 0, false
+}
+
+// ManifestDrift is a package installed in another version or build than the manifest records.
+type ManifestDrift struct {
+	Expected ManifestEntry
+	Actual   ManifestEntry
+}
+
+// ManifestDiff is the difference between a database and a manifest.
+type ManifestDiff struct {
+	// Added are the installed packages the manifest doesn't have.
+	Added []ManifestEntry
+	// Removed are the packages of the manifest not installed.
+	Removed []ManifestEntry
+	// Drifted are the packages installed in another version, or the same version with another
+	// header: rebuilt or tampered with.
+	Drifted []ManifestDrift
+}
+
+// Empty reports whether the database matches the manifest.
+func (d *ManifestDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Drifted) == 0
+}
+
+// CompareToManifest diffs the installed packages against a golden manifest, computed with the same
+// hash algorithm. Packages are matched by name and arch, the arch being the last dot separated part
+// of the NEVRA.
+func CompareToManifest(db *RpmDB, golden *PackageManifest) (*ManifestDiff, error) {
+	hash, ok := manifestHash(golden.Algorithm)
+	if !ok {
+		return nil, fmt.Errorf("unsupported hash algorithm: %q", golden.Algorithm)
+	}
+	actual, err := Manifest(db, hash)
+	if err != nil {
+		return nil, err
+	}
+	return DiffManifests(golden, actual), nil
+}
+
+// DiffManifests diffs two manifests of the same hash algorithm, two snapshots of a database for
+// instance, matching the packages like CompareToManifest.
+func DiffManifests(expected, actual *PackageManifest) *ManifestDiff {
+	diff := &ManifestDiff{}
+
+	exact := make(map[ManifestEntry]int)
+	for _, entry := range expected.Entries {
+		exact[entry]++
+	}
+	var added []ManifestEntry
+	for _, entry := range actual.Entries {
+		if exact[entry] > 0 {
+			exact[entry]--
+			continue
+		}
+		added = append(added, entry)
+	}
+
+	// what is left of the manifest, by name and arch
+	removed := make(map[string][]ManifestEntry)
+	var keys []string
+	for _, entry := range expected.Entries {
+		if exact[entry] == 0 {
+			continue
+		}
+		exact[entry]--
+		key := manifestKey(entry.NEVRA)
+		if _, ok := removed[key]; !ok {
+			keys = append(keys, key)
+		}
+		removed[key] = append(removed[key], entry)
+	}
+
+	for _, entry := range added {
+		key := manifestKey(entry.NEVRA)
+		if candidates := removed[key]; len(candidates) > 0 {
+			diff.Drifted = append(diff.Drifted, ManifestDrift{Expected: candidates[0], Actual: entry})
+			removed[key] = candidates[1:]
+			continue
+		}
+		diff.Added = append(diff.Added, entry)
+	}
+	for _, key := range keys {
+		diff.Removed = append(diff.Removed, removed[key]...)
+	}
+	sort.Slice(diff.Removed, func(i, j int) bool {
+		return diff.Removed[i].NEVRA < diff.Removed[j].NEVRA
+	})
+	return diff
+}
+
+// manifestKey is the name and arch of a NEVRA, version and release never contain dashes and the arch
+// ends the release part
+func manifestKey(nevra string) string {
+	name, release := nevra, ""
+	if i := strings.LastIndexByte(name, '-'); i >= 0 {
+		name, release = name[:i], name[i+1:]
+	}
+	if i := strings.LastIndexByte(name, '-'); i >= 0 {
+		name = name[:i]
+	}
+	arch := ""
+	if i := strings.LastIndexByte(release, '.'); i >= 0 {
+		arch = release[i+1:]
+	}
+	return name + "." + arch
+}
@@ -0,0 +1,111 @@
+package rpmdb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ModularityLabel is the parsed RPMTAG_MODULARITYLABEL of a package built for a module stream.
+// ref. https://github.com/fedora-modularity/libmodulemd/blob/2.15.0/spec.v2.yaml
+type ModularityLabel struct {
+	Name    string
+	Stream  string
+	Version string
+	Context string
+}
+
+// ParseModularityLabel parses a "name:stream:version:context" label.
+func ParseModularityLabel(label string) (*ModularityLabel, error) {
+	parts := strings.Split(label, ":")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("invalid modularity label: %q", label)
+	}
+	for _, part := range parts {
+		if part == "" {
+			return nil, fmt.Errorf("invalid modularity label: %q", label)
+		}
+	}
+	return &ModularityLabel{Name: parts[0], Stream: parts[1], Version: parts[2], Context: parts[3]}, nil
+}
+
+func (l *ModularityLabel) String() string {
+	return l.Name + ":" + l.Stream + ":" + l.Version + ":" + l.Context
+}
+
+// ModuleStream is a module stream packages are installed from, which dnf has enabled.
+type ModuleStream struct {
+	Name   string
+	Stream string
+	// Builds are the "version:context" of the module builds the packages come from, sorted. An
+	// update of the stream installs the packages of a newer build, the unchanged ones staying.
+	Builds   []string
+	Packages []*PackageInfo
+}
+
+// ModuleReport is the result of FindModules.
+type ModuleReport struct {
+	// Streams are sorted by module name and stream.
+	Streams []*ModuleStream
+	// Mixed are the modules installed from several streams. dnf only enables one stream of a
+	// module: packages of another one break the module, installed by rpm or left over by a stream
+	// switch that didn't replace them.
+	Mixed []string
+	// Invalid are the packages whose label doesn't parse.
+	Invalid []*PackageInfo
+}
+
+// FindModules infers the enabled module streams from the modularity labels of the packages.
+func FindModules(pkgList []*PackageInfo) *ModuleReport {
+	report := &ModuleReport{}
+	streams := make(map[string]*ModuleStream)
+	builds := make(map[string]map[string]bool)
+	for _, pkg := range pkgList {
+		if pkg.ModularityLabel == "" {
+			continue
+		}
+		label, err := ParseModularityLabel(pkg.ModularityLabel)
+		if err != nil {
+			report.Invalid = append(report.Invalid, pkg)
+			continue
+		}
+
+		key := label.Name + ":" + label.Stream
+		stream, ok := streams[key]
+		if !ok {
+			stream = &ModuleStream{Name: label.Name, Stream: label.Stream}
+			streams[key] = stream
+			builds[key] = make(map[string]bool)
+			report.Streams = append(report.Streams, stream)
+		}
+		stream.Packages = append(stream.Packages, pkg)
+		if build := label.Version + ":" + label.Context; !builds[key][build] {
+			builds[key][build] = true
+			stream.Builds = append(stream.Builds, build)
+		}
+	}
+
+	sort.Slice(report.Streams, func(i, j int) bool {
+		a, b := report.Streams[i], report.Streams[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Stream < b.Stream
+	})
+	for i, stream := range report.Streams {
+		sort.Strings(stream.Builds)
+		if i > 0 && report.Streams[i-1].Name == stream.Name && !containsString(report.Mixed, stream.Name) {
+			report.Mixed = append(report.Mixed, stream.Name)
+		}
+	}
+	return report
+}
+
+// Modules runs FindModules over every package of the database.
+func (d *RpmDB) Modules() (*ModuleReport, error) {
+	pkgList, err := d.ListPackages()
+	if err != nil {
+		return nil, err
+	}
+	return FindModules(pkgList), nil
+}
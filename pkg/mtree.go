@@ -0,0 +1,129 @@
+package rpmdb
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// the mtree keywords of the digests, algorithms mtree doesn't know of are left out
+var mtreeDigestKeywords = map[DigestAlgo]string{
+	PGPHASHALGO_MD5:       "md5digest",
+	PGPHASHALGO_SHA1:      "sha1digest",
+	PGPHASHALGO_RIPEMD160: "rmd160digest",
+	PGPHASHALGO_SHA256:    "sha256digest",
+	PGPHASHALGO_SHA384:    "sha384digest",
+	PGPHASHALGO_SHA512:    "sha512digest",
+}
+
+type mtreeEntry struct {
+	file FileInfo
+	algo DigestAlgo
+}
+
+// WriteMtree writes the files of the packages named names, or of every package when there are
+// none, as an mtree(8) specification so that the filesystem can be checked with mtree -f or
+// bsdtar. Entries are full paths relative to the root, sorted; files shared by several packages
+// appear once. Ghost and missingok files are optional, files rpm didn't install are left out.
+func (d *RpmDB) WriteMtree(w io.Writer, names ...string) error {
+	var entries []mtreeEntry
+	err := d.forEachPackage(func(hnum uint32, indexEntries []indexEntry, pkg *PackageInfo) error {
+		if len(names) > 0 && !containsString(names, pkg.Name) {
+			return nil
+		}
+		files, err := fileInfos(indexEntries)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pkg.NEVRA(), err)
+		}
+		algo, err := digestAlgoTag(indexEntries, RPMTAG_FILEDIGESTALGO, PGPHASHALGO_MD5)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pkg.NEVRA(), err)
+		}
+		for _, file := range files {
+			if file.State == RPMFILE_STATE_NOTINSTALLED || file.State == RPMFILE_STATE_NETSHARED {
+				continue
+			}
+			entries = append(entries, mtreeEntry{file: file, algo: algo})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].file.Path < entries[j].file.Path
+	})
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("#mtree\n")
+	for i, entry := range entries {
+		if i > 0 && entries[i-1].file.Path == entry.file.Path {
+			continue
+		}
+		bw.WriteString(mtreeLine(entry.file, entry.algo))
+		bw.WriteByte('\n')
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write mtree: %w", err)
+	}
+	return nil
+}
+
+// mtreeLine formats the keywords of a file.
+// ref. https://man.freebsd.org/cgi/man.cgi?query=mtree&sektion=5
+func mtreeLine(file FileInfo, algo DigestAlgo) string {
+	keywords := []string{"." + mtreeEscape(file.Path)}
+
+	switch file.Mode & modeTypeMask {
+	case modeRegular:
+		keywords = append(keywords, "type=file", fmt.Sprintf("size=%d", file.Size))
+		if keyword, ok := mtreeDigestKeywords[algo]; ok && file.Digest != "" {
+			keywords = append(keywords, keyword+"="+file.Digest)
+		}
+	case modeDir:
+		keywords = append(keywords, "type=dir")
+	case modeSymlink:
+		keywords = append(keywords, "type=link", "link="+mtreeEscape(file.LinkTo))
+	case modeBlock, modeChar:
+		typ := "char"
+		if file.Mode&modeTypeMask == modeBlock {
+			typ = "block"
+		}
+		keywords = append(keywords, "type="+typ, fmt.Sprintf("device=native,%d,%d", file.Rdev>>8, file.Rdev&0xff))
+	case modeFIFO:
+		keywords = append(keywords, "type=fifo")
+	case modeSocket:
+		keywords = append(keywords, "type=socket")
+	}
+
+	keywords = append(keywords, fmt.Sprintf("mode=%04o", file.Mode&07777))
+	if file.Username != "" {
+		keywords = append(keywords, "uname="+mtreeEscape(file.Username))
+	}
+	if file.Groupname != "" {
+		keywords = append(keywords, "gname="+mtreeEscape(file.Groupname))
+	}
+	keywords = append(keywords, fmt.Sprintf("time=%d.000000000", file.MTime))
+	if file.Flags&(RPMFILE_GHOST|RPMFILE_MISSINGOK) != 0 {
+		keywords = append(keywords, "optional")
+	}
+	return strings.Join(keywords, " ")
+}
+
+// mtreeEscape encodes the bytes mtree would split or glob on as \ooo, like strsvis(3) with
+// VIS_OCTAL|VIS_WHITE|VIS_GLOB.
+func mtreeEscape(s string) string {
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c >= 0x7f || strings.IndexByte(`\#*?[`, c) >= 0 {
+			fmt.Fprintf(&buf, `\%03o`, c)
+			continue
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String()
+}
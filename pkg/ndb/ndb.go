@@ -0,0 +1,204 @@
+// Package ndb reads the Packages.db of rpm's native database format, the ndb backend of SUSE. The
+// file starts with a header followed by the slots of the packages, each slot telling where the
+// blob of its header is, in blocks of 16 bytes.
+// ref. https://github.com/rpm-software-management/rpm/blob/rpm-4.16.0-release/lib/backend/ndb/rpmpkg.c
+package ndb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/chennqqi/go-rpmdb/pkg/bdb"
+)
+
+const (
+	HeaderMagic = 'R' | 'p'<<8 | 'm'<<16 | 'P'<<24
+	SlotMagic   = 'S' | 'l'<<8 | 'o'<<16 | 't'<<24
+	BlobMagic   = 'B' | 'l'<<8 | 'b'<<16 | 'S'<<24
+	TailMagic   = 'B' | 'l'<<8 | 'b'<<16 | 'E'<<24
+
+	Version = 0
+
+	// the header takes the first two slots of the first slot page
+	HeaderSize = 32
+	SlotSize   = 16
+	PageSize   = 4096
+	BlockSize  = 16
+
+	BlobHeaderSize = 16
+	BlobTailSize   = 12
+
+	// a database of 4096 packages has 16 slot pages, more is a corrupt file
+	maxSlotPages = 2048
+)
+
+// Slot locates the blob of a package.
+type Slot struct {
+	PkgIndex    uint32
+	BlockOffset uint32
+	BlockCount  uint32
+}
+
+// DB is a Packages.db opened for reading.
+type DB struct {
+	file       io.ReaderAt
+	closer     io.Closer
+	Generation uint32
+	// Slots are the slots in use, sorted by package index.
+	Slots []Slot
+}
+
+func Open(path string) (*DB, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	db, err := NewReader(file, info.Size())
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	db.closer = file
+	return db, nil
+}
+
+// NewReader reads a database from r, size is the length of the database.
+func NewReader(r io.ReaderAt, size int64) (*DB, error) {
+	file := io.NewSectionReader(r, 0, size)
+	header := make([]byte, HeaderSize)
+	if _, err := file.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	if magic := binary.LittleEndian.Uint32(header); magic != HeaderMagic {
+		return nil, fmt.Errorf("unexpected magic number: %+v", magic)
+	}
+	if version := binary.LittleEndian.Uint32(header[4:]); version != Version {
+		return nil, fmt.Errorf("unsupported version: %d", version)
+	}
+	slotPages := binary.LittleEndian.Uint32(header[12:])
+	if slotPages == 0 || slotPages > maxSlotPages {
+		return nil, fmt.Errorf("invalid slot page count: %d", slotPages)
+	}
+
+	slots := make([]byte, int(slotPages)*PageSize-HeaderSize)
+	if _, err := file.ReadAt(slots, HeaderSize); err != nil {
+		return nil, fmt.Errorf("failed to read slots: %w", err)
+	}
+	db := &DB{file: file, Generation: binary.LittleEndian.Uint32(header[8:])}
+	for i := 0; i+SlotSize <= len(slots); i += SlotSize {
+		slot := slots[i : i+SlotSize]
+		if binary.LittleEndian.Uint32(slot) != SlotMagic {
+			return nil, fmt.Errorf("invalid slot %d", i/SlotSize)
+		}
+		// the free slots have no package
+		pkgIndex := binary.LittleEndian.Uint32(slot[4:])
+		if pkgIndex == 0 {
+			continue
+		}
+		db.Slots = append(db.Slots, Slot{
+			PkgIndex:    pkgIndex,
+			BlockOffset: binary.LittleEndian.Uint32(slot[8:]),
+			BlockCount:  binary.LittleEndian.Uint32(slot[12:]),
+		})
+	}
+	sort.Slice(db.Slots, func(i, j int) bool { return db.Slots[i].PkgIndex < db.Slots[j].PkgIndex })
+	return db, nil
+}
+
+func (db *DB) Close() error {
+	if db.closer == nil {
+		return nil
+	}
+	return db.closer.Close()
+}
+
+// Read returns the blobs of the packages in package index order, the index being the Key, in
+// little-endian like the bdb keys, and the PageNo of the entries.
+func (db *DB) Read() <-chan bdb.Entry {
+	return db.ReadUntil(nil)
+}
+
+// ReadUntil is Read stopping once done is closed: the channel is then closed, no blob being sent
+// anymore, and the reading goroutine exits.
+func (db *DB) ReadUntil(done <-chan struct{}) <-chan bdb.Entry {
+	entries := make(chan bdb.Entry)
+	go func() {
+		defer close(entries)
+		send := func(entry bdb.Entry) bool {
+			select {
+			case entries <- entry:
+				return true
+			case <-done:
+				return false
+			}
+		}
+		for _, slot := range db.Slots {
+			blob, err := db.readBlob(slot)
+			if err != nil {
+				send(bdb.Entry{Err: err})
+				return
+			}
+			key := make([]byte, 4)
+			binary.LittleEndian.PutUint32(key, slot.PkgIndex)
+			if !send(bdb.Entry{Key: key, Value: blob, PageNo: slot.PkgIndex}) {
+				return
+			}
+		}
+	}()
+	return entries
+}
+
+// ReadOverflow reads the blob of the package index pageNo, the Entry.PageNo of a previous Read.
+func (db *DB) ReadOverflow(pageNo uint32) ([]byte, error) {
+	i := sort.Search(len(db.Slots), func(i int) bool { return db.Slots[i].PkgIndex >= pageNo })
+	if i == len(db.Slots) || db.Slots[i].PkgIndex != pageNo {
+		return nil, fmt.Errorf("no such package: %d", pageNo)
+	}
+	return db.readBlob(db.Slots[i])
+}
+
+// readBlob reads the blob of a slot: a header of magic, package index, generation and length, the
+// blob, and a tail of checksum, length and magic ending the blocks of the slot.
+func (db *DB) readBlob(slot Slot) ([]byte, error) {
+	size := int64(slot.BlockCount) * BlockSize
+	if size < BlobHeaderSize+BlobTailSize {
+		return nil, fmt.Errorf("package %d: invalid block count: %d", slot.PkgIndex, slot.BlockCount)
+	}
+	head := make([]byte, BlobHeaderSize)
+	offset := int64(slot.BlockOffset) * BlockSize
+	if _, err := db.file.ReadAt(head, offset); err != nil {
+		return nil, fmt.Errorf("package %d: failed to read blob: %w", slot.PkgIndex, err)
+	}
+	if magic := binary.LittleEndian.Uint32(head); magic != BlobMagic {
+		return nil, fmt.Errorf("package %d: unexpected blob magic: %+v", slot.PkgIndex, magic)
+	}
+	if pkgIndex := binary.LittleEndian.Uint32(head[4:]); pkgIndex != slot.PkgIndex {
+		return nil, fmt.Errorf("package %d: blob of package %d", slot.PkgIndex, pkgIndex)
+	}
+	length := int64(binary.LittleEndian.Uint32(head[12:]))
+	if BlobHeaderSize+length+BlobTailSize > size {
+		return nil, fmt.Errorf("package %d: invalid blob length: %d", slot.PkgIndex, length)
+	}
+	blob := make([]byte, length)
+	if _, err := db.file.ReadAt(blob, offset+BlobHeaderSize); err != nil {
+		return nil, fmt.Errorf("package %d: failed to read blob: %w", slot.PkgIndex, err)
+	}
+	// a blob cut short by an interrupted write has no tail
+	tail := make([]byte, BlobTailSize)
+	if _, err := db.file.ReadAt(tail, offset+size-BlobTailSize); err != nil {
+		return nil, fmt.Errorf("package %d: failed to read blob: %w", slot.PkgIndex, err)
+	}
+	if binary.LittleEndian.Uint32(tail[8:]) != TailMagic || int64(binary.LittleEndian.Uint32(tail[4:])) != length {
+		return nil, fmt.Errorf("package %d: invalid blob tail", slot.PkgIndex)
+	}
+	return blob, nil
+}
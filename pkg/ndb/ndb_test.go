@@ -0,0 +1,87 @@
+package ndb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ndb")
+	if err != nil {
+		t.Fatalf("TempDir() error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "Packages.db")
+
+	// enough packages for a second slot page, blobs of every padding
+	w, err := Create(path)
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	blobs := make(map[uint32][]byte)
+	for i := uint32(1); i <= 300; i++ {
+		pkgIndex := i * 3
+		blobs[pkgIndex] = bytes.Repeat([]byte{byte(i)}, int(i*7))
+		if err := w.Put(pkgIndex, blobs[pkgIndex]); err != nil {
+			t.Fatalf("Put() error: %v", err)
+		}
+	}
+	if err := w.Put(3, nil); err == nil {
+		t.Errorf("Put() of a duplicate index succeeded")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+	last := uint32(0)
+	n := 0
+	for entry := range db.Read() {
+		if entry.Err != nil {
+			t.Fatalf("Read() error: %v", entry.Err)
+		}
+		pkgIndex := binary.LittleEndian.Uint32(entry.Key)
+		if pkgIndex <= last || pkgIndex != entry.PageNo {
+			t.Errorf("package %d after %d, page %d", pkgIndex, last, entry.PageNo)
+		}
+		last = pkgIndex
+		if !bytes.Equal(entry.Value, blobs[pkgIndex]) {
+			t.Errorf("package %d: got %d bytes, want %d", pkgIndex, len(entry.Value), len(blobs[pkgIndex]))
+		}
+		n++
+	}
+	if n != len(blobs) {
+		t.Errorf("got %d packages, want %d", n, len(blobs))
+	}
+
+	blob, err := db.ReadOverflow(150)
+	if err != nil || !bytes.Equal(blob, blobs[150]) {
+		t.Errorf("ReadOverflow(150): got %d bytes, %v", len(blob), err)
+	}
+	if _, err := db.ReadOverflow(151); err == nil {
+		t.Errorf("ReadOverflow() of a missing package succeeded")
+	}
+
+	// a blob whose tail was never written
+	slot := db.Slots[0]
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error: %v", err)
+	}
+	end := int(slot.BlockOffset+slot.BlockCount) * BlockSize
+	copy(data[end-BlobTailSize:end], make([]byte, BlobTailSize))
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	if _, err := db.ReadOverflow(slot.PkgIndex); err == nil {
+		t.Errorf("ReadOverflow() of a truncated blob succeeded")
+	}
+}
@@ -0,0 +1,100 @@
+package ndb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/adler32"
+	"os"
+)
+
+// Writer creates a Packages.db readable by Open and rpm. The blobs are kept in memory until Close,
+// which lays them out after the slot pages they need.
+type Writer struct {
+	file  *os.File
+	slots []Slot
+	blobs [][]byte
+}
+
+func Create(path string) (*Writer, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{file: file}, nil
+}
+
+// Put adds the blob of the package index pkgIndex, which must not be 0 nor used already.
+func (w *Writer) Put(pkgIndex uint32, blob []byte) error {
+	if pkgIndex == 0 {
+		return fmt.Errorf("invalid package index: 0")
+	}
+	for _, slot := range w.slots {
+		if slot.PkgIndex == pkgIndex {
+			return fmt.Errorf("duplicate package index: %d", pkgIndex)
+		}
+	}
+	size := BlobHeaderSize + len(blob) + BlobTailSize
+	w.slots = append(w.slots, Slot{PkgIndex: pkgIndex, BlockCount: uint32((size + BlockSize - 1) / BlockSize)})
+	w.blobs = append(w.blobs, blob)
+	return nil
+}
+
+// Close writes the header, the slots and the blobs, and closes the file.
+func (w *Writer) Close() error {
+	err := w.write()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (w *Writer) write() error {
+	// the header takes the first two slots
+	slotPages := (HeaderSize + len(w.slots)*SlotSize + PageSize - 1) / PageSize
+	if slotPages == 0 {
+		slotPages = 1
+	}
+	page := make([]byte, slotPages*PageSize)
+	var nextPkgIndex uint32 = 1
+	binary.LittleEndian.PutUint32(page, HeaderMagic)
+	binary.LittleEndian.PutUint32(page[4:], Version)
+	binary.LittleEndian.PutUint32(page[12:], uint32(slotPages))
+
+	blockOffset := uint32(slotPages * PageSize / BlockSize)
+	for i := 0; HeaderSize+i*SlotSize < len(page); i++ {
+		slot := page[HeaderSize+i*SlotSize:]
+		binary.LittleEndian.PutUint32(slot, SlotMagic)
+		if i >= len(w.slots) {
+			continue
+		}
+		w.slots[i].BlockOffset = blockOffset
+		binary.LittleEndian.PutUint32(slot[4:], w.slots[i].PkgIndex)
+		binary.LittleEndian.PutUint32(slot[8:], w.slots[i].BlockOffset)
+		binary.LittleEndian.PutUint32(slot[12:], w.slots[i].BlockCount)
+		blockOffset += w.slots[i].BlockCount
+		if w.slots[i].PkgIndex >= nextPkgIndex {
+			nextPkgIndex = w.slots[i].PkgIndex + 1
+		}
+	}
+	binary.LittleEndian.PutUint32(page[16:], nextPkgIndex)
+	if _, err := w.file.WriteAt(page, 0); err != nil {
+		return fmt.Errorf("failed to write slots: %w", err)
+	}
+
+	for i, slot := range w.slots {
+		data := make([]byte, int(slot.BlockCount)*BlockSize)
+		binary.LittleEndian.PutUint32(data, BlobMagic)
+		binary.LittleEndian.PutUint32(data[4:], slot.PkgIndex)
+		binary.LittleEndian.PutUint32(data[12:], uint32(len(w.blobs[i])))
+		copy(data[BlobHeaderSize:], w.blobs[i])
+		// the checksum covers the head, the blob and its padding
+		tail := data[len(data)-BlobTailSize:]
+		binary.LittleEndian.PutUint32(tail, adler32.Checksum(data[:len(data)-BlobTailSize]))
+		binary.LittleEndian.PutUint32(tail[4:], uint32(len(w.blobs[i])))
+		binary.LittleEndian.PutUint32(tail[8:], TailMagic)
+		if _, err := w.file.WriteAt(data, int64(slot.BlockOffset)*BlockSize); err != nil {
+			return fmt.Errorf("failed to write package %d: %w", slot.PkgIndex, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,305 @@
+package rpmdb
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+var (
+	// ref. https://github.com/rpm-software-management/rpm/blob/rpm-4.11.3-release/lib/rpmlead.c#L23
+	leadMagic = []byte{0xed, 0xab, 0xee, 0xdb}
+	// ref. https://github.com/rpm-software-management/rpm/blob/rpm-4.11.3-release/lib/header.c#L91
+	headerMagic = []byte{0x8e, 0xad, 0xe8, 0x01, 0x00, 0x00, 0x00, 0x00}
+)
+
+const (
+	leadSize = 96
+
+	RPMLEAD_BINARY = 0
+	RPMLEAD_SOURCE = 1
+
+	// signature types, only the header structure is still in use
+	// ref. https://github.com/rpm-software-management/rpm/blob/rpm-4.11.3-release/lib/signature.h#L13
+	RPMSIGTYPE_NONE        = 0 /*!< unsigned rpm 2 package */
+	RPMSIGTYPE_PGP262_1024 = 1 /*!< 256 byte PGP 2.6.3 signature */
+	RPMSIGTYPE_HEADERSIG   = 5 /*!< header style signature */
+
+	// size of the signature of RPMSIGTYPE_PGP262_1024 leads
+	pgp262SignatureSize = 256
+
+	// sanity limits of header sizes, like hdrchkTags and hdrchkData
+	// ref. https://github.com/rpm-software-management/rpm/blob/rpm-4.11.3-release/lib/header_internal.h#L43
+	maxHeaderTags = 0x0000ffff
+	maxHeaderData = 0x0fffffff
+
+	// the lead only knows about linux nowadays
+	leadOSLinux = 1
+)
+
+// ref. https://github.com/rpm-software-management/rpm/blob/rpm-4.11.3-release/rpmrc.in#L190
+var leadArchNums = map[string]uint16{
+	"i386":    1,
+	"i486":    1,
+	"i586":    1,
+	"i686":    1,
+	"x86_64":  1,
+	"alpha":   2,
+	"sparc":   3,
+	"mips":    4,
+	"ppc":     5,
+	"ia64":    9,
+	"s390":    14,
+	"s390x":   15,
+	"ppc64":   16,
+	"ppc64le": 16,
+	"aarch64": 19,
+}
+
+// legacy arch and os numbers, rpm 3 headers stored these instead of names in RPMTAG_ARCH and RPMTAG_OS
+// ref. https://github.com/rpm-software-management/rpm/blob/rpm-3.0.6-release/rpmrc.in
+var (
+	legacyArchNames = map[uint32]string{
+		1:  "i386",
+		2:  "alpha",
+		3:  "sparc",
+		4:  "mips",
+		5:  "ppc",
+		6:  "m68k",
+		7:  "sgi",
+		8:  "rs6000",
+		9:  "ia64",
+		10: "sparc64",
+		11: "mipsel",
+		12: "armv4l",
+	}
+	legacyOSNames = map[uint32]string{
+		1:  "Linux",
+		2:  "IRIX",
+		3:  "solaris",
+		4:  "SunOS",
+		5:  "AIX",
+		6:  "HP-UX",
+		7:  "osf1",
+		8:  "FreeBSD",
+		9:  "SCO_SV",
+		10: "IRIX64",
+		11: "NEXTSTEP",
+		12: "BSD_OS",
+	}
+)
+
+// WritePackageFile writes a minimal .rpm file made of the lead, a signature header with size and
+// digests, and the given header blob (as stored in rpmdb or produced by HeaderBuilder), followed by an
+// empty payload. This is enough for `rpm -qp` and for gpg-pubkey style metadata-only packages.
+func WritePackageFile(w io.Writer, blob []byte) error {
+	indexEntries, err := headerImport(blob)
+	if err != nil {
+		return fmt.Errorf("error during importing header: %w", err)
+	}
+	pkg, err := getNEVRA(indexEntries)
+	if err != nil {
+		return fmt.Errorf("invalid package info: %w", err)
+	}
+
+	leadType := uint16(RPMLEAD_BINARY)
+	for _, entry := range indexEntries {
+		if entry.Info.Tag == RPMTAG_SOURCEPACKAGE {
+			leadType = RPMLEAD_SOURCE
+		}
+	}
+
+	header := append(append([]byte{}, headerMagic...), blob...)
+
+	sig, err := signatureHeader(header)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	writeLead(&buf, pkg, leadType)
+	buf.Write(sig)
+	// the signature header is padded to an 8 byte boundary
+	if pad := len(sig) % 8; pad != 0 {
+		buf.Write(make([]byte, 8-pad))
+	}
+	buf.Write(header)
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write package: %w", err)
+	}
+	return nil
+}
+
+func signatureHeader(header []byte) ([]byte, error) {
+	md5sum := md5.Sum(header)
+	sha1sum := sha1.Sum(header)
+	sha256sum := sha256.Sum256(header)
+
+	sb := NewHeaderBuilder()
+	sb.regionTag = HEADER_SIGNATURES
+	sb.AddInt32(TAG_ID(RPMSIGTAG_SIZE), uint32(len(header)))
+	sb.AddBinary(TAG_ID(RPMSIGTAG_MD5), md5sum[:])
+	sb.AddString(TAG_ID(RPMSIGTAG_SHA1), hex.EncodeToString(sha1sum[:]))
+	sb.AddString(TAG_ID(RPMSIGTAG_SHA256), hex.EncodeToString(sha256sum[:]))
+
+	blob, err := sb.Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build signature header: %w", err)
+	}
+	return append(append([]byte{}, headerMagic...), blob...), nil
+}
+
+// ref. https://github.com/rpm-software-management/rpm/blob/rpm-4.11.3-release/lib/rpmlead.c#L28-L37
+func writeLead(buf *bytes.Buffer, pkg *PackageInfo, leadType uint16) {
+	lead := make([]byte, leadSize)
+	copy(lead, leadMagic)
+	lead[4] = 3 // major
+	lead[5] = 0 // minor
+	binary.BigEndian.PutUint16(lead[6:], leadType)
+	binary.BigEndian.PutUint16(lead[8:], leadArchNums[pkg.Arch])
+
+	name := pkg.Name + "-" + pkg.Version + "-" + pkg.Release
+	if len(name) > 65 {
+		name = name[:65]
+	}
+	copy(lead[10:76], name)
+
+	binary.BigEndian.PutUint16(lead[76:], leadOSLinux)
+	binary.BigEndian.PutUint16(lead[78:], RPMSIGTYPE_HEADERSIG)
+	buf.Write(lead)
+}
+
+// ReadPackageFile reads the package header of a .rpm file. Besides current packages it accepts the
+// rpm 2/3 era layouts: leads of major version 2, unsigned or PGP 2.6.3 signed packages and headers
+// without immutable region.
+func ReadPackageFile(r io.Reader) (*PackageInfo, error) {
+	indexEntries, _, err := readPackageHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := getNEVRA(indexEntries)
+	if err != nil {
+		return nil, fmt.Errorf("invalid package info: %w", err)
+	}
+	return pkg, nil
+}
+
+// PackageLayout tells where the parts of a .rpm file are, as offsets from its start.
+type PackageLayout struct {
+	// SignatureOffset is the end of the lead, SignatureSize includes the padding of the signature
+	// header.
+	SignatureOffset int64
+	SignatureSize   int64
+	HeaderOffset    int64
+	HeaderSize      int64
+	// PayloadOffset is where the compressed payload starts, PayloadSize is only known once the
+	// payload has been read.
+	PayloadOffset int64
+	PayloadSize   int64
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// readPackageHeader reads a .rpm file up to the end of its header, leaving r at the payload.
+func readPackageHeader(r io.Reader) ([]indexEntry, *PackageLayout, error) {
+	cr := &countingReader{r: r}
+	lead, err := ReadLead(cr)
+	if err != nil {
+		return nil, nil, err
+	}
+	layout := &PackageLayout{SignatureOffset: cr.n}
+
+	switch lead.SignatureType {
+	case RPMSIGTYPE_NONE:
+	case RPMSIGTYPE_PGP262_1024:
+		if _, err := io.CopyN(ioutil.Discard, cr, pgp262SignatureSize); err != nil {
+			return nil, nil, fmt.Errorf("failed to read signature: %w", err)
+		}
+	case RPMSIGTYPE_HEADERSIG:
+		sig, err := readHeaderStructure(cr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read signature header: %w", err)
+		}
+		// the signature header is padded to an 8 byte boundary
+		if pad := (len(headerMagic) + len(sig)) % 8; pad != 0 {
+			if _, err := io.CopyN(ioutil.Discard, cr, int64(8-pad)); err != nil {
+				return nil, nil, fmt.Errorf("failed to read signature padding: %w", err)
+			}
+		}
+	}
+	layout.HeaderOffset = cr.n
+	layout.SignatureSize = layout.HeaderOffset - layout.SignatureOffset
+
+	blob, err := readHeaderStructure(cr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	layout.PayloadOffset = cr.n
+	layout.HeaderSize = layout.PayloadOffset - layout.HeaderOffset
+
+	indexEntries, err := headerImport(blob)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error during importing header: %w", err)
+	}
+	return indexEntries, layout, nil
+}
+
+// SplitPackageFile copies the metadata of a .rpm file, the lead, signature and header, to headerW
+// and its compressed payload to payloadW, so that headers can be stored apart from deduplicated
+// payloads. Concatenating both gives the file back.
+func SplitPackageFile(r io.Reader, headerW, payloadW io.Writer) (*PackageLayout, error) {
+	var head bytes.Buffer
+	_, layout, err := readPackageHeader(io.TeeReader(r, &head))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := headerW.Write(head.Bytes()); err != nil {
+		return nil, fmt.Errorf("failed to write header: %w", err)
+	}
+	if layout.PayloadSize, err = io.Copy(payloadW, r); err != nil {
+		return nil, fmt.Errorf("failed to copy payload: %w", err)
+	}
+	return layout, nil
+}
+
+// readHeaderStructure reads a header with its magic and returns the blob without the magic, the
+// format headerImport takes.
+func readHeaderStructure(r io.Reader) ([]byte, error) {
+	intro := make([]byte, len(headerMagic)+8)
+	if _, err := io.ReadFull(r, intro); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(intro[:3], headerMagic[:3]) {
+		return nil, errors.New("invalid header magic")
+	}
+
+	il := binary.BigEndian.Uint32(intro[8:])
+	dl := binary.BigEndian.Uint32(intro[12:])
+	if il > maxHeaderTags || dl > maxHeaderData {
+		return nil, fmt.Errorf("header too large: il=%d dl=%d", il, dl)
+	}
+
+	blob := make([]byte, 8+16*int(il)+int(dl))
+	copy(blob, intro[8:])
+	if _, err := io.ReadFull(r, blob[8:]); err != nil {
+		return nil, err
+	}
+	return blob, nil
+}
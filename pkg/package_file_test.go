@@ -0,0 +1,289 @@
+package rpmdb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// legacyPackageFile assembles an rpm 2/3 era package: a lead with the given major version and
+// signature type, the signature, and a header without region storing arch and os numbers.
+func legacyPackageFile(t *testing.T, major byte, sigType uint16, sig []byte) []byte {
+	b := NewHeaderBuilder()
+	b.AddString(RPMTAG_NAME, "bash")
+	b.AddString(RPMTAG_VERSION, "1.14.7")
+	b.AddString(RPMTAG_RELEASE, "1")
+	b.AddInt32(RPMTAG_OS, 1)
+	b.AddInt32(RPMTAG_ARCH, 1)
+	b.AddStringArray(RPMTAG_OLDFILENAMES, []string{"/bin/bash"})
+
+	lead := make([]byte, leadSize)
+	copy(lead, leadMagic)
+	lead[4] = major
+	binary.BigEndian.PutUint16(lead[8:], 1)
+	copy(lead[10:], "bash-1.14.7-1")
+	binary.BigEndian.PutUint16(lead[76:], 1)
+	binary.BigEndian.PutUint16(lead[78:], sigType)
+
+	var buf bytes.Buffer
+	buf.Write(lead)
+	buf.Write(sig)
+	buf.Write(headerMagic)
+	buf.Write(legacyHeader(t, b))
+	return buf.Bytes()
+}
+
+func TestReadPackageFile(t *testing.T) {
+	b := NewHeaderBuilder()
+	b.AddString(RPMTAG_NAME, "bash")
+	b.AddString(RPMTAG_VERSION, "4.2.46")
+	b.AddString(RPMTAG_RELEASE, "30.el7")
+	b.AddInt32(RPMTAG_EPOCH, 1)
+	b.AddString(RPMTAG_ARCH, "x86_64")
+	blob, err := b.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes() error: %v", err)
+	}
+	var current bytes.Buffer
+	if err := WritePackageFile(&current, blob); err != nil {
+		t.Fatalf("WritePackageFile() error: %v", err)
+	}
+
+	vectors := []struct {
+		name string
+		file []byte
+		want string
+	}{
+		{
+			name: "current",
+			file: current.Bytes(),
+			want: "bash-1:4.2.46-30.el7.x86_64",
+		},
+		{
+			name: "rpm 3 unsigned",
+			file: legacyPackageFile(t, 3, RPMSIGTYPE_NONE, nil),
+			want: "bash-1.14.7-1.i386",
+		},
+		{
+			name: "rpm 2 pgp signed",
+			file: legacyPackageFile(t, 2, RPMSIGTYPE_PGP262_1024, make([]byte, pgp262SignatureSize)),
+			want: "bash-1.14.7-1.i386",
+		},
+	}
+
+	for _, v := range vectors {
+		t.Run(v.name, func(t *testing.T) {
+			pkg, err := ReadPackageFile(bytes.NewReader(v.file))
+			if err != nil {
+				t.Fatalf("ReadPackageFile() error: %v", err)
+			}
+			if pkg.NEVRA() != v.want {
+				t.Errorf("NEVRA: got %s, want %s", pkg.NEVRA(), v.want)
+			}
+		})
+	}
+
+	// truncated and foreign files are rejected rather than misread
+	if _, err := ReadPackageFile(bytes.NewReader(current.Bytes()[:leadSize+20])); err == nil {
+		t.Errorf("truncated package: expected error")
+	}
+	if _, err := ReadPackageFile(bytes.NewReader(make([]byte, leadSize))); err == nil {
+		t.Errorf("invalid lead: expected error")
+	}
+}
+
+func TestReadLead(t *testing.T) {
+	file := legacyPackageFile(t, 3, RPMSIGTYPE_NONE, nil)
+	lead, err := ReadLead(bytes.NewReader(file))
+	if err != nil {
+		t.Fatalf("ReadLead() error: %v", err)
+	}
+	want := Lead{Major: 3, ArchNum: 1, Name: "bash-1.14.7-1", OSNum: 1, SignatureType: RPMSIGTYPE_NONE}
+	copy(want.Magic[:], leadMagic)
+	if *lead != want {
+		t.Errorf("got %+v, want %+v", *lead, want)
+	}
+
+	tampered := func(offset int, b ...byte) []byte {
+		data := append([]byte{}, file[:leadSize]...)
+		copy(data[offset:], b)
+		return data
+	}
+	vectors := []struct {
+		name string
+		data []byte
+		err  error
+		lead bool
+	}{
+		{name: "truncated", data: file[:50], err: ErrTruncatedLead},
+		{name: "empty", data: nil, err: ErrTruncatedLead},
+		{name: "magic", data: tampered(0, 0xde, 0xad), err: ErrNotRPM, lead: true},
+		{name: "version", data: tampered(4, 5), err: ErrInvalidLead, lead: true},
+		{name: "type", data: tampered(6, 0, 7), err: ErrInvalidLead, lead: true},
+		{name: "signature type", data: tampered(78, 0, 2), err: ErrInvalidLead, lead: true},
+		{name: "name", data: tampered(10, bytes.Repeat([]byte("x"), 66)...), err: ErrInvalidLead, lead: true},
+	}
+	for _, v := range vectors {
+		t.Run(v.name, func(t *testing.T) {
+			lead, err := ReadLead(bytes.NewReader(v.data))
+			if !errors.Is(err, v.err) {
+				t.Errorf("error: got %v, want %v", err, v.err)
+			}
+			if (lead != nil) != v.lead {
+				t.Errorf("lead: got %+v", lead)
+			}
+		})
+	}
+
+	// ReadPackageFile reports lead errors the same way
+	if _, err := ReadPackageFile(bytes.NewReader(make([]byte, leadSize))); !errors.Is(err, ErrNotRPM) {
+		t.Errorf("ReadPackageFile() error: got %v, want %v", err, ErrNotRPM)
+	}
+}
+
+// deltaHead builds the head of a delta of the given version, as makedeltarpm writes it.
+func deltaHead(version byte, sourceNEVR string, seq, md5sum []byte, size uint32) []byte {
+	var buf bytes.Buffer
+	be32 := func(v uint32) {
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, v)
+		buf.Write(b)
+	}
+	buf.WriteString("DLT")
+	buf.WriteByte(version)
+	be32(uint32(len(sourceNEVR) + 1))
+	buf.WriteString(sourceNEVR + "\x00")
+	be32(uint32(len(seq)))
+	buf.Write(seq)
+	buf.Write(md5sum)
+	if version >= '2' {
+		be32(size)
+	}
+	// the rest of the delta is not read
+	buf.Write(make([]byte, 64))
+	return buf.Bytes()
+}
+
+func TestReadDeltaRPM(t *testing.T) {
+	seq := bytes.Repeat([]byte{0xab}, 18)
+	md5sum := bytes.Repeat([]byte{0x01}, 16)
+	head := deltaHead('3', "bash-4.2.46-30.el7", seq, md5sum, 1037976)
+	wantSeq := "bash-4.2.46-30.el7-" + strings.Repeat("ab", 18)
+
+	header := func(format string) []byte {
+		b := NewHeaderBuilder()
+		b.AddString(RPMTAG_NAME, "bash")
+		b.AddString(RPMTAG_VERSION, "4.2.46")
+		b.AddString(RPMTAG_RELEASE, "31.el7")
+		b.AddString(RPMTAG_ARCH, "x86_64")
+		b.AddString(RPMTAG_PAYLOADFORMAT, format)
+		b.AddString(RPMTAG_PAYLOADCOMPRESSOR, "gzip")
+		blob, err := b.Bytes()
+		if err != nil {
+			t.Fatalf("Bytes() error: %v", err)
+		}
+		var buf bytes.Buffer
+		if err := WritePackageFile(&buf, blob); err != nil {
+			t.Fatalf("WritePackageFile() error: %v", err)
+		}
+		return buf.Bytes()
+	}
+	var standard bytes.Buffer
+	standard.Write(header("drpm"))
+	gz := gzip.NewWriter(&standard)
+	gz.Write(head)
+	gz.Close()
+
+	var rpmOnly bytes.Buffer
+	rpmOnly.WriteString("drpmDLT3")
+	rpmOnly.Write([]byte{0, 0, 0, 18})
+	rpmOnly.WriteString("bash-4.2.46-31.el7")
+	rpmOnly.Write([]byte{0, 0, 0, 3, 'a', 'd', 'd'})
+	rpmOnly.Write(head)
+
+	delta, err := ReadDeltaRPM(bytes.NewReader(standard.Bytes()))
+	if err != nil {
+		t.Fatalf("ReadDeltaRPM() error: %v", err)
+	}
+	if delta.RPMOnly || delta.Version != 3 || delta.SourceNEVR != "bash-4.2.46-30.el7" || delta.TargetNEVR != "bash-4.2.46-31.el7" ||
+		delta.Sequence != wantSeq || delta.TargetMD5 != strings.Repeat("01", 16) || delta.TargetSize != 1037976 ||
+		delta.Target == nil || delta.Target.NEVRA() != "bash-4.2.46-31.el7.x86_64" {
+		t.Errorf("standard: got %+v", delta)
+	}
+
+	delta, err = ReadDeltaRPM(bytes.NewReader(rpmOnly.Bytes()))
+	if err != nil {
+		t.Fatalf("ReadDeltaRPM() error: %v", err)
+	}
+	if !delta.RPMOnly || delta.Version != 3 || delta.SourceNEVR != "bash-4.2.46-30.el7" || delta.TargetNEVR != "bash-4.2.46-31.el7" ||
+		delta.Sequence != wantSeq || delta.Target != nil {
+		t.Errorf("rpm-only: got %+v", delta)
+	}
+
+	// plain rpms are recognized as such
+	if _, err := ReadDeltaRPM(bytes.NewReader(header("cpio"))); !errors.Is(err, ErrNotDeltaRPM) {
+		t.Errorf("plain rpm: got %v, want %v", err, ErrNotDeltaRPM)
+	}
+	if _, err := ReadDeltaRPM(strings.NewReader("garbage")); !errors.Is(err, ErrNotDeltaRPM) {
+		t.Errorf("garbage: got %v, want %v", err, ErrNotDeltaRPM)
+	}
+}
+
+func TestSplitPackageFile(t *testing.T) {
+	b := NewHeaderBuilder()
+	b.AddString(RPMTAG_NAME, "bash")
+	b.AddString(RPMTAG_VERSION, "4.2.46")
+	b.AddString(RPMTAG_RELEASE, "30.el7")
+	b.AddString(RPMTAG_ARCH, "x86_64")
+	blob, err := b.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes() error: %v", err)
+	}
+	var file bytes.Buffer
+	if err := WritePackageFile(&file, blob); err != nil {
+		t.Fatalf("WritePackageFile() error: %v", err)
+	}
+	metadataSize := int64(file.Len())
+	payload := bytes.Repeat([]byte("payload "), 1000)
+	file.Write(payload)
+
+	var header, gotPayload bytes.Buffer
+	layout, err := SplitPackageFile(bytes.NewReader(file.Bytes()), &header, &gotPayload)
+	if err != nil {
+		t.Fatalf("SplitPackageFile() error: %v", err)
+	}
+	if !bytes.Equal(gotPayload.Bytes(), payload) {
+		t.Errorf("payload: got %d bytes, want %d", gotPayload.Len(), len(payload))
+	}
+	if !bytes.Equal(append(header.Bytes(), gotPayload.Bytes()...), file.Bytes()) {
+		t.Errorf("header and payload don't give the file back")
+	}
+
+	headerSize := int64(len(headerMagic) + len(blob))
+	want := PackageLayout{
+		SignatureOffset: leadSize,
+		SignatureSize:   metadataSize - leadSize - headerSize,
+		HeaderOffset:    metadataSize - headerSize,
+		HeaderSize:      headerSize,
+		PayloadOffset:   metadataSize,
+		PayloadSize:     int64(len(payload)),
+	}
+	if *layout != want {
+		t.Errorf("layout: got %+v, want %+v", *layout, want)
+	}
+	if layout.SignatureSize%8 != 0 {
+		t.Errorf("signature is not padded: %d", layout.SignatureSize)
+	}
+
+	// the header part alone is a valid package for ReadPackageFile
+	pkg, err := ReadPackageFile(&header)
+	if err != nil {
+		t.Fatalf("ReadPackageFile() error: %v", err)
+	}
+	if pkg.NEVRA() != "bash-4.2.46-30.el7.x86_64" {
+		t.Errorf("NEVRA: got %s", pkg.NEVRA())
+	}
+}
@@ -0,0 +1,121 @@
+package rpmdb
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path"
+)
+
+// Backend is the storage format of an rpm database.
+type Backend int
+
+const (
+	// BackendBDB is the Berkeley DB hash database of rpm < 4.16, the Packages file, which Open reads.
+	BackendBDB Backend = iota
+	// BackendSQLite is the rpmdb.sqlite of rpm >= 4.16, Fedora 33 and RHEL 9 onwards, which Open
+	// reads too.
+	BackendSQLite
+	// BackendNDB is the Packages.db of rpm's native format, used by SUSE, which Open reads too.
+	BackendNDB
+)
+
+var backendNames = map[Backend]string{
+	BackendBDB:    "bdb",
+	BackendSQLite: "sqlite",
+	BackendNDB:    "ndb",
+}
+
+// String returns the name of the backend in rpm's %_db_backend macro.
+func (b Backend) String() string {
+	if name, ok := backendNames[b]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// the first bytes of the database files of the backends, Berkeley DB keeping its magic number
+// further in
+var backendMagics = []struct {
+	magic   []byte
+	backend Backend
+}{
+	{[]byte("SQLite format 3\x00"), BackendSQLite},
+	{[]byte("RpmP"), BackendNDB},
+}
+
+// fileBackend tells the backend of the database file at path from its first bytes, BackendBDB when
+// they are none of the others.
+func fileBackend(path string) Backend {
+	f, err := os.Open(path)
+	if err != nil {
+		return BackendBDB
+	}
+	defer f.Close()
+	return readerBackend(f)
+}
+
+// readerBackend tells the backend of the database r holds like fileBackend.
+func readerBackend(r io.ReaderAt) Backend {
+	head := make([]byte, 16)
+	n, _ := r.ReadAt(head, 0)
+	for _, m := range backendMagics {
+		if bytes.HasPrefix(head[:n], m.magic) {
+			return m.backend
+		}
+	}
+	return BackendBDB
+}
+
+// Candidate is an rpm database found in a root filesystem.
+type Candidate struct {
+	// Path is the location of the database in the root, /var/lib/rpm/Packages for instance.
+	Path string
+	// HostPath is where it is on the host, resolved within the root.
+	HostPath string
+	Backend  Backend
+}
+
+// the database directories distributions use, the %_dbpath of rpm
+var dbDirs = []string{
+	// most distributions, often a symlink to /usr/lib/sysimage/rpm nowadays
+	"/var/lib/rpm",
+	// openSUSE and SLE 15, Fedora 36 onwards
+	"/usr/lib/sysimage/rpm",
+	// the read-only database of rpm-ostree systems (Fedora CoreOS, Silverblue, RHEL for Edge)
+	"/usr/share/rpm",
+}
+
+// the database files, the formats newer than Berkeley DB first: a conversion can leave the
+// Packages file it was made from behind
+var dbFiles = []struct {
+	name    string
+	backend Backend
+}{
+	{"rpmdb.sqlite", BackendSQLite},
+	{"Packages.db", BackendNDB},
+	{"Packages", BackendBDB},
+}
+
+// DefaultPaths returns the rpm databases of a root filesystem, "/" for the host, in the order they
+// should be tried. A database reached through several of the directories, symlinked to each
+// other, is returned once under the first one.
+func DefaultPaths(root string) []Candidate {
+	var candidates []Candidate
+	seen := make(map[string]bool)
+	for _, dir := range dbDirs {
+		for _, file := range dbFiles {
+			dbPath := path.Join(dir, file.name)
+			host, err := resolveInRoot(root, dbPath, true)
+			if err != nil || seen[host] {
+				continue
+			}
+			if info, err := os.Stat(host); err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			seen[host] = true
+			candidates = append(candidates, Candidate{Path: dbPath, HostPath: host, Backend: file.backend})
+		}
+	}
+	return candidates
+}
@@ -0,0 +1,481 @@
+package rpmdb
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// OpenPGP packet tags and signature subpacket types
+// ref. https://tools.ietf.org/html/rfc4880#section-4.3
+const (
+	pgpTagSignature    = 2
+	pgpTagPublicKey    = 6
+	pgpTagPublicSubkey = 14
+
+	pgpSubpacketIssuer            = 16
+	pgpSubpacketIssuerFingerprint = 33
+)
+
+// pgpPacket splits the first OpenPGP packet of data into its tag and body.
+// ref. https://tools.ietf.org/html/rfc4880#section-4.2
+func pgpPacket(data []byte) (tag byte, body []byte, err error) {
+	tag, body, _, err = pgpNextPacket(data)
+	return tag, body, err
+}
+
+// pgpNextPacket is pgpPacket also returning the packets following the first one.
+func pgpNextPacket(data []byte) (tag byte, body, rest []byte, err error) {
+	if len(data) < 2 || data[0]&0x80 == 0 {
+		return 0, nil, nil, errors.New("invalid OpenPGP packet header")
+	}
+
+	var length, offset int
+	if data[0]&0x40 == 0 {
+		// old format
+		tag = (data[0] >> 2) & 0x0f
+		switch data[0] & 0x03 {
+		case 0:
+			length, offset = int(data[1]), 2
+		case 1:
+			if len(data) < 3 {
+				return 0, nil, nil, errors.New("short OpenPGP packet header")
+			}
+			length, offset = int(binary.BigEndian.Uint16(data[1:])), 3
+		case 2:
+			if len(data) < 5 {
+				return 0, nil, nil, errors.New("short OpenPGP packet header")
+			}
+			length, offset = int(binary.BigEndian.Uint32(data[1:])), 5
+		default:
+			length, offset = len(data)-1, 1
+		}
+	} else {
+		// new format
+		tag = data[0] & 0x3f
+		length, offset, err = pgpLength(data[1:])
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		offset++
+	}
+
+	if length < 0 || offset+length > len(data) {
+		return 0, nil, nil, fmt.Errorf("truncated OpenPGP packet: %d > %d", offset+length, len(data))
+	}
+	return tag, data[offset : offset+length], data[offset+length:], nil
+}
+
+// pgpLength decodes a new format packet or subpacket length, returning the length and the number of
+// bytes it was encoded on.
+func pgpLength(data []byte) (int, int, error) {
+	if len(data) < 1 {
+		return 0, 0, errors.New("missing OpenPGP length")
+	}
+	switch {
+	case data[0] < 192:
+		return int(data[0]), 1, nil
+	case data[0] < 255:
+		if len(data) < 2 {
+			return 0, 0, errors.New("short OpenPGP length")
+		}
+		return (int(data[0])-192)<<8 + int(data[1]) + 192, 2, nil
+	default:
+		if len(data) < 5 {
+			return 0, 0, errors.New("short OpenPGP length")
+		}
+		return int(binary.BigEndian.Uint32(data[1:])), 5, nil
+	}
+}
+
+// pgpSignatureKeyID returns the issuer key ID of an OpenPGP signature packet as 16 lowercase hex digits.
+// ref. https://tools.ietf.org/html/rfc4880#section-5.2
+func pgpSignatureKeyID(data []byte) (string, error) {
+	tag, body, err := pgpPacket(data)
+	if err != nil {
+		return "", err
+	}
+	if tag != pgpTagSignature {
+		return "", fmt.Errorf("not a signature packet: %d", tag)
+	}
+	if len(body) < 1 {
+		return "", errors.New("empty signature packet")
+	}
+
+	switch body[0] {
+	case 3:
+		// version, hashed length (5), type, creation time (4), key ID (8), ...
+		if len(body) < 15 {
+			return "", errors.New("short v3 signature packet")
+		}
+		return hex.EncodeToString(body[7:15]), nil
+	case 4:
+		// version, type, public key algorithm, hash algorithm, hashed subpackets, unhashed subpackets
+		if len(body) < 6 {
+			return "", errors.New("short v4 signature packet")
+		}
+		hashedLen := int(binary.BigEndian.Uint16(body[4:]))
+		if 6+hashedLen+2 > len(body) {
+			return "", errors.New("truncated v4 signature subpackets")
+		}
+		hashed := body[6 : 6+hashedLen]
+		unhashedLen := int(binary.BigEndian.Uint16(body[6+hashedLen:]))
+		unhashedStart := 6 + hashedLen + 2
+		if unhashedStart+unhashedLen > len(body) {
+			return "", errors.New("truncated v4 signature subpackets")
+		}
+		unhashed := body[unhashedStart : unhashedStart+unhashedLen]
+
+		for _, subpackets := range [][]byte{hashed, unhashed} {
+			if keyID, ok := pgpIssuer(subpackets); ok {
+				return keyID, nil
+			}
+		}
+		return "", errors.New("signature without issuer")
+	}
+	return "", fmt.Errorf("unsupported signature version: %d", body[0])
+}
+
+func pgpIssuer(subpackets []byte) (string, bool) {
+	for len(subpackets) > 0 {
+		length, n, err := pgpLength(subpackets)
+		if err != nil || length < 1 || n+length > len(subpackets) {
+			return "", false
+		}
+		subpacket := subpackets[n : n+length]
+		subpackets = subpackets[n+length:]
+
+		switch subpacket[0] & 0x7f {
+		case pgpSubpacketIssuer:
+			if len(subpacket) == 9 {
+				return hex.EncodeToString(subpacket[1:]), true
+			}
+		case pgpSubpacketIssuerFingerprint:
+			// version followed by the fingerprint, the key ID being its low 64 bits for v4 keys
+			if len(subpacket) >= 10 {
+				return hex.EncodeToString(subpacket[len(subpacket)-8:]), true
+			}
+		}
+	}
+	return "", false
+}
+
+// OpenPGP public key algorithms
+// ref. https://tools.ietf.org/html/rfc4880#section-9.1
+const (
+	pgpPubkeyRSA         = 1
+	pgpPubkeyRSASignOnly = 3
+	pgpPubkeyDSA         = 17
+	pgpPubkeyECDSA       = 19
+	pgpPubkeyEdDSA       = 22
+)
+
+// the curves of the ECDSA and EdDSA keys by OID
+// ref. https://tools.ietf.org/html/rfc6637#section-11
+var (
+	pgpOIDEd25519 = []byte{0x2b, 0x06, 0x01, 0x04, 0x01, 0xda, 0x47, 0x0f, 0x01}
+	pgpCurves     = map[string]elliptic.Curve{
+		"\x2a\x86\x48\xce\x3d\x03\x01\x07": elliptic.P256(),
+		"\x2b\x81\x04\x00\x22":             elliptic.P384(),
+		"\x2b\x81\x04\x00\x23":             elliptic.P521(),
+	}
+)
+
+// the hashes of the digest algorithms having a PKCS #1 encoding
+var pgpCryptoHashes = map[DigestAlgo]crypto.Hash{
+	PGPHASHALGO_MD5:    crypto.MD5,
+	PGPHASHALGO_SHA1:   crypto.SHA1,
+	PGPHASHALGO_SHA224: crypto.SHA224,
+	PGPHASHALGO_SHA256: crypto.SHA256,
+	PGPHASHALGO_SHA384: crypto.SHA384,
+	PGPHASHALGO_SHA512: crypto.SHA512,
+}
+
+// pgpPublicKey is a key or subkey of an OpenPGP certificate.
+type pgpPublicKey struct {
+	// fingerprint is the SHA-1 fingerprint as 40 lowercase hex digits, keyID its low 64 bits.
+	fingerprint string
+	keyID       string
+	algo        byte
+	// key is a *rsa.PublicKey, *dsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey.
+	key crypto.PublicKey
+}
+
+// pgpPublicKeys returns the v4 keys and subkeys of a sequence of OpenPGP packets, a certificate as
+// rpm stores it in the PUBKEYS tag. The keys of unsupported versions or algorithms are skipped.
+// ref. https://tools.ietf.org/html/rfc4880#section-11.1
+func pgpPublicKeys(data []byte) ([]*pgpPublicKey, error) {
+	var keys []*pgpPublicKey
+	for len(data) > 0 {
+		tag, body, rest, err := pgpNextPacket(data)
+		if err != nil {
+			return nil, err
+		}
+		data = rest
+		if tag != pgpTagPublicKey && tag != pgpTagPublicSubkey {
+			continue
+		}
+		key, err := parsePGPPublicKey(body)
+		if err != nil {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// parsePGPPublicKey decodes the body of a v4 public key packet.
+// ref. https://tools.ietf.org/html/rfc4880#section-5.5.2
+func parsePGPPublicKey(body []byte) (*pgpPublicKey, error) {
+	// version, creation time (4), algorithm, algorithm specific fields
+	if len(body) < 6 {
+		return nil, errors.New("short public key packet")
+	}
+	if body[0] != 4 {
+		return nil, fmt.Errorf("unsupported public key version: %d", body[0])
+	}
+	// the fingerprint is the SHA-1 of the packet with an old format header of two length bytes
+	h := sha1.New()
+	h.Write([]byte{0x99, byte(len(body) >> 8), byte(len(body))})
+	h.Write(body)
+	fingerprint := h.Sum(nil)
+
+	key := &pgpPublicKey{fingerprint: hex.EncodeToString(fingerprint), keyID: hex.EncodeToString(fingerprint[12:]), algo: body[5]}
+	fields := body[6:]
+	switch key.algo {
+	case pgpPubkeyRSA, pgpPubkeyRSASignOnly:
+		mpis, err := pgpMPIs(fields, 2)
+		if err != nil {
+			return nil, err
+		}
+		e := new(big.Int).SetBytes(mpis[1])
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		key.key = &rsa.PublicKey{N: new(big.Int).SetBytes(mpis[0]), E: int(e.Int64())}
+	case pgpPubkeyDSA:
+		mpis, err := pgpMPIs(fields, 4)
+		if err != nil {
+			return nil, err
+		}
+		key.key = &dsa.PublicKey{
+			Parameters: dsa.Parameters{
+				P: new(big.Int).SetBytes(mpis[0]),
+				Q: new(big.Int).SetBytes(mpis[1]),
+				G: new(big.Int).SetBytes(mpis[2]),
+			},
+			Y: new(big.Int).SetBytes(mpis[3]),
+		}
+	case pgpPubkeyECDSA, pgpPubkeyEdDSA:
+		if len(fields) < 1 || 1+int(fields[0]) > len(fields) {
+			return nil, errors.New("truncated curve OID")
+		}
+		oid := fields[1 : 1+fields[0]]
+		mpis, err := pgpMPIs(fields[1+fields[0]:], 1)
+		if err != nil {
+			return nil, err
+		}
+		point := mpis[0]
+		if key.algo == pgpPubkeyEdDSA {
+			// a native point, prefixed with 0x40
+			if !bytes.Equal(oid, pgpOIDEd25519) || len(point) != 1+ed25519.PublicKeySize || point[0] != 0x40 {
+				return nil, errors.New("unsupported EdDSA key")
+			}
+			key.key = ed25519.PublicKey(point[1:])
+			break
+		}
+		curve, ok := pgpCurves[string(oid)]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve: %x", oid)
+		}
+		x, y := elliptic.Unmarshal(curve, point)
+		if x == nil {
+			return nil, errors.New("invalid ECDSA point")
+		}
+		key.key = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+	default:
+		return nil, fmt.Errorf("unsupported public key algorithm: %d", key.algo)
+	}
+	return key, nil
+}
+
+// pgpMPIs decodes n multiprecision integers, big-endian numbers prefixed with their bit count.
+// ref. https://tools.ietf.org/html/rfc4880#section-3.2
+func pgpMPIs(data []byte, n int) ([][]byte, error) {
+	mpis := make([][]byte, n)
+	for i := range mpis {
+		if len(data) < 2 {
+			return nil, errors.New("truncated MPI")
+		}
+		length := (int(binary.BigEndian.Uint16(data)) + 7) / 8
+		if 2+length > len(data) {
+			return nil, errors.New("truncated MPI")
+		}
+		mpis[i] = data[2 : 2+length]
+		data = data[2+length:]
+	}
+	return mpis, nil
+}
+
+// pgpSignature is a decoded v3 or v4 signature packet.
+type pgpSignature struct {
+	keyID    string
+	pubAlgo  byte
+	hashAlgo DigestAlgo
+	// suffix is hashed after the signed data
+	suffix []byte
+	// the high 16 bits of the digest, the algorithm specific values
+	left16 []byte
+	values []byte
+}
+
+// parsePGPSignature decodes a signature packet as the signature tags of the headers hold it.
+// ref. https://tools.ietf.org/html/rfc4880#section-5.2
+func parsePGPSignature(data []byte) (*pgpSignature, error) {
+	tag, body, err := pgpPacket(data)
+	if err != nil {
+		return nil, err
+	}
+	if tag != pgpTagSignature {
+		return nil, fmt.Errorf("not a signature packet: %d", tag)
+	}
+	if len(body) < 1 {
+		return nil, errors.New("empty signature packet")
+	}
+
+	sig := &pgpSignature{}
+	switch body[0] {
+	case 3:
+		// version, hashed length (5), type and creation time, key ID (8), public key algorithm,
+		// hash algorithm, left 16 bits, values
+		if len(body) < 19 || body[1] != 5 {
+			return nil, errors.New("invalid v3 signature packet")
+		}
+		sig.keyID = hex.EncodeToString(body[7:15])
+		sig.pubAlgo, sig.hashAlgo = body[15], DigestAlgo(body[16])
+		sig.suffix = body[2:7]
+		sig.left16, sig.values = body[17:19], body[19:]
+	case 4:
+		keyID, err := pgpSignatureKeyID(data)
+		if err != nil {
+			return nil, err
+		}
+		// the bounds of the subpackets were checked by pgpSignatureKeyID
+		hashedLen := int(binary.BigEndian.Uint16(body[4:]))
+		unhashedLen := int(binary.BigEndian.Uint16(body[6+hashedLen:]))
+		end := 6 + hashedLen + 2 + unhashedLen
+		if end+2 > len(body) {
+			return nil, errors.New("truncated v4 signature packet")
+		}
+		sig.keyID = keyID
+		sig.pubAlgo, sig.hashAlgo = body[2], DigestAlgo(body[3])
+		// the hashed part of the packet followed by a trailer of the version, 0xff and its length
+		sig.suffix = append(append([]byte{}, body[:6+hashedLen]...), 4, 0xff, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(sig.suffix[len(sig.suffix)-4:], uint32(6+hashedLen))
+		sig.left16, sig.values = body[end:end+2], body[end+2:]
+	default:
+		return nil, fmt.Errorf("unsupported signature version: %d", body[0])
+	}
+	return sig, nil
+}
+
+// digest returns the digest of the signed data the signature is computed on.
+func (s *pgpSignature) digest(data ...[]byte) ([]byte, error) {
+	h, err := NewDigest(s.hashAlgo)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range data {
+		h.Write(d)
+	}
+	h.Write(s.suffix)
+	return h.Sum(nil), nil
+}
+
+// verify checks the signature of a digest computed by digest with the issuer key.
+func (s *pgpSignature) verify(key *pgpPublicKey, digest []byte) error {
+	if key.algo != s.pubAlgo && !(isPGPRSA(key.algo) && isPGPRSA(s.pubAlgo)) {
+		return fmt.Errorf("signature algorithm %d of a key of algorithm %d", s.pubAlgo, key.algo)
+	}
+	if len(digest) < 2 || !bytes.Equal(digest[:2], s.left16) {
+		return errors.New("digest mismatch")
+	}
+
+	switch pub := key.key.(type) {
+	case *rsa.PublicKey:
+		mpis, err := pgpMPIs(s.values, 1)
+		if err != nil {
+			return err
+		}
+		hash, ok := pgpCryptoHashes[s.hashAlgo]
+		if !ok {
+			return fmt.Errorf("%w: %v", ErrUnsupportedDigestAlgo, s.hashAlgo)
+		}
+		// the MPI drops the leading zeros the PKCS #1 signature has
+		sig := mpis[0]
+		if len(sig) < pub.Size() {
+			sig = append(make([]byte, pub.Size()-len(sig)), sig...)
+		}
+		return rsa.VerifyPKCS1v15(pub, hash, digest, sig)
+	case *dsa.PublicKey:
+		r, sv, err := pgpSignaturePair(s.values)
+		if err != nil {
+			return err
+		}
+		// the digest is truncated to the size of q
+		if n := (pub.Q.BitLen() + 7) / 8; len(digest) > n {
+			digest = digest[:n]
+		}
+		if !dsa.Verify(pub, digest, r, sv) {
+			return errors.New("invalid DSA signature")
+		}
+	case *ecdsa.PublicKey:
+		r, sv, err := pgpSignaturePair(s.values)
+		if err != nil {
+			return err
+		}
+		if !ecdsa.Verify(pub, digest, r, sv) {
+			return errors.New("invalid ECDSA signature")
+		}
+	case ed25519.PublicKey:
+		mpis, err := pgpMPIs(s.values, 2)
+		if err != nil {
+			return err
+		}
+		if len(mpis[0]) > 32 || len(mpis[1]) > 32 {
+			return errors.New("invalid EdDSA signature")
+		}
+		sig := make([]byte, ed25519.SignatureSize)
+		copy(sig[32-len(mpis[0]):], mpis[0])
+		copy(sig[64-len(mpis[1]):], mpis[1])
+		if !ed25519.Verify(pub, digest, sig) {
+			return errors.New("invalid EdDSA signature")
+		}
+	default:
+		return fmt.Errorf("unsupported public key algorithm: %d", key.algo)
+	}
+	return nil
+}
+
+func isPGPRSA(algo byte) bool {
+	return algo == pgpPubkeyRSA || algo == pgpPubkeyRSASignOnly
+}
+
+// pgpSignaturePair decodes the r and s values of DSA and ECDSA signatures.
+func pgpSignaturePair(values []byte) (r, s *big.Int, err error) {
+	mpis, err := pgpMPIs(values, 2)
+	if err != nil {
+		return nil, nil, err
+	}
+	return new(big.Int).SetBytes(mpis[0]), new(big.Int).SetBytes(mpis[1]), nil
+}
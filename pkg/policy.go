@@ -0,0 +1,118 @@
+package rpmdb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Violation is a breach of a policy by an inventory, by one of its packages most of the time.
+type Violation struct {
+	Policy string `json:"policy"`
+	// Package is the NEVRA of the package at fault, empty for a rule on the whole inventory.
+	Package string `json:"package,omitempty"`
+	Message string `json:"message"`
+}
+
+// PolicyInput is the inventory policies evaluate. Its JSON, {"packages": [...]} with the fields of
+// PackageInfo, is meant as the input document of a rego policy: `input.packages[_].License`.
+type PolicyInput struct {
+	Packages []*PackageInfo `json:"packages"`
+}
+
+// Policy is a rule over an inventory. A rego policy evaluated by OPA is a PolicyFunc turning the
+// results of its deny rule into violations.
+type Policy interface {
+	Evaluate(ctx context.Context, input *PolicyInput) ([]Violation, error)
+}
+
+// PolicyFunc is a function implementing Policy.
+type PolicyFunc func(ctx context.Context, input *PolicyInput) ([]Violation, error)
+
+func (f PolicyFunc) Evaluate(ctx context.Context, input *PolicyInput) ([]Violation, error) {
+	return f(ctx, input)
+}
+
+// PackagePolicy returns the policy name reporting the packages check returns a message for, an
+// empty message meaning the package complies.
+func PackagePolicy(name string, check func(pkg *PackageInfo) string) Policy {
+	return PolicyFunc(func(ctx context.Context, input *PolicyInput) ([]Violation, error) {
+		var violations []Violation
+		for _, pkg := range input.Packages {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			if message := check(pkg); message != "" {
+				violations = append(violations, Violation{Policy: name, Package: pkg.NEVRA(), Message: message})
+			}
+		}
+		return violations, nil
+	})
+}
+
+// ForbiddenLicenses returns the policy name reporting the packages whose License names one of
+// licenses. rpm licenses are expressions, "GPLv2+ and LGPLv2+" or "(MIT OR Apache-2.0)", every
+// license of which is checked.
+func ForbiddenLicenses(name string, licenses ...string) Policy {
+	forbidden := make(map[string]bool, len(licenses))
+	for _, license := range licenses {
+		forbidden[license] = true
+	}
+	return PackagePolicy(name, func(pkg *PackageInfo) string {
+		for _, license := range licenseIDs(pkg.License) {
+			if forbidden[license] {
+				return fmt.Sprintf("forbidden license %s", license)
+			}
+		}
+		return ""
+	})
+}
+
+// AllowedVendors returns the policy name reporting the packages whose Vendor isn't one of vendors,
+// as recorded: "Red Hat, Inc." for instance. Packages without vendor are reported.
+func AllowedVendors(name string, vendors ...string) Policy {
+	return PackagePolicy(name, func(pkg *PackageInfo) string {
+		if containsString(vendors, pkg.Vendor) {
+			return ""
+		}
+		if pkg.Vendor == "" {
+			return "no vendor"
+		}
+		return fmt.Sprintf("vendor %q not allowed", pkg.Vendor)
+	})
+}
+
+// licenseIDs splits a license expression into its licenses, dropping the operators and the
+// exceptions following "with".
+func licenseIDs(expression string) []string {
+	var ids []string
+	fields := strings.Fields(strings.NewReplacer("(", " ", ")", " ").Replace(expression))
+	for i := 0; i < len(fields); i++ {
+		switch strings.ToLower(fields[i]) {
+		case "and", "or":
+		case "with":
+			i++
+		default:
+			ids = append(ids, fields[i])
+		}
+	}
+	return ids
+}
+
+// EvaluatePolicies runs the policies over the packages and returns their violations, in the order
+// of the policies. A policy failing to evaluate stops the evaluation.
+func EvaluatePolicies(ctx context.Context, pkgs []*PackageInfo, policies ...Policy) ([]Violation, error) {
+	input := &PolicyInput{Packages: pkgs}
+	var violations []Violation
+	for i, policy := range policies {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		found, err := policy.Evaluate(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("policy %d: %w", i, err)
+		}
+		violations = append(violations, found...)
+	}
+	return violations, nil
+}
@@ -0,0 +1,67 @@
+package rpmdb
+
+import (
+	"fmt"
+	"sort"
+)
+
+// PrivilegedFile is a file granting privileges when executed.
+type PrivilegedFile struct {
+	File   FileInfo
+	Setuid bool
+	Setgid bool
+	// Caps is the text form of the file capabilities, empty when it has none.
+	Caps string
+}
+
+// PackagePrivileges are the privileged files of a package.
+type PackagePrivileges struct {
+	Package *PackageInfo
+	Files   []PrivilegedFile
+}
+
+// PrivilegedFiles lists the setuid, setgid and file capability binaries of every package, grouped
+// by package and sorted by NEVRA then path, for security baselines. The report is computed from
+// the headers only: a file changed since install is caught by Verify, not here. Setgid
+// directories, which only set the group of new files, are left out.
+func (d *RpmDB) PrivilegedFiles() ([]*PackagePrivileges, error) {
+	var report []*PackagePrivileges
+	err := d.forEachPackage(func(hnum uint32, indexEntries []indexEntry, pkg *PackageInfo) error {
+		files, err := fileInfos(indexEntries)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pkg.NEVRA(), err)
+		}
+
+		var privileged []PrivilegedFile
+		for _, file := range files {
+			if file.Mode&modeTypeMask != modeRegular {
+				continue
+			}
+			p := PrivilegedFile{
+				File:   file,
+				Setuid: file.Mode&modeSetuid != 0,
+				Setgid: file.Mode&modeSetgid != 0,
+				Caps:   file.Caps,
+			}
+			if p.Setuid || p.Setgid || p.Caps != "" {
+				privileged = append(privileged, p)
+			}
+		}
+		if len(privileged) == 0 {
+			return nil
+		}
+		sort.Slice(privileged, func(i, j int) bool {
+			return privileged[i].File.Path < privileged[j].File.Path
+		})
+		report = append(report, &PackagePrivileges{Package: pkg, Files: privileged})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].Package.NEVRA() < report[j].Package.NEVRA()
+	})
+	return report, nil
+}
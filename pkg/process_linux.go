@@ -0,0 +1,138 @@
+package rpmdb
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ProcessPackages is what a running process was started from: its executable and the shared
+// libraries it mapped, with the packages owning them.
+type ProcessPackages struct {
+	PID int
+	// Exe is the executable, where /proc/<pid>/exe links to.
+	Exe string
+	// Libraries are the shared objects of /proc/<pid>/maps, sorted.
+	Libraries []string
+	// Owners are the packages owning Exe and each of the Libraries, the files no package owns being
+	// left out: built from source, or replaced on disk since the process started, in which case
+	// /proc links the file "(deleted)".
+	Owners map[string][]*PackageInfo
+	// Err is why the files of the process couldn't be read, the other processes being attributed
+	// regardless. Reading the executable of the processes of other users requires privileges.
+	Err error
+}
+
+// deletedSuffix is appended by the kernel to the path of the files unlinked since they were opened
+const deletedSuffix = " (deleted)"
+
+// ProcessPackages attributes the processes pids to the packages of the database, every process of
+// procDir when pids is empty. procDir is the mount point of procfs, /proc on the host. The paths
+// are the ones in the mount namespace of procDir: the processes of containers are attributed with
+// the database of their image, not the one of the host.
+func (d *RpmDB) ProcessPackages(procDir string, pids ...int) ([]*ProcessPackages, error) {
+	if len(pids) == 0 {
+		var err error
+		if pids, err = listPIDs(procDir); err != nil {
+			return nil, err
+		}
+	}
+
+	var paths []string
+	procs := make([]*ProcessPackages, 0, len(pids))
+	for _, pid := range pids {
+		proc := readProcess(procDir, pid)
+		procs = append(procs, proc)
+		if proc.Exe != "" {
+			paths = append(paths, proc.Exe)
+		}
+		paths = append(paths, proc.Libraries...)
+	}
+
+	owners, err := d.WhoOwnsAll(paths, &WhoOwnsOptions{Canonicalize: true})
+	if err != nil {
+		return nil, err
+	}
+	for _, proc := range procs {
+		proc.Owners = make(map[string][]*PackageInfo)
+		for _, file := range append([]string{proc.Exe}, proc.Libraries...) {
+			if pkgs, ok := owners[file]; ok {
+				proc.Owners[file] = pkgs
+			}
+		}
+	}
+	return procs, nil
+}
+
+func listPIDs(procDir string) ([]int, error) {
+	entries, err := ioutil.ReadDir(procDir)
+	if err != nil {
+		return nil, err
+	}
+	var pids []int
+	for _, entry := range entries {
+		if pid, err := strconv.Atoi(entry.Name()); err == nil && entry.IsDir() {
+			pids = append(pids, pid)
+		}
+	}
+	sort.Ints(pids)
+	return pids, nil
+}
+
+func readProcess(procDir string, pid int) *ProcessPackages {
+	proc := &ProcessPackages{PID: pid}
+	dir := filepath.Join(procDir, strconv.Itoa(pid))
+
+	// kernel threads have no executable
+	exe, err := os.Readlink(filepath.Join(dir, "exe"))
+	if err != nil && !os.IsNotExist(err) {
+		proc.Err = err
+		return proc
+	}
+	if strings.HasPrefix(exe, "/") {
+		proc.Exe = strings.TrimSuffix(exe, deletedSuffix)
+	}
+
+	proc.Libraries, err = mappedLibraries(filepath.Join(dir, "maps"))
+	if err != nil && !os.IsNotExist(err) {
+		proc.Err = err
+	}
+	return proc
+}
+
+// mappedLibraries returns the shared objects of a maps file, mapped once per segment.
+// ref. https://man7.org/linux/man-pages/man5/proc.5.html
+func mappedLibraries(maps string) ([]string, error) {
+	f, err := os.Open(maps)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	seen := make(map[string]bool)
+	var libraries []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// address perms offset dev inode pathname, the pathname possibly having spaces
+		fields := strings.SplitN(scanner.Text(), " ", 6)
+		if len(fields) < 6 {
+			continue
+		}
+		file := strings.TrimSuffix(strings.TrimLeft(fields[5], " "), deletedSuffix)
+		if !strings.HasPrefix(file, "/") || !strings.Contains(filepath.Base(file), ".so") || seen[file] {
+			continue
+		}
+		seen[file] = true
+		libraries = append(libraries, file)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", maps, err)
+	}
+	sort.Strings(libraries)
+	return libraries, nil
+}
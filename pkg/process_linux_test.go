@@ -0,0 +1,74 @@
+package rpmdb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestProcessPackages(t *testing.T) {
+	procDir, err := ioutil.TempDir("", "rpmdb-proc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(procDir)
+
+	maps := `00400000-004dd000 r-xp 00000000 fd:00 123 /usr/bin/bash
+7f0000000000-7f00001c4000 r-xp 00000000 fd:00 456 /usr/lib64/libc-2.17.so
+7f00001c4000-7f00003c4000 ---p 001c4000 fd:00 456 /usr/lib64/libc-2.17.so
+7f00003c4000-7f00003c8000 r--p 001c4000 fd:00 789 /usr/lib64/libfoo.so.1 (deleted)
+7f0000400000-7f0000402000 rw-p 00000000 00:00 0 
+7f0000500000-7f0000600000 r--p 00000000 fd:00 1011 /usr/lib/locale/locale-archive
+7ffc00000000-7ffc00021000 rw-p 00000000 00:00 0                          [stack]
+`
+	for _, dir := range []string{"100", "200", "self"} {
+		if err := os.MkdirAll(filepath.Join(procDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("/bin/bash", filepath.Join(procDir, "100", "exe")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(procDir, "100", "maps"), []byte(maps), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// a kernel thread
+	if err := ioutil.WriteFile(filepath.Join(procDir, "200", "maps"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+	procs, err := db.ProcessPackages(procDir)
+	if err != nil {
+		t.Fatalf("ProcessPackages() error: %v", err)
+	}
+	if len(procs) != 2 || procs[0].PID != 100 || procs[1].PID != 200 {
+		t.Fatalf("ProcessPackages() got %+v", procs)
+	}
+
+	bash := procs[0]
+	if bash.Err != nil || bash.Exe != "/bin/bash" {
+		t.Errorf("bash: got %s, %v", bash.Exe, bash.Err)
+	}
+	if want := []string{"/usr/lib64/libc-2.17.so", "/usr/lib64/libfoo.so.1"}; !reflect.DeepEqual(bash.Libraries, want) {
+		t.Errorf("libraries: got %v, want %v", bash.Libraries, want)
+	}
+	owners := make(map[string]string)
+	for file, pkgs := range bash.Owners {
+		owners[file] = pkgs[0].NEVRA()
+	}
+	want := map[string]string{"/bin/bash": "bash-4.2.46-30.el7.x86_64", "/usr/lib64/libc-2.17.so": "glibc-2.17-222.el7.x86_64"}
+	if !reflect.DeepEqual(owners, want) {
+		t.Errorf("owners: got %v, want %v", owners, want)
+	}
+
+	if kthread := procs[1]; kthread.Err != nil || kthread.Exe != "" || len(kthread.Owners) != 0 {
+		t.Errorf("kernel thread: got %+v", kthread)
+	}
+}
@@ -0,0 +1,164 @@
+package rpmdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// TagProfile is the space a tag takes in the headers of a database.
+type TagProfile struct {
+	Tag TAG_ID
+	// Headers is the number of headers having the tag.
+	Headers int
+	// Bytes is the size of its index entries and data, the padding aligning the data of the next
+	// entry included.
+	Bytes int64
+}
+
+// HeaderSize is the size of the header of a package.
+type HeaderSize struct {
+	Package *PackageInfo
+	Bytes   int
+}
+
+// HeaderProfile is where the bytes of the headers of a database go.
+type HeaderProfile struct {
+	Headers int
+	// Bytes is the size of every header blob.
+	Bytes int64
+	// FileBytes is the size of the file tags WithoutFiles leaves out, FILEDIGESTS among them,
+	// ChangelogBytes the one of CHANGELOGTIME, CHANGELOGNAME and CHANGELOGTEXT, OtherBytes the
+	// rest, the lengths heading each blob included. They add up to Bytes.
+	FileBytes      int64
+	ChangelogBytes int64
+	OtherBytes     int64
+	// Tags are sorted by decreasing size.
+	Tags []*TagProfile
+	// Largest are the largest headers, largest first.
+	Largest []*HeaderSize
+}
+
+// ProfileHeaders reports which tags take the most space in the headers of the database, and the
+// largest headers, at most largest of them: what a bloated database is made of, and what
+// WithoutFiles and WithoutChangelogs save on the listings. The headers are measured as stored, the entries a dribble
+// replaced included.
+func (d *RpmDB) ProfileHeaders(largest int) (*HeaderProfile, error) {
+	profile := &HeaderProfile{}
+	tags := make(map[TAG_ID]*TagProfile)
+
+	err := d.forEachHeader(func(hnum uint32, blob []byte) error {
+		sizes, err := entrySizes(blob)
+		if err != nil {
+			return fmt.Errorf("invalid header %d: %w", hnum, err)
+		}
+		profile.Headers++
+		profile.Bytes += int64(len(blob))
+		profile.OtherBytes += int64(len(blob))
+
+		seen := make(map[TAG_ID]bool, len(sizes))
+		for _, size := range sizes {
+			tag := tags[size.tag]
+			if tag == nil {
+				tag = &TagProfile{Tag: size.tag}
+				tags[size.tag] = tag
+			}
+			if !seen[size.tag] {
+				tag.Headers++
+				seen[size.tag] = true
+			}
+			tag.Bytes += int64(size.bytes)
+
+			switch {
+			case fileTags[size.tag]:
+				profile.FileBytes += int64(size.bytes)
+				profile.OtherBytes -= int64(size.bytes)
+			case changelogTags[size.tag]:
+				profile.ChangelogBytes += int64(size.bytes)
+				profile.OtherBytes -= int64(size.bytes)
+			}
+		}
+
+		if largest <= 0 || (len(profile.Largest) == largest && len(blob) <= profile.Largest[largest-1].Bytes) {
+			return nil
+		}
+		indexEntries, err := headerImport(blob)
+		if err != nil {
+			return fmt.Errorf("error during importing header: %w", err)
+		}
+		pkg, err := getNEVRA(indexEntries)
+		if err != nil {
+			return fmt.Errorf("invalid package info: %w", err)
+		}
+		// kept sorted, the first of equal sizes staying ahead
+		i := sort.Search(len(profile.Largest), func(i int) bool {
+			return profile.Largest[i].Bytes < len(blob)
+		})
+		if len(profile.Largest) < largest {
+			profile.Largest = append(profile.Largest, nil)
+		}
+		copy(profile.Largest[i+1:], profile.Largest[i:])
+		profile.Largest[i] = &HeaderSize{Package: pkg, Bytes: len(blob)}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tag := range tags {
+		profile.Tags = append(profile.Tags, tag)
+	}
+	sort.Slice(profile.Tags, func(i, j int) bool {
+		a, b := profile.Tags[i], profile.Tags[j]
+		if a.Bytes != b.Bytes {
+			return a.Bytes > b.Bytes
+		}
+		return a.Tag < b.Tag
+	})
+	return profile, nil
+}
+
+type entrySize struct {
+	tag   TAG_ID
+	bytes int
+}
+
+// entrySizes returns the size of every entry of a header blob: its index entry and its data up to
+// the data of the next entry in offset order. The region entries get the 16 bytes of their
+// trailer this way.
+func entrySizes(blob []byte) ([]entrySize, error) {
+	if len(blob) < 8 {
+		return nil, fmt.Errorf("header too short: %d bytes", len(blob))
+	}
+	il := int(int32(binary.BigEndian.Uint32(blob[0:])))
+	dl := int(int32(binary.BigEndian.Uint32(blob[4:])))
+	if il < 1 || dl < 0 || il > (len(blob)-8)/16 || 8+il*16+dl > len(blob) {
+		return nil, fmt.Errorf("invalid header lengths: il=%d dl=%d for %d bytes", il, dl, len(blob))
+	}
+
+	type entry struct {
+		tag    TAG_ID
+		offset int
+	}
+	entries := make([]entry, il)
+	for i := range entries {
+		e := blob[8+i*16:]
+		entries[i] = entry{tag: TAG_ID(binary.BigEndian.Uint32(e)), offset: int(int32(binary.BigEndian.Uint32(e[8:])))}
+		if entries[i].offset < 0 || entries[i].offset > dl {
+			return nil, fmt.Errorf("invalid data offset %d (tag %v)", entries[i].offset, entries[i].tag)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].offset < entries[j].offset
+	})
+
+	sizes := make([]entrySize, il)
+	for i, e := range entries {
+		end := dl
+		if i+1 < len(entries) {
+			end = entries[i+1].offset
+		}
+		sizes[i] = entrySize{tag: e.tag, bytes: 16 + end - e.offset}
+	}
+	return sizes, nil
+}
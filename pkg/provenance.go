@@ -0,0 +1,133 @@
+package rpmdb
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Provenance is where and how a package was built, as recorded by rpmbuild.
+type Provenance struct {
+	Package   *PackageInfo
+	BuildHost string
+	BuildTime time.Time
+	// RPMVersion is the version of the rpmbuild that built the package.
+	RPMVersion string
+	// OptFlags are the compiler flags of the %optflags macro at build time.
+	OptFlags string
+	// Platform is the build target, "x86_64-redhat-linux-gnu" for instance.
+	Platform string
+	// VCS is the %{vcs} of the spec, the repository the sources come from, rarely set.
+	VCS string
+}
+
+// ProvenancePolicy is what a provenance report expects of the packages.
+type ProvenancePolicy struct {
+	// BuildHosts are path.Match patterns of the expected build hosts, "*.bsys.centos.org" for
+	// instance. Any host is accepted when empty.
+	BuildHosts []string
+	// OptFlags are the compiler flags the packages must have been built with,
+	// "-fstack-protector-strong" or "-Wp,-D_FORTIFY_SOURCE=2" for instance. noarch packages
+	// don't compile anything and are exempt.
+	OptFlags []string
+}
+
+// ProvenanceIssue is a package breaking a provenance policy.
+type ProvenanceIssue struct {
+	Provenance *Provenance
+	// UnexpectedHost is set when the build host matches none of the policy.
+	UnexpectedHost bool
+	// MissingOptFlags are the flags of the policy the package was built without.
+	MissingOptFlags []string
+}
+
+// Provenance lists the build provenance of every package in database order. The gpg-pubkey
+// entries of imported keys, which are not built, are left out.
+func (d *RpmDB) Provenance() ([]*Provenance, error) {
+	var provenances []*Provenance
+	err := d.forEachPackage(func(hnum uint32, indexEntries []indexEntry, pkg *PackageInfo) error {
+		if pkg.Name == "gpg-pubkey" {
+			return nil
+		}
+		p, err := packageProvenance(indexEntries, pkg)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pkg.NEVRA(), err)
+		}
+		provenances = append(provenances, p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return provenances, nil
+}
+
+func packageProvenance(indexEntries []indexEntry, pkg *PackageInfo) (*Provenance, error) {
+	p := &Provenance{Package: pkg}
+	stringTags := []struct {
+		tag   TAG_ID
+		value *string
+	}{
+		{RPMTAG_BUILDHOST, &p.BuildHost},
+		{RPMTAG_RPMVERSION, &p.RPMVersion},
+		{RPMTAG_OPTFLAGS, &p.OptFlags},
+		{RPMTAG_PLATFORM, &p.Platform},
+		{RPMTAG_VCS, &p.VCS},
+	}
+	for _, t := range stringTags {
+		values, err := tagStrings(indexEntries, t.tag)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag %v: %w", t.tag, err)
+		}
+		if len(values) > 0 {
+			*t.value = normalizeString(values[0])
+		}
+	}
+
+	p.BuildTime = pkg.BuildTime
+	return p, nil
+}
+
+// ProvenanceReport lists the packages built on unexpected hosts or without the expected
+// hardening flags, sorted by NEVRA.
+func (d *RpmDB) ProvenanceReport(policy *ProvenancePolicy) ([]*ProvenanceIssue, error) {
+	provenances, err := d.Provenance()
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []*ProvenanceIssue
+	for _, p := range provenances {
+		issue := &ProvenanceIssue{Provenance: p}
+		if len(policy.BuildHosts) > 0 && !matchHost(policy.BuildHosts, p.BuildHost) {
+			issue.UnexpectedHost = true
+		}
+		if p.Package.Arch != "noarch" {
+			flags := strings.Fields(p.OptFlags)
+			for _, flag := range policy.OptFlags {
+				if !containsString(flags, flag) {
+					issue.MissingOptFlags = append(issue.MissingOptFlags, flag)
+				}
+			}
+		}
+		if issue.UnexpectedHost || len(issue.MissingOptFlags) > 0 {
+			issues = append(issues, issue)
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Provenance.Package.NEVRA() < issues[j].Provenance.Package.NEVRA()
+	})
+	return issues, nil
+}
+
+func matchHost(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, host); ok {
+			return true
+		}
+	}
+	return false
+}
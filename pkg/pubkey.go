@@ -0,0 +1,67 @@
+package rpmdb
+
+import (
+	"strings"
+)
+
+// the header tags holding the package signature, in order of preference
+var signatureTags = []TAG_ID{
+	RPMTAG_RSAHEADER,
+	RPMTAG_DSAHEADER,
+	RPMTAG_SIGPGP,
+	RPMTAG_SIGGPG,
+}
+
+// PubKeyRef identifies the key a package is signed with.
+type PubKeyRef struct {
+	// KeyID is the issuer key ID of the signature (16 lowercase hex digits), empty when the
+	// signature can't be decoded.
+	KeyID string `json:"key_id,omitempty" yaml:"key_id,omitempty" msgpack:"key_id,omitempty"`
+	// PubKey is the gpg-pubkey package of the key, nil when the key isn't imported in the database.
+	PubKey *PackageInfo `json:"pub_key,omitempty" yaml:"pub_key,omitempty" msgpack:"pub_key,omitempty"`
+}
+
+// Imported reports whether the signing key is present in the database.
+func (r *PubKeyRef) Imported() bool {
+	return r.PubKey != nil
+}
+
+// signatureKeyID returns the issuer key ID of the package signature. ok is false for unsigned packages.
+func signatureKeyID(indexEntries []indexEntry) (keyID string, ok bool) {
+	for _, tag := range signatureTags {
+		entry := findEntry(indexEntries, tag)
+		if entry == nil || entry.Info.Type != RPM_BIN_TYPE {
+			continue
+		}
+		data := entry.Data
+		if int(entry.Info.Count) < len(data) {
+			data = data[:entry.Info.Count]
+		}
+		keyID, _ := pgpSignatureKeyID(data)
+		return keyID, true
+	}
+	return "", false
+}
+
+// attributeSignatures sets SignedBy on every signed package, keyIDs holding the signature key ID of
+// each package ("" when unsigned or undecodable, signed telling them apart). gpg-pubkey packages are
+// named after the low 32 bits of the key ID (their version).
+func attributeSignatures(pkgList []*PackageInfo, keyIDs []string, signed []bool) {
+	pubKeys := make(map[string]*PackageInfo)
+	for _, pkg := range pkgList {
+		if pkg.Name == "gpg-pubkey" {
+			pubKeys[strings.ToLower(pkg.Version)] = pkg
+		}
+	}
+
+	for i, pkg := range pkgList {
+		if !signed[i] {
+			continue
+		}
+		ref := &PubKeyRef{KeyID: keyIDs[i]}
+		if len(ref.KeyID) == 16 {
+			ref.PubKey = pubKeys[ref.KeyID[8:]]
+		}
+		pkg.SignedBy = ref
+	}
+}
@@ -0,0 +1,91 @@
+//go:build purity
+// +build purity
+
+package rpmdb
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// The point of this package over librpm bindings is static cross-compilation, these checks keep it
+// that way. Run them with `go test -tags purity ./pkg/`.
+
+// imports no package of the module may pull in
+var impureImports = []string{
+	"C",
+	"syscall",
+	"golang.org/x/sys/unix",
+	"golang.org/x/sys/windows",
+}
+
+type goPackage struct {
+	ImportPath string
+	Module     *struct{ Path string }
+	CgoFiles   []string
+	Imports    []string
+	Standard   bool
+}
+
+func listDeps(t *testing.T, env ...string) []goPackage {
+	cmd := exec.Command("go", "list", "-deps", "-json", "github.com/chennqqi/go-rpmdb/...")
+	cmd.Env = append(os.Environ(), env...)
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("go list error: %v", err)
+	}
+
+	var pkgs []goPackage
+	decoder := json.NewDecoder(strings.NewReader(string(out)))
+	for decoder.More() {
+		var pkg goPackage
+		if err := decoder.Decode(&pkg); err != nil {
+			t.Fatalf("failed to decode go list output: %v", err)
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs
+}
+
+func TestNoCgo(t *testing.T) {
+	for _, pkg := range listDeps(t, "CGO_ENABLED=1") {
+		if pkg.Standard {
+			continue
+		}
+		if len(pkg.CgoFiles) > 0 {
+			t.Errorf("%s uses cgo: %v", pkg.ImportPath, pkg.CgoFiles)
+		}
+	}
+}
+
+func TestNoSyscalls(t *testing.T) {
+	for _, pkg := range listDeps(t) {
+		if pkg.Module == nil || pkg.Module.Path != "github.com/chennqqi/go-rpmdb" {
+			continue
+		}
+		for _, imp := range pkg.Imports {
+			for _, impure := range impureImports {
+				if imp == impure {
+					t.Errorf("%s imports %s", pkg.ImportPath, imp)
+				}
+			}
+		}
+	}
+}
+
+func TestCrossCompile(t *testing.T) {
+	targets := []string{"linux/amd64", "linux/arm64", "linux/s390x", "darwin/arm64", "windows/amd64"}
+	for _, target := range targets {
+		t.Run(target, func(t *testing.T) {
+			parts := strings.Split(target, "/")
+			cmd := exec.Command("go", "build", "github.com/chennqqi/go-rpmdb/...")
+			cmd.Env = append(os.Environ(), "CGO_ENABLED=0", "GOOS="+parts[0], "GOARCH="+parts[1])
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Errorf("build error: %v\n%s", err, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package rpmdb
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// WhatProvides returns the packages providing capability, either explicitly (PROVIDENAME) or, for
+// capabilities starting with "/", by owning the file, like `rpm -q --whatprovides`. The Providename
+// and Basenames index databases are used to narrow down the candidates when they are present.
+func (d *RpmDB) WhatProvides(capability string) ([]*PackageInfo, error) {
+	candidates, indexed, err := d.providerCandidates(capability)
+	if err != nil {
+		return nil, err
+	}
+
+	var pkgList []*PackageInfo
+	err = d.forEachCandidate(candidates, indexed, func(hnum uint32, blob []byte) error {
+		indexEntries, err := headerImport(blob)
+		if err != nil {
+			return fmt.Errorf("error during importing header: %w", err)
+		}
+		ok, err := provides(indexEntries, capability)
+		if err != nil || !ok {
+			return err
+		}
+
+		pkg, err := getNEVRA(indexEntries)
+		if err != nil {
+			return fmt.Errorf("invalid package info: %w", err)
+		}
+		pkgList = append(pkgList, pkg)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pkgList, nil
+}
+
+// providerCandidates returns the header instances the indexes record for capability. indexed is
+// false when the required index databases are missing and every header has to be checked.
+func (d *RpmDB) providerCandidates(capability string) (candidates map[uint32]struct{}, indexed bool, err error) {
+	candidates = make(map[uint32]struct{})
+
+	items, ok, err := d.lookupIndex("Providename", capability)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	for _, item := range items {
+		candidates[item.hdrNum] = struct{}{}
+	}
+
+	if strings.HasPrefix(capability, "/") {
+		items, ok, err := d.lookupIndex("Basenames", path.Base(capability))
+		if err != nil || !ok {
+			return nil, false, err
+		}
+		for _, item := range items {
+			candidates[item.hdrNum] = struct{}{}
+		}
+	}
+
+	return candidates, true, nil
+}
+
+func provides(indexEntries []indexEntry, capability string) (bool, error) {
+	provideNames, err := tagStrings(indexEntries, RPMTAG_PROVIDENAME)
+	if err != nil {
+		return false, fmt.Errorf("invalid tag providename: %w", err)
+	}
+	if containsString(provideNames, capability) {
+		return true, nil
+	}
+
+	if !strings.HasPrefix(capability, "/") {
+		return false, nil
+	}
+
+	files, err := fileNames(indexEntries)
+	if err != nil {
+		return false, err
+	}
+	return containsString(files, capability), nil
+}
+
+// WhatRequires returns the packages requiring capability, like `rpm -q --whatrequires`. The
+// Requirename index database is used to narrow down the candidates when it is present.
+func (d *RpmDB) WhatRequires(capability string) ([]*PackageInfo, error) {
+	items, indexed, err := d.lookupIndex("Requirename", capability)
+	if err != nil {
+		return nil, err
+	}
+	candidates := make(map[uint32]struct{})
+	for _, item := range items {
+		candidates[item.hdrNum] = struct{}{}
+	}
+
+	var pkgList []*PackageInfo
+	err = d.forEachCandidate(candidates, indexed, func(hnum uint32, blob []byte) error {
+		indexEntries, err := headerImport(blob)
+		if err != nil {
+			return fmt.Errorf("error during importing header: %w", err)
+		}
+		requireNames, err := tagStrings(indexEntries, RPMTAG_REQUIRENAME)
+		if err != nil {
+			return fmt.Errorf("invalid tag requirename: %w", err)
+		}
+		if !containsString(requireNames, capability) {
+			return nil
+		}
+
+		pkg, err := getNEVRA(indexEntries)
+		if err != nil {
+			return fmt.Errorf("invalid package info: %w", err)
+		}
+		pkgList = append(pkgList, pkg)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pkgList, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
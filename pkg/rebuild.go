@@ -0,0 +1,138 @@
+package rpmdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+
+	"github.com/chennqqi/go-rpmdb/pkg/bdb"
+	"github.com/chennqqi/go-rpmdb/pkg/ndb"
+)
+
+// RebuildResult summarizes a Rebuild run.
+type RebuildResult struct {
+	Copied  int
+	Skipped int
+}
+
+// rebuildPageSize is the page size of the Berkeley DB databases rebuilt from another backend, the
+// one rpm creates them with.
+const rebuildPageSize = 4096
+
+// Rebuild copies every header of the src database, in any of the formats Open reads, that can still
+// be decoded into a freshly written database at dst, skipping corrupt entries. It is the equivalent
+// of recovering a broken rpmdb with db_dump/db_load followed by rpm --rebuilddb, and converts the
+// database when the backends differ. The backend of dst is the one of its file name: Packages.db
+// is written in the ndb format, any other name as a Berkeley DB hash database. rpmdb.sqlite can't
+// be written.
+func Rebuild(src, dst string) (*RebuildResult, error) {
+	backend := BackendBDB
+	for _, file := range dbFiles {
+		if filepath.Base(dst) == file.name {
+			backend = file.backend
+		}
+	}
+	if backend == BackendSQLite {
+		return nil, fmt.Errorf("%s: the %s backend can't be written", dst, backend)
+	}
+
+	srcDB, err := openStorage(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source database: %w", err)
+	}
+	defer srcDB.Close()
+
+	var w rebuildWriter
+	if backend == BackendNDB {
+		w, err = ndb.Create(dst)
+	} else {
+		pageSize := uint32(rebuildPageSize)
+		if bdbDB, ok := srcDB.(*bdb.BerkeleyDB); ok {
+			pageSize = bdbDB.HashMetadata.PageSize
+		}
+		w, err = createBDBRebuild(dst, pageSize)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create destination database: %w", err)
+	}
+
+	result := &RebuildResult{}
+	entries, stop := (&RpmDB{db: srcDB}).readHeaders()
+	defer stop()
+	for entry := range entries {
+		if entry.Err != nil {
+			// the rest of the source can't be traversed anymore, keep what has been recovered so far
+			result.Skipped++
+			break
+		}
+
+		if isPlaceholder(entry) {
+			continue
+		}
+		if len(entry.Key) != 4 {
+			result.Skipped++
+			continue
+		}
+		if _, err := headerImport(entry.Value); err != nil {
+			result.Skipped++
+			continue
+		}
+
+		if err := w.Put(binary.LittleEndian.Uint32(entry.Key), entry.Value); err != nil {
+			w.Close()
+			return nil, fmt.Errorf("failed to write header: %w", err)
+		}
+		result.Copied++
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write destination database: %w", err)
+	}
+
+	return result, nil
+}
+
+// rebuildWriter is the database Rebuild writes, ndb.Writer or bdbRebuild.
+type rebuildWriter interface {
+	Put(hnum uint32, blob []byte) error
+	Close() error
+}
+
+// bdbRebuild writes the headers of a Berkeley DB Packages file, keyed by their instance number.
+type bdbRebuild struct {
+	w           *bdb.Writer
+	maxInstance uint32
+}
+
+func createBDBRebuild(path string, pageSize uint32) (*bdbRebuild, error) {
+	w, err := bdb.Create(path, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	return &bdbRebuild{w: w}, nil
+}
+
+func (b *bdbRebuild) Put(hnum uint32, blob []byte) error {
+	key := make([]byte, 4)
+	binary.LittleEndian.PutUint32(key, hnum)
+	if err := b.w.Put(key, blob); err != nil {
+		return err
+	}
+	if hnum > b.maxInstance {
+		b.maxInstance = hnum
+	}
+	return nil
+}
+
+// Close writes record 0, which holds the highest header instance number in use: rpm allocates new
+// instances from it.
+func (b *bdbRebuild) Close() error {
+	joinKey := make([]byte, 4)
+	instance := make([]byte, 4)
+	binary.LittleEndian.PutUint32(instance, b.maxInstance)
+	if err := b.w.Put(joinKey, instance); err != nil {
+		b.w.Close()
+		return fmt.Errorf("failed to write instance record: %w", err)
+	}
+	return b.w.Close()
+}
@@ -0,0 +1,109 @@
+package rpmdb
+
+import (
+	"bytes"
+	"regexp"
+)
+
+// the tags WithRedaction drops: the build host and the scriptlet bodies, their interpreters and
+// flags being kept
+var redactedTags = map[TAG_ID]bool{
+	RPMTAG_BUILDHOST:               true,
+	RPMTAG_PREIN:                   true,
+	RPMTAG_POSTIN:                  true,
+	RPMTAG_PREUN:                   true,
+	RPMTAG_POSTUN:                  true,
+	RPMTAG_PRETRANS:                true,
+	RPMTAG_POSTTRANS:               true,
+	RPMTAG_PREUNTRANS:              true,
+	RPMTAG_POSTUNTRANS:             true,
+	RPMTAG_VERIFYSCRIPT:            true,
+	RPMTAG_TRIGGERSCRIPTS:          true,
+	RPMTAG_FILETRIGGERSCRIPTS:      true,
+	RPMTAG_TRANSFILETRIGGERSCRIPTS: true,
+}
+
+// the tags whose email addresses WithRedaction removes, "Jane Doe <jane@example.com> - 1.0-2" for
+// a changelog entry
+var emailTags = map[TAG_ID]bool{
+	RPMTAG_PACKAGER:      true,
+	RPMTAG_CHANGELOGNAME: true,
+}
+
+var emailPattern = regexp.MustCompile(`\s*<[^<>\s]+@[^<>\s]+>|[^<>\s]+@[^<>\s]+\.[^<>\s]+`)
+
+// WithRedaction removes from every header read the fields an inventory sent to a third party may
+// not have to disclose: the build host, the email addresses of the packager and of the changelog
+// authors, and the scriptlet bodies. ListPackagesWithTags, Provenance, ExportDump and the other
+// outputs all see the redacted headers, whose signatures don't verify anymore.
+func WithRedaction() Option {
+	return func(d *RpmDB) {
+		d.redact = true
+	}
+}
+
+// redactHeader re-encodes a header blob without the redacted fields, the blob being returned as is
+// when it has none. Repeated tags keep their first occurrence.
+func redactHeader(blob []byte) ([]byte, error) {
+	indexEntries, err := headerImport(blob)
+	if err != nil {
+		return nil, err
+	}
+	redacted := false
+	for i := range indexEntries {
+		tag := indexEntries[i].Info.Tag
+		if redactedTags[tag] || emailTags[tag] {
+			redacted = true
+			break
+		}
+	}
+	if !redacted {
+		return blob, nil
+	}
+
+	b := NewHeaderBuilder()
+	for i := range indexEntries {
+		entry := &indexEntries[i]
+		tag := entry.Info.Tag
+		if _, ok := b.entries[tag]; ok || redactedTags[tag] {
+			continue
+		}
+		if emailTags[tag] {
+			values, err := entryStrings(entry)
+			if err != nil {
+				return nil, err
+			}
+			var data []byte
+			for _, v := range values {
+				data = append(data, emailPattern.ReplaceAllString(v, "")...)
+				data = append(data, 0)
+			}
+			b.add(tag, entry.Info.Type, entry.Info.Count, data)
+			continue
+		}
+		data, err := entryData(entry)
+		if err != nil {
+			return nil, err
+		}
+		b.add(tag, entry.Info.Type, entry.Info.Count, data)
+	}
+	return b.Bytes()
+}
+
+// entryData returns the data of an entry without the padding up to the next one.
+func entryData(entry *indexEntry) ([]byte, error) {
+	if err := checkEntry(entry); err != nil {
+		return nil, err
+	}
+	switch entry.Info.Type {
+	case RPM_NULL_TYPE:
+		return nil, nil
+	case RPM_STRING_TYPE, RPM_STRING_ARRAY_TYPE, RPM_I18NSTRING_TYPE:
+		end := 0
+		for i := uint32(0); i < entry.Info.Count; i++ {
+			end += bytes.IndexByte(entry.Data[end:], 0) + 1
+		}
+		return entry.Data[:end], nil
+	}
+	return entry.Data[:typeSizes[entry.Info.Type]*uint64(entry.Info.Count)], nil
+}
@@ -0,0 +1,310 @@
+package rpmdb
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/chennqqi/go-rpmdb/pkg/sqlite"
+)
+
+// RepoPackage is a package listed in the primary metadata of a repository.
+type RepoPackage struct {
+	PackageInfo
+	// Repo is the id the repository was loaded with.
+	Repo string
+	// Location is the path of the rpm relative to the repository base url.
+	Location string
+	// Checksum is the digest of the rpm file, the pkgid of createrepo.
+	Checksum string
+}
+
+// Repository is the primary metadata of a yum/dnf repository.
+type Repository struct {
+	ID       string
+	Packages []*RepoPackage
+
+	byName map[string][]*RepoPackage
+}
+
+// PackageStatus is an installed package correlated with repository metadata.
+type PackageStatus struct {
+	Package *PackageInfo
+	// Repo is the id of the first repository carrying the installed version, empty when none does:
+	// the package was installed from elsewhere or dropped from the repositories.
+	Repo string
+	// Update is the newest version of the same name and a compatible arch, nil when the installed
+	// one is up to date.
+	Update *RepoPackage
+}
+
+// LoadRepository loads the primary metadata of a repository. path is a repository directory
+// holding repodata/repomd.xml, a repodata directory, or a primary.xml or primary.sqlite file,
+// optionally compressed with gzip or bzip2.
+func LoadRepository(id, path string) (*Repository, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load repository %s: %w", id, err)
+	}
+	if info.IsDir() {
+		if path, err = primaryLocation(path); err != nil {
+			return nil, fmt.Errorf("failed to load repository %s: %w", id, err)
+		}
+	}
+
+	data, err := readRepoFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load repository %s: %w", id, err)
+	}
+
+	repo := &Repository{ID: id}
+	if bytes.HasPrefix(data, []byte("SQLite format 3\x00")) {
+		err = repo.readPrimarySqlite(data)
+	} else {
+		err = repo.readPrimaryXML(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load repository %s: %w", id, err)
+	}
+
+	repo.byName = make(map[string][]*RepoPackage)
+	for _, pkg := range repo.Packages {
+		pkg.Repo = id
+		repo.byName[pkg.Name] = append(repo.byName[pkg.Name], pkg)
+	}
+	return repo, nil
+}
+
+// Find returns the packages of the repository named name.
+func (r *Repository) Find(name string) []*RepoPackage {
+	return r.byName[name]
+}
+
+// CheckUpdates correlates the installed packages with the repositories, an offline
+// "dnf check-update". The repositories are given in priority order.
+func CheckUpdates(pkgList []*PackageInfo, repos ...*Repository) []*PackageStatus {
+	var statuses []*PackageStatus
+	for _, pkg := range pkgList {
+		status := &PackageStatus{Package: pkg}
+		for _, repo := range repos {
+			for _, candidate := range repo.Find(pkg.Name) {
+				if !compatibleArch(pkg.Arch, candidate.Arch) {
+					continue
+				}
+				cmp := CompareEVR(&candidate.PackageInfo, pkg)
+				if cmp == 0 && candidate.Arch == pkg.Arch && status.Repo == "" {
+					status.Repo = repo.ID
+				}
+				if cmp > 0 && (status.Update == nil || CompareEVR(&candidate.PackageInfo, &status.Update.PackageInfo) > 0) {
+					status.Update = candidate
+				}
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// CheckUpdates lists the installed packages correlated with the repositories, see CheckUpdates.
+func (d *RpmDB) CheckUpdates(repos ...*Repository) ([]*PackageStatus, error) {
+	pkgList, err := d.ListPackages()
+	if err != nil {
+		return nil, err
+	}
+	return CheckUpdates(pkgList, repos...), nil
+}
+
+// packages may move between an arch and noarch across updates, as dnf allows
+func compatibleArch(installed, candidate string) bool {
+	return installed == candidate || installed == "noarch" || candidate == "noarch"
+}
+
+type repomdXML struct {
+	Data []struct {
+		Type     string `xml:"type,attr"`
+		Location struct {
+			Href string `xml:"href,attr"`
+		} `xml:"location"`
+	} `xml:"data"`
+}
+
+// primaryLocation finds the primary metadata in repomd.xml, preferring the sqlite database.
+func primaryLocation(dir string) (string, error) {
+	repodata := filepath.Join(dir, "repodata")
+	if _, err := os.Stat(filepath.Join(repodata, "repomd.xml")); err != nil {
+		// dir is the repodata directory itself
+		repodata, dir = dir, filepath.Dir(dir)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(repodata, "repomd.xml"))
+	if err != nil {
+		return "", err
+	}
+	var repomd repomdXML
+	if err := xml.Unmarshal(data, &repomd); err != nil {
+		return "", fmt.Errorf("invalid repomd.xml: %w", err)
+	}
+
+	locations := make(map[string]string)
+	for _, d := range repomd.Data {
+		locations[d.Type] = d.Location.Href
+	}
+	for _, typ := range []string{"primary_db", "primary"} {
+		href, ok := locations[typ]
+		if !ok || !supportedCompression(href) {
+			continue
+		}
+		return filepath.Join(dir, filepath.FromSlash(href)), nil
+	}
+	return "", errors.New("no supported primary metadata in repomd.xml")
+}
+
+func supportedCompression(path string) bool {
+	switch filepath.Ext(path) {
+	case ".xz", ".zst", ".zck":
+		return false
+	}
+	return true
+}
+
+func readRepoFile(path string) ([]byte, error) {
+	if !supportedCompression(path) {
+		return nil, fmt.Errorf("unsupported compression: %s", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	switch filepath.Ext(path) {
+	case ".gz":
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	case ".bz2":
+		r = bzip2.NewReader(f)
+	}
+	return ioutil.ReadAll(r)
+}
+
+type primaryPackageXML struct {
+	Name    string `xml:"name"`
+	Arch    string `xml:"arch"`
+	Version struct {
+		Epoch string `xml:"epoch,attr"`
+		Ver   string `xml:"ver,attr"`
+		Rel   string `xml:"rel,attr"`
+	} `xml:"version"`
+	Checksum string `xml:"checksum"`
+	Location struct {
+		Href string `xml:"href,attr"`
+	} `xml:"location"`
+}
+
+// readPrimaryXML streams the packages of primary.xml, which runs to hundreds of megabytes for
+// distribution repositories.
+// ref. https://github.com/rpm-software-management/createrepo_c/blob/master/src/xml_dump_primary.c
+func (r *Repository) readPrimaryXML(reader io.Reader) error {
+	decoder := xml.NewDecoder(reader)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid primary.xml: %w", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "package" {
+			continue
+		}
+
+		var p primaryPackageXML
+		if err := decoder.DecodeElement(&p, &start); err != nil {
+			return fmt.Errorf("invalid primary.xml: %w", err)
+		}
+		if p.Arch == "src" {
+			continue
+		}
+		epoch, _ := strconv.Atoi(p.Version.Epoch)
+		r.Packages = append(r.Packages, &RepoPackage{
+			PackageInfo: PackageInfo{
+				Epoch:   epoch,
+				Name:    p.Name,
+				Version: p.Version.Ver,
+				Release: p.Version.Rel,
+				Arch:    p.Arch,
+			},
+			Location: p.Location.Href,
+			Checksum: strings.TrimSpace(p.Checksum),
+		})
+	}
+}
+
+// readPrimarySqlite reads the packages table of primary.sqlite, epoch is stored as text.
+// ref. https://github.com/rpm-software-management/createrepo_c/blob/master/src/sqlite.c
+func (r *Repository) readPrimarySqlite(data []byte) error {
+	db, err := sqlite.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("invalid primary.sqlite: %w", err)
+	}
+	defer db.Close()
+
+	table := db.Table("packages")
+	if table == nil {
+		return errors.New("invalid primary.sqlite: missing table packages")
+	}
+	columns := []string{"pkgId", "name", "arch", "epoch", "version", "release", "location_href"}
+	indexes := make([]int, len(columns))
+	for i, column := range columns {
+		if indexes[i] = table.ColumnIndex(column); indexes[i] < 0 {
+			return fmt.Errorf("invalid primary.sqlite: missing column packages.%s", column)
+		}
+	}
+
+	err = db.Rows("packages", func(row sqlite.Row) error {
+		v := make([]string, len(indexes))
+		for i, index := range indexes {
+			switch value := row.Values[index].(type) {
+			case string:
+				v[i] = value
+			case int64:
+				v[i] = strconv.FormatInt(value, 10)
+			}
+		}
+		if v[2] == "src" {
+			return nil
+		}
+		epoch, _ := strconv.Atoi(v[3])
+		r.Packages = append(r.Packages, &RepoPackage{
+			PackageInfo: PackageInfo{
+				Epoch:   epoch,
+				Name:    v[1],
+				Version: v[4],
+				Release: v[5],
+				Arch:    v[2],
+			},
+			Location: v[6],
+			Checksum: v[0],
+		})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("invalid primary.sqlite: %w", err)
+	}
+	return nil
+}
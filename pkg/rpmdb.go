@@ -1,45 +1,368 @@
 package rpmdb
 
 import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path/filepath"
+
 	"github.com/chennqqi/go-rpmdb/pkg/bdb"
-	"golang.org/x/xerrors"
+	"github.com/chennqqi/go-rpmdb/pkg/ndb"
+	"github.com/chennqqi/go-rpmdb/pkg/sqlite"
 )
 
+// storage is where the headers are read from, a Berkeley DB Packages file, a db_dump of one, an
+// rpmdb.sqlite or an ndb Packages.db.
+type storage interface {
+	// ReadUntil returns the headers until done is closed, see readHeaders.
+	ReadUntil(done <-chan struct{}) <-chan bdb.Entry
+	ReadOverflow(pageNo uint32) ([]byte, error)
+	Close() error
+}
+
 type RpmDB struct {
-	db *bdb.BerkeleyDB
+	db storage
+	// the directory holding Packages and the index databases
+	dir string
+	// iterate in (name, EVR, arch) order rather than database order
+	sorted bool
+	// strip the fields of WithRedaction from every header
+	redact bool
+	// the interner of WithInterner, nil when strings aren't interned
+	interner *Interner
+	// decode the headers of the listings into pooled tables
+	entryPool bool
+	// the tags the listings leave out of the headers, see WithoutFiles and WithoutChangelogs
+	skipTags map[TAG_ID]bool
+	// keep the tags of unsupported types in TagsMap
+	rawValues bool
+	// what to do with the invalid strings of the headers, see WithStringHandling
+	strings StringHandling
+	// the keys Trust checks the signatures with, the imported ones when nil
+	keyring *Keyring
+	// the bytes each set keeps in memory before spilling to a temporary file, see WithMemoryBudget
+	memoryBudget int64
+}
+
+// Option configures an RpmDB at Open.
+type Option func(*RpmDB)
+
+// WithSortedOutput makes every listing yield the packages sorted by name, EVR and arch instead of
+// the hash order of the database, which differs between copies of the same content. The output
+// doesn't stream: a first pass collects and sorts the keys of every header, the NEVRA and the
+// location of each, a few hundred bytes per package, spilled past WithMemoryBudget, before the
+// headers are read again one at a time in that order.
+func WithSortedOutput() Option {
+	return func(d *RpmDB) {
+		d.sorted = true
+	}
 }
 
-func Open(path string) (*RpmDB, error) {
+// Open reads the Packages file of a Berkeley DB database, the rpmdb.sqlite of rpm >= 4.16, whose
+// Name, Basenames, Providename and Requirename tables answer the lookups, or the Packages.db of
+// the ndb backend, whose index database isn't read.
+func Open(path string, opts ...Option) (*RpmDB, error) {
+	db, err := openStorage(path)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &RpmDB{
+		db:  db,
+		dir: filepath.Dir(path),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d, nil
+
+}
+
+// openStorage opens the database at path in the format its first bytes tell.
+func openStorage(path string) (storage, error) {
+	switch fileBackend(path) {
+	case BackendSQLite:
+		db, err := openSQLite(path)
+		if err != nil {
+			return nil, err
+		}
+		return db, nil
+	case BackendNDB:
+		db, err := ndb.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		return db, nil
+	}
 	db, err := bdb.Open(path)
 	if err != nil {
 		return nil, err
 	}
+	return db, nil
+}
 
-	return &RpmDB{
-		db: db,
-	}, nil
+// OpenReader reads a database of size bytes from r, in any of the formats Open reads: a memory
+// map, a byte slice with bytes.NewReader, a reader of HTTP ranges. Without the index databases
+// of a Packages file, WhatProvides and WhatRequires scan every header.
+func OpenReader(r io.ReaderAt, size int64, opts ...Option) (*RpmDB, error) {
+	db, err := readerStorage(r, size)
+	if err != nil {
+		return nil, err
+	}
 
+	d := &RpmDB{db: db}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d, nil
 }
 
-func (d *RpmDB) ListPackages() ([]*PackageInfo, error) {
-	var pkgList []*PackageInfo
+// readerStorage opens the database r holds in the format its first bytes tell.
+func readerStorage(r io.ReaderAt, size int64) (storage, error) {
+	switch readerBackend(r) {
+	case BackendSQLite:
+		db, err := sqlite.NewReader(r, size)
+		if err != nil {
+			return nil, err
+		}
+		return newSQLiteStorage(db)
+	case BackendNDB:
+		db, err := ndb.NewReader(r, size)
+		if err != nil {
+			return nil, err
+		}
+		return db, nil
+	}
+	db, err := bdb.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// OpenDump reads the packages of the text db_dump prints for a Packages file (db_dump -k or -p
+// included), for the support cases where a dump is all there is. Without the index databases,
+// WhatProvides and WhatRequires scan every header.
+func OpenDump(r io.Reader, opts ...Option) (*RpmDB, error) {
+	dump, err := bdb.ReadDump(r)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &RpmDB{db: dump}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d, nil
+}
+
+// ExportDump writes the packages in the text format of db_dump, which db_load loads back into a
+// Packages database on a host having the Berkeley DB tools, whatever the database was read from.
+// The record of key 0, where rpm keeps the last header instance number, comes last.
+func (d *RpmDB) ExportDump(w io.Writer) error {
+	pageSize := uint32(4096)
+	switch db := d.db.(type) {
+	case *bdb.BerkeleyDB:
+		pageSize = db.HashMetadata.PageSize
+	case *bdb.Dump:
+		pageSize = db.PageSize
+	}
+	dw, err := bdb.NewDumpWriter(w, pageSize)
+	if err != nil {
+		return err
+	}
+
+	key := make([]byte, 4)
+	var maxHnum uint32
+	err = d.forEachHeader(func(hnum uint32, blob []byte) error {
+		if hnum > maxHnum {
+			maxHnum = hnum
+		}
+		binary.LittleEndian.PutUint32(key, hnum)
+		return dw.Put(key, blob)
+	})
+	if err != nil {
+		return err
+	}
 
-	for entry := range d.db.Read() {
+	value := make([]byte, 4)
+	binary.LittleEndian.PutUint32(value, maxHnum)
+	if err := dw.Put(make([]byte, 4), value); err != nil {
+		return err
+	}
+	return dw.Close()
+}
+
+// BackendStats reports how the pages of the Packages file are used: buckets, overflow chains,
+// free and leaked pages. The other formats than Berkeley DB return ErrNotSupport.
+func (d *RpmDB) BackendStats() (*bdb.Stats, error) {
+	db, ok := d.db.(*bdb.BerkeleyDB)
+	if !ok {
+		return nil, fmt.Errorf("backend statistics: %w", ErrNotSupport)
+	}
+	return db.Stats()
+}
+
+func (d *RpmDB) Close() error {
+	return d.db.Close()
+}
+
+// readHeaders returns the records of the database, and stop to call once done with them: it ends
+// the reading goroutine of the storage, blocked on a record nobody receives after an early return.
+func (d *RpmDB) readHeaders() (entries <-chan bdb.Entry, stop func()) {
+	done := make(chan struct{})
+	return d.db.ReadUntil(done), func() { close(done) }
+}
+
+// forEachHeader calls fn with the instance number and the raw blob of every header in the database.
+func (d *RpmDB) forEachHeader(fn func(hnum uint32, blob []byte) error) error {
+	fn = d.rewriteHeaders(fn)
+	if d.sorted {
+		return d.forEachSortedHeader(fn)
+	}
+	entries, stop := d.readHeaders()
+	defer stop()
+	for entry := range entries {
 		if entry.Err != nil {
-			return nil, entry.Err
+			return entry.Err
+		}
+		if isPlaceholder(entry) {
+			continue
+		}
+
+		var hnum uint32
+		if len(entry.Key) == 4 {
+			hnum = binary.LittleEndian.Uint32(entry.Key)
+		}
+		if err := fn(hnum, entry.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewriteHeaders wraps fn to apply WithRedaction and WithStringHandling to the headers it is called
+// with.
+func (d *RpmDB) rewriteHeaders(fn func(hnum uint32, blob []byte) error) func(hnum uint32, blob []byte) error {
+	if d.redact {
+		next := fn
+		fn = func(hnum uint32, blob []byte) error {
+			redacted, err := redactHeader(blob)
+			if err != nil {
+				return fmt.Errorf("failed to redact header: %w", err)
+			}
+			return next(hnum, redacted)
 		}
+	}
+	if d.strings != StringsRaw {
+		next := fn
+		fn = func(hnum uint32, blob []byte) error {
+			sanitized, err := sanitizeHeader(blob, d.strings == StringsStrict)
+			if err != nil {
+				return fmt.Errorf("failed to sanitize header: %w", err)
+			}
+			return next(hnum, sanitized)
+		}
+	}
+	return fn
+}
+
+// isPlaceholder tells the records of the Packages database that hold no package: the join key
+// record of instance 0, where rpm keeps the last instance number allocated, zero-length values and
+// empty headers left by an interrupted write.
+func isPlaceholder(entry bdb.Entry) bool {
+	if len(entry.Key) == 4 && binary.LittleEndian.Uint32(entry.Key) == 0 {
+		return true
+	}
+	// an index and a data length of 0
+	return len(entry.Value) == 0 || (len(entry.Value) == 8 && binary.BigEndian.Uint64(entry.Value) == 0)
+}
 
+type headerKey struct {
+	pkg    PackageInfo
+	hnum   uint32
+	pageNo uint32
+}
+
+// forEachSortedHeader is forEachHeader for WithSortedOutput: the keys of all the headers are kept
+// and sorted, or spilled in sorted runs past WithMemoryBudget, then the headers are read again by
+// page in their order.
+func (d *RpmDB) forEachSortedHeader(fn func(hnum uint32, blob []byte) error) error {
+	keys := &headerKeySet{budget: d.memoryBudget}
+	defer keys.Close()
+	entries, stop := d.readHeaders()
+	defer stop()
+	for entry := range entries {
+		if entry.Err != nil {
+			return entry.Err
+		}
+		if isPlaceholder(entry) {
+			continue
+		}
 		indexEntries, err := headerImport(entry.Value)
 		if err != nil {
-			return nil, xerrors.Errorf("error during importing header: %w", err)
+			return fmt.Errorf("error during importing header: %w", err)
+		}
+		pkg, err := getNEVRA(indexEntries)
+		if err != nil {
+			return fmt.Errorf("invalid package info: %w", err)
+		}
+
+		key := headerKey{pageNo: entry.PageNo}
+		key.pkg.Name, key.pkg.Epoch, key.pkg.Version, key.pkg.Release, key.pkg.Arch = pkg.Name, pkg.Epoch, pkg.Version, pkg.Release, pkg.Arch
+		if len(entry.Key) == 4 {
+			key.hnum = binary.LittleEndian.Uint32(entry.Key)
+		}
+		if err := keys.add(key); err != nil {
+			return err
+		}
+	}
+	if err := keys.finish(); err != nil {
+		return err
+	}
+
+	return keys.each(func(key *headerKey) error {
+		blob, err := d.db.ReadOverflow(key.pageNo)
+		if err != nil {
+			return err
 		}
+		return fn(key.hnum, blob)
+	})
+}
+
+// forEachPackage calls fn with the decoded index entries and package info of every header, fn not
+// retaining the entries as forEachIndex requires.
+func (d *RpmDB) forEachPackage(fn func(hnum uint32, indexEntries []indexEntry, pkg *PackageInfo) error) error {
+	return d.forEachIndex(func(hnum uint32, indexEntries []indexEntry) error {
 		pkg, err := getNEVRA(indexEntries)
 		if err != nil {
-			return nil, xerrors.Errorf("invalid package info: %w", err)
+			return fmt.Errorf("invalid package info: %w", err)
 		}
+		if d.interner != nil {
+			d.interner.internPackage(pkg)
+		}
+		return fn(hnum, indexEntries, pkg)
+	})
+}
+
+func (d *RpmDB) ListPackages() ([]*PackageInfo, error) {
+	var pkgList []*PackageInfo
+	var keyIDs []string
+	var signed []bool
+
+	err := d.forEachPackage(func(hnum uint32, indexEntries []indexEntry, pkg *PackageInfo) error {
 		pkgList = append(pkgList, pkg)
+
+		keyID, ok := signatureKeyID(indexEntries)
+		keyIDs = append(keyIDs, d.intern(keyID))
+		signed = append(signed, ok)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	attributeSignatures(pkgList, keyIDs, signed)
 	return pkgList, nil
 }
 
@@ -52,27 +375,36 @@ func (d *RpmDB) ListPackages() ([]*PackageInfo, error) {
 */
 func (d *RpmDB) ListPackagesWithTags(ids ...TAG_ID) ([]*PackageInfoEx, error) {
 	var pkgList []*PackageInfoEx
+	var infoList []*PackageInfo
+	var keyIDs []string
+	var signed []bool
 
 	tagMask := make(map[TAG_ID]bool)
 	for i := 0; i < len(ids); i++ {
 		tagMask[ids[i]] = true
 	}
 
-	for entry := range d.db.Read() {
-		if entry.Err != nil {
-			return nil, entry.Err
-		}
-
-		indexEntries, err := headerImport(entry.Value)
+	err := d.forEachIndex(func(hnum uint32, indexEntries []indexEntry) error {
+		pkg, err := getPackageWithTags(indexEntries, tagMask, d.rawValues)
 		if err != nil {
-			return nil, xerrors.Errorf("error during importing header: %w", err)
+			return fmt.Errorf("invalid package info: %w", err)
 		}
-		pkg, err := getPackageWithTags(indexEntries, tagMask)
-		if err != nil {
-			return nil, xerrors.Errorf("invalid package info: %w", err)
+		if d.interner != nil {
+			d.interner.internPackage(&pkg.PackageInfo)
+			d.interner.internTags(pkg.TagsMap)
 		}
 		pkgList = append(pkgList, pkg)
+		infoList = append(infoList, &pkg.PackageInfo)
+
+		keyID, ok := signatureKeyID(indexEntries)
+		keyIDs = append(keyIDs, d.intern(keyID))
+		signed = append(signed, ok)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	attributeSignatures(infoList, keyIDs, signed)
 	return pkgList, nil
 }
@@ -1,14 +1,43 @@
 package rpmdb
 
 import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
 	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
+	"unsafe"
+
+	"github.com/chennqqi/go-rpmdb/pkg/bdb"
+	"github.com/chennqqi/go-rpmdb/pkg/ndb"
 )
 
 func TestPackageList(t *testing.T) {
 	vectors := []struct {
 		file    string // Test input file
-		pkgList []PackageInfo
+		pkgList []testPackageInfo
 	}{
 		{
 			file:    "testdata/centos6-plain/Packages",
@@ -92,3 +121,4759 @@ func TestPackageList(t *testing.T) {
 		})
 	}
 }
+
+func samePackage(got *PackageInfo, want testPackageInfo) bool {
+	return testPackageInfo{
+		Epoch:     got.Epoch,
+		Name:      got.Name,
+		Version:   got.Version,
+		Release:   got.Release,
+		Arch:      got.Arch,
+		SourceRpm: got.SourceRpm,
+		Size:      got.Size,
+		License:   got.License,
+		Vendor:    got.Vendor,
+	} == want
+}
+
+// TestGoldenPackageList compares the packages of every fixture having an rpm-qa.golden file, the
+// sorted output of rpm -qa --queryformat "%{NEVRA}\n" on the system the database comes from,
+// with the ones listed. A fixture is a directory holding the database under its file name of
+// dbFiles, so that a fixture of any backend is added without code. The CentOS golden files restate
+// the lists of rpmdb_testcase.go; the databases of CentOS 8, Fedora, openSUSE, Amazon Linux 2023,
+// Photon and Rocky, with the rpm -qa output of their systems, are still to be added.
+func TestGoldenPackageList(t *testing.T) {
+	goldens, err := filepath.Glob("testdata/*/rpm-qa.golden")
+	if err != nil {
+		t.Fatalf("Glob() error: %v", err)
+	}
+	if len(goldens) == 0 {
+		t.Fatal("no golden file")
+	}
+
+	for _, golden := range goldens {
+		dir := filepath.Dir(golden)
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			content, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("ReadFile() error: %v", err)
+			}
+			want := strings.Fields(string(content))
+
+			db := openFixture(t, dir)
+			defer db.Close()
+			pkgList, err := db.ListPackages()
+			if err != nil {
+				t.Fatalf("ListPackages() error: %v", err)
+			}
+			got := make([]string, 0, len(pkgList))
+			for _, pkg := range pkgList {
+				got = append(got, pkg.NEVRA())
+			}
+			sort.Strings(got)
+
+			if !reflect.DeepEqual(got, want) {
+				missing, unexpected := diffSorted(want, got)
+				t.Errorf("package list: %d missing %v, %d unexpected %v", len(missing), missing, len(unexpected), unexpected)
+			}
+		})
+	}
+}
+
+// openFixture opens the database of the fixture directory, in whichever format it has.
+func openFixture(t *testing.T, dir string) *RpmDB {
+	t.Helper()
+	for _, file := range dbFiles {
+		dbPath := filepath.Join(dir, file.name)
+		if _, err := os.Stat(dbPath); err != nil {
+			continue
+		}
+		db, err := Open(dbPath)
+		if err != nil {
+			t.Fatalf("Open() error: %v", err)
+		}
+		return db
+	}
+	t.Fatalf("%s: no database", dir)
+	return nil
+}
+
+// diffSorted returns the strings of sorted a missing from sorted b, and the ones of b missing from a.
+func diffSorted(a, b []string) (onlyA, onlyB []string) {
+	for len(a) > 0 && len(b) > 0 {
+		switch {
+		case a[0] == b[0]:
+			a, b = a[1:], b[1:]
+		case a[0] < b[0]:
+			onlyA, a = append(onlyA, a[0]), a[1:]
+		default:
+			onlyB, b = append(onlyB, b[0]), b[1:]
+		}
+	}
+	return append(onlyA, a...), append(onlyB, b...)
+}
+
+func TestInstallOnlyPackages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmdb")
+	if err != nil {
+		t.Fatalf("TempDir() error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	header := func(name, version, release string, provides ...string) []byte {
+		b := NewHeaderBuilder()
+		b.AddString(RPMTAG_NAME, name)
+		b.AddString(RPMTAG_VERSION, version)
+		b.AddString(RPMTAG_RELEASE, release)
+		b.AddString(RPMTAG_ARCH, "x86_64")
+		b.AddStringArray(RPMTAG_PROVIDENAME, append([]string{name}, provides...))
+		// on overflow pages like the headers rpm writes
+		b.AddString(RPMTAG_DESCRIPTION, strings.Repeat("foo ", 1024))
+		blob, err := b.Bytes()
+		if err != nil {
+			t.Fatalf("Bytes() error: %v", err)
+		}
+		return blob
+	}
+	// the packages of centos7-httpd24, three gpg-pubkey among them, and kernels installed out of
+	// order along with kernel modules marked installonly by their provide
+	blobs := [][]byte{
+		header("kernel", "3.10.0", "1062.el7"),
+		header("kernel", "3.10.0", "957.el7"),
+		header("kernel", "3.10.0", "1160.el7"),
+		header("kmod-nvidia", "470.82", "1.el7", "installonlypkg(kernel-module)"),
+		header("kmod-nvidia", "460.91", "1.el7", "installonlypkg(kernel-module)"),
+		header("kmod-other", "1.0", "1.el7", "kernel-module(other)"),
+	}
+	source, err := Open("testdata/centos7-httpd24/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	w, err := bdb.Create(filepath.Join(dir, "Packages"), 4096)
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	err = source.forEachHeader(func(hnum uint32, blob []byte) error {
+		key := make([]byte, 4)
+		binary.LittleEndian.PutUint32(key, hnum)
+		return w.Put(key, blob)
+	})
+	source.Close()
+	for i, blob := range blobs {
+		if err != nil {
+			break
+		}
+		key := make([]byte, 4)
+		binary.LittleEndian.PutUint32(key, uint32(10000+i))
+		err = w.Put(key, blob)
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		t.Fatalf("writing the database: %v", err)
+	}
+
+	db, err := Open(filepath.Join(dir, "Packages"))
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+	groups, err := db.InstallOnlyPackages()
+	if err != nil {
+		t.Fatalf("InstallOnlyPackages() error: %v", err)
+	}
+	got := make(map[string][]string)
+	var names []string
+	for _, group := range groups {
+		names = append(names, group.Name)
+		for _, pkg := range group.Installed {
+			got[group.Name] = append(got[group.Name], pkg.EVR())
+		}
+	}
+	want := map[string][]string{
+		// a numeric segment is newer than an alphabetic one
+		"gpg-pubkey":  {"f2ee9d55-560cfc0a", "f4a80eb5-53a7ff4b", "352c64e5-52ae6884"},
+		"kernel":      {"3.10.0-957.el7", "3.10.0-1062.el7", "3.10.0-1160.el7"},
+		"kmod-nvidia": {"460.91-1.el7", "470.82-1.el7"},
+	}
+	if wantNames := []string{"gpg-pubkey", "kernel", "kmod-nvidia"}; !reflect.DeepEqual(names, wantNames) {
+		t.Fatalf("InstallOnlyPackages(): got %v, want %v", names, wantNames)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("InstallOnlyPackages(): got %v, want %v", got, want)
+	}
+
+	kernel := groups[1]
+	if latest := kernel.Latest(); latest.Release != "1160.el7" {
+		t.Errorf("Latest(): got %s", latest.NEVRA())
+	}
+	for release, want := range map[string]string{
+		"3.10.0-1062.el7.x86_64": "1062.el7",
+		"3.10.0-1062.el7":        "1062.el7",
+		"3.10.0-1127.el7.x86_64": "",
+		"3.10.0-1062.el7.i686":   "",
+		"3.10.0":                 "",
+	} {
+		pkg := kernel.Lookup(release)
+		if (pkg == nil && want != "") || (pkg != nil && pkg.Release != want) {
+			t.Errorf("Lookup(%s): got %v, want %q", release, pkg, want)
+		}
+	}
+
+	for _, tt := range []struct {
+		name     string
+		provides []string
+		want     bool
+	}{
+		{"kernel-core", nil, true},
+		{"kmod-nvidia", []string{"kmod-nvidia", "installonlypkg(kernel-module)"}, true},
+		{"kernel-ml", []string{"kernel-ml", "multiversion(kernel)"}, true},
+		{"kmod-other", []string{"kmod-other", "kernel-module(other)"}, false},
+		{"installonlypkg", []string{"installonlypkg"}, false},
+	} {
+		if got := isInstallOnly(tt.name, tt.provides); got != tt.want {
+			t.Errorf("isInstallOnly(%s, %v): got %v, want %v", tt.name, tt.provides, got, tt.want)
+		}
+	}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	pkg := func(nevra string) *PackageInfo {
+		// name-[epoch:]version-release.arch
+		p := &PackageInfo{}
+		i := strings.LastIndex(nevra, ".")
+		p.Arch, nevra = nevra[i+1:], nevra[:i]
+		i = strings.LastIndex(nevra, "-")
+		p.Release, nevra = nevra[i+1:], nevra[:i]
+		i = strings.LastIndex(nevra, "-")
+		p.Name, p.Version = nevra[:i], nevra[i+1:]
+		if i := strings.Index(p.Version, ":"); i >= 0 {
+			p.Epoch, _ = strconv.Atoi(p.Version[:i])
+			p.Version = p.Version[i+1:]
+		}
+		return p
+	}
+	vectors := []struct {
+		name       string
+		nevras     []string
+		duplicates []string
+		multilib   []string
+	}{
+		{
+			name:   "single installs",
+			nevras: []string{"bash-4.2.46-31.el7.x86_64", "glibc-2.17-222.el7.x86_64"},
+		},
+		{
+			name:     "multilib pair",
+			nevras:   []string{"glibc-2.17-222.el7.x86_64", "glibc-2.17-222.el7.i686", "zlib-1.2.7-17.el7.i686"},
+			multilib: []string{"glibc-2.17-222.el7 x2"},
+		},
+		{
+			name:   "kernels side by side",
+			nevras: []string{"kernel-3.10.0-862.el7.x86_64", "kernel-3.10.0-957.el7.x86_64", "kernel-3.10.0-1062.el7.x86_64"},
+		},
+		{
+			name:       "kernel recorded twice",
+			nevras:     []string{"kernel-3.10.0-862.el7.x86_64", "kernel-3.10.0-957.el7.x86_64", "kernel-3.10.0-957.el7.x86_64"},
+			duplicates: []string{"kernel.x86_64 x2 identical"},
+		},
+		{
+			name:       "interrupted upgrade",
+			nevras:     []string{"bash-4.2.46-31.el7.x86_64", "bash-4.2.46-34.el7.x86_64"},
+			duplicates: []string{"bash.x86_64 x2"},
+		},
+		{
+			name:       "install recorded twice",
+			nevras:     []string{"bash-4.2.46-31.el7.x86_64", "zlib-1.2.7-17.el7.x86_64", "bash-4.2.46-31.el7.x86_64"},
+			duplicates: []string{"bash.x86_64 x2 identical"},
+		},
+		{
+			name:       "multilib upgrade interrupted for one arch",
+			nevras:     []string{"glibc-2.17-222.el7.x86_64", "glibc-2.17-222.el7.i686", "glibc-2.17-196.el7.i686"},
+			duplicates: []string{"glibc.i686 x2"},
+			multilib:   []string{"glibc-2.17-222.el7 x2"},
+		},
+		{
+			name:       "epoch only differs",
+			nevras:     []string{"bind-license-9.9.4-61.el7.noarch", "bind-license-32:9.9.4-61.el7.noarch"},
+			duplicates: []string{"bind-license.noarch x2"},
+		},
+		{
+			name:   "different epochs are no multilib pair",
+			nevras: []string{"openssl-libs-1:1.0.2k-12.el7.x86_64", "openssl-libs-1.0.2k-12.el7.i686"},
+		},
+		{
+			name:     "same epoch multilib pair",
+			nevras:   []string{"openssl-libs-1:1.0.2k-12.el7.x86_64", "openssl-libs-1:1.0.2k-12.el7.i686"},
+			multilib: []string{"openssl-libs-1:1.0.2k-12.el7 x2"},
+		},
+	}
+
+	for _, v := range vectors {
+		t.Run(v.name, func(t *testing.T) {
+			var pkgList []*PackageInfo
+			for _, nevra := range v.nevras {
+				pkgList = append(pkgList, pkg(nevra))
+				if got := pkgList[len(pkgList)-1].NEVRA(); got != nevra {
+					t.Fatalf("test package %s: got %s", nevra, got)
+				}
+			}
+			report := FindDuplicates(pkgList)
+			var duplicates, multilib []string
+			for _, d := range report.Duplicates {
+				s := fmt.Sprintf("%s.%s x%d", d.Name, d.Arch, len(d.Packages))
+				if d.Identical {
+					s += " identical"
+				}
+				duplicates = append(duplicates, s)
+			}
+			for _, m := range report.Multilib {
+				multilib = append(multilib, fmt.Sprintf("%s x%d", m.NEVR, len(m.Packages)))
+			}
+			if !reflect.DeepEqual(duplicates, v.duplicates) {
+				t.Errorf("Duplicates: got %q, want %q", duplicates, v.duplicates)
+			}
+			if !reflect.DeepEqual(multilib, v.multilib) {
+				t.Errorf("Multilib: got %q, want %q", multilib, v.multilib)
+			}
+		})
+	}
+
+	db, err := Open("testdata/centos7-many/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+	report, err := db.FindDuplicates()
+	if err != nil {
+		t.Fatalf("FindDuplicates() error: %v", err)
+	}
+	if len(report.Duplicates) != 0 {
+		t.Errorf("FindDuplicates() of a healthy database got %+v", report.Duplicates)
+	}
+}
+
+func TestRebuild(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-rpmdb")
+	if err != nil {
+		t.Fatalf("TempDir() error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	dst := filepath.Join(dir, "Packages")
+	result, err := Rebuild("testdata/centos7-many/Packages", dst)
+	if err != nil {
+		t.Fatalf("Rebuild() error: %v", err)
+	}
+	if result.Copied != len(CentOS7Many) || result.Skipped != 0 {
+		t.Errorf("result: got %+v, want %d copied", *result, len(CentOS7Many))
+	}
+
+	db, err := Open(dst)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	pkgList, err := db.ListPackages()
+	if err != nil {
+		t.Fatalf("ListPackages() error: %v", err)
+	}
+	if len(pkgList) != len(CentOS7Many) {
+		t.Fatalf("pkg length: got %v, want %v", len(pkgList), len(CentOS7Many))
+	}
+	for i, got := range pkgList {
+		if want := CentOS7Many[i]; !samePackage(got, want) {
+			t.Errorf("%d: got %+v, want %+v", i, *got, want)
+		}
+	}
+
+	// from and to other backends, the one of dst being told by its name
+	for _, conversion := range []struct{ src, dst string }{
+		{"testdata/centos7-plain/Packages", "ndb/Packages.db"},
+		{filepath.Join(dir, "ndb/Packages.db"), "from-ndb/Packages"},
+		{"testdata/sqlite-centos7/rpmdb.sqlite", "from-sqlite/Packages"},
+	} {
+		dst := filepath.Join(dir, conversion.dst)
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			t.Fatalf("MkdirAll() error: %v", err)
+		}
+		result, err := Rebuild(conversion.src, dst)
+		if err != nil {
+			t.Fatalf("Rebuild(%s, %s) error: %v", conversion.src, conversion.dst, err)
+		}
+		if result.Copied != len(CentOS7Plain) || result.Skipped != 0 {
+			t.Errorf("Rebuild(%s, %s): got %+v, want %d copied", conversion.src, conversion.dst, *result, len(CentOS7Plain))
+		}
+		db, err := Open(dst)
+		if err != nil {
+			t.Fatalf("Open(%s) error: %v", conversion.dst, err)
+		}
+		pkgList, err := db.ListPackages()
+		db.Close()
+		if err != nil {
+			t.Fatalf("ListPackages() of %s error: %v", conversion.dst, err)
+		}
+		if len(pkgList) != len(CentOS7Plain) {
+			t.Errorf("%s: got %d packages, want %d", conversion.dst, len(pkgList), len(CentOS7Plain))
+		}
+		got := make(map[string]bool)
+		for _, pkg := range pkgList {
+			got[pkg.Name+"-"+pkg.Version+"-"+pkg.Release] = true
+		}
+		for _, want := range CentOS7Plain {
+			if !got[want.Name+"-"+want.Version+"-"+want.Release] {
+				t.Errorf("%s: %s-%s-%s missing", conversion.dst, want.Name, want.Version, want.Release)
+			}
+		}
+	}
+	if fileBackend(filepath.Join(dir, "ndb/Packages.db")) != BackendNDB {
+		t.Errorf("Rebuild() to Packages.db: not an ndb database")
+	}
+	if _, err := Rebuild("testdata/centos7-plain/Packages", filepath.Join(dir, "rpmdb.sqlite")); err == nil {
+		t.Errorf("Rebuild() to rpmdb.sqlite: got no error")
+	}
+}
+
+func TestSalvagePackages(t *testing.T) {
+	header := func(name string) []byte {
+		b := NewHeaderBuilder()
+		b.AddString(RPMTAG_NAME, name)
+		b.AddString(RPMTAG_VERSION, "1.0")
+		b.AddString(RPMTAG_RELEASE, "1")
+		b.AddString(RPMTAG_DESCRIPTION, strings.Repeat("foo ", 1024))
+		b.AddString(RPMTAG_ARCH, "x86_64")
+		blob, err := b.Bytes()
+		if err != nil {
+			t.Fatalf("Bytes() error: %v", err)
+		}
+		return blob
+	}
+	truncated := header("bar")
+	// cut in the middle of the description, the arch and the region trailer are lost
+	truncated = truncated[:len(truncated)-2000]
+	// an index length past the blob, whose data can't be found
+	lost := header("baz")
+	binary.BigEndian.PutUint32(lost, 1000)
+	blobs := [][]byte{header("foo"), truncated, lost}
+
+	dir, err := ioutil.TempDir("", "rpmdb")
+	if err != nil {
+		t.Fatalf("TempDir() error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	dbPath := filepath.Join(dir, "Packages")
+	w, err := bdb.Create(dbPath, 4096)
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	for i, blob := range blobs {
+		key := make([]byte, 4)
+		binary.LittleEndian.PutUint32(key, uint32(i+1))
+		if err := w.Put(key, blob); err != nil {
+			t.Fatalf("Put() error: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.ListPackages(); err == nil {
+		t.Fatalf("ListPackages() of a damaged database: expected an error")
+	}
+	salvaged, err := db.SalvagePackages()
+	if err != nil {
+		t.Fatalf("SalvagePackages() error: %v", err)
+	}
+	sort.Slice(salvaged, func(i, j int) bool { return salvaged[i].HNum < salvaged[j].HNum })
+	var got []string
+	for _, s := range salvaged {
+		got = append(got, fmt.Sprintf("%d %s %v %d/%d", s.HNum, s.Package.NEVRA(), s.Partial, s.Recovered, s.Entries))
+	}
+	want := []string{"1 foo-1.0-1.x86_64 false 0/0", "2 bar-1.0-1 true 3/5", "3 -- true 0/999"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SalvagePackages() got %q, want %q", got, want)
+	}
+	if salvaged[1].Err == nil {
+		t.Errorf("truncated header got %+v", salvaged[1])
+	}
+}
+
+func TestCarveHeaders(t *testing.T) {
+	header := func(name string) []byte {
+		b := NewHeaderBuilder()
+		b.AddString(RPMTAG_NAME, name)
+		b.AddString(RPMTAG_VERSION, "1.0")
+		b.AddString(RPMTAG_RELEASE, "1")
+		b.AddString(RPMTAG_DESCRIPTION, strings.Repeat("foo ", 1024))
+		b.AddString(RPMTAG_ARCH, "x86_64")
+		blob, err := b.Bytes()
+		if err != nil {
+			t.Fatalf("Bytes() error: %v", err)
+		}
+		return blob
+	}
+	garbage := bytes.Repeat([]byte{0, 0, 0, 0x3f, 0, 0, 0, 0x07, 0xff}, 100)
+	var img []byte
+	img = append(img, garbage...)
+	img = append(img, headerMagic...)
+	img = append(img, header("foo")...)
+	img = append(img, garbage...)
+	img = append(img, header("bar")...)
+	img = append(img, garbage...)
+	baz := header("baz")
+	img = append(img, baz[:len(baz)-2000]...)
+
+	var got []string
+	err := CarveHeaders(bytes.NewReader(img), int64(len(img)), func(h *CarvedHeader) error {
+		got = append(got, fmt.Sprintf("%d %v %s %v", h.Offset, h.Magic, h.Package.NEVRA(), h.Partial))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("CarveHeaders() error: %v", err)
+	}
+	foo := len(garbage)
+	bar := foo + len(headerMagic) + len(header("foo")) + len(garbage)
+	want := []string{
+		fmt.Sprintf("%d true foo-1.0-1.x86_64 false", foo),
+		fmt.Sprintf("%d false bar-1.0-1.x86_64 false", bar),
+		fmt.Sprintf("%d false baz-1.0-1 true", bar+len(header("bar"))+len(garbage)),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CarveHeaders() got %q, want %q", got, want)
+	}
+
+}
+
+func TestCheckConsistency(t *testing.T) {
+	header := func(name string) []byte {
+		b := NewHeaderBuilder()
+		b.AddString(RPMTAG_NAME, name)
+		b.AddString(RPMTAG_VERSION, "1.0")
+		b.AddString(RPMTAG_RELEASE, "1")
+		b.AddString(RPMTAG_DESCRIPTION, strings.Repeat("foo ", 1024))
+		b.AddString(RPMTAG_ARCH, "x86_64")
+		blob, err := b.Bytes()
+		if err != nil {
+			t.Fatalf("Bytes() error: %v", err)
+		}
+		return blob
+	}
+	create := func(path string, records map[uint32][]byte, order []uint32) {
+		w, err := bdb.Create(path, 4096)
+		if err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+		for _, hnum := range order {
+			key := make([]byte, 4)
+			binary.LittleEndian.PutUint32(key, hnum)
+			if err := w.Put(key, records[hnum]); err != nil {
+				t.Fatalf("Put() error: %v", err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close() error: %v", err)
+		}
+	}
+	index := func(path string, keys map[string][]uint32) {
+		w, err := bdb.Create(path, 4096)
+		if err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+		var sorted []string
+		for key := range keys {
+			sorted = append(sorted, key)
+		}
+		sort.Strings(sorted)
+		for _, key := range sorted {
+			var items []byte
+			for _, hnum := range keys[key] {
+				item := make([]byte, 8)
+				binary.LittleEndian.PutUint32(item, hnum)
+				items = append(items, item...)
+			}
+			if err := w.Put([]byte(key), items); err != nil {
+				t.Fatalf("Put() error: %v", err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close() error: %v", err)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "rpmdb")
+	if err != nil {
+		t.Fatalf("TempDir() error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	// bar was written twice, qux erased from Packages only and baz installed in Packages only
+	create(filepath.Join(dir, "Packages"), map[uint32][]byte{1: header("foo"), 2: header("bar"), 3: header("baz")}, []uint32{1, 2, 2, 3})
+	index(filepath.Join(dir, "Name"), map[string][]uint32{"foo": {1}, "bar": {2}, "qux": {7}})
+	index(filepath.Join(dir, "Basenames"), map[string][]uint32{"foo": {1}, "qux": {7}, "qux.conf": {7}})
+
+	db, err := Open(filepath.Join(dir, "Packages"))
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+	report, err := db.CheckConsistency()
+	if err != nil {
+		t.Fatalf("CheckConsistency() error: %v", err)
+	}
+	var got []string
+	for _, issue := range report.Issues {
+		got = append(got, issue.Problem.String()+": "+issue.String())
+		if issue.Fix == "" {
+			t.Errorf("%s: no fix", issue)
+		}
+	}
+	want := []string{
+		"duplicate-key: instance 2 (bar-1.0-1.x86_64) is recorded 2 times in Packages",
+		`dangling-index-entry: Name references missing instance 7 for 1 keys, "qux" first`,
+		`dangling-index-entry: Basenames references missing instance 7 for 2 keys, "qux" first`,
+		"unindexed-header: instance 3 (baz-1.0-1.x86_64) is missing from the Name index",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CheckConsistency() got %q, want %q", got, want)
+	}
+	if report.Headers != 4 || !reflect.DeepEqual(report.Indexes, []string{"Name", "Basenames"}) {
+		t.Errorf("CheckConsistency() got %d headers, indexes %q", report.Headers, report.Indexes)
+	}
+
+	for _, path := range []string{"testdata/centos7-plain/Packages", "testdata/sqlite-centos7/rpmdb.sqlite"} {
+		db, err := Open(path)
+		if err != nil {
+			t.Fatalf("Open() error: %v", err)
+		}
+		report, err := db.CheckConsistency()
+		db.Close()
+		if err != nil {
+			t.Fatalf("%s: CheckConsistency() error: %v", path, err)
+		}
+		if len(report.Issues) > 0 {
+			t.Errorf("%s: CheckConsistency() got %v", path, report.Issues)
+		}
+	}
+}
+
+func TestWhatProvides(t *testing.T) {
+	vectors := []struct {
+		capability string
+		want       []string
+	}{
+		{capability: "libc.so.6()(64bit)", want: []string{"glibc"}},
+		{capability: "/bin/sh", want: []string{"bash"}},
+		{capability: "/usr/bin/bash", want: []string{"bash"}},
+		{capability: "no-such-capability", want: nil},
+	}
+
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	for _, v := range vectors {
+		t.Run(v.capability, func(t *testing.T) {
+			pkgList, err := db.WhatProvides(v.capability)
+			if err != nil {
+				t.Fatalf("WhatProvides() error: %v", err)
+			}
+
+			var got []string
+			for _, pkg := range pkgList {
+				got = append(got, pkg.Name)
+			}
+			if !reflect.DeepEqual(got, v.want) {
+				t.Errorf("got %v, want %v", got, v.want)
+			}
+		})
+	}
+}
+
+// writeTestIndexes writes the Providename and Basenames index databases of the headers of
+// centos7-plain next to a copy of its Packages file in dir, leaving the keys of skip out. The
+// Basenames keys end with a NUL, like the ones of some rpm versions.
+func writeTestIndexes(t *testing.T, dir string, skip ...string) string {
+	t.Helper()
+	data, err := ioutil.ReadFile("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("ReadFile() error: %v", err)
+	}
+	dbPath := filepath.Join(dir, "Packages")
+	if err := ioutil.WriteFile(dbPath, data, 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	indexes := map[string]map[string][]byte{"Providename": {}, "Basenames": {}}
+	err = db.forEachHeader(func(hnum uint32, blob []byte) error {
+		indexEntries, err := headerImport(blob)
+		if err != nil {
+			return err
+		}
+		for name, tag := range map[string]TAG_ID{"Providename": RPMTAG_PROVIDENAME, "Basenames": RPMTAG_BASENAMES} {
+			keys, err := tagStrings(indexEntries, tag)
+			if err != nil {
+				return err
+			}
+			for i, key := range keys {
+				item := make([]byte, 8)
+				binary.LittleEndian.PutUint32(item, hnum)
+				binary.LittleEndian.PutUint32(item[4:], uint32(i))
+				indexes[name][key] = append(indexes[name][key], item...)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("reading the headers: %v", err)
+	}
+	for _, key := range skip {
+		delete(indexes["Providename"], key)
+		delete(indexes["Basenames"], key)
+	}
+
+	for name, items := range indexes {
+		w, err := bdb.Create(filepath.Join(dir, name), 4096)
+		if err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+		for key, value := range items {
+			k := []byte(key)
+			if name == "Basenames" {
+				k = append(k, 0)
+			}
+			if err := w.Put(k, value); err != nil {
+				t.Fatalf("Put() error: %v", err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close() error: %v", err)
+		}
+	}
+	return dbPath
+}
+
+func TestWhatProvidesIndexed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmdb")
+	if err != nil {
+		t.Fatalf("TempDir() error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	whatProvides := func(dbPath, capability string) []string {
+		db, err := Open(dbPath)
+		if err != nil {
+			t.Fatalf("Open() error: %v", err)
+		}
+		defer db.Close()
+		pkgList, err := db.WhatProvides(capability)
+		if err != nil {
+			t.Fatalf("WhatProvides(%s) error: %v", capability, err)
+		}
+		var names []string
+		for _, pkg := range pkgList {
+			names = append(names, pkg.Name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	indexed := writeTestIndexes(t, dir)
+	db, err := Open(indexed)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	items, ok, err := db.lookupIndex("Basenames", "bash")
+	db.Close()
+	if err != nil || !ok || len(items) == 0 {
+		t.Fatalf("lookupIndex(Basenames, bash): got %v, %v, %v", items, ok, err)
+	}
+	for _, capability := range []string{"libc.so.6()(64bit)", "/bin/sh", "/usr/bin/bash", "bash", "no-such-capability"} {
+		if got, want := whatProvides(indexed, capability), whatProvides("testdata/centos7-plain/Packages", capability); !reflect.DeepEqual(got, want) {
+			t.Errorf("WhatProvides(%s) with indexes: got %v, want %v", capability, got, want)
+		}
+	}
+
+	// the candidates are the ones of the indexes, the headers missing from them aren't read
+	narrowed := filepath.Join(dir, "narrowed")
+	if err := os.Mkdir(narrowed, 0755); err != nil {
+		t.Fatalf("Mkdir() error: %v", err)
+	}
+	writeTestIndexes(t, narrowed, "libc.so.6()(64bit)", "/bin/sh", "sh")
+	for _, capability := range []string{"libc.so.6()(64bit)", "/bin/sh"} {
+		if got := whatProvides(filepath.Join(narrowed, "Packages"), capability); got != nil {
+			t.Errorf("WhatProvides(%s) without index entry: got %v", capability, got)
+		}
+	}
+}
+
+func TestWhoOwns(t *testing.T) {
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	vectors := []struct {
+		file         string
+		want         string // "" for no owner
+		canonicalize bool
+	}{
+		{file: "/usr/bin/bash", want: "bash-4.2.46-30.el7.x86_64"},
+		{file: "/bin/bash", want: ""},
+		{file: "/bin/bash", want: "bash-4.2.46-30.el7.x86_64", canonicalize: true},
+		{file: "//usr/bin/./bash", want: "bash-4.2.46-30.el7.x86_64", canonicalize: true},
+		// glibc records its libraries under the /lib64 symlink
+		{file: "/usr/lib64/libc.so.6", want: ""},
+		{file: "/usr/lib64/libc.so.6", want: "glibc-2.17-222.el7.x86_64", canonicalize: true},
+		{file: "/lib64/libc.so.6", want: "glibc-2.17-222.el7.x86_64", canonicalize: true},
+		// the symlink itself
+		{file: "/bin", want: "filesystem-3.2-25.el7.x86_64", canonicalize: true},
+		{file: "/usr/sbin/", want: "filesystem-3.2-25.el7.x86_64", canonicalize: true},
+		{file: "/usr/bin/nonexistent", want: "", canonicalize: true},
+	}
+	for _, v := range vectors {
+		pkgList, err := db.WhoOwns(v.file, &WhoOwnsOptions{Canonicalize: v.canonicalize})
+		if err != nil {
+			t.Fatalf("WhoOwns(%s) error: %v", v.file, err)
+		}
+		var got string
+		if len(pkgList) == 1 {
+			got = pkgList[0].NEVRA()
+		}
+		if got != v.want || len(pkgList) > 1 {
+			t.Errorf("WhoOwns(%s, %v): got %d packages %q, want %q", v.file, v.canonicalize, len(pkgList), got, v.want)
+		}
+	}
+
+	if _, err := db.WhoOwns("usr/bin/bash", nil); err == nil {
+		t.Errorf("WhoOwns() of a relative path succeeded")
+	}
+
+	links := map[string]string{"/bin": "usr/bin", "/usr/lib64/foo": "../../opt/foo", "/loop": "/loop"}
+	for dir, want := range map[string]string{"/bin": "/usr/bin", "/usr/lib64/foo/bar": "/opt/foo/bar", "/usr": "/usr"} {
+		if got, err := resolveLinks(dir, links); err != nil || got != want {
+			t.Errorf("resolveLinks(%s): got %s, %v, want %s", dir, got, err, want)
+		}
+	}
+	if _, err := resolveLinks("/loop/foo", links); err == nil {
+		t.Errorf("resolveLinks() of a loop succeeded")
+	}
+}
+
+func TestWhoOwnsAll(t *testing.T) {
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	paths := []string{"/bin/bash", "/usr/bin/bash", "/lib64/libc.so.6", "/usr/lib64/libc.so.6", "/usr/bin/", "/etc/passwd", "/usr/share/man", "/usr/bin/nonexistent"}
+	for _, canonicalize := range []bool{false, true} {
+		opts := &WhoOwnsOptions{Canonicalize: canonicalize}
+		owners, err := db.WhoOwnsAll(paths, opts)
+		if err != nil {
+			t.Fatalf("WhoOwnsAll() error: %v", err)
+		}
+		for _, file := range paths {
+			want, err := db.WhoOwns(file, opts)
+			if err != nil {
+				t.Fatalf("WhoOwns(%s) error: %v", file, err)
+			}
+			got := owners[file]
+			if len(got) != len(want) {
+				t.Errorf("%s, %v: got %d owners, want %d", file, canonicalize, len(got), len(want))
+				continue
+			}
+			for i := range got {
+				if got[i].NEVRA() != want[i].NEVRA() {
+					t.Errorf("%s, %v: owner %d: got %s, want %s", file, canonicalize, i, got[i].NEVRA(), want[i].NEVRA())
+				}
+			}
+		}
+		if _, ok := owners["/usr/bin/nonexistent"]; ok {
+			t.Errorf("owners of a nonexistent file")
+		}
+	}
+	// both spellings name the file of the same package
+	owners, err := db.WhoOwnsAll(paths, &WhoOwnsOptions{Canonicalize: true})
+	if err != nil {
+		t.Fatalf("WhoOwnsAll() error: %v", err)
+	}
+	if a, b := owners["/bin/bash"], owners["/usr/bin/bash"]; len(a) != 1 || len(b) != 1 || a[0] != b[0] {
+		t.Errorf("bash owners: got %v and %v", a, b)
+	}
+}
+
+func TestDependencyGraph(t *testing.T) {
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	graph, err := db.DependencyGraph()
+	if err != nil {
+		t.Fatalf("DependencyGraph() error: %v", err)
+	}
+	if len(graph.Nodes) != len(CentOS7Plain) {
+		t.Errorf("node length: got %v, want %v", len(graph.Nodes), len(CentOS7Plain))
+	}
+
+	sorted, err := graph.TopologicalSort()
+	var cycleErr *DependencyCycleError
+	if err != nil && !errors.As(err, &cycleErr) {
+		t.Fatalf("TopologicalSort() error: %v", err)
+	}
+	position := make(map[*DependencyNode]int)
+	for i, node := range sorted {
+		position[node] = i
+	}
+	cycle := make(map[*DependencyNode]int)
+	for i, nodes := range graph.Cycles() {
+		for _, node := range nodes {
+			cycle[node] = i + 1
+		}
+	}
+	for _, node := range graph.Nodes {
+		for _, required := range node.Requires {
+			if position[required] > position[node] && (cycle[node] == 0 || cycle[node] != cycle[required]) {
+				t.Errorf("%s sorted before its requirement %s", node.Package.Name, required.Package.Name)
+			}
+		}
+	}
+
+	leaves := make(map[*DependencyNode]bool)
+	for _, leaf := range graph.Leaves() {
+		if len(leaf.RequiredBy) != 0 {
+			t.Errorf("leaf %s is required", leaf.Package.Name)
+		}
+		leaves[leaf] = true
+	}
+	orphans := make(map[*DependencyNode]bool)
+	for _, orphan := range graph.Orphans() {
+		orphans[orphan] = true
+	}
+	for leaf := range leaves {
+		if !orphans[leaf] {
+			t.Errorf("leaf %s is not an orphan", leaf.Package.Name)
+		}
+	}
+
+	glibc := graph.Lookup("glibc")
+	if len(glibc) != 1 {
+		t.Fatalf("glibc nodes: got %d, want 1", len(glibc))
+	}
+	if orphans[glibc[0]] {
+		t.Errorf("glibc reported as orphan")
+	}
+}
+
+func TestManifest(t *testing.T) {
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	manifest, err := Manifest(db, crypto.SHA1)
+	if err != nil {
+		t.Fatalf("Manifest() error: %v", err)
+	}
+	if len(manifest.Entries) != len(CentOS7Plain) {
+		t.Fatalf("entry length: got %v, want %v", len(manifest.Entries), len(CentOS7Plain))
+	}
+
+	// the digest of the immutable region is what rpm records in SHA1HEADER
+	pkgList, err := db.ListPackagesWithTags(RPMTAG_SHA1HEADER)
+	if err != nil {
+		t.Fatalf("ListPackagesWithTags() error: %v", err)
+	}
+	digests := make(map[string]bool)
+	for _, entry := range manifest.Entries {
+		digests[entry.Digest] = true
+	}
+	for _, pkg := range pkgList {
+		sha1header, ok := pkg.TagsMap[RPMTAG_SHA1HEADER].(string)
+		if !ok {
+			t.Fatalf("%s: missing SHA1HEADER", pkg.Name)
+		}
+		if !digests[sha1header] {
+			t.Errorf("%s: SHA1HEADER %s not in manifest", pkg.Name, sha1header)
+		}
+	}
+
+	again, err := Manifest(db, crypto.SHA1)
+	if err != nil {
+		t.Fatalf("Manifest() error: %v", err)
+	}
+	if again.Digest != manifest.Digest {
+		t.Errorf("digest: got %s, want %s", again.Digest, manifest.Digest)
+	}
+}
+
+func TestManifestLegacyHeaders(t *testing.T) {
+	// the gpg-pubkey header imported by the rpm of CentOS 6 has no region
+	db, err := Open("testdata/centos6-devtools/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	manifest, err := Manifest(db, crypto.SHA256)
+	if err != nil {
+		t.Fatalf("Manifest() error: %v", err)
+	}
+	if len(manifest.Entries) != len(CentOS6DevTools) {
+		t.Errorf("entry length: got %v, want %v", len(manifest.Entries), len(CentOS6DevTools))
+	}
+}
+
+func TestSignatureHeaderImport(t *testing.T) {
+	b := NewHeaderBuilder()
+	b.AddString(RPMTAG_NAME, "foo")
+	blob, err := b.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes() error: %v", err)
+	}
+	header := append(append([]byte{}, headerMagic...), blob...)
+
+	sig, err := signatureHeader(header)
+	if err != nil {
+		t.Fatalf("signatureHeader() error: %v", err)
+	}
+	indexEntries, err := headerImport(sig[len(headerMagic):])
+	if err != nil {
+		t.Fatalf("headerImport() error: %v", err)
+	}
+
+	// RPMSIGTAG_SIZE and RPMSIGTAG_MD5 share their numbers with RPMTAG_NAME and RPMTAG_SUMMARY
+	if entry := findEntry(indexEntries, RPMTAG_NAME); entry != nil {
+		t.Errorf("signature size decoded as %v", entry.Info.Tag)
+	}
+	sizes, err := tagUint32s(indexEntries, RPMTAG_SIGSIZE)
+	if err != nil {
+		t.Fatalf("invalid tag sigsize: %v", err)
+	}
+	if len(sizes) != 1 || sizes[0] != uint32(len(header)) {
+		t.Errorf("sigsize: got %v, want %d", sizes, len(header))
+	}
+	for _, tag := range []TAG_ID{RPMTAG_SIGMD5, RPMTAG_SHA1HEADER, RPMTAG_SHA256HEADER} {
+		if findEntry(indexEntries, tag) == nil {
+			t.Errorf("missing %v", tag)
+		}
+	}
+}
+
+func TestInstallReasons(t *testing.T) {
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+	pkgList, err := db.ListPackages()
+	if err != nil {
+		t.Fatalf("ListPackages() error: %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "yumdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeReason := func(letter, entry, reason string) {
+		path := filepath.Join(dir, letter, entry)
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(path, "reason"), []byte(reason), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeReason("b", "0a1b2c-bash-4.2.46-30.el7-x86_64", "user")
+	writeReason("g", "3d4e5f-glibc-common-2.17-222.el7-x86_64", "dep")
+
+	yumdb, err := ReadYumDB(dir)
+	if err != nil {
+		t.Fatalf("ReadYumDB() error: %v", err)
+	}
+	sidecar, err := ReadInstallReasons(strings.NewReader("# written by hand\nbash-4.2.46-30.el7.x86_64 dep\nzlib-1.2.7-17.el7.x86_64 weak\n"))
+	if err != nil {
+		t.Fatalf("ReadInstallReasons() error: %v", err)
+	}
+	ApplyInstallReasons(pkgList, yumdb, sidecar)
+
+	want := map[string]InstallReason{
+		"bash":         InstallReasonUser,
+		"glibc-common": InstallReasonDependency,
+		"zlib":         InstallReasonWeakDependency,
+		"coreutils":    InstallReasonUnknown,
+	}
+	for _, pkg := range pkgList {
+		if reason, ok := want[pkg.Name]; ok && pkg.InstallReason != reason {
+			t.Errorf("%s: got %v, want %v", pkg.Name, pkg.InstallReason, reason)
+		}
+	}
+
+	b := NewHeaderBuilder()
+	b.AddString(RPMTAG_NAME, "foo")
+	b.AddInt32(RPMTAG_AUTOINSTALLED, 1)
+	blob, err := b.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes() error: %v", err)
+	}
+	indexEntries, err := headerImport(blob)
+	if err != nil {
+		t.Fatalf("headerImport() error: %v", err)
+	}
+	pkg, err := getNEVRA(indexEntries)
+	if err != nil {
+		t.Fatalf("getNEVRA() error: %v", err)
+	}
+	if pkg.InstallReason != InstallReasonDependency {
+		t.Errorf("autoinstalled: got %v, want %v", pkg.InstallReason, InstallReasonDependency)
+	}
+}
+
+func TestEnrichWithDNFHistory(t *testing.T) {
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	histories, err := db.EnrichWithDNFHistory("testdata/dnf-history/history.sqlite")
+	if err != nil {
+		t.Fatalf("EnrichWithDNFHistory() error: %v", err)
+	}
+	if len(histories) != len(CentOS7Plain) {
+		t.Fatalf("length: got %d, want %d", len(histories), len(CentOS7Plain))
+	}
+
+	type record struct {
+		transaction int64
+		cmdLine     string
+		action      string
+		repo        string
+		reason      InstallReason
+	}
+	want := map[string]record{
+		"bash":   {1, "install bash tzdata", "Install", "base", InstallReasonUser},
+		"tzdata": {2, "upgrade tzdata", "Upgrade", "updates", InstallReasonUser},
+		// marked as user installed afterwards
+		"glibc": {1, "install bash tzdata", "Install", "base", InstallReasonUser},
+		// the transaction failed
+		"popt": {},
+	}
+	for _, history := range histories {
+		w, ok := want[history.Package.Name]
+		if !ok {
+			continue
+		}
+		var got record
+		if history.Transaction != nil {
+			got = record{history.Transaction.ID, history.Transaction.CmdLine, history.Action, history.Repo, history.Reason}
+		}
+		if got != w {
+			t.Errorf("%s: got %+v, want %+v", history.Package.Name, got, w)
+		}
+		if history.Package.InstallReason != w.reason {
+			t.Errorf("%s: install reason: got %v, want %v", history.Package.Name, history.Package.InstallReason, w.reason)
+		}
+	}
+}
+
+func TestCheckUpdates(t *testing.T) {
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	base, err := LoadRepository("base", "testdata/repodata/base")
+	if err != nil {
+		t.Fatalf("LoadRepository() error: %v", err)
+	}
+	if len(base.Packages) != 3 {
+		t.Errorf("base: got %d packages, want 3 binary packages", len(base.Packages))
+	}
+	updates, err := LoadRepository("updates", "testdata/repodata/updates/primary.sqlite.bz2")
+	if err != nil {
+		t.Fatalf("LoadRepository() error: %v", err)
+	}
+
+	statuses, err := db.CheckUpdates(base, updates)
+	if err != nil {
+		t.Fatalf("CheckUpdates() error: %v", err)
+	}
+	if len(statuses) != len(CentOS7Plain) {
+		t.Fatalf("length: got %d, want %d", len(statuses), len(CentOS7Plain))
+	}
+
+	type status struct {
+		repo   string
+		update string
+	}
+	want := map[string]status{
+		// the newest of two updates
+		"bash":   {"base", "bash-4.2.46-34.el7.x86_64"},
+		"glibc":  {"base", ""},
+		"tzdata": {"base", "tzdata-2019a-1.el7.noarch"},
+		// only an older version is available
+		"zlib": {"", ""},
+		"popt": {"", ""},
+	}
+	for _, s := range statuses {
+		w, ok := want[s.Package.Name]
+		if !ok {
+			continue
+		}
+		got := status{repo: s.Repo}
+		if s.Update != nil {
+			got.update = s.Update.NEVRA()
+			if s.Update.Repo != "updates" || s.Update.Location != "Packages/"+got.update+".rpm" {
+				t.Errorf("%s: unexpected update origin: %s %s", s.Package.Name, s.Update.Repo, s.Update.Location)
+			}
+		}
+		if got != w {
+			t.Errorf("%s: got %+v, want %+v", s.Package.Name, got, w)
+		}
+	}
+}
+
+func TestComps(t *testing.T) {
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+	pkgList, err := db.ListPackages()
+	if err != nil {
+		t.Fatalf("ListPackages() error: %v", err)
+	}
+
+	comps, err := ReadComps("testdata/repodata/base/repodata/comps.xml")
+	if err != nil {
+		t.Fatalf("ReadComps() error: %v", err)
+	}
+
+	groups := comps.PackageGroups(pkgList)
+	wantGroups := map[string][]string{
+		"bash":   {"core"},
+		"glibc":  {"core"},
+		"tzdata": {"core"},
+		"zlib":   {"gnome-desktop"},
+	}
+	if !reflect.DeepEqual(groups, wantGroups) {
+		t.Errorf("PackageGroups: got %v, want %v", groups, wantGroups)
+	}
+
+	type environment struct {
+		name     string
+		complete bool
+		missing  []string
+	}
+	var got []environment
+	for _, status := range comps.ResolveEnvironments(pkgList) {
+		env := environment{name: status.Environment.Name, complete: status.Complete()}
+		for _, group := range status.Groups {
+			env.missing = append(env.missing, group.Missing...)
+		}
+		got = append(got, env)
+	}
+	want := []environment{
+		{"Minimal Install", true, nil},
+		{"Server with GUI", false, []string{"gnome-shell"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ResolveEnvironments: got %+v, want %+v", got, want)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	header := func(name, version string) *HeaderBuilder {
+		b := NewHeaderBuilder()
+		b.AddString(RPMTAG_NAME, name)
+		b.AddString(RPMTAG_VERSION, version)
+		b.AddString(RPMTAG_RELEASE, "1")
+		b.AddString(RPMTAG_ARCH, "x86_64")
+		b.AddString(RPMTAG_DESCRIPTION, strings.Repeat("foo ", 1024))
+		return b
+	}
+	dir, err := ioutil.TempDir("", "rpmdb")
+	if err != nil {
+		t.Fatalf("TempDir() error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	dbPath := createTestDB(t, dir, header("foo", "1.0"), header("bar", "1.0"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := Watch(ctx, dbPath, &WatchOptions{Settle: 20 * time.Millisecond, PollInterval: 50 * time.Millisecond})
+	next := func() *PackageDelta {
+		t.Helper()
+		select {
+		case delta, ok := <-w.Deltas:
+			if !ok {
+				t.Fatalf("Watch() error: %v", w.Err())
+			}
+			return delta
+		case <-time.After(10 * time.Second):
+			t.Fatalf("no delta")
+		}
+		return nil
+	}
+	nevras := func(pkgs []*PackageInfo) []string {
+		var names []string
+		for _, pkg := range pkgs {
+			names = append(names, pkg.NEVRA())
+		}
+		return names
+	}
+
+	delta := next()
+	if got := nevras(delta.Added); !reflect.DeepEqual(got, []string{"bar-1.0-1.x86_64", "foo-1.0-1.x86_64"}) || len(delta.Removed)+len(delta.Changed) != 0 {
+		t.Errorf("first delta got %v, %+v", got, delta)
+	}
+	if len(delta.Manifest.Entries) != 2 || delta.Manifest.Digest == "" {
+		t.Errorf("Manifest got %+v", delta.Manifest)
+	}
+
+	// rpm --rebuilddb renames the new database over the old one
+	other := filepath.Join(dir, "new")
+	if err := os.Mkdir(other, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(createTestDB(t, other, header("foo", "2.0"), header("baz", "1.0")), dbPath); err != nil {
+		t.Fatal(err)
+	}
+	delta = next()
+	if got := nevras(delta.Added); !reflect.DeepEqual(got, []string{"baz-1.0-1.x86_64"}) {
+		t.Errorf("Added got %v", got)
+	}
+	if got := nevras(delta.Removed); !reflect.DeepEqual(got, []string{"bar-1.0-1.x86_64"}) {
+		t.Errorf("Removed got %v", got)
+	}
+	if len(delta.Changed) != 1 || delta.Changed[0].Before.Version != "1.0" || delta.Changed[0].After.Version != "2.0" {
+		t.Errorf("Changed got %+v", delta.Changed)
+	}
+
+	cancel()
+	for range w.Deltas {
+	}
+	if err := w.Err(); err != context.Canceled {
+		t.Errorf("Err() got %v", err)
+	}
+
+	if err := WatchFunc(context.Background(), filepath.Join(dir, "missing"), nil, func(*PackageDelta) error { return nil }); err == nil {
+		t.Errorf("WatchFunc() of a missing database succeeded")
+	}
+}
+
+func TestScanSnapshotStopsReading(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmdb-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dbPath := filepath.Join(dir, "Packages")
+	w, err := bdb.Create(dbPath, 4096)
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	for i := 0; i < 8; i++ {
+		b := NewHeaderBuilder()
+		b.AddString(RPMTAG_NAME, fmt.Sprintf("foo%d", i))
+		b.AddString(RPMTAG_VERSION, "1.0")
+		b.AddString(RPMTAG_RELEASE, "1")
+		b.AddString(RPMTAG_DESCRIPTION, strings.Repeat("foo ", 1024))
+		blob, err := b.Bytes()
+		if err != nil {
+			t.Fatalf("Bytes() error: %v", err)
+		}
+		// an index length past every other header, the rescan fails at the first read
+		if i%2 == 0 {
+			binary.BigEndian.PutUint32(blob, 1000)
+		}
+		key := make([]byte, 4)
+		binary.LittleEndian.PutUint32(key, uint32(i+1))
+		if err := w.Put(key, blob); err != nil {
+			t.Fatalf("Put() error: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	checkGoroutines(t, func() {
+		for i := 0; i < 20; i++ {
+			if _, err := scanSnapshot(dbPath, nil); err == nil {
+				t.Fatalf("scanSnapshot() of a damaged database: expected an error")
+			}
+		}
+	})
+}
+
+func TestWatchEvents(t *testing.T) {
+	header := func(name, version string, installed uint32) *HeaderBuilder {
+		b := NewHeaderBuilder()
+		b.AddString(RPMTAG_NAME, name)
+		b.AddString(RPMTAG_VERSION, version)
+		b.AddString(RPMTAG_RELEASE, "1")
+		b.AddString(RPMTAG_ARCH, "x86_64")
+		b.AddInt32(RPMTAG_INSTALLTIME, installed)
+		b.AddInt32(RPMTAG_INSTALLTID, installed)
+		b.AddString(RPMTAG_DESCRIPTION, strings.Repeat("foo ", 1024))
+		return b
+	}
+	dir, err := ioutil.TempDir("", "rpmdb")
+	if err != nil {
+		t.Fatalf("TempDir() error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	dbPath := createTestDB(t, dir, header("foo", "1.0", 1000), header("bar", "1.0", 2000))
+
+	// the first batch of a watch, the sink stopping it
+	firstBatch := func(opts *EventOptions) ([]*PackageEvent, *PackageManifest) {
+		t.Helper()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var events []*PackageEvent
+		var manifest *PackageManifest
+		err := WatchEvents(ctx, dbPath, opts, EventSinkFunc(func(e []*PackageEvent, m *PackageManifest) error {
+			events, manifest = e, m
+			cancel()
+			return nil
+		}))
+		if err != context.Canceled {
+			t.Fatalf("WatchEvents() error: %v", err)
+		}
+		return events, manifest
+	}
+
+	events, manifest := firstBatch(&EventOptions{Since: time.Unix(1500, 0)})
+	if len(events) != 1 || events[0].Type != PackageInstalled || events[0].Name != "bar" || events[0].After != "1.0-1" ||
+		events[0].TID != 2000 || !events[0].Time.Equal(time.Unix(2000, 0)) || !events[0].Backfilled {
+		t.Errorf("events since got %+v", events)
+	}
+	if events, _ := firstBatch(nil); len(events) != 0 {
+		t.Errorf("events without backfill got %+v", events)
+	}
+
+	// the agent is down while foo is upgraded, baz installed and bar erased
+	other := filepath.Join(dir, "new")
+	if err := os.Mkdir(other, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(createTestDB(t, other, header("foo", "2.0", 3000), header("baz", "1.0", 3000)), dbPath); err != nil {
+		t.Fatal(err)
+	}
+	events, _ = firstBatch(&EventOptions{WatchOptions: WatchOptions{Previous: manifest}})
+	var got []string
+	for _, event := range events {
+		got = append(got, fmt.Sprintf("%s %s %s %s %d %v", event.Type, event.Name, event.Before, event.After, event.TID, event.Backfilled))
+	}
+	want := []string{"installed baz  1.0-1 3000 true", "upgraded foo 1.0-1 2.0-1 3000 true", "removed bar 1.0-1  0 true"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("backfilled events got %q, want %q", got, want)
+	}
+
+	var buf bytes.Buffer
+	if err := NewJSONEventSink(&buf).WriteEvents(events[1:2], nil); err != nil {
+		t.Fatalf("WriteEvents() error: %v", err)
+	}
+	if want := `{"type":"upgraded","time":"1970-01-01T00:50:00Z","name":"foo","arch":"x86_64","before":"1.0-1","after":"2.0-1","tid":3000,"backfilled":true}` + "\n"; buf.String() != want {
+		t.Errorf("JSON event got %s, want %s", buf.String(), want)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	db, err := Open("testdata/centos7-httpd24/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+	want, err := db.ListPackages()
+	if err != nil {
+		t.Fatalf("ListPackages() error: %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "rpmdb")
+	if err != nil {
+		t.Fatalf("TempDir() error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "packages.snapshot")
+	if err := db.SaveSnapshot(path); err != nil {
+		t.Fatalf("SaveSnapshot() error: %v", err)
+	}
+	got, err := db.LoadSnapshot(path)
+	if err != nil {
+		t.Fatalf("LoadSnapshot() error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadSnapshot() got other packages than ListPackages()")
+	}
+	signed := 0
+	for _, pkg := range got {
+		if pkg.SignedBy == nil || pkg.SignedBy.PubKey == nil {
+			continue
+		}
+		signed++
+		found := false
+		for _, pubKey := range got {
+			found = found || pubKey == pkg.SignedBy.PubKey
+		}
+		if !found {
+			t.Errorf("%s: PubKey not relinked to the gpg-pubkey package", pkg.NEVRA())
+		}
+	}
+	if signed == 0 {
+		t.Errorf("no signed package")
+	}
+
+	b := NewHeaderBuilder()
+	b.AddString(RPMTAG_NAME, "foo")
+	b.AddString(RPMTAG_VERSION, "1.0")
+	b.AddString(RPMTAG_RELEASE, "1")
+	b.AddString(RPMTAG_DESCRIPTION, strings.Repeat("foo ", 1024))
+	other, err := Open(createTestDB(t, dir, b))
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer other.Close()
+	if _, err := other.LoadSnapshot(path); err != ErrStaleSnapshot {
+		t.Errorf("LoadSnapshot() of another database got %v", err)
+	}
+	if err := ioutil.WriteFile(path, []byte("garbage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.LoadSnapshot(path); err == nil {
+		t.Errorf("LoadSnapshot() of garbage succeeded")
+	}
+}
+
+func TestCompareToManifest(t *testing.T) {
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	manifest, err := Manifest(db, crypto.SHA256)
+	if err != nil {
+		t.Fatalf("Manifest() error: %v", err)
+	}
+	parsed, err := ParseManifest(manifest.Bytes())
+	if err != nil {
+		t.Fatalf("ParseManifest() error: %v", err)
+	}
+	if !reflect.DeepEqual(parsed, manifest) {
+		t.Errorf("ParseManifest() round trip mismatch")
+	}
+	diff, err := CompareToManifest(db, parsed)
+	if err != nil {
+		t.Fatalf("CompareToManifest() error: %v", err)
+	}
+	if !diff.Empty() {
+		t.Errorf("CompareToManifest() against itself: %+v", diff)
+	}
+
+	// a golden image without bash, with an extra package, an older glibc and a rebuilt zlib
+	var golden bytes.Buffer
+	zero := strings.Repeat("0", 64)
+	for _, line := range strings.SplitAfter(string(manifest.Bytes()), "\n") {
+		switch {
+		case strings.HasPrefix(line, "bash-4.2.46-30.el7.x86_64 "):
+			continue
+		case strings.HasPrefix(line, "glibc-2.17-222.el7.x86_64 "):
+			line = strings.Replace(line, "222.el7", "221.el7", 1)
+		case strings.HasPrefix(line, "zlib-1.2.7-17.el7.x86_64 "):
+			line = "zlib-1.2.7-17.el7.x86_64 sha256:" + zero + "\n"
+		}
+		golden.WriteString(line)
+	}
+	golden.WriteString("foo-1.0-1.el7.noarch sha256:" + zero + "\n")
+
+	expected, err := ParseManifest(golden.Bytes())
+	if err != nil {
+		t.Fatalf("ParseManifest() error: %v", err)
+	}
+	diff, err = CompareToManifest(db, expected)
+	if err != nil {
+		t.Fatalf("CompareToManifest() error: %v", err)
+	}
+
+	var added, removed, drifted []string
+	for _, entry := range diff.Added {
+		added = append(added, entry.NEVRA)
+	}
+	for _, entry := range diff.Removed {
+		removed = append(removed, entry.NEVRA)
+	}
+	for _, drift := range diff.Drifted {
+		drifted = append(drifted, drift.Expected.NEVRA+" -> "+drift.Actual.NEVRA)
+	}
+	if want := []string{"bash-4.2.46-30.el7.x86_64"}; !reflect.DeepEqual(added, want) {
+		t.Errorf("added: got %v, want %v", added, want)
+	}
+	if want := []string{"foo-1.0-1.el7.noarch"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed: got %v, want %v", removed, want)
+	}
+	want := []string{
+		"glibc-2.17-221.el7.x86_64 -> glibc-2.17-222.el7.x86_64",
+		"zlib-1.2.7-17.el7.x86_64 -> zlib-1.2.7-17.el7.x86_64",
+	}
+	if !reflect.DeepEqual(drifted, want) {
+		t.Errorf("drifted: got %v, want %v", drifted, want)
+	}
+
+	if _, err := ParseManifest([]byte("foo-1.0-1.el7.noarch sha256:" + zero + "\nbar-1.0-1.el7.noarch sha1:" + zero[:40] + "\n")); err == nil {
+		t.Errorf("ParseManifest() mixed algorithms: expected an error")
+	}
+}
+
+func TestExportFileOwners(t *testing.T) {
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	var ndjson bytes.Buffer
+	if err := db.ExportFileOwners(&ndjson); err != nil {
+		t.Fatalf("ExportFileOwners() error: %v", err)
+	}
+	ndjsonBytes := append([]byte(nil), ndjson.Bytes()...)
+	ndjsonSize := ndjson.Len()
+	var owners []FileOwner
+	decoder := json.NewDecoder(&ndjson)
+	for decoder.More() {
+		var owner FileOwner
+		if err := decoder.Decode(&owner); err != nil {
+			t.Fatalf("Decode() error: %v", err)
+		}
+		owners = append(owners, owner)
+	}
+	if len(owners) == 0 {
+		t.Fatalf("ExportFileOwners() wrote no files")
+	}
+	for i := 1; i < len(owners); i++ {
+		if owners[i-1].Path > owners[i].Path {
+			t.Fatalf("not sorted: %s > %s", owners[i-1].Path, owners[i].Path)
+		}
+	}
+	var bash *FileOwner
+	for i := range owners {
+		if owners[i].Path == "/usr/bin/bash" {
+			bash = &owners[i]
+		}
+	}
+	if bash == nil || bash.NEVRA != "bash-4.2.46-30.el7.x86_64" || bash.Algo != "sha256" || len(bash.Digest) != 64 {
+		t.Errorf("/usr/bin/bash: got %+v", bash)
+	}
+
+	var index bytes.Buffer
+	if err := db.ExportFileOwnersIndex(&index); err != nil {
+		t.Fatalf("ExportFileOwnersIndex() error: %v", err)
+	}
+	if index.Len()*4 > ndjsonSize {
+		t.Errorf("index is not compact: %d bytes", index.Len())
+	}
+	indexBytes := append([]byte(nil), index.Bytes()...)
+	x, err := ReadFileOwnersIndex(&index)
+	if err != nil {
+		t.Fatalf("ReadFileOwnersIndex() error: %v", err)
+	}
+	if x.Len() != len(owners) {
+		t.Errorf("Len(): got %d, want %d", x.Len(), len(owners))
+	}
+	if got, want := x.Lookup("/usr/bin/bash"), []string{"bash-4.2.46-30.el7.x86_64"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Lookup(/usr/bin/bash): got %v, want %v", got, want)
+	}
+	if got := x.Lookup("/usr/bin/nope"); got != nil {
+		t.Errorf("Lookup(/usr/bin/nope): got %v", got)
+	}
+	if _, err := ReadFileOwnersIndex(strings.NewReader("not an index")); err == nil {
+		t.Errorf("ReadFileOwnersIndex() garbage: expected an error")
+	}
+
+	// a budget far below the size of the files spills runs, merged back into the same exports
+	spilled, err := Open("testdata/centos7-plain/Packages", WithMemoryBudget(64<<10))
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer spilled.Close()
+	set, err := spilled.fileOwners()
+	if err != nil {
+		t.Fatalf("fileOwners() error: %v", err)
+	}
+	if len(set.spill.runs) < 2 {
+		t.Errorf("WithMemoryBudget() spilled %d runs", len(set.spill.runs))
+	}
+	spillName := set.spill.f.Name()
+	set.Close()
+	if _, err := os.Stat(spillName); !os.IsNotExist(err) {
+		t.Errorf("spill file left: %v", err)
+	}
+	var spilledNDJSON, spilledIndex bytes.Buffer
+	if err := spilled.ExportFileOwners(&spilledNDJSON); err != nil {
+		t.Fatalf("ExportFileOwners() spilled error: %v", err)
+	}
+	if !bytes.Equal(spilledNDJSON.Bytes(), ndjsonBytes) {
+		t.Errorf("ExportFileOwners() spilled differs")
+	}
+	if err := spilled.ExportFileOwnersIndex(&spilledIndex); err != nil {
+		t.Fatalf("ExportFileOwnersIndex() spilled error: %v", err)
+	}
+	if !bytes.Equal(spilledIndex.Bytes(), indexBytes) {
+		t.Errorf("ExportFileOwnersIndex() spilled differs")
+	}
+}
+
+func TestWriteMtree(t *testing.T) {
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	var buf bytes.Buffer
+	if err := db.WriteMtree(&buf, "bash"); err != nil {
+		t.Fatalf("WriteMtree() error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if lines[0] != "#mtree" {
+		t.Errorf("header: got %q", lines[0])
+	}
+	for _, want := range []string{
+		"./usr/bin/bash type=file size=964544 sha256digest=2f83ab3f0ee1257ff84dc3f80f9324edb1175df3ddfd7fe8ef0c968496f27b5d mode=0755 uname=root gname=root time=1523407981.000000000",
+		"./usr/bin/bashbug type=link link=bashbug-64 mode=0777 uname=root gname=root time=1523407981.000000000",
+	} {
+		found := false
+		for _, line := range lines {
+			found = found || line == want
+		}
+		if !found {
+			t.Errorf("missing line %q", want)
+		}
+	}
+	for i := 2; i < len(lines); i++ {
+		if lines[i-1] >= lines[i] {
+			t.Fatalf("not sorted: %q >= %q", lines[i-1], lines[i])
+		}
+	}
+
+	vectors := []struct {
+		file FileInfo
+		want string
+	}{
+		{
+			file: FileInfo{Path: "/dev/null", Mode: 020666, Rdev: 0x0103, Username: "root", Groupname: "root"},
+			want: "./dev/null type=char device=native,1,3 mode=0666 uname=root gname=root time=0.000000000",
+		},
+		{
+			file: FileInfo{Path: "/var/log/my log#1", Mode: 0100600, Flags: RPMFILE_GHOST},
+			want: `./var/log/my\040log\0431 type=file size=0 mode=0600 time=0.000000000 optional`,
+		},
+		{
+			file: FileInfo{Path: "/usr/bin/passwd", Mode: 0104755, Size: 1, Digest: "00"},
+			want: "./usr/bin/passwd type=file size=1 mode=4755 time=0.000000000",
+		},
+	}
+	for _, v := range vectors {
+		if got := mtreeLine(v.file, PGPHASHALGO_SHA224); got != v.want {
+			t.Errorf("mtreeLine(%s): got %q, want %q", v.file.Path, got, v.want)
+		}
+	}
+}
+
+func TestPrivilegedFiles(t *testing.T) {
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	report, err := db.PrivilegedFiles()
+	if err != nil {
+		t.Fatalf("PrivilegedFiles() error: %v", err)
+	}
+	got := make(map[string][]string)
+	var order []string
+	for _, pkg := range report {
+		order = append(order, pkg.Package.Name)
+		for _, file := range pkg.Files {
+			flags := ""
+			if file.Setuid {
+				flags += "u"
+			}
+			if file.Setgid {
+				flags += "g"
+			}
+			got[pkg.Package.Name] = append(got[pkg.Package.Name], file.File.Path+" "+flags+" "+file.Caps)
+		}
+	}
+
+	if want := []string{"dbus", "iputils", "libutempter", "pam", "passwd", "shadow-utils", "util-linux"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("packages: got %v, want %v", order, want)
+	}
+	want := map[string][]string{
+		"iputils": {
+			"/usr/bin/ping  = cap_net_admin,cap_net_raw+p",
+			"/usr/sbin/arping  = cap_net_raw+p",
+			"/usr/sbin/clockdiff  = cap_net_raw+p",
+		},
+		"libutempter": {"/usr/libexec/utempter/utempter g "},
+		"util-linux": {
+			"/usr/bin/chfn u ",
+			"/usr/bin/chsh u ",
+			"/usr/bin/mount u ",
+			"/usr/bin/su u ",
+			"/usr/bin/umount u ",
+			"/usr/bin/write g ",
+		},
+	}
+	for name, files := range want {
+		if !reflect.DeepEqual(got[name], files) {
+			t.Errorf("%s: got %q, want %q", name, got[name], files)
+		}
+	}
+}
+
+func TestConfigFiles(t *testing.T) {
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	configs, err := db.ConfigFiles()
+	if err != nil {
+		t.Fatalf("ConfigFiles() error: %v", err)
+	}
+	var bashrc *ConfigFile
+	for i, config := range configs {
+		if i > 0 && configs[i-1].File.Path > config.File.Path {
+			t.Fatalf("not sorted: %s > %s", configs[i-1].File.Path, config.File.Path)
+		}
+		if config.File.Flags&RPMFILE_CONFIG == 0 {
+			t.Errorf("%s is not a config file", config.File.Path)
+		}
+		if config.File.Path == "/etc/skel/.bashrc" {
+			bashrc = config
+		}
+	}
+	if bashrc == nil {
+		t.Fatalf("/etc/skel/.bashrc not listed")
+	}
+	if bashrc.Package.Name != "bash" || !bashrc.NoReplace || bashrc.Algo != PGPHASHALGO_SHA256 ||
+		bashrc.File.Digest != "898ed3bdcb749c665866ee2750ab50d7ac5da6b666546fcd952cfc4cbc0c33b4" {
+		t.Errorf("/etc/skel/.bashrc: got %+v", bashrc)
+	}
+}
+
+func TestLocaleUsage(t *testing.T) {
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	usages, err := db.LocaleUsage(&LangFilter{Include: []string{"de_DE.UTF-8"}})
+	if err != nil {
+		t.Fatalf("LocaleUsage() error: %v", err)
+	}
+	if usages[0].Package.Name != "coreutils" {
+		t.Errorf("largest: got %s, want coreutils", usages[0].Package.Name)
+	}
+	var bash *LocaleUsage
+	for _, usage := range usages {
+		if usage.Package.Name == "bash" {
+			bash = usage
+		}
+	}
+	if bash == nil {
+		t.Fatalf("bash not listed")
+	}
+	if bash.Total != 2454202 || bash.Removable != 2454202-45776 || bash.Sizes["de"] != 45776 || len(bash.Sizes) != 28 {
+		t.Errorf("bash: got total %d, removable %d, de %d, %d languages", bash.Total, bash.Removable, bash.Sizes["de"], len(bash.Sizes))
+	}
+
+	vectors := []struct {
+		filter *LangFilter
+		lang   string
+		want   bool
+	}{
+		{nil, "de", true},
+		{&LangFilter{Include: []string{"en_US"}}, "", true},
+		{&LangFilter{Include: []string{"en_US"}}, "en", true},
+		{&LangFilter{Include: []string{"en_US"}}, "en_GB", false},
+		{&LangFilter{Include: []string{"en"}}, "en_GB", false},
+		{&LangFilter{Include: []string{"fr_FR"}}, "de|fr", true},
+		{&LangFilter{Include: []string{"all"}}, "ja", true},
+		{&LangFilter{Exclude: []string{"ja_JP"}}, "ja", false},
+		{&LangFilter{Exclude: []string{"ja_JP"}}, "ko", true},
+	}
+	for _, v := range vectors {
+		if got := v.filter.Match(FileInfo{Lang: v.lang}); got != v.want {
+			t.Errorf("%+v.Match(%q): got %v, want %v", v.filter, v.lang, got, v.want)
+		}
+	}
+}
+
+func TestDiskUsage(t *testing.T) {
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	usages, err := db.DiskUsage(1)
+	if err != nil {
+		t.Fatalf("DiskUsage() error: %v", err)
+	}
+	if usages[0].Package.Name != "glibc-common" {
+		t.Errorf("largest: got %s, want glibc-common", usages[0].Package.Name)
+	}
+	// the image was installed with nodocs and en_US only, the docs and locales of bash are not there
+	want := map[string]int64{"/etc": 442, "/usr": 971847}
+	for _, usage := range usages {
+		if usage.Package.Name == "bash" && (!reflect.DeepEqual(usage.Dirs, want) || usage.Total != 972289) {
+			t.Errorf("bash: got %v (%d), want %v", usage.Dirs, usage.Total, want)
+		}
+	}
+
+	usages, err = db.DiskUsage(2)
+	if err != nil {
+		t.Fatalf("DiskUsage() error: %v", err)
+	}
+	want = map[string]int64{"/etc/skel": 442, "/usr/bin": 971847}
+	for _, usage := range usages {
+		if usage.Package.Name == "bash" && !reflect.DeepEqual(usage.Dirs, want) {
+			t.Errorf("bash at depth 2: got %v, want %v", usage.Dirs, want)
+		}
+	}
+
+	for file, want := range map[string]string{"/usr/bin/bash": "/usr", "/vmlinuz": "/", "/usr": "/"} {
+		if got := usageDir(file, 1); got != want {
+			t.Errorf("usageDir(%s): got %s, want %s", file, got, want)
+		}
+	}
+}
+
+func TestProvenance(t *testing.T) {
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	provenances, err := db.Provenance()
+	if err != nil {
+		t.Fatalf("Provenance() error: %v", err)
+	}
+	var bash *Provenance
+	for _, p := range provenances {
+		if p.Package.Name == "bash" {
+			bash = p
+		}
+	}
+	if bash == nil {
+		t.Fatalf("bash not listed")
+	}
+	if bash.BuildHost != "x86-01.bsys.centos.org" || bash.BuildTime.Unix() != 1523408122 || bash.RPMVersion != "4.11.3" ||
+		bash.Platform != "x86_64-redhat-linux-gnu" || !strings.Contains(bash.OptFlags, "-fstack-protector-strong") || bash.VCS != "" {
+		t.Errorf("bash: got %+v", bash)
+	}
+
+	issues, err := db.ProvenanceReport(&ProvenancePolicy{BuildHosts: []string{"*.bsys.centos.org"}})
+	if err != nil {
+		t.Fatalf("ProvenanceReport() error: %v", err)
+	}
+	if len(issues) != 28 || issues[0].Provenance.Package.Name != "ca-certificates" || !issues[0].UnexpectedHost {
+		t.Errorf("unexpected hosts: got %d issues", len(issues))
+	}
+
+	issues, err = db.ProvenanceReport(&ProvenancePolicy{
+		BuildHosts: []string{"*.centos.org"},
+		OptFlags:   []string{"-fstack-protector-strong", "-fstack-clash-protection"},
+	})
+	if err != nil {
+		t.Fatalf("ProvenanceReport() error: %v", err)
+	}
+	for _, issue := range issues {
+		p := issue.Provenance
+		if issue.UnexpectedHost || p.Package.Arch == "noarch" || !reflect.DeepEqual(issue.MissingOptFlags, []string{"-fstack-clash-protection"}) {
+			t.Errorf("%s: got host %v, missing %v", p.Package.NEVRA(), issue.UnexpectedHost, issue.MissingOptFlags)
+		}
+	}
+	if len(issues) == 0 {
+		t.Errorf("missing optflags: got no issues")
+	}
+}
+
+func TestTrust(t *testing.T) {
+	levels := func(path string, opts ...Option) map[string]int {
+		db, err := Open(path, opts...)
+		if err != nil {
+			t.Fatalf("Open() error: %v", err)
+		}
+		defer db.Close()
+		trusts, err := db.Trust()
+		if err != nil {
+			t.Fatalf("Trust() error: %v", err)
+		}
+		got := make(map[string]int)
+		for _, trust := range trusts {
+			if trust.Package.Name == "gpg-pubkey" {
+				t.Errorf("gpg-pubkey listed")
+			}
+			got[trust.Level.String()+": "+trust.Reason]++
+		}
+		return got
+	}
+
+	// the CentOS 7 key is imported, it verifies the RSA signatures of every header
+	if got := levels("testdata/centos7-many/Packages"); !reflect.DeepEqual(got, map[string]int{"signature: ": len(CentOS7Many) - 1}) {
+		t.Errorf("centos7-many: got %v", got)
+	}
+	if got := levels("testdata/centos7-plain/Packages"); !reflect.DeepEqual(got, map[string]int{"digest: key 24c6a8a7f4a80eb5 not imported": len(CentOS7Plain)}) {
+		t.Errorf("centos7-plain: got %v", got)
+	}
+	// redacted headers are re-encoded
+	if got := levels("testdata/centos7-many/Packages", WithRedaction()); !reflect.DeepEqual(got, map[string]int{"region: SHA1HEADER mismatch": len(CentOS7Many) - 1}) {
+		t.Errorf("centos7-many redacted: got %v", got)
+	}
+
+	dir, err := ioutil.TempDir("", "rpmdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	b := NewHeaderBuilder()
+	b.AddString(RPMTAG_NAME, "foo")
+	b.AddString(RPMTAG_VERSION, "1.0")
+	b.AddString(RPMTAG_RELEASE, "1")
+	// large enough for an overflow page, where rpm stores the headers
+	b.AddString(RPMTAG_DESCRIPTION, strings.Repeat("foo ", 1024))
+	if got := levels(createTestDB(t, dir, b)); !reflect.DeepEqual(got, map[string]int{"region: no header digest": 1}) {
+		t.Errorf("built header: got %v", got)
+	}
+
+	// a signature doesn't verify the digest of another header
+	db, err := Open("testdata/centos7-many/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+	var keys []*pgpPublicKey
+	var sigs []*pgpSignature
+	var digests [][]byte
+	err = db.forEachHeader(func(hnum uint32, blob []byte) error {
+		indexEntries, err := headerImport(blob)
+		if err != nil {
+			return err
+		}
+		if pkg, _ := getNEVRA(indexEntries); pkg.Name == "gpg-pubkey" {
+			keys, err = headerPublicKeys(indexEntries)
+			return err
+		}
+		sig, digest := headerTrust(&PackageTrust{}, blob, indexEntries)
+		if sig != nil && len(sigs) < 2 {
+			sigs, digests = append(sigs, sig), append(digests, digest)
+		}
+		return nil
+	})
+	if err != nil || len(keys) == 0 || len(sigs) != 2 {
+		t.Fatalf("got %d keys and %d signatures, error %v", len(keys), len(sigs), err)
+	}
+	if err := sigs[0].verify(keys[0], digests[1]); err == nil {
+		t.Errorf("verify() of another digest: got no error")
+	}
+}
+
+// testSignaturePacket returns a new format signature packet of the body.
+func testSignaturePacket(body ...[]byte) []byte {
+	var b []byte
+	for _, part := range body {
+		b = append(b, part...)
+	}
+	return append([]byte{0xc0 | pgpTagSignature, byte(len(b))}, b...)
+}
+
+// testV4Signature returns the body of a v4 RSA signature with the given hashed and unhashed
+// subpackets, each a type followed by its data.
+func testV4Signature(hashed, unhashed [][]byte) []byte {
+	subpackets := func(list [][]byte) []byte {
+		var b []byte
+		for _, subpacket := range list {
+			b = append(b, byte(len(subpacket)))
+			b = append(b, subpacket...)
+		}
+		return append([]byte{0, byte(len(b))}, b...)
+	}
+	body := []byte{4, 0x00, 1, 8}
+	body = append(body, subpackets(hashed)...)
+	body = append(body, subpackets(unhashed)...)
+	// the left 16 bits of the digest and the MPI of the signature
+	return append(body, 0xab, 0xcd, 0x00, 0x08, 0xff)
+}
+
+func TestSignatureKeyID(t *testing.T) {
+	keyID, _ := hex.DecodeString("24c6a8a7f4a80eb5")
+	fingerprint, _ := hex.DecodeString("6341ab2753d78a78a7c27bb124c6a8a7f4a80eb5")
+	creation := []byte{2, 0x53, 0xa7, 0xff, 0x4b}
+
+	v3 := append([]byte{3, 5, 0x00, 0x53, 0xa7, 0xff, 0x4b}, keyID...)
+	v3 = append(v3, 1, 8, 0xab, 0xcd, 0x00, 0x08, 0xff)
+	// an old format packet of a one byte length
+	oldV3 := append([]byte{0x80 | pgpTagSignature<<2, byte(len(v3))}, v3...)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    string
+		wantErr bool
+	}{
+		{name: "v3", data: oldV3, want: "24c6a8a7f4a80eb5"},
+		{name: "v4 issuer", data: testSignaturePacket(testV4Signature([][]byte{creation, append([]byte{pgpSubpacketIssuer}, keyID...)}, nil)), want: "24c6a8a7f4a80eb5"},
+		{name: "v4 unhashed issuer", data: testSignaturePacket(testV4Signature([][]byte{creation}, [][]byte{append([]byte{pgpSubpacketIssuer}, keyID...)})), want: "24c6a8a7f4a80eb5"},
+		{name: "v4 issuer fingerprint", data: testSignaturePacket(testV4Signature([][]byte{creation, append([]byte{pgpSubpacketIssuerFingerprint, 4}, fingerprint...)}, nil)), want: "24c6a8a7f4a80eb5"},
+		{name: "v4 without issuer", data: testSignaturePacket(testV4Signature([][]byte{creation}, nil)), wantErr: true},
+		{name: "v4 truncated subpackets", data: testSignaturePacket([]byte{4, 0x00, 1, 8, 0, 40, 5, 2}), wantErr: true},
+		{name: "short v3", data: testSignaturePacket(v3[:10]), wantErr: true},
+		{name: "unsupported version", data: testSignaturePacket([]byte{5, 0x00, 1, 8}), wantErr: true},
+		{name: "public key packet", data: []byte{0xc0 | 6, 1, 4}, wantErr: true},
+		{name: "truncated packet", data: oldV3[:len(oldV3)-1], wantErr: true},
+		{name: "not a packet", data: []byte{0x00, 0x01}, wantErr: true},
+		{name: "empty", data: nil, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := pgpSignatureKeyID(tt.data)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: got %s, want an error", tt.name, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: got %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestSignedBy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmdb")
+	if err != nil {
+		t.Fatalf("TempDir() error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	header := func(name, version string, signature []byte) *HeaderBuilder {
+		b := NewHeaderBuilder()
+		b.AddString(RPMTAG_NAME, name)
+		b.AddString(RPMTAG_VERSION, version)
+		b.AddString(RPMTAG_RELEASE, "1")
+		b.AddString(RPMTAG_DESCRIPTION, strings.Repeat("foo ", 1024))
+		if signature != nil {
+			b.AddBinary(RPMTAG_RSAHEADER, signature)
+		}
+		return b
+	}
+	signature := func(keyID string) []byte {
+		id, _ := hex.DecodeString(keyID)
+		return testSignaturePacket(testV4Signature([][]byte{append([]byte{pgpSubpacketIssuer}, id...)}, nil))
+	}
+	db, err := Open(createTestDB(t, dir,
+		header("gpg-pubkey", "f4a80eb5", nil),
+		header("unsigned", "1.0", nil),
+		header("imported", "1.0", signature("24c6a8a7f4a80eb5")),
+		header("missing", "1.0", signature("199e2f91fd431d51")),
+		header("undecodable", "1.0", []byte{0xc0 | 6, 1, 4}),
+	))
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+	pkgList, err := db.ListPackages()
+	if err != nil {
+		t.Fatalf("ListPackages() error: %v", err)
+	}
+	byName := make(map[string]*PackageInfo)
+	for _, pkg := range pkgList {
+		byName[pkg.Name] = pkg
+	}
+
+	if ref := byName["unsigned"].SignedBy; ref != nil {
+		t.Errorf("unsigned: got SignedBy %+v", ref)
+	}
+	if ref := byName["imported"].SignedBy; ref == nil || ref.KeyID != "24c6a8a7f4a80eb5" || !ref.Imported() || ref.PubKey != byName["gpg-pubkey"] {
+		t.Errorf("signed by an imported key: got %+v", ref)
+	}
+	if ref := byName["missing"].SignedBy; ref == nil || ref.KeyID != "199e2f91fd431d51" || ref.Imported() {
+		t.Errorf("signed by a key missing from the database: got %+v", ref)
+	}
+	if ref := byName["undecodable"].SignedBy; ref == nil || ref.KeyID != "" || ref.Imported() {
+		t.Errorf("undecodable signature: got %+v", ref)
+	}
+}
+
+func TestKeyring(t *testing.T) {
+	// the fingerprints the distributions publish for the keys taken from the test databases
+	fingerprints := map[string]string{
+		"RPM-GPG-KEY-CentOS-6":        "c1dac52d1664e8a4386dba430946fca2c105b9de",
+		"RPM-GPG-KEY-CentOS-7":        "6341ab2753d78a78a7c27bb124c6a8a7f4a80eb5",
+		"RPM-GPG-KEY-CentOS-SIG-SCLo": "c4dbd535b1fbba14f8ba64a84eb84e71f2ee9d55",
+		"RPM-GPG-KEY-EPEL-7":          "91e97d7c4a5e96f17f3e888f6a2faea2352c64e5",
+	}
+	for _, key := range distroKeys {
+		k := NewKeyring()
+		if err := k.Add([]byte(key.armored)); err != nil || len(k.KeyIDs()) == 0 {
+			t.Errorf("bundled key %s: got %v, %v", key.name, k.KeyIDs(), err)
+			continue
+		}
+		fingerprint, ok := fingerprints[key.name]
+		if !ok {
+			t.Errorf("bundled key %s: no fingerprint to check", key.name)
+			continue
+		}
+		if primary := k.keys[fingerprint[24:]]; primary == nil || primary.fingerprint != fingerprint {
+			t.Errorf("bundled key %s: got %v, want fingerprint %s", key.name, primary, fingerprint)
+		}
+	}
+	keyring, err := DistroKeyring()
+	if err != nil {
+		t.Fatalf("DistroKeyring() error: %v", err)
+	}
+	keyIDs := keyring.KeyIDs()
+	for _, keyID := range []string{"0946fca2c105b9de", "24c6a8a7f4a80eb5"} {
+		if i := sort.SearchStrings(keyIDs, keyID); i == len(keyIDs) || keyIDs[i] != keyID {
+			t.Errorf("DistroKeyring(): %s missing from %v", keyID, keyIDs)
+		}
+	}
+
+	trust := func(path string, k *Keyring) map[string]int {
+		db, err := Open(path, WithKeyring(k))
+		if err != nil {
+			t.Fatalf("Open() error: %v", err)
+		}
+		defer db.Close()
+		trusts, err := db.Trust()
+		if err != nil {
+			t.Fatalf("Trust() error: %v", err)
+		}
+		got := make(map[string]int)
+		for _, trust := range trusts {
+			got[trust.Level.String()+": "+trust.Reason]++
+		}
+		return got
+	}
+	// the CentOS 7 key isn't imported in centos7-plain
+	if got := trust("testdata/centos7-plain/Packages", keyring); !reflect.DeepEqual(got, map[string]int{"signature: ": len(CentOS7Plain)}) {
+		t.Errorf("centos7-plain: got %v", got)
+	}
+	// the imported keys aren't used with a keyring
+	if got := trust("testdata/centos7-many/Packages", NewKeyring()); !reflect.DeepEqual(got, map[string]int{"digest: key 24c6a8a7f4a80eb5 not in keyring": len(CentOS7Many) - 1}) {
+		t.Errorf("centos7-many: got %v", got)
+	}
+
+	dir, err := ioutil.TempDir("", "rpmdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	armored := distroKeys[1].armored
+	certs, err := pgpDearmor([]byte(armored))
+	if err != nil || len(certs) != 1 {
+		t.Fatalf("pgpDearmor() got %d blocks, error %v", len(certs), err)
+	}
+	// rpm-gpg holds armored and binary keys, a file can have several blocks
+	files := map[string]string{
+		"RPM-GPG-KEY-CentOS-7.gpg": string(certs[0]),
+		"RPM-GPG-KEY-both":         distroKeys[0].armored + "\n" + armored,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	loaded, err := LoadKeyring(dir)
+	if err != nil {
+		t.Fatalf("LoadKeyring() error: %v", err)
+	}
+	want := NewKeyring()
+	for _, key := range distroKeys[:2] {
+		if err := want.Add([]byte(key.armored)); err != nil {
+			t.Fatalf("Add() error: %v", err)
+		}
+	}
+	if !reflect.DeepEqual(loaded.KeyIDs(), want.KeyIDs()) {
+		t.Errorf("KeyIDs(): got %v, want %v", loaded.KeyIDs(), want.KeyIDs())
+	}
+
+	// a corrupt armored key fails its checksum
+	corrupt := strings.Replace(armored, "mQINBFOn", "mQINBFOo", 1)
+	if err := NewKeyring().Add([]byte(corrupt)); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("Add() of a corrupt key: got error %v", err)
+	}
+}
+
+func TestPayloadDigest(t *testing.T) {
+	b := NewHeaderBuilder()
+	b.AddString(RPMTAG_NAME, "foo")
+	b.AddString(RPMTAG_VERSION, "1.0")
+	b.AddString(RPMTAG_RELEASE, "1")
+	b.AddString(RPMTAG_ARCH, "noarch")
+	b.AddStringArray(RPMTAG_PAYLOADDIGEST, []string{"b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c"})
+	b.AddStringArray(RPMTAG_PAYLOADDIGESTALT, []string{"7d865e959b2466918c9863afca942d0fb89d7c9ac0c99bafc3749504ded97730"})
+	b.AddInt32(RPMTAG_PAYLOADDIGESTALGO, uint32(PGPHASHALGO_SHA256))
+	blob, err := b.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes() error: %v", err)
+	}
+	indexEntries, err := headerImport(blob)
+	if err != nil {
+		t.Fatalf("headerImport() error: %v", err)
+	}
+
+	pkg, err := getNEVRA(indexEntries)
+	if err != nil {
+		t.Fatalf("getNEVRA() error: %v", err)
+	}
+	ex, err := getPackageWithTags(indexEntries, nil, false)
+	if err != nil {
+		t.Fatalf("getPackageWithTags() error: %v", err)
+	}
+	for _, got := range []*PackageInfo{pkg, &ex.PackageInfo} {
+		if got.PayloadDigest != "b5bb9d8014a0f9b1d61e21e796d78dccdf1352f23cd32812f4850b878ae4944c" ||
+			got.PayloadDigestAlt != "7d865e959b2466918c9863afca942d0fb89d7c9ac0c99bafc3749504ded97730" ||
+			got.PayloadDigestAlgo != PGPHASHALGO_SHA256 {
+			t.Errorf("got %s %s %v", got.PayloadDigest, got.PayloadDigestAlt, got.PayloadDigestAlgo)
+		}
+	}
+
+	// rpm 4.11 didn't record payload digests
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+	pkgList, err := db.ListPackages()
+	if err != nil {
+		t.Fatalf("ListPackages() error: %v", err)
+	}
+	for _, pkg := range pkgList {
+		if pkg.PayloadDigest != "" || pkg.PayloadDigestAlgo != 0 {
+			t.Errorf("%s: got payload digest %s", pkg.NEVRA(), pkg.PayloadDigest)
+		}
+	}
+}
+
+func TestWithSortedOutput(t *testing.T) {
+	db, err := Open("testdata/centos7-many/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+	sorted, err := Open("testdata/centos7-many/Packages", WithSortedOutput())
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer sorted.Close()
+
+	pkgList, err := db.ListPackages()
+	if err != nil {
+		t.Fatalf("ListPackages() error: %v", err)
+	}
+	sortedList, err := sorted.ListPackages()
+	if err != nil {
+		t.Fatalf("ListPackages() sorted error: %v", err)
+	}
+	if len(sortedList) != len(pkgList) {
+		t.Fatalf("got %d packages, want %d", len(sortedList), len(pkgList))
+	}
+
+	var want, got []string
+	for i := range pkgList {
+		want = append(want, pkgList[i].NEVRA())
+		got = append(got, sortedList[i].NEVRA())
+	}
+	sort.Strings(want)
+	gotSorted := append([]string{}, got...)
+	sort.Strings(gotSorted)
+	if !reflect.DeepEqual(gotSorted, want) {
+		t.Errorf("sorted listing doesn't hold the same packages")
+	}
+	for i := 1; i < len(sortedList); i++ {
+		a, b := sortedList[i-1], sortedList[i]
+		if a.Name > b.Name || (a.Name == b.Name && CompareEVR(a, b) > 0) {
+			t.Errorf("not sorted: %s before %s", a.NEVRA(), b.NEVRA())
+		}
+	}
+
+	withTags, err := sorted.ListPackagesWithTags(RPMTAG_SUMMARY)
+	if err != nil {
+		t.Fatalf("ListPackagesWithTags() error: %v", err)
+	}
+	for i, pkg := range withTags {
+		if pkg.NEVRA() != got[i] {
+			t.Fatalf("ListPackagesWithTags() order: got %s at %d, want %s", pkg.NEVRA(), i, got[i])
+		}
+	}
+	// a budget of a few keys spills runs, merged back into the same order
+	budgeted, err := Open("testdata/centos7-many/Packages", WithSortedOutput(), WithMemoryBudget(4<<10))
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer budgeted.Close()
+	budgetedList, err := budgeted.ListPackages()
+	if err != nil {
+		t.Fatalf("ListPackages() sorted within a budget error: %v", err)
+	}
+	if len(budgetedList) != len(got) {
+		t.Fatalf("ListPackages() sorted within a budget: got %d packages, want %d", len(budgetedList), len(got))
+	}
+	for i, pkg := range budgetedList {
+		if pkg.NEVRA() != got[i] {
+			t.Fatalf("ListPackages() sorted within a budget: got %s at %d, want %s", pkg.NEVRA(), i, got[i])
+		}
+	}
+
+	// equal keys keep the database order across the runs
+	keys := &headerKeySet{budget: 2 * headerKeyOverhead}
+	defer keys.Close()
+	for i, name := range []string{"b", "a", "b", "a", "b", "a", "c"} {
+		key := headerKey{hnum: uint32(i + 1), pageNo: uint32(100 + i)}
+		key.pkg.Name, key.pkg.Version, key.pkg.Release = name, "1", "1"
+		if err := keys.add(key); err != nil {
+			t.Fatalf("add() error: %v", err)
+		}
+	}
+	if err := keys.finish(); err != nil {
+		t.Fatalf("finish() error: %v", err)
+	}
+	if len(keys.spill.runs) < 2 {
+		t.Errorf("headerKeySet spilled %d runs", len(keys.spill.runs))
+	}
+	var order []uint32
+	err = keys.each(func(key *headerKey) error {
+		if key.pageNo != 99+key.hnum {
+			t.Errorf("key %d: got page %d", key.hnum, key.pageNo)
+		}
+		order = append(order, key.hnum)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("each() error: %v", err)
+	}
+	if want := []uint32{2, 4, 6, 1, 3, 5, 7}; !reflect.DeepEqual(order, want) {
+		t.Errorf("each(): got %v, want %v", order, want)
+	}
+}
+
+func TestPackages(t *testing.T) {
+	db, err := Open("testdata/centos7-httpd24/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+	want, err := db.ListPackages()
+	if err != nil {
+		t.Fatalf("ListPackages() error: %v", err)
+	}
+
+	for _, budget := range []int64{0, 16 << 10} {
+		budgeted, err := Open("testdata/centos7-httpd24/Packages", WithMemoryBudget(budget))
+		if err != nil {
+			t.Fatalf("Open() error: %v", err)
+		}
+		defer budgeted.Close()
+		set, err := budgeted.Packages()
+		if err != nil {
+			t.Fatalf("Packages() within %d bytes error: %v", budget, err)
+		}
+		if budget > 0 && (len(set.spill.runs) < 2 || len(set.pkgs) != 0) {
+			t.Errorf("Packages() within %d bytes: spilled %d runs, kept %d packages", budget, len(set.spill.runs), len(set.pkgs))
+		}
+		if set.Len() != len(want) {
+			t.Errorf("Len() within %d bytes: got %d, want %d", budget, set.Len(), len(want))
+		}
+
+		// twice, the spilled packages being read back each time
+		for run := 0; run < 2; run++ {
+			i := 0
+			err := set.Each(func(pkg *PackageInfo) error {
+				if i >= len(want) {
+					return fmt.Errorf("more than %d packages", len(want))
+				}
+				w := want[i]
+				i++
+				if pkg.NEVRA() != w.NEVRA() || pkg.License != w.License || !pkg.InstallTime.Equal(w.InstallTime) {
+					t.Errorf("Each() within %d bytes: got %+v, want %+v", budget, pkg, w)
+				}
+				if (pkg.SignedBy == nil) != (w.SignedBy == nil) {
+					t.Errorf("%s: got SignedBy %v, want %v", pkg.NEVRA(), pkg.SignedBy, w.SignedBy)
+				} else if pkg.SignedBy != nil {
+					if pkg.SignedBy.KeyID != w.SignedBy.KeyID || (pkg.SignedBy.PubKey == nil) != (w.SignedBy.PubKey == nil) {
+						t.Errorf("%s: got SignedBy %v, want %v", pkg.NEVRA(), pkg.SignedBy, w.SignedBy)
+					}
+					if pubKey := pkg.SignedBy.PubKey; pubKey != nil && set.pubKeys[strings.ToLower(pubKey.Version)] != pubKey {
+						t.Errorf("%s: SignedBy.PubKey isn't the gpg-pubkey package of the set", pkg.NEVRA())
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Each() within %d bytes error: %v", budget, err)
+			}
+			if i != len(want) {
+				t.Errorf("Each() within %d bytes: got %d packages, want %d", budget, i, len(want))
+			}
+		}
+
+		stopErr := errors.New("stop")
+		if err := set.Each(func(pkg *PackageInfo) error { return stopErr }); err != stopErr {
+			t.Errorf("Each() within %d bytes: got %v, want the error of fn", budget, err)
+		}
+
+		var spillName string
+		if set.spill.f != nil {
+			spillName = set.spill.f.Name()
+		}
+		if err := set.Close(); err != nil {
+			t.Errorf("Close() error: %v", err)
+		}
+		if spillName != "" {
+			if _, err := os.Stat(spillName); !os.IsNotExist(err) {
+				t.Errorf("spill file left: %v", err)
+			}
+		}
+	}
+}
+
+func TestErrorSentinels(t *testing.T) {
+	_, err := NewDigest(DigestAlgo(99))
+	if !errors.Is(err, ErrUnsupportedDigestAlgo) {
+		t.Errorf("NewDigest() error: got %v, want %v", err, ErrUnsupportedDigestAlgo)
+	}
+
+	_, err = getNEVRA([]indexEntry{{Info: entryInfo{Tag: RPMTAG_NAME, Type: RPM_INT32_TYPE, Count: 1}, Data: make([]byte, 4)}})
+	if !errors.Is(err, ErrInvalidTag) || err.Error() != "invalid tag name" {
+		t.Errorf("getNEVRA() error: got %v, want %v", err, ErrInvalidTag)
+	}
+
+	err2 := fmt.Errorf("sort: %w", &DependencyCycleError{})
+	var cycleErr *DependencyCycleError
+	if !errors.Is(err2, ErrDependencyCycle) || !errors.As(err2, &cycleErr) {
+		t.Errorf("DependencyCycleError doesn't match: %v", err2)
+	}
+}
+
+func TestPackageStates(t *testing.T) {
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	states, err := db.PackageStates()
+	if err != nil {
+		t.Fatalf("PackageStates() error: %v", err)
+	}
+	// the image was installed with --excludedocs
+	if len(states) != 118 {
+		t.Errorf("packages: got %d, want 118", len(states))
+	}
+	for _, state := range states {
+		if state.Package.Name != "bash" {
+			continue
+		}
+		notInstalled := state.Files[RPMFILE_STATE_NOTINSTALLED]
+		if len(notInstalled) != 90 || notInstalled[0] != "/usr/share/doc/bash-4.2.46" {
+			t.Errorf("bash: got %d files not installed, want 90", len(notInstalled))
+		}
+		if len(state.Files) != 1 || state.Package.RemoveTID != 0 {
+			t.Errorf("bash: got states %v and removetid %d", state.Files, state.Package.RemoveTID)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "rpmdb")
+	if err != nil {
+		t.Fatalf("TempDir() error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	removed := verifyTestHeader([]testFile{{path: "/usr/bin/foo", mode: 0100755}}, 1500000000)
+	removed.AddInt32(RPMTAG_REMOVETID, 1600000000)
+	netshared := verifyTestHeader([]testFile{{path: "/usr/bin/bar", mode: 0100755}, {path: "/mnt/share/bar", mode: 0100644}}, 1500000000)
+	netshared.AddString(RPMTAG_NAME, "bar")
+	netshared.add(RPMTAG_FILESTATES, RPM_CHAR_TYPE, 2, []byte{byte(RPMFILE_STATE_NORMAL), byte(RPMFILE_STATE_NETSHARED)})
+	db, err = Open(createTestDB(t, dir, removed, netshared))
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	states, err = db.PackageStates()
+	if err != nil {
+		t.Fatalf("PackageStates() error: %v", err)
+	}
+	if len(states) != 2 {
+		t.Fatalf("packages: got %d, want 2", len(states))
+	}
+	if got := states[0].Files[RPMFILE_STATE_NETSHARED]; !reflect.DeepEqual(got, []string{"/mnt/share/bar"}) {
+		t.Errorf("bar: got net shared %v, want /mnt/share/bar", got)
+	}
+	if states[1].Package.RemoveTID != 1600000000 || len(states[1].Files) != 0 {
+		t.Errorf("foo: got removetid %d and states %v", states[1].Package.RemoveTID, states[1].Files)
+	}
+	if s := RPMFILE_STATE_NETSHARED.String(); s != "net shared" {
+		t.Errorf("String(): got %q, want net shared", s)
+	}
+}
+
+func TestDumpHeader(t *testing.T) {
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	var blob []byte
+	err = db.forEachHeader(func(hnum uint32, data []byte) error {
+		indexEntries, err := headerImport(data)
+		if err != nil {
+			return err
+		}
+		pkg, err := getNEVRA(indexEntries)
+		if err != nil {
+			return err
+		}
+		if pkg.Name == "bash" {
+			blob = data
+		}
+		return nil
+	})
+	if err != nil || blob == nil {
+		t.Fatalf("bash header not found: %v", err)
+	}
+
+	var dump bytes.Buffer
+	if err := DumpHeader(blob, &dump); err != nil {
+		t.Fatalf("DumpHeader() error: %v", err)
+	}
+	for _, want := range []string{
+		"RPMTAG_NAME                   1000 STRING            1  \"bash\"\n",
+		"RPMTAG_FILESIZES              1028 INT32           109\n",
+		"    [4] 964544\n",
+		"RPMTAG_INSTALLTID             1128 INT32             1  1538853262\n",
+	} {
+		if !strings.Contains(dump.String(), want) {
+			t.Errorf("dump doesn't contain %q", want)
+		}
+	}
+
+	// the same header with its magic or in a package file
+	var rpm bytes.Buffer
+	if err := WritePackageFile(&rpm, blob); err != nil {
+		t.Fatalf("WritePackageFile() error: %v", err)
+	}
+	for name, data := range map[string][]byte{
+		"magic":   append(append([]byte{}, headerMagic...), blob...),
+		"package": rpm.Bytes(),
+	} {
+		var got bytes.Buffer
+		if err := DumpHeader(data, &got); err != nil {
+			t.Fatalf("DumpHeader() of the %s error: %v", name, err)
+		}
+		if got.String() != dump.String() {
+			t.Errorf("DumpHeader() of the %s differs", name)
+		}
+	}
+
+	// a header cut short is not dumped
+	b := NewHeaderBuilder()
+	b.AddString(RPMTAG_NAME, "foo")
+	corrupt, err := b.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes() error: %v", err)
+	}
+	if err := DumpHeader(corrupt[:len(corrupt)-20], &dump); err == nil {
+		t.Errorf("DumpHeader() of a truncated header succeeded")
+	}
+}
+
+// dbDump writes a database the way db_dump does, with -p when printable.
+func dbDump(t *testing.T, path string, printable bool) string {
+	db, err := bdb.Open(path)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	var dump strings.Builder
+	format := "bytevalue"
+	if printable {
+		format = "print"
+	}
+	fmt.Fprintf(&dump, "VERSION=3\nformat=%s\ntype=hash\ndb_pagesize=%d\nHEADER=END\n", format, db.HashMetadata.PageSize)
+	line := func(data []byte) {
+		dump.WriteByte(' ')
+		for _, b := range data {
+			switch {
+			case !printable:
+				fmt.Fprintf(&dump, "%02x", b)
+			case b == '\\':
+				dump.WriteString(`\\`)
+			case b >= 0x20 && b < 0x7f:
+				dump.WriteByte(b)
+			default:
+				fmt.Fprintf(&dump, `\%02x`, b)
+			}
+		}
+		dump.WriteByte('\n')
+	}
+	for entry := range db.ReadAll() {
+		if entry.Err != nil {
+			t.Fatalf("ReadAll() error: %v", entry.Err)
+		}
+		line(entry.Key)
+		line(entry.Value)
+	}
+	dump.WriteString("DATA=END\n")
+	return dump.String()
+}
+
+func TestOpenReader(t *testing.T) {
+	for _, path := range []string{"testdata/centos7-plain/Packages", "testdata/sqlite-centos7/rpmdb.sqlite"} {
+		t.Run(path, func(t *testing.T) {
+			db, err := Open(path)
+			if err != nil {
+				t.Fatalf("Open() error: %v", err)
+			}
+			defer db.Close()
+			want, err := db.ListPackages()
+			if err != nil {
+				t.Fatalf("ListPackages() error: %v", err)
+			}
+
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fromMemory, err := OpenReader(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatalf("OpenReader() error: %v", err)
+			}
+			defer fromMemory.Close()
+			got, err := fromMemory.ListPackages()
+			if err != nil {
+				t.Fatalf("ListPackages() error: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("OpenReader() got other packages than Open()")
+			}
+		})
+	}
+	if _, err := OpenReader(bytes.NewReader(nil), 0); err == nil {
+		t.Errorf("OpenReader() of nothing: expected an error")
+	}
+}
+
+func TestOpenDump(t *testing.T) {
+	path := "testdata/centos7-plain/Packages"
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	want, err := db.ListPackages()
+	if err != nil {
+		t.Fatalf("ListPackages() error: %v", err)
+	}
+
+	for _, printable := range []bool{false, true} {
+		dump := dbDump(t, path, printable)
+		db, err := OpenDump(strings.NewReader(dump))
+		if err != nil {
+			t.Fatalf("OpenDump() error: %v", err)
+		}
+		got, err := db.ListPackages()
+		if err != nil {
+			t.Fatalf("ListPackages() error: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("printable %v: got %d packages, want %d", printable, len(got), len(want))
+		}
+
+		providers, err := db.WhatProvides("/usr/bin/bash")
+		if err != nil {
+			t.Fatalf("WhatProvides() error: %v", err)
+		}
+		if len(providers) != 1 || providers[0].Name != "bash" {
+			t.Errorf("WhatProvides(): got %v, want bash", providers)
+		}
+
+		sorted, err := OpenDump(strings.NewReader(dump), WithSortedOutput())
+		if err != nil {
+			t.Fatalf("OpenDump() error: %v", err)
+		}
+		pkgs, err := sorted.ListPackages()
+		if err != nil {
+			t.Fatalf("ListPackages() error: %v", err)
+		}
+		if len(pkgs) != len(want) || !sort.SliceIsSorted(pkgs, func(i, j int) bool { return pkgs[i].Name < pkgs[j].Name }) {
+			t.Errorf("WithSortedOutput(): got %d packages out of order", len(pkgs))
+		}
+
+		if _, err := OpenDump(strings.NewReader(dump[:len(dump)/2])); err == nil {
+			t.Errorf("OpenDump() of a truncated dump succeeded")
+		}
+	}
+}
+
+func TestExportDump(t *testing.T) {
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	want, err := db.ListPackages()
+	if err != nil {
+		t.Fatalf("ListPackages() error: %v", err)
+	}
+
+	var dump bytes.Buffer
+	if err := db.ExportDump(&dump); err != nil {
+		t.Fatalf("ExportDump() error: %v", err)
+	}
+	if !strings.HasPrefix(dump.String(), "VERSION=3\nformat=bytevalue\ntype=hash\ndb_pagesize=4096\nHEADER=END\n") {
+		t.Errorf("unexpected dump header: %q", dump.String()[:64])
+	}
+	if !strings.HasSuffix(dump.String(), "\n 00000000\n a9000000\nDATA=END\n") {
+		t.Errorf("unexpected instance record: %q", dump.String()[dump.Len()-40:])
+	}
+
+	// a dump exported from a dump is the same
+	loaded, err := OpenDump(bytes.NewReader(dump.Bytes()))
+	if err != nil {
+		t.Fatalf("OpenDump() error: %v", err)
+	}
+	got, err := loaded.ListPackages()
+	if err != nil {
+		t.Fatalf("ListPackages() error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %d packages, want %d", len(got), len(want))
+	}
+	var again bytes.Buffer
+	if err := loaded.ExportDump(&again); err != nil {
+		t.Fatalf("ExportDump() error: %v", err)
+	}
+	if !bytes.Equal(again.Bytes(), dump.Bytes()) {
+		t.Errorf("the dump of the dump differs")
+	}
+}
+
+func TestBackendStats(t *testing.T) {
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	stats, err := db.BackendStats()
+	if err != nil {
+		t.Fatalf("BackendStats() error: %v", err)
+	}
+	want := bdb.Stats{
+		PageSize:       4096,
+		Pages:          4046,
+		Buckets:        2,
+		Records:        145,
+		HashPages:      2,
+		OverflowPages:  1827,
+		OverflowChains: 144,
+		LongestChain:   265,
+		LargestEntry:   1074828,
+		FreePages:      2216,
+	}
+	fill := stats.HashPageFill
+	stats.HashPageFill = 0
+	if !reflect.DeepEqual(*stats, want) {
+		t.Errorf("got %+v, want %+v", *stats, want)
+	}
+	if fill <= 0 || fill >= 1 {
+		t.Errorf("hash page fill: got %v", fill)
+	}
+	// every page but the metadata one is accounted for
+	if n := 1 + stats.HashPages + stats.OverflowPages + stats.FreePages; n != int(stats.Pages) {
+		t.Errorf("pages: got %d, want %d", n, stats.Pages)
+	}
+
+	dump, err := OpenDump(strings.NewReader(dbDump(t, "testdata/centos7-plain/Packages", false)))
+	if err != nil {
+		t.Fatalf("OpenDump() error: %v", err)
+	}
+	if _, err := dump.BackendStats(); !errors.Is(err, ErrNotSupport) {
+		t.Errorf("BackendStats() of a dump: got %v, want %v", err, ErrNotSupport)
+	}
+}
+
+func TestScanRoots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmdb")
+	if err != nil {
+		t.Fatalf("TempDir() error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	install := func(src, root, dbPath string) {
+		data, err := ioutil.ReadFile(src)
+		if err != nil {
+			t.Fatalf("ReadFile() error: %v", err)
+		}
+		dst := filepath.Join(dir, root, dbPath)
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			t.Fatalf("MkdirAll() error: %v", err)
+		}
+		if err := ioutil.WriteFile(dst, data, 0644); err != nil {
+			t.Fatalf("WriteFile() error: %v", err)
+		}
+	}
+	install("testdata/centos7-plain/Packages", "host", "var/lib/rpm/Packages")
+	install("testdata/centos6-plain/Packages", "container1", "usr/lib/sysimage/rpm/Packages")
+	// roots holding only the database of a newer backend
+	install("testdata/sqlite-centos7/rpmdb.sqlite", "container3", "var/lib/rpm/rpmdb.sqlite")
+	ndbPath := filepath.Join(dir, "container4", "usr/lib/sysimage/rpm/Packages.db")
+	if err := os.MkdirAll(filepath.Dir(ndbPath), 0755); err != nil {
+		t.Fatalf("MkdirAll() error: %v", err)
+	}
+	w, err := ndb.Create(ndbPath)
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	source, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	err = source.forEachHeader(w.Put)
+	source.Close()
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		t.Fatalf("writing %s: %v", ndbPath, err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "container2", "var/lib"), 0755); err != nil {
+		t.Fatalf("MkdirAll() error: %v", err)
+	}
+	// an absolute link resolves within the container, not to the database of the host
+	if err := os.Symlink(filepath.Join(dir, "host", "var/lib/rpm"), filepath.Join(dir, "container2", "var/lib/rpm")); err != nil {
+		t.Fatalf("Symlink() error: %v", err)
+	}
+
+	var roots []string
+	for _, root := range []string{"host", "container1", "container2", "container3", "container4"} {
+		roots = append(roots, filepath.Join(dir, root))
+	}
+	scans, err := ScanRoots(context.Background(), roots, &ScanOptions{Concurrency: 2})
+	if err != nil {
+		t.Fatalf("ScanRoots() error: %v", err)
+	}
+	if len(scans) != 5 {
+		t.Fatalf("scans: got %d, want 5", len(scans))
+	}
+	for i, want := range map[int]int{0: len(CentOS7Plain), 1: len(CentOS6Plain), 3: len(CentOS7Plain), 4: len(CentOS7Plain)} {
+		if scans[i].Root != roots[i] || scans[i].Err != nil || len(scans[i].Packages) != want {
+			t.Errorf("%s: got %d packages, %v, want %d", roots[i], len(scans[i].Packages), scans[i].Err, want)
+		}
+	}
+	if scans[2].Err != ErrNoDatabase || scans[2].DBPath != "" {
+		t.Errorf("%s: got %s, %v, want %v", roots[2], scans[2].DBPath, scans[2].Err, ErrNoDatabase)
+	}
+
+	// explicit DBPaths are the only ones tried
+	scans, err = ScanRoots(context.Background(), roots[3:4], &ScanOptions{DBPaths: []string{"/var/lib/rpm/Packages"}})
+	if err != nil || scans[0].Err != ErrNoDatabase {
+		t.Errorf("ScanRoots() with DBPaths: got %v, %v, want %v", err, scans[0].Err, ErrNoDatabase)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	scans, err = ScanRoots(ctx, roots, nil)
+	if err != context.Canceled || len(scans) != 5 || scans[0].Err != context.Canceled {
+		t.Errorf("canceled ScanRoots(): got %v", err)
+	}
+}
+
+func TestDistroQuirks(t *testing.T) {
+	header := func(name, version, release, vendor string) *HeaderBuilder {
+		b := NewHeaderBuilder()
+		b.AddString(RPMTAG_NAME, name)
+		b.AddString(RPMTAG_VERSION, version)
+		b.AddString(RPMTAG_RELEASE, release)
+		b.AddString(RPMTAG_ARCH, "x86_64")
+		if vendor != "" {
+			b.AddString(RPMTAG_VENDOR, vendor)
+		}
+		b.AddString(RPMTAG_DESCRIPTION, strings.Repeat("foo ", 1024))
+		return b
+	}
+
+	amzn := header("bash", "5.2.15", "1.amzn2023.0.2", "Amazon Linux")
+	amzn.AddInt32(RPMTAG_EPOCH, 0)
+	amzn.AddInt32(RPMTAG_SIZE, 8000000)
+	// Photon OS packages have no vendor tag
+	photon := header("bash", "5.1.16", "2.ph5", "")
+	photon.AddInt32(RPMTAG_SIZE, 7000000)
+	mariner := header("bash", "5.1.8", "4.cm2", "Microsoft Corporation")
+	mariner.AddInt32(RPMTAG_EPOCH, 1)
+	// headers written by other tools than rpmbuild
+	foreign := header("foo", "1.0", "1", "")
+	foreign.AddInt16(RPMTAG_EPOCH, 2)
+	foreign.AddInt64(RPMTAG_LONGSIZE, 5<<30)
+
+	vectors := []struct {
+		header *HeaderBuilder
+		want   PackageInfo
+		distro *Distro
+	}{
+		{
+			header: amzn,
+			want:   PackageInfo{Name: "bash", Version: "5.2.15", Release: "1.amzn2023.0.2", Arch: "x86_64", Size: 8000000, Vendor: "Amazon Linux"},
+			distro: &Distro{ID: "amzn", VersionID: "2023", Name: "Amazon Linux"},
+		},
+		{
+			header: photon,
+			want:   PackageInfo{Name: "bash", Version: "5.1.16", Release: "2.ph5", Arch: "x86_64", Size: 7000000},
+			distro: &Distro{ID: "photon", VersionID: "5", Name: "VMware Photon OS"},
+		},
+		{
+			header: mariner,
+			want:   PackageInfo{Epoch: 1, Name: "bash", Version: "5.1.8", Release: "4.cm2", Arch: "x86_64", Vendor: "Microsoft Corporation"},
+			distro: &Distro{ID: "mariner", VersionID: "2", Name: "CBL-Mariner"},
+		},
+		{
+			header: foreign,
+			want:   PackageInfo{Epoch: 2, Name: "foo", Version: "1.0", Release: "1", Arch: "x86_64", Size: 5 << 30},
+		},
+	}
+
+	for _, v := range vectors {
+		t.Run(v.want.NEVRA(), func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "rpmdb-quirks")
+			if err != nil {
+				t.Fatalf("TempDir() error: %v", err)
+			}
+			defer os.RemoveAll(dir)
+
+			db, err := Open(createTestDB(t, dir, v.header))
+			if err != nil {
+				t.Fatalf("Open() error: %v", err)
+			}
+			defer db.Close()
+			pkgList, err := db.ListPackages()
+			if err != nil {
+				t.Fatalf("ListPackages() error: %v", err)
+			}
+			if len(pkgList) != 1 || !reflect.DeepEqual(*pkgList[0], v.want) {
+				t.Fatalf("ListPackages() got %+v, want %+v", pkgList, v.want)
+			}
+
+			if v.distro == nil {
+				return
+			}
+			distro, err := DetectDistro(db)
+			if err != nil {
+				t.Fatalf("DetectDistro() error: %v", err)
+			}
+			if !reflect.DeepEqual(distro, v.distro) {
+				t.Errorf("DetectDistro() got %+v, want %+v", distro, v.distro)
+			}
+		})
+	}
+}
+
+func TestDetectDistro(t *testing.T) {
+	header := func(name, release, vendor string) *HeaderBuilder {
+		b := NewHeaderBuilder()
+		b.AddString(RPMTAG_NAME, name)
+		b.AddString(RPMTAG_VERSION, "1.0")
+		b.AddString(RPMTAG_RELEASE, release)
+		b.AddString(RPMTAG_ARCH, "x86_64")
+		if vendor != "" {
+			b.AddString(RPMTAG_VENDOR, vendor)
+		}
+		b.AddString(RPMTAG_DESCRIPTION, strings.Repeat("foo ", 1024))
+		return b
+	}
+	vectors := []struct {
+		name    string
+		headers []*HeaderBuilder
+		want    string
+	}{
+		{
+			name:    "dist tags",
+			headers: []*HeaderBuilder{header("foo", "1.el8", "Red Hat, Inc."), header("bar", "1.el8", "Red Hat, Inc."), header("baz", "1.fc39", "Fedora Project")},
+			want:    "rhel 8",
+		},
+		{
+			name:    "rebuild vendor",
+			headers: []*HeaderBuilder{header("foo", "1.el8", "Rocky Enterprise Software Foundation"), header("bar", "1.el8", "Rocky Enterprise Software Foundation"), header("baz", "1.el8", "")},
+			want:    "rocky 8",
+		},
+		{
+			// packages without vendor, gpg-pubkey ones, don't outnumber the rebuild's
+			name:    "vendor of fewer packages than the ones without",
+			headers: []*HeaderBuilder{header("foo", "1.el8", "AlmaLinux"), header("bar", "1.el8", ""), header("baz", "1.el8", "")},
+			want:    "almalinux 8",
+		},
+		{
+			// as many packages of each vendor, the first by name wins whatever the map order
+			name:    "vendor tie",
+			headers: []*HeaderBuilder{header("foo", "1.el8", "Rocky Enterprise Software Foundation"), header("bar", "1.el8", "AlmaLinux"), header("baz", "1.el8", "CentOS")},
+			want:    "almalinux 8",
+		},
+	}
+
+	for _, v := range vectors {
+		t.Run(v.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "rpmdb-distro")
+			if err != nil {
+				t.Fatalf("TempDir() error: %v", err)
+			}
+			defer os.RemoveAll(dir)
+
+			db, err := Open(createTestDB(t, dir, v.headers...))
+			if err != nil {
+				t.Fatalf("Open() error: %v", err)
+			}
+			defer db.Close()
+			for i := 0; i < 20; i++ {
+				distro, err := DetectDistro(db)
+				if err != nil {
+					t.Fatalf("DetectDistro() error: %v", err)
+				}
+				if got := distro.ID + " " + distro.VersionID; got != v.want {
+					t.Fatalf("DetectDistro() got %s, want %s", got, v.want)
+				}
+			}
+		})
+	}
+}
+
+func TestContainerManifest(t *testing.T) {
+	manifest := "bash\t5.1.8-4.cm2\t1700000000\t1690000000\tMicrosoft Corporation\t(none)\t7812345\tx86_64\t0\tbash-5.1.8-4.cm2.src.rpm\n" +
+		"tzdata\t2023c-1.cm2\t1700000000\t1690000000\tMicrosoft Corporation\t1\t1583453\tnoarch\t1\t(none)\n"
+	installed, built := time.Unix(1700000000, 0).UTC(), time.Unix(1690000000, 0).UTC()
+	want := []*PackageInfo{
+		{Name: "bash", Version: "5.1.8", Release: "4.cm2", Arch: "x86_64", SourceRpm: "bash-5.1.8-4.cm2.src.rpm", Size: 7812345, Vendor: "Microsoft Corporation", InstallTime: installed, BuildTime: built},
+		{Epoch: 1, Name: "tzdata", Version: "2023c", Release: "1.cm2", Arch: "noarch", Size: 1583453, Vendor: "Microsoft Corporation", InstallTime: installed, BuildTime: built},
+	}
+	pkgList, err := ReadContainerManifest(strings.NewReader(manifest))
+	if err != nil {
+		t.Fatalf("ReadContainerManifest() error: %v", err)
+	}
+	if !reflect.DeepEqual(pkgList, want) {
+		t.Errorf("ReadContainerManifest() got %+v, want %+v", pkgList, want)
+	}
+
+	for _, invalid := range []string{"bash\t5.1.8-4.cm2\n", strings.Replace(manifest, "5.1.8-4.cm2", "5.1.8", 1)} {
+		if _, err := ReadContainerManifest(strings.NewReader(invalid)); err == nil {
+			t.Errorf("ReadContainerManifest(%q) succeeded", invalid)
+		}
+	}
+
+	// a distroless image has the manifest instead of a database
+	dir, err := ioutil.TempDir("", "rpmdb-manifest")
+	if err != nil {
+		t.Fatalf("TempDir() error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	manifestPath := filepath.Join(dir, ContainerManifestPath)
+	if err := os.MkdirAll(filepath.Dir(manifestPath), 0755); err != nil {
+		t.Fatalf("MkdirAll() error: %v", err)
+	}
+	if err := ioutil.WriteFile(manifestPath, []byte(manifest), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	scans, err := ScanRoots(context.Background(), []string{dir}, nil)
+	if err != nil {
+		t.Fatalf("ScanRoots() error: %v", err)
+	}
+	if scans[0].Err != nil || scans[0].DBPath != manifestPath || !reflect.DeepEqual(scans[0].Packages, want) {
+		t.Errorf("ScanRoots() got %s, %+v, %v", scans[0].DBPath, scans[0].Packages, scans[0].Err)
+	}
+}
+
+func TestRedaction(t *testing.T) {
+	tags := []TAG_ID{RPMTAG_BUILDHOST, RPMTAG_PACKAGER, RPMTAG_CHANGELOGNAME, RPMTAG_POSTIN, RPMTAG_POSTINPROG, RPMTAG_BUILDTIME}
+	plain, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	db, err := Open("testdata/centos7-plain/Packages", WithRedaction())
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	bash := func(db *RpmDB) *PackageInfoEx {
+		pkgs, err := db.ListPackagesWithTags(tags...)
+		if err != nil {
+			t.Fatalf("ListPackagesWithTags() error: %v", err)
+		}
+		for _, pkg := range pkgs {
+			if pkg.Name == "bash" {
+				return pkg
+			}
+		}
+		t.Fatal("bash not found")
+		return nil
+	}
+	want, got := bash(plain), bash(db)
+
+	if _, ok := want.TagsMap[RPMTAG_POSTIN]; !ok {
+		t.Fatalf("no postin in %v", want.TagsMap)
+	}
+	for _, tag := range []TAG_ID{RPMTAG_BUILDHOST, RPMTAG_POSTIN} {
+		if v, ok := got.TagsMap[tag]; ok {
+			t.Errorf("%v not redacted: %v", tag, v)
+		}
+	}
+	for _, tag := range []TAG_ID{RPMTAG_POSTINPROG, RPMTAG_BUILDTIME, RPMTAG_PACKAGER} {
+		if !reflect.DeepEqual(got.TagsMap[tag], want.TagsMap[tag]) {
+			t.Errorf("%v: got %v, want %v", tag, got.TagsMap[tag], want.TagsMap[tag])
+		}
+	}
+	if name := want.TagsMap[RPMTAG_CHANGELOGNAME].([]string)[0]; name != "Siteshwar Vashisht <svashisht@redhat.com> - 4.2.46-30" {
+		t.Fatalf("unexpected changelog name: %q", name)
+	}
+	names, ok := got.TagsMap[RPMTAG_CHANGELOGNAME].([]string)
+	if !ok || len(names) != len(want.TagsMap[RPMTAG_CHANGELOGNAME].([]string)) {
+		t.Fatalf("unexpected changelog names: %v", got.TagsMap[RPMTAG_CHANGELOGNAME])
+	}
+	if wantName := "Siteshwar Vashisht - 4.2.46-30"; names[0] != wantName {
+		t.Errorf("got changelog name %q, want %q", names[0], wantName)
+	}
+	for _, name := range names {
+		if strings.Contains(name, "@") {
+			t.Errorf("email not redacted: %q", name)
+		}
+	}
+	if !reflect.DeepEqual(got.PackageInfo, want.PackageInfo) {
+		t.Errorf("got %+v, want %+v", got.PackageInfo, want.PackageInfo)
+	}
+
+	provenances, err := db.Provenance()
+	if err != nil {
+		t.Fatalf("Provenance() error: %v", err)
+	}
+	for _, p := range provenances {
+		if p.BuildHost != "" {
+			t.Errorf("%s: build host not redacted: %s", p.Package.NEVRA(), p.BuildHost)
+		}
+	}
+
+	// the exported headers are redacted too
+	var dump bytes.Buffer
+	if err := db.ExportDump(&dump); err != nil {
+		t.Fatalf("ExportDump() error: %v", err)
+	}
+	loaded, err := OpenDump(&dump)
+	if err != nil {
+		t.Fatalf("OpenDump() error: %v", err)
+	}
+	if exported := bash(loaded); !reflect.DeepEqual(exported.TagsMap, got.TagsMap) {
+		t.Errorf("got %v, want %v", exported.TagsMap, got.TagsMap)
+	}
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	value := struct {
+		Z    int
+		A    map[string]interface{}
+		Text string
+	}{
+		Z:    -0,
+		A:    map[string]interface{}{"b": []interface{}{uint64(1 << 63), true, nil}, "a": "<&>"},
+		Text: "tab\t \"quote\" back\\slash \u00e9",
+	}
+	got, err := CanonicalJSON(value)
+	if err != nil {
+		t.Fatalf("CanonicalJSON() error: %v", err)
+	}
+	want := `{"A":{"a":"<&>","b":[9223372036854775808,true,null]},"Text":"tab\u0009 \"quote\" back\\slash é","Z":0}`
+	if string(got) != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(got, &decoded); err != nil {
+		t.Errorf("invalid JSON: %v", err)
+	}
+
+	if _, err := CanonicalJSON(map[string]float64{"pi": 3.14}); err == nil {
+		t.Error("expected an error for a float")
+	}
+}
+
+func TestSerializedNames(t *testing.T) {
+	pkg := &PackageInfo{
+		Epoch:         1,
+		Name:          "foo",
+		Version:       "1.0",
+		Release:       "1",
+		Arch:          "x86_64",
+		SourceRpm:     "foo-1.0-1.src.rpm",
+		SignedBy:      &PubKeyRef{KeyID: "24c6a8a7f4a80eb5"},
+		InstallReason: InstallReasonUser,
+		InstallTime:   time.Unix(1500000000, 0).UTC(),
+		BuildTime:     time.Unix(1400000000, 0).UTC(),
+	}
+	data, err := json.Marshal(pkg)
+	if err != nil {
+		t.Fatalf("Marshal() error: %v", err)
+	}
+	want := `{"epoch":1,"name":"foo","version":"1.0","release":"1","arch":"x86_64","source_rpm":"foo-1.0-1.src.rpm","size":0,"signed_by":{"key_id":"24c6a8a7f4a80eb5"},"install_reason":1,"install_time":"2017-07-14T02:40:00Z","build_time":"2014-05-13T16:53:20Z"}`
+	if string(data) != want {
+		t.Errorf("Marshal(PackageInfo) got %s, want %s", data, want)
+	}
+	var decoded PackageInfo
+	if err := json.Unmarshal(data, &decoded); err != nil || !reflect.DeepEqual(&decoded, pkg) {
+		t.Errorf("Unmarshal() got %+v, %v", decoded, err)
+	}
+
+	data, err = json.Marshal(&PackageInfoEx{PackageInfo: *pkg, TagsMap: map[TAG_ID]interface{}{RPMTAG_SUMMARY: "foo"}})
+	if err != nil || !strings.HasPrefix(string(data), `{"epoch":1,"name":"foo",`) || !strings.HasSuffix(string(data), `,"tags":{"1004":"foo"}}`) {
+		t.Errorf("Marshal(PackageInfoEx) got %s, %v", data, err)
+	}
+
+	data, err = json.Marshal(FileInfo{Path: "/usr/bin/foo", OrigPath: "/usr/bin/foo", Mode: 0100755, LinkTo: "", Color: RPMFC_ELF64})
+	if err != nil {
+		t.Fatalf("Marshal() error: %v", err)
+	}
+	want = `{"path":"/usr/bin/foo","orig_path":"/usr/bin/foo","size":0,"mode":33261,"mtime":0,"flags":0,"username":"","groupname":"","verify_flags":0,"state":0,"color":2}`
+	if string(data) != want {
+		t.Errorf("Marshal(FileInfo) got %s, want %s", data, want)
+	}
+}
+
+func TestCompactInventory(t *testing.T) {
+	pkgs := []*PackageInfo{
+		{Epoch: 1, Name: "foo", Version: "1.0", Release: "1", Size: 300, SignedBy: &PubKeyRef{KeyID: "ab"}},
+		{Name: "bar", Version: "2", Release: "1", Arch: "noarch"},
+	}
+
+	var msgpack bytes.Buffer
+	if err := WriteInventoryMsgpack(&msgpack, pkgs); err != nil {
+		t.Fatalf("WriteInventoryMsgpack() error: %v", err)
+	}
+	want := "\x92" +
+		"\x86\xa4name\xa3foo\xa5epoch\x01\xa7version\xa31.0\xa7release\xa11\xa4size\xcd\x01\x2c\xa9signed_by\xa2ab" +
+		"\x84\xa4name\xa3bar\xa7version\xa12\xa7release\xa11\xa4arch\xa6noarch"
+	if got := msgpack.String(); got != want {
+		t.Errorf("WriteInventoryMsgpack() got %q, want %q", got, want)
+	}
+
+	want = "\x0a\x16" + "\x0a\x03foo\x10\x01\x1a\x031.0\x22\x011\x38\xac\x02\x6a\x02ab" +
+		"\x0a\x13" + "\x0a\x03bar\x1a\x012\x22\x011\x2a\x06noarch"
+	if got := string(MarshalInventoryProto(pkgs)); got != want {
+		t.Errorf("MarshalInventoryProto() got %q, want %q", got, want)
+	}
+
+	// one length-delimited Package per package
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+	pkgs, err = db.ListPackages()
+	if err != nil {
+		t.Fatalf("ListPackages() error: %v", err)
+	}
+	data := MarshalInventoryProto(pkgs)
+	n := 0
+	for ; len(data) > 0; n++ {
+		if data[0] != 0x0a {
+			t.Fatalf("package %d: unexpected key %#x", n, data[0])
+		}
+		size, k := binary.Uvarint(data[1:])
+		if k <= 0 || uint64(len(data)-1-k) < size {
+			t.Fatalf("package %d: invalid length", n)
+		}
+		data = data[1+k+int(size):]
+	}
+	if n != len(CentOS7Plain) {
+		t.Errorf("got %d packages, want %d", n, len(CentOS7Plain))
+	}
+}
+
+// protoSchema returns the fields of the Package message of inventory.proto, their type by number
+// and their name.
+func protoSchema(t *testing.T) (types map[uint64]string, names map[uint64]string) {
+	t.Helper()
+	data, err := ioutil.ReadFile("inventory.proto")
+	if err != nil {
+		t.Fatalf("ReadFile() error: %v", err)
+	}
+	types, names = make(map[uint64]string), make(map[uint64]string)
+	inPackage := false
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(strings.TrimSuffix(strings.TrimSpace(line), ";"))
+		switch {
+		case len(fields) > 1 && fields[0] == "message":
+			inPackage = fields[1] == "Package"
+		case inPackage && len(fields) == 4 && fields[2] == "=":
+			number, err := strconv.ParseUint(fields[3], 10, 64)
+			if err != nil {
+				t.Fatalf("inventory.proto: %q: %v", line, err)
+			}
+			types[number], names[number] = fields[0], fields[1]
+		}
+	}
+	return types, names
+}
+
+func TestInventoryProtoRoundTrip(t *testing.T) {
+	types, names := protoSchema(t)
+	if len(types) != len(compactFields) {
+		t.Errorf("inventory.proto: got %d Package fields, want %d", len(types), len(compactFields))
+	}
+	for _, f := range compactFields {
+		if names[f.number] != f.name || (f.str != nil) != (types[f.number] == "string") {
+			t.Errorf("field %d: got %s %s in inventory.proto, want %s", f.number, types[f.number], names[f.number], f.name)
+		}
+	}
+
+	db, err := Open("testdata/centos7-many/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+	pkgs, err := db.ListPackages()
+	if err != nil {
+		t.Fatalf("ListPackages() error: %v", err)
+	}
+	pkgs = append(pkgs, &PackageInfo{Name: "empty"})
+	data := MarshalInventoryProto(pkgs)
+
+	// every field is declared by inventory.proto with the wire type of its type, read here
+	// without the decoder of the package
+	uvarint := func(b []byte) (uint64, []byte) {
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatalf("invalid varint")
+		}
+		return v, b[n:]
+	}
+	for rest := data; len(rest) > 0; {
+		var key, size uint64
+		key, rest = uvarint(rest)
+		size, rest = uvarint(rest)
+		if key != 1<<3|2 || size > uint64(len(rest)) {
+			t.Fatalf("Inventory: unexpected key %d or size %d", key, size)
+		}
+		for msg := rest[:size]; len(msg) > 0; {
+			key, msg = uvarint(msg)
+			number, typ := key>>3, types[key>>3]
+			switch {
+			case typ == "string" && key&7 == 2:
+				var n uint64
+				n, msg = uvarint(msg)
+				if !utf8.Valid(msg[:n]) {
+					t.Errorf("%s: invalid UTF-8 %q", names[number], msg[:n])
+				}
+				msg = msg[n:]
+			case (typ == "uint32" || typ == "uint64") && key&7 == 0:
+				var v uint64
+				v, msg = uvarint(msg)
+				if typ == "uint32" && v > math.MaxUint32 {
+					t.Errorf("%s: %d overflows uint32", names[number], v)
+				}
+			default:
+				t.Fatalf("field %d: wire type %d for %q", number, key&7, typ)
+			}
+		}
+		rest = rest[size:]
+	}
+
+	got, err := UnmarshalInventoryProto(data)
+	if err != nil {
+		t.Fatalf("UnmarshalInventoryProto() error: %v", err)
+	}
+	if len(got) != len(pkgs) {
+		t.Fatalf("UnmarshalInventoryProto(): got %d packages, want %d", len(got), len(pkgs))
+	}
+	for i, pkg := range pkgs {
+		for _, f := range compactFields {
+			if f.str != nil && f.str(got[i]) != f.str(pkg) || f.num != nil && f.num(got[i]) != f.num(pkg) {
+				t.Errorf("%s: %s not decoded", pkg.NEVRA(), f.name)
+			}
+		}
+	}
+	if got[0].InstallTime != pkgs[0].InstallTime {
+		t.Errorf("InstallTime: got %v, want %v", got[0].InstallTime, pkgs[0].InstallTime)
+	}
+
+	// the fields of later versions are skipped, the malformed messages fail
+	later := append(appendProtoBytes(nil, 1, "\x0a\x03foo\xa8\x01\x07\xb2\x01\x01x\xb9\x01\x00\x00\x00\x00\x00\x00\x00\x00"), appendProtoBytes(nil, 2, "x")...)
+	if got, err := UnmarshalInventoryProto(later); err != nil || len(got) != 1 || got[0].Name != "foo" {
+		t.Errorf("UnmarshalInventoryProto() with unknown fields: got %v, %v", got, err)
+	}
+	for _, data := range []string{"\x0a\x05\x0a\x03fo", "\x0a\x02\x0a\x03", "\x0a\x02\x10\x80", "\x0a\x02\x0bx", "\x0a\x02\x10\x01\x0a"} {
+		if got, err := UnmarshalInventoryProto([]byte(data)); err == nil {
+			t.Errorf("UnmarshalInventoryProto(%q): got %v, want an error", data, got)
+		}
+	}
+	if got, err := UnmarshalInventoryProto([]byte("\x0a\x02\x08\x01")); err == nil {
+		t.Errorf("UnmarshalInventoryProto() of a varint name: got %v, want an error", got)
+	}
+}
+
+func TestFormats(t *testing.T) {
+	for _, name := range []string{"csv", "cyclonedx", "json", "msgpack", "proto", "spdx", "yaml"} {
+		if _, ok := LookupFormat(name); !ok {
+			t.Errorf("LookupFormat(%q) not found", name)
+		}
+	}
+	if _, ok := LookupFormat("table"); ok {
+		t.Errorf("LookupFormat(table) found")
+	}
+	RegisterFormat("names", func(w io.Writer, pkgs []*PackageInfo) error {
+		for _, pkg := range pkgs {
+			fmt.Fprintln(w, pkg.Name)
+		}
+		return nil
+	})
+	defer func() {
+		formatsMu.Lock()
+		delete(formats, "names")
+		formatsMu.Unlock()
+	}()
+	if got := Formats(); !sort.StringsAreSorted(got) || len(got) != 8 {
+		t.Errorf("Formats() got %v", got)
+	}
+
+	pkgs := []*PackageInfo{
+		{Epoch: 1, Name: "libstdc++", Version: "4.8.5", Release: "28.el7", Arch: "x86_64", Vendor: "CentOS",
+			SourceRpm: "gcc-4.8.5-28.el7.src.rpm", Packager: "CentOS <http://bugs.centos.org>",
+			SignedBy: &PubKeyRef{KeyID: "24c6a8a7f4a80eb5"}, InstallTime: time.Unix(1500000000, 0).UTC()},
+		{Name: "bar", Version: "2", Release: "1", Arch: "noarch"},
+	}
+	if got, want := pkgs[0].PURL(), "pkg:rpm/centos/libstdc%2B%2B@4.8.5-28.el7?arch=x86_64&epoch=1&upstream=gcc-4.8.5-28.el7.src.rpm"; got != want {
+		t.Errorf("PURL() got %s, want %s", got, want)
+	}
+	if got, want := pkgs[1].PURL(), "pkg:rpm/bar@2-1?arch=noarch"; got != want {
+		t.Errorf("PURL() got %s, want %s", got, want)
+	}
+
+	write := func(name string) string {
+		t.Helper()
+		w, _ := LookupFormat(name)
+		var buf bytes.Buffer
+		if err := w(&buf, pkgs); err != nil {
+			t.Fatalf("%s: error: %v", name, err)
+		}
+		return buf.String()
+	}
+
+	want := `- epoch: 1
+  name: "libstdc++"
+  version: "4.8.5"
+  release: "28.el7"
+  arch: "x86_64"
+  source_rpm: "gcc-4.8.5-28.el7.src.rpm"
+  size: 0
+  vendor: "CentOS"
+  packager: "CentOS <http://bugs.centos.org>"
+  signed_by:
+    key_id: "24c6a8a7f4a80eb5"
+  install_time: "2017-07-14T02:40:00Z"
+  build_time: "0001-01-01T00:00:00Z"
+- name: "bar"
+  version: "2"
+  release: "1"
+  arch: "noarch"
+  size: 0
+  install_time: "0001-01-01T00:00:00Z"
+  build_time: "0001-01-01T00:00:00Z"
+`
+	if got := write("yaml"); got != want {
+		t.Errorf("yaml got\n%s\nwant\n%s", got, want)
+	}
+	if got := write("names"); got != "libstdc++\nbar\n" {
+		t.Errorf("names got %q", got)
+	}
+
+	records, err := csv.NewReader(strings.NewReader(write("csv"))).ReadAll()
+	if err != nil || len(records) != 3 || records[0][0] != "name" || records[1][0] != "libstdc++" {
+		t.Fatalf("csv got %v, %v", records, err)
+	}
+	for i, field := range records[0] {
+		if field == "install_time" && (records[1][i] != "2017-07-14T02:40:00Z" || records[2][i] != "") {
+			t.Errorf("csv install_time got %q, %q", records[1][i], records[2][i])
+		}
+	}
+
+	var bom struct {
+		BOMFormat  string `json:"bomFormat"`
+		Components []struct {
+			BOMRef  string `json:"bom-ref"`
+			Version string `json:"version"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal([]byte(write("cyclonedx")), &bom); err != nil {
+		t.Fatalf("cyclonedx error: %v", err)
+	}
+	if bom.BOMFormat != "CycloneDX" || len(bom.Components) != 2 || bom.Components[0].BOMRef != pkgs[0].PURL() || bom.Components[0].Version != "1:4.8.5-28.el7" {
+		t.Errorf("cyclonedx got %+v", bom)
+	}
+
+	var doc struct {
+		CreationInfo struct {
+			Created string `json:"created"`
+		} `json:"creationInfo"`
+		Packages []struct {
+			SPDXID   string `json:"SPDXID"`
+			Supplier string `json:"supplier"`
+		} `json:"packages"`
+		Relationships []struct {
+			Related string `json:"relatedSpdxElement"`
+		} `json:"relationships"`
+	}
+	spdx := write("spdx")
+	if err := json.Unmarshal([]byte(spdx), &doc); err != nil {
+		t.Fatalf("spdx error: %v", err)
+	}
+	if doc.CreationInfo.Created != "2017-07-14T02:40:00Z" || len(doc.Packages) != 2 || doc.Packages[1].Supplier != "NOASSERTION" ||
+		len(doc.Relationships) != 2 || doc.Relationships[1].Related != doc.Packages[1].SPDXID {
+		t.Errorf("spdx got %+v", doc)
+	}
+	if again := write("spdx"); again != spdx {
+		t.Errorf("spdx isn't deterministic")
+	}
+}
+
+func TestSignInventory(t *testing.T) {
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	pkgs, err := db.ListPackages()
+	if err != nil {
+		t.Fatalf("ListPackages() error: %v", err)
+	}
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, signer := range []crypto.Signer{edKey, ecKey, rsaKey} {
+		envelope, err := SignInventory(pkgs, signer)
+		if err != nil {
+			t.Fatalf("SignInventory(%T) error: %v", signer, err)
+		}
+		// the payload doesn't depend on the order of the packages
+		reversed := make([]*PackageInfo, len(pkgs))
+		for i, pkg := range pkgs {
+			reversed[len(pkgs)-1-i] = pkg
+		}
+		again, err := SignInventory(reversed, signer)
+		if err != nil {
+			t.Fatalf("SignInventory(%T) error: %v", signer, err)
+		}
+		if !bytes.Equal(again.Payload, envelope.Payload) {
+			t.Errorf("%T: payload depends on the package order", signer)
+		}
+
+		// through the JSON of the envelope
+		data, err := json.Marshal(envelope)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded Envelope
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		got, err := VerifyInventory(&decoded, signer.Public())
+		if err != nil {
+			t.Fatalf("VerifyInventory(%T) error: %v", signer, err)
+		}
+		if len(got) != len(pkgs) || got[0].NEVRA() != "acl-2.2.51-14.el7.x86_64" {
+			t.Errorf("%T: unexpected inventory: %d packages", signer, len(got))
+		}
+
+		if _, err := VerifyInventory(&decoded, otherKey.Public()); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%T: expected ErrInvalidSignature for another key, got %v", signer, err)
+		}
+		decoded.Payload = bytes.Replace(decoded.Payload, []byte("bash"), []byte("dash"), 1)
+		if _, err := VerifyInventory(&decoded, signer.Public()); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%T: expected ErrInvalidSignature for a tampered payload, got %v", signer, err)
+		}
+	}
+}
+
+func TestNewStatement(t *testing.T) {
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	if _, err := NewStatement(db); err == nil {
+		t.Error("expected an error without subject")
+	}
+
+	image := ResourceDescriptor{Name: "centos:7", Digest: map[string]string{"sha256": strings.Repeat("ab", 32)}}
+	statement, err := NewStatement(db, image, ResourceDescriptor{Name: "host"})
+	if err != nil {
+		t.Fatalf("NewStatement() error: %v", err)
+	}
+	manifest, err := Manifest(db, crypto.SHA256)
+	if err != nil {
+		t.Fatalf("Manifest() error: %v", err)
+	}
+	if !reflect.DeepEqual(statement.Subject[0], image) || statement.Subject[1].Digest["sha256"] != manifest.Digest {
+		t.Errorf("unexpected subjects: %+v", statement.Subject)
+	}
+	if len(statement.Predicate.Packages) != 144 {
+		t.Fatalf("unexpected packages: %d", len(statement.Predicate.Packages))
+	}
+	for i, p := range statement.Predicate.Packages {
+		if p.NEVRA != manifest.Entries[i].NEVRA || p.Digest["sha256"] != manifest.Entries[i].Digest {
+			t.Errorf("package %d: got %s %v, want %+v", i, p.NEVRA, p.Digest, manifest.Entries[i])
+		}
+	}
+
+	payload, err := statement.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes() error: %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(payload, &decoded); err != nil {
+		t.Fatalf("invalid statement: %v", err)
+	}
+	if decoded["_type"] != StatementType || decoded["predicateType"] != InventoryPredicateType {
+		t.Errorf("unexpected statement types: %v %v", decoded["_type"], decoded["predicateType"])
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope, err := SignEnvelope(StatementPayloadType, payload, key)
+	if err != nil {
+		t.Fatalf("SignEnvelope() error: %v", err)
+	}
+	if err := VerifyEnvelope(envelope, key.Public()); err != nil {
+		t.Errorf("VerifyEnvelope() error: %v", err)
+	}
+}
+
+// placeholderStorage yields the placeholder records of some databases before the headers of db.
+type placeholderStorage struct {
+	*bdb.BerkeleyDB
+}
+
+func (s placeholderStorage) ReadUntil(done <-chan struct{}) <-chan bdb.Entry {
+	entries := make(chan bdb.Entry)
+	go func() {
+		defer close(entries)
+		entries <- bdb.Entry{Key: []byte{0, 0, 0, 0}, Value: []byte{0xa9, 0, 0, 0}}
+		entries <- bdb.Entry{Key: []byte{0xaa, 0, 0, 0}}
+		entries <- bdb.Entry{Key: []byte{0xab, 0, 0, 0}, Value: make([]byte, 8)}
+		for entry := range s.BerkeleyDB.ReadUntil(done) {
+			entries <- entry
+		}
+	}()
+	return entries
+}
+
+func TestPlaceholderRecords(t *testing.T) {
+	for _, sorted := range []bool{false, true} {
+		db, err := bdb.Open("testdata/centos7-plain/Packages")
+		if err != nil {
+			t.Fatalf("Open() error: %v", err)
+		}
+		d := &RpmDB{db: placeholderStorage{db}, sorted: sorted}
+		pkgs, err := d.ListPackages()
+		if err != nil {
+			t.Fatalf("ListPackages(sorted=%v) error: %v", sorted, err)
+		}
+		if len(pkgs) != len(CentOS7Plain) {
+			t.Errorf("sorted=%v: got %d packages, want %d", sorted, len(pkgs), len(CentOS7Plain))
+		}
+
+		var dump bytes.Buffer
+		if err := d.ExportDump(&dump); err != nil {
+			t.Fatalf("ExportDump() error: %v", err)
+		}
+		if n := strings.Count(dump.String(), "\n 00000000\n"); n != 1 {
+			t.Errorf("sorted=%v: %d instance records", sorted, n)
+		}
+		d.Close()
+	}
+}
+
+func TestInterner(t *testing.T) {
+	// the address of the bytes of a string
+	data := func(s string) uintptr {
+		return (*reflect.StringHeader)(unsafe.Pointer(&s)).Data
+	}
+
+	interner := NewInterner()
+	var lists [][]*PackageInfo
+	for _, path := range []string{"testdata/centos7-plain/Packages", "testdata/centos7-httpd24/Packages"} {
+		plain, err := Open(path)
+		if err != nil {
+			t.Fatalf("Open() error: %v", err)
+		}
+		want, err := plain.ListPackages()
+		if err != nil {
+			t.Fatalf("ListPackages() error: %v", err)
+		}
+
+		db, err := Open(path, WithInterner(interner))
+		if err != nil {
+			t.Fatalf("Open() error: %v", err)
+		}
+		got, err := db.ListPackages()
+		if err != nil {
+			t.Fatalf("ListPackages() error: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: interned packages differ", path)
+		}
+		lists = append(lists, got)
+	}
+
+	first := lists[0][0]
+	for _, list := range lists {
+		for _, pkg := range list {
+			if pkg.Vendor == first.Vendor && data(pkg.Vendor) != data(first.Vendor) {
+				t.Fatalf("%s: vendor %q not interned", pkg.NEVRA(), pkg.Vendor)
+			}
+			if pkg.SignedBy != nil && first.SignedBy != nil && pkg.SignedBy.KeyID == first.SignedBy.KeyID &&
+				data(pkg.SignedBy.KeyID) != data(first.SignedBy.KeyID) {
+				t.Fatalf("%s: key ID not interned", pkg.NEVRA())
+			}
+		}
+	}
+	if n := interner.Len(); n == 0 || n > 4*(len(lists[0])+len(lists[1])) {
+		t.Errorf("unexpected interned strings: %d", n)
+	}
+
+	db, err := Open("testdata/centos7-plain/Packages", WithInterner(interner))
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	pkgs, err := db.ListPackagesWithTags(RPMTAG_DIRNAMES)
+	if err != nil {
+		t.Fatalf("ListPackagesWithTags() error: %v", err)
+	}
+	dirs := make(map[string]uintptr)
+	for _, pkg := range pkgs {
+		dirNames, _ := pkg.TagsMap[RPMTAG_DIRNAMES].([]string)
+		for _, dir := range dirNames {
+			if p, ok := dirs[dir]; ok && p != data(dir) {
+				t.Fatalf("%s: dirname %s not interned", pkg.NEVRA(), dir)
+			}
+			dirs[dir] = data(dir)
+		}
+	}
+	if len(dirs) == 0 {
+		t.Error("no dirnames")
+	}
+}
+
+func TestEntryPool(t *testing.T) {
+	tags := []TAG_ID{RPMTAG_BASENAMES, RPMTAG_DIRNAMES, RPMTAG_FILESIZES, RPMTAG_SIGMD5, RPMTAG_INSTALLTIME}
+	paths := []string{"testdata/centos6-plain/Packages", "testdata/centos7-plain/Packages", "testdata/centos7-httpd24/Packages"}
+
+	var wants, gots [][]*PackageInfoEx
+	for _, path := range paths {
+		plain, err := Open(path)
+		if err != nil {
+			t.Fatalf("Open() error: %v", err)
+		}
+		want, err := plain.ListPackagesWithTags(tags...)
+		if err != nil {
+			t.Fatalf("ListPackagesWithTags() error: %v", err)
+		}
+		wants = append(wants, want)
+
+		db, err := Open(path, WithEntryPool())
+		if err != nil {
+			t.Fatalf("Open() error: %v", err)
+		}
+		got, err := db.ListPackagesWithTags(tags...)
+		if err != nil {
+			t.Fatalf("ListPackagesWithTags() error: %v", err)
+		}
+		gots = append(gots, got)
+
+		wantPkgs, err := plain.ListPackages()
+		if err != nil {
+			t.Fatalf("ListPackages() error: %v", err)
+		}
+		gotPkgs, err := db.ListPackages()
+		if err != nil {
+			t.Fatalf("ListPackages() error: %v", err)
+		}
+		if !reflect.DeepEqual(gotPkgs, wantPkgs) {
+			t.Errorf("%s: pooled packages differ", path)
+		}
+	}
+
+	// the listings that reused the tables since returned the same packages
+	for i, path := range paths {
+		if !reflect.DeepEqual(gots[i], wants[i]) {
+			t.Errorf("%s: pooled packages with tags differ", path)
+		}
+	}
+}
+
+func TestWithoutFiles(t *testing.T) {
+	path := "testdata/centos7-plain/Packages"
+	plain, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	db, err := Open(path, WithoutFiles())
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+
+	want, err := plain.ListPackages()
+	if err != nil {
+		t.Fatalf("ListPackages() error: %v", err)
+	}
+	got, err := db.ListPackages()
+	if err != nil {
+		t.Fatalf("ListPackages() error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("packages without files differ")
+	}
+
+	pkgs, err := db.ListPackagesWithTags(RPMTAG_BASENAMES, RPMTAG_FILEDIGESTS, RPMTAG_SUMMARY, RPMTAG_INSTALLTIME)
+	if err != nil {
+		t.Fatalf("ListPackagesWithTags() error: %v", err)
+	}
+	for _, pkg := range pkgs {
+		for _, tag := range []TAG_ID{RPMTAG_BASENAMES, RPMTAG_FILEDIGESTS} {
+			if _, ok := pkg.TagsMap[tag]; ok {
+				t.Errorf("%s: unexpected tag %v", pkg.NEVRA(), tag)
+			}
+		}
+		for _, tag := range []TAG_ID{RPMTAG_SUMMARY, RPMTAG_INSTALLTIME} {
+			if _, ok := pkg.TagsMap[tag]; !ok {
+				t.Errorf("%s: missing tag %v", pkg.NEVRA(), tag)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := db.ExportFileOwners(&buf); err != nil {
+		t.Fatalf("ExportFileOwners() error: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("unexpected file owners: %q", buf.String())
+	}
+}
+
+func TestProfileHeaders(t *testing.T) {
+	db, err := Open("testdata/centos7-many/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	profile, err := db.ProfileHeaders(3)
+	if err != nil {
+		t.Fatalf("ProfileHeaders() error: %v", err)
+	}
+	if profile.Headers != len(CentOS7Many) || profile.FileBytes+profile.ChangelogBytes+profile.OtherBytes != profile.Bytes {
+		t.Errorf("got %d headers, %d bytes of files, %d of changelogs and %d others for %d", profile.Headers,
+			profile.FileBytes, profile.ChangelogBytes, profile.OtherBytes, profile.Bytes)
+	}
+	// every byte but the lengths heading the blobs belongs to a tag
+	total := int64(8 * profile.Headers)
+	for i, tag := range profile.Tags {
+		total += tag.Bytes
+		if i > 0 && tag.Bytes > profile.Tags[i-1].Bytes {
+			t.Errorf("tags not sorted: %v after %v", tag.Tag, profile.Tags[i-1].Tag)
+		}
+	}
+	if total != profile.Bytes {
+		t.Errorf("tags: got %d bytes, want %d", total, profile.Bytes)
+	}
+	if top := profile.Tags[0]; top.Tag != RPMTAG_CHANGELOGTEXT || top.Headers != len(CentOS7Many)-1 {
+		t.Errorf("largest tag: got %v of %d headers", top.Tag, top.Headers)
+	}
+	if len(profile.Largest) != 3 || profile.Largest[0].Package.Name != "kernel-debug-devel" ||
+		profile.Largest[1].Bytes > profile.Largest[0].Bytes || profile.Largest[2].Bytes > profile.Largest[1].Bytes {
+		t.Errorf("largest headers: got %v", profile.Largest)
+	}
+
+	// the options combine
+	db, err = Open("testdata/centos7-many/Packages", WithoutChangelogs(), WithoutFiles())
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+	pkgs, err := db.ListPackagesWithTags(RPMTAG_CHANGELOGTEXT, RPMTAG_BASENAMES, RPMTAG_SUMMARY)
+	if err != nil {
+		t.Fatalf("ListPackagesWithTags() error: %v", err)
+	}
+	for _, pkg := range pkgs {
+		if _, ok := pkg.TagsMap[RPMTAG_CHANGELOGTEXT]; ok {
+			t.Errorf("%s: unexpected changelog", pkg.NEVRA())
+		}
+		if _, ok := pkg.TagsMap[RPMTAG_BASENAMES]; ok {
+			t.Errorf("%s: unexpected files", pkg.NEVRA())
+		}
+	}
+}
+
+func TestChangelogSpans(t *testing.T) {
+	db, err := Open("testdata/centos7-many/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	spans := func(policy *ChangelogPolicy) map[string]*ChangelogSpan {
+		list, err := db.ChangelogSpans(policy)
+		if err != nil {
+			t.Fatalf("ChangelogSpans() error: %v", err)
+		}
+		if len(list) != len(CentOS7Many)-1 {
+			t.Errorf("got %d spans, want %d", len(list), len(CentOS7Many)-1)
+		}
+		byName := make(map[string]*ChangelogSpan)
+		for _, span := range list {
+			byName[span.Package.Name] = span
+		}
+		return byName
+	}
+	day := func(s string) time.Time {
+		tm, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+
+	got := spans(nil)
+	// the oldest entry of the RHEL 7 kernels points to the git history
+	kernel := got["kernel-headers"]
+	if !kernel.Trimmed || kernel.Initial || kernel.Entries != 970 || !kernel.Earliest.Equal(day("2013-06-03 12:00")) || !kernel.Latest.Equal(day("2019-03-12 12:00")) {
+		t.Errorf("kernel-headers: got %+v", kernel)
+	}
+	if kernel.Covers(day("2012-01-01 00:00")) || !kernel.Covers(day("2014-01-01 00:00")) {
+		t.Errorf("kernel-headers: unexpected coverage")
+	}
+	tzdata, bash := got["tzdata"], got["bash"]
+	if !tzdata.Initial || tzdata.Trimmed || !tzdata.Covers(day("1990-01-01 00:00")) {
+		t.Errorf("tzdata: got %+v", tzdata)
+	}
+	if bash.Initial || bash.Trimmed || !bash.Earliest.Equal(day("1997-06-03 12:00")) {
+		t.Errorf("bash: got %+v", bash)
+	}
+
+	// with a cutoff, the changelogs starting after it without initial entry are trimmed
+	got = spans(&ChangelogPolicy{TrimTime: day("1997-06-03 00:00")})
+	if bash, tzdata := got["bash"], got["tzdata"]; !bash.Trimmed || tzdata.Trimmed || bash.Covers(day("1997-01-01 00:00")) {
+		t.Errorf("trim time: got bash %+v, tzdata %+v", bash, tzdata)
+	}
+	got = spans(&ChangelogPolicy{TrimAge: 2 * 365 * 24 * time.Hour})
+	if bash := got["bash"]; bash.Trimmed {
+		t.Errorf("trim age: got bash %+v", bash)
+	}
+}
+
+func TestPackageNames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-rpmdb")
+	if err != nil {
+		t.Fatalf("TempDir() error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if _, err := Rebuild("testdata/centos7-plain/Packages", filepath.Join(dir, "Packages")); err != nil {
+		t.Fatalf("Rebuild() error: %v", err)
+	}
+
+	db, err := Open(filepath.Join(dir, "Packages"))
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+	pkgs, err := db.ListPackages()
+	if err != nil {
+		t.Fatalf("ListPackages() error: %v", err)
+	}
+	seen := make(map[string]bool)
+	var want []string
+	for _, pkg := range pkgs {
+		if !seen[pkg.Name] {
+			seen[pkg.Name] = true
+			want = append(want, pkg.Name)
+		}
+	}
+	sort.Strings(want)
+
+	// without index every header is read
+	got, err := db.PackageNames()
+	if err != nil {
+		t.Fatalf("PackageNames() error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("names: got %v, want %v", got, want)
+	}
+
+	// an index naming two packages only tells it is the one read
+	w, err := bdb.Create(filepath.Join(dir, "Name"), 4096)
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	item := []byte{1, 0, 0, 0, 0, 0, 0, 0}
+	for _, record := range []struct {
+		key, value []byte
+	}{
+		{[]byte("zlib"), item},
+		{[]byte("bash"), append(append([]byte(nil), item...), 2, 0, 0, 0, 0, 0, 0, 0)},
+		{[]byte("erased"), nil},
+	} {
+		if err := w.Put(record.key, record.value); err != nil {
+			t.Fatalf("Put() error: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	got, err = db.PackageNames()
+	if err != nil {
+		t.Fatalf("PackageNames() error: %v", err)
+	}
+	if want := []string{"bash", "zlib"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("indexed names: got %v, want %v", got, want)
+	}
+}
+
+func TestAggregator(t *testing.T) {
+	aggregator, err := NewAggregator(crypto.SHA256)
+	if err != nil {
+		t.Fatalf("NewAggregator() error: %v", err)
+	}
+	sources := map[string]string{
+		"plain":      "testdata/centos7-plain/Packages",
+		"plain-copy": "testdata/centos7-plain/Packages",
+		"httpd24":    "testdata/centos7-httpd24/Packages",
+	}
+	for source, path := range sources {
+		db, err := Open(path)
+		if err != nil {
+			t.Fatalf("Open() error: %v", err)
+		}
+		if err := aggregator.Add(source, db); err != nil {
+			t.Fatalf("Add(%s) error: %v", source, err)
+		}
+		db.Close()
+	}
+
+	// httpd24 upgrades 65 packages of plain and installs 81 more
+	if got, want := aggregator.Len(), 144+65+81; got != want {
+		t.Errorf("Len() = %d, want %d", got, want)
+	}
+
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+	manifest, err := Manifest(db, crypto.SHA256)
+	if err != nil {
+		t.Fatalf("Manifest() error: %v", err)
+	}
+	digests := make(map[string]string)
+	for _, entry := range manifest.Entries {
+		digests[entry.NEVRA] = entry.Digest
+	}
+
+	counts := make(map[int]int)
+	bash := false
+	for _, pkg := range aggregator.Packages() {
+		counts[len(pkg.Sources)]++
+		if digest, ok := digests[pkg.Package.NEVRA()]; ok && digest != pkg.Digest {
+			t.Errorf("%s: digest %s, want %s", pkg.Package.NEVRA(), pkg.Digest, digest)
+		}
+		// upgraded in httpd24
+		if pkg.Package.Name == "bash" && pkg.Package.Release == "30.el7" {
+			bash = true
+			if want := []string{"plain", "plain-copy"}; !reflect.DeepEqual(pkg.Sources, want) {
+				t.Errorf("%s: sources %v, want %v", pkg.Package.NEVRA(), pkg.Sources, want)
+			}
+		}
+	}
+	if !bash {
+		t.Errorf("bash of plain not aggregated")
+	}
+	if want := map[int]int{1: 146, 2: 65, 3: 79}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("packages by source count: got %v, want %v", counts, want)
+	}
+}
+
+func TestRenderDependencyGraph(t *testing.T) {
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+	graph, err := db.DependencyGraph()
+	if err != nil {
+		t.Fatalf("DependencyGraph() error: %v", err)
+	}
+	bash := graph.Lookup("bash")[0]
+	glibc := graph.Lookup("glibc")[0]
+
+	var buf bytes.Buffer
+	if err := graph.WriteDOT(&buf, bash, GraphRequires, 1); err != nil {
+		t.Fatalf("WriteDOT() error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if want := fmt.Sprintf("\t%q [style=bold];", bash.Package.NEVRA()); lines[2] != want {
+		t.Errorf("root: got %q, want %q", lines[2], want)
+	}
+	if want := 3 + 1 + 2*len(bash.Requires); len(lines) != want {
+		t.Errorf("DOT lines: got %d, want %d", len(lines), want)
+	}
+	edge := fmt.Sprintf("\t%q -> %q;", bash.Package.NEVRA(), glibc.Package.NEVRA())
+	if !strings.Contains(buf.String(), edge+"\n") {
+		t.Errorf("missing edge %s", edge)
+	}
+
+	// the reverse tree of glibc reaches every dependent, edges still pointing to glibc
+	buf.Reset()
+	if err := graph.WriteMermaid(&buf, glibc, GraphRequiredBy, 0); err != nil {
+		t.Fatalf("WriteMermaid() error: %v", err)
+	}
+	lines = strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if want := fmt.Sprintf("    n0[%q]", glibc.Package.NEVRA()); lines[0] != "flowchart LR" || lines[1] != want {
+		t.Errorf("header: got %q, want flowchart LR, %q", lines[:2], want)
+	}
+	nodes, edges := 0, 0
+	for _, line := range lines[1:] {
+		if strings.HasSuffix(line, "]") {
+			nodes++
+		} else {
+			edges++
+		}
+	}
+	// every node of the tree is expanded without depth limit
+	dependents := append(graph.Dependents(glibc), glibc)
+	wantEdges := 0
+	for _, node := range dependents {
+		wantEdges += len(node.RequiredBy)
+	}
+	if nodes != len(dependents) || edges != wantEdges {
+		t.Errorf("Mermaid: got %d nodes, %d edges, want %d, %d", nodes, edges, len(dependents), wantEdges)
+	}
+}
+
+func TestEvaluatePolicies(t *testing.T) {
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+	pkgs, err := db.ListPackages()
+	if err != nil {
+		t.Fatalf("ListPackages() error: %v", err)
+	}
+
+	count := PolicyFunc(func(ctx context.Context, input *PolicyInput) ([]Violation, error) {
+		if len(input.Packages) > 100 {
+			return []Violation{{Policy: "size", Message: fmt.Sprintf("%d packages", len(input.Packages))}}, nil
+		}
+		return nil, nil
+	})
+	violations, err := EvaluatePolicies(context.Background(), pkgs,
+		AllowedVendors("vendor", "CentOS"),
+		ForbiddenLicenses("license", "GPLv3+", "GPL-3.0-only"),
+		count,
+	)
+	if err != nil {
+		t.Fatalf("EvaluatePolicies() error: %v", err)
+	}
+
+	policies := make(map[string]int)
+	bash := false
+	for _, violation := range violations {
+		policies[violation.Policy]++
+		if violation.Package == "bash-4.2.46-30.el7.x86_64" {
+			bash = violation.Policy == "license" && violation.Message == "forbidden license GPLv3+"
+		}
+	}
+	if policies["vendor"] != 0 || policies["license"] == 0 || policies["size"] != 1 {
+		t.Errorf("unexpected violations by policy: %v", policies)
+	}
+	if !bash {
+		t.Errorf("bash license not reported")
+	}
+	if last := violations[len(violations)-1]; last.Policy != "size" || last.Package != "" {
+		t.Errorf("violations not in policy order: last %+v", last)
+	}
+
+	violations, err = EvaluatePolicies(context.Background(), pkgs, AllowedVendors("vendor", "Red Hat, Inc."))
+	if err != nil || len(violations) != len(pkgs) {
+		t.Errorf("vendor violations: got %d, %v, want %d", len(violations), err, len(pkgs))
+	}
+
+	failing := PolicyFunc(func(ctx context.Context, input *PolicyInput) ([]Violation, error) {
+		return nil, ErrNotSupport
+	})
+	if _, err := EvaluatePolicies(context.Background(), pkgs, count, failing); !errors.Is(err, ErrNotSupport) {
+		t.Errorf("failing policy: got %v", err)
+	}
+
+	if got := licenseIDs("(MIT OR Apache-2.0) and GPLv2+ with exceptions"); !reflect.DeepEqual(got, []string{"MIT", "Apache-2.0", "GPLv2+"}) {
+		t.Errorf("licenseIDs() = %v", got)
+	}
+}
+
+func TestVersionLocks(t *testing.T) {
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+	pkgs, err := db.ListPackages()
+	if err != nil {
+		t.Fatalf("ListPackages() error: %v", err)
+	}
+	versions := make(map[string]string)
+	for _, pkg := range pkgs {
+		versions[pkg.Name] = pkg.Version + "-" + pkg.Release
+	}
+
+	dir, err := ioutil.TempDir("", "versionlock")
+	if err != nil {
+		t.Fatalf("TempDir() error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	// dnf and yum entries, in the lists of an image
+	lists := map[string]string{
+		"etc/dnf/plugins/versionlock.list":      "# added by dnf versionlock\nbash-0:" + versions["bash"] + ".*\n\n!glibc-common-0:*\n",
+		"etc/yum/pluginconf.d/versionlock.list": "0:zlib-" + versions["zlib"] + ".*\nopenssl-libs-0.9.*\n",
+	}
+	for name, content := range lists {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	locks, err := ReadRootVersionLocks(dir)
+	if err != nil {
+		t.Fatalf("ReadRootVersionLocks() error: %v", err)
+	}
+	if len(locks.Locks) != 3 || !reflect.DeepEqual(locks.Excludes, []string{"glibc-common-0:*"}) {
+		t.Errorf("unexpected locks: %+v", *locks)
+	}
+
+	ApplyVersionLocks(pkgs, locks)
+	var locked []string
+	for _, pkg := range pkgs {
+		if pkg.VersionLocked {
+			locked = append(locked, pkg.Name)
+		}
+	}
+	sort.Strings(locked)
+	if want := []string{"bash", "zlib"}; !reflect.DeepEqual(locked, want) {
+		t.Errorf("locked packages: got %v, want %v", locked, want)
+	}
+
+	if _, err := ReadVersionLocks(strings.NewReader("bash-[4\n")); err == nil {
+		t.Errorf("invalid entry accepted")
+	}
+	if locks, err := ReadRootVersionLocks(filepath.Join(dir, "empty")); err != nil || len(locks.Locks) != 0 {
+		t.Errorf("root without lists: got %+v, %v", locks, err)
+	}
+}
+
+func TestFindModules(t *testing.T) {
+	pkgs := []*PackageInfo{
+		{Name: "nodejs", Version: "10.24.0", Release: "1.module_el8.3.0+717+fa496f1d", ModularityLabel: "nodejs:10:8030020210304194401:30b713e6"},
+		{Name: "npm", Version: "6.14.11", Release: "1.10.24.0.1.module_el8.3.0+717+fa496f1d", ModularityLabel: "nodejs:10:8030020210304194401:30b713e6"},
+		{Name: "nodejs-docs", Version: "10.23.1", Release: "1.module_el8.3.0+625+6c0e5ab5", ModularityLabel: "nodejs:10:8030020210113194500:30b713e6"},
+		{Name: "nodejs-nodemon", Version: "2.0.3", Release: "1.module_el8.4.0+750+a6b6c67c", ModularityLabel: "nodejs:14:8040020210416145651:bd1311ed"},
+		{Name: "perl-DBI", Version: "1.641", Release: "3.module_el8.1.0+199+8f0a7bd7", ModularityLabel: "perl-DBI:1.641:8010020190322130042:16b3ab4d"},
+		{Name: "bash", Version: "4.4.19", Release: "14.el8"},
+		{Name: "broken", Version: "1", Release: "1", ModularityLabel: "broken:1"},
+	}
+	report := FindModules(pkgs)
+
+	var streams []string
+	for _, stream := range report.Streams {
+		streams = append(streams, fmt.Sprintf("%s:%s %v %d", stream.Name, stream.Stream, stream.Builds, len(stream.Packages)))
+	}
+	want := []string{
+		"nodejs:10 [8030020210113194500:30b713e6 8030020210304194401:30b713e6] 3",
+		"nodejs:14 [8040020210416145651:bd1311ed] 1",
+		"perl-DBI:1.641 [8010020190322130042:16b3ab4d] 1",
+	}
+	if !reflect.DeepEqual(streams, want) {
+		t.Errorf("streams: got %q, want %q", streams, want)
+	}
+	if !reflect.DeepEqual(report.Mixed, []string{"nodejs"}) {
+		t.Errorf("mixed: got %v, want [nodejs]", report.Mixed)
+	}
+	if len(report.Invalid) != 1 || report.Invalid[0].Name != "broken" {
+		t.Errorf("invalid: got %v", report.Invalid)
+	}
+
+	label, err := ParseModularityLabel(pkgs[0].ModularityLabel)
+	if err != nil || label.Stream != "10" || label.String() != pkgs[0].ModularityLabel {
+		t.Errorf("ParseModularityLabel() = %+v, %v", label, err)
+	}
+	if _, err := ParseModularityLabel("nodejs::1:2"); err == nil {
+		t.Errorf("empty stream accepted")
+	}
+}
+
+func TestDefaultPaths(t *testing.T) {
+	root, err := ioutil.TempDir("", "rpmdb")
+	if err != nil {
+		t.Fatalf("TempDir() error: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	// /var/lib/rpm linking to the database moved to /usr, converted to sqlite and left over
+	for _, name := range []string{"usr/lib/sysimage/rpm/rpmdb.sqlite", "usr/lib/sysimage/rpm/Packages", "usr/share/rpm/Packages"} {
+		name = filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(root, "var", "lib"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../../usr/lib/sysimage/rpm", filepath.Join(root, "var", "lib", "rpm")); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, candidate := range DefaultPaths(root) {
+		got = append(got, fmt.Sprintf("%s %s %s", candidate.Path, candidate.Backend, candidate.HostPath[len(root):]))
+	}
+	want := []string{
+		"/var/lib/rpm/rpmdb.sqlite sqlite /usr/lib/sysimage/rpm/rpmdb.sqlite",
+		"/var/lib/rpm/Packages bdb /usr/lib/sysimage/rpm/Packages",
+		"/usr/share/rpm/Packages bdb /usr/share/rpm/Packages",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DefaultPaths(): got %q, want %q", got, want)
+	}
+}
+
+// writeCounter records the writes of StreamPackages, failing those after the first fail.
+type writeCounter struct {
+	lines [][]byte
+	fail  int
+}
+
+func (w *writeCounter) Write(p []byte) (int, error) {
+	if w.fail > 0 && len(w.lines) >= w.fail {
+		return 0, errors.New("disk full")
+	}
+	w.lines = append(w.lines, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func TestStreamPackages(t *testing.T) {
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkgs, err := db.ListPackages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := &writeCounter{}
+	if err := db.StreamPackages(w, &StreamOptions{Root: "/host"}); err != nil {
+		t.Fatalf("StreamPackages() error: %v", err)
+	}
+	// a write per package
+	if len(w.lines) != len(pkgs) {
+		t.Fatalf("StreamPackages(): got %d writes, want %d", len(w.lines), len(pkgs))
+	}
+	for i, line := range w.lines {
+		var got struct {
+			Root string `json:"root"`
+			PackageInfo
+		}
+		if err := json.Unmarshal(line, &got); err != nil {
+			t.Fatalf("line %d: %v", i, err)
+		}
+		if got.Root != "/host" || got.NEVRA() != pkgs[i].NEVRA() || got.Size != pkgs[i].Size {
+			t.Errorf("line %d: got %s %s, want /host %s", i, got.Root, got.NEVRA(), pkgs[i].NEVRA())
+		}
+		if (got.SignedBy == nil) != (pkgs[i].SignedBy == nil) ||
+			(got.SignedBy != nil && got.SignedBy.KeyID != pkgs[i].SignedBy.KeyID) {
+			t.Errorf("line %d: got signer %+v, want %+v", i, got.SignedBy, pkgs[i].SignedBy)
+		}
+	}
+
+	w = &writeCounter{fail: 3}
+	err = db.StreamPackages(w, nil)
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("StreamPackages() to a full disk: got error %v", err)
+	}
+	if len(w.lines) != 3 || bytes.Contains(w.lines[0], []byte(`"root"`)) {
+		t.Errorf("StreamPackages() to a full disk: got %d writes, first %s", len(w.lines), w.lines[0])
+	}
+
+	// a client gone away stops the reading of the database with the stream
+	checkGoroutines(t, func() {
+		for i := 0; i < 20; i++ {
+			if err := db.StreamPackages(&writeCounter{fail: 1}, nil); err == nil {
+				t.Fatalf("StreamPackages() to a closed client: expected an error")
+			}
+		}
+	})
+}
+
+func TestTimeTags(t *testing.T) {
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	pkgs, err := db.ListPackagesWithTags(RPMTAG_INSTALLTIME, RPMTAG_BUILDTIME, RPMTAG_CHANGELOGTIME, RPMTAG_CHANGELOGNAME)
+	if err != nil {
+		t.Fatalf("ListPackagesWithTags() error: %v", err)
+	}
+	for _, pkg := range pkgs {
+		if pkg.InstallTime.IsZero() || pkg.InstallTime.Location() != time.UTC || pkg.TagsMap[RPMTAG_INSTALLTIME] != pkg.InstallTime {
+			t.Errorf("%s: got install time %v, tag %v", pkg.NEVRA(), pkg.InstallTime, pkg.TagsMap[RPMTAG_INSTALLTIME])
+		}
+		if pkg.TagsMap[RPMTAG_BUILDTIME] != pkg.BuildTime || pkg.BuildTime.After(pkg.InstallTime) {
+			t.Errorf("%s: got build time %v, installed %v", pkg.NEVRA(), pkg.BuildTime, pkg.InstallTime)
+		}
+		if pkg.Name != "bash" {
+			continue
+		}
+		times, ok := pkg.TagsMap[RPMTAG_CHANGELOGTIME].([]time.Time)
+		if !ok || len(times) != len(pkg.TagsMap[RPMTAG_CHANGELOGNAME].([]string)) {
+			t.Fatalf("unexpected changelog times: %v", pkg.TagsMap[RPMTAG_CHANGELOGTIME])
+		}
+		// the newest entry comes first, at noon UTC of its day
+		if times[0].Hour() != 12 || !times[0].After(times[len(times)-1]) {
+			t.Errorf("unexpected changelog times: %v", times)
+		}
+	}
+
+	b := NewHeaderBuilder()
+	b.AddInt32(RPMTAG_INSTALLTIME, 1500000000)
+	blob, err := b.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes() error: %v", err)
+	}
+	var dump bytes.Buffer
+	if err := DumpHeaderIn(blob, &dump, time.FixedZone("JST", 9*60*60)); err != nil {
+		t.Fatalf("DumpHeaderIn() error: %v", err)
+	}
+	if !strings.Contains(dump.String(), "1500000000 (2017-07-14T11:40:00+09:00)") {
+		t.Errorf("DumpHeaderIn() got %q", dump.String())
+	}
+
+	var lines bytes.Buffer
+	if err := db.StreamPackages(&lines, &StreamOptions{Location: time.FixedZone("CET", 60*60)}); err != nil {
+		t.Fatalf("StreamPackages() error: %v", err)
+	}
+	line, err := lines.ReadBytes('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		InstallTime string `json:"install_time"`
+	}
+	if err := json.Unmarshal(line, &got); err != nil || !strings.HasSuffix(got.InstallTime, "+01:00") {
+		t.Errorf("StreamPackages() got install time %q, %v", got.InstallTime, err)
+	}
+}
+
+func TestStringHandling(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	b := verifyTestHeader([]testFile{{path: "/usr/share/doc/caf\xe9", mode: modeRegular | 0644, content: "x"}}, 1500000000)
+	b.AddString(RPMTAG_SUMMARY, "Biblioth\xe8que")
+	b.add(RPMTAG_VENDOR, RPM_STRING_TYPE, 1, []byte("Acme\x00junk\x00"))
+	valid := NewHeaderBuilder()
+	valid.AddString(RPMTAG_NAME, "bar")
+	valid.AddString(RPMTAG_VERSION, "1.0")
+	valid.AddString(RPMTAG_RELEASE, "1")
+	// large enough for an overflow page, where rpm stores the headers
+	valid.AddString(RPMTAG_DESCRIPTION, strings.Repeat("bar ", 1024))
+	dbPath := createTestDB(t, dir, b, valid)
+
+	tags := []TAG_ID{RPMTAG_SUMMARY, RPMTAG_OLDFILENAMES}
+	list := func(h StringHandling) ([]*PackageInfoEx, error) {
+		db, err := Open(dbPath, WithStringHandling(h))
+		if err != nil {
+			t.Fatalf("Open() error: %v", err)
+		}
+		defer db.Close()
+		return db.ListPackagesWithTags(tags...)
+	}
+
+	raw, err := list(StringsRaw)
+	if err != nil {
+		t.Fatalf("ListPackagesWithTags() error: %v", err)
+	}
+	if len(raw) != 2 || raw[0].Vendor != "Acme\x00junk" || raw[0].TagsMap[RPMTAG_SUMMARY] != "Biblioth\xe8que" {
+		t.Errorf("raw strings: got %q, %q", raw[0].Vendor, raw[0].TagsMap[RPMTAG_SUMMARY])
+	}
+
+	sanitized, err := list(StringsSanitize)
+	if err != nil {
+		t.Fatalf("ListPackagesWithTags() error: %v", err)
+	}
+	if sanitized[0].Vendor != "Acme" || sanitized[0].TagsMap[RPMTAG_SUMMARY] != "Biblioth\uFFFDque" ||
+		!reflect.DeepEqual(sanitized[0].TagsMap[RPMTAG_OLDFILENAMES], []string{"/usr/share/doc/caf\uFFFD"}) {
+		t.Errorf("sanitized strings: got %q, %q, %q", sanitized[0].Vendor, sanitized[0].TagsMap[RPMTAG_SUMMARY], sanitized[0].TagsMap[RPMTAG_OLDFILENAMES])
+	}
+	if len(sanitized) != 2 || !reflect.DeepEqual(sanitized[1], raw[1]) {
+		t.Errorf("valid package changed: got %+v, want %+v", sanitized[1], raw[1])
+	}
+	data, err := json.Marshal(sanitized)
+	if err != nil || !utf8.Valid(data) || bytes.Contains(data, []byte(`\u0000`)) {
+		t.Errorf("Marshal() got %s, %v", data, err)
+	}
+
+	if _, err := list(StringsStrict); !errors.Is(err, ErrInvalidString) {
+		t.Errorf("ListPackagesWithTags() with StringsStrict got error %v", err)
+	}
+
+	// the last string of an array doesn't keep the padding up to the next entry
+	db, err := Open("testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	pkgs, err := db.ListPackagesWithTags(RPMTAG_BASENAMES, RPMTAG_REQUIRENAME)
+	if err != nil {
+		t.Fatalf("ListPackagesWithTags() error: %v", err)
+	}
+	for _, pkg := range pkgs {
+		for tag, v := range pkg.TagsMap {
+			for _, s := range v.([]string) {
+				if strings.Contains(s, "\x00") {
+					t.Errorf("%s: %v: got %q", pkg.NEVRA(), tag, s)
+				}
+			}
+		}
+	}
+}
+
+// testdata/sqlite-centos7/rpmdb.sqlite is made by python's sqlite3 from the headers of
+// testdata/centos7-plain with the schema of rpm 4.16: the Packages table and the index tables of the
+// string tags, each with its key and hnum indexes.
+func TestSQLiteBackend(t *testing.T) {
+	open := func(path string) *RpmDB {
+		db, err := Open(path, WithSortedOutput())
+		if err != nil {
+			t.Fatalf("Open(%s) error: %v", path, err)
+		}
+		return db
+	}
+	sqliteDB, bdbDB := open("testdata/sqlite-centos7/rpmdb.sqlite"), open("testdata/centos7-plain/Packages")
+	defer sqliteDB.Close()
+	defer bdbDB.Close()
+
+	got, err := sqliteDB.ListPackages()
+	if err != nil {
+		t.Fatalf("ListPackages() error: %v", err)
+	}
+	want, err := bdbDB.ListPackages()
+	if err != nil {
+		t.Fatalf("ListPackages() error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListPackages(): got %d packages, want %d", len(got), len(want))
+	}
+
+	gotNames, err := sqliteDB.PackageNames()
+	if err != nil {
+		t.Fatalf("PackageNames() error: %v", err)
+	}
+	wantNames, err := bdbDB.PackageNames()
+	if err != nil {
+		t.Fatalf("PackageNames() error: %v", err)
+	}
+	if !reflect.DeepEqual(gotNames, wantNames) {
+		t.Errorf("PackageNames(): got %v, want %v", gotNames, wantNames)
+	}
+
+	if _, ok, err := sqliteDB.lookupIndex("Basenames", "bash"); err != nil || !ok {
+		t.Fatalf("lookupIndex(Basenames) = %v, %v", ok, err)
+	}
+	if _, err := sqliteDB.BackendStats(); !errors.Is(err, ErrNotSupport) {
+		t.Errorf("BackendStats(): got %v, want ErrNotSupport", err)
+	}
+
+	// the indexed lookups find what the full scans of the bdb fallback do, in hnum order
+	sqliteDB.sorted, bdbDB.sorted = false, false
+	nevras := func(pkgs []*PackageInfo, err error) []string {
+		if err != nil {
+			t.Fatalf("lookup error: %v", err)
+		}
+		var list []string
+		for _, pkg := range pkgs {
+			list = append(list, pkg.NEVRA())
+		}
+		sort.Strings(list)
+		return list
+	}
+	for _, file := range []string{"/usr/bin/bash", "/etc/passwd", "/usr/lib64/libc.so.6", "/usr/share/doc", "/missing"} {
+		got, want := nevras(sqliteDB.WhoOwns(file, nil)), nevras(bdbDB.WhoOwns(file, nil))
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("WhoOwns(%s): got %v, want %v", file, got, want)
+		}
+	}
+	for _, capability := range []string{"bash", "/bin/sh", "libc.so.6()(64bit)", "config(setup)", "missing"} {
+		got, want := nevras(sqliteDB.WhatProvides(capability)), nevras(bdbDB.WhatProvides(capability))
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("WhatProvides(%s): got %v, want %v", capability, got, want)
+		}
+		got, want = nevras(sqliteDB.WhatRequires(capability)), nevras(bdbDB.WhatRequires(capability))
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("WhatRequires(%s): got %v, want %v", capability, got, want)
+		}
+		if capability == "/bin/sh" && len(got) == 0 {
+			t.Errorf("WhatRequires(/bin/sh): no package")
+		}
+	}
+}
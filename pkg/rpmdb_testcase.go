@@ -1,11 +1,24 @@
 package rpmdb
 
+// testPackageInfo holds the PackageInfo fields printed by the rpm -qa queryformat used below.
+type testPackageInfo struct {
+	Epoch     int
+	Name      string
+	Version   string
+	Release   string
+	Arch      string
+	SourceRpm string
+	Size      int
+	License   string
+	Vendor    string
+}
+
 var (
 
 	// docker run --rm -it centos:6 bash
 	// yum groupinstall -y "Development tools"
 	// rpm -qa --queryformat "\{%{EPOCH}, \"%{NAME}\", \"%{VERSION}\", \"%{RELEASE}\", \"%{ARCH}\", \"%{SOURCERPM}\", %{SIZE}, \"%{LICENSE}\", \"%{VENDOR}\"\},\n" | sed "s/^{(none)/{0/g" | sed "s/(none)//g"
-	CentOS6DevTools = []PackageInfo{
+	CentOS6DevTools = []testPackageInfo{
 		{0, "iproute", "2.6.32", "57.el6", "x86_64", "iproute-2.6.32-57.el6.src.rpm", 963477, "GPLv2+ and Public Domain", "CentOS"},
 		{0, "setup", "2.8.14", "23.el6", "noarch", "setup-2.8.14-23.el6.src.rpm", 666669, "Public Domain", "CentOS"},
 		{0, "iputils", "20071127", "24.el6", "x86_64", "iputils-20071127-24.el6.src.rpm", 297243, "BSD with advertising and GPLv2+ and Rdisc", "CentOS"},
@@ -278,7 +291,7 @@ var (
 	// yum install -y ncurses-devel newt-devel numactl-devel pciutils-devel python-devel zlib-devel
 	// yum install -y net-tools bc
 	// rpm -qa --queryformat "\{%{EPOCH}, \"%{NAME}\", \"%{VERSION}\", \"%{RELEASE}\", \"%{ARCH}\", \"%{SOURCERPM}\", %{SIZE}, \"%{LICENSE}\", \"%{VENDOR}\"\},\n" | sed "s/^{(none)/{0/g" | sed "s/(none)//g"
-	CentOS6Many = []PackageInfo{
+	CentOS6Many = []testPackageInfo{
 		{0, "iproute", "2.6.32", "57.el6", "x86_64", "iproute-2.6.32-57.el6.src.rpm", 963477, "GPLv2+ and Public Domain", "CentOS"},
 		{0, "setup", "2.8.14", "23.el6", "noarch", "setup-2.8.14-23.el6.src.rpm", 666669, "Public Domain", "CentOS"},
 		{0, "iputils", "20071127", "24.el6", "x86_64", "iputils-20071127-24.el6.src.rpm", 297243, "BSD with advertising and GPLv2+ and Rdisc", "CentOS"},
@@ -609,7 +622,7 @@ var (
 
 	// docker run --rm -it centos:6 bash
 	// rpm -qa --queryformat "\{%{EPOCH}, \"%{NAME}\", \"%{VERSION}\", \"%{RELEASE}\", \"%{ARCH}\"\, \"%{SOURCERPM}\", %{SIZE}, \"%{LICENSE}\", \"%{VENDOR}\"\},\n" | sed "s/(none)/0/g"
-	CentOS6Plain = []PackageInfo{
+	CentOS6Plain = []testPackageInfo{
 		{0, "setup", "2.8.14", "23.el6", "noarch", "setup-2.8.14-23.el6.src.rpm", 666669, "Public Domain", "CentOS"},
 		{0, "basesystem", "10.0", "4.el6", "noarch", "basesystem-10.0-4.el6.src.rpm", 0, "Public Domain", "CentOS"},
 		{0, "tzdata", "2018e", "3.el6", "noarch", "tzdata-2018e-3.el6.src.rpm", 1961609, "Public Domain", "CentOS"},
@@ -744,7 +757,7 @@ var (
 	// docker run --rm -it centos:7 bash
 	// yum groupinstall -y "Development tools"
 	// rpm -qa --queryformat "\{%{EPOCH}, \"%{NAME}\", \"%{VERSION}\", \"%{RELEASE}\", \"%{ARCH}\", \"%{SOURCERPM}\", %{SIZE}, \"%{LICENSE}\", \"%{VENDOR}\"\},\n" | sed "s/^{(none)/{0/g" | sed "s/(none)//g"
-	CentOS7DevTools = []PackageInfo{
+	CentOS7DevTools = []testPackageInfo{
 		{0, "libproxy", "0.4.11", "11.el7", "x86_64", "libproxy-0.4.11-11.el7.src.rpm", 164014, "LGPLv2+", "CentOS"},
 		{0, "tzdata", "2018e", "3.el7", "noarch", "tzdata-2018e-3.el7.src.rpm", 1966505, "Public Domain", "CentOS"},
 		{0, "gnutls", "3.3.29", "9.el7_6", "x86_64", "gnutls-3.3.29-9.el7_6.src.rpm", 2097819, "GPLv3+ and LGPLv2+", "CentOS"},
@@ -1013,7 +1026,7 @@ var (
 
 	// docker run --rm -it centos/httpd-24-centos7 bash
 	// rpm -qa --queryformat "\{%{EPOCH}, \"%{NAME}\", \"%{VERSION}\", \"%{RELEASE}\", \"%{ARCH}\", \"%{SOURCERPM}\", %{SIZE}, \"%{LICENSE}\", \"%{VENDOR}\"\},\n" | sed "s/^{(none)/{0/g" | sed "s/(none)//g"
-	CentOS7Httpd24 = []PackageInfo{
+	CentOS7Httpd24 = []testPackageInfo{
 		{32, "bind-license", "9.9.4", "73.el7_6", "noarch", "bind-9.9.4-73.el7_6.src.rpm", 26831, "ISC", "CentOS"},
 		{0, "bash", "4.2.46", "31.el7", "x86_64", "bash-4.2.46-31.el7.src.rpm", 3667773, "GPLv3+", "CentOS"},
 		{0, "glibc-common", "2.17", "260.el7_6.3", "x86_64", "glibc-2.17-260.el7_6.3.src.rpm", 120499441, "LGPLv2+ and LGPLv2+ with exceptions and GPLv2+", "CentOS"},
@@ -1248,7 +1261,7 @@ var (
 	// yum install -y ncurses-devel newt-devel numactl-devel pciutils-devel python-devel zlib-devel
 	// yum install -y net-tools bc
 	// rpm -qa --queryformat "\{%{EPOCH}, \"%{NAME}\", \"%{VERSION}\", \"%{RELEASE}\", \"%{ARCH}\", \"%{SOURCERPM}\", %{SIZE}, \"%{LICENSE}\", \"%{VENDOR}\"\},\n" | sed "s/^{(none)/{0/g" | sed "s/(none)//g"
-	CentOS7Many = []PackageInfo{
+	CentOS7Many = []testPackageInfo{
 		{0, "gnutls", "3.3.29", "9.el7_6", "x86_64", "gnutls-3.3.29-9.el7_6.src.rpm", 2097819, "GPLv3+ and LGPLv2+", "CentOS"},
 		{0, "nss-softokn-freebl", "3.36.0", "5.el7_5", "x86_64", "nss-softokn-3.36.0-5.el7_5.src.rpm", 565628, "MPLv2.0", "CentOS"},
 		{0, "openssh-clients", "7.4p1", "16.el7", "x86_64", "openssh-7.4p1-16.el7.src.rpm", 2651616, "BSD", "CentOS"},
@@ -1649,7 +1662,7 @@ var (
 
 	// docker run --rm -it centos:7 bash
 	// rpm -qa --queryformat "\{%{EPOCH}, \"%{NAME}\", \"%{VERSION}\", \"%{RELEASE}\", \"%{ARCH}\", \"%{SOURCERPM}\", %{SIZE}, \"%{LICENSE}\", \"%{VENDOR}\"\},\n" | sed "s/(none)/0/g"
-	CentOS7Plain = []PackageInfo{
+	CentOS7Plain = []testPackageInfo{
 		{0, "tzdata", "2018e", "3.el7", "noarch", "tzdata-2018e-3.el7.src.rpm", 1966505, "Public Domain", "CentOS"},
 		{0, "nss-softokn-freebl", "3.36.0", "5.el7_5", "x86_64", "nss-softokn-3.36.0-5.el7_5.src.rpm", 565628, "MPLv2.0", "CentOS"},
 		{0, "ncurses", "5.9", "14.20130511.el7_4", "x86_64", "ncurses-5.9-14.20130511.el7_4.src.rpm", 439378, "MIT", "CentOS"},
@@ -1798,7 +1811,7 @@ var (
 
 	// docker run --rm -it centos/python-35-centos7 bash
 	// rpm -qa --queryformat "\{%{EPOCH}, \"%{NAME}\", \"%{VERSION}\", \"%{RELEASE}\", \"%{ARCH}\", \"%{SOURCERPM}\", %{SIZE}, \"%{LICENSE}\", \"%{VENDOR}\"\},\n" | sed "s/^{(none)/{0/g" | sed "s/(none)//g"
-	CentOS7Python35 = []PackageInfo{
+	CentOS7Python35 = []testPackageInfo{
 		{0, "fontconfig-devel", "2.13.0", "4.3.el7", "x86_64", "fontconfig-2.13.0-4.3.el7.src.rpm", 111050, "MIT and Public Domain and UCD", "CentOS"},
 		{32, "bind-license", "9.9.4", "73.el7_6", "noarch", "bind-9.9.4-73.el7_6.src.rpm", 26831, "ISC", "CentOS"},
 		{0, "less", "458", "9.el7", "x86_64", "less-458-9.el7.src.rpm", 215376, "GPLv3+", "CentOS"},
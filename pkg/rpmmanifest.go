@@ -0,0 +1,105 @@
+package rpmdb
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ContainerManifestPath is where the distroless images of CBL-Mariner and Azure Linux, which have
+// no rpm database, list their packages. Each line is the output of
+//
+//	rpm -qa --queryformat "%{NAME}\t%{VERSION}-%{RELEASE}\t%{INSTALLTIME}\t%{BUILDTIME}\t%{VENDOR}\t%{EPOCH}\t%{SIZE}\t%{ARCH}\t%{EPOCHNUM}\t%{SOURCERPM}\n"
+//
+// run when the image was built.
+// ref. https://github.com/microsoft/CBL-Mariner/blob/2.0/toolkit/docs/how_it_works/5_misc.md
+const ContainerManifestPath = "/var/lib/rpmmanifest/container-manifest-2"
+
+const containerManifestFields = 10
+
+// ReadContainerManifest parses a container manifest, see ContainerManifestPath. The fields rpm
+// prints as "(none)" are left empty, like the ones of headers.
+func ReadContainerManifest(r io.Reader) ([]*PackageInfo, error) {
+	var pkgList []*PackageInfo
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		pkg, err := parseContainerManifestLine(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		pkgList = append(pkgList, pkg)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read container manifest: %w", err)
+	}
+	return pkgList, nil
+}
+
+func parseContainerManifestLine(text string) (*PackageInfo, error) {
+	fields := strings.Split(text, "\t")
+	if len(fields) != containerManifestFields {
+		return nil, fmt.Errorf("got %d fields, want %d", len(fields), containerManifestFields)
+	}
+	for i := range fields {
+		fields[i] = normalizeString(fields[i])
+	}
+
+	pkg := &PackageInfo{
+		Name:      fields[0],
+		Vendor:    fields[4],
+		Arch:      fields[7],
+		SourceRpm: fields[9],
+	}
+	// neither the version nor the release of a package can have a dash
+	sep := strings.LastIndex(fields[1], "-")
+	if pkg.Name == "" || sep <= 0 || sep == len(fields[1])-1 {
+		return nil, fmt.Errorf("invalid package %q %q", fields[0], fields[1])
+	}
+	pkg.Version, pkg.Release = fields[1][:sep], fields[1][sep+1:]
+
+	for _, t := range []struct {
+		field string
+		value *time.Time
+	}{{fields[2], &pkg.InstallTime}, {fields[3], &pkg.BuildTime}} {
+		if t.field == "" {
+			continue
+		}
+		seconds, err := strconv.ParseUint(t.field, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time %q", t.field)
+		}
+		*t.value = unixTime(seconds)
+	}
+
+	var err error
+	if fields[6] != "" {
+		if pkg.Size, err = strconv.Atoi(fields[6]); err != nil {
+			return nil, fmt.Errorf("invalid size %q", fields[6])
+		}
+	}
+	// EPOCHNUM is 0 without epoch, where EPOCH is "(none)"
+	if fields[8] != "" {
+		if pkg.Epoch, err = strconv.Atoi(fields[8]); err != nil {
+			return nil, fmt.Errorf("invalid epoch %q", fields[8])
+		}
+	}
+	return pkg, nil
+}
+
+// ReadContainerManifestFile is ReadContainerManifest on a file.
+func ReadContainerManifestFile(path string) ([]*PackageInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadContainerManifest(f)
+}
@@ -0,0 +1,115 @@
+// Package rpmsql is a read-only database/sql driver running SQL queries over the packages of an rpm
+// database:
+//
+//	db, err := sql.Open("rpmdb", "rpmdb:///var/lib/rpm/Packages")
+//	rows, err := db.Query("SELECT name, version FROM packages WHERE vendor != 'Red Hat, Inc.'")
+//
+// A single table, packages, is available. The supported subset is SELECT with a column list, * or
+// COUNT(*), WHERE with AND/OR/NOT, comparisons, LIKE and IN, ORDER BY and LIMIT. ? placeholders can
+// be used in place of literals.
+package rpmsql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	rpmdb "github.com/chennqqi/go-rpmdb/pkg"
+)
+
+// ErrReadOnly is returned for the statements and transactions that would write to the database.
+var ErrReadOnly = errors.New("the database is read-only")
+
+func init() {
+	sql.Register("rpmdb", &Driver{})
+}
+
+// Driver opens the Packages database named by the DSN, "rpmdb://<path>" or a plain path.
+type Driver struct{}
+
+func (d *Driver) Open(name string) (driver.Conn, error) {
+	path := strings.TrimPrefix(name, "rpmdb://")
+	db, err := rpmdb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer db.Close()
+
+	// the database is read once per connection, queries run on the snapshot
+	pkgList, err := db.ListPackages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list packages: %w", err)
+	}
+	return &conn{pkgList: pkgList}, nil
+}
+
+type conn struct {
+	pkgList []*rpmdb.PackageInfo
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	q, err := parse(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query: %w", err)
+	}
+	return &stmt{conn: c, query: q}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("rpmdb: transactions are not supported, %w", ErrReadOnly)
+}
+
+type stmt struct {
+	conn  *conn
+	query *query
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return s.query.numInput
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, fmt.Errorf("rpmdb: %w", ErrReadOnly)
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	names, values, err := s.query.execute(s.conn.pkgList, args)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{columns: names, values: values}, nil
+}
+
+type rows struct {
+	columns []string
+	values  [][]driver.Value
+	pos     int
+}
+
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.pos])
+	r.pos++
+	return nil
+}
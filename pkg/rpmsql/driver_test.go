@@ -0,0 +1,89 @@
+package rpmsql
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	db, err := sql.Open("rpmdb", "rpmdb://../testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("sql.Open() error: %v", err)
+	}
+	defer db.Close()
+
+	vectors := []struct {
+		query string
+		args  []interface{}
+		want  []string
+	}{
+		{
+			query: "SELECT name FROM packages WHERE name LIKE 'bash%' OR name = 'zlib' ORDER BY name",
+			want:  []string{"bash", "zlib"},
+		},
+		{
+			query: "SELECT name FROM packages WHERE vendor != 'CentOS' ORDER BY name",
+			want:  nil,
+		},
+		{
+			query: "SELECT nevra FROM packages WHERE name IN ('glibc', 'glibc-common') AND arch = ? ORDER BY name DESC",
+			args:  []interface{}{"x86_64"},
+			want:  []string{"glibc-common-2.17-222.el7.x86_64", "glibc-2.17-222.el7.x86_64"},
+		},
+		{
+			query: "SELECT name FROM packages WHERE size > 20000000 AND NOT name LIKE 'GLIBC%' ORDER BY size DESC LIMIT 2",
+			want:  []string{"binutils", "python-libs"},
+		},
+	}
+
+	for _, v := range vectors {
+		t.Run(v.query, func(t *testing.T) {
+			rows, err := db.Query(v.query, v.args...)
+			if err != nil {
+				t.Fatalf("Query() error: %v", err)
+			}
+			defer rows.Close()
+
+			var got []string
+			for rows.Next() {
+				var s string
+				if err := rows.Scan(&s); err != nil {
+					t.Fatalf("Scan() error: %v", err)
+				}
+				got = append(got, s)
+			}
+			if err := rows.Err(); err != nil {
+				t.Fatalf("rows error: %v", err)
+			}
+			if !reflect.DeepEqual(got, v.want) {
+				t.Errorf("got %v, want %v", got, v.want)
+			}
+		})
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM packages WHERE epoch = 0").Scan(&count); err != nil {
+		t.Fatalf("QueryRow() error: %v", err)
+	}
+	if count == 0 {
+		t.Errorf("count: got 0")
+	}
+
+	if _, err := db.Query("SELECT nosuchcolumn FROM packages"); err == nil {
+		t.Errorf("expected an error for an unknown column")
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	db, err := sql.Open("rpmdb", "rpmdb://../testdata/centos7-plain/Packages")
+	if err != nil {
+		t.Fatalf("sql.Open() error: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Begin(); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Begin() error: got %v, want %v", err, ErrReadOnly)
+	}
+}
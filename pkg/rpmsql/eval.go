@@ -0,0 +1,233 @@
+package rpmsql
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	rpmdb "github.com/chennqqi/go-rpmdb/pkg"
+)
+
+// the columns of the packages table, in SELECT * order
+var columns = []string{
+	"epoch", "name", "version", "release", "arch", "sourcerpm", "size", "license", "vendor",
+	"modularitylabel", "evr", "nevra",
+}
+
+// columnTypes tells integer columns (true) apart from text columns
+var columnTypes = map[string]bool{
+	"epoch": true, "name": false, "version": false, "release": false, "arch": false,
+	"sourcerpm": false, "size": true, "license": false, "vendor": false,
+	"modularitylabel": false, "evr": false, "nevra": false,
+}
+
+func columnValue(pkg *rpmdb.PackageInfo, column string) driver.Value {
+	switch column {
+	case "epoch":
+		return int64(pkg.Epoch)
+	case "name":
+		return pkg.Name
+	case "version":
+		return pkg.Version
+	case "release":
+		return pkg.Release
+	case "arch":
+		return pkg.Arch
+	case "sourcerpm":
+		return pkg.SourceRpm
+	case "size":
+		return int64(pkg.Size)
+	case "license":
+		return pkg.License
+	case "vendor":
+		return pkg.Vendor
+	case "modularitylabel":
+		return pkg.ModularityLabel
+	case "evr":
+		return pkg.EVR()
+	case "nevra":
+		return pkg.NEVRA()
+	}
+	return nil
+}
+
+// execute runs the query over the packages and returns the selected columns and rows.
+func (q *query) execute(pkgList []*rpmdb.PackageInfo, args []driver.Value) ([]string, [][]driver.Value, error) {
+	if len(args) != q.numInput {
+		return nil, nil, fmt.Errorf("expected %d arguments, got %d", q.numInput, len(args))
+	}
+
+	var selected []*rpmdb.PackageInfo
+	for _, pkg := range pkgList {
+		ok, err := evalExpr(q.where, pkg, args)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			selected = append(selected, pkg)
+		}
+	}
+
+	if q.count {
+		return []string{"count"}, [][]driver.Value{{int64(len(selected))}}, nil
+	}
+
+	if len(q.orderBy) > 0 {
+		sort.SliceStable(selected, func(i, j int) bool {
+			for _, term := range q.orderBy {
+				cmp := compareValues(columnValue(selected[i], term.column), columnValue(selected[j], term.column))
+				if cmp == 0 {
+					continue
+				}
+				if term.desc {
+					return cmp > 0
+				}
+				return cmp < 0
+			}
+			return false
+		})
+	}
+	if q.limit >= 0 && len(selected) > q.limit {
+		selected = selected[:q.limit]
+	}
+
+	names := q.columns
+	if names == nil {
+		names = columns
+	}
+	rows := make([][]driver.Value, len(selected))
+	for i, pkg := range selected {
+		row := make([]driver.Value, len(names))
+		for j, name := range names {
+			row[j] = columnValue(pkg, name)
+		}
+		rows[i] = row
+	}
+	return names, rows, nil
+}
+
+func evalExpr(e expr, pkg *rpmdb.PackageInfo, args []driver.Value) (bool, error) {
+	switch e := e.(type) {
+	case nil:
+		return true, nil
+	case *binaryExpr:
+		left, err := evalExpr(e.left, pkg, args)
+		if err != nil {
+			return false, err
+		}
+		if e.op == "AND" && !left {
+			return false, nil
+		}
+		if e.op == "OR" && left {
+			return true, nil
+		}
+		return evalExpr(e.right, pkg, args)
+	case *notExpr:
+		inner, err := evalExpr(e.inner, pkg, args)
+		return !inner, err
+	case *compareExpr:
+		value := columnValue(pkg, e.column)
+		for _, o := range e.values {
+			operand, err := o.resolve(args, columnTypes[e.column])
+			if err != nil {
+				return false, fmt.Errorf("%s: %w", e.column, err)
+			}
+			if e.op == "LIKE" {
+				s, ok := operand.(string)
+				if !ok {
+					return false, fmt.Errorf("%s: LIKE pattern must be a string", e.column)
+				}
+				return likePattern(s).MatchString(toString(value)), nil
+			}
+
+			cmp := compareValues(value, operand)
+			switch e.op {
+			case "IN", "=":
+				if cmp == 0 {
+					return true, nil
+				}
+			case "!=":
+				return cmp != 0, nil
+			case "<":
+				return cmp < 0, nil
+			case "<=":
+				return cmp <= 0, nil
+			case ">":
+				return cmp > 0, nil
+			case ">=":
+				return cmp >= 0, nil
+			}
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("unexpected expression: %T", e)
+}
+
+// resolve returns the literal or bound value, converted to the type of the column.
+func (o operand) resolve(args []driver.Value, integer bool) (driver.Value, error) {
+	value := o.value
+	if o.placeholder >= 0 {
+		value = args[o.placeholder]
+	}
+
+	switch v := value.(type) {
+	case int64:
+		if integer {
+			return v, nil
+		}
+		return toString(v), nil
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	}
+	return nil, fmt.Errorf("unsupported value: %T", value)
+}
+
+// compareValues compares integers numerically and everything else as strings.
+func compareValues(a, b driver.Value) int {
+	ai, aok := a.(int64)
+	bi, bok := b.(int64)
+	if aok && bok {
+		switch {
+		case ai < bi:
+			return -1
+		case ai > bi:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(toString(a), toString(b))
+}
+
+func toString(v driver.Value) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	}
+	return ""
+}
+
+// likePattern converts a LIKE pattern (% and _ wildcards) to an anchored regular expression. As in
+// SQLite, LIKE is case-insensitive for ASCII.
+func likePattern(pattern string) *regexp.Regexp {
+	var sb strings.Builder
+	sb.WriteString("(?is)^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			sb.WriteString(".*")
+		case '_':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String())
+}
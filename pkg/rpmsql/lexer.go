@@ -0,0 +1,107 @@
+package rpmsql
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+	tokenPlaceholder
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+}
+
+// keywords are compared case-insensitively, identifiers are lowercased
+var keywords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "AND": true, "OR": true, "NOT": true,
+	"LIKE": true, "IN": true, "ORDER": true, "BY": true, "ASC": true, "DESC": true,
+	"LIMIT": true, "COUNT": true,
+}
+
+func lex(query string) ([]token, error) {
+	var tokens []token
+	runes := []rune(query)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'':
+			// string literal, '' escapes a quote
+			var sb strings.Builder
+			i++
+			for {
+				if i >= len(runes) {
+					return nil, fmt.Errorf("unterminated string literal")
+				}
+				if runes[i] == '\'' {
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						sb.WriteRune('\'')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenString, value: sb.String()})
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			i++
+			for i < len(runes) && unicode.IsDigit(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, value: string(runes[start:i])})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			word := string(runes[start:i])
+			if keywords[strings.ToUpper(word)] {
+				word = strings.ToUpper(word)
+			} else {
+				word = strings.ToLower(word)
+			}
+			tokens = append(tokens, token{kind: tokenIdent, value: word})
+		case r == '?':
+			tokens = append(tokens, token{kind: tokenPlaceholder, value: "?"})
+			i++
+		case strings.ContainsRune("<>!=", r):
+			start := i
+			i++
+			if i < len(runes) && (runes[i] == '=' || (r == '<' && runes[i] == '>')) {
+				i++
+			}
+			op := string(runes[start:i])
+			if op == "!" {
+				return nil, fmt.Errorf("unexpected character: %q", r)
+			}
+			if op == "<>" {
+				op = "!="
+			}
+			tokens = append(tokens, token{kind: tokenOperator, value: op})
+		case strings.ContainsRune("(),*;", r):
+			tokens = append(tokens, token{kind: tokenOperator, value: string(r)})
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected character: %q", r)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF}), nil
+}
@@ -0,0 +1,317 @@
+package rpmsql
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// query is a parsed SELECT statement.
+type query struct {
+	columns []string // nil for *
+	count   bool
+	where   expr
+	orderBy []orderTerm
+	limit   int // -1 for no limit
+	// numInput is the number of ? placeholders
+	numInput int
+}
+
+type orderTerm struct {
+	column string
+	desc   bool
+}
+
+// expr is a node of a WHERE clause.
+type expr interface{}
+
+type binaryExpr struct {
+	op          string // AND, OR
+	left, right expr
+}
+
+type notExpr struct {
+	inner expr
+}
+
+type compareExpr struct {
+	column string
+	op     string // =, !=, <, <=, >, >=, LIKE, IN
+	values []operand
+}
+
+// operand is a literal or the index of a placeholder
+type operand struct {
+	value       interface{}
+	placeholder int // -1 for literals
+}
+
+type parser struct {
+	tokens   []token
+	pos      int
+	numInput int
+}
+
+func parse(sql string) (*query, error) {
+	tokens, err := lex(sql)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	q, err := p.parseSelect()
+	if err != nil {
+		return nil, err
+	}
+	q.numInput = p.numInput
+	return q, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) accept(kind tokenKind, value string) bool {
+	if t := p.peek(); t.kind == kind && t.value == value {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(kind tokenKind, value string) error {
+	if !p.accept(kind, value) {
+		return fmt.Errorf("expected %s, got %q", value, p.peek().value)
+	}
+	return nil
+}
+
+func (p *parser) column() (string, error) {
+	t := p.next()
+	if t.kind != tokenIdent || keywords[t.value] {
+		return "", fmt.Errorf("expected column name, got %q", t.value)
+	}
+	if _, ok := columnTypes[t.value]; !ok {
+		return "", fmt.Errorf("unknown column: %s", t.value)
+	}
+	return t.value, nil
+}
+
+func (p *parser) parseSelect() (*query, error) {
+	q := &query{limit: -1}
+	if err := p.expect(tokenIdent, "SELECT"); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case p.accept(tokenOperator, "*"):
+	case p.accept(tokenIdent, "COUNT"):
+		if err := p.expect(tokenOperator, "("); err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenOperator, "*"); err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenOperator, ")"); err != nil {
+			return nil, err
+		}
+		q.count = true
+	default:
+		for {
+			column, err := p.column()
+			if err != nil {
+				return nil, err
+			}
+			q.columns = append(q.columns, column)
+			if !p.accept(tokenOperator, ",") {
+				break
+			}
+		}
+	}
+
+	if err := p.expect(tokenIdent, "FROM"); err != nil {
+		return nil, err
+	}
+	if t := p.next(); t.kind != tokenIdent || t.value != "packages" {
+		return nil, fmt.Errorf("unknown table: %s", t.value)
+	}
+
+	if p.accept(tokenIdent, "WHERE") {
+		where, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		q.where = where
+	}
+
+	if p.accept(tokenIdent, "ORDER") {
+		if err := p.expect(tokenIdent, "BY"); err != nil {
+			return nil, err
+		}
+		for {
+			column, err := p.column()
+			if err != nil {
+				return nil, err
+			}
+			term := orderTerm{column: column}
+			if p.accept(tokenIdent, "DESC") {
+				term.desc = true
+			} else {
+				p.accept(tokenIdent, "ASC")
+			}
+			q.orderBy = append(q.orderBy, term)
+			if !p.accept(tokenOperator, ",") {
+				break
+			}
+		}
+	}
+
+	if p.accept(tokenIdent, "LIMIT") {
+		t := p.next()
+		limit, err := strconv.Atoi(t.value)
+		if t.kind != tokenNumber || err != nil || limit < 0 {
+			return nil, fmt.Errorf("invalid limit: %q", t.value)
+		}
+		q.limit = limit
+	}
+
+	p.accept(tokenOperator, ";")
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q", t.value)
+	}
+	return q, nil
+}
+
+func (p *parser) parseOr() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(tokenIdent, "OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: "OR", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(tokenIdent, "AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: "AND", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (expr, error) {
+	if p.accept(tokenIdent, "NOT") {
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{inner: inner}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (expr, error) {
+	if p.accept(tokenOperator, "(") {
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenOperator, ")"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	}
+
+	column, err := p.column()
+	if err != nil {
+		return nil, err
+	}
+
+	negate := p.accept(tokenIdent, "NOT")
+	var e expr
+	switch t := p.next(); {
+	case t.kind == tokenIdent && t.value == "LIKE":
+		value, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		e = &compareExpr{column: column, op: "LIKE", values: []operand{value}}
+	case t.kind == tokenIdent && t.value == "IN":
+		if err := p.expect(tokenOperator, "("); err != nil {
+			return nil, err
+		}
+		c := &compareExpr{column: column, op: "IN"}
+		for {
+			value, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+			c.values = append(c.values, value)
+			if !p.accept(tokenOperator, ",") {
+				break
+			}
+		}
+		if err := p.expect(tokenOperator, ")"); err != nil {
+			return nil, err
+		}
+		e = c
+	case t.kind == tokenOperator && !negate && isComparison(t.value):
+		value, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		e = &compareExpr{column: column, op: t.value, values: []operand{value}}
+	default:
+		return nil, fmt.Errorf("expected comparison after %s, got %q", column, t.value)
+	}
+
+	if negate {
+		return &notExpr{inner: e}, nil
+	}
+	return e, nil
+}
+
+func isComparison(op string) bool {
+	switch op {
+	case "=", "!=", "<", "<=", ">", ">=":
+		return true
+	}
+	return false
+}
+
+func (p *parser) operand() (operand, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenString:
+		return operand{value: t.value, placeholder: -1}, nil
+	case tokenNumber:
+		n, err := strconv.ParseInt(t.value, 10, 64)
+		if err != nil {
+			return operand{}, fmt.Errorf("invalid number: %q", t.value)
+		}
+		return operand{value: n, placeholder: -1}, nil
+	case tokenPlaceholder:
+		p.numInput++
+		return operand{placeholder: p.numInput - 1}, nil
+	}
+	return operand{}, fmt.Errorf("expected literal, got %q", t.value)
+}
@@ -0,0 +1,168 @@
+package rpmdb
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+// SalvagedPackage is a package read from a possibly damaged database by SalvagePackages.
+type SalvagedPackage struct {
+	// HNum is the instance number of the header.
+	HNum uint32
+	// Package has the fields of the entries recovered, none when nothing could be: a header
+	// truncated before its name still tells an instance was there.
+	Package *PackageInfo
+	// Partial is set for a header that didn't decode as a whole, Err telling why: Recovered of the
+	// Entries the header claims were decoded, the ones of a truncated index or whose data is cut
+	// off or invalid being left out.
+	Partial   bool
+	Err       error
+	Recovered int
+	Entries   int
+}
+
+// SalvagePackages lists the packages of a damaged database for incident response: the headers
+// that don't decode, truncated in the middle of their data area for instance, give the fields of
+// the entries they still hold, most of the time the name and version, where ListPackages fails.
+// The packages read before a failure of the database itself are returned with the error.
+func (d *RpmDB) SalvagePackages() ([]*SalvagedPackage, error) {
+	var salvaged, signedPkgs []*SalvagedPackage
+	var keyIDs []string
+	var signed []bool
+	var current *SalvagedPackage
+	decode := d.rewriteHeaders(func(hnum uint32, blob []byte) error {
+		indexEntries, err := headerImport(blob)
+		if err != nil {
+			return err
+		}
+		pkg, err := getNEVRA(indexEntries)
+		if err != nil {
+			return err
+		}
+		if d.interner != nil {
+			d.interner.internPackage(pkg)
+		}
+		current.Package = pkg
+		keyID, ok := signatureKeyID(indexEntries)
+		signedPkgs = append(signedPkgs, current)
+		keyIDs = append(keyIDs, d.intern(keyID))
+		signed = append(signed, ok)
+		return nil
+	})
+
+	var err error
+	entries, stop := d.readHeaders()
+	defer stop()
+	for entry := range entries {
+		if entry.Err != nil {
+			err = entry.Err
+			continue
+		}
+		if isPlaceholder(entry) {
+			continue
+		}
+		s := &SalvagedPackage{}
+		if len(entry.Key) == 4 {
+			s.HNum = binary.LittleEndian.Uint32(entry.Key)
+		}
+		salvaged = append(salvaged, s)
+		current = s
+
+		blob := entry.Value
+		if _, importErr := headerImport(blob); importErr != nil {
+			s.Partial, s.Err = true, importErr
+			blob, s.Recovered, s.Entries = salvageHeader(blob)
+		}
+		if blob != nil {
+			if decodeErr := decode(s.HNum, blob); decodeErr != nil && s.Err == nil {
+				s.Partial, s.Err = true, decodeErr
+			}
+		}
+		if s.Package == nil {
+			s.Package = &PackageInfo{}
+		}
+	}
+
+	pkgList := make([]*PackageInfo, len(signedPkgs))
+	for i, s := range signedPkgs {
+		pkgList[i] = s.Package
+	}
+	attributeSignatures(pkgList, keyIDs, signed)
+	return salvaged, err
+}
+
+// salvageHeader re-encodes the entries of a header blob headerImport rejects that are still whole:
+// their info is within the index table, and their data within the blob and the data length, and
+// valid for their type and count. A later entry replaces an earlier one of the same tag, as the
+// dribbles do the region entries. It returns the blob, nil when no entry is left, the number of
+// entries recovered and the number of entries the header claims, its region entry left out.
+func salvageHeader(data []byte) ([]byte, int, int) {
+	if len(data) < 8 {
+		return nil, 0, 0
+	}
+	il := int64(int32(binary.BigEndian.Uint32(data[0:])))
+	dl := int64(int32(binary.BigEndian.Uint32(data[4:])))
+	if il < 1 {
+		return nil, 0, 0
+	}
+	const entrySize = 16
+	n := il
+	if available := int64(len(data)-8) / entrySize; n > available {
+		n = available
+	}
+	dataStart := 8 + il*entrySize
+	dataEnd := dataStart + dl
+	if dl < 0 || dataEnd > int64(len(data)) {
+		dataEnd = int64(len(data))
+	}
+
+	infos := make([]entryInfo, n)
+	for i := range infos {
+		pe := data[8+i*entrySize:]
+		infos[i] = entryInfo{
+			Tag:    TAG_ID(int32(binary.BigEndian.Uint32(pe))),
+			Type:   TAG_TYPE(binary.BigEndian.Uint32(pe[4:])),
+			Offset: int32(binary.BigEndian.Uint32(pe[8:])),
+			Count:  binary.BigEndian.Uint32(pe[12:]),
+		}
+	}
+	// the data of an entry runs up to the next offset, the region trailer's included
+	offsets := make([]int64, 0, n)
+	for _, info := range infos {
+		offsets = append(offsets, int64(info.Offset))
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+
+	entries := int(il)
+	b := NewHeaderBuilder()
+	recovered := 0
+	for i, info := range infos {
+		if i == 0 && (info.Tag == HEADER_IMAGE || info.Tag == HEADER_SIGNATURES || info.Tag == HEADER_IMMUTABLE) {
+			entries--
+			continue
+		}
+		start := dataStart + int64(info.Offset)
+		if info.Offset < 0 || start >= dataEnd {
+			continue
+		}
+		end := dataEnd
+		if j := sort.Search(len(offsets), func(j int) bool { return offsets[j] > int64(info.Offset) }); j < len(offsets) && dataStart+offsets[j] < end {
+			end = dataStart + offsets[j]
+		}
+		entry := indexEntry{Info: info, Length: int(end - start), Data: data[start:end]}
+		value, err := entryData(&entry)
+		if err != nil {
+			continue
+		}
+		b.add(info.Tag, info.Type, info.Count, value)
+		recovered++
+	}
+	if recovered == 0 {
+		return nil, 0, entries
+	}
+	blob, err := b.Bytes()
+	if err != nil {
+		return nil, 0, entries
+	}
+	return blob, recovered, entries
+}
@@ -0,0 +1,251 @@
+package rpmdb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the purl namespaces of the vendors, matched as prefixes of VENDOR
+var purlNamespaces = []struct {
+	vendor    string
+	namespace string
+}{
+	{"CentOS", "centos"},
+	{"Red Hat", "redhat"},
+	{"Fedora Project", "fedora"},
+	{"Rocky Enterprise Software Foundation", "rocky"},
+	{"AlmaLinux", "almalinux"},
+	{"Oracle America", "oracle"},
+	{"Amazon", "amazon"},
+	{"openSUSE", "opensuse"},
+	{"SUSE", "suse"},
+	{"VMware", "photon"},
+	{"Microsoft", "mariner"},
+	{"openEuler", "openeuler"},
+}
+
+// PURL returns the package URL of the package, "pkg:rpm/centos/bash@4.2.46-31.el7?arch=x86_64",
+// the namespace being the distribution of its vendor, none when the vendor is unknown. The epoch
+// is a qualifier, as the source rpm upstream.
+// ref. https://github.com/package-url/purl-spec/blob/master/PURL-TYPES.rst#rpm
+func (p *PackageInfo) PURL() string {
+	var b strings.Builder
+	b.WriteString("pkg:rpm/")
+	for _, v := range purlNamespaces {
+		if strings.HasPrefix(p.Vendor, v.vendor) {
+			b.WriteString(v.namespace + "/")
+			break
+		}
+	}
+	b.WriteString(purlEscape(p.Name) + "@" + purlEscape(p.Version+"-"+p.Release))
+
+	// the qualifiers are sorted by key
+	var qualifiers []string
+	if p.Arch != "" {
+		qualifiers = append(qualifiers, "arch="+purlEscape(p.Arch))
+	}
+	if p.Epoch != 0 {
+		qualifiers = append(qualifiers, "epoch="+strconv.Itoa(p.Epoch))
+	}
+	if p.SourceRpm != "" {
+		qualifiers = append(qualifiers, "upstream="+purlEscape(p.SourceRpm))
+	}
+	if len(qualifiers) > 0 {
+		b.WriteString("?" + strings.Join(qualifiers, "&"))
+	}
+	return b.String()
+}
+
+// purlEscape percent-encodes everything but the unreserved characters, the "+" of "libstdc++"
+// among others.
+func purlEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// the CycloneDX types of WriteCycloneDX
+// ref. https://cyclonedx.org/docs/1.5/json/
+type cycloneDXBOM struct {
+	BOMFormat   string               `json:"bomFormat"`
+	SpecVersion string               `json:"specVersion"`
+	Version     int                  `json:"version"`
+	Components  []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXComponent struct {
+	Type       string              `json:"type"`
+	BOMRef     string              `json:"bom-ref"`
+	Publisher  string              `json:"publisher,omitempty"`
+	Name       string              `json:"name"`
+	Version    string              `json:"version"`
+	Licenses   []cycloneDXLicense  `json:"licenses,omitempty"`
+	PURL       string              `json:"purl"`
+	Properties []cycloneDXProperty `json:"properties,omitempty"`
+}
+
+type cycloneDXLicense struct {
+	License struct {
+		Name string `json:"name"`
+	} `json:"license"`
+}
+
+type cycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// WriteCycloneDX encodes the packages as a CycloneDX 1.5 JSON BOM, a library component per
+// package whose bom-ref is its PURL. The rpm licenses aren't SPDX expressions, they are names.
+// The BOM has no serial number nor timestamp: the same packages give the same bytes.
+func WriteCycloneDX(w io.Writer, pkgs []*PackageInfo) error {
+	bom := cycloneDXBOM{BOMFormat: "CycloneDX", SpecVersion: "1.5", Version: 1, Components: []cycloneDXComponent{}}
+	for _, pkg := range pkgs {
+		purl := pkg.PURL()
+		c := cycloneDXComponent{
+			Type:      "library",
+			BOMRef:    purl,
+			Publisher: pkg.Vendor,
+			Name:      pkg.Name,
+			Version:   pkg.EVR(),
+			PURL:      purl,
+		}
+		if pkg.License != "" {
+			var license cycloneDXLicense
+			license.License.Name = pkg.License
+			c.Licenses = []cycloneDXLicense{license}
+		}
+		for _, p := range []cycloneDXProperty{
+			{"rpm:arch", pkg.Arch},
+			{"rpm:sourceRpm", pkg.SourceRpm},
+			{"rpm:installTime", formatTime(pkg.InstallTime)},
+		} {
+			if p.Value != "" {
+				c.Properties = append(c.Properties, p)
+			}
+		}
+		bom.Components = append(bom.Components, c)
+	}
+	return writeIndentedJSON(w, bom)
+}
+
+// the SPDX types of WriteSPDX
+// ref. https://spdx.github.io/spdx-spec/v2.3/
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name             string            `json:"name"`
+	SPDXID           string            `json:"SPDXID"`
+	VersionInfo      string            `json:"versionInfo"`
+	Supplier         string            `json:"supplier"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	Homepage         string            `json:"homepage,omitempty"`
+	SourceInfo       string            `json:"sourceInfo,omitempty"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	LicenseComments  string            `json:"licenseComments,omitempty"`
+	CopyrightText    string            `json:"copyrightText"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+const spdxNoAssertion = "NOASSERTION"
+
+// WriteSPDX encodes the packages as an SPDX 2.3 JSON document describing them. The rpm licenses
+// aren't SPDX expressions, they are license comments, the licenses being NOASSERTION. The
+// document is created at the latest install time and its namespace derives from the packages: the
+// same packages give the same bytes.
+func WriteSPDX(w io.Writer, pkgs []*PackageInfo) error {
+	doc := spdxDocument{
+		SPDXVersion:   "SPDX-2.3",
+		DataLicense:   "CC0-1.0",
+		SPDXID:        "SPDXRef-DOCUMENT",
+		Name:          "rpmdb",
+		CreationInfo:  spdxCreationInfo{Creators: []string{"Tool: go-rpmdb"}},
+		Packages:      []spdxPackage{},
+		Relationships: []spdxRelationship{},
+	}
+
+	var created time.Time
+	h := sha256.New()
+	for i, pkg := range pkgs {
+		if pkg.InstallTime.After(created) {
+			created = pkg.InstallTime
+		}
+		purl := pkg.PURL()
+		fmt.Fprintln(h, purl)
+
+		p := spdxPackage{
+			Name:             pkg.Name,
+			SPDXID:           fmt.Sprintf("SPDXRef-Package-%d", i+1),
+			VersionInfo:      pkg.EVR(),
+			Supplier:         spdxNoAssertion,
+			DownloadLocation: spdxNoAssertion,
+			Homepage:         pkg.URL,
+			LicenseConcluded: spdxNoAssertion,
+			LicenseDeclared:  spdxNoAssertion,
+			LicenseComments:  pkg.License,
+			CopyrightText:    spdxNoAssertion,
+			ExternalRefs:     []spdxExternalRef{{"PACKAGE-MANAGER", "purl", purl}},
+		}
+		if pkg.Vendor != "" {
+			p.Supplier = "Organization: " + pkg.Vendor
+		}
+		if pkg.SourceRpm != "" {
+			p.SourceInfo = "built from " + pkg.SourceRpm
+		}
+		doc.Packages = append(doc.Packages, p)
+		doc.Relationships = append(doc.Relationships, spdxRelationship{doc.SPDXID, "DESCRIBES", p.SPDXID})
+	}
+	doc.CreationInfo.Created = created.UTC().Format(time.RFC3339)
+	doc.DocumentNamespace = "https://github.com/chennqqi/go-rpmdb/spdx/" + hex.EncodeToString(h.Sum(nil))
+	return writeIndentedJSON(w, doc)
+}
+
+func writeIndentedJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
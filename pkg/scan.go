@@ -0,0 +1,142 @@
+package rpmdb
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+)
+
+// ErrNoDatabase is reported by ScanRoots for the roots without rpm database.
+var ErrNoDatabase = errors.New("no rpm database")
+
+// DefaultDBPaths are the locations of the Packages file in a root, the rpm < 4.16 location first,
+// then the one of distributions moving the database to /usr and the one of rpm-ostree.
+//
+// Deprecated: use DefaultPaths, which finds the databases of every backend.
+var DefaultDBPaths = []string{
+	"/var/lib/rpm/Packages",
+	"/usr/lib/sysimage/rpm/Packages",
+	"/usr/share/rpm/Packages",
+}
+
+// ScanOptions configures ScanRoots.
+type ScanOptions struct {
+	// Concurrency is the number of roots scanned at once, 1 when zero.
+	Concurrency int
+	// DBPaths are the locations of the database tried in every root, resolved within the root,
+	// symlinks included. When empty, the first of DefaultPaths is read.
+	DBPaths []string
+	// Options are passed to Open for every database.
+	Options []Option
+}
+
+// RootScan is the outcome of the scan of a root.
+type RootScan struct {
+	Root string
+	// DBPath is the host path of the database read, empty when none was found. It is the one of the
+	// ContainerManifestPath of the images having a manifest instead of a database.
+	DBPath   string
+	Packages []*PackageInfo
+	// Err is why the root couldn't be scanned, ErrNoDatabase when it has no database. The other
+	// roots are scanned regardless.
+	Err error
+}
+
+// ScanRoots lists the packages of many root filesystems at once, the host and the rootfs of its
+// containers for instance, with opts.Concurrency databases opened at a time. The scans are
+// returned in the order of roots, each tagged with its root. Canceling ctx stops starting new
+// scans: the error is then ctx.Err() and the roots left out have it as Err.
+func ScanRoots(ctx context.Context, roots []string, opts *ScanOptions) ([]*RootScan, error) {
+	if opts == nil {
+		opts = &ScanOptions{}
+	}
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	scans := make([]*RootScan, len(roots))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				scans[i] = scanRoot(roots[i], opts.DBPaths, opts.Options)
+			}
+		}()
+	}
+
+	var err error
+feed:
+	for i := range roots {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			err = ctx.Err()
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i, scan := range scans {
+		if scan == nil {
+			scans[i] = &RootScan{Root: roots[i], Err: err}
+		}
+	}
+	return scans, err
+}
+
+func scanRoot(root string, dbPaths []string, opts []Option) *RootScan {
+	scan := &RootScan{Root: root}
+	if len(dbPaths) == 0 {
+		if candidates := DefaultPaths(root); len(candidates) > 0 {
+			scan.DBPath = candidates[0].HostPath
+		}
+	}
+	for _, dbPath := range dbPaths {
+		host, err := resolveInRoot(root, dbPath, true)
+		if err != nil {
+			scan.Err = err
+			return scan
+		}
+		if info, err := os.Stat(host); err == nil && info.Mode().IsRegular() {
+			scan.DBPath = host
+			break
+		}
+	}
+	if scan.DBPath == "" {
+		return scanContainerManifest(scan)
+	}
+
+	db, err := Open(scan.DBPath, opts...)
+	if err != nil {
+		scan.Err = err
+		return scan
+	}
+	defer db.Close()
+	scan.Packages, scan.Err = db.ListPackages()
+	return scan
+}
+
+// scanContainerManifest lists the packages of the manifest of a root without database, so that the
+// distroless Mariner images need no special case.
+func scanContainerManifest(scan *RootScan) *RootScan {
+	host, err := resolveInRoot(scan.Root, ContainerManifestPath, true)
+	if err != nil {
+		scan.Err = err
+		return scan
+	}
+	if info, err := os.Stat(host); err != nil || !info.Mode().IsRegular() {
+		scan.Err = ErrNoDatabase
+		return scan
+	}
+	scan.DBPath = host
+	scan.Packages, scan.Err = ReadContainerManifestFile(host)
+	return scan
+}
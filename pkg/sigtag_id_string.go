@@ -0,0 +1,65 @@
+// Code generated by "stringer -type=SIGTAG_ID"; DO NOT EDIT.
+
+package rpmdb
+
+import "strconv"
+
+func _() {
+	// An "invalid array index" compiler error signifies that the constant values have changed.
+	// Re-run the stringer command to generate them again.
+	var x [1]struct{}
+	_ = x[RPMSIGTAG_SIZE-1000]
+	_ = x[RPMSIGTAG_LEMD5_1-1001]
+	_ = x[RPMSIGTAG_PGP-1002]
+	_ = x[RPMSIGTAG_LEMD5_2-1003]
+	_ = x[RPMSIGTAG_MD5-1004]
+	_ = x[RPMSIGTAG_GPG-1005]
+	_ = x[RPMSIGTAG_PGP5-1006]
+	_ = x[RPMSIGTAG_PAYLOADSIZE-1007]
+	_ = x[RPMSIGTAG_RESERVEDSPACE-1008]
+	_ = x[RPMSIGTAG_BADSHA1_1-264]
+	_ = x[RPMSIGTAG_BADSHA1_2-265]
+	_ = x[RPMSIGTAG_DSA-267]
+	_ = x[RPMSIGTAG_RSA-268]
+	_ = x[RPMSIGTAG_SHA1-269]
+	_ = x[RPMSIGTAG_LONGSIZE-270]
+	_ = x[RPMSIGTAG_LONGARCHIVESIZE-271]
+	_ = x[RPMSIGTAG_SHA256-273]
+	_ = x[RPMSIGTAG_FILESIGNATURES-274]
+	_ = x[RPMSIGTAG_FILESIGNATURELENGTH-275]
+	_ = x[RPMSIGTAG_VERITYSIGNATURES-276]
+	_ = x[RPMSIGTAG_VERITYSIGNATUREALGO-277]
+}
+
+const (
+	_SIGTAG_ID_name_0 = "RPMSIGTAG_BADSHA1_1RPMSIGTAG_BADSHA1_2"
+	_SIGTAG_ID_name_1 = "RPMSIGTAG_DSARPMSIGTAG_RSARPMSIGTAG_SHA1RPMSIGTAG_LONGSIZERPMSIGTAG_LONGARCHIVESIZE"
+	_SIGTAG_ID_name_2 = "RPMSIGTAG_SHA256RPMSIGTAG_FILESIGNATURESRPMSIGTAG_FILESIGNATURELENGTHRPMSIGTAG_VERITYSIGNATURESRPMSIGTAG_VERITYSIGNATUREALGO"
+	_SIGTAG_ID_name_3 = "RPMSIGTAG_SIZERPMSIGTAG_LEMD5_1RPMSIGTAG_PGPRPMSIGTAG_LEMD5_2RPMSIGTAG_MD5RPMSIGTAG_GPGRPMSIGTAG_PGP5RPMSIGTAG_PAYLOADSIZERPMSIGTAG_RESERVEDSPACE"
+)
+
+var (
+	_SIGTAG_ID_index_0 = [...]uint8{0, 19, 38}
+	_SIGTAG_ID_index_1 = [...]uint8{0, 13, 26, 40, 58, 83}
+	_SIGTAG_ID_index_2 = [...]uint8{0, 16, 40, 69, 95, 124}
+	_SIGTAG_ID_index_3 = [...]uint8{0, 14, 31, 44, 61, 74, 87, 101, 122, 145}
+)
+
+func (i SIGTAG_ID) String() string {
+	switch {
+	case 264 <= i && i <= 265:
+		i -= 264
+		return _SIGTAG_ID_name_0[_SIGTAG_ID_index_0[i]:_SIGTAG_ID_index_0[i+1]]
+	case 267 <= i && i <= 271:
+		i -= 267
+		return _SIGTAG_ID_name_1[_SIGTAG_ID_index_1[i]:_SIGTAG_ID_index_1[i+1]]
+	case 273 <= i && i <= 277:
+		i -= 273
+		return _SIGTAG_ID_name_2[_SIGTAG_ID_index_2[i]:_SIGTAG_ID_index_2[i+1]]
+	case 1000 <= i && i <= 1008:
+		i -= 1000
+		return _SIGTAG_ID_name_3[_SIGTAG_ID_index_3[i]:_SIGTAG_ID_index_3[i+1]]
+	default:
+		return "SIGTAG_ID(" + strconv.FormatInt(int64(i), 10) + ")"
+	}
+}
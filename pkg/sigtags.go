@@ -0,0 +1,76 @@
+//go:generate stringer -type=SIGTAG_ID
+package rpmdb
+
+// SIGTAG_ID is a tag of the signature header of package files. Signature tags below HEADER_TAGBASE
+// share the numbers of the retrofitted RPMTAG_SIG_BASE tags, the older ones collide with RPMTAG_NAME
+// and friends and have to be translated before the header can be read as a package header.
+type SIGTAG_ID int32
+
+// rpmSigTag_e
+// ref. https://github.com/rpm-software-management/rpm/blob/rpm-4.20.0-release/include/rpm/rpmtag.h
+const (
+	RPMSIGTAG_SIZE                SIGTAG_ID = 1000                                  /*!< internal Header+Payload size (32bit) in bytes. */
+	RPMSIGTAG_LEMD5_1             SIGTAG_ID = 1001                                  /*!< internal Broken MD5, take 1 @deprecated legacy. */
+	RPMSIGTAG_PGP                 SIGTAG_ID = 1002                                  /*!< internal PGP 2.6.3 signature. */
+	RPMSIGTAG_LEMD5_2             SIGTAG_ID = 1003                                  /*!< internal Broken MD5, take 2 @deprecated legacy. */
+	RPMSIGTAG_MD5                 SIGTAG_ID = 1004                                  /*!< internal MD5 signature. */
+	RPMSIGTAG_GPG                 SIGTAG_ID = 1005                                  /*!< internal GnuPG signature. */
+	RPMSIGTAG_PGP5                SIGTAG_ID = 1006                                  /*!< internal PGP5 signature @deprecated legacy. */
+	RPMSIGTAG_PAYLOADSIZE         SIGTAG_ID = 1007                                  /*!< internal uncompressed payload size (32bit) in bytes. */
+	RPMSIGTAG_RESERVEDSPACE       SIGTAG_ID = 1008                                  /*!< internal space reserved for signatures */
+	RPMSIGTAG_BADSHA1_1           SIGTAG_ID = SIGTAG_ID(RPMTAG_BADSHA1_1)           /*!< internal Broken SHA1, take 1. */
+	RPMSIGTAG_BADSHA1_2           SIGTAG_ID = SIGTAG_ID(RPMTAG_BADSHA1_2)           /*!< internal Broken SHA1, take 2. */
+	RPMSIGTAG_DSA                 SIGTAG_ID = SIGTAG_ID(RPMTAG_DSAHEADER)           /*!< internal DSA header signature. */
+	RPMSIGTAG_RSA                 SIGTAG_ID = SIGTAG_ID(RPMTAG_RSAHEADER)           /*!< internal RSA header signature. */
+	RPMSIGTAG_SHA1                SIGTAG_ID = SIGTAG_ID(RPMTAG_SHA1HEADER)          /*!< internal sha1 header digest. */
+	RPMSIGTAG_LONGSIZE            SIGTAG_ID = SIGTAG_ID(RPMTAG_LONGSIGSIZE)         /*!< internal Header+Payload size (64bit) in bytes. */
+	RPMSIGTAG_LONGARCHIVESIZE     SIGTAG_ID = SIGTAG_ID(RPMTAG_LONGARCHIVESIZE)     /*!< internal uncompressed payload size (64bit) in bytes. */
+	RPMSIGTAG_SHA256              SIGTAG_ID = SIGTAG_ID(RPMTAG_SHA256HEADER)        /*!< internal sha256 header digest. */
+	RPMSIGTAG_FILESIGNATURES      SIGTAG_ID = SIGTAG_ID(RPMTAG_SIG_BASE + 18)       /*!< internal IMA file signatures. */
+	RPMSIGTAG_FILESIGNATURELENGTH SIGTAG_ID = SIGTAG_ID(RPMTAG_SIG_BASE + 19)       /*!< internal IMA file signature length. */
+	RPMSIGTAG_VERITYSIGNATURES    SIGTAG_ID = SIGTAG_ID(RPMTAG_VERITYSIGNATURES)    /*!< internal fsverity signatures. */
+	RPMSIGTAG_VERITYSIGNATUREALGO SIGTAG_ID = SIGTAG_ID(RPMTAG_VERITYSIGNATUREALGO) /*!< internal fsverity signature algorithm. */
+)
+
+// HeaderTag returns the package header tag rpm stores the signature tag as when it merges the
+// signature header into the package header, false for tags rpm drops.
+// ref. https://github.com/rpm-software-management/rpm/blob/rpm-4.20.0-release/lib/package.c
+func (t SIGTAG_ID) HeaderTag() (TAG_ID, bool) {
+	switch t {
+	case RPMSIGTAG_SIZE:
+		return RPMTAG_SIGSIZE, true
+	case RPMSIGTAG_PGP:
+		return RPMTAG_SIGPGP, true
+	case RPMSIGTAG_MD5:
+		return RPMTAG_SIGMD5, true
+	case RPMSIGTAG_GPG:
+		return RPMTAG_SIGGPG, true
+	case RPMSIGTAG_PGP5:
+		return RPMTAG_SIGPGP5, true
+	case RPMSIGTAG_PAYLOADSIZE:
+		return RPMTAG_ARCHIVESIZE, true
+	case RPMSIGTAG_FILESIGNATURES:
+		return RPMTAG_FILESIGNATURES, true
+	case RPMSIGTAG_FILESIGNATURELENGTH:
+		return RPMTAG_FILESIGNATURELENGTH, true
+	}
+	if TAG_ID(t) >= HEADER_SIGBASE && TAG_ID(t) < HEADER_TAGBASE {
+		return TAG_ID(t), true
+	}
+	return 0, false
+}
+
+// sigToHeaderEntries translates the entries of a signature header to the package header namespace,
+// dropping the ones without a package header counterpart.
+func sigToHeaderEntries(indexEntries []indexEntry) []indexEntry {
+	var entries []indexEntry
+	for _, entry := range indexEntries {
+		tag, ok := SIGTAG_ID(entry.Info.Tag).HeaderTag()
+		if !ok {
+			continue
+		}
+		entry.Info.Tag = tag
+		entries = append(entries, entry)
+	}
+	return entries
+}
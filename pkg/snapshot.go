@@ -0,0 +1,145 @@
+package rpmdb
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ErrStaleSnapshot is returned by LoadSnapshot for a snapshot of other headers than the ones of
+// the database: the packages must be listed again.
+var ErrStaleSnapshot = errors.New("snapshot of other headers than the database ones")
+
+// snapshotMagic starts the snapshot files, followed by the gzip compressed gob of a snapshotFile.
+// The version is bumped whenever PackageInfo changes in a way gob can't decode.
+var snapshotMagic = []byte("go-rpmdb snapshot 1\n")
+
+// snapshotFile is a snapshot of the packages of a database.
+type snapshotFile struct {
+	// Headers is the digest of the headers the packages were parsed from, see headersDigest.
+	Headers  string
+	Packages []*PackageInfo
+}
+
+// SaveSnapshot writes the packages of the database, as ListPackages returns them, to a cache file
+// at path with a digest of the headers they were parsed from. The file is replaced atomically: a
+// tool reading it meanwhile gets the previous snapshot.
+func (d *RpmDB) SaveSnapshot(path string) error {
+	pkgList, err := d.ListPackages()
+	if err != nil {
+		return err
+	}
+	digest, err := d.headersDigest()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(snapshotMagic)
+	gz := gzip.NewWriter(&buf)
+	if err := gob.NewEncoder(gz).Encode(&snapshotFile{Headers: digest, Packages: pkgList}); err != nil {
+		return fmt.Errorf("encoding the snapshot: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// LoadSnapshot returns the packages of the snapshot SaveSnapshot wrote at path, provided the
+// headers of the database are still the ones they were parsed from, ErrStaleSnapshot otherwise.
+// Checking the headers only hashes them, which is much cheaper than parsing them again.
+func (d *RpmDB) LoadSnapshot(path string) ([]*PackageInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || !bytes.Equal(magic, snapshotMagic) {
+		return nil, fmt.Errorf("%s: not a snapshot, or of another version", path)
+	}
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	var snapshot snapshotFile
+	if err := gob.NewDecoder(gz).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("%s: decoding the snapshot: %w", path, err)
+	}
+
+	digest, err := d.headersDigest()
+	if err != nil {
+		return nil, err
+	}
+	if digest != snapshot.Headers {
+		return nil, ErrStaleSnapshot
+	}
+	relinkPubKeys(snapshot.Packages)
+	return snapshot.Packages, nil
+}
+
+// headersDigest returns the sha256 digest of the sorted sha256 digests of the header blobs, as
+// WithRedaction and WithStringHandling rewrite them: any change to a header, or to the options
+// rewriting them, changes it, not the order of the database.
+func (d *RpmDB) headersDigest() (string, error) {
+	var digests []string
+	err := d.forEachHeader(func(hnum uint32, blob []byte) error {
+		sum := sha256.Sum256(blob)
+		digests = append(digests, string(sum[:]))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(digests)
+	h := sha256.New()
+	for _, digest := range digests {
+		h.Write([]byte(digest))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// relinkPubKeys points the SignedBy.PubKey of the packages back to the gpg-pubkey packages of the
+// list, gob having decoded a copy of them for each signed package.
+func relinkPubKeys(pkgs []*PackageInfo) {
+	byNEVRA := make(map[string]*PackageInfo)
+	for _, pkg := range pkgs {
+		if pkg.Name == "gpg-pubkey" {
+			byNEVRA[pkg.NEVRA()] = pkg
+		}
+	}
+	for _, pkg := range pkgs {
+		if pkg.SignedBy == nil || pkg.SignedBy.PubKey == nil {
+			continue
+		}
+		if pubKey, ok := byNEVRA[pkg.SignedBy.PubKey.NEVRA()]; ok {
+			pkg.SignedBy.PubKey = pubKey
+		}
+	}
+}
@@ -0,0 +1,578 @@
+package rpmdb
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// WithMemoryBudget bounds to about bytes each of the sets a database of millions of files or
+// packages would otherwise hold in memory, so that it doesn't get a constrained scanner killed:
+// past it, they are spilled to a temporary file and read back from it. It bounds the file owners
+// ExportFileOwners and ExportFileOwnersIndex sort, the keys WithSortedOutput sorts, and the
+// packages of Packages, the bounded counterpart of ListPackages. The slices the other listings
+// return are in memory regardless. Zero or less keeps everything in memory, the default.
+func WithMemoryBudget(bytes int64) Option {
+	return func(d *RpmDB) {
+		d.memoryBudget = bytes
+	}
+}
+
+// spillFile is the temporary file a set spills its runs to, created with the first one. The runs
+// are read back with ReadAt, the writes stay at the end of the file. It must be closed.
+type spillFile struct {
+	prefix string
+	f      *os.File
+	end    int64
+	runs   []spillRun
+}
+
+// spillRun is a run of the spill file.
+type spillRun struct {
+	offset, length int64
+}
+
+// appendRun appends a run to the file, whose records write puts with w.
+func (s *spillFile) appendRun(write func(w *spillWriter) error) error {
+	if s.f == nil {
+		f, err := ioutil.TempFile("", s.prefix)
+		if err != nil {
+			return err
+		}
+		s.f = f
+	}
+	w := &spillWriter{bw: bufio.NewWriter(s.f)}
+	if err := write(w); err != nil {
+		return err
+	}
+	if err := w.bw.Flush(); err != nil {
+		return err
+	}
+	s.runs = append(s.runs, spillRun{offset: s.end, length: w.n})
+	s.end += w.n
+	return nil
+}
+
+// run returns a reader of the i-th run.
+func (s *spillFile) run(i int) *bufio.Reader {
+	return bufio.NewReader(io.NewSectionReader(s.f, s.runs[i].offset, s.runs[i].length))
+}
+
+// Close removes the file.
+func (s *spillFile) Close() error {
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	os.Remove(s.f.Name())
+	return err
+}
+
+// spillWriter writes the records of a run, counting its bytes.
+type spillWriter struct {
+	bw  *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+	n   int64
+}
+
+func (w *spillWriter) Write(p []byte) (int, error) {
+	n, err := w.bw.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+func (w *spillWriter) putUvarint(v uint64) {
+	n := binary.PutUvarint(w.buf[:], v)
+	w.Write(w.buf[:n])
+}
+
+// putString writes the uvarint length and the bytes of s.
+func (w *spillWriter) putString(s string) {
+	w.putUvarint(uint64(len(s)))
+	w.bw.WriteString(s)
+	w.n += int64(len(s))
+}
+
+// readSpillString reads a string putString wrote.
+func readSpillString(r *bufio.Reader) (string, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// ownerOverhead is the memory a FileOwner takes besides its path and digest: its four string
+// headers, the NEVRA and the algorithm being shared by the files of a package.
+const ownerOverhead = 4 * 16
+
+// ownerSet is the files of every package sorted by path then NEVRA, kept in memory or, past the
+// budget, in sorted runs of a spill file. It must be closed.
+type ownerSet struct {
+	budget int64
+	size   int64
+	owners []FileOwner
+	count  int
+	spill  spillFile
+}
+
+func ownerLess(a, b *FileOwner) bool {
+	if a.Path != b.Path {
+		return a.Path < b.Path
+	}
+	return a.NEVRA < b.NEVRA
+}
+
+func (s *ownerSet) add(owner FileOwner) error {
+	s.owners = append(s.owners, owner)
+	s.count++
+	s.size += int64(len(owner.Path)+len(owner.Digest)) + ownerOverhead
+	if s.budget > 0 && s.size > s.budget {
+		return s.spillRun()
+	}
+	return nil
+}
+
+func (s *ownerSet) sort() {
+	sort.Slice(s.owners, func(i, j int) bool {
+		return ownerLess(&s.owners[i], &s.owners[j])
+	})
+}
+
+// spillRun appends the owners in memory to the spill file as a sorted run: the path, NEVRA, digest
+// and algorithm of each.
+func (s *ownerSet) spillRun() error {
+	s.spill.prefix = "rpmdb-owners"
+	s.sort()
+	err := s.spill.appendRun(func(w *spillWriter) error {
+		for _, owner := range s.owners {
+			for _, field := range []string{owner.Path, owner.NEVRA, owner.Digest, owner.Algo} {
+				w.putString(field)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to spill the file owners: %w", err)
+	}
+	s.owners = s.owners[:0]
+	s.size = 0
+	return nil
+}
+
+// finish sorts the owners once they are all added, spilling the last run if others were.
+func (s *ownerSet) finish() error {
+	if len(s.spill.runs) > 0 && len(s.owners) > 0 {
+		return s.spillRun()
+	}
+	s.sort()
+	return nil
+}
+
+// each calls fn with the owners in order, merging the runs when spilled. It can be called again.
+func (s *ownerSet) each(fn func(owner *FileOwner) error) error {
+	if len(s.spill.runs) == 0 {
+		for i := range s.owners {
+			if err := fn(&s.owners[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var cursors ownerCursors
+	for i := range s.spill.runs {
+		c := &ownerCursor{r: s.spill.run(i)}
+		if ok, err := c.next(); err != nil {
+			return err
+		} else if ok {
+			cursors = append(cursors, c)
+		}
+	}
+	heap.Init(&cursors)
+	for len(cursors) > 0 {
+		c := cursors[0]
+		if err := fn(&c.owner); err != nil {
+			return err
+		}
+		ok, err := c.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Fix(&cursors, 0)
+		} else {
+			heap.Pop(&cursors)
+		}
+	}
+	return nil
+}
+
+// Close removes the spill file.
+func (s *ownerSet) Close() error {
+	return s.spill.Close()
+}
+
+// ownerCursor reads the owners of a run.
+type ownerCursor struct {
+	r     *bufio.Reader
+	owner FileOwner
+}
+
+// next reads the next owner of the run, false at its end.
+func (c *ownerCursor) next() (bool, error) {
+	if _, err := c.r.Peek(1); err == io.EOF {
+		return false, nil
+	}
+	var fields [4]string
+	for i := range fields {
+		field, err := readSpillString(c.r)
+		if err != nil {
+			return false, fmt.Errorf("failed to read the spilled file owners: %w", err)
+		}
+		fields[i] = field
+	}
+	c.owner = FileOwner{Path: fields[0], NEVRA: fields[1], Digest: fields[2], Algo: fields[3]}
+	return true, nil
+}
+
+// ownerCursors is a heap of the cursors of the runs, by their current owner.
+type ownerCursors []*ownerCursor
+
+func (h ownerCursors) Len() int            { return len(h) }
+func (h ownerCursors) Less(i, j int) bool  { return ownerLess(&h[i].owner, &h[j].owner) }
+func (h ownerCursors) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *ownerCursors) Push(x interface{}) { *h = append(*h, x.(*ownerCursor)) }
+func (h *ownerCursors) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// headerKeyOverhead is the memory a headerKey takes besides its strings: the PackageInfo holding
+// its NEVRA, the instance and the page number.
+const headerKeyOverhead = 384
+
+// headerKeySet is the keys of the headers WithSortedOutput sorts, kept in memory or, past the
+// budget, in sorted runs of a spill file. It must be closed.
+type headerKeySet struct {
+	budget int64
+	size   int64
+	keys   []headerKey
+	spill  spillFile
+}
+
+// headerKeyLess orders the keys by name, EVR and arch.
+func headerKeyLess(a, b *headerKey) bool {
+	if a.pkg.Name != b.pkg.Name {
+		return a.pkg.Name < b.pkg.Name
+	}
+	if cmp := CompareEVR(&a.pkg, &b.pkg); cmp != 0 {
+		return cmp < 0
+	}
+	return a.pkg.Arch < b.pkg.Arch
+}
+
+func (s *headerKeySet) add(key headerKey) error {
+	s.keys = append(s.keys, key)
+	s.size += int64(len(key.pkg.Name)+len(key.pkg.Version)+len(key.pkg.Release)+len(key.pkg.Arch)) + headerKeyOverhead
+	if s.budget > 0 && s.size > s.budget {
+		return s.spillRun()
+	}
+	return nil
+}
+
+// sort keeps the database order of the equal keys, as the runs are merged.
+func (s *headerKeySet) sort() {
+	sort.SliceStable(s.keys, func(i, j int) bool {
+		return headerKeyLess(&s.keys[i], &s.keys[j])
+	})
+}
+
+// spillRun appends the keys in memory to the spill file as a sorted run: the name, epoch, version,
+// release, arch, instance and page number of each.
+func (s *headerKeySet) spillRun() error {
+	s.spill.prefix = "rpmdb-keys"
+	s.sort()
+	err := s.spill.appendRun(func(w *spillWriter) error {
+		for _, key := range s.keys {
+			w.putString(key.pkg.Name)
+			w.putUvarint(uint64(key.pkg.Epoch))
+			w.putString(key.pkg.Version)
+			w.putString(key.pkg.Release)
+			w.putString(key.pkg.Arch)
+			w.putUvarint(uint64(key.hnum))
+			w.putUvarint(uint64(key.pageNo))
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to spill the header keys: %w", err)
+	}
+	s.keys = s.keys[:0]
+	s.size = 0
+	return nil
+}
+
+// finish sorts the keys once they are all added, spilling the last run if others were.
+func (s *headerKeySet) finish() error {
+	if len(s.spill.runs) > 0 && len(s.keys) > 0 {
+		return s.spillRun()
+	}
+	s.sort()
+	return nil
+}
+
+// each calls fn with the keys in order, merging the runs when spilled: the equal keys of an
+// earlier run come first, so that the order is the one of sorting them all at once.
+func (s *headerKeySet) each(fn func(key *headerKey) error) error {
+	if len(s.spill.runs) == 0 {
+		for i := range s.keys {
+			if err := fn(&s.keys[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var cursors headerKeyCursors
+	for i := range s.spill.runs {
+		c := &headerKeyCursor{r: s.spill.run(i), run: i}
+		if ok, err := c.next(); err != nil {
+			return err
+		} else if ok {
+			cursors = append(cursors, c)
+		}
+	}
+	heap.Init(&cursors)
+	for len(cursors) > 0 {
+		c := cursors[0]
+		if err := fn(&c.key); err != nil {
+			return err
+		}
+		ok, err := c.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Fix(&cursors, 0)
+		} else {
+			heap.Pop(&cursors)
+		}
+	}
+	return nil
+}
+
+// Close removes the spill file.
+func (s *headerKeySet) Close() error {
+	return s.spill.Close()
+}
+
+// headerKeyCursor reads the keys of a run.
+type headerKeyCursor struct {
+	r   *bufio.Reader
+	run int
+	key headerKey
+}
+
+// next reads the next key of the run, false at its end.
+func (c *headerKeyCursor) next() (bool, error) {
+	if _, err := c.r.Peek(1); err == io.EOF {
+		return false, nil
+	}
+	var key headerKey
+	var err error
+	var epoch, hnum, pageNo uint64
+	if key.pkg.Name, err = readSpillString(c.r); err == nil {
+		epoch, err = binary.ReadUvarint(c.r)
+	}
+	if err == nil {
+		key.pkg.Version, err = readSpillString(c.r)
+	}
+	if err == nil {
+		key.pkg.Release, err = readSpillString(c.r)
+	}
+	if err == nil {
+		key.pkg.Arch, err = readSpillString(c.r)
+	}
+	if err == nil {
+		hnum, err = binary.ReadUvarint(c.r)
+	}
+	if err == nil {
+		pageNo, err = binary.ReadUvarint(c.r)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read the spilled header keys: %w", err)
+	}
+	key.pkg.Epoch, key.hnum, key.pageNo = int(epoch), uint32(hnum), uint32(pageNo)
+	c.key = key
+	return true, nil
+}
+
+// headerKeyCursors is a heap of the cursors of the runs, by their current key then run.
+type headerKeyCursors []*headerKeyCursor
+
+func (h headerKeyCursors) Len() int { return len(h) }
+func (h headerKeyCursors) Less(i, j int) bool {
+	a, b := &h[i].key, &h[j].key
+	if headerKeyLess(a, b) {
+		return true
+	}
+	if headerKeyLess(b, a) {
+		return false
+	}
+	return h[i].run < h[j].run
+}
+func (h headerKeyCursors) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *headerKeyCursors) Push(x interface{}) { *h = append(*h, x.(*headerKeyCursor)) }
+func (h *headerKeyCursors) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// packageOverhead is the memory a PackageInfo takes besides the bytes of its strings.
+const packageOverhead = 384
+
+// PackageSet is the packages of a listing, in database order, kept in memory or, past
+// WithMemoryBudget, in runs of a temporary file they are decoded back from one at a time. It must
+// be closed.
+type PackageSet struct {
+	budget int64
+	size   int64
+	pkgs   []*PackageInfo
+	count  int
+	spill  spillFile
+	// the gpg-pubkey packages by lower case version, the key IDs of the signatures are attributed to
+	pubKeys map[string]*PackageInfo
+}
+
+// Packages lists the packages like ListPackages, within WithMemoryBudget: past it, the decoded
+// packages are spilled to a temporary file, the set keeping in memory only the gpg-pubkey ones
+// their signatures are attributed to.
+func (d *RpmDB) Packages() (*PackageSet, error) {
+	set := &PackageSet{budget: d.memoryBudget, pubKeys: make(map[string]*PackageInfo)}
+	err := d.forEachPackage(func(hnum uint32, indexEntries []indexEntry, pkg *PackageInfo) error {
+		if keyID, ok := signatureKeyID(indexEntries); ok {
+			pkg.SignedBy = &PubKeyRef{KeyID: d.intern(keyID)}
+		}
+		return set.add(pkg)
+	})
+	if err == nil {
+		err = set.finish()
+	}
+	if err != nil {
+		set.Close()
+		return nil, err
+	}
+	return set, nil
+}
+
+func (s *PackageSet) add(pkg *PackageInfo) error {
+	if pkg.Name == "gpg-pubkey" {
+		s.pubKeys[strings.ToLower(pkg.Version)] = pkg
+	}
+	s.pkgs = append(s.pkgs, pkg)
+	s.count++
+	s.size += packageOverhead
+	for _, field := range []string{pkg.Name, pkg.Version, pkg.Release, pkg.Arch, pkg.SourceRpm, pkg.License, pkg.Vendor, pkg.URL,
+		pkg.Packager, pkg.ModularityLabel, pkg.PayloadDigest, pkg.PayloadDigestAlt} {
+		s.size += int64(len(field))
+	}
+	if s.budget > 0 && s.size > s.budget {
+		return s.spillRun()
+	}
+	return nil
+}
+
+// spillRun appends the packages in memory to the spill file as a run, a gob stream of them.
+func (s *PackageSet) spillRun() error {
+	s.spill.prefix = "rpmdb-packages"
+	err := s.spill.appendRun(func(w *spillWriter) error {
+		enc := gob.NewEncoder(w)
+		for _, pkg := range s.pkgs {
+			if err := enc.Encode(pkg); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to spill the packages: %w", err)
+	}
+	s.pkgs = s.pkgs[:0]
+	s.size = 0
+	return nil
+}
+
+// finish spills the last packages if others were, so that the memory held past Packages is the
+// gpg-pubkey packages only.
+func (s *PackageSet) finish() error {
+	if len(s.spill.runs) > 0 && len(s.pkgs) > 0 {
+		return s.spillRun()
+	}
+	return nil
+}
+
+// Len returns the number of packages.
+func (s *PackageSet) Len() int {
+	return s.count
+}
+
+// Each calls fn with every package, in database order, or the WithSortedOutput one. The packages
+// read back from the spill file are decoded anew for each call, the PubKey of their SignedBy and
+// the gpg-pubkey packages being the ones of the set. It can be called again; an error of fn stops
+// it and is returned.
+func (s *PackageSet) Each(fn func(pkg *PackageInfo) error) error {
+	for i := range s.spill.runs {
+		dec := gob.NewDecoder(s.spill.run(i))
+		for {
+			pkg := &PackageInfo{}
+			if err := dec.Decode(pkg); err == io.EOF {
+				break
+			} else if err != nil {
+				return fmt.Errorf("failed to read the spilled packages: %w", err)
+			}
+			if err := s.yield(pkg, fn); err != nil {
+				return err
+			}
+		}
+	}
+	for _, pkg := range s.pkgs {
+		if err := s.yield(pkg, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// yield attributes the signature of pkg like ListPackages before calling fn with it.
+func (s *PackageSet) yield(pkg *PackageInfo, fn func(pkg *PackageInfo) error) error {
+	if pkg.Name == "gpg-pubkey" {
+		if pubKey, ok := s.pubKeys[strings.ToLower(pkg.Version)]; ok {
+			pkg = pubKey
+		}
+	}
+	if ref := pkg.SignedBy; ref != nil && len(ref.KeyID) == 16 {
+		ref.PubKey = s.pubKeys[ref.KeyID[8:]]
+	}
+	return fn(pkg)
+}
+
+// Close removes the spill file.
+func (s *PackageSet) Close() error {
+	return s.spill.Close()
+}
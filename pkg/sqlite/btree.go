@@ -0,0 +1,174 @@
+package sqlite
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// b-tree page types
+// ref. https://www.sqlite.org/fileformat2.html#b_tree_pages
+const (
+	interiorIndexPage = 2
+	interiorTablePage = 5
+	leafIndexPage     = 10
+	leafTablePage     = 13
+)
+
+// walkTable calls fn with the rowid and payload of every cell of the table b-tree rooted at rootPage,
+// in rowid order.
+func (db *DB) walkTable(rootPage uint32, fn func(rowid int64, payload []byte) error) error {
+	visited := make(map[uint32]struct{})
+	return db.walkTablePage(rootPage, visited, fn)
+}
+
+func (db *DB) walkTablePage(pageNo uint32, visited map[uint32]struct{}, fn func(rowid int64, payload []byte) error) error {
+	if _, ok := visited[pageNo]; ok {
+		return fmt.Errorf("b-tree loop at page %d", pageNo)
+	}
+	visited[pageNo] = struct{}{}
+
+	page, err := db.readPage(pageNo)
+	if err != nil {
+		return err
+	}
+	// the first page starts with the database header
+	offset := 0
+	if pageNo == 1 {
+		offset = headerSize
+	}
+	if len(page) < offset+8 {
+		return fmt.Errorf("page %d too short", pageNo)
+	}
+
+	pageType := page[offset]
+	numCells := int(binary.BigEndian.Uint16(page[offset+3:]))
+	cellPointers := offset + 8
+	if pageType == interiorTablePage {
+		cellPointers = offset + 12
+	}
+	if cellPointers+2*numCells > len(page) {
+		return fmt.Errorf("page %d: invalid cell count: %d", pageNo, numCells)
+	}
+
+	for i := 0; i < numCells; i++ {
+		cell := int(binary.BigEndian.Uint16(page[cellPointers+2*i:]))
+		if cell >= len(page) {
+			return fmt.Errorf("page %d: invalid cell offset: %d", pageNo, cell)
+		}
+
+		switch pageType {
+		case interiorTablePage:
+			if cell+4 > len(page) {
+				return fmt.Errorf("page %d: truncated cell", pageNo)
+			}
+			child := binary.BigEndian.Uint32(page[cell:])
+			if err := db.walkTablePage(child, visited, fn); err != nil {
+				return err
+			}
+		case leafTablePage:
+			rowid, payload, err := db.leafTableCell(page, cell)
+			if err != nil {
+				return fmt.Errorf("page %d: %w", pageNo, err)
+			}
+			if err := fn(rowid, payload); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("page %d: unexpected table page type: %d", pageNo, pageType)
+		}
+	}
+
+	if pageType == interiorTablePage {
+		rightMost := binary.BigEndian.Uint32(page[offset+8:])
+		return db.walkTablePage(rightMost, visited, fn)
+	}
+	return nil
+}
+
+// leafTableCell decodes a table leaf cell: payload size, rowid, payload and the first overflow page
+// when the payload doesn't fit.
+func (db *DB) leafTableCell(page []byte, cell int) (int64, []byte, error) {
+	payloadSize, n := readVarint(page[cell:])
+	if n == 0 {
+		return 0, nil, fmt.Errorf("invalid payload size")
+	}
+	cell += n
+	rowid, n := readVarint(page[cell:])
+	if n == 0 {
+		return 0, nil, fmt.Errorf("invalid rowid")
+	}
+	cell += n
+
+	if payloadSize < 0 || payloadSize > int64(db.pageSize)*int64(db.pageCount+1) {
+		return 0, nil, fmt.Errorf("invalid payload size: %d", payloadSize)
+	}
+	payload, err := db.readPayload(page, cell, int(payloadSize), db.localPayloadSize(int(payloadSize)))
+	if err != nil {
+		return 0, nil, err
+	}
+	return rowid, payload, nil
+}
+
+// readPayload reads the payload of size bytes starting at cell, local of them on the page and the
+// rest on the chain of overflow pages whose first page number follows them.
+func (db *DB) readPayload(page []byte, cell, size, local int) ([]byte, error) {
+	if cell+local > len(page) {
+		return nil, fmt.Errorf("truncated payload")
+	}
+
+	payload := make([]byte, 0, size)
+	payload = append(payload, page[cell:cell+local]...)
+	if local == size {
+		return payload, nil
+	}
+
+	if cell+local+4 > len(page) {
+		return nil, fmt.Errorf("truncated overflow pointer")
+	}
+	overflow := binary.BigEndian.Uint32(page[cell+local:])
+	visited := make(map[uint32]struct{})
+	for len(payload) < size {
+		if overflow == 0 {
+			return nil, fmt.Errorf("overflow chain ends early: %d < %d", len(payload), size)
+		}
+		if _, ok := visited[overflow]; ok {
+			return nil, fmt.Errorf("overflow loop at page %d", overflow)
+		}
+		visited[overflow] = struct{}{}
+
+		data, err := db.readPage(overflow)
+		if err != nil {
+			return nil, err
+		}
+		overflow = binary.BigEndian.Uint32(data)
+		chunk := data[4:db.usableSize]
+		if remaining := size - len(payload); len(chunk) > remaining {
+			chunk = chunk[:remaining]
+		}
+		payload = append(payload, chunk...)
+	}
+	return payload, nil
+}
+
+// localPayloadSize is the part of a table leaf payload stored on the page itself.
+func (db *DB) localPayloadSize(size int) int {
+	return db.localSize(size, db.usableSize-35)
+}
+
+// localIndexPayloadSize is the part of an index payload stored on the page itself.
+func (db *DB) localIndexPayloadSize(size int) int {
+	return db.localSize(size, (db.usableSize-12)*64/255-23)
+}
+
+func (db *DB) localSize(size, maxLocal int) int {
+	u := db.usableSize
+	if size <= maxLocal {
+		return size
+	}
+	minLocal := (u-12)*32/255 - 23
+	k := minLocal + (size-minLocal)%(u-4)
+	if k <= maxLocal {
+		return k
+	}
+	return minLocal
+}
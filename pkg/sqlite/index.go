@@ -0,0 +1,359 @@
+package sqlite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// Index is an index of the schema, over the columns of a rowid table.
+type Index struct {
+	Name     string
+	Table    string
+	RootPage uint32
+	SQL      string
+	// Columns are the indexed columns in order, nil for the automatic indexes of UNIQUE and
+	// PRIMARY KEY constraints, which have no SQL.
+	Columns []string
+}
+
+// Indexes returns the indexes of the table named name.
+func (db *DB) Indexes(name string) []*Index {
+	var indexes []*Index
+	for _, index := range db.indexes {
+		if strings.EqualFold(index.Table, name) {
+			indexes = append(indexes, index)
+		}
+	}
+	return indexes
+}
+
+// parseCreateIndex extracts the column names of a CREATE INDEX statement.
+// ref. https://www.sqlite.org/lang_createindex.html
+func parseCreateIndex(sql string) []string {
+	start := strings.Index(sql, "(")
+	end := strings.LastIndex(sql, ")")
+	if start < 0 || end < start {
+		return nil
+	}
+	var columns []string
+	for _, def := range splitTopLevel(sql[start+1 : end]) {
+		if fields := strings.Fields(def); len(fields) > 0 {
+			columns = append(columns, unquote(fields[0]))
+		}
+	}
+	return columns
+}
+
+// Row returns the row of the table having rowid, ok being false when there is none. Only the pages
+// on the path to the row are read.
+func (db *DB) Row(name string, rowid int64) (row Row, ok bool, err error) {
+	table, err := db.rowidTable(name)
+	if err != nil {
+		return Row{}, false, err
+	}
+
+	pageNo := table.RootPage
+	for depth := 0; ; depth++ {
+		if depth > maxDepth {
+			return Row{}, false, fmt.Errorf("%s: b-tree too deep", name)
+		}
+		page, pageType, cells, err := db.btreePage(pageNo)
+		if err != nil {
+			return Row{}, false, err
+		}
+		switch pageType {
+		case interiorTablePage:
+			// the cells hold the largest rowid of their left child, the right-most pointer the rest
+			next := binary.BigEndian.Uint32(page[btreeHeaderOffset(pageNo)+8:])
+			for _, cell := range cells {
+				if cell+4 > len(page) {
+					return Row{}, false, fmt.Errorf("page %d: truncated cell", pageNo)
+				}
+				key, n := readVarint(page[cell+4:])
+				if n == 0 {
+					return Row{}, false, fmt.Errorf("page %d: invalid rowid", pageNo)
+				}
+				if rowid <= key {
+					next = binary.BigEndian.Uint32(page[cell:])
+					break
+				}
+			}
+			pageNo = next
+		case leafTablePage:
+			for _, cell := range cells {
+				cellRowid, payload, err := db.leafTableCell(page, cell)
+				if err != nil {
+					return Row{}, false, fmt.Errorf("page %d: %w", pageNo, err)
+				}
+				if cellRowid == rowid {
+					row, err := table.row(rowid, payload)
+					return row, err == nil, err
+				}
+			}
+			return Row{}, false, nil
+		default:
+			return Row{}, false, fmt.Errorf("page %d: unexpected table page type: %d", pageNo, pageType)
+		}
+	}
+}
+
+// Lookup calls fn with the rows of the table whose column equals value, like
+//
+//	SELECT * FROM name WHERE column = value
+//
+// in the order of the rowids. The index of the table starting with the column is searched when
+// there is one, the rows being read by rowid; the whole table is walked otherwise. Values compare
+// as sqlite does with the BINARY collation and without type affinity: an int64 never equals a
+// string.
+func (db *DB) Lookup(name, column string, value interface{}, fn func(row Row) error) error {
+	table, err := db.rowidTable(name)
+	if err != nil {
+		return err
+	}
+	i := table.ColumnIndex(column)
+	if i < 0 {
+		return fmt.Errorf("%s: no such column: %s", name, column)
+	}
+	switch v := value.(type) {
+	case int:
+		value = int64(v)
+	case int64, float64, string, []byte, nil:
+	default:
+		return fmt.Errorf("unsupported value type: %T", value)
+	}
+	if i == table.rowidColumn {
+		rowid, ok := value.(int64)
+		if !ok {
+			return nil
+		}
+		row, ok, err := db.Row(name, rowid)
+		if err != nil || !ok {
+			return err
+		}
+		return fn(row)
+	}
+
+	var index *Index
+	for _, candidate := range db.Indexes(name) {
+		if len(candidate.Columns) > 0 && strings.EqualFold(candidate.Columns[0], column) {
+			index = candidate
+			break
+		}
+	}
+	if index == nil {
+		return db.Rows(name, func(row Row) error {
+			if compareValues(row.Values[i], value) != 0 {
+				return nil
+			}
+			return fn(row)
+		})
+	}
+
+	var rowids []int64
+	visited := make(map[uint32]struct{})
+	err = db.searchIndex(index.RootPage, value, visited, func(rowid int64) error {
+		rowids = append(rowids, rowid)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", index.Name, err)
+	}
+	// the entries of equal keys are sorted by rowid already
+	for _, rowid := range rowids {
+		row, ok, err := db.Row(name, rowid)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%s: missing row %d", index.Name, rowid)
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// the depth of a b-tree is a handful of pages, more is a corrupt file
+const maxDepth = 64
+
+// searchIndex calls fn with the rowids of the entries of the index b-tree rooted at pageNo whose
+// first column equals value, in index order. An index record holds the indexed columns followed by
+// the rowid; the cells of an interior page are entries too, larger than the ones of their left
+// child.
+func (db *DB) searchIndex(pageNo uint32, value interface{}, visited map[uint32]struct{}, fn func(rowid int64) error) error {
+	if _, ok := visited[pageNo]; ok {
+		return fmt.Errorf("b-tree loop at page %d", pageNo)
+	}
+	visited[pageNo] = struct{}{}
+
+	page, pageType, cells, err := db.btreePage(pageNo)
+	if err != nil {
+		return err
+	}
+	if pageType != interiorIndexPage && pageType != leafIndexPage {
+		return fmt.Errorf("page %d: unexpected index page type: %d", pageNo, pageType)
+	}
+	interior := pageType == interiorIndexPage
+
+	for _, cell := range cells {
+		var child uint32
+		if interior {
+			if cell+4 > len(page) {
+				return fmt.Errorf("page %d: truncated cell", pageNo)
+			}
+			child = binary.BigEndian.Uint32(page[cell:])
+			cell += 4
+		}
+		values, err := db.indexCell(page, cell)
+		if err != nil {
+			return fmt.Errorf("page %d: %w", pageNo, err)
+		}
+		if len(values) < 2 {
+			return fmt.Errorf("page %d: invalid index record length: %d", pageNo, len(values))
+		}
+
+		cmp := compareValues(value, values[0])
+		if cmp > 0 {
+			continue
+		}
+		// the left child holds the entries up to this one, equal ones included
+		if interior {
+			if err := db.searchIndex(child, value, visited, fn); err != nil {
+				return err
+			}
+		}
+		if cmp < 0 {
+			return nil
+		}
+		rowid, ok := values[len(values)-1].(int64)
+		if !ok {
+			return fmt.Errorf("page %d: invalid index rowid: %v", pageNo, values[len(values)-1])
+		}
+		if err := fn(rowid); err != nil {
+			return err
+		}
+	}
+
+	if interior {
+		rightMost := binary.BigEndian.Uint32(page[btreeHeaderOffset(pageNo)+8:])
+		return db.searchIndex(rightMost, value, visited, fn)
+	}
+	return nil
+}
+
+// indexCell decodes the record of an index cell: payload size, payload and the first overflow page
+// when the payload doesn't fit.
+func (db *DB) indexCell(page []byte, cell int) ([]interface{}, error) {
+	payloadSize, n := readVarint(page[cell:])
+	if n == 0 || payloadSize < 0 || payloadSize > int64(db.pageSize)*int64(db.pageCount+1) {
+		return nil, fmt.Errorf("invalid payload size: %d", payloadSize)
+	}
+	payload, err := db.readPayload(page, cell+n, int(payloadSize), db.localIndexPayloadSize(int(payloadSize)))
+	if err != nil {
+		return nil, err
+	}
+	return decodeRecord(payload)
+}
+
+// btreePage reads a b-tree page and returns its type and the offsets of its cells.
+func (db *DB) btreePage(pageNo uint32) (page []byte, pageType byte, cells []int, err error) {
+	page, err = db.readPage(pageNo)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	offset := btreeHeaderOffset(pageNo)
+	if len(page) < offset+12 {
+		return nil, 0, nil, fmt.Errorf("page %d too short", pageNo)
+	}
+
+	pageType = page[offset]
+	numCells := int(binary.BigEndian.Uint16(page[offset+3:]))
+	cellPointers := offset + 8
+	if pageType == interiorTablePage || pageType == interiorIndexPage {
+		cellPointers = offset + 12
+	}
+	if cellPointers+2*numCells > len(page) {
+		return nil, 0, nil, fmt.Errorf("page %d: invalid cell count: %d", pageNo, numCells)
+	}
+	cells = make([]int, numCells)
+	for i := range cells {
+		cells[i] = int(binary.BigEndian.Uint16(page[cellPointers+2*i:]))
+		if cells[i] >= len(page) {
+			return nil, 0, nil, fmt.Errorf("page %d: invalid cell offset: %d", pageNo, cells[i])
+		}
+	}
+	return page, pageType, cells, nil
+}
+
+// btreeHeaderOffset is where the b-tree header of a page starts, the first page starting with the
+// database header.
+func btreeHeaderOffset(pageNo uint32) int {
+	if pageNo == 1 {
+		return headerSize
+	}
+	return 0
+}
+
+// compareValues orders the values as sqlite does with the BINARY collation: NULL, then the numbers,
+// the strings and the blobs.
+// ref. https://www.sqlite.org/datatype3.html#sort_order
+func compareValues(a, b interface{}) int {
+	ca, cb := valueClass(a), valueClass(b)
+	if ca != cb {
+		if ca < cb {
+			return -1
+		}
+		return 1
+	}
+	switch a := a.(type) {
+	case int64, float64:
+		ia, aInt := a.(int64)
+		ib, bInt := b.(int64)
+		if !aInt || !bInt {
+			// large integers lose precision as floats, they are only converted next to a float
+			fa, fb := toFloat(a), toFloat(b)
+			ia, ib = 0, 0
+			switch {
+			case fa < fb:
+				ia = -1
+			case fa > fb:
+				ia = 1
+			}
+		}
+		switch {
+		case ia < ib:
+			return -1
+		case ia > ib:
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(a, b.(string))
+	case []byte:
+		return bytes.Compare(a, b.([]byte))
+	}
+	return 0
+}
+
+func valueClass(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case int64, float64:
+		return 1
+	case string:
+		return 2
+	default:
+		return 3
+	}
+}
+
+func toFloat(v interface{}) float64 {
+	if i, ok := v.(int64); ok {
+		return float64(i)
+	}
+	return v.(float64)
+}
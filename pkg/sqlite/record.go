@@ -0,0 +1,102 @@
+package sqlite
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// readVarint decodes a big-endian variable length integer of 1 to 9 bytes, n is 0 when b is too
+// short.
+// ref. https://www.sqlite.org/fileformat2.html#varint
+func readVarint(b []byte) (v int64, n int) {
+	var u uint64
+	for i := 0; i < 9; i++ {
+		if i >= len(b) {
+			return 0, 0
+		}
+		if i == 8 {
+			u = u<<8 | uint64(b[i])
+			return int64(u), 9
+		}
+		u = u<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return int64(u), i + 1
+		}
+	}
+	return int64(u), 9
+}
+
+// decodeRecord decodes a record: a header of serial types followed by the values.
+// ref. https://www.sqlite.org/fileformat2.html#record_format
+func decodeRecord(payload []byte) ([]interface{}, error) {
+	headerSize, n := readVarint(payload)
+	if n == 0 || headerSize < int64(n) || headerSize > int64(len(payload)) {
+		return nil, fmt.Errorf("invalid record header size: %d", headerSize)
+	}
+
+	var values []interface{}
+	header := payload[n:headerSize]
+	body := payload[headerSize:]
+	for len(header) > 0 {
+		serialType, n := readVarint(header)
+		if n == 0 {
+			return nil, fmt.Errorf("invalid serial type")
+		}
+		header = header[n:]
+
+		size := serialTypeSize(serialType)
+		if size < 0 || size > len(body) {
+			return nil, fmt.Errorf("invalid value of serial type %d", serialType)
+		}
+		data := body[:size]
+		body = body[size:]
+
+		switch {
+		case serialType == 0:
+			values = append(values, nil)
+		case serialType >= 1 && serialType <= 6:
+			values = append(values, decodeInt(data))
+		case serialType == 7:
+			values = append(values, math.Float64frombits(binary.BigEndian.Uint64(data)))
+		case serialType == 8:
+			values = append(values, int64(0))
+		case serialType == 9:
+			values = append(values, int64(1))
+		case serialType >= 12 && serialType%2 == 0:
+			values = append(values, append([]byte{}, data...))
+		case serialType >= 13:
+			values = append(values, string(data))
+		}
+	}
+	return values, nil
+}
+
+func serialTypeSize(serialType int64) int {
+	switch {
+	case serialType >= 0 && serialType <= 4:
+		return int(serialType)
+	case serialType == 5:
+		return 6
+	case serialType == 6 || serialType == 7:
+		return 8
+	case serialType == 8 || serialType == 9:
+		return 0
+	case serialType >= 12:
+		return int((serialType - 12) / 2)
+	}
+	// 10 and 11 are reserved
+	return -1
+}
+
+// decodeInt decodes a big-endian two's complement integer of 1 to 8 bytes.
+func decodeInt(data []byte) int64 {
+	var v int64
+	if len(data) > 0 && data[0]&0x80 != 0 {
+		v = -1
+	}
+	for _, b := range data {
+		v = v<<8 | int64(b)
+	}
+	return v
+}
@@ -0,0 +1,84 @@
+package sqlite
+
+import (
+	"strings"
+)
+
+// table constraints of a CREATE TABLE statement, as opposed to column definitions
+var tableConstraints = map[string]bool{
+	"CONSTRAINT": true,
+	"PRIMARY":    true,
+	"UNIQUE":     true,
+	"CHECK":      true,
+	"FOREIGN":    true,
+}
+
+// parseCreateTable extracts the column names of a CREATE TABLE statement, along with the column
+// aliasing the rowid (INTEGER PRIMARY KEY) and whether the table is WITHOUT ROWID.
+// ref. https://www.sqlite.org/lang_createtable.html#rowid
+func parseCreateTable(sql string) (columns []string, rowidColumn int, withoutRowid bool) {
+	rowidColumn = -1
+	start := strings.Index(sql, "(")
+	end := strings.LastIndex(sql, ")")
+	if start < 0 || end < start {
+		return nil, rowidColumn, false
+	}
+	withoutRowid = strings.Contains(strings.ToUpper(sql[end:]), "WITHOUT ROWID")
+
+	for _, def := range splitTopLevel(sql[start+1 : end]) {
+		fields := strings.Fields(def)
+		if len(fields) == 0 || tableConstraints[strings.ToUpper(fields[0])] {
+			continue
+		}
+		name := unquote(fields[0])
+
+		upper := strings.ToUpper(strings.Join(fields[1:], " "))
+		if len(fields) > 1 && strings.ToUpper(fields[1]) == "INTEGER" && strings.Contains(upper, "PRIMARY KEY") &&
+			!strings.Contains(upper, "PRIMARY KEY DESC") {
+			rowidColumn = len(columns)
+		}
+		columns = append(columns, name)
+	}
+	return columns, rowidColumn, withoutRowid
+}
+
+// splitTopLevel splits on the commas outside of parentheses and quotes.
+func splitTopLevel(s string) []string {
+	var parts []string
+	depth, last := 0, 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '[':
+			quote = ']'
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, s[last:i])
+			last = i + 1
+		}
+	}
+	return append(parts, s[last:])
+}
+
+func unquote(name string) string {
+	if len(name) >= 2 {
+		switch {
+		case name[0] == '"' && name[len(name)-1] == '"',
+			name[0] == '`' && name[len(name)-1] == '`',
+			name[0] == '\'' && name[len(name)-1] == '\'',
+			name[0] == '[' && name[len(name)-1] == ']':
+			return name[1 : len(name)-1]
+		}
+	}
+	return name
+}
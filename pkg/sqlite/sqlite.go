@@ -0,0 +1,230 @@
+// Package sqlite is a minimal read-only reader of the SQLite 3 file format, enough to walk the rows
+// of the tables of rpm's rpmdb.sqlite and dnf's history.sqlite without cgo. It doesn't read the
+// write-ahead log: changes not checkpointed yet into the main file are not seen.
+// ref. https://www.sqlite.org/fileformat2.html
+package sqlite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	headerSize = 100
+
+	// schema table root page
+	schemaRootPage = 1
+
+	textEncodingUTF8 = 1
+)
+
+var headerMagic = []byte("SQLite format 3\x00")
+
+type DB struct {
+	file io.ReaderAt
+	// closer is nil when the database was opened on a caller provided reader
+	closer io.Closer
+
+	pageSize   int
+	usableSize int
+	pageCount  uint32
+
+	tables  map[string]*Table
+	indexes []*Index
+}
+
+// Table is a rowid table of the schema.
+type Table struct {
+	Name     string
+	RootPage uint32
+	SQL      string
+	// Columns are the column names in declaration order.
+	Columns []string
+
+	// index of the INTEGER PRIMARY KEY column, which holds the rowid, -1 when there is none
+	rowidColumn  int
+	withoutRowid bool
+}
+
+// ColumnIndex returns the position of the column in the rows, -1 when the table doesn't have it.
+func (t *Table) ColumnIndex(name string) int {
+	for i, column := range t.Columns {
+		if strings.EqualFold(column, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Row is a row of a table, values are int64, float64, string, []byte or nil.
+type Row struct {
+	Rowid  int64
+	Values []interface{}
+}
+
+func Open(path string) (*DB, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	db, err := NewReader(file, info.Size())
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	db.closer = file
+	return db, nil
+}
+
+// NewReader reads a database from r, size is the length of the database.
+func NewReader(r io.ReaderAt, size int64) (*DB, error) {
+	header := make([]byte, headerSize)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	if !bytes.Equal(header[:len(headerMagic)], headerMagic) {
+		return nil, fmt.Errorf("not a sqlite 3 database")
+	}
+
+	pageSize := int(binary.BigEndian.Uint16(header[16:]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		return nil, fmt.Errorf("unexpected page size: %d", pageSize)
+	}
+	if encoding := binary.BigEndian.Uint32(header[56:]); encoding != textEncodingUTF8 && encoding != 0 {
+		return nil, fmt.Errorf("unsupported text encoding: %d", encoding)
+	}
+
+	db := &DB{
+		file:       r,
+		pageSize:   pageSize,
+		usableSize: pageSize - int(header[20]),
+		pageCount:  uint32(size / int64(pageSize)),
+	}
+	if err := db.readSchema(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+func (db *DB) Close() error {
+	if db.closer == nil {
+		return nil
+	}
+	return db.closer.Close()
+}
+
+// Table returns the table named name, nil when there is none.
+func (db *DB) Table(name string) *Table {
+	return db.tables[strings.ToLower(name)]
+}
+
+// Rows calls fn with every row of the table in rowid order.
+func (db *DB) Rows(name string, fn func(row Row) error) error {
+	table, err := db.rowidTable(name)
+	if err != nil {
+		return err
+	}
+
+	return db.walkTable(table.RootPage, func(rowid int64, payload []byte) error {
+		row, err := table.row(rowid, payload)
+		if err != nil {
+			return err
+		}
+		return fn(row)
+	})
+}
+
+// rowidTable returns the table named name, an error when there is none or it is WITHOUT ROWID.
+func (db *DB) rowidTable(name string) (*Table, error) {
+	table := db.Table(name)
+	if table == nil {
+		return nil, fmt.Errorf("no such table: %s", name)
+	}
+	if table.withoutRowid {
+		return nil, fmt.Errorf("WITHOUT ROWID tables are not supported: %s", name)
+	}
+	return table, nil
+}
+
+// row decodes the record of a row of the table.
+func (t *Table) row(rowid int64, payload []byte) (Row, error) {
+	values, err := decodeRecord(payload)
+	if err != nil {
+		return Row{}, fmt.Errorf("%s: rowid %d: %w", t.Name, rowid, err)
+	}
+	// columns added by ALTER TABLE are missing from older records
+	for len(values) < len(t.Columns) {
+		values = append(values, nil)
+	}
+	if t.rowidColumn >= 0 && t.rowidColumn < len(values) {
+		values[t.rowidColumn] = rowid
+	}
+	return Row{Rowid: rowid, Values: values}, nil
+}
+
+// the schema table has the columns type, name, tbl_name, rootpage and sql
+// ref. https://www.sqlite.org/schematab.html
+func (db *DB) readSchema() error {
+	db.tables = make(map[string]*Table)
+	return db.walkTable(schemaRootPage, func(rowid int64, payload []byte) error {
+		values, err := decodeRecord(payload)
+		if err != nil {
+			return fmt.Errorf("invalid schema record: %w", err)
+		}
+		if len(values) < 5 {
+			return fmt.Errorf("invalid schema record length: %d", len(values))
+		}
+
+		typ, _ := values[0].(string)
+		name, _ := values[1].(string)
+		tableName, _ := values[2].(string)
+		rootPage, _ := values[3].(int64)
+		sql, _ := values[4].(string)
+		if typ == "index" && rootPage > 0 {
+			db.indexes = append(db.indexes, &Index{
+				Name:     name,
+				Table:    tableName,
+				RootPage: uint32(rootPage),
+				SQL:      sql,
+				Columns:  parseCreateIndex(sql),
+			})
+			return nil
+		}
+		if typ != "table" || rootPage <= 0 {
+			return nil
+		}
+
+		table := &Table{
+			Name:     name,
+			RootPage: uint32(rootPage),
+			SQL:      sql,
+		}
+		table.Columns, table.rowidColumn, table.withoutRowid = parseCreateTable(sql)
+		db.tables[strings.ToLower(name)] = table
+		return nil
+	})
+}
+
+func (db *DB) readPage(pageNo uint32) ([]byte, error) {
+	if pageNo < 1 || (db.pageCount > 0 && pageNo > db.pageCount) {
+		return nil, fmt.Errorf("page out of range: %d", pageNo)
+	}
+	page := make([]byte, db.pageSize)
+	if _, err := db.file.ReadAt(page, int64(pageNo-1)*int64(db.pageSize)); err != nil {
+		return nil, fmt.Errorf("failed to read page %d: %w", pageNo, err)
+	}
+	return page, nil
+}
@@ -0,0 +1,167 @@
+package sqlite
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testdata/test.sqlite is made by python's sqlite3 with 1024 byte pages: 2000 small rows spreading
+// over interior pages, a row overflowing to several pages, and a column added by ALTER TABLE.
+func TestRows(t *testing.T) {
+	db, err := Open("testdata/test.sqlite")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	table := db.Table("items")
+	if table == nil {
+		t.Fatalf("missing table items")
+	}
+	wantColumns := []string{"id", "name", "size", "ratio", "data", "note"}
+	if !reflect.DeepEqual(table.Columns, wantColumns) {
+		t.Errorf("columns: got %v, want %v", table.Columns, wantColumns)
+	}
+
+	var count int
+	var lastRowid int64
+	err = db.Rows("items", func(row Row) error {
+		count++
+		if row.Rowid <= lastRowid {
+			return fmt.Errorf("rowid %d after %d", row.Rowid, lastRowid)
+		}
+		lastRowid = row.Rowid
+
+		if id := row.Values[0]; id != row.Rowid {
+			t.Errorf("id: got %v, want %d", id, row.Rowid)
+		}
+		if row.Rowid == 5000 {
+			if name := row.Values[1].(string); name != "long-"+strings.Repeat("x", 5000) {
+				t.Errorf("long name: got %d bytes", len(name))
+			}
+			var blob []byte
+			for i := 0; i < 20; i++ {
+				for b := 0; b < 256; b++ {
+					blob = append(blob, byte(b))
+				}
+			}
+			if !bytes.Equal(row.Values[4].([]byte), blob) {
+				t.Errorf("long blob mismatch")
+			}
+			if row.Values[2] != nil || row.Values[3] != nil {
+				t.Errorf("nulls: got %v, %v", row.Values[2], row.Values[3])
+			}
+			return nil
+		}
+
+		i := row.Rowid
+		want := []interface{}{
+			i,
+			fmt.Sprintf("item-%04d", i),
+			(i*1000003)%(1<<40) - (1 << 39),
+			float64(i) / 8,
+			bytes.Repeat([]byte{byte(i % 256)}, int(i%7)),
+			nil,
+		}
+		if i == 1 {
+			want[5] = "first"
+		}
+		// sqlite stores integral values of REAL columns as integers on disk
+		if f, ok := row.Values[3].(int64); ok {
+			row.Values[3] = float64(f)
+		}
+		if !reflect.DeepEqual(row.Values, want) {
+			t.Errorf("row %d: got %v, want %v", i, row.Values, want)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Rows() error: %v", err)
+	}
+	if count != 2001 {
+		t.Errorf("rows: got %d, want 2001", count)
+	}
+
+	if err := db.Rows("kv", func(Row) error { return nil }); err == nil {
+		t.Errorf("WITHOUT ROWID table: expected error")
+	}
+	if err := db.Rows("missing", func(Row) error { return nil }); err == nil {
+		t.Errorf("missing table: expected error")
+	}
+}
+
+// testdata/index.sqlite is made by python's sqlite3 with 1024 byte pages: a table shaped like the
+// index tables of rpmdb.sqlite, 3000 rows of 300 keys indexed over several levels of pages, and a
+// table without index.
+func TestLookup(t *testing.T) {
+	db, err := Open("testdata/index.sqlite")
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	if indexes := db.Indexes("Basenames"); len(indexes) != 1 || !reflect.DeepEqual(indexes[0].Columns, []string{"key"}) {
+		t.Fatalf("indexes: got %+v", indexes)
+	}
+
+	// the rows of every key, as a full scan finds them
+	want := make(map[string][]Row)
+	err = db.Rows("Basenames", func(row Row) error {
+		key := row.Values[0].(string)
+		want[key] = append(want[key], row)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Rows() error: %v", err)
+	}
+	if len(want) != 300 {
+		t.Fatalf("keys: got %d, want 300", len(want))
+	}
+	for key, rows := range want {
+		var got []Row
+		err := db.Lookup("Basenames", "key", key, func(row Row) error {
+			got = append(got, row)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Lookup(%s) error: %v", key, err)
+		}
+		if !reflect.DeepEqual(got, rows) {
+			t.Errorf("Lookup(%s): got %v, want %v", key, got, rows)
+		}
+	}
+	for _, key := range []interface{}{"file-", "zzz", int64(7), []byte("file-007-xxxxxxx")} {
+		err := db.Lookup("Basenames", "key", key, func(row Row) error {
+			return fmt.Errorf("unexpected row %v", row)
+		})
+		if err != nil {
+			t.Errorf("Lookup(%v) error: %v", key, err)
+		}
+	}
+
+	// without index the table is walked, the rowid column is read by rowid
+	var names []int64
+	err = db.Lookup("plain", "name", "name-3", func(row Row) error {
+		names = append(names, row.Rowid)
+		return nil
+	})
+	if err != nil || len(names) != 50 || names[0] != 3 || names[49] != 493 {
+		t.Errorf("Lookup(name-3): got %v, %v", names, err)
+	}
+	for _, id := range []int64{1, 250, 500, 501, 0} {
+		row, ok, err := db.Row("plain", id)
+		if err != nil {
+			t.Fatalf("Row(%d) error: %v", id, err)
+		}
+		if ok != (id >= 1 && id <= 500) || (ok && row.Values[2] != id) {
+			t.Errorf("Row(%d): got %v, %v", id, row, ok)
+		}
+		var n int
+		if err := db.Lookup("plain", "id", int(id), func(Row) error { n++; return nil }); err != nil || (n == 1) != ok {
+			t.Errorf("Lookup(id=%d): got %d rows, %v", id, n, err)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package rpmdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/chennqqi/go-rpmdb/pkg/bdb"
+	"github.com/chennqqi/go-rpmdb/pkg/sqlite"
+)
+
+// sqliteStorage reads the headers of the Packages table of an rpmdb.sqlite, whose hnum is both the
+// Entry.Key and the Entry.PageNo of a header: ReadOverflow reads the row of the hnum. The index
+// tables have a row per key, hnum and position of the key in its tag.
+// ref. https://github.com/rpm-software-management/rpm/blob/rpm-4.16.0-release/lib/backend/sqlite.c
+type sqliteStorage struct {
+	db *sqlite.DB
+	// the column of the blobs in the Packages table
+	blobColumn int
+}
+
+func openSQLite(path string) (*sqliteStorage, error) {
+	db, err := sqlite.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return newSQLiteStorage(db)
+}
+
+// newSQLiteStorage reads the Packages table of db, closing db when it has none.
+func newSQLiteStorage(db *sqlite.DB) (*sqliteStorage, error) {
+	table := db.Table("Packages")
+	if table == nil || table.ColumnIndex("blob") < 0 {
+		db.Close()
+		return nil, fmt.Errorf("invalid rpmdb.sqlite: missing table Packages")
+	}
+	return &sqliteStorage{db: db, blobColumn: table.ColumnIndex("blob")}, nil
+}
+
+// errReadStopped stops the scan of the Packages table of a ReadUntil whose done is closed.
+var errReadStopped = errors.New("read stopped")
+
+// ReadUntil returns the headers in hnum order until done is closed.
+func (s *sqliteStorage) ReadUntil(done <-chan struct{}) <-chan bdb.Entry {
+	entries := make(chan bdb.Entry)
+	go func() {
+		defer close(entries)
+		err := s.db.Rows("Packages", func(row sqlite.Row) error {
+			blob, err := s.blob(row)
+			if err != nil {
+				return err
+			}
+			key := make([]byte, 4)
+			binary.LittleEndian.PutUint32(key, uint32(row.Rowid))
+			select {
+			case entries <- bdb.Entry{Key: key, Value: blob, PageNo: uint32(row.Rowid)}:
+				return nil
+			case <-done:
+				return errReadStopped
+			}
+		})
+		if err != nil && err != errReadStopped {
+			select {
+			case entries <- bdb.Entry{Err: err}:
+			case <-done:
+			}
+		}
+	}()
+	return entries
+}
+
+// ReadOverflow reads the header of the hnum pageNo.
+func (s *sqliteStorage) ReadOverflow(pageNo uint32) ([]byte, error) {
+	row, ok, err := s.db.Row("Packages", int64(pageNo))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("no such package: %d", pageNo)
+	}
+	return s.blob(row)
+}
+
+func (s *sqliteStorage) blob(row sqlite.Row) ([]byte, error) {
+	blob, ok := row.Values[s.blobColumn].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("invalid blob of package %d: %T", row.Rowid, row.Values[s.blobColumn])
+	}
+	return blob, nil
+}
+
+func (s *sqliteStorage) Close() error {
+	return s.db.Close()
+}
+
+// lookupIndex is RpmDB.lookupIndex for the index table name, searched with its key index when it
+// has one. ok is false when there is no such table.
+func (s *sqliteStorage) lookupIndex(name, key string) (items []indexItem, ok bool, err error) {
+	if s.db.Table(name) == nil {
+		return nil, false, nil
+	}
+	err = s.db.Lookup(name, "key", key, func(row sqlite.Row) error {
+		item, err := s.indexItem(name, row)
+		if err != nil {
+			return err
+		}
+		items = append(items, item)
+		return nil
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read index %s: %w", name, err)
+	}
+	return items, true, nil
+}
+
+// indexKeys returns the keys of the index table name, ok being false when there is no such table.
+func (s *sqliteStorage) indexKeys(name string) (keys []string, ok bool, err error) {
+	table := s.db.Table(name)
+	if table == nil {
+		return nil, false, nil
+	}
+	column := table.ColumnIndex("key")
+	if column < 0 {
+		return nil, false, fmt.Errorf("failed to read index %s: missing column key", name)
+	}
+	err = s.db.Rows(name, func(row sqlite.Row) error {
+		key, ok := row.Values[column].(string)
+		if !ok {
+			return fmt.Errorf("invalid key: %T", row.Values[column])
+		}
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read index %s: %w", name, err)
+	}
+	return keys, true, nil
+}
+
+// forEachIndexItem calls fn with the key and item of every row of the index table name, ok being
+// false when there is no such table.
+func (s *sqliteStorage) forEachIndexItem(name string, fn func(key string, item indexItem) error) (ok bool, err error) {
+	table := s.db.Table(name)
+	if table == nil {
+		return false, nil
+	}
+	column := table.ColumnIndex("key")
+	if column < 0 {
+		return false, fmt.Errorf("failed to read index %s: missing column key", name)
+	}
+	err = s.db.Rows(name, func(row sqlite.Row) error {
+		key, ok := row.Values[column].(string)
+		if !ok {
+			return fmt.Errorf("invalid key: %T", row.Values[column])
+		}
+		item, err := s.indexItem(name, row)
+		if err != nil {
+			return err
+		}
+		return fn(key, item)
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to read index %s: %w", name, err)
+	}
+	return true, nil
+}
+
+func (s *sqliteStorage) indexItem(name string, row sqlite.Row) (indexItem, error) {
+	table := s.db.Table(name)
+	hnumColumn, idxColumn := table.ColumnIndex("hnum"), table.ColumnIndex("idx")
+	if hnumColumn < 0 || idxColumn < 0 {
+		return indexItem{}, fmt.Errorf("missing columns hnum and idx")
+	}
+	hnum, hok := row.Values[hnumColumn].(int64)
+	idx, iok := row.Values[idxColumn].(int64)
+	if !hok || !iok {
+		return indexItem{}, fmt.Errorf("invalid row %d", row.Rowid)
+	}
+	return indexItem{hdrNum: uint32(hnum), tagNum: uint32(idx)}, nil
+}
+
+// forEachCandidate calls fn with the headers of the candidates the indexes tell when indexed, with
+// every header otherwise. The headers of an rpmdb.sqlite are read by hnum, in the hnum order of a
+// full scan; the others are all read, the headers that aren't candidates being skipped.
+func (d *RpmDB) forEachCandidate(candidates map[uint32]struct{}, indexed bool, fn func(hnum uint32, blob []byte) error) error {
+	s, ok := d.db.(*sqliteStorage)
+	if !indexed || !ok || d.sorted {
+		return d.forEachHeader(func(hnum uint32, blob []byte) error {
+			if indexed {
+				if _, ok := candidates[hnum]; !ok {
+					return nil
+				}
+			}
+			return fn(hnum, blob)
+		})
+	}
+
+	hnums := make([]uint32, 0, len(candidates))
+	for hnum := range candidates {
+		hnums = append(hnums, hnum)
+	}
+	sort.Slice(hnums, func(i, j int) bool { return hnums[i] < hnums[j] })
+	fn = d.rewriteHeaders(fn)
+	for _, hnum := range hnums {
+		row, ok, err := s.db.Row("Packages", int64(hnum))
+		if err != nil {
+			return err
+		}
+		// an index row left behind by an erased package
+		if !ok {
+			continue
+		}
+		blob, err := s.blob(row)
+		if err != nil {
+			return err
+		}
+		if isPlaceholder(bdb.Entry{Value: blob}) {
+			continue
+		}
+		if err := fn(hnum, blob); err != nil {
+			return err
+		}
+	}
+	return nil
+}
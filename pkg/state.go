@@ -0,0 +1,46 @@
+package rpmdb
+
+import (
+	"fmt"
+	"sort"
+)
+
+// PackageState is a package not installed as a whole, whose files skew verification results.
+type PackageState struct {
+	Package *PackageInfo
+	// Files are the paths of the files by state, the normal ones left out: the files not installed
+	// (--excludedocs, %lang), shared over the network (%_netsharedpath), replaced by another
+	// package or of the wrong color on multilib systems.
+	Files map[FileState][]string
+}
+
+// PackageStates lists the packages having files in any other state than normal as well as the ones
+// an unfinished removal left (RemoveTID set), sorted by NEVRA.
+func (d *RpmDB) PackageStates() ([]*PackageState, error) {
+	var states []*PackageState
+	err := d.forEachPackage(func(hnum uint32, indexEntries []indexEntry, pkg *PackageInfo) error {
+		files, err := fileInfos(indexEntries)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pkg.NEVRA(), err)
+		}
+
+		state := &PackageState{Package: pkg, Files: make(map[FileState][]string)}
+		for _, file := range files {
+			if file.State != RPMFILE_STATE_NORMAL {
+				state.Files[file.State] = append(state.Files[file.State], file.Path)
+			}
+		}
+		if len(state.Files) > 0 || pkg.RemoveTID != 0 {
+			states = append(states, state)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Package.NEVRA() < states[j].Package.NEVRA()
+	})
+	return states, nil
+}
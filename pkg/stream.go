@@ -0,0 +1,59 @@
+package rpmdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// StreamOptions configures StreamPackages.
+type StreamOptions struct {
+	// Root is added to every line as "root" when not empty, telling apart the packages of the
+	// databases of a scan streamed to the same writer.
+	Root string
+	// Location is the time zone of the times of the lines, UTC when nil.
+	Location *time.Location
+}
+
+// streamedPackage is a line of StreamPackages, the fields of the package inlined.
+type streamedPackage struct {
+	Root string `json:"root,omitempty"`
+	*PackageInfo
+}
+
+// StreamPackages writes the packages as newline delimited JSON while the headers are read, a line
+// per package with the json tags of PackageInfo. Only the package being written is held in
+// memory, and every line is a single Write to w made before the next header is read: a slow w,
+// an upload to object storage for instance, slows down the reading rather than the lines piling
+// up. WithSortedOutput still buffers the keys of the headers to sort them, within WithMemoryBudget.
+//
+// The pubkey signing a package is only known once every header has been read, SignedBy only has
+// the KeyID. A write error stops the stream and is returned.
+func (d *RpmDB) StreamPackages(w io.Writer, opts *StreamOptions) error {
+	if opts == nil {
+		opts = &StreamOptions{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return d.forEachPackage(func(hnum uint32, indexEntries []indexEntry, pkg *PackageInfo) error {
+		if keyID, ok := signatureKeyID(indexEntries); ok {
+			pkg.SignedBy = &PubKeyRef{KeyID: d.intern(keyID)}
+		}
+		if opts.Location != nil {
+			pkg.InstallTime, pkg.BuildTime = inLocation(pkg.InstallTime, opts.Location), inLocation(pkg.BuildTime, opts.Location)
+		}
+		if err := encoder.Encode(streamedPackage{Root: opts.Root, PackageInfo: pkg}); err != nil {
+			return fmt.Errorf("failed to write %s: %w", pkg.NEVRA(), err)
+		}
+		return nil
+	})
+}
+
+// inLocation returns t in loc, the zero time of a missing tag staying the zero time.
+func inLocation(t time.Time, loc *time.Location) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return t.In(loc)
+}
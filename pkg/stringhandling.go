@@ -0,0 +1,122 @@
+package rpmdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// StringHandling is what reading a header does with the strings that aren't valid UTF-8 or have
+// NULs within them: the Latin-1 descriptions of old vendor packages, or junk bytes.
+type StringHandling int
+
+const (
+	// StringsRaw keeps the bytes as the header has them, the default.
+	StringsRaw StringHandling = iota
+	// StringsSanitize replaces the invalid bytes with U+FFFD and cuts the strings at their first
+	// NUL, as rpm reads them, so that every output is valid UTF-8 and JSON without control
+	// characters.
+	StringsSanitize
+	// StringsStrict fails the reading of the headers having such a string, with ErrInvalidString.
+	StringsStrict
+)
+
+// ErrInvalidString is returned with StringsStrict for the headers having a string that isn't
+// valid UTF-8 or has a NUL within it.
+var ErrInvalidString = errors.New("invalid string")
+
+// WithStringHandling applies h to the strings of every header read: ListPackagesWithTags, the file
+// lists and the other outputs all see the same strings. Sanitized headers are re-encoded, their
+// signatures don't verify anymore.
+func WithStringHandling(h StringHandling) Option {
+	return func(d *RpmDB) {
+		d.strings = h
+	}
+}
+
+// sanitizeHeader re-encodes a header blob with valid strings, the blob being returned as is when
+// its strings are valid already. With strict, an invalid string is an error instead.
+func sanitizeHeader(blob []byte, strict bool) ([]byte, error) {
+	indexEntries, err := headerImport(blob)
+	if err != nil {
+		return nil, err
+	}
+	valid := true
+	for i := range indexEntries {
+		entry := &indexEntries[i]
+		if ok, err := validStrings(entry); err != nil {
+			return nil, err
+		} else if !ok {
+			if strict {
+				return nil, fmt.Errorf("%w (tag %v)", ErrInvalidString, entry.Info.Tag)
+			}
+			valid = false
+		}
+	}
+	if valid {
+		return blob, nil
+	}
+
+	b := NewHeaderBuilder()
+	for i := range indexEntries {
+		entry := &indexEntries[i]
+		tag := entry.Info.Tag
+		if _, ok := b.entries[tag]; ok {
+			continue
+		}
+		switch entry.Info.Type {
+		case RPM_STRING_TYPE:
+			value := bytes.TrimRight(entry.Data, "\x00")
+			if i := bytes.IndexByte(value, 0); i >= 0 {
+				value = value[:i]
+			}
+			b.add(tag, entry.Info.Type, 1, append([]byte(strings.ToValidUTF8(string(value), "\uFFFD")), 0))
+			continue
+		case RPM_STRING_ARRAY_TYPE, RPM_I18NSTRING_TYPE:
+			values, err := entryStrings(entry)
+			if err != nil {
+				return nil, err
+			}
+			var data []byte
+			for _, v := range values {
+				data = append(data, strings.ToValidUTF8(v, "\uFFFD")...)
+				data = append(data, 0)
+			}
+			b.add(tag, entry.Info.Type, entry.Info.Count, data)
+			continue
+		}
+		data, err := entryData(entry)
+		if err != nil {
+			return nil, err
+		}
+		b.add(tag, entry.Info.Type, entry.Info.Count, data)
+	}
+	return b.Bytes()
+}
+
+// validStrings tells whether the strings of an entry are valid UTF-8 without NUL, true for the
+// entries of other types. The strings of arrays end at their NUL, only a single string can have
+// one within it.
+func validStrings(entry *indexEntry) (bool, error) {
+	switch entry.Info.Type {
+	case RPM_STRING_TYPE:
+		if err := checkEntry(entry); err != nil {
+			return false, err
+		}
+		value := bytes.TrimRight(entry.Data, "\x00")
+		return utf8.Valid(value) && bytes.IndexByte(value, 0) < 0, nil
+	case RPM_STRING_ARRAY_TYPE, RPM_I18NSTRING_TYPE:
+		values, err := entryStrings(entry)
+		if err != nil {
+			return false, err
+		}
+		for _, v := range values {
+			if !utf8.ValidString(v) {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
@@ -8,13 +8,13 @@ func _() {
 	// An "invalid array index" compiler error signifies that the constant values have changed.
 	// Re-run the stringer command to generate them again.
 	var x [1]struct{}
-	_ = x[HEADER_SIGBASE-256]
-	_ = x[HEADER_TAGBASE-1000]
 	_ = x[HEADER_IMAGE-61]
 	_ = x[HEADER_SIGNATURES-62]
 	_ = x[HEADER_IMMUTABLE-63]
 	_ = x[HEADER_REGIONS-64]
 	_ = x[HEADER_I18NTABLE-100]
+	_ = x[HEADER_SIGBASE-256]
+	_ = x[HEADER_TAGBASE-1000]
 	_ = x[RPMTAG_NAME-1000]
 	_ = x[RPMTAG_VERSION-1001]
 	_ = x[RPMTAG_RELEASE-1002]
@@ -65,7 +65,6 @@ func _() {
 	_ = x[RPMTAG_PROVIDENAME-1047]
 	_ = x[RPMTAG_REQUIREFLAGS-1048]
 	_ = x[RPMTAG_REQUIRENAME-1049]
-	_ = x[RPMTAG_REQUIRES-1049]
 	_ = x[RPMTAG_REQUIREVERSION-1050]
 	_ = x[RPMTAG_NOSOURCE-1051]
 	_ = x[RPMTAG_NOPATCH-1052]
@@ -301,6 +300,20 @@ func _() {
 	_ = x[RPMTAG_IDENTITY-5095]
 	_ = x[RPMTAG_MODULARITYLABEL-5096]
 	_ = x[RPMTAG_PAYLOADDIGESTALT-5097]
+	_ = x[RPMTAG_ARCHSUFFIX-5098]
+	_ = x[RPMTAG_SPEC-5099]
+	_ = x[RPMTAG_TRANSLATIONURL-5100]
+	_ = x[RPMTAG_UPSTREAMRELEASES-5101]
+	_ = x[RPMTAG_SOURCELICENSE-5102]
+	_ = x[RPMTAG_PREUNTRANS-5103]
+	_ = x[RPMTAG_POSTUNTRANS-5104]
+	_ = x[RPMTAG_PREUNTRANSPROG-5105]
+	_ = x[RPMTAG_POSTUNTRANSPROG-5106]
+	_ = x[RPMTAG_PREUNTRANSFLAGS-5107]
+	_ = x[RPMTAG_POSTUNTRANSFLAGS-5108]
+	_ = x[RPMTAG_SYSUSERS-5109]
+	_ = x[RPMTAG_BUILDSYSTEM-5110]
+	_ = x[RPMTAG_BUILDOPTION-5111]
 }
 
 const (
@@ -310,7 +323,7 @@ const (
 	_TAG_ID_name_3 = "HEADER_TAGBASERPMTAG_VERSIONRPMTAG_RELEASERPMTAG_EPOCHRPMTAG_SUMMARYRPMTAG_DESCRIPTIONRPMTAG_BUILDTIMERPMTAG_BUILDHOSTRPMTAG_INSTALLTIMERPMTAG_SIZERPMTAG_DISTRIBUTIONRPMTAG_VENDORRPMTAG_GIFRPMTAG_XPMRPMTAG_LICENSERPMTAG_PACKAGERRPMTAG_GROUPRPMTAG_CHANGELOGRPMTAG_SOURCERPMTAG_PATCHRPMTAG_URLRPMTAG_OSRPMTAG_ARCHRPMTAG_PREINRPMTAG_POSTINRPMTAG_PREUNRPMTAG_POSTUNRPMTAG_OLDFILENAMESRPMTAG_FILESIZESRPMTAG_FILESTATESRPMTAG_FILEMODESRPMTAG_FILEUIDSRPMTAG_FILEGIDSRPMTAG_FILERDEVSRPMTAG_FILEMTIMESRPMTAG_FILEDIGESTSRPMTAG_FILELINKTOSRPMTAG_FILEFLAGSRPMTAG_ROOTRPMTAG_FILEUSERNAMERPMTAG_FILEGROUPNAMERPMTAG_EXCLUDERPMTAG_EXCLUSIVERPMTAG_ICONRPMTAG_SOURCERPMRPMTAG_FILEVERIFYFLAGSRPMTAG_ARCHIVESIZERPMTAG_PROVIDENAMERPMTAG_REQUIREFLAGSRPMTAG_REQUIRENAMERPMTAG_REQUIREVERSIONRPMTAG_NOSOURCERPMTAG_NOPATCHRPMTAG_CONFLICTFLAGSRPMTAG_CONFLICTNAMERPMTAG_CONFLICTVERSIONRPMTAG_DEFAULTPREFIXRPMTAG_BUILDROOTRPMTAG_INSTALLPREFIXRPMTAG_EXCLUDEARCHRPMTAG_EXCLUDEOSRPMTAG_EXCLUSIVEARCHRPMTAG_EXCLUSIVEOSRPMTAG_AUTOREQPROVRPMTAG_RPMVERSIONRPMTAG_TRIGGERSCRIPTSRPMTAG_TRIGGERNAMERPMTAG_TRIGGERVERSIONRPMTAG_TRIGGERFLAGSRPMTAG_TRIGGERINDEX"
 	_TAG_ID_name_4 = "RPMTAG_VERIFYSCRIPTRPMTAG_CHANGELOGTIMERPMTAG_CHANGELOGNAMERPMTAG_CHANGELOGTEXTRPMTAG_BROKENMD5RPMTAG_PREREQRPMTAG_PREINPROGRPMTAG_POSTINPROGRPMTAG_PREUNPROGRPMTAG_POSTUNPROGRPMTAG_BUILDARCHSRPMTAG_OBSOLETENAMERPMTAG_VERIFYSCRIPTPROGRPMTAG_TRIGGERSCRIPTPROGRPMTAG_DOCDIRRPMTAG_COOKIERPMTAG_FILEDEVICESRPMTAG_FILEINODESRPMTAG_FILELANGSRPMTAG_PREFIXESRPMTAG_INSTPREFIXESRPMTAG_TRIGGERINRPMTAG_TRIGGERUNRPMTAG_TRIGGERPOSTUNRPMTAG_AUTOREQRPMTAG_AUTOPROVRPMTAG_CAPABILITYRPMTAG_SOURCEPACKAGERPMTAG_OLDORIGFILENAMESRPMTAG_BUILDPREREQRPMTAG_BUILDREQUIRESRPMTAG_BUILDCONFLICTSRPMTAG_BUILDMACROSRPMTAG_PROVIDEFLAGSRPMTAG_PROVIDEVERSIONRPMTAG_OBSOLETEFLAGSRPMTAG_OBSOLETEVERSIONRPMTAG_DIRINDEXESRPMTAG_BASENAMESRPMTAG_DIRNAMESRPMTAG_ORIGDIRINDEXESRPMTAG_ORIGBASENAMESRPMTAG_ORIGDIRNAMESRPMTAG_OPTFLAGSRPMTAG_DISTURLRPMTAG_PAYLOADFORMATRPMTAG_PAYLOADCOMPRESSORRPMTAG_PAYLOADFLAGSRPMTAG_INSTALLCOLORRPMTAG_INSTALLTIDRPMTAG_REMOVETIDRPMTAG_SHA1RHNRPMTAG_RHNPLATFORMRPMTAG_PLATFORMRPMTAG_PATCHESNAMERPMTAG_PATCHESFLAGSRPMTAG_PATCHESVERSIONRPMTAG_CACHECTIMERPMTAG_CACHEPKGPATHRPMTAG_CACHEPKGSIZERPMTAG_CACHEPKGMTIMERPMTAG_FILECOLORSRPMTAG_FILECLASSRPMTAG_CLASSDICTRPMTAG_FILEDEPENDSXRPMTAG_FILEDEPENDSNRPMTAG_DEPENDSDICTRPMTAG_SOURCEPKGIDRPMTAG_FILECONTEXTSRPMTAG_FSCONTEXTSRPMTAG_RECONTEXTSRPMTAG_POLICIESRPMTAG_PRETRANSRPMTAG_POSTTRANSRPMTAG_PRETRANSPROGRPMTAG_POSTTRANSPROGRPMTAG_DISTTAGRPMTAG_OLDSUGGESTSNAMERPMTAG_OLDSUGGESTSVERSIONRPMTAG_OLDSUGGESTSFLAGSRPMTAG_OLDENHANCESNAMERPMTAG_OLDENHANCESVERSIONRPMTAG_OLDENHANCESFLAGSRPMTAG_PRIORITYRPMTAG_CVSIDRPMTAG_BLINKPKGIDRPMTAG_BLINKHDRIDRPMTAG_BLINKNEVRARPMTAG_FLINKPKGIDRPMTAG_FLINKHDRIDRPMTAG_FLINKNEVRARPMTAG_PACKAGEORIGINRPMTAG_TRIGGERPREINRPMTAG_BUILDSUGGESTSRPMTAG_BUILDENHANCESRPMTAG_SCRIPTSTATESRPMTAG_SCRIPTMETRICSRPMTAG_BUILDCPUCLOCKRPMTAG_FILEDIGESTALGOSRPMTAG_VARIANTSRPMTAG_XMAJORRPMTAG_XMINORRPMTAG_REPOTAGRPMTAG_KEYWORDSRPMTAG_BUILDPLATFORMSRPMTAG_PACKAGECOLORRPMTAG_PACKAGEPREFCOLORRPMTAG_XATTRSDICTRPMTAG_FILEXATTRSXRPMTAG_DEPATTRSDICTRPMTAG_CONFLICTATTRSXRPMTAG_OBSOLETEATTRSXRPMTAG_PROVIDEATTRSXRPMTAG_REQUIREATTRSXRPMTAG_BUILDPROVIDESRPMTAG_BUILDOBSOLETESRPMTAG_DBINSTANCERPMTAG_NVRA"
 	_TAG_ID_name_5 = "RPMTAG_FILENAMESRPMTAG_FILEPROVIDERPMTAG_FILEREQUIRERPMTAG_FSNAMESRPMTAG_FSSIZESRPMTAG_TRIGGERCONDSRPMTAG_TRIGGERTYPERPMTAG_ORIGFILENAMESRPMTAG_LONGFILESIZESRPMTAG_LONGSIZERPMTAG_FILECAPSRPMTAG_FILEDIGESTALGORPMTAG_BUGURLRPMTAG_EVRRPMTAG_NVRRPMTAG_NEVRRPMTAG_NEVRARPMTAG_HEADERCOLORRPMTAG_VERBOSERPMTAG_EPOCHNUMRPMTAG_PREINFLAGSRPMTAG_POSTINFLAGSRPMTAG_PREUNFLAGSRPMTAG_POSTUNFLAGSRPMTAG_PRETRANSFLAGSRPMTAG_POSTTRANSFLAGSRPMTAG_VERIFYSCRIPTFLAGSRPMTAG_TRIGGERSCRIPTFLAGS"
-	_TAG_ID_name_6 = "RPMTAG_COLLECTIONSRPMTAG_POLICYNAMESRPMTAG_POLICYTYPESRPMTAG_POLICYTYPESINDEXESRPMTAG_POLICYFLAGSRPMTAG_VCSRPMTAG_ORDERNAMERPMTAG_ORDERVERSIONRPMTAG_ORDERFLAGSRPMTAG_MSSFMANIFESTRPMTAG_MSSFDOMAINRPMTAG_INSTFILENAMESRPMTAG_REQUIRENEVRSRPMTAG_PROVIDENEVRSRPMTAG_OBSOLETENEVRSRPMTAG_CONFLICTNEVRSRPMTAG_FILENLINKSRPMTAG_RECOMMENDNAMERPMTAG_RECOMMENDVERSIONRPMTAG_RECOMMENDFLAGSRPMTAG_SUGGESTNAMERPMTAG_SUGGESTVERSIONRPMTAG_SUGGESTFLAGSRPMTAG_SUPPLEMENTNAMERPMTAG_SUPPLEMENTVERSIONRPMTAG_SUPPLEMENTFLAGSRPMTAG_ENHANCENAMERPMTAG_ENHANCEVERSIONRPMTAG_ENHANCEFLAGSRPMTAG_RECOMMENDNEVRSRPMTAG_SUGGESTNEVRSRPMTAG_SUPPLEMENTNEVRSRPMTAG_ENHANCENEVRSRPMTAG_ENCODINGRPMTAG_FILETRIGGERINRPMTAG_FILETRIGGERUNRPMTAG_FILETRIGGERPOSTUNRPMTAG_FILETRIGGERSCRIPTSRPMTAG_FILETRIGGERSCRIPTPROGRPMTAG_FILETRIGGERSCRIPTFLAGSRPMTAG_FILETRIGGERNAMERPMTAG_FILETRIGGERINDEXRPMTAG_FILETRIGGERVERSIONRPMTAG_FILETRIGGERFLAGSRPMTAG_TRANSFILETRIGGERINRPMTAG_TRANSFILETRIGGERUNRPMTAG_TRANSFILETRIGGERPOSTUNRPMTAG_TRANSFILETRIGGERSCRIPTSRPMTAG_TRANSFILETRIGGERSCRIPTPROGRPMTAG_TRANSFILETRIGGERSCRIPTFLAGSRPMTAG_TRANSFILETRIGGERNAMERPMTAG_TRANSFILETRIGGERINDEXRPMTAG_TRANSFILETRIGGERVERSIONRPMTAG_TRANSFILETRIGGERFLAGSRPMTAG_REMOVEPATHPOSTFIXESRPMTAG_FILETRIGGERPRIORITIESRPMTAG_TRANSFILETRIGGERPRIORITIESRPMTAG_FILETRIGGERCONDSRPMTAG_FILETRIGGERTYPERPMTAG_TRANSFILETRIGGERCONDSRPMTAG_TRANSFILETRIGGERTYPERPMTAG_FILESIGNATURESRPMTAG_FILESIGNATURELENGTHRPMTAG_PAYLOADDIGESTRPMTAG_PAYLOADDIGESTALGORPMTAG_AUTOINSTALLEDRPMTAG_IDENTITYRPMTAG_MODULARITYLABELRPMTAG_PAYLOADDIGESTALT"
+	_TAG_ID_name_6 = "RPMTAG_COLLECTIONSRPMTAG_POLICYNAMESRPMTAG_POLICYTYPESRPMTAG_POLICYTYPESINDEXESRPMTAG_POLICYFLAGSRPMTAG_VCSRPMTAG_ORDERNAMERPMTAG_ORDERVERSIONRPMTAG_ORDERFLAGSRPMTAG_MSSFMANIFESTRPMTAG_MSSFDOMAINRPMTAG_INSTFILENAMESRPMTAG_REQUIRENEVRSRPMTAG_PROVIDENEVRSRPMTAG_OBSOLETENEVRSRPMTAG_CONFLICTNEVRSRPMTAG_FILENLINKSRPMTAG_RECOMMENDNAMERPMTAG_RECOMMENDVERSIONRPMTAG_RECOMMENDFLAGSRPMTAG_SUGGESTNAMERPMTAG_SUGGESTVERSIONRPMTAG_SUGGESTFLAGSRPMTAG_SUPPLEMENTNAMERPMTAG_SUPPLEMENTVERSIONRPMTAG_SUPPLEMENTFLAGSRPMTAG_ENHANCENAMERPMTAG_ENHANCEVERSIONRPMTAG_ENHANCEFLAGSRPMTAG_RECOMMENDNEVRSRPMTAG_SUGGESTNEVRSRPMTAG_SUPPLEMENTNEVRSRPMTAG_ENHANCENEVRSRPMTAG_ENCODINGRPMTAG_FILETRIGGERINRPMTAG_FILETRIGGERUNRPMTAG_FILETRIGGERPOSTUNRPMTAG_FILETRIGGERSCRIPTSRPMTAG_FILETRIGGERSCRIPTPROGRPMTAG_FILETRIGGERSCRIPTFLAGSRPMTAG_FILETRIGGERNAMERPMTAG_FILETRIGGERINDEXRPMTAG_FILETRIGGERVERSIONRPMTAG_FILETRIGGERFLAGSRPMTAG_TRANSFILETRIGGERINRPMTAG_TRANSFILETRIGGERUNRPMTAG_TRANSFILETRIGGERPOSTUNRPMTAG_TRANSFILETRIGGERSCRIPTSRPMTAG_TRANSFILETRIGGERSCRIPTPROGRPMTAG_TRANSFILETRIGGERSCRIPTFLAGSRPMTAG_TRANSFILETRIGGERNAMERPMTAG_TRANSFILETRIGGERINDEXRPMTAG_TRANSFILETRIGGERVERSIONRPMTAG_TRANSFILETRIGGERFLAGSRPMTAG_REMOVEPATHPOSTFIXESRPMTAG_FILETRIGGERPRIORITIESRPMTAG_TRANSFILETRIGGERPRIORITIESRPMTAG_FILETRIGGERCONDSRPMTAG_FILETRIGGERTYPERPMTAG_TRANSFILETRIGGERCONDSRPMTAG_TRANSFILETRIGGERTYPERPMTAG_FILESIGNATURESRPMTAG_FILESIGNATURELENGTHRPMTAG_PAYLOADDIGESTRPMTAG_PAYLOADDIGESTALGORPMTAG_AUTOINSTALLEDRPMTAG_IDENTITYRPMTAG_MODULARITYLABELRPMTAG_PAYLOADDIGESTALTRPMTAG_ARCHSUFFIXRPMTAG_SPECRPMTAG_TRANSLATIONURLRPMTAG_UPSTREAMRELEASESRPMTAG_SOURCELICENSERPMTAG_PREUNTRANSRPMTAG_POSTUNTRANSRPMTAG_PREUNTRANSPROGRPMTAG_POSTUNTRANSPROGRPMTAG_PREUNTRANSFLAGSRPMTAG_POSTUNTRANSFLAGSRPMTAG_SYSUSERSRPMTAG_BUILDSYSTEMRPMTAG_BUILDOPTION"
 )
 
 var (
@@ -318,7 +331,7 @@ var (
 	_TAG_ID_index_3 = [...]uint16{0, 14, 28, 42, 54, 68, 86, 102, 118, 136, 147, 166, 179, 189, 199, 213, 228, 240, 256, 269, 281, 291, 300, 311, 323, 336, 348, 361, 380, 396, 413, 429, 444, 459, 475, 492, 510, 528, 544, 555, 574, 594, 608, 624, 635, 651, 673, 691, 709, 728, 746, 767, 782, 796, 816, 835, 857, 877, 893, 913, 931, 947, 967, 985, 1003, 1020, 1041, 1059, 1080, 1099, 1118}
 	_TAG_ID_index_4 = [...]uint16{0, 19, 39, 59, 79, 95, 108, 124, 141, 157, 174, 191, 210, 233, 257, 270, 283, 301, 318, 334, 349, 368, 384, 400, 420, 434, 449, 466, 486, 509, 527, 547, 568, 586, 605, 626, 646, 668, 685, 701, 716, 737, 757, 776, 791, 805, 825, 849, 868, 887, 904, 920, 934, 952, 967, 985, 1004, 1025, 1042, 1061, 1080, 1100, 1117, 1133, 1149, 1168, 1187, 1205, 1223, 1242, 1259, 1276, 1291, 1306, 1322, 1341, 1361, 1375, 1397, 1422, 1445, 1467, 1492, 1515, 1530, 1542, 1559, 1576, 1593, 1610, 1627, 1644, 1664, 1683, 1703, 1723, 1742, 1762, 1782, 1804, 1819, 1832, 1845, 1859, 1874, 1895, 1914, 1937, 1954, 1972, 1991, 2012, 2033, 2053, 2073, 2093, 2114, 2131, 2142}
 	_TAG_ID_index_5 = [...]uint16{0, 16, 34, 52, 66, 80, 99, 117, 137, 157, 172, 187, 208, 221, 231, 241, 252, 264, 282, 296, 311, 328, 346, 363, 381, 401, 422, 446, 471}
-	_TAG_ID_index_6 = [...]uint16{0, 18, 36, 54, 79, 97, 107, 123, 142, 159, 178, 195, 215, 234, 253, 273, 293, 310, 330, 353, 374, 392, 413, 432, 453, 477, 499, 517, 538, 557, 578, 597, 619, 638, 653, 673, 693, 717, 742, 770, 799, 821, 844, 869, 892, 917, 942, 971, 1001, 1034, 1068, 1095, 1123, 1153, 1181, 1207, 1235, 1268, 1291, 1313, 1341, 1368, 1389, 1415, 1435, 1459, 1479, 1494, 1516, 1539}
+	_TAG_ID_index_6 = [...]uint16{0, 18, 36, 54, 79, 97, 107, 123, 142, 159, 178, 195, 215, 234, 253, 273, 293, 310, 330, 353, 374, 392, 413, 432, 453, 477, 499, 517, 538, 557, 578, 597, 619, 638, 653, 673, 693, 717, 742, 770, 799, 821, 844, 869, 892, 917, 942, 971, 1001, 1034, 1068, 1095, 1123, 1153, 1181, 1207, 1235, 1268, 1291, 1313, 1341, 1368, 1389, 1415, 1435, 1459, 1479, 1494, 1516, 1539, 1556, 1567, 1588, 1611, 1631, 1648, 1666, 1687, 1709, 1731, 1754, 1769, 1787, 1805}
 )
 
 func (i TAG_ID) String() string {
@@ -339,7 +352,7 @@ func (i TAG_ID) String() string {
 	case 5000 <= i && i <= 5027:
 		i -= 5000
 		return _TAG_ID_name_5[_TAG_ID_index_5[i]:_TAG_ID_index_5[i+1]]
-	case 5029 <= i && i <= 5097:
+	case 5029 <= i && i <= 5111:
 		i -= 5029
 		return _TAG_ID_name_6[_TAG_ID_index_6[i]:_TAG_ID_index_6[i+1]]
 	default:
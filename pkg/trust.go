@@ -0,0 +1,229 @@
+package rpmdb
+
+import (
+	"crypto"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+)
+
+// TrustLevel is how far the integrity of a header can be established from the database alone.
+type TrustLevel int
+
+const (
+	// TrustNone is a header without immutable region, imported by an old rpm or rebuilt: the tags of
+	// the package can't be told from the ones added since.
+	TrustNone TrustLevel = iota
+	// TrustRegion is a header whose immutable region holds the tags of the package as built, but
+	// whose digests are missing or don't match.
+	TrustRegion
+	// TrustDigest is a region matching the SHA256HEADER or SHA1HEADER digest of the header: it is
+	// intact, but anyone can compute a digest.
+	TrustDigest
+	// TrustSignature is a region whose RSAHEADER or DSAHEADER signature verifies with an imported
+	// key, or one of WithKeyring: the header is the one the key holder signed.
+	TrustSignature
+)
+
+var trustLevelNames = map[TrustLevel]string{
+	TrustNone:      "none",
+	TrustRegion:    "region",
+	TrustDigest:    "digest",
+	TrustSignature: "signature",
+}
+
+// String returns the name of the level.
+func (l TrustLevel) String() string {
+	if name, ok := trustLevelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("TrustLevel(%d)", int(l))
+}
+
+// PackageTrust is the trust level of the header of a package.
+type PackageTrust struct {
+	Package *PackageInfo
+	Level   TrustLevel
+	// KeyID is the issuer of the header signature, empty for unsigned packages.
+	KeyID string
+	// Reason tells why the package doesn't reach the next level, empty at TrustSignature.
+	Reason string
+}
+
+// the digests of the immutable region, strongest first
+var headerDigestTags = []struct {
+	tag  TAG_ID
+	name string
+	algo crypto.Hash
+}{
+	{RPMTAG_SHA256HEADER, "SHA256HEADER", crypto.SHA256},
+	{RPMTAG_SHA1HEADER, "SHA1HEADER", crypto.SHA1},
+}
+
+// the signature tags covering the header only, the other ones covering the payload as well
+var headerSignatureTags = []TAG_ID{RPMTAG_RSAHEADER, RPMTAG_DSAHEADER}
+
+// Trust computes the trust level of every package in database order, the signatures being checked
+// with the keys of the gpg-pubkey entries, which are left out, or the ones of WithKeyring. Each level requires the ones below
+// it but a digest: the signature of a header lacking digests is enough. Headers rewritten on read,
+// with WithRedaction or a sanitizing WithStringHandling, don't match their digests anymore.
+func (d *RpmDB) Trust() ([]*PackageTrust, error) {
+	type pendingSignature struct {
+		trust  *PackageTrust
+		sig    *pgpSignature
+		digest []byte
+	}
+	var trusts []*PackageTrust
+	var pending []pendingSignature
+	keyring := NewKeyring()
+	if d.keyring != nil {
+		keyring = d.keyring
+	}
+
+	err := d.forEachHeader(func(hnum uint32, blob []byte) error {
+		indexEntries, err := headerImport(blob)
+		if err != nil {
+			return fmt.Errorf("error during importing header: %w", err)
+		}
+		pkg, err := getNEVRA(indexEntries)
+		if err != nil {
+			return fmt.Errorf("invalid package info: %w", err)
+		}
+		if d.interner != nil {
+			d.interner.internPackage(pkg)
+		}
+
+		if pkg.Name == "gpg-pubkey" {
+			if d.keyring == nil {
+				// a key that can't be decoded verifies nothing, the packages it signs say so
+				keys, _ := headerPublicKeys(indexEntries)
+				for _, key := range keys {
+					keyring.keys[key.keyID] = key
+				}
+			}
+			return nil
+		}
+
+		trust := &PackageTrust{Package: pkg}
+		trusts = append(trusts, trust)
+		sig, digest := headerTrust(trust, blob, indexEntries)
+		if sig != nil {
+			pending = append(pending, pendingSignature{trust: trust, sig: sig, digest: digest})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the keys may be imported after the packages they sign
+	for _, p := range pending {
+		key, ok := keyring.keys[p.sig.keyID]
+		if !ok {
+			p.trust.Reason = fmt.Sprintf("key %s not imported", p.sig.keyID)
+			if d.keyring != nil {
+				p.trust.Reason = fmt.Sprintf("key %s not in keyring", p.sig.keyID)
+			}
+			continue
+		}
+		if err := p.sig.verify(key, p.digest); err != nil {
+			p.trust.Reason = fmt.Sprintf("invalid signature: %v", err)
+			continue
+		}
+		p.trust.Level, p.trust.Reason = TrustSignature, ""
+	}
+	return trusts, nil
+}
+
+// headerTrust sets the level reached by the region and digests of a header, returning its
+// signature and the digest of the signed data when the signature remains to be checked.
+func headerTrust(trust *PackageTrust, blob []byte, indexEntries []indexEntry) (*pgpSignature, []byte) {
+	if !hasRegion(blob) {
+		trust.Reason = "no immutable region"
+		return nil, nil
+	}
+	region, err := immutableRegion(blob)
+	if err != nil {
+		trust.Reason = err.Error()
+		return nil, nil
+	}
+	trust.Level, trust.Reason = TrustRegion, "no header digest"
+
+	for _, d := range headerDigestTags {
+		values, err := tagStrings(indexEntries, d.tag)
+		if err != nil || len(values) == 0 {
+			continue
+		}
+		want := values[0]
+		got, err := headerDigest(d.algo, blob)
+		if err != nil {
+			trust.Reason = err.Error()
+			return nil, nil
+		}
+		if got != want {
+			// a tampered header, its signature can't verify either
+			trust.Level, trust.Reason = TrustRegion, d.name+" mismatch"
+			return nil, nil
+		}
+		trust.Level, trust.Reason = TrustDigest, "unsigned"
+	}
+
+	for _, tag := range headerSignatureTags {
+		entry := findEntry(indexEntries, tag)
+		if entry == nil || entry.Info.Type != RPM_BIN_TYPE {
+			continue
+		}
+		data := entry.Data
+		if int(entry.Info.Count) < len(data) {
+			data = data[:entry.Info.Count]
+		}
+		sig, err := parsePGPSignature(data)
+		if err != nil {
+			trust.Reason = fmt.Sprintf("invalid signature: %v", err)
+			return nil, nil
+		}
+		trust.KeyID = sig.keyID
+		digest, err := sig.digest(headerMagic, region)
+		if err != nil {
+			trust.Reason = fmt.Sprintf("invalid signature: %v", err)
+			return nil, nil
+		}
+		return sig, digest
+	}
+	return nil, nil
+}
+
+// hasRegion tells whether a header blob starts with a region tag having a trailer, the headers for
+// which immutableRegion doesn't return the whole blob.
+func hasRegion(blob []byte) bool {
+	if len(blob) < 8+16 || binary.BigEndian.Uint32(blob) < 1 {
+		return false
+	}
+	tag := TAG_ID(binary.BigEndian.Uint32(blob[8:]))
+	if tag != HEADER_IMMUTABLE && tag != HEADER_SIGNATURES && tag != HEADER_IMAGE {
+		return false
+	}
+	return binary.BigEndian.Uint32(blob[16:]) != 0
+}
+
+// headerPublicKeys decodes the keys of a gpg-pubkey header, whose PUBKEYS tag holds the base64 of
+// its certificate.
+func headerPublicKeys(indexEntries []indexEntry) ([]*pgpPublicKey, error) {
+	certs, err := tagStrings(indexEntries, RPMTAG_PUBKEYS)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag %v: %w", RPMTAG_PUBKEYS, err)
+	}
+	var keys []*pgpPublicKey
+	for _, cert := range certs {
+		data, err := base64.StdEncoding.DecodeString(cert)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key: %w", err)
+		}
+		k, err := pgpPublicKeys(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key: %w", err)
+		}
+		keys = append(keys, k...)
+	}
+	return keys, nil
+}
@@ -0,0 +1,69 @@
+package rpmdb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DiskUsage is the space the files of a package take, by directory.
+type DiskUsage struct {
+	Package *PackageInfo
+	// Dirs are the sizes of the regular files under each directory, "/usr" or "/etc" at depth 1,
+	// "/usr/share" at depth 2. Files above the depth count for their own directory.
+	Dirs  map[string]int64
+	Total int64
+}
+
+// DiskUsage breaks down the size of every package by the directories at depth below the root,
+// the largest package first. It is computed from the headers, without walking the filesystem:
+// ghost files and files rpm didn't install count for nothing, and hard links count once per name.
+func (d *RpmDB) DiskUsage(depth int) ([]*DiskUsage, error) {
+	if depth < 1 {
+		depth = 1
+	}
+	var usages []*DiskUsage
+	err := d.forEachPackage(func(hnum uint32, indexEntries []indexEntry, pkg *PackageInfo) error {
+		files, err := fileInfos(indexEntries)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pkg.NEVRA(), err)
+		}
+
+		usage := &DiskUsage{Package: pkg, Dirs: make(map[string]int64)}
+		for _, file := range files {
+			if file.Mode&modeTypeMask != modeRegular || file.Flags&RPMFILE_GHOST != 0 {
+				continue
+			}
+			if file.State == RPMFILE_STATE_NOTINSTALLED || file.State == RPMFILE_STATE_NETSHARED {
+				continue
+			}
+			usage.Dirs[usageDir(file.Path, depth)] += file.Size
+			usage.Total += file.Size
+		}
+		usages = append(usages, usage)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(usages, func(i, j int) bool {
+		if usages[i].Total != usages[j].Total {
+			return usages[i].Total > usages[j].Total
+		}
+		return usages[i].Package.NEVRA() < usages[j].Package.NEVRA()
+	})
+	return usages, nil
+}
+
+// usageDir returns the directory at depth holding the file, "/usr/bin" for "/usr/bin/bash" at
+// depth 2 and "/" for "/foo".
+func usageDir(file string, depth int) string {
+	parts := strings.Split(strings.Trim(file, "/"), "/")
+	// the last part is the file name
+	parts = parts[:len(parts)-1]
+	if len(parts) > depth {
+		parts = parts[:depth]
+	}
+	return "/" + strings.Join(parts, "/")
+}
@@ -0,0 +1,133 @@
+package rpmdb
+
+import (
+	"strings"
+)
+
+// Vercmp compares two version (or release) strings the way rpm does, returning -1, 0 or 1. Tilde
+// sorts before anything, even the end of the string, and caret sorts after the end of the string
+// but before anything else.
+// ref. https://github.com/rpm-software-management/rpm/blob/rpm-4.16.0-release/rpmio/rpmvercmp.c#L18
+func Vercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+
+	one, two := a, b
+	for len(one) > 0 || len(two) > 0 {
+		one = strings.TrimLeftFunc(one, isVercmpSeparator)
+		two = strings.TrimLeftFunc(two, isVercmpSeparator)
+
+		// handle the tilde separator, it sorts before everything else
+		if strings.HasPrefix(one, "~") || strings.HasPrefix(two, "~") {
+			if !strings.HasPrefix(one, "~") {
+				return 1
+			}
+			if !strings.HasPrefix(two, "~") {
+				return -1
+			}
+			one, two = one[1:], two[1:]
+			continue
+		}
+
+		// handle the caret separator, it sorts after the end of the string but before anything else
+		if strings.HasPrefix(one, "^") || strings.HasPrefix(two, "^") {
+			if len(one) == 0 {
+				return -1
+			}
+			if len(two) == 0 {
+				return 1
+			}
+			if !strings.HasPrefix(one, "^") {
+				return 1
+			}
+			if !strings.HasPrefix(two, "^") {
+				return -1
+			}
+			one, two = one[1:], two[1:]
+			continue
+		}
+
+		if len(one) == 0 || len(two) == 0 {
+			break
+		}
+
+		// grab the first completely alpha or completely numeric segment of both strings
+		isNum := isDigit(rune(one[0]))
+		segmentFunc := isAlpha
+		if isNum {
+			segmentFunc = isDigit
+		}
+		seg1, rest1 := splitSegment(one, segmentFunc)
+		seg2, rest2 := splitSegment(two, segmentFunc)
+		one, two = rest1, rest2
+
+		// segments of different types: numeric is newer than alpha
+		if len(seg2) == 0 {
+			if isNum {
+				return 1
+			}
+			return -1
+		}
+
+		if isNum {
+			seg1 = strings.TrimLeft(seg1, "0")
+			seg2 = strings.TrimLeft(seg2, "0")
+
+			// whichever number has more digits wins
+			if len(seg1) > len(seg2) {
+				return 1
+			}
+			if len(seg2) > len(seg1) {
+				return -1
+			}
+		}
+
+		if cmp := strings.Compare(seg1, seg2); cmp != 0 {
+			return cmp
+		}
+	}
+
+	// whichever version still has characters left over wins
+	if len(one) == 0 && len(two) == 0 {
+		return 0
+	}
+	if len(one) == 0 {
+		return -1
+	}
+	return 1
+}
+
+// CompareEVR compares the epoch, version and release of two packages, returning -1, 0 or 1.
+func CompareEVR(a, b *PackageInfo) int {
+	if a.Epoch < b.Epoch {
+		return -1
+	}
+	if a.Epoch > b.Epoch {
+		return 1
+	}
+	if cmp := Vercmp(a.Version, b.Version); cmp != 0 {
+		return cmp
+	}
+	return Vercmp(a.Release, b.Release)
+}
+
+func splitSegment(s string, f func(rune) bool) (string, string) {
+	i := strings.IndexFunc(s, func(r rune) bool { return !f(r) })
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], s[i:]
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func isAlpha(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+func isVercmpSeparator(r rune) bool {
+	return !isDigit(r) && !isAlpha(r) && r != '~' && r != '^'
+}
@@ -0,0 +1,54 @@
+package rpmdb
+
+import (
+	"testing"
+)
+
+// ref. https://github.com/rpm-software-management/rpm/blob/rpm-4.16.0-release/tests/rpmvercmp.at
+func TestVercmp(t *testing.T) {
+	vectors := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "2.0", -1},
+		{"2.0", "1.0", 1},
+		{"2.0.1", "2.0.1", 0},
+		{"2.0", "2.0.1", -1},
+		{"2.0.1a", "2.0.1", 1},
+		{"5.5p1", "5.5p2", -1},
+		{"5.5p10", "5.5p1", 1},
+		{"10xyz", "10.1xyz", -1},
+		{"xyz10", "xyz10.1", -1},
+		{"1.0aa", "1.0a", 1},
+		{"10.0001", "10.1", 0},
+		{"10.0001", "10.0039", -1},
+		{"4.999.9", "5.0", -1},
+		{"20101121", "20101122", -1},
+		{"2_0", "2_0", 0},
+		{"2.0", "2_0", 0},
+		{"a", "b", -1},
+		{"a+", "a_", 0},
+		{"1b.fc17", "1.fc17", -1},
+		{"6.0.rc1", "6.0", 1},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0~rc1", "1.0~rc2", -1},
+		{"1.0~rc1~git123", "1.0~rc1", -1},
+		{"1.0^", "1.0", 1},
+		{"1.0^git1", "1.0", 1},
+		{"1.0^git1", "1.01", -1},
+		{"1.0^git1~pre", "1.0^git1", -1},
+		{"1.0^20160101", "1.0.1", -1},
+		{"1.0~rc1^git1", "1.0~rc1", 1},
+		{"1.0^git1", "1.0^git2", -1},
+	}
+
+	for _, v := range vectors {
+		if got := Vercmp(v.a, v.b); got != v.want {
+			t.Errorf("Vercmp(%q, %q): got %d, want %d", v.a, v.b, got, v.want)
+		}
+		if got := Vercmp(v.b, v.a); got != -v.want {
+			t.Errorf("Vercmp(%q, %q): got %d, want %d", v.b, v.a, got, -v.want)
+		}
+	}
+}
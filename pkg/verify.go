@@ -0,0 +1,541 @@
+package rpmdb
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// VerifyAttr is a set of file attributes checked by Verify.
+type VerifyAttr uint32
+
+// ref. https://github.com/rpm-software-management/rpm/blob/rpm-4.20.0-release/include/rpm/rpmfiles.h#L100
+const (
+	RPMVERIFY_NONE       VerifyAttr = 0
+	RPMVERIFY_FILEDIGEST VerifyAttr = 1 << 0
+	RPMVERIFY_FILESIZE   VerifyAttr = 1 << 1
+	RPMVERIFY_LINKTO     VerifyAttr = 1 << 2
+	RPMVERIFY_USER       VerifyAttr = 1 << 3
+	RPMVERIFY_GROUP      VerifyAttr = 1 << 4
+	RPMVERIFY_MTIME      VerifyAttr = 1 << 5
+	RPMVERIFY_MODE       VerifyAttr = 1 << 6
+	RPMVERIFY_RDEV       VerifyAttr = 1 << 7
+	RPMVERIFY_CAPS       VerifyAttr = 1 << 8
+	RPMVERIFY_ALL                   = ^RPMVERIFY_NONE
+)
+
+// the result columns of rpm -V
+var verifyColumns = []struct {
+	attr   VerifyAttr
+	letter byte
+}{
+	{RPMVERIFY_FILESIZE, 'S'},
+	{RPMVERIFY_MODE, 'M'},
+	{RPMVERIFY_FILEDIGEST, '5'},
+	{RPMVERIFY_RDEV, 'D'},
+	{RPMVERIFY_LINKTO, 'L'},
+	{RPMVERIFY_USER, 'U'},
+	{RPMVERIFY_GROUP, 'G'},
+	{RPMVERIFY_MTIME, 'T'},
+	{RPMVERIFY_CAPS, 'P'},
+}
+
+// st_mode bits
+const (
+	modeTypeMask = 0170000
+	modeSocket   = 0140000
+	modeSymlink  = 0120000
+	modeRegular  = 0100000
+	modeBlock    = 0060000
+	modeDir      = 0040000
+	modeChar     = 0020000
+	modeFIFO     = 0010000
+	modeSetuid   = 04000
+	modeSetgid   = 02000
+	modeSticky   = 01000
+)
+
+// VerifyPolicy tells Verify what to check, the zero value checks everything like rpm -V does.
+type VerifyPolicy struct {
+	// Root is the directory the packages are installed in, "/" when empty. Verifying the root of
+	// a mounted image or container doesn't need chroot.
+	Root string
+	// Skip are the attributes not checked, the --nofiledigest, --nosize, --nomtime... options of
+	// rpm -V.
+	Skip VerifyAttr
+	// SkipFlags exempts the files having any of the flags: RPMFILE_CONFIG for --noconfig,
+	// RPMFILE_GHOST for --noghost...
+	SkipFlags FileFlags
+	// OnlyFlags limits the verification to the files having one of the flags, RPMFILE_CONFIG for
+	// --configfiles. Zero checks every file.
+	OnlyFlags FileFlags
+	// Include limits the verification to the files matching one of the patterns, Exclude leaves
+	// out the files matching one of them. The patterns are path.Match patterns matching a file or
+	// any directory above it.
+	Include []string
+	Exclude []string
+	// Concurrency is the number of files verified at once, 1 when zero.
+	Concurrency int
+	// BytesPerSecond caps the rate the files are read at for their digests, all workers together,
+	// and FilesPerSecond the rate the files are checked at. Zero means unlimited.
+	BytesPerSecond int64
+	FilesPerSecond int
+}
+
+// VerifyResult is a file failing verification.
+type VerifyResult struct {
+	Package *PackageInfo
+	File    FileInfo
+	// Failed are the attributes differing from the header.
+	Failed VerifyAttr
+	// Unknown are the attributes that couldn't be checked, Err tells why.
+	Unknown VerifyAttr
+	Missing bool
+	Err     error
+}
+
+// String formats the result like rpm -V: "S.5....T.  c /etc/foo.conf".
+func (r *VerifyResult) String() string {
+	if r.Missing {
+		return "missing   " + string(fileAttrLetter(r.File.Flags)) + " " + r.File.Path
+	}
+	var buf strings.Builder
+	for _, column := range verifyColumns {
+		switch {
+		case r.Unknown&column.attr != 0:
+			buf.WriteByte('?')
+		case r.Failed&column.attr != 0:
+			buf.WriteByte(column.letter)
+		default:
+			buf.WriteByte('.')
+		}
+	}
+	buf.WriteString("  ")
+	buf.WriteByte(fileAttrLetter(r.File.Flags))
+	buf.WriteString(" ")
+	buf.WriteString(r.File.Path)
+	return buf.String()
+}
+
+func fileAttrLetter(flags FileFlags) byte {
+	switch {
+	case flags&RPMFILE_CONFIG != 0:
+		return 'c'
+	case flags&RPMFILE_DOC != 0:
+		return 'd'
+	case flags&RPMFILE_GHOST != 0:
+		return 'g'
+	case flags&RPMFILE_LICENSE != 0:
+		return 'l'
+	case flags&RPMFILE_PUBKEY != 0:
+		return 'P'
+	case flags&RPMFILE_README != 0:
+		return 'r'
+	case flags&RPMFILE_ARTIFACT != 0:
+		return 'a'
+	}
+	return ' '
+}
+
+type verifyJob struct {
+	// seq is the position of the file in database order
+	seq  int
+	pkg  *PackageInfo
+	file FileInfo
+	algo DigestAlgo
+}
+
+// Verify checks the files of the installed packages against their headers, as rpm -Va does, names
+// limiting it to the packages of these names. Only the files failing verification are returned, in
+// database order. A nil policy checks everything.
+func (d *RpmDB) Verify(policy *VerifyPolicy, names ...string) ([]*VerifyResult, error) {
+	type sequenced struct {
+		seq    int
+		result *VerifyResult
+	}
+	var mu sync.Mutex
+	var results []sequenced
+	err := d.runVerify(context.Background(), policy, names, func(seq int, result *VerifyResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		results = append(results, sequenced{seq, result})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].seq < results[j].seq
+	})
+	failed := make([]*VerifyResult, len(results))
+	for i, r := range results {
+		failed[i] = r.result
+	}
+	return failed, nil
+}
+
+func (p *VerifyPolicy) selected(file string) bool {
+	if len(p.Include) > 0 && !matchPathPatterns(p.Include, file) {
+		return false
+	}
+	return !matchPathPatterns(p.Exclude, file)
+}
+
+func matchPathPatterns(patterns []string, file string) bool {
+	for _, pattern := range patterns {
+		for dir := file; ; dir = path.Dir(dir) {
+			if ok, _ := path.Match(pattern, dir); ok {
+				return true
+			}
+			if dir == "/" || dir == "." {
+				break
+			}
+		}
+	}
+	return false
+}
+
+type verifier struct {
+	policy *VerifyPolicy
+	root   string
+
+	bytesLimiter *rateLimiter
+	filesLimiter *rateLimiter
+
+	once   sync.Once
+	users  map[int64]string
+	groups map[int64]string
+}
+
+func newVerifier(policy *VerifyPolicy) *verifier {
+	root := policy.Root
+	if root == "" {
+		root = "/"
+	}
+	return &verifier{
+		policy:       policy,
+		root:         root,
+		bytesLimiter: newRateLimiter(float64(policy.BytesPerSecond)),
+		filesLimiter: newRateLimiter(float64(policy.FilesPerSecond)),
+	}
+}
+
+// verifyFile checks a file the way rpmfilesVerify does, nil when it passes.
+// ref. https://github.com/rpm-software-management/rpm/blob/rpm-4.20.0-release/lib/verify.c#L60
+func (v *verifier) verifyFile(ctx context.Context, job verifyJob) *VerifyResult {
+	file := job.file
+	flags := file.VerifyFlags &^ v.policy.Skip
+
+	switch file.State {
+	case RPMFILE_STATE_NOTINSTALLED, RPMFILE_STATE_NETSHARED:
+		return nil
+	case RPMFILE_STATE_REPLACED, RPMFILE_STATE_WRONGCOLOR:
+		// only the existence of the file can be checked
+		flags = RPMVERIFY_NONE
+	}
+	// the content of ghost files is not in the package
+	if file.Flags&RPMFILE_GHOST != 0 {
+		flags &^= RPMVERIFY_FILEDIGEST | RPMVERIFY_FILESIZE | RPMVERIFY_MTIME | RPMVERIFY_LINKTO
+	}
+
+	result := &VerifyResult{Package: job.pkg, File: file}
+	if err := v.filesLimiter.wait(ctx, 1); err != nil {
+		result.Unknown, result.Err = flags, err
+		return result
+	}
+	name, err := v.hostPath(file.Path, false)
+	if err != nil {
+		result.Unknown, result.Err = flags, err
+		return result
+	}
+	info, err := os.Lstat(name)
+	if os.IsNotExist(err) {
+		if file.Flags&(RPMFILE_MISSINGOK|RPMFILE_GHOST) != 0 {
+			return nil
+		}
+		result.Missing = true
+		return result
+	}
+	if err != nil {
+		result.Unknown, result.Err = flags, err
+		return result
+	}
+	if uint32(file.Mode)&modeTypeMask == modeDir && info.Mode()&os.ModeSymlink != 0 {
+		info = v.directorySymlink(file.Path, info)
+	}
+
+	mode := unixMode(info.Mode())
+	switch mode & modeTypeMask {
+	case modeDir, modeFIFO, modeChar, modeBlock, modeSocket:
+		flags &^= RPMVERIFY_FILEDIGEST | RPMVERIFY_FILESIZE | RPMVERIFY_MTIME | RPMVERIFY_LINKTO | RPMVERIFY_CAPS
+	case modeSymlink:
+		flags &^= RPMVERIFY_FILEDIGEST | RPMVERIFY_FILESIZE | RPMVERIFY_MTIME | RPMVERIFY_MODE | RPMVERIFY_CAPS
+	default:
+		flags &^= RPMVERIFY_LINKTO | RPMVERIFY_RDEV
+	}
+
+	if flags&RPMVERIFY_FILEDIGEST != 0 && file.Digest != "" {
+		digest, err := v.fileDigest(ctx, name, job.algo)
+		if err != nil {
+			result.Unknown |= RPMVERIFY_FILEDIGEST
+			result.Err = err
+		} else if digest != file.Digest {
+			result.Failed |= RPMVERIFY_FILEDIGEST
+		}
+	}
+	if flags&RPMVERIFY_LINKTO != 0 {
+		linkTo, err := os.Readlink(name)
+		if err != nil {
+			result.Unknown |= RPMVERIFY_LINKTO
+			result.Err = err
+		} else if linkTo != file.LinkTo {
+			result.Failed |= RPMVERIFY_LINKTO
+		}
+	}
+	if flags&RPMVERIFY_FILESIZE != 0 && info.Size() != file.Size {
+		result.Failed |= RPMVERIFY_FILESIZE
+	}
+	if flags&RPMVERIFY_MODE != 0 && mode != uint32(file.Mode) {
+		result.Failed |= RPMVERIFY_MODE
+	}
+	if flags&RPMVERIFY_RDEV != 0 {
+		fileType := uint32(file.Mode) & modeTypeMask
+		isDevice := fileType == modeChar || fileType == modeBlock
+		if fileType != mode&modeTypeMask && (isDevice || mode&modeTypeMask == modeChar || mode&modeTypeMask == modeBlock) {
+			result.Failed |= RPMVERIFY_RDEV
+		} else if isDevice {
+			if rdev, ok := statField(info, "Rdev"); !ok {
+				result.Unknown |= RPMVERIFY_RDEV
+			} else if uint16(rdev) != file.Rdev {
+				result.Failed |= RPMVERIFY_RDEV
+			}
+		}
+	}
+	if flags&RPMVERIFY_MTIME != 0 && info.ModTime().Unix() != int64(file.MTime) {
+		result.Failed |= RPMVERIFY_MTIME
+	}
+	if flags&(RPMVERIFY_USER|RPMVERIFY_GROUP) != 0 {
+		v.once.Do(v.loadAccounts)
+		v.verifyOwner(result, info, flags&RPMVERIFY_USER, "Uid", v.users, file.Username)
+		v.verifyOwner(result, info, flags&RPMVERIFY_GROUP, "Gid", v.groups, file.Groupname)
+	}
+	// reading security.capability needs getxattr, out of reach without syscall
+	if flags&RPMVERIFY_CAPS != 0 && file.Caps != "" {
+		result.Unknown |= RPMVERIFY_CAPS
+	}
+
+	if result.Failed == 0 && result.Unknown == 0 {
+		return nil
+	}
+	return result
+}
+
+// hostPath returns where a file of the root is on the host. The symlinks of the directories above
+// it are followed within the root, as they would be in a chroot: /lib64 -> /usr/lib64 in an image
+// mounted at /mnt leads to /mnt/usr/lib64, not to the /usr/lib64 of the host. The file itself is
+// followed when followLast is set.
+func (v *verifier) hostPath(file string, followLast bool) (string, error) {
+	if v.root == "/" && !followLast {
+		return filepath.FromSlash(file), nil
+	}
+	return resolveInRoot(v.root, file, followLast)
+}
+
+// directorySymlink returns the directory a symlink owned in place of a directory leads to, when
+// owned by root or the owner of the directory, the symlink itself otherwise. rpm permits these
+// since /bin, /lib and /sbin became symlinks to /usr.
+// ref. https://github.com/rpm-software-management/rpm/blob/rpm-4.20.0-release/lib/verify.c#L103
+func (v *verifier) directorySymlink(file string, link os.FileInfo) os.FileInfo {
+	target, err := v.hostPath(file, true)
+	if err != nil {
+		return link
+	}
+	dir, err := os.Lstat(target)
+	if err != nil || !dir.IsDir() {
+		return link
+	}
+	linkUID, ok := statField(link, "Uid")
+	if !ok {
+		return dir
+	}
+	if dirUID, _ := statField(dir, "Uid"); linkUID != 0 && linkUID != dirUID {
+		return link
+	}
+	return dir
+}
+
+// maxSymlinks is the number of symlinks resolveInRoot and resolveLinks follow before giving up, the
+// Linux limit
+const maxSymlinks = 40
+
+// resolveInRoot resolves file within root, absolute symlinks starting over from root and ".."
+// never leaving it.
+func resolveInRoot(root, file string, followLast bool) (string, error) {
+	resolved := "/"
+	remaining := strings.Split(file, "/")
+	links := 0
+	for len(remaining) > 0 {
+		component := remaining[0]
+		remaining = remaining[1:]
+		switch component {
+		case "", ".":
+			continue
+		case "..":
+			resolved = path.Dir(resolved)
+			continue
+		}
+
+		next := path.Join(resolved, component)
+		if len(remaining) == 0 && !followLast {
+			resolved = next
+			break
+		}
+		host := filepath.Join(root, filepath.FromSlash(next))
+		info, err := os.Lstat(host)
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			// missing components are reported by the caller
+			resolved = next
+			continue
+		}
+
+		if links++; links > maxSymlinks {
+			return "", fmt.Errorf("too many levels of symbolic links: %s", file)
+		}
+		target, err := os.Readlink(host)
+		if err != nil {
+			return "", err
+		}
+		target = filepath.ToSlash(target)
+		if path.IsAbs(target) {
+			resolved = "/"
+		}
+		remaining = append(strings.Split(target, "/"), remaining...)
+	}
+	return filepath.Join(root, filepath.FromSlash(resolved)), nil
+}
+
+func (v *verifier) verifyOwner(result *VerifyResult, info os.FileInfo, attr VerifyAttr, field string, names map[int64]string, want string) {
+	if attr == 0 {
+		return
+	}
+	id, ok := statField(info, field)
+	if !ok {
+		result.Unknown |= attr
+		return
+	}
+	if names[id] != want {
+		result.Failed |= attr
+	}
+}
+
+// loadAccounts reads the user and group names of the root, not the ones of the host.
+func (v *verifier) loadAccounts() {
+	v.users = readAccounts(filepath.Join(v.root, "etc", "passwd"))
+	v.groups = readAccounts(filepath.Join(v.root, "etc", "group"))
+}
+
+// readAccounts maps the ids of passwd or group entries to their names, both have the name first and
+// the id third.
+func readAccounts(path string) map[int64]string {
+	names := make(map[int64]string)
+	f, err := os.Open(path)
+	if err != nil {
+		return names
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) < 3 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		id, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		if _, ok := names[id]; !ok {
+			names[id] = fields[0]
+		}
+	}
+	return names
+}
+
+// statField reads an integer field of the system stat structure. It goes through reflection so the
+// package doesn't import syscall, see purity_test.go, and reports false on systems without it.
+func statField(info os.FileInfo, name string) (int64, bool) {
+	sys := reflect.ValueOf(info.Sys())
+	if sys.Kind() == reflect.Ptr {
+		sys = sys.Elem()
+	}
+	if sys.Kind() != reflect.Struct {
+		return 0, false
+	}
+	field := sys.FieldByName(name)
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return field.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(field.Uint()), true
+	}
+	return 0, false
+}
+
+// unixMode converts an os.FileMode back to st_mode.
+func unixMode(m os.FileMode) uint32 {
+	mode := uint32(m.Perm())
+	switch {
+	case m&os.ModeDir != 0:
+		mode |= modeDir
+	case m&os.ModeSymlink != 0:
+		mode |= modeSymlink
+	case m&os.ModeNamedPipe != 0:
+		mode |= modeFIFO
+	case m&os.ModeSocket != 0:
+		mode |= modeSocket
+	case m&os.ModeCharDevice != 0:
+		mode |= modeChar
+	case m&os.ModeDevice != 0:
+		mode |= modeBlock
+	default:
+		mode |= modeRegular
+	}
+	if m&os.ModeSetuid != 0 {
+		mode |= modeSetuid
+	}
+	if m&os.ModeSetgid != 0 {
+		mode |= modeSetgid
+	}
+	if m&os.ModeSticky != 0 {
+		mode |= modeSticky
+	}
+	return mode
+}
+
+func (v *verifier) fileDigest(ctx context.Context, name string, algo DigestAlgo) (string, error) {
+	h, err := NewDigest(algo)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	r := &rateLimitedReader{ctx: ctx, r: f, limiter: v.bytesLimiter}
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
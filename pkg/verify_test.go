@@ -0,0 +1,745 @@
+package rpmdb
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chennqqi/go-rpmdb/pkg/bdb"
+)
+
+// createTestDB writes a Packages database holding the given headers. The headers must not fit a page:
+// rpmdb headers are always stored off-page and the reader skips on-page values.
+func createTestDB(t *testing.T, dir string, headers ...*HeaderBuilder) string {
+	dbPath := filepath.Join(dir, "Packages")
+	w, err := bdb.Create(dbPath, 4096)
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	for i, b := range headers {
+		blob, err := b.Bytes()
+		if err != nil {
+			t.Fatalf("Bytes() error: %v", err)
+		}
+		key := make([]byte, 4)
+		binary.LittleEndian.PutUint32(key, uint32(i+1))
+		if err := w.Put(key, blob); err != nil {
+			t.Fatalf("Put() error: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+	return dbPath
+}
+
+type testFile struct {
+	path    string
+	mode    uint16
+	content string
+	linkTo  string
+	flags   FileFlags
+}
+
+// verifyTestHeader builds the header of a package owning the files, as installed by root.
+func verifyTestHeader(files []testFile, mtime uint32) *HeaderBuilder {
+	b := NewHeaderBuilder()
+	b.AddString(RPMTAG_NAME, "foo")
+	b.AddString(RPMTAG_VERSION, "1.0")
+	b.AddString(RPMTAG_RELEASE, "1")
+	b.AddString(RPMTAG_ARCH, "x86_64")
+	b.AddString(RPMTAG_DESCRIPTION, strings.Repeat("foo ", 1024))
+	b.AddInt32(RPMTAG_FILEDIGESTALGO, 8)
+
+	var names, digests, linkTos, users []string
+	var sizes, mtimes, flags []uint32
+	var modes, rdevs []uint16
+	for _, f := range files {
+		names = append(names, f.path)
+		digest := ""
+		if f.mode&modeTypeMask == modeRegular {
+			sum := sha256.Sum256([]byte(f.content))
+			digest = hex.EncodeToString(sum[:])
+		}
+		digests = append(digests, digest)
+		linkTos = append(linkTos, f.linkTo)
+		users = append(users, "root")
+		sizes = append(sizes, uint32(len(f.content)))
+		mtimes = append(mtimes, mtime)
+		flags = append(flags, uint32(f.flags))
+		modes = append(modes, f.mode)
+		rdevs = append(rdevs, 0)
+	}
+	b.AddStringArray(RPMTAG_OLDFILENAMES, names)
+	b.AddStringArray(RPMTAG_FILEDIGESTS, digests)
+	b.AddStringArray(RPMTAG_FILELINKTOS, linkTos)
+	b.AddStringArray(RPMTAG_FILEUSERNAME, users)
+	b.AddStringArray(RPMTAG_FILEGROUPNAME, users)
+	b.AddInt32(RPMTAG_FILESIZES, sizes...)
+	b.AddInt32(RPMTAG_FILEMTIMES, mtimes...)
+	b.AddInt32(RPMTAG_FILEFLAGS, flags...)
+	b.AddInt16(RPMTAG_FILEMODES, modes...)
+	b.AddInt16(RPMTAG_FILERDEVS, rdevs...)
+	return b
+}
+
+func TestVerify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmdb-verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := []testFile{
+		{path: "/usr/bin", mode: modeDir | 0755},
+		{path: "/usr/bin/foo", mode: modeRegular | 0755, content: "foo\n"},
+		{path: "/usr/bin/bar", mode: modeRegular | 0755, content: "bar\n"},
+		{path: "/usr/lib/libfoo.so", mode: modeSymlink | 0777, linkTo: "libfoo.so.1"},
+		{path: "/etc/foo.conf", mode: modeRegular | 0644, content: "a = 1\n", flags: RPMFILE_CONFIG | RPMFILE_NOREPLACE},
+		{path: "/usr/share/doc/foo/README", mode: modeRegular | 0644, content: "read me\n", flags: RPMFILE_DOC},
+		{path: "/var/log/foo.log", mode: modeRegular | 0600, flags: RPMFILE_GHOST},
+	}
+	mtime := time.Unix(1500000000, 0)
+	dbPath := createTestDB(t, dir, verifyTestHeader(files, uint32(mtime.Unix())))
+
+	root := filepath.Join(dir, "root")
+	for _, f := range files[:5] {
+		name := filepath.Join(root, filepath.FromSlash(f.path))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		switch f.mode & modeTypeMask {
+		case modeDir:
+			err = os.MkdirAll(name, os.FileMode(f.mode&0777))
+		case modeSymlink:
+			err = os.Symlink(f.linkTo, name)
+		default:
+			content := f.content
+			if f.path == "/etc/foo.conf" {
+				content = "a = 2 # edited\n"
+			}
+			err = ioutil.WriteFile(name, []byte(content), os.FileMode(f.mode&0777))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(filepath.Join(root, "usr", "bin", "bar"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files[:5] {
+		if f.mode&modeTypeMask == modeRegular {
+			if err := os.Chtimes(filepath.Join(root, filepath.FromSlash(f.path)), mtime, mtime); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// the files belong to whoever runs the test, name them as the header does
+	if err := os.MkdirAll(filepath.Join(root, "etc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	passwd := fmt.Sprintf("root:x:%d:%d:root:/root:/bin/bash\n", os.Getuid(), os.Getgid())
+	group := fmt.Sprintf("root:x:%d:\n", os.Getgid())
+	if err := ioutil.WriteFile(filepath.Join(root, "etc", "passwd"), []byte(passwd), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "etc", "group"), []byte(group), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	vectors := []struct {
+		name   string
+		policy VerifyPolicy
+		want   []string
+	}{
+		{
+			name:   "everything",
+			policy: VerifyPolicy{Root: root},
+			want: []string{
+				".M.......    /usr/bin/bar",
+				"S.5......  c /etc/foo.conf",
+				"missing   d /usr/share/doc/foo/README",
+			},
+		},
+		{
+			name:   "no digest nor size",
+			policy: VerifyPolicy{Root: root, Skip: RPMVERIFY_FILEDIGEST | RPMVERIFY_FILESIZE, Concurrency: 4},
+			want: []string{
+				".M.......    /usr/bin/bar",
+				"missing   d /usr/share/doc/foo/README",
+			},
+		},
+		{
+			name:   "no config nor doc",
+			policy: VerifyPolicy{Root: root, SkipFlags: RPMFILE_CONFIG | RPMFILE_DOC},
+			want: []string{
+				".M.......    /usr/bin/bar",
+			},
+		},
+		{
+			name:   "include and exclude",
+			policy: VerifyPolicy{Root: root, Include: []string{"/usr", "/etc/*.conf"}, Exclude: []string{"/usr/share/doc"}},
+			want: []string{
+				".M.......    /usr/bin/bar",
+				"S.5......  c /etc/foo.conf",
+			},
+		},
+		{
+			name:   "config files only",
+			policy: VerifyPolicy{Root: root, OnlyFlags: RPMFILE_CONFIG},
+			want: []string{
+				"S.5......  c /etc/foo.conf",
+			},
+		},
+	}
+	for _, v := range vectors {
+		t.Run(v.name, func(t *testing.T) {
+			results, err := db.Verify(&v.policy)
+			if err != nil {
+				t.Fatalf("Verify() error: %v", err)
+			}
+			var got []string
+			for _, result := range results {
+				if result.Package.Name != "foo" {
+					t.Errorf("package: got %s, want foo", result.Package.Name)
+				}
+				got = append(got, result.String())
+			}
+			if !reflect.DeepEqual(got, v.want) {
+				t.Errorf("got %q, want %q", got, v.want)
+			}
+		})
+	}
+
+	configs, err := db.ConfigFiles()
+	if err != nil {
+		t.Fatalf("ConfigFiles() error: %v", err)
+	}
+	if len(configs) != 1 || configs[0].File.Path != "/etc/foo.conf" || !configs[0].NoReplace || configs[0].Algo != PGPHASHALGO_SHA256 {
+		t.Errorf("ConfigFiles(): got %+v", configs)
+	}
+	modified, err := db.ModifiedConfigFiles(&VerifyPolicy{Root: root, SkipFlags: RPMFILE_DOC})
+	if err != nil {
+		t.Fatalf("ModifiedConfigFiles() error: %v", err)
+	}
+	if len(modified) != 1 || modified[0].String() != "S.5......  c /etc/foo.conf" {
+		t.Errorf("ModifiedConfigFiles(): got %v", modified)
+	}
+
+	results, err := db.Verify(&VerifyPolicy{Root: root}, "bar")
+	if err != nil {
+		t.Fatalf("Verify() error: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Verify() of another package: got %d results", len(results))
+	}
+}
+
+func TestVerifyRelocated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmdb-verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := []testFile{
+		{path: "/usr/bin/foo", mode: modeRegular | 0755, content: "foo\n"},
+		{path: "/lib", mode: modeDir | 0755},
+		{path: "/lib/libfoo.so.1", mode: modeRegular | 0755, content: "libfoo\n"},
+	}
+	mtime := time.Unix(1500000000, 0)
+
+	// rpm 3 kept the file list and recorded where the prefix went
+	legacy := verifyTestHeader(files, uint32(mtime.Unix()))
+	legacy.AddStringArray(RPMTAG_PREFIXES, []string{"/usr"})
+	legacy.AddStringArray(RPMTAG_INSTPREFIXES, []string{"/opt/foo"})
+
+	// rpm 4 rewrites the file list, the compressed one taking precedence over OLDFILENAMES
+	relocated := verifyTestHeader(files, uint32(mtime.Unix()))
+	relocated.AddStringArray(RPMTAG_PREFIXES, []string{"/usr"})
+	relocated.AddStringArray(RPMTAG_INSTPREFIXES, []string{"/opt/foo/"})
+	relocated.AddStringArray(RPMTAG_BASENAMES, []string{"foo", "lib", "libfoo.so.1"})
+	relocated.AddStringArray(RPMTAG_DIRNAMES, []string{"/opt/foo/bin/", "/", "/lib/"})
+	relocated.AddInt32(RPMTAG_DIRINDEXES, 0, 1, 2)
+	relocated.AddStringArray(RPMTAG_ORIGBASENAMES, []string{"foo", "lib", "libfoo.so.1"})
+	relocated.AddStringArray(RPMTAG_ORIGDIRNAMES, []string{"/usr/bin/", "/", "/lib/"})
+	relocated.AddInt32(RPMTAG_ORIGDIRINDEXES, 0, 1, 2)
+
+	for _, b := range []*HeaderBuilder{legacy, relocated} {
+		blob, err := b.Bytes()
+		if err != nil {
+			t.Fatalf("Bytes() error: %v", err)
+		}
+		indexEntries, err := headerImport(blob)
+		if err != nil {
+			t.Fatalf("headerImport() error: %v", err)
+		}
+		infos, err := fileInfos(indexEntries)
+		if err != nil {
+			t.Fatalf("fileInfos() error: %v", err)
+		}
+		if infos[0].Path != "/opt/foo/bin/foo" || infos[0].OrigPath != "/usr/bin/foo" {
+			t.Errorf("relocation: got %s from %s, want /opt/foo/bin/foo from /usr/bin/foo", infos[0].Path, infos[0].OrigPath)
+		}
+		if infos[2].Path != "/lib/libfoo.so.1" || infos[2].OrigPath != "/lib/libfoo.so.1" {
+			t.Errorf("relocation: got %s from %s, want /lib/libfoo.so.1 unchanged", infos[2].Path, infos[2].OrigPath)
+		}
+	}
+	dbPath := createTestDB(t, dir, legacy, relocated)
+
+	// a merged /usr with an absolute /lib symlink, which must not lead to the /usr/lib of the host
+	root := filepath.Join(dir, "root")
+	for _, d := range []string{"opt/foo/bin", "usr/lib"} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.FromSlash(d)), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("/usr/lib", filepath.Join(root, "lib")); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"opt/foo/bin/foo": "foo\n", "usr/lib/libfoo.so.1": "libfoo\n"} {
+		name = filepath.Join(root, filepath.FromSlash(name))
+		if err := ioutil.WriteFile(name, []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(name, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	results, err := db.Verify(&VerifyPolicy{Root: root, Skip: RPMVERIFY_USER | RPMVERIFY_GROUP})
+	if err != nil {
+		t.Fatalf("Verify() error: %v", err)
+	}
+	for _, result := range results {
+		t.Errorf("unexpected result: %s (%v)", result, result.Err)
+	}
+
+	resolved, err := resolveInRoot(root, "/lib/../lib/libfoo.so.1", false)
+	if err != nil {
+		t.Fatalf("resolveInRoot() error: %v", err)
+	}
+	if want := filepath.Join(root, "usr", "lib", "libfoo.so.1"); resolved != want {
+		t.Errorf("resolveInRoot(): got %s, want %s", resolved, want)
+	}
+}
+
+type countingHash struct {
+	hash.Hash
+	writes *int
+}
+
+func (h countingHash) Write(p []byte) (int, error) {
+	*h.writes++
+	return h.Hash.Write(p)
+}
+
+func TestDigestAlgoRegistry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmdb-verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := []testFile{{path: "/usr/bin/foo", mode: modeRegular | 0755, content: "foo\n"}}
+	dbPath := createTestDB(t, dir, verifyTestHeader(files, 0))
+	root := filepath.Join(dir, "root")
+	if err := os.MkdirAll(filepath.Join(root, "usr", "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "usr", "bin", "foo"), []byte("foo\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+	policy := &VerifyPolicy{Root: root, Skip: RPMVERIFY_ALL &^ RPMVERIFY_FILEDIGEST}
+	defer RegisterDigestAlgo(PGPHASHALGO_SHA256, "sha256", sha256.New)
+
+	// an unregistered algorithm leaves the digest unchecked
+	UnregisterDigestAlgo(PGPHASHALGO_SHA256)
+	results, err := db.Verify(policy)
+	if err != nil {
+		t.Fatalf("Verify() error: %v", err)
+	}
+	if len(results) != 1 || results[0].String() != "..?......    /usr/bin/foo" || results[0].Err == nil {
+		t.Errorf("unregistered algorithm: got %v", results)
+	}
+	if PGPHASHALGO_SHA256.String() != "sha256" || DigestAlgo(42).String() != "pgphashalgo(42)" {
+		t.Errorf("String(): got %s, %s", PGPHASHALGO_SHA256, DigestAlgo(42))
+	}
+
+	// a replacement implementation is used
+	var writes int
+	RegisterDigestAlgo(PGPHASHALGO_SHA256, "sha256-counted", func() hash.Hash {
+		return countingHash{Hash: sha256.New(), writes: &writes}
+	})
+	results, err = db.Verify(policy)
+	if err != nil {
+		t.Fatalf("Verify() error: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("registered algorithm: got %v", results)
+	}
+	if writes == 0 {
+		t.Errorf("registered algorithm not used")
+	}
+	if PGPHASHALGO_SHA256.String() != "sha256-counted" {
+		t.Errorf("String(): got %s, want sha256-counted", PGPHASHALGO_SHA256)
+	}
+}
+
+func TestVerifyAsync(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmdb-verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var files []testFile
+	for i := 0; i < 8; i++ {
+		files = append(files, testFile{path: fmt.Sprintf("/usr/share/foo/%d", i), mode: modeRegular | 0644, content: strings.Repeat("x", 1024)})
+	}
+	dbPath := createTestDB(t, dir, verifyTestHeader(files, 0))
+	root := filepath.Join(dir, "root")
+	if err := os.MkdirAll(filepath.Join(root, "usr", "share", "foo"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for i, f := range files {
+		content := f.content
+		if i%2 == 1 {
+			content = strings.Repeat("y", 1024)
+		}
+		if err := ioutil.WriteFile(filepath.Join(root, filepath.FromSlash(f.path)), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+	policy := &VerifyPolicy{Root: root, Skip: RPMVERIFY_ALL &^ RPMVERIFY_FILEDIGEST, Concurrency: 4}
+
+	stream := db.VerifyAsync(context.Background(), policy)
+	got := make(map[string]bool)
+	for result := range stream.Results {
+		got[result.String()] = true
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("VerifyAsync() error: %v", err)
+	}
+	want := map[string]bool{
+		"..5......    /usr/share/foo/1": true,
+		"..5......    /usr/share/foo/3": true,
+		"..5......    /usr/share/foo/5": true,
+		"..5......    /usr/share/foo/7": true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("VerifyAsync(): got %v, want %v", got, want)
+	}
+
+	// 8 files at 40 files per second, the first one immediately
+	policy.FilesPerSecond = 40
+	start := time.Now()
+	results, err := db.Verify(policy)
+	if err != nil {
+		t.Fatalf("Verify() error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("FilesPerSecond not honored: 8 files in %v", elapsed)
+	}
+	if len(results) != 4 || results[0].File.Path != "/usr/share/foo/1" || results[3].File.Path != "/usr/share/foo/7" {
+		t.Errorf("Verify(): got %v, want the odd files in database order", results)
+	}
+
+	// 8KB at 16KB per second
+	policy.FilesPerSecond, policy.BytesPerSecond = 0, 16*1024
+	start = time.Now()
+	if _, err := db.Verify(policy); err != nil {
+		t.Fatalf("Verify() error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 350*time.Millisecond {
+		t.Errorf("BytesPerSecond not honored: 8KB in %v", elapsed)
+	}
+
+	// canceling stops the workers
+	policy.BytesPerSecond = 1024
+	ctx, cancel := context.WithCancel(context.Background())
+	stream = db.VerifyAsync(ctx, policy)
+	cancel()
+	for range stream.Results {
+	}
+	if err := stream.Err(); err != context.Canceled {
+		t.Errorf("canceled VerifyAsync(): got %v, want %v", err, context.Canceled)
+	}
+}
+
+// checkGoroutines fails when fn leaves goroutines running, given a moment to exit.
+func checkGoroutines(t *testing.T, fn func()) {
+	t.Helper()
+	before := runtime.NumGoroutine()
+	fn()
+	after := runtime.NumGoroutine()
+	for i := 0; i < 100 && after > before; i++ {
+		time.Sleep(10 * time.Millisecond)
+		after = runtime.NumGoroutine()
+	}
+	if after > before {
+		t.Errorf("%d goroutines left running", after-before)
+	}
+}
+
+func TestVerifyAsyncCancelStopsReading(t *testing.T) {
+	for _, path := range []string{"testdata/centos7-plain/Packages", "testdata/sqlite-centos7/rpmdb.sqlite"} {
+		db, err := Open(path)
+		if err != nil {
+			t.Fatalf("Open() error: %v", err)
+		}
+		checkGoroutines(t, func() {
+			for i := 0; i < 20; i++ {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				stream := db.VerifyAsync(ctx, &VerifyPolicy{Root: "/nonexistent"})
+				for range stream.Results {
+				}
+				if err := stream.Err(); err != context.Canceled {
+					t.Errorf("%s: VerifyAsync() got %v, want %v", path, err, context.Canceled)
+				}
+			}
+		})
+		db.Close()
+	}
+}
+
+func TestLicenseFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmdb-license")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := []testFile{
+		{path: "/usr/bin/foo", mode: modeRegular | 0755, content: "foo\n"},
+		{path: "/usr/share/licenses/foo/LICENSE", mode: modeRegular | 0644, content: "MIT\n", flags: RPMFILE_LICENSE},
+		{path: "/usr/share/licenses/foo/COPYING", mode: modeRegular | 0644, content: "GPL\n", flags: RPMFILE_LICENSE | RPMFILE_DOC},
+		{path: "/usr/share/doc/foo/README", mode: modeRegular | 0644, content: "foo\n", flags: RPMFILE_DOC},
+	}
+	dbPath := createTestDB(t, dir, verifyTestHeader(files, 0))
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	licenses, err := db.LicenseFiles()
+	if err != nil {
+		t.Fatalf("LicenseFiles() error: %v", err)
+	}
+	var paths []string
+	for _, license := range licenses {
+		paths = append(paths, license.File.Path)
+	}
+	if want := []string{"/usr/share/licenses/foo/COPYING", "/usr/share/licenses/foo/LICENSE"}; !reflect.DeepEqual(paths, want) {
+		t.Fatalf("license files: got %v, want %v", paths, want)
+	}
+
+	// in a root where the licenses are a symlink to the doc directory
+	root := filepath.Join(dir, "root")
+	if err := os.MkdirAll(filepath.Join(root, "usr", "share", "doc", "foo"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/usr/share/doc", filepath.Join(root, "usr", "share", "licenses")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "usr", "share", "doc", "foo", "LICENSE"), []byte("MIT\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	text, err := licenses[1].Read(root)
+	if err != nil || string(text) != "MIT\n" {
+		t.Errorf("Read(): got %q, %v, want MIT", text, err)
+	}
+	if _, err := licenses[0].Read(root); !os.IsNotExist(err) {
+		t.Errorf("Read() of a removed file: got %v, want not exist", err)
+	}
+}
+
+func TestMultilibFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmdb-color")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	twin := func(arch string, color FileColor, state FileState) *HeaderBuilder {
+		b := NewHeaderBuilder()
+		b.AddString(RPMTAG_NAME, "foo")
+		b.AddString(RPMTAG_VERSION, "1.0")
+		b.AddString(RPMTAG_RELEASE, "1")
+		b.AddString(RPMTAG_ARCH, arch)
+		b.AddString(RPMTAG_DESCRIPTION, strings.Repeat("foo ", 1024))
+		b.AddInt32(RPMTAG_INSTALLCOLOR, uint32(RPMFC_ELF32|RPMFC_ELF64))
+		b.AddStringArray(RPMTAG_OLDFILENAMES, []string{"/usr/bin/foo", "/usr/share/doc/foo/README"})
+		b.AddInt32(RPMTAG_FILECOLORS, uint32(color), uint32(RPMFC_BLACK))
+		b.add(RPMTAG_FILESTATES, RPM_CHAR_TYPE, 2, []byte{byte(state), byte(RPMFILE_STATE_NORMAL)})
+		return b
+	}
+	// the i686 twin was installed last, its binary lost to the one of x86_64
+	dbPath := createTestDB(t, dir,
+		twin("x86_64", RPMFC_ELF64, RPMFILE_STATE_NORMAL),
+		twin("i686", RPMFC_ELF32, RPMFILE_STATE_WRONGCOLOR))
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	pkgList, err := db.ListPackages()
+	if err != nil {
+		t.Fatalf("ListPackages() error: %v", err)
+	}
+	for i, want := range []FileColor{RPMFC_ELF64, RPMFC_ELF32} {
+		if pkgList[i].Color != want || pkgList[i].InstallColor != RPMFC_ELF32|RPMFC_ELF64 {
+			t.Errorf("%s: got color %d, install color %d", pkgList[i].NEVRA(), pkgList[i].Color, pkgList[i].InstallColor)
+		}
+	}
+
+	multilib, err := db.MultilibFiles()
+	if err != nil {
+		t.Fatalf("MultilibFiles() error: %v", err)
+	}
+	if len(multilib) != 1 || multilib[0].Path != "/usr/bin/foo" || len(multilib[0].Files) != 2 {
+		t.Fatalf("MultilibFiles() got %+v", multilib)
+	}
+	if got := multilib[0].Owner.NEVRA(); got != "foo-1.0-1.x86_64" {
+		t.Errorf("owner: got %s", got)
+	}
+	if f := multilib[0].Files[0]; f.Package.Arch != "i686" || f.Color != RPMFC_ELF32 || f.State != RPMFILE_STATE_WRONGCOLOR {
+		t.Errorf("i686 file: got %+v", f)
+	}
+
+	// without states the preferred color wins
+	if owner := colorOwner([]ColoredFile{{Package: pkgList[1], Color: RPMFC_ELF32}, {Package: pkgList[0], Color: RPMFC_ELF64}}); owner != pkgList[0] {
+		t.Errorf("colorOwner() got %s", owner.NEVRA())
+	}
+}
+
+// testELF returns an ELF64 file with the GNU build-id note id in a PT_NOTE program header.
+func testELF(id []byte) []byte {
+	note := make([]byte, 16, 16+len(id))
+	binary.LittleEndian.PutUint32(note, 4)
+	binary.LittleEndian.PutUint32(note[4:], uint32(len(id)))
+	binary.LittleEndian.PutUint32(note[8:], 3)
+	copy(note[12:], "GNU\x00")
+	note = append(note, id...)
+
+	const ehdrSize, phdrSize = 64, 56
+	file := make([]byte, ehdrSize+phdrSize)
+	copy(file, "\x7fELF\x02\x01\x01")
+	binary.LittleEndian.PutUint16(file[16:], 2)  // ET_EXEC
+	binary.LittleEndian.PutUint16(file[18:], 62) // EM_X86_64
+	binary.LittleEndian.PutUint32(file[20:], 1)
+	binary.LittleEndian.PutUint64(file[32:], ehdrSize)
+	binary.LittleEndian.PutUint16(file[52:], ehdrSize)
+	binary.LittleEndian.PutUint16(file[54:], phdrSize)
+	binary.LittleEndian.PutUint16(file[56:], 1)
+	binary.LittleEndian.PutUint16(file[58:], 64)
+	phdr := file[ehdrSize:]
+	binary.LittleEndian.PutUint32(phdr, 4) // PT_NOTE
+	binary.LittleEndian.PutUint64(phdr[8:], ehdrSize+phdrSize)
+	binary.LittleEndian.PutUint64(phdr[32:], uint64(len(note)))
+	binary.LittleEndian.PutUint64(phdr[40:], uint64(len(note)))
+	return append(file, note...)
+}
+
+func TestBuildIDs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpmdb-buildid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fooID, barID := strings.Repeat("ab", 20), strings.Repeat("cd", 20)
+	fooELF, _ := hex.DecodeString(fooID)
+	barELF, _ := hex.DecodeString(barID)
+	files := []testFile{
+		{path: "/usr/bin/foo", mode: modeRegular | 0755, content: string(testELF(fooELF))},
+		{path: "/usr/bin/bar", mode: modeRegular | 0755, content: string(testELF(barELF))},
+		{path: "/usr/bin/foo.sh", mode: modeRegular | 0755, content: "#!/bin/sh\n"},
+		{path: "/usr/lib/.build-id/ab/" + fooID[2:], mode: modeSymlink | 0777, linkTo: "../../../../usr/bin/foo"},
+		{path: "/usr/lib/.build-id/ab/" + fooID[2:] + ".debug", mode: modeSymlink | 0777, linkTo: "../../debug/usr/bin/foo.debug"},
+	}
+	dbPath := createTestDB(t, dir, verifyTestHeader(files, 0))
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	defer db.Close()
+
+	// the packaged links are enough without the files
+	ids, err := db.BuildIDs(nil)
+	if err != nil {
+		t.Fatalf("BuildIDs() error: %v", err)
+	}
+	if len(ids) != 1 || len(ids[fooID]) != 1 || ids[fooID][0].Path != "/usr/bin/foo" {
+		t.Fatalf("BuildIDs() of the links got %+v", ids)
+	}
+
+	root := filepath.Join(dir, "root")
+	if err := os.MkdirAll(filepath.Join(root, "usr", "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files[:3] {
+		if err := ioutil.WriteFile(filepath.Join(root, f.path), []byte(f.content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ids, err = db.BuildIDs(&BuildIDOptions{Root: root})
+	if err != nil {
+		t.Fatalf("BuildIDs() error: %v", err)
+	}
+	if len(ids) != 2 || len(ids[fooID]) != 1 || len(ids[barID]) != 1 || ids[barID][0].Path != "/usr/bin/bar" || ids[barID][0].Package.Name != "foo" {
+		t.Errorf("BuildIDs() of the root got %+v", ids)
+	}
+
+	// a rebuilt binary has another build-id, not the one of the package
+	if err := ioutil.WriteFile(filepath.Join(root, "usr", "bin", "bar"), testELF([]byte{1, 2, 3, 4}), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for verify, want := range map[bool]int{false: 2, true: 1} {
+		ids, err := db.BuildIDs(&BuildIDOptions{Root: root, VerifyDigests: verify})
+		if err != nil {
+			t.Fatalf("BuildIDs() error: %v", err)
+		}
+		if _, ok := ids["01020304"]; ok == verify || len(ids) != want {
+			t.Errorf("BuildIDs(VerifyDigests: %v) got %+v", verify, ids)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package rpmdb
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// VerifyStream is a verification started by VerifyAsync.
+type VerifyStream struct {
+	// Results receives the files failing verification as the workers check them, in no particular
+	// order. It is closed once the verification ends.
+	Results <-chan *VerifyResult
+
+	done chan struct{}
+	err  error
+}
+
+// Err returns the error that ended the verification, nil when every file was checked. It blocks
+// until Results is closed, Results must be drained or the context canceled first.
+func (s *VerifyStream) Err() error {
+	<-s.done
+	return s.err
+}
+
+// VerifyAsync verifies like Verify, streaming the failing files instead of collecting them: the
+// files of a -Va run are checked while the database is read, with policy.Concurrency workers and
+// the rates of the policy. Canceling ctx stops the verification.
+func (d *RpmDB) VerifyAsync(ctx context.Context, policy *VerifyPolicy, names ...string) *VerifyStream {
+	results := make(chan *VerifyResult)
+	stream := &VerifyStream{Results: results, done: make(chan struct{})}
+	go func() {
+		defer close(stream.done)
+		stream.err = d.runVerify(ctx, policy, names, func(seq int, result *VerifyResult) {
+			select {
+			case results <- result:
+			case <-ctx.Done():
+			}
+		})
+		close(results)
+	}()
+	return stream
+}
+
+// runVerify feeds the files of the packages to the workers while reading the database, emit is
+// called by the workers with the failing files and their position in database order.
+func (d *RpmDB) runVerify(ctx context.Context, policy *VerifyPolicy, names []string, emit func(seq int, result *VerifyResult)) error {
+	if policy == nil {
+		policy = &VerifyPolicy{}
+	}
+	v := newVerifier(policy)
+	concurrency := policy.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	jobs := make(chan verifyJob)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				if result := v.verifyFile(ctx, job); result != nil {
+					emit(job.seq, result)
+				}
+			}
+		}()
+	}
+
+	seq := 0
+	err := d.forEachPackage(func(hnum uint32, indexEntries []indexEntry, pkg *PackageInfo) error {
+		if len(names) > 0 && !containsString(names, pkg.Name) {
+			return nil
+		}
+		files, err := fileInfos(indexEntries)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pkg.NEVRA(), err)
+		}
+		// packages built before FILEDIGESTALGO have md5 digests
+		algo, err := digestAlgoTag(indexEntries, RPMTAG_FILEDIGESTALGO, PGPHASHALGO_MD5)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pkg.NEVRA(), err)
+		}
+
+		for _, file := range files {
+			if file.Flags&policy.SkipFlags != 0 || (policy.OnlyFlags != 0 && file.Flags&policy.OnlyFlags == 0) {
+				continue
+			}
+			if !policy.selected(file.Path) {
+				continue
+			}
+			select {
+			case jobs <- verifyJob{seq: seq, pkg: pkg, file: file, algo: algo}:
+				seq++
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+	close(jobs)
+	wg.Wait()
+
+	if err != nil {
+		return err
+	}
+	// canceled while the last files were checked
+	return ctx.Err()
+}
+
+// rateLimiter spaces out events so that they don't exceed rate per second on average, by booking
+// a time slot for every one. A nil limiter doesn't limit.
+type rateLimiter struct {
+	mu   sync.Mutex
+	rate float64
+	next time.Time
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{rate: rate}
+}
+
+// wait blocks until n events can happen.
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	start := l.next
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	l.mu.Unlock()
+
+	delay := start.Sub(now)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type rateLimitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rateLimiter
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if werr := r.limiter.wait(r.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
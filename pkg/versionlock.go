@@ -0,0 +1,125 @@
+package rpmdb
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// VersionLockPaths are the versionlock lists of the dnf and yum plugins, in the order
+// ReadRootVersionLocks tries them.
+var VersionLockPaths = []string{
+	"/etc/dnf/plugins/versionlock.list",
+	"/etc/yum/pluginconf.d/versionlock.list",
+}
+
+// VersionLocks are the entries of versionlock lists. Entries are package specs with shell globs:
+// "bash-0:4.2.46-30.el7.*" as written by dnf versionlock add, "0:bash-4.2.46-30.el7.*" by yum, or
+// shorter forms down to a bare name. Excludes, the entries starting with "!", are versions the
+// package manager must not install: they lock nothing installed.
+type VersionLocks struct {
+	Locks    []string
+	Excludes []string
+}
+
+// ReadVersionLocks parses a versionlock list, blank lines and lines starting with # being skipped.
+func ReadVersionLocks(r io.Reader) (*VersionLocks, error) {
+	locks := &VersionLocks{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		entry := strings.TrimPrefix(text, "!")
+		if _, err := path.Match(entry, ""); err != nil {
+			return nil, fmt.Errorf("line %d: invalid entry %q: %w", line, text, err)
+		}
+		if entry != text {
+			locks.Excludes = append(locks.Excludes, entry)
+		} else {
+			locks.Locks = append(locks.Locks, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read versionlock list: %w", err)
+	}
+	return locks, nil
+}
+
+// ReadVersionLockFile is ReadVersionLocks on a file.
+func ReadVersionLockFile(path string) (*VersionLocks, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadVersionLocks(f)
+}
+
+// ReadRootVersionLocks reads the VersionLockPaths of a root filesystem, an image or a mounted
+// host, merged. The lists missing are skipped: a root without any has no lock.
+func ReadRootVersionLocks(root string) (*VersionLocks, error) {
+	merged := &VersionLocks{}
+	for _, lockPath := range VersionLockPaths {
+		host, err := resolveInRoot(root, lockPath, true)
+		if err != nil {
+			return nil, err
+		}
+		locks, err := ReadVersionLockFile(host)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", lockPath, err)
+		}
+		merged.Locks = append(merged.Locks, locks.Locks...)
+		merged.Excludes = append(merged.Excludes, locks.Excludes...)
+	}
+	return merged, nil
+}
+
+// Locked reports whether a lock entry matches the package.
+func (l *VersionLocks) Locked(pkg *PackageInfo) bool {
+	specs := lockSpecs(pkg)
+	for _, lock := range l.Locks {
+		for _, spec := range specs {
+			if ok, _ := path.Match(lock, spec); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lockSpecs are the forms an entry can name a package with, from the bare name to the full NEVRA
+// of dnf and yum.
+func lockSpecs(pkg *PackageInfo) []string {
+	epoch := strconv.Itoa(pkg.Epoch)
+	nvr := pkg.Name + "-" + pkg.Version + "-" + pkg.Release
+	return []string{
+		pkg.Name,
+		pkg.Name + "." + pkg.Arch,
+		pkg.Name + "-" + pkg.Version,
+		nvr,
+		nvr + "." + pkg.Arch,
+		pkg.Name + "-" + epoch + ":" + pkg.Version + "-" + pkg.Release + "." + pkg.Arch,
+		epoch + ":" + nvr + "." + pkg.Arch,
+	}
+}
+
+// ApplyVersionLocks sets PackageInfo.VersionLocked for the packages one of the lists locks.
+func ApplyVersionLocks(pkgList []*PackageInfo, locks ...*VersionLocks) {
+	for _, pkg := range pkgList {
+		for _, l := range locks {
+			if l.Locked(pkg) {
+				pkg.VersionLocked = true
+				break
+			}
+		}
+	}
+}
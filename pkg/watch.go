@@ -0,0 +1,340 @@
+package rpmdb
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WatchOptions configures Watch and WatchFunc.
+type WatchOptions struct {
+	// Settle is how long the database must stay unchanged before it is scanned, rpm writing it in
+	// several steps during a transaction: a second when zero.
+	Settle time.Duration
+	// PollInterval is how often the files of the database are checked for changes, a stat of each:
+	// two seconds when zero. They are polled on every system, inotify being out of reach without
+	// syscall.
+	PollInterval time.Duration
+	// Options are the ones the database is opened with for every scan.
+	Options []Option
+	// OnError is called with the errors of the scans following the first one, nil ignoring them.
+	// A database that rpm is rewriting may not read: it is scanned again at the next check.
+	OnError func(err error)
+	// Previous is the manifest of the last delta handled before the watch was stopped, nil if
+	// none: the first delta then has what changed since rather than every package. The packages
+	// removed meanwhile only have the name, EVR and arch of their manifest entry.
+	Previous *PackageManifest
+}
+
+// PackageDelta is what changed in a database between two scans.
+type PackageDelta struct {
+	// Time is when the database was scanned.
+	Time time.Time
+	// Added are the packages installed since the previous scan, every package at the first one.
+	Added []*PackageInfo
+	// Removed are the packages erased since the previous scan.
+	Removed []*PackageInfo
+	// Changed are the packages installed in another version or build, matched by name and arch:
+	// upgraded, downgraded or rebuilt.
+	Changed []*PackageChange
+	// Manifest is the manifest of the database as scanned, in sha256: saving it lets an agent tell
+	// what it missed once restarted.
+	Manifest *PackageManifest
+
+	// the INSTALLTID of the packages added, or changed to, the transaction that installed them
+	installTIDs map[*PackageInfo]uint32
+}
+
+// PackageChange is a package replaced by another version or build of it.
+type PackageChange struct {
+	Before *PackageInfo
+	After  *PackageInfo
+}
+
+// Empty reports whether nothing changed.
+func (d *PackageDelta) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// WatchFunc scans the database at path, then scans it again each time rpm modifies it, calling fn
+// with what changed since the previous scan: every package is added at the first call, unless
+// opts.Previous tells what the previous scan was. The files of the database are checked every
+// PollInterval; they are only scanned once they changed and settled, a query of rpm triggering
+// nothing. It returns the error of the first scan or of fn, or ctx.Err() once ctx is canceled.
+func WatchFunc(ctx context.Context, path string, opts *WatchOptions, fn func(delta *PackageDelta) error) error {
+	if opts == nil {
+		opts = &WatchOptions{}
+	}
+	settle := opts.Settle
+	if settle <= 0 {
+		settle = time.Second
+	}
+	poll := opts.PollInterval
+	if poll <= 0 {
+		poll = 2 * time.Second
+	}
+
+	previous := &watchSnapshot{}
+	if opts.Previous != nil {
+		if opts.Previous.Algorithm != hashNames[crypto.SHA256] {
+			return fmt.Errorf("previous manifest in %s, the watch manifests are in sha256", opts.Previous.Algorithm)
+		}
+		previous = manifestSnapshot(opts.Previous)
+	}
+
+	stamp := stampDatabase(path)
+	snapshot, err := scanSnapshot(path, opts.Options)
+	if err != nil {
+		return err
+	}
+	if err := fn(diffSnapshots(previous, snapshot)); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		current := stampDatabase(path)
+		if current.equal(stamp) {
+			continue
+		}
+		for settled := false; !settled; {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(settle):
+			}
+			next := stampDatabase(path)
+			settled = next.equal(current)
+			current = next
+		}
+
+		next, err := scanSnapshot(path, opts.Options)
+		if err != nil {
+			// the stamp is kept: the next check scans again
+			if opts.OnError != nil {
+				opts.OnError(err)
+			}
+			continue
+		}
+		stamp = current
+		delta := diffSnapshots(snapshot, next)
+		snapshot = next
+		if delta.Empty() {
+			continue
+		}
+		if err := fn(delta); err != nil {
+			return err
+		}
+	}
+}
+
+// Watcher is a watch started by Watch.
+type Watcher struct {
+	// Deltas receives the changes of the database, the first delta having every package. It is
+	// closed once the watch ends.
+	Deltas <-chan *PackageDelta
+
+	done chan struct{}
+	err  error
+}
+
+// Err returns the error that ended the watch, ctx.Err() once its context is canceled. It blocks
+// until Deltas is closed, Deltas must be drained or the context canceled first.
+func (w *Watcher) Err() error {
+	<-w.done
+	return w.err
+}
+
+// Watch watches the database at path like WatchFunc, sending the deltas to a channel.
+func Watch(ctx context.Context, path string, opts *WatchOptions) *Watcher {
+	deltas := make(chan *PackageDelta)
+	w := &Watcher{Deltas: deltas, done: make(chan struct{})}
+	go func() {
+		defer close(w.done)
+		w.err = WatchFunc(ctx, path, opts, func(delta *PackageDelta) error {
+			select {
+			case deltas <- delta:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		close(deltas)
+	}()
+	return w
+}
+
+// fileStamp tells whether a file changed, a missing file having the zero stamp. The modification
+// times are only as precise as the clock ticks of the file system: a file renamed over another,
+// as rpm --rebuilddb does, is told apart by its identity.
+type fileStamp struct {
+	info os.FileInfo
+}
+
+func (s fileStamp) equal(other fileStamp) bool {
+	if s.info == nil || other.info == nil {
+		return s.info == other.info
+	}
+	return s.info.Size() == other.info.Size() && s.info.ModTime().Equal(other.info.ModTime()) && os.SameFile(s.info, other.info)
+}
+
+// databaseStamp is the stamp of the database and of the write-ahead log of an rpmdb.sqlite, where
+// the transactions go until they are checkpointed.
+type databaseStamp [2]fileStamp
+
+func stampDatabase(path string) databaseStamp {
+	var stamp databaseStamp
+	for i, file := range []string{path, path + "-wal"} {
+		if info, err := os.Stat(file); err == nil {
+			stamp[i] = fileStamp{info: info}
+		}
+	}
+	return stamp
+}
+
+func (s databaseStamp) equal(other databaseStamp) bool {
+	return s[0].equal(other[0]) && s[1].equal(other[1])
+}
+
+// watchSnapshot is a scan of the database: its manifest, the packages of each entry and their
+// INSTALLTID.
+type watchSnapshot struct {
+	manifest    *PackageManifest
+	packages    map[ManifestEntry][]*PackageInfo
+	installTIDs map[*PackageInfo]uint32
+}
+
+// manifestSnapshot returns the snapshot of a saved manifest, its packages being made from the
+// entries.
+func manifestSnapshot(manifest *PackageManifest) *watchSnapshot {
+	snapshot := &watchSnapshot{manifest: manifest, packages: make(map[ManifestEntry][]*PackageInfo)}
+	for _, entry := range manifest.Entries {
+		snapshot.packages[entry] = append(snapshot.packages[entry], packageFromNEVRA(entry.NEVRA))
+	}
+	return snapshot
+}
+
+// packageFromNEVRA returns the package of "name-[epoch:]version-release.arch", the arch being the
+// last dot separated part of the release like for manifestKey.
+func packageFromNEVRA(nevra string) *PackageInfo {
+	pkg := &PackageInfo{Name: nevra}
+	if i := strings.LastIndexByte(pkg.Name, '-'); i >= 0 {
+		pkg.Name, pkg.Release = pkg.Name[:i], pkg.Name[i+1:]
+	}
+	if i := strings.LastIndexByte(pkg.Name, '-'); i >= 0 {
+		pkg.Name, pkg.Version = pkg.Name[:i], pkg.Name[i+1:]
+	}
+	if i := strings.LastIndexByte(pkg.Release, '.'); i >= 0 {
+		pkg.Release, pkg.Arch = pkg.Release[:i], pkg.Release[i+1:]
+	}
+	if i := strings.IndexByte(pkg.Version, ':'); i >= 0 {
+		if epoch, err := strconv.Atoi(pkg.Version[:i]); err == nil {
+			pkg.Epoch, pkg.Version = epoch, pkg.Version[i+1:]
+		}
+	}
+	return pkg
+}
+
+func scanSnapshot(path string, opts []Option) (*watchSnapshot, error) {
+	db, err := Open(path, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	snapshot := &watchSnapshot{
+		manifest:    &PackageManifest{Algorithm: hashNames[crypto.SHA256]},
+		packages:    make(map[ManifestEntry][]*PackageInfo),
+		installTIDs: make(map[*PackageInfo]uint32),
+	}
+	var pkgList []*PackageInfo
+	var keyIDs []string
+	var signed []bool
+	err = db.forEachHeader(func(hnum uint32, blob []byte) error {
+		indexEntries, err := headerImport(blob)
+		if err != nil {
+			return fmt.Errorf("error during importing header: %w", err)
+		}
+		pkg, err := getNEVRA(indexEntries)
+		if err != nil {
+			return fmt.Errorf("invalid package info: %w", err)
+		}
+		if db.interner != nil {
+			db.interner.internPackage(pkg)
+		}
+		digest, err := headerDigest(crypto.SHA256, blob)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pkg.NEVRA(), err)
+		}
+		entry := ManifestEntry{NEVRA: pkg.NEVRA(), Digest: digest}
+		snapshot.manifest.Entries = append(snapshot.manifest.Entries, entry)
+		snapshot.packages[entry] = append(snapshot.packages[entry], pkg)
+		if entry := findEntry(indexEntries, RPMTAG_INSTALLTID); entry != nil {
+			if tids, err := entryUint32s(entry); err == nil && len(tids) > 0 {
+				snapshot.installTIDs[pkg] = tids[0]
+			}
+		}
+
+		pkgList = append(pkgList, pkg)
+		keyID, ok := signatureKeyID(indexEntries)
+		keyIDs = append(keyIDs, db.intern(keyID))
+		signed = append(signed, ok)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	attributeSignatures(pkgList, keyIDs, signed)
+
+	snapshot.manifest.seal(crypto.SHA256)
+	return snapshot, nil
+}
+
+// diffSnapshots returns the delta from prev to next, the packages of the entries DiffManifests
+// reports being taken from the snapshots.
+func diffSnapshots(prev, next *watchSnapshot) *PackageDelta {
+	delta := &PackageDelta{Time: time.Now().UTC(), Manifest: next.manifest, installTIDs: next.installTIDs}
+	before := &PackageManifest{Algorithm: next.manifest.Algorithm}
+	if prev.manifest != nil {
+		before = prev.manifest
+	}
+	take := func(packages map[ManifestEntry][]*PackageInfo, entry ManifestEntry) *PackageInfo {
+		pkgs := packages[entry]
+		packages[entry] = pkgs[1:]
+		return pkgs[0]
+	}
+	// the snapshots are kept, the packages are taken from copies of their maps
+	prevPackages := make(map[ManifestEntry][]*PackageInfo, len(prev.packages))
+	for entry, pkgs := range prev.packages {
+		prevPackages[entry] = pkgs
+	}
+	nextPackages := make(map[ManifestEntry][]*PackageInfo, len(next.packages))
+	for entry, pkgs := range next.packages {
+		nextPackages[entry] = pkgs
+	}
+
+	diff := DiffManifests(before, next.manifest)
+	for _, entry := range diff.Added {
+		delta.Added = append(delta.Added, take(nextPackages, entry))
+	}
+	for _, entry := range diff.Removed {
+		delta.Removed = append(delta.Removed, take(prevPackages, entry))
+	}
+	for _, drift := range diff.Drifted {
+		delta.Changed = append(delta.Changed, &PackageChange{
+			Before: take(prevPackages, drift.Expected),
+			After:  take(nextPackages, drift.Actual),
+		})
+	}
+	return delta
+}
@@ -0,0 +1,248 @@
+package rpmdb
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// WhoOwnsOptions configures WhoOwns.
+type WhoOwnsOptions struct {
+	// Canonicalize cleans the path (repeated and trailing slashes, "." and "..") and resolves its
+	// directory with the symlinks the packages install, the /bin -> usr/bin of merged /usr
+	// systems or /lib64 -> usr/lib64 for instance, like the file fingerprints of rpm. Every header
+	// is read to find the symlinks. The last component isn't followed: a symlink is owned by the
+	// package installing it, not the one of its target.
+	Canonicalize bool
+}
+
+// WhoOwns returns the packages owning file, like `rpm -qf`. Without opts.Canonicalize, file must
+// be spelled as the packages record it. The Basenames index database is used to narrow down the
+// candidates when it is present.
+func (d *RpmDB) WhoOwns(file string, opts *WhoOwnsOptions) ([]*PackageInfo, error) {
+	if opts == nil {
+		opts = &WhoOwnsOptions{}
+	}
+	if !strings.HasPrefix(file, "/") {
+		return nil, fmt.Errorf("not an absolute path: %q", file)
+	}
+
+	var match func(files []string) (bool, error)
+	if opts.Canonicalize {
+		canonical, err := d.canonicalizer()
+		if err != nil {
+			return nil, err
+		}
+		file = path.Clean(file)
+		dir, err := canonical(path.Dir(file))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		// the packages of a merged /usr system record files on both sides of the symlinks, glibc
+		// keeping /lib64 for instance: their directories are resolved too, the base name isn't
+		match = func(files []string) (bool, error) {
+			for _, f := range files {
+				if path.Base(f) != path.Base(file) {
+					continue
+				}
+				fileDir, err := canonical(path.Dir(f))
+				if err != nil {
+					return false, fmt.Errorf("%s: %w", f, err)
+				}
+				if fileDir == dir {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+	} else {
+		match = func(files []string) (bool, error) {
+			return containsString(files, file), nil
+		}
+	}
+
+	items, indexed, err := d.lookupIndex("Basenames", path.Base(file))
+	if err != nil {
+		return nil, err
+	}
+	candidates := make(map[uint32]struct{})
+	for _, item := range items {
+		candidates[item.hdrNum] = struct{}{}
+	}
+
+	var pkgList []*PackageInfo
+	err = d.forEachCandidate(candidates, indexed, func(hnum uint32, blob []byte) error {
+		indexEntries, err := headerImport(blob)
+		if err != nil {
+			return fmt.Errorf("error during importing header: %w", err)
+		}
+		files, err := fileNames(indexEntries)
+		if err != nil {
+			return err
+		}
+		if ok, err := match(files); err != nil || !ok {
+			return err
+		}
+
+		pkg, err := getNEVRA(indexEntries)
+		if err != nil {
+			return fmt.Errorf("invalid package info: %w", err)
+		}
+		pkgList = append(pkgList, pkg)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pkgList, nil
+}
+
+// canonicalizer returns a function resolving the symlinks of directories with the ones recorded
+// in the database, the results being cached.
+func (d *RpmDB) canonicalizer() (func(dir string) (string, error), error) {
+	links := make(map[string]string)
+	err := d.forEachPackage(func(hnum uint32, indexEntries []indexEntry, pkg *PackageInfo) error {
+		files, err := fileInfos(indexEntries)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pkg.NEVRA(), err)
+		}
+		for _, f := range files {
+			if uint32(f.Mode)&modeTypeMask == modeSymlink && f.LinkTo != "" {
+				links[f.Path] = f.LinkTo
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := make(map[string]string)
+	return func(dir string) (string, error) {
+		if r, ok := resolved[dir]; ok {
+			return r, nil
+		}
+		r, err := resolveLinks(dir, links)
+		if err != nil {
+			return "", err
+		}
+		resolved[dir] = r
+		return r, nil
+	}, nil
+}
+
+// resolveLinks resolves the symlinks of the absolute dir, relative targets being relative to the
+// directory of the link.
+func resolveLinks(dir string, links map[string]string) (string, error) {
+	resolved := "/"
+	rest := strings.Split(strings.TrimPrefix(dir, "/"), "/")
+	for hops := 0; len(rest) > 0; {
+		component := rest[0]
+		rest = rest[1:]
+		if component == "" {
+			continue
+		}
+		next := path.Join(resolved, component)
+		target, ok := links[next]
+		if !ok {
+			resolved = next
+			continue
+		}
+
+		if hops++; hops > maxSymlinks {
+			return "", fmt.Errorf("too many levels of symbolic links")
+		}
+		if strings.HasPrefix(target, "/") {
+			resolved = "/"
+		}
+		rest = append(strings.Split(target, "/"), rest...)
+	}
+	return resolved, nil
+}
+
+// WhoOwnsAll is WhoOwns for many paths at once, the headers being read once whatever the number of
+// paths: the binaries of the running processes an agent collected for instance. The owners are
+// keyed by the paths as given, the ones without owner being left out. A package owning several of
+// the paths is the same *PackageInfo in every list.
+func (d *RpmDB) WhoOwnsAll(paths []string, opts *WhoOwnsOptions) (map[string][]*PackageInfo, error) {
+	if opts == nil {
+		opts = &WhoOwnsOptions{}
+	}
+	// the recorded files are canonicalized alike when looked up
+	canonicalFile := func(file string) (string, error) { return file, nil }
+	if opts.Canonicalize {
+		canonical, err := d.canonicalizer()
+		if err != nil {
+			return nil, err
+		}
+		canonicalFile = func(file string) (string, error) {
+			file = path.Clean(file)
+			dir, err := canonical(path.Dir(file))
+			if err != nil {
+				return "", fmt.Errorf("%s: %w", file, err)
+			}
+			return path.Join(dir, path.Base(file)), nil
+		}
+	}
+
+	queries := make(map[string][]string)
+	// canonicalizing keeps the base name, the files of other names are skipped right away
+	baseNames := make(map[string]bool)
+	for _, file := range paths {
+		if !strings.HasPrefix(file, "/") {
+			return nil, fmt.Errorf("not an absolute path: %q", file)
+		}
+		key, err := canonicalFile(file)
+		if err != nil {
+			return nil, err
+		}
+		baseNames[path.Base(key)] = true
+		if !containsString(queries[key], file) {
+			queries[key] = append(queries[key], file)
+		}
+	}
+
+	owners := make(map[string][]*PackageInfo)
+	err := d.forEachHeader(func(hnum uint32, blob []byte) error {
+		indexEntries, err := headerImport(blob)
+		if err != nil {
+			return fmt.Errorf("error during importing header: %w", err)
+		}
+		files, err := fileNames(indexEntries)
+		if err != nil {
+			return err
+		}
+
+		var pkg *PackageInfo
+		for _, f := range files {
+			if !baseNames[path.Base(f)] {
+				continue
+			}
+			key, err := canonicalFile(f)
+			if err != nil {
+				return err
+			}
+			matches, ok := queries[key]
+			if !ok {
+				continue
+			}
+			if pkg == nil {
+				if pkg, err = getNEVRA(indexEntries); err != nil {
+					return fmt.Errorf("invalid package info: %w", err)
+				}
+			}
+			for _, file := range matches {
+				// a package listing a file twice owns it once
+				if n := len(owners[file]); n == 0 || owners[file][n-1] != pkg {
+					owners[file] = append(owners[file], pkg)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return owners, nil
+}